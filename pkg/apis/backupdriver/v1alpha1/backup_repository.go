@@ -28,6 +28,15 @@ type BackupRepository struct {
 	RepositoryParameters  map[string]string `json:"repopsitoryParameters"`
 	BackupRepositoryClaim string            `json:"backupRepositoryClaim"`
 
+	// RepositoryCredential references the Secret holding the credentials of the repository.
+	// The credentials are never stored in RepositoryParameters.
+	// +optional
+	RepositoryCredential *SecretKeyReference `json:"repositoryCredential,omitempty"`
+
+	// RepositoryEncryption references the Secret holding the keys to encrypt the snapshots in the repository with.
+	// +optional
+	RepositoryEncryption *RepositoryEncryption `json:"repositoryEncryption,omitempty"`
+
 	// +optional
 	SvcBackupRepositoryName string `json:"svcBackupRepositoryName"`
 }
@@ -68,6 +77,14 @@ type BackupRepositoryClaim struct {
 	RepositoryDriver     string            `json:"repositoryDriver"`
 	RepositoryParameters map[string]string `json:"repopsitoryParameters"`
 
+	// RepositoryCredential references the Secret holding the credentials of the repository.
+	// +optional
+	RepositoryCredential *SecretKeyReference `json:"repositoryCredential,omitempty"`
+
+	// RepositoryEncryption references the Secret holding the keys to encrypt the snapshots in the repository with.
+	// +optional
+	RepositoryEncryption *RepositoryEncryption `json:"repositoryEncryption,omitempty"`
+
 	// +optional
 	BackupRepository string `json:"backupRepository,omitempty"`
 }
@@ -83,3 +100,21 @@ type BackupRepositoryClaimList struct {
 
 	Items []BackupRepositoryClaim `json:"items"`
 }
+
+// SecretKeyReference references a key of a Secret in the given namespace.
+// For the s3 repository driver, the value of the key is an AWS shared credentials file.
+type SecretKeyReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+}
+
+// RepositoryEncryption references the Secret holding the encryption keys of a repository. Each key of the Secret is
+// a key ID, whose value is a 256-bit key, either raw or base64 encoded. New snapshots are encrypted with the key
+// ActiveKeyID. The key ID is recorded with each encrypted snapshot, so the snapshots encrypted with an older key can
+// be restored as long as the key is kept in the Secret.
+type RepositoryEncryption struct {
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	ActiveKeyID string `json:"activeKeyID"`
+}
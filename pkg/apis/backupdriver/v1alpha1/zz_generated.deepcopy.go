@@ -42,6 +42,16 @@ func (in *BackupRepository) DeepCopyInto(out *BackupRepository) {
 			(*out)[key] = val
 		}
 	}
+	if in.RepositoryCredential != nil {
+		in, out := &in.RepositoryCredential, &out.RepositoryCredential
+		*out = new(SecretKeyReference)
+		**out = **in
+	}
+	if in.RepositoryEncryption != nil {
+		in, out := &in.RepositoryEncryption, &out.RepositoryEncryption
+		*out = new(RepositoryEncryption)
+		**out = **in
+	}
 	return
 }
 
@@ -80,6 +90,16 @@ func (in *BackupRepositoryClaim) DeepCopyInto(out *BackupRepositoryClaim) {
 			(*out)[key] = val
 		}
 	}
+	if in.RepositoryCredential != nil {
+		in, out := &in.RepositoryCredential, &out.RepositoryCredential
+		*out = new(SecretKeyReference)
+		**out = **in
+	}
+	if in.RepositoryEncryption != nil {
+		in, out := &in.RepositoryEncryption, &out.RepositoryEncryption
+		*out = new(RepositoryEncryption)
+		**out = **in
+	}
 	return
 }
 
@@ -376,6 +396,38 @@ func (in *DeleteSnapshotStatus) DeepCopy() *DeleteSnapshotStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryEncryption) DeepCopyInto(out *RepositoryEncryption) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryEncryption.
+func (in *RepositoryEncryption) DeepCopy() *RepositoryEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyReference) DeepCopyInto(out *SecretKeyReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyReference.
+func (in *SecretKeyReference) DeepCopy() *SecretKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Snapshot) DeepCopyInto(out *Snapshot) {
 	*out = *in
@@ -43,10 +43,13 @@ type DownloadSpec struct {
 	// It is used to update the download status in the clonefromsnapshot.
 	// +optional
 	CloneFromSnapshotReference string `json:"clonefromSnapshotReference,omitempty"`
+
+	// DownloadCancel indicates request to cancel ongoing download.
+	DownloadCancel bool `json:"downloadCancel,omitempty"`
 }
 
 // DownloadPhase represents the lifecycle phase of a Download.
-// +kubebuilder:validation:Enum=New;InProgress;Completed;Retry;Failed
+// +kubebuilder:validation:Enum=New;InProgress;Completed;Retry;Failed;Canceling;Canceled
 type DownloadPhase string
 
 const (
@@ -55,6 +58,8 @@ const (
 	DownloadPhaseCompleted  DownloadPhase = "Completed"
 	DownLoadPhaseRetry      DownloadPhase = "Retry"
 	DownloadPhaseFailed     DownloadPhase = "Failed"
+	DownloadPhaseCanceling  DownloadPhase = "Canceling"
+	DownloadPhaseCanceled   DownloadPhase = "Canceled"
 )
 
 // DownloadStatus is the current status of a Download.
@@ -105,6 +110,11 @@ type DownloadStatus struct {
 	// +optional
 	// +nullable
 	NextRetryTimestamp *meta_v1.Time `json:"nextRetryTimestamp,omitempty"`
+
+	// CurrentBackOff records the backoff on retry for failed download. Retry on download should obey
+	// exponential backoff mechanism.
+	// +optional
+	CurrentBackOff int32 `json:"currentBackOff,omitempty"`
 }
 
 // DownloadOperationProgress represents the progress of a
@@ -32,6 +32,11 @@ type UploadSpec struct {
 	// UploadCancel indicates request to cancel ongoing upload.
 	UploadCancel bool `json:"uploadCancel,omitempty"`
 
+	// UploadRetry indicates request to retry a failed upload. It is reset once the
+	// upload is re-armed.
+	// +optional
+	UploadRetry bool `json:"uploadRetry,omitempty"`
+
 	// BackupRepository provides backup repository info for upload. Used for
 	// multiple backup repository.
 	BackupRepositoryName string `json:"backupRepository,omitempty"`
@@ -44,7 +49,7 @@ type UploadSpec struct {
 }
 
 // UploadPhase represents the lifecycle phase of a Upload.
-// +kubebuilder:validation:Enum=New;InProgress;Completed;UploadError;CleanupFailed;Canceled;Canceling;
+// +kubebuilder:validation:Enum=New;InProgress;Completed;UploadError;CleanupFailed;Canceled;Canceling;Failed;
 type UploadPhase string
 
 const (
@@ -55,6 +60,7 @@ const (
 	UploadPhaseCleanupFailed UploadPhase = "CleanupFailed"
 	UploadPhaseCanceling     UploadPhase = "Canceling"
 	UploadPhaseCanceled      UploadPhase = "Canceled"
+	UploadPhaseFailed        UploadPhase = "Failed"
 )
 
 // UploadStatus is the current status of a Upload.
@@ -82,7 +88,8 @@ type UploadStatus struct {
 
 	// Progress holds the total number of bytes of the volume and the current
 	// number of backed up bytes. This can be used to display progress information
-	// about the backup operation.
+	// about the backup operation. Once the upload is completed, it also holds the
+	// number of bytes stored in the backup repository, after compression.
 	// +optional
 	Progress UploadOperationProgress `json:"progress,omitempty"`
 
@@ -117,6 +124,11 @@ type UploadOperationProgress struct {
 
 	// +optional
 	BytesDone int64 `json:"bytesDone,omitempty"`
+
+	// StoredBytes is the number of bytes of the volume data stored in the
+	// backup repository, which is less than BytesDone if the data is compressed.
+	// +optional
+	StoredBytes int64 `json:"storedBytes,omitempty"`
 }
 
 // +genclient
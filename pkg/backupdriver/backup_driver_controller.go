@@ -76,10 +76,12 @@ func (ctrl *backupDriverController) createSnapshot(snapshot *backupdriverapi.Sna
 		brName = br.SvcBackupRepositoryName
 	}
 	snapshotStatusFields := make(map[string]interface{})
+	ctrl.metrics.RegisterSnapshotAttempt()
 	peID, svcSnapshotName, err := ctrl.snapManager.CreateSnapshotWithBackupRepository(peID, tags, brName, snapshot.Namespace+"/"+snapshot.Name, snapshot.Labels[constants.SnapshotBackupLabel])
 	if err != nil {
 		errMsg := fmt.Sprintf("createSnapshot: Failed at calling SnapshotManager CreateSnapshot from peID %v , Error: %v", peID, err)
 		ctrl.logger.Errorf(errMsg)
+		ctrl.metrics.RegisterSnapshotFailed()
 		snapshotStatusFields["Message"] = errMsg
 		_, statusUpdateErr := ctrl.updateSnapshotStatusPhase(ctx, snapshot.Namespace, snapshot.Name, backupdriverapi.SnapshotPhaseSnapshotFailed, snapshotStatusFields)
 		if statusUpdateErr != nil {
@@ -88,6 +90,8 @@ func (ctrl *backupDriverController) createSnapshot(snapshot *backupdriverapi.Sna
 		return err
 	}
 
+	ctrl.metrics.RegisterSnapshotSuccess()
+
 	// Construct the snapshotID for cns volume
 	snapshotID := peID.String()
 	ctrl.logger.Infof("createSnapshot: The snapshotID depends on the Astrolabe PE ID in the format, <peType>:<id>:<snapshotID>, %s", snapshotID)
@@ -128,6 +132,75 @@ func (ctrl *backupDriverController) createSnapshot(snapshot *backupdriverapi.Sna
 	return nil
 }
 
+// cancelSnapshot drives a Snapshot through Canceling to Canceled. The upload of the local snapshot is canceled,
+// or for Guest Clusters the Supervisor Cluster snapshot, and the local snapshot is deleted once nothing uses it anymore.
+// A Snapshot which is done before the cancellation takes effect lands in its own phase instead, e.g. Uploaded.
+func (ctrl *backupDriverController) cancelSnapshot(snapshot *backupdriverapi.Snapshot) error {
+	ctrl.logger.Infof("Entering cancelSnapshot: %s/%s, Phase %s", snapshot.Namespace, snapshot.Name, snapshot.Status.Phase)
+	ctx := context.Background()
+	snapshotStatusFields := make(map[string]interface{})
+
+	if snapshot.Status.Phase == backupdriverapi.SnapshotPhaseNew || snapshot.Status.SnapshotID == "" {
+		// No local snapshot was taken yet, nothing to clean up
+		snapshotStatusFields["Message"] = "Snapshot was canceled before the local snapshot was taken"
+		_, err := ctrl.updateSnapshotStatusPhase(ctx, snapshot.Namespace, snapshot.Name, backupdriverapi.SnapshotPhaseCanceled, snapshotStatusFields)
+		return err
+	}
+
+	if ctrl.snapManager == nil {
+		errMsg := fmt.Sprintf("snapManager is not initialized.")
+		ctrl.logger.Error(errMsg)
+		return errors.New(errMsg)
+	}
+
+	if snapshot.Status.Phase != backupdriverapi.SnapshotPhaseCanceling {
+		snapshotStatusFields["Message"] = "Canceling snapshot"
+		_, err := ctrl.updateSnapshotStatusPhase(ctx, snapshot.Namespace, snapshot.Name, backupdriverapi.SnapshotPhaseCanceling, snapshotStatusFields)
+		if err != nil {
+			return err
+		}
+	}
+
+	peID, err := astrolabe.NewProtectedEntityIDFromString(snapshot.Status.SnapshotID)
+	if err != nil {
+		ctrl.logger.WithError(err).Errorf("Fail to construct new Protected Entity ID from string %s", snapshot.Status.SnapshotID)
+		return err
+	}
+	newPhase, err := ctrl.snapManager.CancelSnapshot(peID, snapshot.Status.SvcSnapshotName)
+	if err != nil {
+		ctrl.logger.WithError(err).Errorf("cancelSnapshot: Failed at calling SnapshotManager CancelSnapshot for peID %v", peID)
+		return err
+	}
+	switch newPhase {
+	case backupdriverapi.SnapshotPhaseCanceling:
+		ctrl.logger.Infof("cancelSnapshot: Cancellation of snapshot %s/%s is in progress", snapshot.Namespace, snapshot.Name)
+		return nil
+	case backupdriverapi.SnapshotPhaseCanceled:
+		snapshotStatusFields["Message"] = "Snapshot was canceled"
+	default:
+		// The snapshot was done before the cancellation took effect, e.g. its data is already in the repository
+		snapshotStatusFields["Message"] = fmt.Sprintf("Snapshot was not canceled, it is already %s", newPhase)
+	}
+
+	_, err = ctrl.updateSnapshotStatusPhase(ctx, snapshot.Namespace, snapshot.Name, newPhase, snapshotStatusFields)
+	if err != nil {
+		ctrl.logger.Errorf("cancelSnapshot: update status for snapshot %s/%s failed: %v", snapshot.Namespace, snapshot.Name, err)
+		return err
+	}
+	ctrl.logger.Infof("cancelSnapshot %s/%s completed in phase %s", snapshot.Namespace, snapshot.Name, newPhase)
+	return nil
+}
+
+// isSnapshotCancelable returns true if a Snapshot in the given phase still has work in flight which can be canceled.
+func isSnapshotCancelable(phase backupdriverapi.SnapshotPhase) bool {
+	switch phase {
+	case backupdriverapi.SnapshotPhaseNew, backupdriverapi.SnapshotPhaseSnapshotted, backupdriverapi.SnapshotPhaseUploading,
+		backupdriverapi.SnapshotPhaseUploadFailed, backupdriverapi.SnapshotPhaseCanceling:
+		return true
+	}
+	return false
+}
+
 func (ctrl *backupDriverController) deleteSnapshot(deleteSnapshot *backupdriverapi.DeleteSnapshot) error {
 	ctrl.logger.Infof("deleteSnapshot called with SnapshotID %s, Namespace: %s, Name: %s",
 		deleteSnapshot.Spec.SnapshotID, deleteSnapshot.Namespace, deleteSnapshot.Name)
@@ -232,6 +305,13 @@ func (ctrl *backupDriverController) cloneFromSnapshot(cloneFromSnapshot *backupd
 		cloneFromSnapshot.Spec.SnapshotID, cloneFromSnapshot.Spec.BackupRepository, cloneFromSnapshot.Namespace, cloneFromSnapshot.Name)
 	if err != nil {
 		ctrl.logger.WithError(err).Errorf("Failed at calling SnapshotManager cloneFromSnapshot with peId %v", peId)
+		// The partially restored volume is cleaned up by the SnapshotManager if the clone was canceled
+		latestClone, getErr := ctrl.backupdriverClient.CloneFromSnapshots(cloneFromSnapshot.Namespace).Get(context.TODO(), cloneFromSnapshot.Name, metav1.GetOptions{})
+		if getErr == nil && latestClone.Spec.CloneCancel {
+			ctrl.logger.Infof("cloneFromSnapshot %s/%s was canceled", cloneFromSnapshot.Namespace, cloneFromSnapshot.Name)
+			_, err = ctrl.updateCloneFromSnapshotStatusPhase(context.TODO(), cloneFromSnapshot.Namespace, cloneFromSnapshot.Name,
+				backupdriverapi.ClonePhaseCanceled, "CloneFromSnapshot was canceled")
+		}
 		return err
 	}
 
@@ -254,7 +334,8 @@ func (ctrl *backupDriverController) updateSnapshotStatusPhase(ctx context.Contex
 		return nil, err
 	}
 
-	if snapshot.Status.Phase == newPhase {
+	// The progress of the upload can change without a phase transition
+	if snapshot.Status.Phase == newPhase && !isSnapshotProgressChanged(snapshot, snapshotStatusFields) {
 		ctrl.logger.Debugf("updateSnapshotStatusPhase: Snapshot %s/%s already updated with %s", snapshot.Namespace, snapshot.Name, newPhase)
 		return snapshot, nil
 	}
@@ -280,7 +361,7 @@ func (ctrl *backupDriverController) updateSnapshotStatusPhase(ctx context.Contex
 		snapshotClone.Status.Metadata = mdBuf.([]byte)
 	}
 
-	if newPhase == backupdriverapi.SnapshotPhaseUploaded {
+	if newPhase == backupdriverapi.SnapshotPhaseUploaded && snapshot.Status.Phase != newPhase {
 		snapshotClone.Status.CompletionTimestamp = &metav1.Time{Time: time.Now()}
 	}
 
@@ -294,6 +375,50 @@ func (ctrl *backupDriverController) updateSnapshotStatusPhase(ctx context.Contex
 	return updatedSnapshot, nil
 }
 
+func isSnapshotProgressChanged(snapshot *backupdriverapi.Snapshot, snapshotStatusFields map[string]interface{}) bool {
+	if totalBytes, ok := snapshotStatusFields["Progress.TotalBytes"]; ok && snapshot.Status.Progress.TotalBytes != totalBytes.(int64) {
+		return true
+	}
+	if bytesDone, ok := snapshotStatusFields["Progress.BytesDone"]; ok && snapshot.Status.Progress.BytesDone != bytesDone.(int64) {
+		return true
+	}
+	return false
+}
+
+// Update the cloneFromSnapshot status phase
+func (ctrl *backupDriverController) updateCloneFromSnapshotStatusPhase(ctx context.Context, cloneNs string, cloneName string,
+	newPhase backupdriverapi.ClonePhase, msg string) (*backupdriverapi.CloneFromSnapshot, error) {
+	ctrl.logger.Debugf("Entering updateCloneFromSnapshotStatusPhase: %s/%s, Phase %s", cloneNs, cloneName, newPhase)
+
+	// Retrieve the latest version of CloneFromSnapshot and update the status.
+	cloneFromSnapshot, err := ctrl.backupdriverClient.CloneFromSnapshots(cloneNs).Get(ctx, cloneName, metav1.GetOptions{})
+	if err != nil {
+		ctrl.logger.Errorf("updateCloneFromSnapshotStatusPhase: Failed to retrieve the latest CloneFromSnapshot state, error: %v", err)
+		return nil, err
+	}
+
+	if cloneFromSnapshot.Status.Phase == newPhase {
+		ctrl.logger.Debugf("updateCloneFromSnapshotStatusPhase: CloneFromSnapshot %s/%s already updated with %s", cloneFromSnapshot.Namespace, cloneFromSnapshot.Name, newPhase)
+		return cloneFromSnapshot, nil
+	}
+
+	cloneFromSnapshotClone := cloneFromSnapshot.DeepCopy()
+	cloneFromSnapshotClone.Status.Phase = newPhase
+	cloneFromSnapshotClone.Status.Message = msg
+	if newPhase == backupdriverapi.ClonePhaseCanceled {
+		cloneFromSnapshotClone.Status.CompletionTimestamp = &metav1.Time{Time: time.Now()}
+	}
+
+	updatedCloneFromSnapshot, err := ctrl.backupdriverClient.CloneFromSnapshots(cloneFromSnapshotClone.Namespace).UpdateStatus(ctx, cloneFromSnapshotClone, metav1.UpdateOptions{})
+	if err != nil {
+		ctrl.logger.Errorf("updateCloneFromSnapshotStatusPhase: update status for CloneFromSnapshot %s/%s failed: %v", cloneFromSnapshotClone.Namespace, cloneFromSnapshotClone.Name, err)
+		return nil, err
+	}
+	ctrl.logger.Infof("updateCloneFromSnapshotStatusPhase: CloneFromSnapshot %s/%s updated phase from %s to %s",
+		updatedCloneFromSnapshot.Namespace, updatedCloneFromSnapshot.Name, cloneFromSnapshot.Status.Phase, updatedCloneFromSnapshot.Status.Phase)
+	return updatedCloneFromSnapshot, nil
+}
+
 func (ctrl *backupDriverController) updateDeleteSnapshotStatusPhase(ctx context.Context, deleteSnapshotNs string, deleteSnapshotName string,
 	newPhase backupdriverapi.DeleteSnapshotPhase, deleteSnapshotStatusFields map[string]interface{}) (*backupdriverapi.DeleteSnapshot, error) {
 	ctrl.logger.Debugf("Entering updateDeleteSnapshotStatusPhase: %s/%s, Phase %s", deleteSnapshotNs, deleteSnapshotName, newPhase)
@@ -17,27 +17,34 @@ limitations under the License.
 package backupdriver
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backuprepository"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/client-go/informers/core/v1"
 	"strings"
+	"sync"
 	"time"
 
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/sirupsen/logrus"
 	backupdriverapi "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
 	datamoverapi "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/datamover/v1alpha1"
+	pluginclientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned"
 	backupdriverclientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/backupdriver/v1alpha1"
 	datamoverclientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/datamover/v1alpha1"
 	backupdriverinformers "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/informers/externalversions"
 	backupdriverlisters "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/listers/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/metrics"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/snapshotmgr"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	corelisters "k8s.io/client-go/listers/core/v1"
@@ -55,15 +62,21 @@ type backupDriverController struct {
 	name   string
 	logger logrus.FieldLogger
 
-	// Supervisor Cluster KubeClient for Guest Cluster
+	// KubeClient of the cluster itself, to delete the unused repository credential Secrets
+	kubeClient kubernetes.Interface
+
+	// Supervisor Cluster KubeClient for Guest Cluster. It is only set in the Guest Cluster and is the config
+	// used at startup, the current one is kept in svcClients.
 	svcKubeConfig *rest.Config
 
-	backupdriverClient    *backupdriverclientset.BackupdriverV1alpha1Client
-	datamoverClient       *datamoverclientset.DatamoverV1alpha1Client
-	svcBackupdriverClient *backupdriverclientset.BackupdriverV1alpha1Client
+	backupdriverClient *backupdriverclientset.BackupdriverV1alpha1Client
+	datamoverClient    *datamoverclientset.DatamoverV1alpha1Client
+
+	// Supervisor Cluster clients for Guest Cluster, which are rebuilt when the supervisor credentials are rotated
+	svcLock    sync.RWMutex
+	svcClients *supervisorClients
 
-	// Supervisor Cluster namespace
-	svcNamespace string
+	resyncPeriod time.Duration
 
 	rateLimiter workqueue.RateLimiter
 
@@ -113,6 +126,8 @@ type backupDriverController struct {
 
 	// Secret queue
 	secretQueue workqueue.RateLimitingInterface
+	// Secret Lister
+	secretLister corelisters.SecretLister
 
 	// DeleteSnapshot queue
 	deleteSnapshotQueue workqueue.RateLimitingInterface
@@ -121,17 +136,34 @@ type backupDriverController struct {
 	// DeleteSnapshot Synced
 	deleteSnapshotSynced cache.InformerSynced
 
-	// Map supervisor cluster snapshot CRs to guest cluster snapshot CRs
-	svcSnapshotMap map[string]string
+	// Map supervisor cluster snapshot CRs to guest cluster snapshot CRs. It is accessed by multiple workers
+	// concurrently and is rebuilt from the guest and supervisor snapshot CRs after a restart.
+	svcSnapshotMap *sync.Map
 
 	// Snapshot manager
 	snapManager *snapshotmgr.SnapshotManager
+	// reloadSnapManagerSvcConfig reloads the supervisor config of the snapshot manager
+	reloadSnapManagerSvcConfig func(svcConfig *rest.Config, svcNamespace string) error
+
+	// Prometheus metrics
+	metrics *metrics.ServerMetrics
+}
+
+// supervisorClients are the clients to the Supervisor Cluster namespace of a Guest Cluster.
+type supervisorClients struct {
+	config             *rest.Config
+	namespace          string
+	backupdriverClient *backupdriverclientset.BackupdriverV1alpha1Client
+	informerFactory    backupdriverinformers.SharedInformerFactory
+	// Closed to stop the informers of informerFactory
+	stopCh chan struct{}
 }
 
 // NewBackupDriverController returns a BackupDriverController.
 func NewBackupDriverController(
 	name string,
 	logger logrus.FieldLogger,
+	kubeClient kubernetes.Interface,
 	backupdriverClient *backupdriverclientset.BackupdriverV1alpha1Client,
 	datamoverclientset *datamoverclientset.DatamoverV1alpha1Client,
 	svcBackupdriverClient *backupdriverclientset.BackupdriverV1alpha1Client,
@@ -139,11 +171,12 @@ func NewBackupDriverController(
 	svcNamespace string,
 	resyncPeriod time.Duration,
 	informerFactory informers.SharedInformerFactory,
+	secretInformerFactory informers.SharedInformerFactory,
 	backupdriverInformerFactory backupdriverinformers.SharedInformerFactory,
-	svcInformerFactory informers.SharedInformerFactory,
 	svcBackupdriverInformerFactory backupdriverinformers.SharedInformerFactory,
 	snapManager *snapshotmgr.SnapshotManager,
-	rateLimiter workqueue.RateLimiter) BackupDriverController {
+	rateLimiter workqueue.RateLimiter,
+	serverMetrics *metrics.ServerMetrics) BackupDriverController {
 
 	var cacheSyncs []cache.InformerSynced
 
@@ -176,35 +209,47 @@ func NewBackupDriverController(
 	svcSnapshotQueue := workqueue.NewNamedRateLimitingQueue(rateLimiter, "backup-driver-svc-snapshot-queue")
 	secretQueue := workqueue.NewNamedRateLimitingQueue(rateLimiter, "backup-driver-secret-queue")
 
-	var svcSnapshotMap map[string]string
+	var svcSnapshotMap *sync.Map
+	var svcClients *supervisorClients
 	var secretInformer v1.SecretInformer
 
 	// Configure supervisor cluster queues and caches in the guest
 	// We watch supervisor snapshot CRs for the upload status. If local mode is set, we do not have to watch for upload status
 	if svcKubeConfig != nil {
-		svcSnapshotMap = make(map[string]string)
+		svcSnapshotMap = &sync.Map{}
 		svcSnapshotInformer := svcBackupdriverInformerFactory.Backupdriver().V1alpha1().Snapshots()
 
 		cacheSyncs = append(cacheSyncs,
 			svcSnapshotInformer.Informer().HasSynced)
-	} else {
-		// Watch for Secret Changes only in Supervisor/Vanilla setup.
-		secretInformer = informerFactory.Core().V1().Secrets()
-		cacheSyncs = append(cacheSyncs, secretInformer.Informer().HasSynced)
+
+		svcClients = &supervisorClients{
+			config:             svcKubeConfig,
+			namespace:          svcNamespace,
+			backupdriverClient: svcBackupdriverClient,
+			informerFactory:    svcBackupdriverInformerFactory,
+			stopCh:             make(chan struct{}),
+		}
 	}
+	// Watch for the vc config Secret changes in Supervisor/Vanilla setup, and for the para virt backup driver
+	// Secret changes in Guest setup. The secret informer factory of the Guest is scoped to the para virt backup
+	// driver Secret.
+	secretInformer = secretInformerFactory.Core().V1().Secrets()
+	cacheSyncs = append(cacheSyncs, secretInformer.Informer().HasSynced)
 
 	ctrl := &backupDriverController{
 		name:                        name,
 		logger:                      logger.WithField("controller", name),
+		kubeClient:                  kubeClient,
 		svcKubeConfig:               svcKubeConfig,
 		backupdriverClient:          backupdriverClient,
 		datamoverClient:             datamoverclientset,
-		svcBackupdriverClient:       svcBackupdriverClient,
+		svcClients:                  svcClients,
+		resyncPeriod:                resyncPeriod,
 		snapManager:                 snapManager,
+		reloadSnapManagerSvcConfig:  snapManager.ReloadSnapshotManagerParaVirtPetmConfig,
 		pvLister:                    pvInformer.Lister(),
 		pvcLister:                   pvcInformer.Lister(),
 		claimQueue:                  claimQueue,
-		svcNamespace:                svcNamespace,
 		snapshotLister:              snapshotInformer.Lister(),
 		snapshotQueue:               snapshotQueue,
 		cloneFromSnapshotLister:     cloneFromSnapshotInformer.Lister(),
@@ -217,8 +262,24 @@ func NewBackupDriverController(
 		uploadQueue:                 uploadQueue,
 		svcSnapshotQueue:            svcSnapshotQueue,
 		secretQueue:                 secretQueue,
+		secretLister:                secretInformer.Lister(),
 		cacheSyncs:                  cacheSyncs,
 		svcSnapshotMap:              svcSnapshotMap,
+		metrics:                     serverMetrics,
+	}
+
+	// Report the depth of all the work queues
+	for queueName, queue := range map[string]workqueue.RateLimitingInterface{
+		"claim":           claimQueue,
+		"snapshot":        snapshotQueue,
+		"clone":           cloneFromSnapshotQueue,
+		"brc":             backupRepositoryClaimQueue,
+		"delete-snapshot": deleteSnapshotQueue,
+		"upload":          uploadQueue,
+		"svc-snapshot":    svcSnapshotQueue,
+		"secret":          secretQueue,
+	} {
+		serverMetrics.RegisterWorkqueueDepth(queueName, queue.Len)
 	}
 
 	pvcInformer.Informer().AddEventHandlerWithResyncPeriod(
@@ -290,30 +351,54 @@ func NewBackupDriverController(
 
 	if svcKubeConfig != nil {
 		// Configure supervisor cluster informers in the guest
-		svcSnapshotInformer := svcBackupdriverInformerFactory.Backupdriver().V1alpha1().Snapshots()
-		svcSnapshotInformer.Informer().AddEventHandlerWithResyncPeriod(
-			cache.ResourceEventHandlerFuncs{
-				//AddFunc:    func(obj interface{}) { ctrl.enqueueSvcSnapshot(obj) },
-				UpdateFunc: func(oldObj, newObj interface{}) { ctrl.updateSvcSnapshot(newObj) },
-				//DeleteFunc: func(obj interface{}) { ctrl.delSvcSnapshot(obj) },
-			},
-			resyncPeriod)
-	}
-	// Configure secret informer in Supervisor and Vanilla setup only.
-	if secretInformer != nil {
-		secretInformer.Informer().AddEventHandlerWithResyncPeriod(
-			cache.FilteringResourceEventHandler{
-				FilterFunc: utils.GetVcConfigSecretFilterFunc(logger),
-				Handler: cache.ResourceEventHandlerFuncs{
-					AddFunc:    func(obj interface{}) { ctrl.enqueueSecret(obj) },
-					UpdateFunc: func(oldObj, newObj interface{}) { ctrl.enqueueSecret(newObj) },
-				},
-			},
-			constants.DefaultSecretResyncPeriod)
+		ctrl.addSvcInformerEventHandlers(svcBackupdriverInformerFactory)
 	}
+	var secretFilterFunc func(obj interface{}) bool
+	if svcKubeConfig != nil {
+		secretFilterFunc = isPvSecret
+	} else {
+		secretFilterFunc = utils.GetVcConfigSecretFilterFunc(logger)
+	}
+	secretInformer.Informer().AddEventHandlerWithResyncPeriod(
+		cache.FilteringResourceEventHandler{
+			FilterFunc: secretFilterFunc,
+			Handler: cache.ResourceEventHandlerFuncs{
+				AddFunc:    func(obj interface{}) { ctrl.enqueueSecret(obj) },
+				UpdateFunc: func(oldObj, newObj interface{}) { ctrl.enqueueSecret(newObj) },
+			},
+		},
+		constants.DefaultSecretResyncPeriod)
 	return ctrl
 }
 
+// addSvcInformerEventHandlers registers the event handlers of the supervisor cluster informers in the guest
+func (ctrl *backupDriverController) addSvcInformerEventHandlers(svcBackupdriverInformerFactory backupdriverinformers.SharedInformerFactory) {
+	svcSnapshotInformer := svcBackupdriverInformerFactory.Backupdriver().V1alpha1().Snapshots()
+	svcSnapshotInformer.Informer().AddEventHandlerWithResyncPeriod(
+		cache.ResourceEventHandlerFuncs{
+			//AddFunc:    func(obj interface{}) { ctrl.enqueueSvcSnapshot(obj) },
+			UpdateFunc: func(oldObj, newObj interface{}) { ctrl.updateSvcSnapshot(newObj) },
+			//DeleteFunc: func(obj interface{}) { ctrl.delSvcSnapshot(obj) },
+		},
+		ctrl.resyncPeriod)
+}
+
+// isPvSecret returns true if the object is the para virt backup driver secret which grants access to the supervisor cluster
+func isPvSecret(obj interface{}) bool {
+	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok && unknown.Obj != nil {
+		obj = unknown.Obj
+	}
+	secret, ok := obj.(*corev1.Secret)
+	return ok && secret.Namespace == constants.BackupDriverNamespace && secret.Name == constants.PvSecretName
+}
+
+// getSvcClients returns the current clients to the supervisor cluster
+func (ctrl *backupDriverController) getSvcClients() *supervisorClients {
+	ctrl.svcLock.RLock()
+	defer ctrl.svcLock.RUnlock()
+	return ctrl.svcClients
+}
+
 // getKey helps to get the resource name from resource object
 func (ctrl *backupDriverController) getKey(obj interface{}) (string, error) {
 	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok && unknown.Obj != nil {
@@ -345,6 +430,15 @@ func (ctrl *backupDriverController) Run(
 
 	stopCh := ctx.Done()
 
+	if ctrl.svcKubeConfig != nil {
+		// The supervisor cluster informers are started here, so that they can be restarted with rotated credentials
+		svcClients := ctrl.getSvcClients()
+		go svcClients.informerFactory.Start(svcClients.stopCh)
+		defer func() {
+			close(ctrl.getSvcClients().stopCh)
+		}()
+	}
+
 	ctrl.logger.Infof("Waiting for caches to sync")
 
 	if !cache.WaitForCacheSync(stopCh, ctrl.cacheSyncs...) {
@@ -353,6 +447,10 @@ func (ctrl *backupDriverController) Run(
 	}
 	ctrl.logger.Infof("Caches are synced")
 
+	if ctrl.svcKubeConfig != nil {
+		ctrl.rebuildSvcSnapshotMap()
+	}
+
 	for i := 0; i < workers; i++ {
 		//go wait.Until(ctrl.pvcWorker, 0, stopCh)
 		//go wait.Until(ctrl.pvWorker, 0, stopCh)
@@ -361,11 +459,10 @@ func (ctrl *backupDriverController) Run(
 		go wait.Until(ctrl.backupRepositoryClaimWorker, 0, stopCh)
 		go wait.Until(ctrl.deleteSnapshotWorker, 0, stopCh)
 		go wait.Until(ctrl.uploadWorker, 0, stopCh)
+		go wait.Until(ctrl.secretWorker, 0, stopCh)
 
 		if ctrl.svcKubeConfig != nil {
 			go wait.Until(ctrl.svcSnapshotWorker, 0, stopCh)
-		} else {
-			go wait.Until(ctrl.secretWorker, 0, stopCh)
 		}
 	}
 
@@ -395,15 +492,17 @@ func (ctrl *backupDriverController) syncSecretByKey(key string) error {
 		ctrl.logger.Errorf("Split meta namespace key of secret %s failed: %v", key, err)
 		return err
 	}
+	if ctrl.svcKubeConfig != nil {
+		return ctrl.reloadSvcClients(namespace, name)
+	}
 	// Retrieve the latest Secret.
-	params := make(map[string]interface{})
-	err = utils.RetrieveVcConfigSecret(params, nil, ctrl.logger)
+	vcParams, err := utils.RetrieveVcConfigs(nil, ctrl.logger)
 	if err != nil {
 		ctrl.logger.Errorf("Failed to retrieve the latest vc config secret")
 		return err
 	}
 	ctrl.logger.Debugf("Successfully retrieved latest vSphere VC credentials.")
-	err = ctrl.snapManager.ReloadSnapshotManagerIvdPetmConfig(params)
+	err = ctrl.snapManager.ReloadSnapshotManagerIvdPetmConfig(vcParams)
 	if err != nil {
 		ctrl.logger.Errorf("Secret %s/%s Reload failed, err: %v", namespace, name, err)
 		return err
@@ -471,11 +570,12 @@ func (ctrl *backupDriverController) syncSnapshotByKey(key string) error {
 	// For guest clusters, add supervisor Snapshot to guest snapshot mapping if not already added
 	// The key is <supervisor snapshot CR name> and value is <guest snapshot namespace>:<guest snapshot CR name>
 	if ctrl.svcKubeConfig != nil && snapshot.Status.SvcSnapshotName != "" {
-		if _, ok := ctrl.svcSnapshotMap[snapshot.Status.SvcSnapshotName]; !ok {
-			ctrl.svcSnapshotMap[snapshot.Status.SvcSnapshotName] = snapshot.Namespace + ":" + snapshot.Name
-			ctrl.logger.Infof("Added supervisor snapshot %s to guest snapshot %s mapping",
-				snapshot.Status.SvcSnapshotName, ctrl.svcSnapshotMap[snapshot.Status.SvcSnapshotName])
-		}
+		ctrl.addSvcSnapshotMapping(snapshot.Status.SvcSnapshotName, snapshot.Namespace, snapshot.Name)
+	}
+
+	if snapshot.Spec.SnapshotCancel && isSnapshotCancelable(snapshot.Status.Phase) {
+		ctrl.logger.Infof("syncSnapshotByKey: calling cancelSnapshot %s/%s", snapshot.Namespace, snapshot.Name)
+		return ctrl.cancelSnapshot(snapshot)
 	}
 
 	if snapshot.Status.Phase != backupdriverapi.SnapshotPhaseNew {
@@ -495,12 +595,8 @@ func (ctrl *backupDriverController) syncSnapshotByKey(key string) error {
 		return nil
 	}
 
-	if snapshot.Spec.SnapshotCancel == false {
-		ctrl.logger.Infof("syncSnapshotByKey: calling CreateSnapshot %s/%s", snapshot.Namespace, snapshot.Name)
-		return ctrl.createSnapshot(snapshot)
-	}
-
-	return nil
+	ctrl.logger.Infof("syncSnapshotByKey: calling CreateSnapshot %s/%s", snapshot.Namespace, snapshot.Name)
+	return ctrl.createSnapshot(snapshot)
 }
 
 // enqueueSnapshot adds Snapshot to given work queue.
@@ -530,10 +626,10 @@ func (ctrl *backupDriverController) delSnapshot(obj interface{}) {
 		}
 		if snapshot, ok := obj.(*backupdriverapi.Snapshot); ok {
 			if snapshot.Status.SvcSnapshotName != "" {
-				if _, ok := ctrl.svcSnapshotMap[snapshot.Status.SvcSnapshotName]; ok {
+				if guestSnapshot, ok := ctrl.svcSnapshotMap.Load(snapshot.Status.SvcSnapshotName); ok {
 					ctrl.logger.Infof("Deleting supervisor snapshot %s to guest snapshot %s mapping",
-						snapshot.Status.SvcSnapshotName, ctrl.svcSnapshotMap[snapshot.Status.SvcSnapshotName])
-					delete(ctrl.svcSnapshotMap, snapshot.Status.SvcSnapshotName)
+						snapshot.Status.SvcSnapshotName, guestSnapshot)
+					ctrl.svcSnapshotMap.Delete(snapshot.Status.SvcSnapshotName)
 				}
 			}
 		}
@@ -588,10 +684,9 @@ func (ctrl *backupDriverController) syncCloneFromSnapshotByKey(key string) error
 	}
 
 	switch phase := cloneFromSnapshot.Status.Phase; phase {
-	case backupdriverapi.ClonePhaseInProgress:
-	case backupdriverapi.ClonePhaseFailed:
-	case backupdriverapi.ClonePhaseCanceling:
-	case backupdriverapi.ClonePhaseCanceled:
+	case backupdriverapi.ClonePhaseInProgress, backupdriverapi.ClonePhaseFailed, backupdriverapi.ClonePhaseCanceling,
+		backupdriverapi.ClonePhaseCanceled:
+		// The cancellation of a clone in progress is handled by the restore waiting on its download
 		ctrl.logger.Debugf("Skipping cloneFromSnapshot %s which is not in New or Retry phase. Current phase: %v", key, cloneFromSnapshot.Status.Phase)
 		return nil
 	case backupdriverapi.ClonePhaseCompleted:
@@ -612,13 +707,19 @@ func (ctrl *backupDriverController) syncCloneFromSnapshotByKey(key string) error
 		// to clone from snapshot
 	}
 
-	if cloneFromSnapshot.Spec.CloneCancel == false {
-		ctrl.logger.Infof("syncCloneFromSnapshotByKey: calling CloneFromSnapshot %s/%s", cloneFromSnapshot.Namespace, cloneFromSnapshot.Name)
-		err := ctrl.cloneFromSnapshot(cloneFromSnapshot)
-		if err != nil {
-			ctrl.logger.Errorf("cloneFromSnapshot %s/%s failed: %v", namespace, name, err)
-			return err
-		}
+	if cloneFromSnapshot.Spec.CloneCancel {
+		// Nothing was created for the clone yet
+		ctrl.logger.Infof("syncCloneFromSnapshotByKey: CloneFromSnapshot %s/%s was canceled before it was processed", namespace, name)
+		_, err := ctrl.updateCloneFromSnapshotStatusPhase(context.TODO(), namespace, name, backupdriverapi.ClonePhaseCanceled,
+			"CloneFromSnapshot was canceled before the volume was created")
+		return err
+	}
+
+	ctrl.logger.Infof("syncCloneFromSnapshotByKey: calling CloneFromSnapshot %s/%s", cloneFromSnapshot.Namespace, cloneFromSnapshot.Name)
+	err = ctrl.cloneFromSnapshot(cloneFromSnapshot)
+	if err != nil {
+		ctrl.logger.Errorf("cloneFromSnapshot %s/%s failed: %v", namespace, name, err)
+		return err
 	}
 
 	return nil
@@ -689,7 +790,8 @@ func (ctrl *backupDriverController) syncBackupRepositoryClaimByKey(key string) e
 		var svcBackupRepositoryName string
 		// In case of guest clusters, create BackupRepositoryClaim in the supervisor namespace
 		if ctrl.svcKubeConfig != nil {
-			svcBackupRepositoryName, err = backuprepository.ClaimSvcBackupRepository(ctx, brc, ctrl.svcKubeConfig, ctrl.svcNamespace, ctrl.logger)
+			svcClients := ctrl.getSvcClients()
+			svcBackupRepositoryName, err = backuprepository.ClaimSvcBackupRepository(ctx, brc, svcClients.config, svcClients.namespace, ctrl.logger)
 			if err != nil {
 				ctrl.logger.Errorf("Failed to create Supervisor BackupRepositoryClaim")
 				return err
@@ -757,6 +859,12 @@ func (ctrl *backupDriverController) dequeBackupRepositoryClaim(obj interface{})
 			return
 		}
 	}
+	if brc.RepositoryCredential != nil {
+		if err := backuprepository.DeleteUnusedRepositoryCredentialSecrets(context.TODO(), ctrl.kubeClient,
+			ctrl.backupdriverClient, ctrl.logger); err != nil {
+			ctrl.logger.WithError(err).Error("Failed to delete the unused repository credential Secrets")
+		}
+	}
 
 	ctrl.backupRepositoryClaimQueue.Forget(key)
 	ctrl.backupRepositoryClaimQueue.Done(key)
@@ -935,10 +1043,18 @@ func (ctrl *backupDriverController) syncUploadByKey(key string) error {
 	case datamoverapi.UploadPhaseCompleted:
 		newSnapshotStatusPhase = backupdriverapi.SnapshotPhaseUploaded
 	case datamoverapi.UploadPhaseCanceled:
-		newSnapshotStatusPhase = backupdriverapi.SnapshotPhaseCanceled
+		if snapshot.Status.Phase == backupdriverapi.SnapshotPhaseCanceled {
+			return nil
+		}
+		// The local snapshot is left behind by the canceled upload, clean it up before landing in Canceled
+		ctrl.logger.Debugf("syncUploadByKey: calling cancelSnapshot %s/%s", snapshot.Namespace, snapshot.Name)
+		return ctrl.cancelSnapshot(snapshot)
 	case datamoverapi.UploadPhaseCanceling:
 		newSnapshotStatusPhase = backupdriverapi.SnapshotPhaseCanceling
 	case datamoverapi.UploadPhaseUploadError:
+		// The upload is retried by the data manager until it succeeds or fails for good
+		newSnapshotStatusPhase = backupdriverapi.SnapshotPhaseUploading
+	case datamoverapi.UploadPhaseFailed:
 		newSnapshotStatusPhase = backupdriverapi.SnapshotPhaseUploadFailed
 	case datamoverapi.UploadPhaseCleanupFailed:
 		newSnapshotStatusPhase = backupdriverapi.SnapshotPhaseCleanupFailed
@@ -947,6 +1063,11 @@ func (ctrl *backupDriverController) syncUploadByKey(key string) error {
 		return nil
 	}
 	snapshotStatusFields := make(map[string]interface{})
+	if upload.Status.Phase == datamoverapi.UploadPhaseFailed {
+		snapshotStatusFields["Message"] = upload.Status.Message
+	}
+	snapshotStatusFields["Progress.TotalBytes"] = upload.Status.Progress.TotalBytes
+	snapshotStatusFields["Progress.BytesDone"] = upload.Status.Progress.BytesDone
 	ctrl.logger.Debugf("syncUploadByKey: calling updateSnapshotStatusPhase %s/%s", snapshot.Namespace, snapshot.Name)
 	_, err = ctrl.updateSnapshotStatusPhase(ctx, snapshot.Namespace, snapshot.Name, newSnapshotStatusPhase, snapshotStatusFields)
 	return err
@@ -1003,7 +1124,7 @@ func (ctrl *backupDriverController) syncSvcSnapshotByKey(key string) error {
 	}
 
 	// Always retrieve up-to-date SvcSnapshot CR from API server
-	svcSnapshot, err := ctrl.svcBackupdriverClient.Snapshots(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	svcSnapshot, err := ctrl.getSvcClients().backupdriverClient.Snapshots(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			ctrl.logger.Infof("SvcSnapshot %s/%s is deleted, no need to process it", namespace, name)
@@ -1014,14 +1135,12 @@ func (ctrl *backupDriverController) syncSvcSnapshotByKey(key string) error {
 	}
 
 	// Get the corresponding guest snapshot
-	if _, ok := ctrl.svcSnapshotMap[svcSnapshot.Name]; !ok {
+	snapshotNamespace, snapshotName, ok := ctrl.getGuestSnapshotForSvcSnapshot(svcSnapshot)
+	if !ok {
 		ctrl.logger.Debugf("The supervisor snapshot %s does not belong to this cluster, or is still not Snapshotted. Skipping updating snapshot status", svcSnapshot.Name)
 		return nil
 	}
 
-	snapshotParts := strings.Split(ctrl.svcSnapshotMap[svcSnapshot.Name], ":")
-	snapshotNamespace := snapshotParts[0]
-	snapshotName := snapshotParts[1]
 	snapshot, err := ctrl.backupdriverClient.Snapshots(snapshotNamespace).Get(context.TODO(), snapshotName, metav1.GetOptions{})
 	if err != nil {
 		ctrl.logger.WithError(err).Debug("No matching snapshot found. Skipping updating snapshot")
@@ -1053,6 +1172,8 @@ func (ctrl *backupDriverController) syncSvcSnapshotByKey(key string) error {
 		return nil
 	}
 	snapshotStatusFields := make(map[string]interface{})
+	snapshotStatusFields["Progress.TotalBytes"] = svcSnapshot.Status.Progress.TotalBytes
+	snapshotStatusFields["Progress.BytesDone"] = svcSnapshot.Status.Progress.BytesDone
 	ctrl.logger.Debugf("syncSvcSnapshotByKey: calling updateSnapshotStatusPhase %s/%s", snapshot.Namespace, snapshot.Name)
 	_, err = ctrl.updateSnapshotStatusPhase(ctx, snapshot.Namespace, snapshot.Name, newSnapshotStatusPhase, snapshotStatusFields)
 	return err
@@ -1071,7 +1192,7 @@ func (ctrl *backupDriverController) updateSvcSnapshot(newObj interface{}) {
 	if okNew {
 		// Skip snapshots not in the svc snapshot map
 		// TODO: Add filter to informer to get snapshot owned by this cluster
-		if _, ok := ctrl.svcSnapshotMap[svcSnapshotNew.Name]; !ok {
+		if _, _, ok := ctrl.getGuestSnapshotForSvcSnapshot(svcSnapshotNew); !ok {
 			ctrl.logger.Debugf("The supervisor snapshot %s either does not belong to this cluster, or is still not snapshotted. Skipping updating snapshot status", svcSnapshotNew.Name)
 			return
 		}
@@ -1084,3 +1205,128 @@ func (ctrl *backupDriverController) updateSvcSnapshot(newObj interface{}) {
 		ctrl.svcSnapshotQueue.Add(objName)
 	}
 }
+
+// addSvcSnapshotMapping adds the supervisor snapshot to guest snapshot mapping if not already added.
+// The key is <supervisor snapshot CR name> and value is <guest snapshot namespace>:<guest snapshot CR name>
+func (ctrl *backupDriverController) addSvcSnapshotMapping(svcSnapshotName string, snapshotNamespace string, snapshotName string) {
+	guestSnapshot := snapshotNamespace + ":" + snapshotName
+	if _, loaded := ctrl.svcSnapshotMap.LoadOrStore(svcSnapshotName, guestSnapshot); !loaded {
+		ctrl.logger.Infof("Added supervisor snapshot %s to guest snapshot %s mapping", svcSnapshotName, guestSnapshot)
+	}
+}
+
+// getGuestSnapshotForSvcSnapshot returns the namespace and name of the guest snapshot which the supervisor snapshot
+// was created for. If the mapping is not known yet, e.g. after a restart, it is derived from the guest snapshot labels
+// of the supervisor snapshot, and only trusted if that guest snapshot refers back to the supervisor snapshot.
+func (ctrl *backupDriverController) getGuestSnapshotForSvcSnapshot(svcSnapshot *backupdriverapi.Snapshot) (string, string, bool) {
+	if guestSnapshot, ok := ctrl.svcSnapshotMap.Load(svcSnapshot.Name); ok {
+		snapshotParts := strings.Split(guestSnapshot.(string), ":")
+		return snapshotParts[0], snapshotParts[1], true
+	}
+
+	snapshotNamespace, namespaceFound := svcSnapshot.Labels[constants.GuestSnapshotNamespaceLabel]
+	snapshotName, nameFound := svcSnapshot.Labels[constants.GuestSnapshotNameLabel]
+	if !namespaceFound || !nameFound {
+		return "", "", false
+	}
+	snapshot, err := ctrl.snapshotLister.Snapshots(snapshotNamespace).Get(snapshotName)
+	if err != nil || snapshot.Status.SvcSnapshotName != svcSnapshot.Name {
+		// The guest snapshot belongs to another guest cluster sharing the supervisor namespace, or was not Snapshotted yet
+		return "", "", false
+	}
+	ctrl.addSvcSnapshotMapping(svcSnapshot.Name, snapshotNamespace, snapshotName)
+	return snapshotNamespace, snapshotName, true
+}
+
+// rebuildSvcSnapshotMap restores the supervisor snapshot to guest snapshot mapping from the guest snapshots,
+// and enqueues the supervisor snapshots so that status changes missed during a restart are applied.
+func (ctrl *backupDriverController) rebuildSvcSnapshotMap() {
+	snapshots, err := ctrl.snapshotLister.List(labels.Everything())
+	if err != nil {
+		ctrl.logger.WithError(err).Error("Failed to list snapshots to rebuild the supervisor snapshot to guest snapshot mapping")
+		return
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Status.SvcSnapshotName == "" {
+			continue
+		}
+		ctrl.addSvcSnapshotMapping(snapshot.Status.SvcSnapshotName, snapshot.Namespace, snapshot.Name)
+		switch snapshot.Status.Phase {
+		case backupdriverapi.SnapshotPhaseSnapshotted, backupdriverapi.SnapshotPhaseUploading, backupdriverapi.SnapshotPhaseCanceling:
+			ctrl.svcSnapshotQueue.Add(ctrl.getSvcClients().namespace + "/" + snapshot.Status.SvcSnapshotName)
+		}
+	}
+}
+
+// reloadSvcClients rebuilds the clients and informers to the supervisor cluster from the para virt backup driver
+// secret, if the credentials in the secret were rotated.
+func (ctrl *backupDriverController) reloadSvcClients(namespace string, name string) error {
+	secret, err := ctrl.secretLister.Secrets(namespace).Get(name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			ctrl.logger.Warnf("Secret %s/%s is deleted, keep using the current supervisor credentials", namespace, name)
+			return nil
+		}
+		ctrl.logger.Errorf("Get Secret %s/%s failed: %v", namespace, name, err)
+		return err
+	}
+
+	oldSvcClients := ctrl.getSvcClients()
+	// The endpoint of the supervisor cluster does not change with the credentials
+	svcConfig, svcNamespace := utils.NewSupervisorConfigFromSecret(secret, strings.TrimPrefix(oldSvcClients.config.Host, "https://"))
+	if svcConfig.BearerToken == oldSvcClients.config.BearerToken && svcNamespace == oldSvcClients.namespace &&
+		bytes.Equal(svcConfig.TLSClientConfig.CAData, oldSvcClients.config.TLSClientConfig.CAData) {
+		ctrl.logger.Debugf("The supervisor credentials in Secret %s/%s are not changed", namespace, name)
+		return nil
+	}
+	ctrl.logger.Infof("The supervisor credentials in Secret %s/%s are changed, reloading the supervisor clients", namespace, name)
+
+	svcBackupdriverClient, err := backupdriverclientset.NewForConfig(svcConfig)
+	if err != nil {
+		ctrl.logger.Errorf("Failed to get the supervisor backupdriver client: %v", err)
+		return err
+	}
+	svcPluginClient, err := pluginclientset.NewForConfig(svcConfig)
+	if err != nil {
+		ctrl.logger.Errorf("Failed to get the plugin client for the supervisor cluster: %v", err)
+		return err
+	}
+	svcBackupdriverInformerFactory := backupdriverinformers.NewSharedInformerFactoryWithOptions(svcPluginClient, ctrl.resyncPeriod,
+		backupdriverinformers.WithNamespace(svcNamespace))
+	ctrl.addSvcInformerEventHandlers(svcBackupdriverInformerFactory)
+
+	newSvcClients := &supervisorClients{
+		config:             svcConfig,
+		namespace:          svcNamespace,
+		backupdriverClient: svcBackupdriverClient,
+		informerFactory:    svcBackupdriverInformerFactory,
+		stopCh:             make(chan struct{}),
+	}
+	svcBackupdriverInformerFactory.Start(newSvcClients.stopCh)
+	for informerType, synced := range svcBackupdriverInformerFactory.WaitForCacheSync(newSvcClients.stopCh) {
+		if !synced {
+			close(newSvcClients.stopCh)
+			return fmt.Errorf("Failed to sync the supervisor cluster informer for %v", informerType)
+		}
+	}
+
+	err = ctrl.reloadSnapManagerSvcConfig(svcConfig, svcNamespace)
+	if err != nil {
+		close(newSvcClients.stopCh)
+		ctrl.logger.Errorf("Failed to reload the supervisor config of snapshot manager: %v", err)
+		return err
+	}
+
+	ctrl.svcLock.Lock()
+	ctrl.svcClients = newSvcClients
+	ctrl.svcLock.Unlock()
+	close(oldSvcClients.stopCh)
+	ctrl.logger.Infof("Reloaded the supervisor clients, supervisor namespace: %s", svcNamespace)
+
+	// Supervisor snapshot updates may have been missed while the credentials were invalid
+	ctrl.svcSnapshotMap.Range(func(svcSnapshotName, _ interface{}) bool {
+		ctrl.svcSnapshotQueue.Add(svcNamespace + "/" + svcSnapshotName.(string))
+		return true
+	})
+	return nil
+}
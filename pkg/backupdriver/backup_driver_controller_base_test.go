@@ -0,0 +1,218 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupdriver
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	backupdriverapi "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/builder"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	backupdriverlisters "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/listers/backupdriver/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func newGuestSnapshot(name string, phase backupdriverapi.SnapshotPhase, svcSnapshotName string) *backupdriverapi.Snapshot {
+	snapshot := builder.ForSnapshot("app-ns", name, nil).Result()
+	snapshot.Status.Phase = phase
+	snapshot.Status.SvcSnapshotName = svcSnapshotName
+	return snapshot
+}
+
+// newGuestController returns the controller of a Guest Cluster with the snapshots, as it is after a restart.
+func newGuestController(t *testing.T, snapshots ...*backupdriverapi.Snapshot) *backupDriverController {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, snapshot := range snapshots {
+		require.NoError(t, indexer.Add(snapshot))
+	}
+	return &backupDriverController{
+		logger:           logrus.New(),
+		snapshotLister:   backupdriverlisters.NewSnapshotLister(indexer),
+		svcSnapshotQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "test-svc-snapshot-queue"),
+		svcSnapshotMap:   &sync.Map{},
+		svcClients:       &supervisorClients{namespace: "svc-ns"},
+	}
+}
+
+func TestRebuildSvcSnapshotMap(t *testing.T) {
+	ctrl := newGuestController(t,
+		newGuestSnapshot("snap-1", backupdriverapi.SnapshotPhaseSnapshotted, "svc-snap-1"),
+		newGuestSnapshot("snap-2", backupdriverapi.SnapshotPhaseUploading, "svc-snap-2"),
+		newGuestSnapshot("snap-3", backupdriverapi.SnapshotPhaseCanceling, "svc-snap-3"),
+		newGuestSnapshot("snap-4", backupdriverapi.SnapshotPhaseUploaded, "svc-snap-4"),
+		// Not snapshotted in the supervisor cluster yet
+		newGuestSnapshot("snap-5", backupdriverapi.SnapshotPhaseNew, ""))
+	defer ctrl.svcSnapshotQueue.ShutDown()
+
+	ctrl.rebuildSvcSnapshotMap()
+
+	mapping := make(map[string]string)
+	ctrl.svcSnapshotMap.Range(func(svcSnapshotName, guestSnapshot interface{}) bool {
+		mapping[svcSnapshotName.(string)] = guestSnapshot.(string)
+		return true
+	})
+	assert.Equal(t, map[string]string{
+		"svc-snap-1": "app-ns:snap-1",
+		"svc-snap-2": "app-ns:snap-2",
+		"svc-snap-3": "app-ns:snap-3",
+		"svc-snap-4": "app-ns:snap-4",
+	}, mapping)
+
+	// The supervisor snapshots still in flight are synced again, as their updates may have been missed
+	var queued []string
+	for ctrl.svcSnapshotQueue.Len() > 0 {
+		key, _ := ctrl.svcSnapshotQueue.Get()
+		queued = append(queued, key.(string))
+		ctrl.svcSnapshotQueue.Done(key)
+	}
+	sort.Strings(queued)
+	assert.Equal(t, []string{"svc-ns/svc-snap-1", "svc-ns/svc-snap-2", "svc-ns/svc-snap-3"}, queued)
+}
+
+func TestGetGuestSnapshotForSvcSnapshotAfterRestart(t *testing.T) {
+	ctrl := newGuestController(t,
+		newGuestSnapshot("snap-1", backupdriverapi.SnapshotPhaseUploading, "svc-snap-1"),
+		// The guest snapshot of another guest cluster sharing the supervisor namespace, with the same name
+		newGuestSnapshot("snap-2", backupdriverapi.SnapshotPhaseUploading, "svc-snap-other"))
+	defer ctrl.svcSnapshotQueue.ShutDown()
+
+	newSvcSnapshot := func(name string, guestSnapshotName string) *backupdriverapi.Snapshot {
+		return builder.ForSnapshot("svc-ns", name, map[string]string{
+			constants.GuestSnapshotNamespaceLabel: "app-ns",
+			constants.GuestSnapshotNameLabel:      guestSnapshotName,
+		}).Result()
+	}
+
+	// The mapping missing after a restart is derived from the labels and cached
+	namespace, name, ok := ctrl.getGuestSnapshotForSvcSnapshot(newSvcSnapshot("svc-snap-1", "snap-1"))
+	assert.True(t, ok)
+	assert.Equal(t, "app-ns", namespace)
+	assert.Equal(t, "snap-1", name)
+	guestSnapshot, cached := ctrl.svcSnapshotMap.Load("svc-snap-1")
+	assert.True(t, cached)
+	assert.Equal(t, "app-ns:snap-1", guestSnapshot)
+
+	// The labels are only trusted if the guest snapshot refers back to the supervisor snapshot
+	_, _, ok = ctrl.getGuestSnapshotForSvcSnapshot(newSvcSnapshot("svc-snap-2", "snap-2"))
+	assert.False(t, ok)
+	_, _, ok = ctrl.getGuestSnapshotForSvcSnapshot(newSvcSnapshot("svc-snap-3", "snap-3"))
+	assert.False(t, ok)
+	_, _, ok = ctrl.getGuestSnapshotForSvcSnapshot(builder.ForSnapshot("svc-ns", "svc-snap-4", nil).Result())
+	assert.False(t, ok)
+	_, cached = ctrl.svcSnapshotMap.Load("svc-snap-2")
+	assert.False(t, cached)
+}
+
+func TestReloadSvcClientsOnRotation(t *testing.T) {
+	// The supervisor cluster serves no snapshots, and records the credentials of the requests
+	var lock sync.Mutex
+	authorizations := make(map[string]bool)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		authorizations[r.Header.Get("Authorization")] = true
+		lock.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, `{"apiVersion":"backupdriver.cnsdp.vmware.com/v1alpha1","kind":"SnapshotList","metadata":{"resourceVersion":"1"},"items":[]}`)
+	}))
+	defer server.Close()
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	secretIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: constants.BackupDriverNamespace, Name: constants.PvSecretName},
+		Data: map[string][]byte{
+			"namespace": []byte("svc-ns"),
+			"ca.crt":    caData,
+			"token":     []byte("old-token"),
+		},
+	}
+	require.NoError(t, secretIndexer.Add(secret))
+
+	var reloadedConfigs []*rest.Config
+	ctrl := newGuestController(t)
+	defer ctrl.svcSnapshotQueue.ShutDown()
+	ctrl.secretLister = corelisters.NewSecretLister(secretIndexer)
+	ctrl.reloadSnapManagerSvcConfig = func(svcConfig *rest.Config, svcNamespace string) error {
+		reloadedConfigs = append(reloadedConfigs, svcConfig)
+		return nil
+	}
+	oldSvcClients := &supervisorClients{
+		config: &rest.Config{
+			Host:            server.URL,
+			BearerToken:     "old-token",
+			TLSClientConfig: rest.TLSClientConfig{CAData: caData},
+		},
+		namespace: "svc-ns",
+		stopCh:    make(chan struct{}),
+	}
+	ctrl.svcClients = oldSvcClients
+	ctrl.addSvcSnapshotMapping("svc-snap-1", "app-ns", "snap-1")
+
+	// The clients are kept while the credentials are not changed
+	require.NoError(t, ctrl.reloadSvcClients(constants.BackupDriverNamespace, constants.PvSecretName))
+	assert.Equal(t, oldSvcClients, ctrl.getSvcClients())
+	assert.Empty(t, reloadedConfigs)
+
+	// The clients are rebuilt with the rotated credentials
+	secret = secret.DeepCopy()
+	secret.Data["token"] = []byte("new-token")
+	require.NoError(t, secretIndexer.Update(secret))
+	require.NoError(t, ctrl.reloadSvcClients(constants.BackupDriverNamespace, constants.PvSecretName))
+	newSvcClients := ctrl.getSvcClients()
+	defer close(newSvcClients.stopCh)
+	assert.Equal(t, "new-token", newSvcClients.config.BearerToken)
+	assert.Equal(t, server.URL, newSvcClients.config.Host)
+	require.Len(t, reloadedConfigs, 1)
+	assert.Equal(t, "new-token", reloadedConfigs[0].BearerToken)
+
+	// The supervisor snapshots are watched with the new credentials, and the old informers are stopped
+	lock.Lock()
+	assert.True(t, authorizations["Bearer new-token"])
+	assert.False(t, authorizations["Bearer old-token"])
+	lock.Unlock()
+	select {
+	case <-oldSvcClients.stopCh:
+	default:
+		t.Error("The informers of the old supervisor clients are not stopped")
+	}
+
+	// The supervisor snapshot updates missed meanwhile are synced again
+	require.Equal(t, 1, ctrl.svcSnapshotQueue.Len())
+	key, _ := ctrl.svcSnapshotQueue.Get()
+	assert.Equal(t, "svc-ns/svc-snap-1", key)
+	ctrl.svcSnapshotQueue.Done(key)
+}
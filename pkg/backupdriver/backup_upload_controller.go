@@ -0,0 +1,298 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupdriver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	backupdriverapi "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	backupdriverclientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/backupdriver/v1alpha1"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	veleroclientset "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned"
+	veleroscheme "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// The reason of the events recorded on the backups for the failed uploads of their snapshots
+	SnapshotUploadFailedReason = "SnapshotUploadFailed"
+)
+
+// BackupUploadController is the interface for the controller which tracks the uploads of the snapshots of the Velero
+// backups. The backup item action returns as soon as the local snapshot of a PVC is taken, so the snapshots are
+// uploaded after Velero has completed the backup. The progress of the uploads is recorded in the annotations of the
+// backup, and the backup is marked as PartiallyFailed if an upload fails.
+type BackupUploadController interface {
+	// Run starts the controller.
+	Run(ctx context.Context)
+}
+
+type backupUploadController struct {
+	name   string
+	logger logrus.FieldLogger
+
+	// The namespace of the Velero backups
+	namespace string
+
+	kubeClient         kubernetes.Interface
+	backupdriverClient backupdriverclientset.BackupdriverV1alpha1Interface
+	veleroClient       veleroclientset.Interface
+	recorder           record.EventRecorder
+
+	// How often the uploads are checked
+	period time.Duration
+	// The time the uploads started to be tracked, the backups completed before are not tracked
+	since time.Time
+}
+
+// backupUploads summarizes the uploads of the snapshots of a backup.
+type backupUploads struct {
+	total    int
+	uploaded int
+	pending  int
+	// The failed snapshots, with their messages
+	failed []*backupdriverapi.Snapshot
+}
+
+// NewBackupUploadController returns a BackupUploadController.
+func NewBackupUploadController(
+	name string,
+	logger logrus.FieldLogger,
+	namespace string,
+	kubeClient kubernetes.Interface,
+	backupdriverClient *backupdriverclientset.BackupdriverV1alpha1Client,
+	veleroClient veleroclientset.Interface,
+	period time.Duration) BackupUploadController {
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(logger.Debugf)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(veleroscheme.Scheme, corev1.EventSource{Component: name})
+
+	return &backupUploadController{
+		name:               name,
+		logger:             logger.WithField("controller", name),
+		namespace:          namespace,
+		kubeClient:         kubeClient,
+		backupdriverClient: backupdriverClient,
+		veleroClient:       veleroClient,
+		recorder:           recorder,
+		period:             period,
+	}
+}
+
+// Run checks the uploads every period until the context is done.
+func (c *backupUploadController) Run(ctx context.Context) {
+	c.logger.Infof("Starting the tracking of the backup uploads, period: %v", c.period)
+	defer c.logger.Info("Shutting down the tracking of the backup uploads")
+
+	wait.Until(func() {
+		if err := c.sync(ctx); err != nil {
+			c.logger.WithError(err).Error("Failed to track the backup uploads")
+		}
+	}, c.period, ctx.Done())
+}
+
+// sync updates the uploads of the completed backups whose uploads are not done yet.
+func (c *backupUploadController) sync(ctx context.Context) error {
+	if c.since.IsZero() {
+		since, err := c.getTrackingStart(ctx)
+		if err != nil {
+			return err
+		}
+		c.since = since
+	}
+	backupList, err := c.veleroClient.VeleroV1().Backups(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list the backups")
+	}
+	var backups []*velerov1.Backup
+	for i := range backupList.Items {
+		backup := &backupList.Items[i]
+		// The uploads of the backups in progress are tracked once Velero is done with them
+		if backup.Status.Phase != velerov1.BackupPhaseCompleted && backup.Status.Phase != velerov1.BackupPhasePartiallyFailed {
+			continue
+		}
+		switch backup.Annotations[constants.BackupUploadStatusAnnotation] {
+		case constants.BackupUploadStatusCompleted, constants.BackupUploadStatusPartiallyFailed:
+			continue
+		case "":
+			// The failed uploads of the backups completed before the uploads were tracked are already in their errors
+			if backup.Status.CompletionTimestamp != nil && backup.Status.CompletionTimestamp.Time.Before(c.since) {
+				continue
+			}
+		}
+		backups = append(backups, backup)
+	}
+	if len(backups) == 0 {
+		return nil
+	}
+
+	snapshotList, err := c.backupdriverClient.Snapshots(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: constants.SnapshotBackupLabel,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list the Snapshots")
+	}
+	snapshots := make(map[string][]*backupdriverapi.Snapshot)
+	for i := range snapshotList.Items {
+		snapshot := &snapshotList.Items[i]
+		backupName := snapshot.Labels[constants.SnapshotBackupLabel]
+		snapshots[backupName] = append(snapshots[backupName], snapshot)
+	}
+
+	for _, backup := range backups {
+		// The Snapshots of a backup are created before Velero completes it, so the backups without any, e.g. the
+		// backups of no PVCs, are marked as Completed at once instead of being checked again every period
+		uploads := getBackupUploads(backup, snapshots[backup.Name])
+		if err := c.updateBackup(ctx, backup, uploads); err != nil {
+			c.logger.WithError(err).Errorf("Failed to update the uploads of the backup %s", backup.Name)
+		}
+	}
+	return nil
+}
+
+// getTrackingStart returns the time the uploads started to be tracked, recorded in the tracking ConfigMap when the
+// uploads are first tracked, so that the backups completed while the backup-driver was restarting are still tracked.
+func (c *backupUploadController) getTrackingStart(ctx context.Context) (time.Time, error) {
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(c.namespace).Get(ctx, constants.BackupUploadTrackingConfigMap, metav1.GetOptions{})
+	if err == nil {
+		since, err := time.Parse(time.RFC3339, configMap.Data[constants.BackupUploadTrackingSinceKey])
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "Invalid %s in the ConfigMap %s", constants.BackupUploadTrackingSinceKey,
+				constants.BackupUploadTrackingConfigMap)
+		}
+		return since, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return time.Time{}, errors.Wrapf(err, "Failed to get the ConfigMap %s", constants.BackupUploadTrackingConfigMap)
+	}
+	since := time.Now().UTC().Truncate(time.Second)
+	configMap = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.BackupUploadTrackingConfigMap,
+			Namespace: c.namespace,
+		},
+		Data: map[string]string{constants.BackupUploadTrackingSinceKey: since.Format(time.RFC3339)},
+	}
+	if _, err := c.kubeClient.CoreV1().ConfigMaps(c.namespace).Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+		return time.Time{}, errors.Wrapf(err, "Failed to create the ConfigMap %s", constants.BackupUploadTrackingConfigMap)
+	}
+	c.logger.Infof("Tracking the uploads of the backups completed since %s", since.Format(time.RFC3339))
+	return since, nil
+}
+
+// getBackupUploads summarizes the uploads of the snapshots taken for the backup.
+func getBackupUploads(backup *velerov1.Backup, snapshots []*backupdriverapi.Snapshot) backupUploads {
+	var uploads backupUploads
+	for _, snapshot := range snapshots {
+		// The Snapshots of an earlier backup with the same name are left out
+		if backup.Status.StartTimestamp != nil && snapshot.CreationTimestamp.Before(backup.Status.StartTimestamp) {
+			continue
+		}
+		// The Supervisor Snapshots taken for the Guest Clusters are labeled with the names of the Guest backups
+		if _, ok := snapshot.Labels[constants.GuestSnapshotNameLabel]; ok {
+			continue
+		}
+		switch snapshot.Status.Phase {
+		case backupdriverapi.SnapshotPhaseSnapshotFailed:
+			// The failed local snapshots already failed the backup of their PVCs
+			continue
+		case backupdriverapi.SnapshotPhaseUploaded, backupdriverapi.SnapshotPhaseCleanupFailed:
+			uploads.uploaded++
+		case backupdriverapi.SnapshotPhaseSnapshotted:
+			// The local snapshots taken without a backup repository in local mode are not uploaded
+			if snapshot.Spec.BackupRepository == "" {
+				uploads.uploaded++
+			} else {
+				uploads.pending++
+			}
+		case backupdriverapi.SnapshotPhaseUploadFailed, backupdriverapi.SnapshotPhaseCanceled:
+			uploads.failed = append(uploads.failed, snapshot)
+		default:
+			uploads.pending++
+		}
+		uploads.total++
+	}
+	return uploads
+}
+
+// updateBackup records the uploads in the annotations of the backup, and marks it as PartiallyFailed once all the
+// uploads are done if any of them failed. Only the Backup CR is patched, the backup metadata which Velero stored in
+// the backup storage location still shows the backup as Completed.
+func (c *backupUploadController) updateBackup(ctx context.Context, backup *velerov1.Backup, uploads backupUploads) error {
+	status := constants.BackupUploadStatusInProgress
+	if uploads.pending == 0 {
+		if len(uploads.failed) > 0 {
+			status = constants.BackupUploadStatusPartiallyFailed
+		} else {
+			status = constants.BackupUploadStatusCompleted
+		}
+	}
+	progress := fmt.Sprintf("%d/%d", uploads.uploaded, uploads.total)
+	if backup.Annotations[constants.BackupUploadStatusAnnotation] == status &&
+		backup.Annotations[constants.BackupUploadProgressAnnotation] == progress {
+		return nil
+	}
+
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				constants.BackupUploadStatusAnnotation:   status,
+				constants.BackupUploadProgressAnnotation: progress,
+			},
+		},
+	}
+	if status == constants.BackupUploadStatusPartiallyFailed {
+		patch["status"] = map[string]interface{}{
+			"phase":  velerov1.BackupPhasePartiallyFailed,
+			"errors": backup.Status.Errors + len(uploads.failed),
+		}
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := c.veleroClient.VeleroV1().Backups(backup.Namespace).Patch(ctx, backup.Name, types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed to patch the backup %s", backup.Name)
+	}
+	c.logger.Infof("The uploads of the backup %s are %s, %s snapshots uploaded", backup.Name, status, progress)
+
+	if status == constants.BackupUploadStatusPartiallyFailed {
+		for _, snapshot := range uploads.failed {
+			c.logger.Warningf("The upload of the Snapshot %s/%s of the backup %s is %s: %s",
+				snapshot.Namespace, snapshot.Name, backup.Name, snapshot.Status.Phase, snapshot.Status.Message)
+			c.recorder.Eventf(backup, corev1.EventTypeWarning, SnapshotUploadFailedReason,
+				"The upload of the snapshot of PVC %s/%s is %s: %s", snapshot.Namespace, snapshot.Spec.TypedLocalObjectReference.Name,
+				snapshot.Status.Phase, snapshot.Status.Message)
+		}
+	}
+	return nil
+}
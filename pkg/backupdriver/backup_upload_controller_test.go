@@ -0,0 +1,334 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupdriver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	backupdriverapi "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/builder"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	backupdriverfake "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/fake"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	velerofake "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+var backupStartTime = time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func newBackup(name string, annotations map[string]string) *velerov1.Backup {
+	return &velerov1.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "velero",
+			Annotations: annotations,
+		},
+		Status: velerov1.BackupStatus{
+			Phase:          velerov1.BackupPhaseCompleted,
+			StartTimestamp: &metav1.Time{Time: backupStartTime},
+			Errors:         1,
+		},
+	}
+}
+
+// newSnapshot returns a Snapshot of the backup, created after the backup started unless createdBefore is set.
+func newSnapshot(name string, phase backupdriverapi.SnapshotPhase, backupRepository string, labels map[string]string,
+	createdBefore bool) *backupdriverapi.Snapshot {
+	snapshotLabels := map[string]string{constants.SnapshotBackupLabel: "backup-1"}
+	for k, v := range labels {
+		snapshotLabels[k] = v
+	}
+	snapshot := builder.ForSnapshot("app-ns", name, snapshotLabels).BackupRepository(backupRepository).Result()
+	snapshot.CreationTimestamp = metav1.Time{Time: backupStartTime.Add(time.Minute)}
+	if createdBefore {
+		snapshot.CreationTimestamp = metav1.Time{Time: backupStartTime.Add(-time.Hour)}
+	}
+	snapshot.Status.Phase = phase
+	return snapshot
+}
+
+func TestGetBackupUploads(t *testing.T) {
+	guestLabels := map[string]string{constants.GuestSnapshotNameLabel: "guest-snapshot"}
+	tests := []struct {
+		name             string
+		snapshots        []*backupdriverapi.Snapshot
+		expectedTotal    int
+		expectedUploaded int
+		expectedPending  int
+		expectedFailed   []string
+	}{
+		{
+			name:          "No snapshots",
+			expectedTotal: 0,
+		},
+		{
+			name: "Local mode snapshot is not uploaded",
+			snapshots: []*backupdriverapi.Snapshot{
+				newSnapshot("snap-1", backupdriverapi.SnapshotPhaseSnapshotted, "", nil, false),
+			},
+			expectedTotal:    1,
+			expectedUploaded: 1,
+		},
+		{
+			name: "Snapshot with a backup repository is pending",
+			snapshots: []*backupdriverapi.Snapshot{
+				newSnapshot("snap-1", backupdriverapi.SnapshotPhaseSnapshotted, "br-1", nil, false),
+				newSnapshot("snap-2", backupdriverapi.SnapshotPhaseUploading, "br-1", nil, false),
+				newSnapshot("snap-3", backupdriverapi.SnapshotPhaseUploaded, "br-1", nil, false),
+			},
+			expectedTotal:    3,
+			expectedUploaded: 1,
+			expectedPending:  2,
+		},
+		{
+			name: "Canceled and failed uploads fail",
+			snapshots: []*backupdriverapi.Snapshot{
+				newSnapshot("snap-1", backupdriverapi.SnapshotPhaseCanceled, "br-1", nil, false),
+				newSnapshot("snap-2", backupdriverapi.SnapshotPhaseUploadFailed, "br-1", nil, false),
+				newSnapshot("snap-3", backupdriverapi.SnapshotPhaseUploaded, "br-1", nil, false),
+			},
+			expectedTotal:    3,
+			expectedUploaded: 1,
+			expectedFailed:   []string{"snap-1", "snap-2"},
+		},
+		{
+			name: "Failed cleanup of an uploaded snapshot is uploaded",
+			snapshots: []*backupdriverapi.Snapshot{
+				newSnapshot("snap-1", backupdriverapi.SnapshotPhaseCleanupFailed, "br-1", nil, false),
+			},
+			expectedTotal:    1,
+			expectedUploaded: 1,
+		},
+		{
+			name: "Failed local snapshot is left out",
+			snapshots: []*backupdriverapi.Snapshot{
+				newSnapshot("snap-1", backupdriverapi.SnapshotPhaseSnapshotFailed, "br-1", nil, false),
+				newSnapshot("snap-2", backupdriverapi.SnapshotPhaseUploaded, "br-1", nil, false),
+			},
+			expectedTotal:    1,
+			expectedUploaded: 1,
+		},
+		{
+			name: "Supervisor snapshots of the Guest Clusters are left out",
+			snapshots: []*backupdriverapi.Snapshot{
+				newSnapshot("snap-1", backupdriverapi.SnapshotPhaseUploadFailed, "br-1", guestLabels, false),
+				newSnapshot("snap-2", backupdriverapi.SnapshotPhaseUploading, "br-1", guestLabels, false),
+			},
+			expectedTotal: 0,
+		},
+		{
+			name: "Snapshots of an earlier backup with the same name are left out",
+			snapshots: []*backupdriverapi.Snapshot{
+				newSnapshot("snap-1", backupdriverapi.SnapshotPhaseUploadFailed, "br-1", nil, true),
+				newSnapshot("snap-2", backupdriverapi.SnapshotPhaseUploading, "br-1", nil, true),
+				newSnapshot("snap-3", backupdriverapi.SnapshotPhaseUploaded, "br-1", nil, false),
+			},
+			expectedTotal:    1,
+			expectedUploaded: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			uploads := getBackupUploads(newBackup("backup-1", nil), test.snapshots)
+			assert.Equal(t, test.expectedTotal, uploads.total)
+			assert.Equal(t, test.expectedUploaded, uploads.uploaded)
+			assert.Equal(t, test.expectedPending, uploads.pending)
+			var failed []string
+			for _, snapshot := range uploads.failed {
+				failed = append(failed, snapshot.Name)
+			}
+			assert.Equal(t, test.expectedFailed, failed)
+		})
+	}
+}
+
+func TestUpdateBackup(t *testing.T) {
+	tests := []struct {
+		name             string
+		uploads          backupUploads
+		expectedStatus   string
+		expectedProgress string
+		expectedPhase    velerov1.BackupPhase
+		expectedErrors   int
+		expectedEvents   int
+	}{
+		{
+			name:             "Backup without snapshots is completed",
+			uploads:          backupUploads{},
+			expectedStatus:   constants.BackupUploadStatusCompleted,
+			expectedProgress: "0/0",
+			expectedPhase:    velerov1.BackupPhaseCompleted,
+			expectedErrors:   1,
+		},
+		{
+			name:             "Pending uploads are in progress",
+			uploads:          backupUploads{total: 2, uploaded: 1, pending: 1},
+			expectedStatus:   constants.BackupUploadStatusInProgress,
+			expectedProgress: "1/2",
+			expectedPhase:    velerov1.BackupPhaseCompleted,
+			expectedErrors:   1,
+		},
+		{
+			name: "Failed upload fails the backup partially",
+			uploads: backupUploads{total: 2, uploaded: 1, failed: []*backupdriverapi.Snapshot{
+				newSnapshot("snap-1", backupdriverapi.SnapshotPhaseUploadFailed, "br-1", nil, false),
+			}},
+			expectedStatus:   constants.BackupUploadStatusPartiallyFailed,
+			expectedProgress: "1/2",
+			expectedPhase:    velerov1.BackupPhasePartiallyFailed,
+			expectedErrors:   2,
+			expectedEvents:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := newBackup("backup-1", nil)
+			veleroClient := velerofake.NewSimpleClientset(backup)
+			recorder := record.NewFakeRecorder(10)
+			c := &backupUploadController{
+				logger:       logrus.New(),
+				namespace:    "velero",
+				veleroClient: veleroClient,
+				recorder:     recorder,
+			}
+
+			ctx := context.Background()
+			require.NoError(t, c.updateBackup(ctx, backup, test.uploads))
+
+			updated, err := veleroClient.VeleroV1().Backups("velero").Get(ctx, "backup-1", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStatus, updated.Annotations[constants.BackupUploadStatusAnnotation])
+			assert.Equal(t, test.expectedProgress, updated.Annotations[constants.BackupUploadProgressAnnotation])
+			assert.Equal(t, test.expectedPhase, updated.Status.Phase)
+			assert.Equal(t, test.expectedErrors, updated.Status.Errors)
+			assert.Len(t, recorder.Events, test.expectedEvents)
+		})
+	}
+}
+
+func newTrackingConfigMap(since time.Time) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: constants.BackupUploadTrackingConfigMap, Namespace: "velero"},
+		Data:       map[string]string{constants.BackupUploadTrackingSinceKey: since.Format(time.RFC3339)},
+	}
+}
+
+func newSyncController(kubeObjects []runtime.Object, backups []runtime.Object, snapshots ...runtime.Object) (*backupUploadController, *record.FakeRecorder) {
+	recorder := record.NewFakeRecorder(10)
+	return &backupUploadController{
+		logger:             logrus.New(),
+		namespace:          "velero",
+		kubeClient:         kubefake.NewSimpleClientset(kubeObjects...),
+		backupdriverClient: backupdriverfake.NewSimpleClientset(snapshots...).BackupdriverV1alpha1(),
+		veleroClient:       velerofake.NewSimpleClientset(backups...),
+		recorder:           recorder,
+	}, recorder
+}
+
+func TestSyncFailingUpload(t *testing.T) {
+	ctx := context.Background()
+	backup := newBackup("backup-1", nil)
+	backup.Status.CompletionTimestamp = &metav1.Time{Time: backupStartTime.Add(time.Hour)}
+	snapshot := newSnapshot("snap-1", backupdriverapi.SnapshotPhaseUploading, "br-1", nil, false)
+	c, recorder := newSyncController([]runtime.Object{newTrackingConfigMap(backupStartTime)}, []runtime.Object{backup}, snapshot)
+	getBackup := func() *velerov1.Backup {
+		backup, err := c.veleroClient.VeleroV1().Backups("velero").Get(ctx, "backup-1", metav1.GetOptions{})
+		require.NoError(t, err)
+		return backup
+	}
+
+	// The Snapshot stays Uploading while the failing upload is retried
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c.sync(ctx))
+		updated := getBackup()
+		assert.Equal(t, constants.BackupUploadStatusInProgress, updated.Annotations[constants.BackupUploadStatusAnnotation])
+		assert.Equal(t, "0/1", updated.Annotations[constants.BackupUploadProgressAnnotation])
+		assert.Equal(t, velerov1.BackupPhaseCompleted, updated.Status.Phase)
+		assert.Equal(t, 1, updated.Status.Errors)
+	}
+
+	// The Snapshot is UploadFailed once the upload has failed its last retry
+	snapshot.Status.Phase = backupdriverapi.SnapshotPhaseUploadFailed
+	snapshot.Status.Message = "The upload failed after 10 retries"
+	_, err := c.backupdriverClient.Snapshots("app-ns").Update(ctx, snapshot, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, c.sync(ctx))
+	updated := getBackup()
+	assert.Equal(t, constants.BackupUploadStatusPartiallyFailed, updated.Annotations[constants.BackupUploadStatusAnnotation])
+	assert.Equal(t, velerov1.BackupPhasePartiallyFailed, updated.Status.Phase)
+	assert.Equal(t, 2, updated.Status.Errors)
+	assert.Len(t, recorder.Events, 1)
+
+	// The failed upload is only counted once
+	require.NoError(t, c.sync(ctx))
+	assert.Equal(t, 2, getBackup().Status.Errors)
+	assert.Len(t, recorder.Events, 1)
+}
+
+func TestSyncBackupsCompletedBeforeTracking(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	// The failed upload of the backup completed before the upgrade is already in its errors
+	oldBackup := newBackup("backup-1", nil)
+	oldBackup.Status.CompletionTimestamp = &metav1.Time{Time: now.Add(-time.Hour)}
+	// The backup tracked before the restart of the backup-driver is still tracked
+	trackedBackup := newBackup("backup-2", map[string]string{constants.BackupUploadStatusAnnotation: constants.BackupUploadStatusInProgress})
+	trackedBackup.Status.CompletionTimestamp = &metav1.Time{Time: now.Add(-time.Hour)}
+	newerBackup := newBackup("backup-3", nil)
+	newerBackup.Status.CompletionTimestamp = &metav1.Time{Time: now.Add(time.Hour)}
+	c, recorder := newSyncController(nil, []runtime.Object{oldBackup, trackedBackup, newerBackup},
+		newSnapshot("snap-1", backupdriverapi.SnapshotPhaseUploadFailed, "br-1", nil, false))
+
+	require.NoError(t, c.sync(ctx))
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps("velero").Get(ctx, constants.BackupUploadTrackingConfigMap, metav1.GetOptions{})
+	require.NoError(t, err)
+	since, err := time.Parse(time.RFC3339, configMap.Data[constants.BackupUploadTrackingSinceKey])
+	require.NoError(t, err)
+	assert.WithinDuration(t, now, since, time.Minute)
+
+	expected := map[string]string{
+		"backup-1": "",
+		"backup-2": constants.BackupUploadStatusCompleted,
+		"backup-3": constants.BackupUploadStatusCompleted,
+	}
+	for name, status := range expected {
+		backup, err := c.veleroClient.VeleroV1().Backups("velero").Get(ctx, name, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, status, backup.Annotations[constants.BackupUploadStatusAnnotation], name)
+		assert.Equal(t, 1, backup.Status.Errors, name)
+	}
+	assert.Empty(t, recorder.Events)
+
+	// The time is kept when the backup-driver restarts
+	c.since = time.Time{}
+	configMap.Data[constants.BackupUploadTrackingSinceKey] = backupStartTime.Format(time.RFC3339)
+	_, err = c.kubeClient.CoreV1().ConfigMaps("velero").Update(ctx, configMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, c.sync(ctx))
+	assert.True(t, backupStartTime.Equal(c.since))
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupdriver
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	backupdriverapi "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backuprepository"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	pluginscheme "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/scheme"
+	backupdriverclientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/incremental"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/scrub"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/snapshotmgr"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/cmd/util/downloadrequest"
+	veleroclientset "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// The reasons of the events recorded on the BackupRepositories by the repository garbage collection
+	OrphanedSnapshotFoundReason          = "OrphanedSnapshotFound"
+	OrphanedSnapshotDeletedReason        = "OrphanedSnapshotDeleted"
+	OrphanedSnapshotDeletionFailedReason = "OrphanedSnapshotDeletionFailed"
+
+	// The timeout of the download of the contents of each backup
+	backupContentsTimeout = time.Minute
+)
+
+// RepositoryGCController is the interface for the controller which deletes the orphaned snapshots from the backup
+// repositories, i.e. the snapshots which are not referenced by any Velero backup or pending Snapshot anymore. They
+// are left behind when the deletion of a snapshot fails, or when a backup is deleted while the plugin is down.
+type RepositoryGCController interface {
+	// Run starts the controller.
+	Run(ctx context.Context)
+}
+
+type repositoryGCController struct {
+	name   string
+	logger logrus.FieldLogger
+
+	// The namespace of the Velero backups
+	namespace string
+
+	backupdriverClient *backupdriverclientset.BackupdriverV1alpha1Client
+	veleroClient       veleroclientset.Interface
+	snapManager        *snapshotmgr.SnapshotManager
+	recorder           record.EventRecorder
+
+	// How often the repositories are collected
+	period time.Duration
+	// How long a snapshot has to stay orphaned before it is deleted
+	gracePeriod time.Duration
+	// The orphaned snapshots are only reported, unless their deletion is enabled
+	deleteEnabled bool
+
+	tracker *scrub.OrphanTracker
+	// The snapshots referenced by the contents of the backups, by backup UID. The contents of a backup never change.
+	backupReferences map[types.UID][]scrub.SnapshotReference
+}
+
+// NewRepositoryGCController returns a RepositoryGCController.
+func NewRepositoryGCController(
+	name string,
+	logger logrus.FieldLogger,
+	namespace string,
+	kubeClient kubernetes.Interface,
+	backupdriverClient *backupdriverclientset.BackupdriverV1alpha1Client,
+	veleroClient veleroclientset.Interface,
+	snapManager *snapshotmgr.SnapshotManager,
+	period time.Duration,
+	gracePeriod time.Duration,
+	deleteEnabled bool) RepositoryGCController {
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(logger.Debugf)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(pluginscheme.Scheme, corev1.EventSource{Component: name})
+
+	return &repositoryGCController{
+		name:               name,
+		logger:             logger.WithField("controller", name),
+		namespace:          namespace,
+		backupdriverClient: backupdriverClient,
+		veleroClient:       veleroClient,
+		snapManager:        snapManager,
+		recorder:           recorder,
+		period:             period,
+		gracePeriod:        gracePeriod,
+		deleteEnabled:      deleteEnabled,
+		tracker:            scrub.NewOrphanTracker(),
+		backupReferences:   make(map[types.UID][]scrub.SnapshotReference),
+	}
+}
+
+// Run collects the repositories every period until the context is done.
+func (c *repositoryGCController) Run(ctx context.Context) {
+	c.logger.Infof("Starting the repository garbage collection, period: %v, grace period: %v, delete: %v",
+		c.period, c.gracePeriod, c.deleteEnabled)
+	defer c.logger.Info("Shutting down the repository garbage collection")
+
+	wait.Until(func() {
+		if err := c.collect(ctx); err != nil {
+			c.logger.WithError(err).Error("Failed to collect the backup repositories")
+		}
+	}, c.period, ctx.Done())
+}
+
+// collect finds the orphaned snapshots in each repository, and deletes the ones which have been orphaned for longer
+// than the grace period.
+func (c *repositoryGCController) collect(ctx context.Context) error {
+	references, ok, err := c.getSnapshotReferences(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	backupRepositoryList, err := c.backupdriverClient.BackupRepositories().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list the BackupRepositories")
+	}
+	now := time.Now()
+	for _, group := range scrub.GroupBackupRepositories(backupRepositoryList.Items) {
+		if !isRepositoryOwned(group, c.namespace) {
+			// The snapshots of the other claimants are not referenced by the backups of the cluster
+			c.logger.Infof("The repository of the backup repository %s is not only claimed in the namespace %s, skipping it",
+				group[0].Name, c.namespace)
+			continue
+		}
+		if err := c.collectRepository(ctx, group, references, now); err != nil {
+			c.logger.WithError(err).Errorf("Failed to collect the repository of the backup repository %s", group[0].Name)
+		}
+	}
+	return nil
+}
+
+// isRepositoryOwned returns true if all the BackupRepositories which share a repository were claimed in the Velero
+// namespace, i.e. by the cluster itself. The repositories claimed for the Guest Clusters in the Supervisor namespaces,
+// or by the previous releases which did not record the namespace of the claims, are not owned.
+func isRepositoryOwned(group []backupdriverapi.BackupRepository, namespace string) bool {
+	for _, backupRepository := range group {
+		if backupRepository.Labels[constants.BackupRepositoryClaimNamespaceLabel] != namespace {
+			return false
+		}
+	}
+	return true
+}
+
+// collectRepository collects the repository shared by the group of BackupRepositories.
+func (c *repositoryGCController) collectRepository(ctx context.Context, group []backupdriverapi.BackupRepository,
+	references []scrub.SnapshotReference, now time.Time) error {
+	backupRepository := &group[0]
+	log := c.logger.WithField("backupRepository", backupRepository.Name)
+
+	var groupReferences []scrub.SnapshotReference
+	for _, member := range group {
+		for _, reference := range references {
+			if reference.BackupRepository == member.Name {
+				groupReferences = append(groupReferences, reference)
+			}
+		}
+	}
+	petm, err := backuprepository.GetRepositoryFromBackupRepository(backupRepository, log)
+	if err != nil {
+		return err
+	}
+	// The data of the snapshots is not read back, only which snapshots are in the repository matters
+	results, err := scrub.VerifyRepository(ctx, petm, groupReferences, scrub.Options{}, log)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Status == scrub.SnapshotStatusCorrupt || result.Status == scrub.SnapshotStatusFailed {
+			// The chains of the snapshots in the repository cannot be trusted, a snapshot which looks orphaned may
+			// still be the base of a referenced snapshot
+			return errors.Errorf("The snapshot %s is %s: %s, skipping the repository", result.PeID.String(), result.Status, result.Message)
+		}
+	}
+
+	for _, orphan := range c.tracker.Update(backupRepository.Name, results, now) {
+		peID := orphan.PeID
+		if _, ok := incremental.StagedSnapshotID(peID); ok {
+			// The staging snapshot of an interrupted consolidation is cleaned up by the next deletion of the snapshot
+			log.Infof("Skipping the staging snapshot %s", peID.String())
+			continue
+		}
+		orphanedFor := now.Sub(orphan.Since)
+		if orphanedFor < c.gracePeriod {
+			log.Debugf("The snapshot %s has been orphaned for %v, within the grace period", peID.String(), orphanedFor)
+			continue
+		}
+		if !c.deleteEnabled {
+			log.Warningf("The snapshot %s has been orphaned for %v, its deletion is disabled", peID.String(), orphanedFor)
+			c.recorder.Eventf(backupRepository, corev1.EventTypeWarning, OrphanedSnapshotFoundReason,
+				"The snapshot %s is not referenced by any backup since %s", peID.String(), orphan.Since.Format(time.RFC3339))
+			continue
+		}
+		log.Infof("Deleting the snapshot %s, orphaned for %v", peID.String(), orphanedFor)
+		if err := c.snapManager.DeleteRemoteSnapshotFromRepo(peID, backupRepository); err != nil {
+			log.WithError(err).Errorf("Failed to delete the orphaned snapshot %s", peID.String())
+			c.recorder.Eventf(backupRepository, corev1.EventTypeWarning, OrphanedSnapshotDeletionFailedReason,
+				"Failed to delete the orphaned snapshot %s: %v", peID.String(), err)
+			continue
+		}
+		c.tracker.Forget(backupRepository.Name, peID)
+		c.recorder.Eventf(backupRepository, corev1.EventTypeNormal, OrphanedSnapshotDeletedReason,
+			"Deleted the snapshot %s, not referenced by any backup since %s", peID.String(), orphan.Since.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// getSnapshotReferences returns the snapshots referenced by the backups and the pending Snapshots. It returns false
+// if the references cannot be complete yet, i.e. while a backup is in progress.
+func (c *repositoryGCController) getSnapshotReferences(ctx context.Context) ([]scrub.SnapshotReference, bool, error) {
+	backupList, err := c.veleroClient.VeleroV1().Backups(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, false, errors.Wrap(err, "Failed to list the backups")
+	}
+	for _, backup := range backupList.Items {
+		if backup.Status.Phase == "" || backup.Status.Phase == velerov1.BackupPhaseNew || backup.Status.Phase == velerov1.BackupPhaseInProgress {
+			c.logger.Infof("The backup %s is in phase %s, skipping the repository garbage collection", backup.Name, backup.Status.Phase)
+			return nil, false, nil
+		}
+	}
+
+	var references []scrub.SnapshotReference
+	backupReferences := make(map[types.UID][]scrub.SnapshotReference)
+	for _, backup := range backupList.Items {
+		switch backup.Status.Phase {
+		case velerov1.BackupPhaseFailedValidation, velerov1.BackupPhaseFailed:
+			// The snapshots of the failed backups are not restorable
+			continue
+		}
+		cached, ok := c.backupReferences[backup.UID]
+		if !ok {
+			cached, err = c.getBackupSnapshotReferences(backup.Name)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		backupReferences[backup.UID] = cached
+		references = append(references, cached...)
+	}
+	// The deleted backups are forgotten
+	c.backupReferences = backupReferences
+
+	snapshotList, err := c.backupdriverClient.Snapshots(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, false, errors.Wrap(err, "Failed to list the Snapshots")
+	}
+	for i := range snapshotList.Items {
+		reference, ok, err := scrub.GetPendingSnapshotReference(&snapshotList.Items[i], c.logger)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			references = append(references, reference)
+		}
+	}
+	return references, true, nil
+}
+
+func (c *repositoryGCController) getBackupSnapshotReferences(backupName string) ([]scrub.SnapshotReference, error) {
+	// The contents of a backup may be large, so they are downloaded to a temporary file
+	contents, err := ioutil.TempFile("", "backup-contents")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create a temporary file")
+	}
+	defer os.Remove(contents.Name())
+	defer contents.Close()
+
+	c.logger.Debugf("Downloading the contents of the backup %s", backupName)
+	err = downloadrequest.Stream(c.veleroClient.VeleroV1(), c.namespace, backupName, velerov1.DownloadTargetKindBackupContents,
+		contents, backupContentsTimeout, false, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to download the contents of the backup %s", backupName)
+	}
+	if _, err := contents.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrapf(err, "Failed to read the contents of the backup %s", backupName)
+	}
+	return scrub.GetSnapshotReferences(backupName, contents, c.logger)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupdriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	backupdriverapi "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/builder"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/scrub"
+)
+
+func newBackupRepository(name string, claimNamespace string, bucket string) backupdriverapi.BackupRepository {
+	b := builder.ForBackupRepository(name).
+		RepositoryDriver("s3repository.astrolabe.vmware-tanzu.com").
+		RepositoryParameters(map[string]string{"region": "us-west-1", "bucket": bucket})
+	if claimNamespace != "" {
+		b = b.BackupRepositoryClaimNamespace(claimNamespace)
+	}
+	return *b.Result()
+}
+
+func TestIsRepositoryOwned(t *testing.T) {
+	backupRepositories := []backupdriverapi.BackupRepository{
+		// Claimed by the cluster for two namespaces
+		newBackupRepository("br-1", "velero", "velero-bucket"),
+		newBackupRepository("br-2", "velero", "velero-bucket"),
+		// Claimed for a Guest Cluster
+		newBackupRepository("br-3", "guest-ns", "guest-bucket"),
+		// Shared by the cluster and a Guest Cluster
+		newBackupRepository("br-4", "velero", "shared-bucket"),
+		newBackupRepository("br-5", "guest-ns", "shared-bucket"),
+		// Created by a previous release
+		newBackupRepository("br-6", "", "legacy-bucket"),
+	}
+
+	owned := make(map[string]bool)
+	for _, group := range scrub.GroupBackupRepositories(backupRepositories) {
+		owned[group[0].RepositoryParameters["bucket"]] = isRepositoryOwned(group, "velero")
+	}
+	assert.Equal(t, map[string]bool{
+		"velero-bucket": true,
+		"guest-bucket":  false,
+		"shared-bucket": false,
+		"legacy-bucket": false,
+	}, owned)
+}
@@ -5,14 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	jsonpatch "github.com/evanphx/json-patch"
-	"github.com/vmware-tanzu/astrolabe/pkg/s3repository"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/checksum"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/compression"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/encryption"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	"k8s.io/apimachinery/pkg/types"
 	"reflect"
 	"time"
 
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/google/uuid"
@@ -41,6 +45,8 @@ either case, it does not return until the BackupRepository is assigned and ready
 func ClaimBackupRepository(ctx context.Context,
 	repositoryDriver string,
 	repositoryParameters map[string]string,
+	repositoryCredential *backupdriverv1.SecretKeyReference,
+	repositoryEncryption *backupdriverv1.RepositoryEncryption,
 	allowedNamespaces []string,
 	ns string,
 	backupdriverV1Client *v1.BackupdriverV1alpha1Client,
@@ -58,7 +64,8 @@ func ClaimBackupRepository(ctx context.Context,
 	// Pre-existing BackupRepositoryClaims found.
 	for _, repositoryClaimItem := range brcList.Items {
 		// Process the BRC only if its they match all the params.
-		repoMatch := compareBackupRepositoryClaim(repositoryDriver, repositoryParameters, allowedNamespaces, &repositoryClaimItem, logger)
+		repoMatch := compareBackupRepositoryClaim(repositoryDriver, repositoryParameters, repositoryCredential, repositoryEncryption,
+			allowedNamespaces, &repositoryClaimItem, logger)
 		if repoMatch {
 			if repositoryClaimItem.BackupRepository == "" {
 				logger.Infof("Found matching BRC for the parameters with no BR reference, BRC: %s", repositoryClaimItem.Name)
@@ -83,7 +90,8 @@ func ClaimBackupRepository(ctx context.Context,
 		}
 		backupRepoClaimName := "brc-" + backupRepoClaimUUID.String()
 		backupRepositoryClaimReq := builder.ForBackupRepositoryClaim(ns, backupRepoClaimName).
-			RepositoryParameters(repositoryParameters).RepositoryDriver().
+			RepositoryParameters(repositoryParameters).RepositoryDriver(repositoryDriver).
+			RepositoryCredential(repositoryCredential).RepositoryEncryption(repositoryEncryption).
 			AllowedNamespaces(allowedNamespaces).Result()
 		backupRepositoryClaim, err := backupdriverV1Client.BackupRepositoryClaims(ns).Create(context.TODO(), backupRepositoryClaimReq, metav1.CreateOptions{})
 		if err != nil {
@@ -149,9 +157,12 @@ func CreateBackupRepository(ctx context.Context,
 	if backupRepoReq == nil {
 		backupRepoReq = builder.ForBackupRepository(backupRepoName).
 			BackupRepositoryClaim(brc.Name).
+			BackupRepositoryClaimNamespace(brc.Namespace).
 			AllowedNamespaces(brc.AllowedNamespaces).
 			RepositoryParameters(brc.RepositoryParameters).
-			RepositoryDriver().
+			RepositoryDriver(brc.RepositoryDriver).
+			RepositoryCredential(brc.RepositoryCredential).
+			RepositoryEncryption(brc.RepositoryEncryption).
 			SvcBackupRepositoryName(svcBrName).Result()
 		newBackupRepo, err := backupdriverV1Client.BackupRepositories().Create(context.TODO(), backupRepoReq, metav1.CreateOptions{})
 		if err != nil {
@@ -164,6 +175,19 @@ func CreateBackupRepository(ctx context.Context,
 		return newBackupRepo, nil
 	}
 	logger.Infof("Found BackupRepository %s for the BackupRepositoryClaim %s", backupRepoReq.Name, brc.Name)
+	if _, ok := backupRepoReq.Labels[constants.BackupRepositoryClaimNamespaceLabel]; !ok {
+		// The BackupRepositories created by the previous releases are labeled when their claims are resynced
+		backupRepoReq = backupRepoReq.DeepCopy()
+		if backupRepoReq.Labels == nil {
+			backupRepoReq.Labels = make(map[string]string)
+		}
+		backupRepoReq.Labels[constants.BackupRepositoryClaimNamespaceLabel] = brc.Namespace
+		backupRepoReq, err = backupdriverV1Client.BackupRepositories().Update(context.TODO(), backupRepoReq, metav1.UpdateOptions{})
+		if err != nil {
+			logger.Errorf("Failed to label the BackupRepository API object %s: %v", backupRepoName, err)
+			return nil, err
+		}
+	}
 
 	return backupRepoReq, nil
 }
@@ -189,7 +213,26 @@ func ClaimSvcBackupRepository(ctx context.Context,
 	if err != nil {
 		return "", errors.WithStack(err)
 	}
-	return ClaimBackupRepository(ctx, brc.RepositoryDriver, brc.RepositoryParameters, []string{svcNamespace}, svcNamespace, svcBackupdriverClient, logger)
+	var svcRepositoryCredential *backupdriverv1.SecretKeyReference
+	if brc.RepositoryCredential != nil {
+		// The Secret referenced by the guest BRC is not accessible in the Supervisor cluster,
+		// copy the credentials to the Supervisor namespace and reference the copy instead.
+		svcRepositoryCredential, err = copyRepositoryCredentialToSvc(brc.RepositoryCredential, brc.RepositoryParameters["profile"], svcConfig,
+			svcNamespace, logger)
+		if err != nil {
+			return "", err
+		}
+	}
+	var svcRepositoryEncryption *backupdriverv1.RepositoryEncryption
+	if brc.RepositoryEncryption != nil {
+		// The same applies to the encryption keys
+		svcRepositoryEncryption, err = copyRepositoryEncryptionToSvc(brc.RepositoryEncryption, svcConfig, svcNamespace, logger)
+		if err != nil {
+			return "", err
+		}
+	}
+	return ClaimBackupRepository(ctx, brc.RepositoryDriver, brc.RepositoryParameters, svcRepositoryCredential, svcRepositoryEncryption,
+		[]string{svcNamespace}, svcNamespace, svcBackupdriverClient, logger)
 }
 
 func checkIfBackupRepositoryClaimIsReferenced(
@@ -263,6 +306,8 @@ func patchBackupRepositoryClaimInt(req *backupdriverv1.BackupRepositoryClaim,
 
 func compareBackupRepositoryClaim(repositoryDriver string,
 	repositoryParameters map[string]string,
+	repositoryCredential *backupdriverv1.SecretKeyReference,
+	repositoryEncryption *backupdriverv1.RepositoryEncryption,
 	allowedNamespaces []string,
 	backupRepositoryClaim *backupdriverv1.BackupRepositoryClaim,
 	logger logrus.FieldLogger) bool {
@@ -273,6 +318,16 @@ func compareBackupRepositoryClaim(repositoryDriver string,
 		logger.Infof("repositoryParameters not matched")
 		return false
 	}
+	equal = reflect.DeepEqual(repositoryCredential, backupRepositoryClaim.RepositoryCredential)
+	if !equal {
+		logger.Infof("repositoryCredential not matched")
+		return false
+	}
+	equal = reflect.DeepEqual(repositoryEncryption, backupRepositoryClaim.RepositoryEncryption)
+	if !equal {
+		logger.Infof("repositoryEncryption not matched")
+		return false
+	}
 	equal = repositoryDriver == backupRepositoryClaim.RepositoryDriver
 	if !equal {
 		logger.Infof("repositoryDriver not matched")
@@ -285,18 +340,57 @@ func compareBackupRepositoryClaim(repositoryDriver string,
 	return true
 }
 
-func GetRepositoryFromBackupRepository(backupRepository *backupdriverv1.BackupRepository, logger logrus.FieldLogger) (*s3repository.ProtectedEntityTypeManager, error) {
+func GetRepositoryFromBackupRepository(backupRepository *backupdriverv1.BackupRepository, logger logrus.FieldLogger) (astrolabe.ProtectedEntityTypeManager, error) {
+	params := make(map[string]interface{})
+	for k, v := range backupRepository.RepositoryParameters {
+		params[k] = v
+	}
+	if backupRepository.RepositoryCredential != nil {
+		// The credentials are resolved only in memory, right before the repository is accessed.
+		if err := resolveRepositoryCredential(params, backupRepository.RepositoryCredential, logger); err != nil {
+			return nil, err
+		}
+	}
+	var petm astrolabe.ProtectedEntityTypeManager
+	var err error
 	switch backupRepository.RepositoryDriver {
 	case constants.S3RepositoryDriver:
-		params := make(map[string]interface{})
-		for k, v := range backupRepository.RepositoryParameters {
-			params[k] = v
-		}
-		return utils.GetS3PETMFromParamsMap(params, logger)
+		petm, err = utils.GetS3PETMFromParamsMap(params, logger)
+	case constants.FileSystemRepositoryDriver:
+		petm, err = utils.GetFileSystemPETMFromParamsMap(params, logger)
 	default:
-		errMsg := fmt.Sprintf("Unsupported backuprepository driver type: %s. Only support %s.", backupRepository.RepositoryDriver, constants.S3RepositoryDriver)
+		errMsg := fmt.Sprintf("Unsupported backuprepository driver type: %s. Only support %s and %s.", backupRepository.RepositoryDriver,
+			constants.S3RepositoryDriver, constants.FileSystemRepositoryDriver)
 		return nil, errors.New(errMsg)
 	}
+	if err != nil {
+		return nil, err
+	}
+	// The checksums cover the snapshots as stored in the repository, i.e. after the compression and the encryption
+	petm = checksum.NewProtectedEntityTypeManager(petm, logger)
+	if backupRepository.RepositoryEncryption != nil {
+		// The snapshots are encrypted and decrypted on the fly on their way to and from the repository
+		keyring, err := resolveRepositoryEncryption(backupRepository.RepositoryEncryption, logger)
+		if err != nil {
+			return nil, err
+		}
+		encryptionPETM := encryption.NewProtectedEntityTypeManager(petm, keyring, logger)
+		_, pluginClient, err := getInClusterClients()
+		if err != nil {
+			return nil, err
+		}
+		if err := recordUnencryptedSnapshots(backupRepository, encryptionPETM, pluginClient, logger); err != nil {
+			return nil, err
+		}
+		petm = encryptionPETM
+	}
+	// The data is compressed before it is encrypted, as the encrypted data does not compress. The data is always
+	// read through the compression, as the snapshots may have been written with another codec.
+	compressionPETM, err := compression.NewProtectedEntityTypeManager(petm, backupRepository.RepositoryParameters[constants.RepositoryParamCompression], logger)
+	if err != nil {
+		return nil, err
+	}
+	return compressionPETM, nil
 }
 
 func GetBackupRepositoryFromBackupRepositoryName(backupRepositoryName string) (*backupdriverv1.BackupRepository, error) {
@@ -322,13 +416,16 @@ func RetrieveBackupRepositoryFromBSL(ctx context.Context, bslName string, pvcNam
 	var backupRepositoryName string
 	logger.Info("Claiming backup repository")
 
-	repositoryParameters := make(map[string]string)
-	err := utils.RetrieveParamsFromBSL(repositoryParameters, bslName, restConfig, logger)
+	repositoryDriver, repositoryParameters, repositoryCredential, err := retrieveRepositoryParameters(bslName, veleroNs, restConfig, logger)
 	if err != nil {
-		logger.Errorf("Failed to translate BSL to repository parameters: %v", err)
 		return backupRepositoryName, errors.WithStack(err)
 	}
-	backupRepositoryName, err = ClaimBackupRepository(ctx, constants.S3RepositoryDriver, repositoryParameters,
+	repositoryEncryption, err := retrieveRepositoryEncryption(veleroNs, restConfig)
+	if err != nil {
+		logger.Errorf("Failed to retrieve the repository encryption: %v", err)
+		return backupRepositoryName, errors.WithStack(err)
+	}
+	backupRepositoryName, err = ClaimBackupRepository(ctx, repositoryDriver, repositoryParameters, repositoryCredential, repositoryEncryption,
 		[]string{pvcNamespace}, veleroNs, backupdriverClient, logger)
 	if err != nil {
 		logger.Errorf("Failed to claim backup repository: %v", err)
@@ -336,3 +433,51 @@ func RetrieveBackupRepositoryFromBSL(ctx context.Context, bslName string, pvcNam
 	}
 	return backupRepositoryName, nil
 }
+
+// retrieveRepositoryParameters returns the repository driver, parameters and credential reference to claim the backup
+// repository with. The file system repository is used if it is selected in the repository ConfigMap, otherwise the
+// parameters are translated from the BSL.
+func retrieveRepositoryParameters(bslName string, veleroNs string, restConfig *rest.Config,
+	logger logrus.FieldLogger) (string, map[string]string, *backupdriverv1.SecretKeyReference, error) {
+	repositoryParameters := make(map[string]string)
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return "", nil, nil, errors.Wrap(err, "Failed to retrieve the k8s clientset")
+	}
+	repositoryConfig, err := utils.RetrieveRepositoryConfig(kubeClient, veleroNs)
+	if err != nil {
+		logger.Errorf("Failed to retrieve the repository config: %v", err)
+		return "", nil, nil, err
+	}
+	if utils.IsFileSystemRepositoryConfigured(repositoryConfig) {
+		path, ok := repositoryConfig[constants.RepositoryConfigPathKey]
+		if !ok || path == "" {
+			path = constants.DefaultFileSystemRepositoryPath
+		}
+		logger.Infof("Using the file system repository mounted at %s", path)
+		repositoryParameters[constants.RepositoryConfigPathKey] = path
+		if err := retrieveRepositoryCompression(repositoryParameters, repositoryConfig); err != nil {
+			return "", nil, nil, err
+		}
+		return constants.FileSystemRepositoryDriver, repositoryParameters, nil, nil
+	}
+
+	repositoryCredential, err := utils.RetrieveParamsFromBSL(repositoryParameters, bslName, restConfig, logger)
+	if err != nil {
+		logger.Errorf("Failed to translate BSL to repository parameters: %v", err)
+		return "", nil, nil, err
+	}
+	if err := retrieveRepositoryCompression(repositoryParameters, repositoryConfig); err != nil {
+		return "", nil, nil, err
+	}
+	return constants.S3RepositoryDriver, repositoryParameters, repositoryCredential, nil
+}
+
+// retrieveRepositoryCompression sets the compression codec of the repository ConfigMap, which takes precedence over
+// the one of the BSL, in the repository parameters, and makes sure that the codec is supported.
+func retrieveRepositoryCompression(repositoryParameters map[string]string, repositoryConfig map[string]string) error {
+	if codec := repositoryConfig[constants.RepositoryParamCompression]; codec != "" {
+		repositoryParameters[constants.RepositoryParamCompression] = codec
+	}
+	return compression.ValidateCodec(repositoryParameters[constants.RepositoryParamCompression])
+}
@@ -1,12 +1,19 @@
 package backuprepository
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/encryption"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/fsrepository"
+	pluginfake "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/fake"
 	veleroplugintest "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/test"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	"github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"os"
 	"testing"
@@ -16,6 +23,7 @@ import (
 	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
 	backupdriverTypedV1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/backupdriver/v1alpha1"
 	"k8s.io/apimachinery/pkg/fields"
+	kubefake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -52,7 +60,7 @@ func TestClaimBackupRepository(t *testing.T) {
 
 	// The following anon function triggers ClaimBackupRepository and waits for the BR
 	go func() {
-		backupRepositoryName, err := ClaimBackupRepository(ctx, constants.S3RepositoryDriver, repositoryParameters,
+		backupRepositoryName, err := ClaimBackupRepository(ctx, constants.S3RepositoryDriver, repositoryParameters, nil, nil,
 			[]string{"test"}, veleroNs, backupdriverClient, logger)
 		if err != nil {
 			t.Fatalf("Failed to retrieve the BackupRepository name.")
@@ -137,7 +145,7 @@ func TestBackupRepositoryCreationFromBSL(t *testing.T) {
 	repositoryParameters[constants.AWS_SECRET_ACCESS_KEY] = secretAccessKey
 
 	logger.Infof("Repository Parameters: %v", repositoryParameters)
-	backupRepositoryName, err := ClaimBackupRepository(ctx, constants.S3RepositoryDriver, repositoryParameters,
+	backupRepositoryName, err := ClaimBackupRepository(ctx, constants.S3RepositoryDriver, repositoryParameters, nil, nil,
 		[]string{"test"}, veleroNs, backupdriverClient, logger)
 	if err != nil {
 		t.Fatalf("Failed to retrieve the BackupRepository name.")
@@ -169,6 +177,15 @@ func TestCreateRepositoryFromBackupRepository(t *testing.T) {
 	map1 := make(map[string]string)
 	map2 := make(map[string]string)
 	map2["region"] = "us-west-1"
+	repoDir, err := ioutil.TempDir("", "backup-repository")
+	if err != nil {
+		t.Fatalf("Failed to create the repository directory: %v", err)
+	}
+	defer os.RemoveAll(repoDir)
+	map3 := map[string]string{
+		constants.RepositoryConfigPathKey:    repoDir,
+		constants.RepositoryParamCompression: "lz4",
+	}
 	tests := []struct {
 		name             string
 		key              string
@@ -188,7 +205,7 @@ func TestCreateRepositoryFromBackupRepository(t *testing.T) {
 				},
 				RepositoryDriver: "unsupported-driver",
 			},
-			expectedErr: errors.New("Unsupported backuprepository driver type: unsupported-driver. Only support s3repository.astrolabe.vmware-tanzu.com."),
+			expectedErr: errors.New("Unsupported backuprepository driver type: unsupported-driver. Only support s3repository.astrolabe.vmware-tanzu.com and fsrepository.astrolabe.vmware-tanzu.com."),
 		},
 		{
 			name: "Repository parameter missing region should return error",
@@ -222,6 +239,38 @@ func TestCreateRepositoryFromBackupRepository(t *testing.T) {
 			},
 			expectedErr: errors.New("Missing bucket param, cannot initialize S3 PETM"),
 		},
+		{
+			name: "Repository parameter missing path should return error",
+			key:  "miss-path",
+			backupRepository: &backupdriverv1.BackupRepository{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: backupdriverv1.SchemeGroupVersion.String(),
+					Kind:       "BackupRepository",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "default",
+				},
+				RepositoryDriver:     constants.FileSystemRepositoryDriver,
+				RepositoryParameters: map1,
+			},
+			expectedErr: errors.New("Missing path param, cannot initialize file system PETM"),
+		},
+		{
+			name: "Unsupported compression codec should return error",
+			key:  "unsupported-compression",
+			backupRepository: &backupdriverv1.BackupRepository{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: backupdriverv1.SchemeGroupVersion.String(),
+					Kind:       "BackupRepository",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "default",
+				},
+				RepositoryDriver:     constants.FileSystemRepositoryDriver,
+				RepositoryParameters: map3,
+			},
+			expectedErr: errors.New("Unsupported compression codec \"lz4\", the supported codecs are gzip, none"),
+		},
 	}
 	for _, test := range tests {
 		var (
@@ -234,3 +283,169 @@ func TestCreateRepositoryFromBackupRepository(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrateRepositoryCredentials(t *testing.T) {
+	repositoryParameters := map[string]string{
+		"region":                        "us-west-1",
+		"bucket":                        "velero",
+		constants.AWS_ACCESS_KEY_ID:     "id",
+		constants.AWS_SECRET_ACCESS_KEY: "key",
+	}
+	data, found := extractRepositoryCredential(repositoryParameters)
+	assert.True(t, found)
+	assert.Equal(t, map[string]string{"region": "us-west-1", "bucket": "velero"}, repositoryParameters)
+
+	logger := veleroplugintest.NewLogger()
+	kubeClient := kubefake.NewSimpleClientset()
+	credentialRef, err := CreateRepositoryCredentialSecret(kubeClient, "velero", data, logger)
+	assert.NoError(t, err)
+	// The same credentials are expected to be stored in the same Secret
+	sameCredentialRef, err := CreateRepositoryCredentialSecret(kubeClient, "velero", data, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, credentialRef, sameCredentialRef)
+
+	params := map[string]interface{}{"region": "us-west-1"}
+	err = utils.AddCredentialsFromSecretRef(params, credentialRef, kubeClient, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", params[constants.AWS_ACCESS_KEY_ID])
+	assert.Equal(t, "key", params[constants.AWS_SECRET_ACCESS_KEY])
+
+	_, found = extractRepositoryCredential(repositoryParameters)
+	assert.False(t, found)
+}
+
+func TestMigrateRepositoryCredential(t *testing.T) {
+	ctx := context.Background()
+	logger := veleroplugintest.NewLogger()
+	cloudCredentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: constants.CloudCredentialSecretName, Namespace: "velero"},
+		Data: map[string][]byte{
+			constants.CloudCredentialSecretKey: []byte("[default]\naws_access_key_id = id\naws_secret_access_key = key\n" +
+				"[other]\naws_access_key_id = other-id\naws_secret_access_key = other-key\n"),
+		},
+	}
+	kubeClient := kubefake.NewSimpleClientset(cloudCredentials)
+
+	// The claims in the velero namespace reference the cloud credential Secret like the new claims
+	data := utils.BuildAWSSharedCredentials("other-id", "other-key", "other")
+	credentialRef, err := migrateRepositoryCredential(ctx, kubeClient, "velero", "velero", data, "other", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, &backupdriverv1.SecretKeyReference{
+		Name:      constants.CloudCredentialSecretName,
+		Namespace: "velero",
+		Key:       constants.CloudCredentialSecretKey,
+	}, credentialRef)
+
+	// The rotated credentials are stored in a Secret of their own
+	data = utils.BuildAWSSharedCredentials("old-id", "old-key", "")
+	credentialRef, err = migrateRepositoryCredential(ctx, kubeClient, "velero", "velero", data, "", logger)
+	assert.NoError(t, err)
+	assert.Contains(t, credentialRef.Name, repositoryCredentialSecretPrefix)
+
+	// The claims of the Guest Clusters reference the same Secret as the credentials copied for the new claims
+	data = utils.BuildAWSSharedCredentials("id", "key", "")
+	credentialRef, err = migrateRepositoryCredential(ctx, kubeClient, "velero", "svc-ns", data, "", logger)
+	assert.NoError(t, err)
+	copiedRef, err := CreateRepositoryCredentialSecret(kubeClient, "svc-ns", utils.BuildAWSSharedCredentials("id", "key", "default"), logger)
+	assert.NoError(t, err)
+	assert.Equal(t, copiedRef, credentialRef)
+}
+
+func TestDeleteUnreferencedRepositoryCredentialSecrets(t *testing.T) {
+	ctx := context.Background()
+	logger := veleroplugintest.NewLogger()
+	kubeClient := kubefake.NewSimpleClientset()
+	referencedRef, err := CreateRepositoryCredentialSecret(kubeClient, "svc-ns", utils.BuildAWSSharedCredentials("id", "key", ""), logger)
+	assert.NoError(t, err)
+	unreferencedRef, err := CreateRepositoryCredentialSecret(kubeClient, "svc-ns", utils.BuildAWSSharedCredentials("old-id", "old-key", ""), logger)
+	assert.NoError(t, err)
+	recentRef, err := CreateRepositoryCredentialSecret(kubeClient, "svc-ns", utils.BuildAWSSharedCredentials("new-id", "new-key", ""), logger)
+	assert.NoError(t, err)
+	// Only the recent Secret is within the grace period
+	creationTimes := map[*backupdriverv1.SecretKeyReference]time.Time{
+		referencedRef:   time.Now().Add(-repositoryCredentialGracePeriod),
+		unreferencedRef: time.Now().Add(-repositoryCredentialGracePeriod),
+		recentRef:       time.Now(),
+	}
+	for ref, creationTime := range creationTimes {
+		secret, err := kubeClient.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		secret.CreationTimestamp = metav1.NewTime(creationTime)
+		_, err = kubeClient.CoreV1().Secrets(ref.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+	}
+	// The Secrets not created by the plugin are kept
+	_, err = kubeClient.CoreV1().Secrets("velero").Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: constants.CloudCredentialSecretName, Namespace: "velero"},
+	}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	referenced := map[string]bool{referencedRef.Namespace + "/" + referencedRef.Name: true}
+	assert.NoError(t, deleteUnreferencedRepositoryCredentialSecrets(ctx, kubeClient, referenced, logger))
+
+	secretList, err := kubeClient.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	var secrets []string
+	for _, secret := range secretList.Items {
+		secrets = append(secrets, secret.Name)
+	}
+	assert.ElementsMatch(t, []string{referencedRef.Name, recentRef.Name, constants.CloudCredentialSecretName}, secrets)
+}
+
+func TestRepositoryEncryptionSecret(t *testing.T) {
+	logger := veleroplugintest.NewLogger()
+	kubeClient := kubefake.NewSimpleClientset()
+	keys := map[string][]byte{
+		"key-1": []byte("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="),
+		"key-2": []byte("0123456789abcdef0123456789abcdef"),
+	}
+	encryptionRef, err := createRepositoryEncryptionSecret(kubeClient, "velero", keys, "key-2", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-2", encryptionRef.ActiveKeyID)
+	// The same keys are expected to be stored in the same Secret
+	sameEncryptionRef, err := createRepositoryEncryptionSecret(kubeClient, "velero", keys, "key-2", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, encryptionRef, sameEncryptionRef)
+
+	keyring, err := newKeyringFromSecret(kubeClient, encryptionRef, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-2", keyring.ActiveKeyID())
+
+	// A missing Secret only fails the access to the encrypted snapshots
+	missingRef := &backupdriverv1.RepositoryEncryption{Name: "missing", Namespace: "velero", ActiveKeyID: "key-1"}
+	keyring, err = newKeyringFromSecret(kubeClient, missingRef, logger)
+	assert.NoError(t, err)
+	_, err = encryption.NewEncryptReader(ioutil.NopCloser(bytes.NewReader(nil)), keyring)
+	assert.True(t, encryption.IsKeyNotFound(err))
+}
+
+func TestRecordUnencryptedSnapshots(t *testing.T) {
+	logger := veleroplugintest.NewLogger()
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "backuprepository")
+	assert.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	fsPETM, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logger)
+	assert.NoError(t, err)
+	keyring, err := encryption.NewKeyring("key-1", map[string][]byte{"key-1": []byte("0123456789abcdef0123456789abcdef")}, "test")
+	assert.NoError(t, err)
+	petm := encryption.NewProtectedEntityTypeManager(fsPETM, keyring, logger)
+
+	backupRepository := &backupdriverv1.BackupRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "br-1"},
+	}
+	pluginClient := pluginfake.NewSimpleClientset(backupRepository)
+	assert.NoError(t, recordUnencryptedSnapshots(backupRepository, petm, pluginClient, logger))
+	updated, err := pluginClient.BackupdriverV1alpha1().BackupRepositories().Get(ctx, "br-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	recorded, ok := updated.Annotations[constants.UnencryptedSnapshotsRecordedAnnotation]
+	assert.True(t, ok)
+
+	// The snapshots are only recorded once
+	pluginClient.ClearActions()
+	assert.NoError(t, recordUnencryptedSnapshots(updated, petm, pluginClient, logger))
+	assert.Empty(t, pluginClient.Actions())
+	updated, err = pluginClient.BackupdriverV1alpha1().BackupRepositories().Get(ctx, "br-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, recorded, updated.Annotations[constants.UnencryptedSnapshotsRecordedAnnotation])
+}
@@ -0,0 +1,286 @@
+package backuprepository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	v1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	repositoryCredentialSecretPrefix = "vsphere-plugin-repository-credential-"
+	repositoryCredentialSecretKey    = constants.CloudCredentialSecretKey
+	// The Secrets created recently are not deleted, as the claims which reference them may be about to be created
+	repositoryCredentialGracePeriod = 10 * time.Minute
+)
+
+// resolveRepositoryCredential adds the credentials referenced by the BackupRepository to the repository params.
+func resolveRepositoryCredential(params map[string]interface{}, repositoryCredential *backupdriverv1.SecretKeyReference,
+	logger logrus.FieldLogger) error {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get k8s inClusterConfig")
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return errors.Wrap(err, "Failed to retrieve the k8s clientset")
+	}
+	return utils.AddCredentialsFromSecretRef(params, repositoryCredential, kubeClient, logger)
+}
+
+// CreateRepositoryCredentialSecret stores the repository credentials in a Secret in the namespace and returns the
+// reference to it. The name of the Secret is derived from its content, so that the same credentials are stored once.
+func CreateRepositoryCredentialSecret(kubeClient kubernetes.Interface, ns string, data []byte,
+	logger logrus.FieldLogger) (*backupdriverv1.SecretKeyReference, error) {
+	hash := sha256.Sum256(data)
+	secretName := repositoryCredentialSecretPrefix + hex.EncodeToString(hash[:])[:16]
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: ns,
+			Labels:    utils.AppendVeleroExcludeLabels(map[string]string{constants.RepositoryCredentialLabel: "true"}),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			repositoryCredentialSecretKey: data,
+		},
+	}
+	_, err := kubeClient.CoreV1().Secrets(ns).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, errors.Wrapf(err, "Failed to create the repository credential Secret %s/%s", ns, secretName)
+		}
+		logger.Debugf("The repository credential Secret %s/%s already exists", ns, secretName)
+	} else {
+		logger.Infof("Created the repository credential Secret %s/%s", ns, secretName)
+	}
+	return &backupdriverv1.SecretKeyReference{
+		Name:      secretName,
+		Namespace: ns,
+		Key:       repositoryCredentialSecretKey,
+	}, nil
+}
+
+// copyRepositoryCredentialToSvc copies the credentials of the profile referenced in the Guest Cluster to the Supervisor
+// namespace. They are copied in the same form as the credentials migrated from the parameters of the claims of the
+// previous releases, so that the claims of both reference the same Secret.
+func copyRepositoryCredentialToSvc(repositoryCredential *backupdriverv1.SecretKeyReference, profile string,
+	svcConfig *rest.Config, svcNamespace string, logger logrus.FieldLogger) (*backupdriverv1.SecretKeyReference, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get k8s inClusterConfig")
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to retrieve the k8s clientset")
+	}
+	secret, err := kubeClient.CoreV1().Secrets(repositoryCredential.Namespace).Get(context.TODO(), repositoryCredential.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to retrieve the repository credential Secret %s/%s",
+			repositoryCredential.Namespace, repositoryCredential.Name)
+	}
+	data, ok := secret.Data[repositoryCredential.Key]
+	if !ok {
+		return nil, errors.Errorf("Key %s is not found in the repository credential Secret %s/%s",
+			repositoryCredential.Key, repositoryCredential.Namespace, repositoryCredential.Name)
+	}
+	credential, err := utils.ParseAWSSharedCredentials(data, profile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to extract credentials for profile %s from the Secret %s/%s", profile,
+			repositoryCredential.Namespace, repositoryCredential.Name)
+	}
+	data = utils.BuildAWSSharedCredentials(credential.AccessKeyID, credential.SecretAccessKey, profile)
+	svcKubeClient, err := kubernetes.NewForConfig(svcConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to retrieve the supervisor k8s clientset")
+	}
+	return CreateRepositoryCredentialSecret(svcKubeClient, svcNamespace, data, logger)
+}
+
+// extractRepositoryCredential moves the plaintext credentials, if any, out of the repository parameters.
+// The content of an AWS shared credentials file is returned for the credentials removed.
+func extractRepositoryCredential(repositoryParameters map[string]string) ([]byte, bool) {
+	accessKeyID, idFound := repositoryParameters[constants.AWS_ACCESS_KEY_ID]
+	secretAccessKey, secretFound := repositoryParameters[constants.AWS_SECRET_ACCESS_KEY]
+	if !idFound && !secretFound {
+		return nil, false
+	}
+	delete(repositoryParameters, constants.AWS_ACCESS_KEY_ID)
+	delete(repositoryParameters, constants.AWS_SECRET_ACCESS_KEY)
+	return utils.BuildAWSSharedCredentials(accessKeyID, secretAccessKey, repositoryParameters["profile"]), true
+}
+
+// cloudCredentialReference returns the reference to the cloud credential Secret in the velero namespace, which the
+// claims of the cluster itself reference, if it holds the same credentials for the profile. It returns nil otherwise,
+// e.g. if the credentials have been rotated since.
+func cloudCredentialReference(ctx context.Context, kubeClient kubernetes.Interface, veleroNs string, data []byte,
+	profile string) (*backupdriverv1.SecretKeyReference, error) {
+	secret, err := kubeClient.CoreV1().Secrets(veleroNs).Get(ctx, constants.CloudCredentialSecretName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Failed to retrieve the Secret %s/%s", veleroNs, constants.CloudCredentialSecretName)
+	}
+	secretKey, err := utils.GetCloudCredentialSecretKey(secret)
+	if err != nil {
+		return nil, nil
+	}
+	cloudCredential, err := utils.ParseAWSSharedCredentials(secret.Data[secretKey], profile)
+	if err != nil {
+		return nil, nil
+	}
+	credential, err := utils.ParseAWSSharedCredentials(data, profile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to extract credentials for profile %s", profile)
+	}
+	if credential.AccessKeyID != cloudCredential.AccessKeyID || credential.SecretAccessKey != cloudCredential.SecretAccessKey {
+		return nil, nil
+	}
+	return &backupdriverv1.SecretKeyReference{
+		Name:      secret.Name,
+		Namespace: secret.Namespace,
+		Key:       secretKey,
+	}, nil
+}
+
+// migrateRepositoryCredential returns the reference to the Secret which the new claims in the namespace would
+// reference for the credentials. The claims in the velero namespace reference the cloud credential Secret, the claims
+// of the Guest Clusters reference the Secrets which the credentials are copied to, see copyRepositoryCredentialToSvc.
+func migrateRepositoryCredential(ctx context.Context, kubeClient kubernetes.Interface, veleroNs string, ns string,
+	data []byte, profile string, logger logrus.FieldLogger) (*backupdriverv1.SecretKeyReference, error) {
+	if ns == veleroNs {
+		repositoryCredential, err := cloudCredentialReference(ctx, kubeClient, veleroNs, data, profile)
+		if err != nil {
+			return nil, err
+		}
+		if repositoryCredential != nil {
+			return repositoryCredential, nil
+		}
+		logger.Warnf("The credentials for profile %s are not found in the Secret %s/%s, storing them in a Secret of their own",
+			profile, veleroNs, constants.CloudCredentialSecretName)
+	}
+	return CreateRepositoryCredentialSecret(kubeClient, ns, data, logger)
+}
+
+/*
+ * MigrateRepositoryCredentials moves the plaintext credentials stored in the parameters of pre-existing
+ * BackupRepositories and BackupRepositoryClaims out of them and references the same Secrets as the new claims would,
+ * so that the pre-existing claims keep matching the new ones. The BackupRepositories reference the Secret of their
+ * claims. The ns is the velero namespace.
+ */
+func MigrateRepositoryCredentials(ctx context.Context, ns string, kubeClient kubernetes.Interface,
+	backupdriverV1Client *v1.BackupdriverV1alpha1Client, logger logrus.FieldLogger) error {
+	brcList, err := backupdriverV1Client.BackupRepositoryClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list backup repository claims")
+	}
+	brcCredentials := make(map[string]*backupdriverv1.SecretKeyReference)
+	for _, brc := range brcList.Items {
+		data, found := extractRepositoryCredential(brc.RepositoryParameters)
+		if !found {
+			brcCredentials[brc.Name] = brc.RepositoryCredential
+			continue
+		}
+		repositoryCredential, err := migrateRepositoryCredential(ctx, kubeClient, ns, brc.Namespace, data,
+			brc.RepositoryParameters["profile"], logger)
+		if err != nil {
+			return err
+		}
+		brc.RepositoryCredential = repositoryCredential
+		if _, err := backupdriverV1Client.BackupRepositoryClaims(brc.Namespace).Update(ctx, &brc, metav1.UpdateOptions{}); err != nil {
+			return errors.Wrapf(err, "Failed to update the backup repository claim %s/%s", brc.Namespace, brc.Name)
+		}
+		brcCredentials[brc.Name] = repositoryCredential
+		logger.Infof("Migrated the credentials of the backup repository claim %s/%s to the Secret %s/%s",
+			brc.Namespace, brc.Name, repositoryCredential.Namespace, repositoryCredential.Name)
+	}
+
+	brList, err := backupdriverV1Client.BackupRepositories().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list backup repositories")
+	}
+	for _, br := range brList.Items {
+		data, found := extractRepositoryCredential(br.RepositoryParameters)
+		if !found {
+			continue
+		}
+		repositoryCredential, ok := brcCredentials[br.BackupRepositoryClaim]
+		if !ok || repositoryCredential == nil {
+			// The claim is gone, reference the Secret which a claim in the velero namespace would
+			repositoryCredential, err = migrateRepositoryCredential(ctx, kubeClient, ns, ns, data,
+				br.RepositoryParameters["profile"], logger)
+			if err != nil {
+				return err
+			}
+		}
+		br.RepositoryCredential = repositoryCredential
+		if _, err := backupdriverV1Client.BackupRepositories().Update(ctx, &br, metav1.UpdateOptions{}); err != nil {
+			return errors.Wrapf(err, "Failed to update the backup repository %s", br.Name)
+		}
+		logger.Infof("Migrated the credentials of the backup repository %s to the Secret %s/%s",
+			br.Name, repositoryCredential.Namespace, repositoryCredential.Name)
+	}
+	return nil
+}
+
+// DeleteUnusedRepositoryCredentialSecrets deletes the Secrets created by the plugin to hold the repository
+// credentials once no BackupRepository or BackupRepositoryClaim references them.
+func DeleteUnusedRepositoryCredentialSecrets(ctx context.Context, kubeClient kubernetes.Interface,
+	backupdriverV1Client *v1.BackupdriverV1alpha1Client, logger logrus.FieldLogger) error {
+	referenced := make(map[string]bool)
+	brList, err := backupdriverV1Client.BackupRepositories().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list backup repositories")
+	}
+	for _, br := range brList.Items {
+		if br.RepositoryCredential != nil {
+			referenced[br.RepositoryCredential.Namespace+"/"+br.RepositoryCredential.Name] = true
+		}
+	}
+	brcList, err := backupdriverV1Client.BackupRepositoryClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list backup repository claims")
+	}
+	for _, brc := range brcList.Items {
+		if brc.RepositoryCredential != nil {
+			referenced[brc.RepositoryCredential.Namespace+"/"+brc.RepositoryCredential.Name] = true
+		}
+	}
+	return deleteUnreferencedRepositoryCredentialSecrets(ctx, kubeClient, referenced, logger)
+}
+
+// deleteUnreferencedRepositoryCredentialSecrets deletes the repository credential Secrets which are not in the
+// referenced set of "<namespace>/<name>".
+func deleteUnreferencedRepositoryCredentialSecrets(ctx context.Context, kubeClient kubernetes.Interface,
+	referenced map[string]bool, logger logrus.FieldLogger) error {
+	secretList, err := kubeClient.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: constants.RepositoryCredentialLabel,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to list the repository credential Secrets")
+	}
+	for _, secret := range secretList.Items {
+		if referenced[secret.Namespace+"/"+secret.Name] || time.Since(secret.CreationTimestamp.Time) < repositoryCredentialGracePeriod {
+			continue
+		}
+		err := kubeClient.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "Failed to delete the repository credential Secret %s/%s", secret.Namespace, secret.Name)
+		}
+		logger.Infof("Deleted the unused repository credential Secret %s/%s", secret.Namespace, secret.Name)
+	}
+	return nil
+}
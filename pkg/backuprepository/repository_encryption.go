@@ -0,0 +1,224 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backuprepository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/encryption"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const repositoryEncryptionSecretPrefix = "vsphere-plugin-repository-encryption-"
+
+// retrieveRepositoryEncryption returns the encryption configured in the repository ConfigMap, or nil if the
+// snapshots are not to be encrypted. The Secret holding the keys is in the velero namespace.
+func retrieveRepositoryEncryption(veleroNs string, restConfig *rest.Config) (*backupdriverv1.RepositoryEncryption, error) {
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to retrieve the k8s clientset")
+	}
+	repositoryConfig, err := utils.RetrieveRepositoryConfig(kubeClient, veleroNs)
+	if err != nil {
+		return nil, err
+	}
+	secretName := repositoryConfig[constants.RepositoryConfigEncryptionSecretKey]
+	if secretName == "" {
+		return nil, nil
+	}
+	activeKeyID := repositoryConfig[constants.RepositoryConfigEncryptionKeyIDKey]
+	if activeKeyID == "" {
+		return nil, errors.Errorf("%s is required in the repository ConfigMap %s/%s when %s is set",
+			constants.RepositoryConfigEncryptionKeyIDKey, veleroNs, constants.RepositoryConfigMap, constants.RepositoryConfigEncryptionSecretKey)
+	}
+	return &backupdriverv1.RepositoryEncryption{
+		Name:        secretName,
+		Namespace:   veleroNs,
+		ActiveKeyID: activeKeyID,
+	}, nil
+}
+
+var (
+	// The clients of the cluster the plugin runs in, see getInClusterClients
+	inClusterClientsLock  sync.Mutex
+	inClusterKubeClient   kubernetes.Interface
+	inClusterPluginClient versioned.Interface
+)
+
+// getInClusterClients returns the clients of the cluster the plugin runs in. They are created by the first successful
+// call and reused afterwards, as the encryption of the repository is resolved for every data movement.
+func getInClusterClients() (kubernetes.Interface, versioned.Interface, error) {
+	inClusterClientsLock.Lock()
+	defer inClusterClientsLock.Unlock()
+	if inClusterKubeClient != nil {
+		return inClusterKubeClient, inClusterPluginClient, nil
+	}
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to get k8s inClusterConfig")
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to retrieve the k8s clientset")
+	}
+	pluginClient, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to retrieve the plugin clientset")
+	}
+	inClusterKubeClient, inClusterPluginClient = kubeClient, pluginClient
+	return inClusterKubeClient, inClusterPluginClient, nil
+}
+
+// resolveRepositoryEncryption returns the keyring of the encryption keys referenced by the BackupRepository.
+func resolveRepositoryEncryption(repositoryEncryption *backupdriverv1.RepositoryEncryption, logger logrus.FieldLogger) (*encryption.Keyring, error) {
+	kubeClient, _, err := getInClusterClients()
+	if err != nil {
+		return nil, err
+	}
+	return newKeyringFromSecret(kubeClient, repositoryEncryption, logger)
+}
+
+// recordUnencryptedSnapshots records the snapshots written to the repository before the encryption was enabled on the
+// BackupRepository, so that they can still be restored while any other snapshot which is not encrypted is refused. It
+// is done once per BackupRepository, which is annotated afterwards.
+func recordUnencryptedSnapshots(backupRepository *backupdriverv1.BackupRepository, petm *encryption.ProtectedEntityTypeManager,
+	pluginClient versioned.Interface, logger logrus.FieldLogger) error {
+	if _, ok := backupRepository.Annotations[constants.UnencryptedSnapshotsRecordedAnnotation]; ok {
+		return nil
+	}
+	ctx := context.TODO()
+	logger.Infof("Recording the snapshots which are not encrypted in the backup repository %s", backupRepository.Name)
+	if err := petm.RecordUnencryptedSnapshots(ctx); err != nil {
+		return errors.Wrapf(err, "Failed to record the snapshots which are not encrypted in the backup repository %s", backupRepository.Name)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				constants.UnencryptedSnapshotsRecordedAnnotation: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := pluginClient.BackupdriverV1alpha1().BackupRepositories().Patch(ctx, backupRepository.Name, types.MergePatchType, patch,
+		metav1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed to annotate the backup repository %s", backupRepository.Name)
+	}
+	return nil
+}
+
+// newKeyringFromSecret returns the keyring of the keys in the Secret. A missing Secret results in an empty keyring,
+// so that the snapshots which are not encrypted can still be restored, while the others fail with the missing key.
+func newKeyringFromSecret(kubeClient kubernetes.Interface, repositoryEncryption *backupdriverv1.RepositoryEncryption,
+	logger logrus.FieldLogger) (*encryption.Keyring, error) {
+	source := fmt.Sprintf("Secret %s/%s", repositoryEncryption.Namespace, repositoryEncryption.Name)
+	var keys map[string][]byte
+	secret, err := kubeClient.CoreV1().Secrets(repositoryEncryption.Namespace).Get(context.TODO(), repositoryEncryption.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "Failed to retrieve the repository encryption %s", source)
+		}
+		logger.Warnf("The repository encryption %s is not found", source)
+	} else {
+		keys = secret.Data
+	}
+	return encryption.NewKeyring(repositoryEncryption.ActiveKeyID, keys, source)
+}
+
+// createRepositoryEncryptionSecret stores the encryption keys in a Secret in the namespace and returns the reference
+// to it. The name of the Secret is derived from its content, so that the same keys are stored once.
+func createRepositoryEncryptionSecret(kubeClient kubernetes.Interface, ns string, keys map[string][]byte, activeKeyID string,
+	logger logrus.FieldLogger) (*backupdriverv1.RepositoryEncryption, error) {
+	keyIDs := make([]string, 0, len(keys))
+	for keyID := range keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	hash := sha256.New()
+	for _, keyID := range keyIDs {
+		hash.Write([]byte(keyID))
+		hash.Write([]byte{0})
+		hash.Write(keys[keyID])
+		hash.Write([]byte{0})
+	}
+	secretName := repositoryEncryptionSecretPrefix + hex.EncodeToString(hash.Sum(nil))[:16]
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: ns,
+			Labels:    utils.AppendVeleroExcludeLabels(nil),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: keys,
+	}
+	_, err := kubeClient.CoreV1().Secrets(ns).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, errors.Wrapf(err, "Failed to create the repository encryption Secret %s/%s", ns, secretName)
+		}
+		logger.Debugf("The repository encryption Secret %s/%s already exists", ns, secretName)
+	} else {
+		logger.Infof("Created the repository encryption Secret %s/%s", ns, secretName)
+	}
+	return &backupdriverv1.RepositoryEncryption{
+		Name:        secretName,
+		Namespace:   ns,
+		ActiveKeyID: activeKeyID,
+	}, nil
+}
+
+// copyRepositoryEncryptionToSvc copies the encryption keys referenced in the Guest Cluster to the Supervisor namespace.
+func copyRepositoryEncryptionToSvc(repositoryEncryption *backupdriverv1.RepositoryEncryption, svcConfig *rest.Config,
+	svcNamespace string, logger logrus.FieldLogger) (*backupdriverv1.RepositoryEncryption, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get k8s inClusterConfig")
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to retrieve the k8s clientset")
+	}
+	secret, err := kubeClient.CoreV1().Secrets(repositoryEncryption.Namespace).Get(context.TODO(), repositoryEncryption.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to retrieve the repository encryption Secret %s/%s",
+			repositoryEncryption.Namespace, repositoryEncryption.Name)
+	}
+	svcKubeClient, err := kubernetes.NewForConfig(svcConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to retrieve the supervisor k8s clientset")
+	}
+	return createRepositoryEncryptionSecret(svcKubeClient, svcNamespace, secret.Data, repositoryEncryption.ActiveKeyID, logger)
+}
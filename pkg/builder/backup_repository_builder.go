@@ -39,25 +39,42 @@ func (b *BackupRepositoryBuilder) AllowedNamespaces(allowedNamespaces []string)
 	return b
 }
 
-// RepositoryDriver sets the repository driver the backup repository. Currently only s3 is supported.
-func (b *BackupRepositoryBuilder) RepositoryDriver() *BackupRepositoryBuilder {
-	// TODO: Change this when other drivers are supported.
-	b.object.RepositoryDriver = constants.S3RepositoryDriver
+// RepositoryDriver sets the repository driver for the backup repository. Both s3 and filesystem are supported.
+func (b *BackupRepositoryBuilder) RepositoryDriver(repositoryDriver string) *BackupRepositoryBuilder {
+	b.object.RepositoryDriver = repositoryDriver
 	return b
 }
 
-// RepositoryParameters sets the parameters for the backup repository, these mostly include the credentials.
+// RepositoryParameters sets the parameters for the backup repository.
 func (b *BackupRepositoryBuilder) RepositoryParameters(repositoryParameters map[string]string) *BackupRepositoryBuilder {
 	b.object.RepositoryParameters = repositoryParameters
 	return b
 }
 
+// RepositoryCredential sets the reference to the Secret key holding the credentials of the backup repository.
+func (b *BackupRepositoryBuilder) RepositoryCredential(repositoryCredential *backupdriverv1.SecretKeyReference) *BackupRepositoryBuilder {
+	b.object.RepositoryCredential = repositoryCredential
+	return b
+}
+
+// RepositoryEncryption sets the reference to the Secret holding the encryption keys of the backup repository.
+func (b *BackupRepositoryBuilder) RepositoryEncryption(repositoryEncryption *backupdriverv1.RepositoryEncryption) *BackupRepositoryBuilder {
+	b.object.RepositoryEncryption = repositoryEncryption
+	return b
+}
+
 // BackupRepositoryClaim sets the name of the backup repository claim for this specific backup repository.
 func (b *BackupRepositoryBuilder) BackupRepositoryClaim(backupRepositoryClaimName string) *BackupRepositoryBuilder {
 	b.object.BackupRepositoryClaim = backupRepositoryClaimName
 	return b
 }
 
+// BackupRepositoryClaimNamespace records the namespace of the backup repository claim for this specific backup repository.
+func (b *BackupRepositoryBuilder) BackupRepositoryClaimNamespace(backupRepositoryClaimNamespace string) *BackupRepositoryBuilder {
+	b.object.Labels[constants.BackupRepositoryClaimNamespaceLabel] = backupRepositoryClaimNamespace
+	return b
+}
+
 // SvcBackupRepositoryName sets the name of the supervisor backup repository
 // corresponding to this specific backup repository, if it is not empty.
 // This is available only for guest clusters.
@@ -2,7 +2,6 @@ package builder
 
 import (
 	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
-	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -40,19 +39,30 @@ func (b *BackupRepositoryClaimBuilder) AllowedNamespaces(allowedNamespaces []str
 	return b
 }
 
-// RepositoryDriver sets the repository driver the backup repository claim. Currently only s3 is supported.
-func (b *BackupRepositoryClaimBuilder) RepositoryDriver() *BackupRepositoryClaimBuilder {
-	// TODO: Change this when other drivers are supported.
-	b.object.RepositoryDriver = constants.S3RepositoryDriver
+// RepositoryDriver sets the repository driver for the backup repository claim. Both s3 and filesystem are supported.
+func (b *BackupRepositoryClaimBuilder) RepositoryDriver(repositoryDriver string) *BackupRepositoryClaimBuilder {
+	b.object.RepositoryDriver = repositoryDriver
 	return b
 }
 
-// RepositoryParameters sets the parameters for the backup repository claim, these mostly include the credentials.
+// RepositoryParameters sets the parameters for the backup repository claim.
 func (b *BackupRepositoryClaimBuilder) RepositoryParameters(repositoryParameters map[string]string) *BackupRepositoryClaimBuilder {
 	b.object.RepositoryParameters = repositoryParameters
 	return b
 }
 
+// RepositoryCredential sets the reference to the Secret key holding the credentials of the backup repository claim.
+func (b *BackupRepositoryClaimBuilder) RepositoryCredential(repositoryCredential *backupdriverv1.SecretKeyReference) *BackupRepositoryClaimBuilder {
+	b.object.RepositoryCredential = repositoryCredential
+	return b
+}
+
+// RepositoryEncryption sets the reference to the Secret holding the encryption keys of the backup repository claim.
+func (b *BackupRepositoryClaimBuilder) RepositoryEncryption(repositoryEncryption *backupdriverv1.RepositoryEncryption) *BackupRepositoryClaimBuilder {
+	b.object.RepositoryEncryption = repositoryEncryption
+	return b
+}
+
 // BackupRepository sets the name of the backup repository for this specific backup repository claim.
 func (b *BackupRepositoryClaimBuilder) BackupRepository(backupRepositoryName string) *BackupRepositoryClaimBuilder {
 	b.object.BackupRepository = backupRepositoryName
@@ -126,3 +126,9 @@ func (b *UploadBuilder) SnapshotReference(snapshotRef string) *UploadBuilder {
 	b.object.Spec.SnapshotReference = snapshotRef
 	return b
 }
+
+// UploadRetry sets the request to retry a failed upload.
+func (b *UploadBuilder) UploadRetry(retry bool) *UploadBuilder {
+	b.object.Spec.UploadRetry = retry
+	return b
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package checksum implements the integrity verification of the snapshots stored in a backup repository.
+//
+// The length and the SHA-256 checksum of the data and of the metadata of a snapshot are computed while the snapshot
+// is written to the repository, and are stored in a sidecar snapshot next to it once it is written. The snapshot
+// itself is stored as is, so that it can still be read by the releases which predate the checksums. A stream read
+// back from the repository fails with a MismatchError at its end if it does not match its checksum.
+package checksum
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// noChecksumDetail is the detail of the MismatchError of a stream without checksums
+const noChecksumDetail = "the stream has no checksum"
+
+// MismatchError is returned when a stream read from the repository does not match its checksums, i.e. the snapshot
+// is corrupted in the repository.
+type MismatchError struct {
+	Detail string
+}
+
+func (this *MismatchError) Error() string {
+	return fmt.Sprintf("Checksum mismatch: %s", this.Detail)
+}
+
+// IsMismatch checks if the error is caused by a corrupted stream.
+func IsMismatch(err error) bool {
+	var mismatchErr *MismatchError
+	return errors.As(err, &mismatchErr)
+}
+
+// IsMissingChecksum checks if the error is caused by a stream without checksums read while the checksums are required,
+// i.e. a snapshot written before the checksums were introduced rather than a corrupted one.
+func IsMissingChecksum(err error) bool {
+	var mismatchErr *MismatchError
+	return errors.As(err, &mismatchErr) && mismatchErr.Detail == noChecksumDetail
+}
+
+type checksumRequiredKey struct{}
+
+// WithChecksumRequired returns a context in which the streams without checksums, which are otherwise read as is as
+// they were written before the checksums were introduced, fail to read with a MismatchError. It is used to verify the
+// snapshots which have just been written.
+func WithChecksumRequired(ctx context.Context) context.Context {
+	return context.WithValue(ctx, checksumRequiredKey{}, true)
+}
+
+func isChecksumRequired(ctx context.Context) bool {
+	required, _ := ctx.Value(checksumRequiredKey{}).(bool)
+	return required
+}
+
+// StreamChecksum is the checksum of a stream of a snapshot as stored in the repository.
+type StreamChecksum struct {
+	Length uint64 `json:"length"`
+	SHA256 string `json:"sha256"`
+}
+
+// summingReader computes the checksum of the stream read from the source.
+type summingReader struct {
+	source io.ReadCloser
+	sum    hash.Hash
+	length uint64
+	done   bool
+}
+
+func newSummingReader(source io.ReadCloser) *summingReader {
+	return &summingReader{
+		source: source,
+		sum:    sha256.New(),
+	}
+}
+
+func (this *summingReader) Read(p []byte) (int, error) {
+	n, err := this.source.Read(p)
+	this.sum.Write(p[:n])
+	this.length += uint64(n)
+	if err == io.EOF {
+		this.done = true
+	}
+	return n, err
+}
+
+func (this *summingReader) Close() error {
+	return this.source.Close()
+}
+
+// checksum returns the checksum of the stream, or false if the stream was not read to its end.
+func (this *summingReader) checksum() (StreamChecksum, bool) {
+	if !this.done {
+		return StreamChecksum{}, false
+	}
+	return StreamChecksum{
+		Length: this.length,
+		SHA256: hex.EncodeToString(this.sum.Sum(nil)),
+	}, true
+}
+
+// verifyReader verifies the stream read from the source against its checksum.
+type verifyReader struct {
+	summingReader
+	expected StreamChecksum
+}
+
+// NewVerifyReader returns a reader of the source stream, which fails with a MismatchError instead of reaching the end
+// of the stream if the stream does not match the checksum. A stream which is longer than expected fails as soon as
+// the extra data is read.
+func NewVerifyReader(source io.ReadCloser, expected StreamChecksum) io.ReadCloser {
+	return &verifyReader{
+		summingReader: *newSummingReader(source),
+		expected:      expected,
+	}
+}
+
+func (this *verifyReader) Read(p []byte) (int, error) {
+	n, err := this.summingReader.Read(p)
+	if this.length > this.expected.Length {
+		return 0, &MismatchError{Detail: fmt.Sprintf("read more than the expected %d bytes", this.expected.Length)}
+	}
+	if err != io.EOF {
+		return n, err
+	}
+	actual, _ := this.checksum()
+	if actual.Length != this.expected.Length {
+		return 0, &MismatchError{Detail: fmt.Sprintf("read %d bytes, expected %d bytes", actual.Length, this.expected.Length)}
+	}
+	if actual.SHA256 != this.expected.SHA256 {
+		return 0, &MismatchError{Detail: "the checksum of the stream does not match"}
+	}
+	return n, io.EOF
+}
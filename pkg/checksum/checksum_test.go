@@ -0,0 +1,214 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checksum
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/fsrepository"
+)
+
+func checksumOf(t *testing.T, payload []byte) StreamChecksum {
+	reader := newSummingReader(ioutil.NopCloser(bytes.NewReader(payload)))
+	_, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	checksum, ok := reader.checksum()
+	require.True(t, ok)
+	return checksum
+}
+
+func verify(stream []byte, expected StreamChecksum) ([]byte, error) {
+	reader := NewVerifyReader(ioutil.NopCloser(bytes.NewReader(stream)), expected)
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+func TestChecksumVerify(t *testing.T) {
+	for _, size := range []int{0, 1, 1024*1024 + 17} {
+		payload := make([]byte, size)
+		for i := range payload {
+			payload[i] = byte(i % 251)
+		}
+		verified, err := verify(payload, checksumOf(t, payload))
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, payload, verified, "size %d", size)
+	}
+
+	// A stream which is not read to its end has no checksum
+	reader := newSummingReader(ioutil.NopCloser(bytes.NewReader([]byte("data"))))
+	_, err := reader.Read(make([]byte, 2))
+	require.NoError(t, err)
+	_, ok := reader.checksum()
+	assert.False(t, ok)
+}
+
+func TestVerifyCorruptedStream(t *testing.T) {
+	payload := bytes.Repeat([]byte("data"), 1024)
+	expected := checksumOf(t, payload)
+
+	corrupt := func(corrupt func([]byte) []byte) error {
+		_, err := verify(corrupt(append([]byte(nil), payload...)), expected)
+		return err
+	}
+	for name, err := range map[string]error{
+		"flipped bit":   corrupt(func(s []byte) []byte { s[len(s)/2] ^= 1; return s }),
+		"truncated":     corrupt(func(s []byte) []byte { return s[:len(s)-1] }),
+		"trailing data": corrupt(func(s []byte) []byte { return append(s, 0) }),
+	} {
+		require.Error(t, err, name)
+		assert.True(t, IsMismatch(errors.Wrap(err, "Failed to restore")), "%s: %v", name, err)
+		assert.False(t, IsMissingChecksum(err), name)
+	}
+}
+
+type fakeProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	info     astrolabe.ProtectedEntityInfo
+	data     []byte
+	metadata []byte
+}
+
+func (this fakeProtectedEntity) GetID() astrolabe.ProtectedEntityID {
+	return this.info.GetID()
+}
+
+func (this fakeProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return this.info, nil
+}
+
+func (this fakeProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.data)), nil
+}
+
+func (this fakeProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.metadata)), nil
+}
+
+func TestVerifyRepository(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "checksum")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	fsPETM, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+	petm := NewProtectedEntityTypeManager(fsPETM, logrus.New())
+
+	peID, err := astrolabe.NewProtectedEntityIDFromString("ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:67469e1c-50a8-4f63-9a6a-ad8a2265197c")
+	require.NoError(t, err)
+	transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("fake", map[string]string{})}
+	sourcePE := fakeProtectedEntity{
+		info:     astrolabe.NewProtectedEntityInfo(peID, "fake-pe", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}),
+		data:     bytes.Repeat([]byte("data "), 100000),
+		metadata: []byte("metadata"),
+	}
+	repoPE, err := petm.Copy(ctx, sourcePE, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+	require.NoError(t, VerifyProtectedEntity(ctx, repoPE))
+
+	reader, err := repoPE.GetDataReader(ctx)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, sourcePE.data, data)
+
+	// A snapshot corrupted in the repository fails the verification
+	dataFile := filepath.Join(repoDir, "plugins/vsphere-astrolabe-repo", "ivd", "data", peID.String()+".data")
+	stored, err := ioutil.ReadFile(dataFile)
+	require.NoError(t, err)
+	stored[len(stored)/2] ^= 1
+	require.NoError(t, ioutil.WriteFile(dataFile, stored, 0644))
+	err = VerifyProtectedEntity(ctx, repoPE)
+	require.Error(t, err)
+	assert.True(t, IsMismatch(err))
+	assert.False(t, IsMissingChecksum(err))
+	assert.Contains(t, err.Error(), peID.String())
+
+	// A snapshot written without checksums is restored, but fails the verification
+	rawID, err := astrolabe.NewProtectedEntityIDFromString("ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:7d1f6cd2-5e4b-4bd1-a6a4-96a6b8d0cd5a")
+	require.NoError(t, err)
+	sourcePE.info = astrolabe.NewProtectedEntityInfo(rawID, "fake-pe", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{})
+	_, err = fsPETM.Copy(ctx, sourcePE, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+	repoPE, err = petm.GetProtectedEntity(ctx, rawID)
+	require.NoError(t, err)
+	reader, err = repoPE.GetDataReader(ctx)
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, sourcePE.data, data)
+	assert.True(t, IsMissingChecksum(VerifyProtectedEntity(ctx, repoPE)))
+}
+
+func TestSidecar(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "checksum")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	fsPETM, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+	petm := NewProtectedEntityTypeManager(fsPETM, logrus.New())
+
+	peID, err := astrolabe.NewProtectedEntityIDFromString("ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:67469e1c-50a8-4f63-9a6a-ad8a2265197c")
+	require.NoError(t, err)
+	transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("fake", map[string]string{})}
+	sourcePE := fakeProtectedEntity{
+		info:     astrolabe.NewProtectedEntityInfo(peID, "fake-pe", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}),
+		data:     []byte("data"),
+		metadata: []byte("metadata"),
+	}
+	repoPE, err := petm.Copy(ctx, sourcePE, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+
+	// The snapshot is stored as is, so that the releases without checksums can read it
+	stored, err := ioutil.ReadFile(filepath.Join(repoDir, "plugins/vsphere-astrolabe-repo", "ivd", "data", peID.String()+".data"))
+	require.NoError(t, err)
+	assert.Equal(t, sourcePE.data, stored)
+
+	// The sidecar is stored next to the snapshot, but is not listed
+	rawIDs, err := fsPETM.GetProtectedEntities(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []astrolabe.ProtectedEntityID{peID, sidecarID(peID)}, rawIDs)
+	peIDs, err := petm.GetProtectedEntities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []astrolabe.ProtectedEntityID{peID}, peIDs)
+	peIDs, err = petm.GetProtectedEntitiesByIDPrefix(ctx, "ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:")
+	require.NoError(t, err)
+	assert.Equal(t, []astrolabe.ProtectedEntityID{peID}, peIDs)
+	snapshotIDs, err := repoPE.ListSnapshots(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []astrolabe.ProtectedEntitySnapshotID{peID.GetSnapshotID()}, snapshotIDs)
+
+	// The sidecar is deleted along with the snapshot
+	_, err = repoPE.DeleteSnapshot(ctx, peID.GetSnapshotID(), make(map[string]map[string]interface{}))
+	require.NoError(t, err)
+	rawIDs, err = fsPETM.GetProtectedEntities(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rawIDs)
+}
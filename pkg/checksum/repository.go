@@ -0,0 +1,340 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checksum
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/decorator"
+)
+
+// sidecarPrefix prefixes the snapshot ID of the sidecar snapshot which holds the checksums of a snapshot
+const sidecarPrefix = "checksum-"
+
+// snapshotChecksums are the checksums of the streams of a snapshot, stored as the metadata of its sidecar snapshot.
+// The streams which the snapshot does not have have no checksum.
+type snapshotChecksums struct {
+	Data     *StreamChecksum `json:"data,omitempty"`
+	Metadata *StreamChecksum `json:"metadata,omitempty"`
+}
+
+// ProtectedEntityTypeManager stores the checksums of the data and metadata of the snapshots copied to the repository
+// it wraps in sidecar snapshots, and verifies the snapshots against them when they are read back. It is meant to wrap
+// the repository driver directly, so that the checksums cover the bytes as stored in the repository. The sidecar
+// snapshots are not listed, and are deleted along with their snapshots. The snapshots without checksums are read as
+// is.
+type ProtectedEntityTypeManager struct {
+	decorator.ProtectedEntityTypeManager
+	logger logrus.FieldLogger
+}
+
+func NewProtectedEntityTypeManager(petm astrolabe.ProtectedEntityTypeManager, logger logrus.FieldLogger) *ProtectedEntityTypeManager {
+	checksumPETM := &ProtectedEntityTypeManager{
+		logger: logger,
+	}
+	checksumPETM.ProtectedEntityTypeManager = decorator.NewProtectedEntityTypeManager(petm, checksumPETM.newVerifyingProtectedEntity)
+	return checksumPETM
+}
+
+func (this *ProtectedEntityTypeManager) Copy(ctx context.Context, pe astrolabe.ProtectedEntity, params map[string]map[string]interface{},
+	options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntity, error) {
+	// The checksums of an earlier copy of the snapshot no longer apply once the snapshot is overwritten
+	if err := this.deleteSidecar(ctx, pe.GetID()); err != nil {
+		return nil, err
+	}
+	source := &checksummingProtectedEntity{
+		ProtectedEntity: pe,
+	}
+	repoPE, err := this.ProtectedEntityTypeManager.ProtectedEntityTypeManager.Copy(ctx, source, params, options)
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := source.checksums()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to compute the checksums of the snapshot %s", repoPE.GetID().String())
+	}
+	this.logger.Debugf("Storing the checksums of the snapshot %s", repoPE.GetID().String())
+	if _, err := this.ProtectedEntityTypeManager.ProtectedEntityTypeManager.Copy(ctx, newSidecar(repoPE.GetID(), checksums),
+		make(map[string]map[string]interface{}), astrolabe.AllocateNewObject); err != nil {
+		return nil, errors.Wrapf(err, "Failed to store the checksums of the snapshot %s", repoPE.GetID().String())
+	}
+	return this.newVerifyingProtectedEntity(repoPE), nil
+}
+
+func (this *ProtectedEntityTypeManager) GetProtectedEntities(ctx context.Context) ([]astrolabe.ProtectedEntityID, error) {
+	peIDs, err := this.ProtectedEntityTypeManager.GetProtectedEntities(ctx)
+	return withoutSidecars(peIDs), err
+}
+
+func (this *ProtectedEntityTypeManager) GetProtectedEntitiesByIDPrefix(ctx context.Context, idPrefix string) ([]astrolabe.ProtectedEntityID, error) {
+	peIDs, err := this.ProtectedEntityTypeManager.GetProtectedEntitiesByIDPrefix(ctx, idPrefix)
+	return withoutSidecars(peIDs), err
+}
+
+// getChecksums returns the checksums of the snapshot, or false if the snapshot was written without checksums.
+func (this *ProtectedEntityTypeManager) getChecksums(ctx context.Context, id astrolabe.ProtectedEntityID) (snapshotChecksums, bool, error) {
+	sidecarPE, err := this.ProtectedEntityTypeManager.ProtectedEntityTypeManager.GetProtectedEntity(ctx, sidecarID(id))
+	if err != nil {
+		return snapshotChecksums{}, false, nil
+	}
+	reader, err := sidecarPE.GetMetadataReader(ctx)
+	if err != nil {
+		return snapshotChecksums{}, false, errors.Wrapf(err, "Failed to read the checksums of the snapshot %s", id.String())
+	}
+	defer reader.Close()
+	var checksums snapshotChecksums
+	if err := json.NewDecoder(reader).Decode(&checksums); err != nil {
+		return snapshotChecksums{}, false, &MismatchError{Detail: "the checksums of the snapshot are corrupted: " + err.Error()}
+	}
+	return checksums, true, nil
+}
+
+func (this *ProtectedEntityTypeManager) deleteSidecar(ctx context.Context, id astrolabe.ProtectedEntityID) error {
+	sidecar := sidecarID(id)
+	sidecarPE, err := this.ProtectedEntityTypeManager.ProtectedEntityTypeManager.GetProtectedEntity(ctx, sidecar)
+	if err != nil {
+		return nil
+	}
+	if _, err := sidecarPE.DeleteSnapshot(ctx, sidecar.GetSnapshotID(), make(map[string]map[string]interface{})); err != nil {
+		return errors.Wrapf(err, "Failed to delete the checksums of the snapshot %s", id.String())
+	}
+	return nil
+}
+
+func sidecarID(id astrolabe.ProtectedEntityID) astrolabe.ProtectedEntityID {
+	return id.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID(sidecarPrefix + id.GetSnapshotID().GetID()))
+}
+
+func isSidecarID(id astrolabe.ProtectedEntityID) bool {
+	return id.HasSnapshot() && strings.HasPrefix(id.GetSnapshotID().GetID(), sidecarPrefix)
+}
+
+func withoutSidecars(peIDs []astrolabe.ProtectedEntityID) []astrolabe.ProtectedEntityID {
+	var filtered []astrolabe.ProtectedEntityID
+	for _, peID := range peIDs {
+		if !isSidecarID(peID) {
+			filtered = append(filtered, peID)
+		}
+	}
+	return filtered
+}
+
+// sidecar is the source of the sidecar snapshot of a snapshot, whose metadata are the checksums of the snapshot.
+type sidecar struct {
+	astrolabe.ProtectedEntity
+	snapshotID astrolabe.ProtectedEntityID
+	checksums  snapshotChecksums
+}
+
+func newSidecar(snapshotID astrolabe.ProtectedEntityID, checksums snapshotChecksums) astrolabe.ProtectedEntity {
+	return sidecar{
+		snapshotID: snapshotID,
+		checksums:  checksums,
+	}
+}
+
+func (this sidecar) GetID() astrolabe.ProtectedEntityID {
+	return sidecarID(this.snapshotID)
+}
+
+func (this sidecar) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("checksum", map[string]string{})}
+	return astrolabe.NewProtectedEntityInfo(this.GetID(), this.GetID().String(), []astrolabe.DataTransport{}, transports,
+		[]astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}), nil
+}
+
+func (this sidecar) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+func (this sidecar) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	buf, err := json.Marshal(this.checksums)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(string(buf))), nil
+}
+
+// checksummingProtectedEntity is the source of a copy to the repository, which computes the checksums of the streams
+// while they are copied.
+type checksummingProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	data     *summingReader
+	metadata *summingReader
+}
+
+func (this *checksummingProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	reader, err := this.ProtectedEntity.GetDataReader(ctx)
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	this.data = newSummingReader(reader)
+	return this.data, nil
+}
+
+func (this *checksummingProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	reader, err := this.ProtectedEntity.GetMetadataReader(ctx)
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	this.metadata = newSummingReader(reader)
+	return this.metadata, nil
+}
+
+// checksums returns the checksums of the streams which have been copied. The copy is expected to read the streams to
+// their ends.
+func (this *checksummingProtectedEntity) checksums() (snapshotChecksums, error) {
+	var checksums snapshotChecksums
+	for _, stream := range []struct {
+		name     string
+		reader   *summingReader
+		checksum **StreamChecksum
+	}{
+		{name: "data", reader: this.data, checksum: &checksums.Data},
+		{name: "metadata", reader: this.metadata, checksum: &checksums.Metadata},
+	} {
+		if stream.reader == nil {
+			continue
+		}
+		checksum, ok := stream.reader.checksum()
+		if !ok {
+			return snapshotChecksums{}, errors.Errorf("The %s was not copied to its end", stream.name)
+		}
+		*stream.checksum = &checksum
+	}
+	return checksums, nil
+}
+
+// verifyingProtectedEntity is a snapshot in the repository.
+type verifyingProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	petm *ProtectedEntityTypeManager
+}
+
+func (this *ProtectedEntityTypeManager) newVerifyingProtectedEntity(pe astrolabe.ProtectedEntity) astrolabe.ProtectedEntity {
+	return verifyingProtectedEntity{
+		ProtectedEntity: pe,
+		petm:            this,
+	}
+}
+
+func (this verifyingProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	reader, err := this.ProtectedEntity.GetDataReader(ctx)
+	return this.verify(ctx, "data", reader, err)
+}
+
+func (this verifyingProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	reader, err := this.ProtectedEntity.GetMetadataReader(ctx)
+	return this.verify(ctx, "metadata", reader, err)
+}
+
+func (this verifyingProtectedEntity) ListSnapshots(ctx context.Context) ([]astrolabe.ProtectedEntitySnapshotID, error) {
+	snapshotIDs, err := this.ProtectedEntity.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []astrolabe.ProtectedEntitySnapshotID
+	for _, snapshotID := range snapshotIDs {
+		if !strings.HasPrefix(snapshotID.GetID(), sidecarPrefix) {
+			filtered = append(filtered, snapshotID)
+		}
+	}
+	return filtered, nil
+}
+
+func (this verifyingProtectedEntity) DeleteSnapshot(ctx context.Context, snapshotToDelete astrolabe.ProtectedEntitySnapshotID,
+	params map[string]map[string]interface{}) (bool, error) {
+	deleted, err := this.ProtectedEntity.DeleteSnapshot(ctx, snapshotToDelete, params)
+	if err != nil {
+		return deleted, err
+	}
+	// The sidecar is deleted after the snapshot, so that a snapshot is never left without its checksums
+	if err := this.petm.deleteSidecar(ctx, this.GetID().IDWithSnapshot(snapshotToDelete)); err != nil {
+		this.petm.logger.WithError(err).Warnf("Failed to delete the checksums of the deleted snapshot %s", this.GetID().String())
+	}
+	return deleted, nil
+}
+
+func (this verifyingProtectedEntity) verify(ctx context.Context, stream string, reader io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	checksums, ok, err := this.petm.getChecksums(ctx, this.GetID())
+	if err != nil {
+		reader.Close()
+		return nil, errors.Wrapf(err, "Failed to verify the %s of the snapshot %s", stream, this.GetID().String())
+	}
+	checksum := checksums.Data
+	if stream == "metadata" {
+		checksum = checksums.Metadata
+	}
+	if !ok || checksum == nil {
+		if isChecksumRequired(ctx) {
+			reader.Close()
+			return nil, errors.Wrapf(&MismatchError{Detail: noChecksumDetail}, "Failed to verify the %s of the snapshot %s", stream, this.GetID().String())
+		}
+		return reader, nil
+	}
+	return &snapshotVerifyReader{
+		ReadCloser: NewVerifyReader(reader, *checksum),
+		stream:     stream,
+		peID:       this.GetID(),
+	}, nil
+}
+
+// snapshotVerifyReader adds the snapshot to the checksum mismatches, which are only detected while the stream is read.
+type snapshotVerifyReader struct {
+	io.ReadCloser
+	stream string
+	peID   astrolabe.ProtectedEntityID
+}
+
+func (this *snapshotVerifyReader) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	if err != nil && IsMismatch(err) {
+		err = errors.Wrapf(err, "The %s of the snapshot %s is corrupted in the repository", this.stream, this.peID.String())
+	}
+	return n, err
+}
+
+// VerifyProtectedEntity reads the data and metadata of the snapshot back from the repository, and fails with a
+// MismatchError unless they match the checksums computed while they were written.
+func VerifyProtectedEntity(ctx context.Context, pe astrolabe.ProtectedEntity) error {
+	ctx = WithChecksumRequired(ctx)
+	for _, getReader := range []func(context.Context) (io.ReadCloser, error){pe.GetMetadataReader, pe.GetDataReader} {
+		reader, err := getReader(ctx)
+		if err != nil {
+			return err
+		}
+		if reader == nil {
+			continue
+		}
+		_, err = io.Copy(ioutil.Discard, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -36,6 +36,7 @@ import (
 
 	"io/ioutil"
 	"os"
+	"time"
 
 	kubeutil "github.com/vmware-tanzu/velero/pkg/util/kube"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -52,6 +53,12 @@ type InstallOptions struct {
 	PodMemLimit    string
 	MasterAffinity bool
 	HostNetwork    bool
+	// The repository config is retrieved from the cluster instead of flags
+	RepositoryConfig map[string]string
+	// The repository garbage collection is disabled unless its period is set
+	RepositoryGCPeriod      time.Duration
+	RepositoryGCGracePeriod time.Duration
+	RepositoryGCDelete      bool
 }
 
 func (o *InstallOptions) BindFlags(flags *pflag.FlagSet) {
@@ -61,17 +68,23 @@ func (o *InstallOptions) BindFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&o.PodMemRequest, "pod-mem-request", o.PodMemRequest, `memory request for backup-driver pod. A value of "0" is treated as unbounded. Optional.`)
 	flags.StringVar(&o.PodCPULimit, "pod-cpu-limit", o.PodCPULimit, `CPU limit for backup-driver pod. A value of "0" is treated as unbounded. Optional.`)
 	flags.StringVar(&o.PodMemLimit, "pod-mem-limit", o.PodMemLimit, `memory limit for backup-driver pod. A value of "0" is treated as unbounded. Optional.`)
+	flags.DurationVar(&o.RepositoryGCPeriod, "repository-gc-period", o.RepositoryGCPeriod, "how often to look for the snapshots in the backup repositories which are not referenced by any backup. The repository garbage collection is disabled if 0. Optional.")
+	flags.DurationVar(&o.RepositoryGCGracePeriod, "repository-gc-grace-period", o.RepositoryGCGracePeriod, "how long a snapshot has to stay unreferenced before the repository garbage collection deletes it. Optional.")
+	flags.BoolVar(&o.RepositoryGCDelete, "repository-gc-delete", o.RepositoryGCDelete, "delete the unreferenced snapshots from the backup repositories, instead of only reporting them. Optional.")
 }
 
 func NewInstallOptions() *InstallOptions {
 	return &InstallOptions{
-		Namespace:      "velero",
-		Image:          pkgInstall.DefaultBackupDriverImage,
-		PodAnnotations: flag.NewMap(),
-		PodCPURequest:  pkgInstall.DefaultBackupDriverPodCPURequest,
-		PodMemRequest:  pkgInstall.DefaultBackupDriverPodMemRequest,
-		PodCPULimit:    pkgInstall.DefaultBackupDriverPodCPULimit,
-		PodMemLimit:    pkgInstall.DefaultBackupDriverPodMemLimit,
+		Namespace:               "velero",
+		Image:                   pkgInstall.DefaultBackupDriverImage,
+		PodAnnotations:          flag.NewMap(),
+		PodCPURequest:           pkgInstall.DefaultBackupDriverPodCPURequest,
+		PodMemRequest:           pkgInstall.DefaultBackupDriverPodMemRequest,
+		PodCPULimit:             pkgInstall.DefaultBackupDriverPodCPULimit,
+		PodMemLimit:             pkgInstall.DefaultBackupDriverPodMemLimit,
+		RepositoryGCPeriod:      cmd.DefaultRepositoryGCPeriod,
+		RepositoryGCGracePeriod: cmd.DefaultRepositoryGCGracePeriod,
+		RepositoryGCDelete:      cmd.DefaultRepositoryGCDelete,
 	}
 }
 
@@ -83,12 +96,16 @@ func (o *InstallOptions) AsBackupDriverOptions() (*pkgInstall.PodOptions, error)
 	}
 
 	return &pkgInstall.PodOptions{
-		Namespace:      o.Namespace,
-		Image:          o.Image,
-		PodAnnotations: o.PodAnnotations.Data(),
-		PodResources:   podResources,
-		MasterAffinity: o.MasterAffinity,
-		HostNetwork:    o.HostNetwork,
+		Namespace:               o.Namespace,
+		Image:                   o.Image,
+		PodAnnotations:          o.PodAnnotations.Data(),
+		PodResources:            podResources,
+		MasterAffinity:          o.MasterAffinity,
+		HostNetwork:             o.HostNetwork,
+		RepositoryConfig:        o.RepositoryConfig,
+		RepositoryGCPeriod:      o.RepositoryGCPeriod,
+		RepositoryGCGracePeriod: o.RepositoryGCGracePeriod,
+		RepositoryGCDelete:      o.RepositoryGCDelete,
 	}, nil
 }
 
@@ -141,6 +158,9 @@ func (o *InstallOptions) Run(c *cobra.Command, f client.Factory) error {
 		return err
 	}
 
+	// Check the repository config for backup-driver
+	o.RepositoryConfig, _ = cmd.CheckRepositoryConfig(kubeClient, o.Namespace)
+
 	fmt.Println("The prerequisite checks for backup-driver completed")
 
 	vo, err := o.AsBackupDriverOptions()
@@ -184,7 +204,7 @@ func (o *InstallOptions) Run(c *cobra.Command, f client.Factory) error {
 	return nil
 }
 
-//Complete completes options for a command.
+// Complete completes options for a command.
 func (o *InstallOptions) Complete(args []string, f client.Factory) error {
 	fileName := "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 
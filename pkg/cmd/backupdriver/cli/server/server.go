@@ -38,18 +38,21 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backupdriver"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backuprepository"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/cmd"
 	plugin_clientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned"
 	backupdriver_clientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/backupdriver/v1alpha1"
 	datamover_clientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/datamover/v1alpha1"
 	pluginInformers "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/informers/externalversions"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/metrics"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/snapshotmgr"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	"github.com/vmware-tanzu/velero/pkg/client"
 	"github.com/vmware-tanzu/velero/pkg/cmd/util/signals"
-	"github.com/vmware-tanzu/velero/pkg/metrics"
+	velero_clientset "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned"
 	"github.com/vmware-tanzu/velero/pkg/util/logging"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -67,23 +70,34 @@ type serverConfig struct {
 	workers            int
 	retryIntervalStart time.Duration
 	retryIntervalMax   time.Duration
+	repositoryGCPeriod time.Duration
+	// How long a snapshot has to stay orphaned before the repository garbage collection deletes it
+	repositoryGCGracePeriod time.Duration
+	// The orphaned snapshots are only reported unless repositoryGCDelete is set
+	repositoryGCDelete bool
+	// How often the uploads of the snapshots of the completed backups are checked
+	backupUploadSyncPeriod time.Duration
 }
 
 func NewCommand(f client.Factory) *cobra.Command {
 	var (
 		logLevelFlag = logging.LogLevelFlag(logrus.InfoLevel)
 		config       = serverConfig{
-			metricsAddress:     cmd.DefaultMetricsAddress,
-			clientQPS:          cmd.DefaultClientQPS,
-			clientBurst:        cmd.DefaultClientBurst,
-			profilerAddress:    cmd.DefaultProfilerAddress,
-			formatFlag:         logging.NewFormatFlag(),
-			master:             "",
-			kubeConfig:         "",
-			resyncPeriod:       constants.ResyncPeriod,
-			workers:            cmd.DefaultBackupWorkers,
-			retryIntervalStart: constants.DefaultRetryIntervalStart,
-			retryIntervalMax:   constants.DefaultRetryIntervalMax,
+			metricsAddress:          cmd.DefaultMetricsAddress,
+			clientQPS:               cmd.DefaultClientQPS,
+			clientBurst:             cmd.DefaultClientBurst,
+			profilerAddress:         cmd.DefaultProfilerAddress,
+			formatFlag:              logging.NewFormatFlag(),
+			master:                  "",
+			kubeConfig:              "",
+			resyncPeriod:            constants.ResyncPeriod,
+			workers:                 cmd.DefaultBackupWorkers,
+			retryIntervalStart:      constants.DefaultRetryIntervalStart,
+			retryIntervalMax:        constants.DefaultRetryIntervalMax,
+			repositoryGCPeriod:      cmd.DefaultRepositoryGCPeriod,
+			repositoryGCGracePeriod: cmd.DefaultRepositoryGCGracePeriod,
+			repositoryGCDelete:      cmd.DefaultRepositoryGCDelete,
+			backupUploadSyncPeriod:  cmd.DefaultBackupUploadSyncPeriod,
 		}
 	)
 
@@ -137,6 +151,10 @@ func NewCommand(f client.Factory) *cobra.Command {
 	command.Flags().IntVar(&config.workers, "backup-workers", config.workers, "Concurrency to process multiple backup requests")
 	command.Flags().DurationVar(&config.retryIntervalStart, "backup-retry-int-start", config.retryIntervalStart, "Initial retry interval of failed backup request. It exponentially increases with each failure, up to retry-interval-max.")
 	command.Flags().DurationVar(&config.retryIntervalMax, "backup-retry-int-max", config.retryIntervalMax, "Maximum retry interval of failed backup request.")
+	command.Flags().DurationVar(&config.repositoryGCPeriod, "repository-gc-period", config.repositoryGCPeriod, "How often to look for the snapshots in the backup repositories which are not referenced by any backup. The repository garbage collection is disabled if 0.")
+	command.Flags().DurationVar(&config.repositoryGCGracePeriod, "repository-gc-grace-period", config.repositoryGCGracePeriod, "How long a snapshot has to stay unreferenced before the repository garbage collection deletes it.")
+	command.Flags().BoolVar(&config.repositoryGCDelete, "repository-gc-delete", config.repositoryGCDelete, "Delete the unreferenced snapshots from the backup repositories claimed in the Velero namespace. They are only reported in events on the BackupRepositories if not set. Do not set it if other clusters store their snapshots in the same repositories.")
+	command.Flags().DurationVar(&config.backupUploadSyncPeriod, "backup-upload-sync-period", config.backupUploadSyncPeriod, "How often to check the uploads of the snapshots of the completed backups. The backups whose uploads fail are marked as PartiallyFailed. The tracking of the uploads is disabled if 0.")
 
 	return command
 }
@@ -147,12 +165,13 @@ type server struct {
 	kubeClient                     kubernetes.Interface
 	backupdriverClient             *backupdriver_clientset.BackupdriverV1alpha1Client
 	datamoverClient                *datamover_clientset.DatamoverV1alpha1Client
+	veleroClient                   velero_clientset.Interface
 	svcBackupdriverClient          *backupdriver_clientset.BackupdriverV1alpha1Client
 	svcConfig                      *rest.Config
 	svcNamespace                   string
 	pluginInformerFactory          pluginInformers.SharedInformerFactory
 	kubeInformerFactory            kubeinformers.SharedInformerFactory
-	svcKubeInformerFactory         kubeinformers.SharedInformerFactory
+	secretInformerFactory          kubeinformers.SharedInformerFactory
 	svcBackupdriverInformerFactory pluginInformers.SharedInformerFactory
 	ctx                            context.Context
 	cancelFunc                     context.CancelFunc
@@ -180,6 +199,16 @@ func (s *server) run() error {
 		return err
 	}
 
+	// Move the plaintext credentials of the BackupRepositories and BackupRepositoryClaims created by
+	// previous releases into Secrets. Failing to do so should not prevent the backup-driver from running.
+	if err := backuprepository.MigrateRepositoryCredentials(s.ctx, s.namespace, s.kubeClient, s.backupdriverClient, s.logger); err != nil {
+		s.logger.WithError(err).Error("Failed to migrate the repository credentials")
+	}
+	// The Secrets are also left behind when the claims are deleted while the backup-driver is down
+	if err := backuprepository.DeleteUnusedRepositoryCredentialSecrets(s.ctx, s.kubeClient, s.backupdriverClient, s.logger); err != nil {
+		s.logger.WithError(err).Error("Failed to delete the unused repository credential Secrets")
+	}
+
 	if err := s.runControllers(); err != nil {
 		return err
 	}
@@ -224,9 +253,16 @@ func newServer(f client.Factory, config serverConfig, logger *logrus.Logger) (*s
 		return nil, err
 	}
 
+	veleroClient, err := velero_clientset.NewForConfig(clientConfig)
+	if err != nil {
+		logger.Errorf("Failed to get the velero client for the current kubernetes cluster")
+		return nil, err
+	}
+
 	// backup driver watches all namespaces so do not specify any one
 	backupdriverInformerFactory := pluginInformers.NewSharedInformerFactoryWithOptions(pluginClient, config.resyncPeriod)
 	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, config.resyncPeriod)
+	secretInformerFactory := kubeInformerFactory
 
 	// Set the ProtectedEntity configuration
 	pvcConfig := make(map[string]interface{})
@@ -241,7 +277,6 @@ func newServer(f client.Factory, config serverConfig, logger *logrus.Logger) (*s
 	clusterFlavor, _ := utils.GetClusterFlavor(clientConfig)
 	var svcConfig *rest.Config
 	var svcBackupdriverClient *backupdriver_clientset.BackupdriverV1alpha1Client
-	var svcKubeInformerFactory kubeinformers.SharedInformerFactory
 	var svcBackupdriverInformerFactory pluginInformers.SharedInformerFactory
 	var svcNamespace string
 	if clusterFlavor == constants.TkgGuest {
@@ -267,13 +302,12 @@ func newServer(f client.Factory, config serverConfig, logger *logrus.Logger) (*s
 		svcBackupdriverInformerFactory = pluginInformers.NewSharedInformerFactoryWithOptions(svcPluginClient, config.resyncPeriod,
 			pluginInformers.WithNamespace(svcNamespace))
 
-		svcKubeClient, err := kubernetes.NewForConfig(svcConfig)
-		if err != nil {
-			logger.Error("Failed to get the kubernetes client for the supervisor cluster")
-			return nil, err
-		}
-		svcKubeInformerFactory = kubeinformers.NewSharedInformerFactoryWithOptions(svcKubeClient, config.resyncPeriod,
-			kubeinformers.WithNamespace(svcNamespace))
+		// The only Secret watched in the guest is the para virt backup driver Secret
+		secretInformerFactory = kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, config.resyncPeriod,
+			kubeinformers.WithNamespace(constants.BackupDriverNamespace),
+			kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("metadata.name", constants.PvSecretName).String()
+			}))
 
 		// Set snapshot manager params
 		pvcConfig["svcConfig"] = svcConfig
@@ -312,12 +346,13 @@ func newServer(f client.Factory, config serverConfig, logger *logrus.Logger) (*s
 		kubeClient:                     kubeClient,
 		backupdriverClient:             backupdriverClient,
 		datamoverClient:                datamoverClient,
+		veleroClient:                   veleroClient,
 		svcBackupdriverClient:          svcBackupdriverClient,
 		svcConfig:                      svcConfig,
 		svcNamespace:                   svcNamespace,
 		pluginInformerFactory:          backupdriverInformerFactory,
 		kubeInformerFactory:            kubeInformerFactory,
-		svcKubeInformerFactory:         svcKubeInformerFactory,
+		secretInformerFactory:          secretInformerFactory,
 		svcBackupdriverInformerFactory: svcBackupdriverInformerFactory,
 		logger:                         logger,
 		logLevel:                       logger.Level,
@@ -357,6 +392,11 @@ func (s *server) runControllers() error {
 	s.logger.Info("Starting backup-driver controllers")
 
 	ctx := s.ctx
+
+	metrics.StartMetricsServer(s.metricsAddress, s.logger)
+	s.metrics = metrics.NewServerMetrics()
+	s.metrics.RegisterAllMetrics()
+
 	var wg sync.WaitGroup
 	// Register controllers
 	s.logger.Info("Registering controllers")
@@ -364,6 +404,7 @@ func (s *server) runControllers() error {
 	backupDriverController := backupdriver.NewBackupDriverController(
 		"BackupDriverController",
 		s.logger,
+		s.kubeClient,
 		s.backupdriverClient,
 		s.datamoverClient,
 		s.svcBackupdriverClient,
@@ -371,11 +412,12 @@ func (s *server) runControllers() error {
 		s.svcNamespace,
 		s.config.resyncPeriod,
 		s.kubeInformerFactory,
+		s.secretInformerFactory,
 		s.pluginInformerFactory,
-		s.svcKubeInformerFactory,
 		s.svcBackupdriverInformerFactory,
 		s.snapManager,
-		workqueue.NewItemExponentialFailureRateLimiter(s.config.retryIntervalStart, s.config.retryIntervalMax))
+		workqueue.NewItemExponentialFailureRateLimiter(s.config.retryIntervalStart, s.config.retryIntervalMax),
+		s.metrics)
 
 	wg.Add(1)
 	go func() {
@@ -383,13 +425,51 @@ func (s *server) runControllers() error {
 		backupDriverController.Run(s.ctx, s.config.workers)
 	}()
 
+	// The snapshots of the Guest Clusters are stored in the repositories of the Supervisor Cluster
+	if s.config.repositoryGCPeriod > 0 && s.svcConfig == nil {
+		repositoryGCController := backupdriver.NewRepositoryGCController(
+			"RepositoryGCController",
+			s.logger,
+			s.namespace,
+			s.kubeClient,
+			s.backupdriverClient,
+			s.veleroClient,
+			s.snapManager,
+			s.config.repositoryGCPeriod,
+			s.config.repositoryGCGracePeriod,
+			s.config.repositoryGCDelete)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repositoryGCController.Run(s.ctx)
+		}()
+	}
+
+	if s.config.backupUploadSyncPeriod > 0 {
+		backupUploadController := backupdriver.NewBackupUploadController(
+			"BackupUploadController",
+			s.logger,
+			s.namespace,
+			s.kubeClient,
+			s.backupdriverClient,
+			s.veleroClient,
+			s.config.backupUploadSyncPeriod)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			backupUploadController.Run(s.ctx)
+		}()
+	}
+
 	// SHARED INFORMERS HAVE TO BE STARTED AFTER ALL CONTROLLERS
 	go s.pluginInformerFactory.Start(ctx.Done())
 	go s.kubeInformerFactory.Start(ctx.Done())
-	if s.svcConfig != nil {
-		go s.svcKubeInformerFactory.Start(ctx.Done())
-		go s.svcBackupdriverInformerFactory.Start(ctx.Done())
-	}
+	// The secret informer factory is the kube informer factory outside of the guest, starting it again is a no-op
+	go s.secretInformerFactory.Start(ctx.Done())
+	// The supervisor backupdriver informers are started by the backup driver controller, which restarts them when
+	// the supervisor credentials are rotated
 
 	s.logger.Info("Server started successfully")
 
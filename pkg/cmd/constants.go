@@ -16,6 +16,12 @@ limitations under the License.
 
 package cmd
 
+import (
+	"time"
+
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+)
+
 const (
 	// the port where prometheus metrics are exposed
 	DefaultMetricsAddress = ":8085"
@@ -28,5 +34,19 @@ const (
 	DefaultInsecureFlag       bool = true
 	DefaultVCConfigFromSecret bool = true
 
-	DefaultBackupWorkers = 1
+	DefaultBackupWorkers      = 1
+	DefaultUploadWorkers      = 1
+	DefaultDownloadWorkers    = 1
+	DefaultBandwidthLimit     = "0"
+	DefaultUploadMaxRetries   = constants.UPLOAD_MAX_RETRY
+	DefaultDownloadMaxRetries = constants.DOWNLOAD_MAX_RETRY
+	DefaultIncrementalUpload  = false
+
+	// The repository garbage collection is disabled by default
+	DefaultRepositoryGCPeriod      = time.Duration(0)
+	DefaultRepositoryGCGracePeriod = 72 * time.Hour
+	DefaultRepositoryGCDelete      = false
+
+	// How often the uploads of the snapshots of the completed backups are checked
+	DefaultBackupUploadSyncPeriod = time.Minute
 )
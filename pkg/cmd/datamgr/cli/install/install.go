@@ -24,6 +24,7 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/cmd"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/dataMover"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/install"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	"github.com/vmware-tanzu/velero/pkg/client"
@@ -57,6 +58,19 @@ type InstallOptions struct {
 	NoSecret       bool
 	DryRun         bool
 	SkipInstall    bool
+	// The number of concurrent uploads and downloads on each node
+	UploadWorkers   int
+	DownloadWorkers int
+	// The maximum bandwidth of the uploads and downloads on each node
+	BandwidthLimit string
+	// The number of retries of a failed upload before it is marked as Failed
+	UploadMaxRetries int
+	// The number of retries of a failed download before it is marked as Failed
+	DownloadMaxRetries int
+	// Upload only the blocks changed since the previous upload of the volume
+	IncrementalUpload bool
+	// The repository config is retrieved from the cluster instead of flags
+	RepositoryConfig map[string]string
 }
 
 func (o *InstallOptions) BindFlags(flags *pflag.FlagSet) {
@@ -72,17 +86,29 @@ func (o *InstallOptions) BindFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&o.PodCPULimit, "datamgr-pod-cpu-limit", o.PodCPULimit, `CPU limit for Datamgr pod. A value of "0" is treated as unbounded. Optional.`)
 	flags.StringVar(&o.PodMemLimit, "datamgr-pod-mem-limit", o.PodMemLimit, `memory limit for Datamgr pod. A value of "0" is treated as unbounded. Optional.`)
 	flags.BoolVar(&o.DryRun, "dry-run", o.DryRun, "generate resources, but don't send them to the cluster. Use with -o. Optional.")
+	flags.IntVar(&o.UploadWorkers, "upload-workers", o.UploadWorkers, "the number of concurrent uploads on each node. Optional.")
+	flags.IntVar(&o.DownloadWorkers, "download-workers", o.DownloadWorkers, "the number of concurrent downloads on each node. Optional.")
+	flags.StringVar(&o.BandwidthLimit, "bandwidth-limit", o.BandwidthLimit, `maximum bandwidth in bytes per second, e.g. "100Mi", of the uploads and downloads on each node. A value of "0" is treated as unbounded. Optional.`)
+	flags.IntVar(&o.UploadMaxRetries, "upload-max-retries", o.UploadMaxRetries, `maximum number of retries of a failed upload before it is marked as Failed. A value of "0" is treated as unlimited. Optional.`)
+	flags.IntVar(&o.DownloadMaxRetries, "download-max-retries", o.DownloadMaxRetries, `maximum number of retries of a failed download before it is marked as Failed. A value of "0" is treated as unlimited. Optional.`)
+	flags.BoolVar(&o.IncrementalUpload, "incremental-upload", o.IncrementalUpload, "upload only the blocks changed since the previous upload of the volume, using changed block tracking. The latest uploaded local snapshot of each volume is kept. Optional.")
 }
 
 func NewInstallOptions() *InstallOptions {
 	return &InstallOptions{
-		Namespace:      "velero",
-		Image:          install.DefaultDatamgrImage,
-		PodAnnotations: flag.NewMap(),
-		PodCPURequest:  install.DefaultDatamgrPodCPURequest,
-		PodMemRequest:  install.DefaultDatamgrPodMemRequest,
-		PodCPULimit:    install.DefaultDatamgrPodCPULimit,
-		PodMemLimit:    install.DefaultDatamgrPodMemLimit,
+		Namespace:          "velero",
+		Image:              install.DefaultDatamgrImage,
+		PodAnnotations:     flag.NewMap(),
+		PodCPURequest:      install.DefaultDatamgrPodCPURequest,
+		PodMemRequest:      install.DefaultDatamgrPodMemRequest,
+		PodCPULimit:        install.DefaultDatamgrPodCPULimit,
+		PodMemLimit:        install.DefaultDatamgrPodMemLimit,
+		UploadWorkers:      cmd.DefaultUploadWorkers,
+		DownloadWorkers:    cmd.DefaultDownloadWorkers,
+		BandwidthLimit:     cmd.DefaultBandwidthLimit,
+		UploadMaxRetries:   cmd.DefaultUploadMaxRetries,
+		DownloadMaxRetries: cmd.DefaultDownloadMaxRetries,
+		IncrementalUpload:  cmd.DefaultIncrementalUpload,
 	}
 }
 
@@ -105,16 +131,31 @@ func (o *InstallOptions) AsDatamgrOptions() (*install.PodOptions, error) {
 		return nil, err
 	}
 
+	if _, err := dataMover.ParseBandwidthLimit(o.BandwidthLimit); err != nil {
+		return nil, err
+	}
+
+	if o.DownloadMaxRetries < 0 {
+		return nil, errors.Errorf("download-max-retries %d must not be negative", o.DownloadMaxRetries)
+	}
+
 	return &install.PodOptions{
-		Namespace:      o.Namespace,
-		Image:          o.Image,
-		ProviderName:   o.ProviderName,
-		Bucket:         o.BucketName,
-		Prefix:         o.Prefix,
-		PodAnnotations: o.PodAnnotations.Data(),
-		PodResources:   podResources,
-		SecretData:     secretData,
-		SecretAdd:      true,
+		Namespace:          o.Namespace,
+		Image:              o.Image,
+		ProviderName:       o.ProviderName,
+		Bucket:             o.BucketName,
+		Prefix:             o.Prefix,
+		PodAnnotations:     o.PodAnnotations.Data(),
+		PodResources:       podResources,
+		SecretData:         secretData,
+		SecretAdd:          true,
+		RepositoryConfig:   o.RepositoryConfig,
+		UploadWorkers:      o.UploadWorkers,
+		DownloadWorkers:    o.DownloadWorkers,
+		BandwidthLimit:     o.BandwidthLimit,
+		UploadMaxRetries:   o.UploadMaxRetries,
+		DownloadMaxRetries: o.DownloadMaxRetries,
+		IncrementalUpload:  o.IncrementalUpload,
 	}, nil
 }
 
@@ -168,6 +209,9 @@ func (o *InstallOptions) Run(c *cobra.Command, f client.Factory) error {
 	// Check velero vsphere plugin image repo
 	o.Image, _ = cmd.CheckPluginImageRepo(kubeClient, o.Namespace, o.Image, constants.DataManagerForPlugin)
 
+	// Check the repository config for data-manager
+	o.RepositoryConfig, _ = cmd.CheckRepositoryConfig(kubeClient, o.Namespace)
+
 	fmt.Println("The prerequisite checks for data-manager completed")
 
 	vo, err := o.AsDatamgrOptions()
@@ -219,7 +263,7 @@ func (o *InstallOptions) Run(c *cobra.Command, f client.Factory) error {
 	return nil
 }
 
-//Complete completes options for a command.
+// Complete completes options for a command.
 func (o *InstallOptions) Complete(args []string, f client.Factory) error {
 	fileName := "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 
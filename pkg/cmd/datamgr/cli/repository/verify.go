@@ -0,0 +1,271 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	datamoverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/datamover/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backuprepository"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/cmd"
+	plugin_clientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/scrub"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/client"
+	"github.com/vmware-tanzu/velero/pkg/cmd/util/downloadrequest"
+	velero_clientset "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned"
+	"github.com/vmware-tanzu/velero/pkg/util/logging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type VerifyOptions struct {
+	// Verify only the repository of this BackupRepository
+	BackupRepository string
+	// Only check that the snapshots are in the repositories, without reading their data
+	SkipData bool
+	// The timeout of the download of the contents of each backup
+	Timeout               time.Duration
+	InsecureSkipTLSVerify bool
+	CACertFile            string
+}
+
+func (o *VerifyOptions) BindFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.BackupRepository, "backup-repository", o.BackupRepository, "name of the BackupRepository whose repository to verify. All the repositories are verified if not specified. Optional.")
+	flags.BoolVar(&o.SkipData, "skip-data", o.SkipData, "only check that the snapshots are in the repositories, without reading their data back to verify their checksums. Optional.")
+	flags.DurationVar(&o.Timeout, "timeout", o.Timeout, "how long to wait to receive the contents of each backup. Optional.")
+	flags.BoolVar(&o.InsecureSkipTLSVerify, "insecure-skip-tls-verify", o.InsecureSkipTLSVerify, "if true, the object store's TLS certificate will not be checked for validity when the contents of the backups are downloaded. This is insecure and susceptible to man-in-the-middle attacks. Not recommended for production. Optional.")
+	flags.StringVar(&o.CACertFile, "cacert", o.CACertFile, "path to a certificate bundle to use when the contents of the backups are downloaded. Optional.")
+}
+
+func NewVerifyOptions() *VerifyOptions {
+	return &VerifyOptions{
+		Timeout: time.Minute,
+	}
+}
+
+func NewVerifyCommand(f client.Factory) *cobra.Command {
+	var (
+		logLevelFlag = logging.LogLevelFlag(logrus.WarnLevel)
+		o            = NewVerifyOptions()
+	)
+
+	c := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the snapshots in the backup repositories",
+		Long: `Verify the snapshots in the backup repositories against the Velero backups which reference them.
+The snapshots referenced by a backup which are not in their repository are reported as missing, and the
+snapshots in a repository which are not referenced by any backup are reported as orphaned. The referenced
+snapshots are read back from their repository to verify them against their checksums, the snapshots which
+do not match are reported as corrupt.
+
+The command needs access to the backup repositories, so it is meant to be run in a data manager pod.`,
+		Example: `	kubectl -n velero exec <data manager pod name> -- /datamgr repository verify`,
+		Run: func(c *cobra.Command, args []string) {
+			// The report is written to stdout, the log to stderr
+			logger := logrus.New()
+			logger.SetOutput(os.Stderr)
+			logger.SetLevel(logLevelFlag.Parse())
+
+			f.SetBasename(fmt.Sprintf("%s-%s", c.Parent().Name(), c.Name()))
+			problems, err := o.Run(context.Background(), f, os.Stdout, logger)
+			cmd.CheckError(err)
+			if problems > 0 {
+				cmd.Exit("Found %d snapshots with problems", problems)
+			}
+		},
+	}
+
+	c.Flags().Var(logLevelFlag, "log-level", fmt.Sprintf("the level at which to log. Valid values are %s.", strings.Join(logLevelFlag.AllowedValues(), ", ")))
+	o.BindFlags(c.Flags())
+
+	return c
+}
+
+// Run verifies the repositories and writes the report to out. It returns the number of the snapshots with problems.
+func (o *VerifyOptions) Run(ctx context.Context, f client.Factory, out io.Writer, logger logrus.FieldLogger) (int, error) {
+	clientConfig, err := f.ClientConfig()
+	if err != nil {
+		return 0, err
+	}
+	veleroClient, err := f.Client()
+	if err != nil {
+		return 0, err
+	}
+	pluginClient, err := plugin_clientset.NewForConfig(clientConfig)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to get the plugin clientset")
+	}
+
+	groups, err := o.getRepositoryGroups(ctx, pluginClient)
+	if err != nil {
+		return 0, err
+	}
+	references, err := o.getSnapshotReferences(ctx, veleroClient, f.Namespace(), out, logger)
+	if err != nil {
+		return 0, err
+	}
+	uploadList, err := pluginClient.DatamoverV1alpha1().Uploads(f.Namespace()).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to list the Uploads")
+	}
+	options := scrub.Options{
+		VerifyData:   !o.SkipData,
+		UploadPhases: make(map[string]datamoverv1.UploadPhase),
+	}
+	for _, upload := range uploadList.Items {
+		options.UploadPhases[upload.Spec.SnapshotID] = upload.Status.Phase
+	}
+
+	problems := 0
+	for _, group := range groups {
+		var names []string
+		var groupReferences []scrub.SnapshotReference
+		for _, backupRepository := range group {
+			names = append(names, backupRepository.Name)
+			for _, reference := range references {
+				if reference.BackupRepository == backupRepository.Name {
+					groupReferences = append(groupReferences, reference)
+				}
+			}
+		}
+		fmt.Fprintf(out, "Backup repositories: %s\n", strings.Join(names, ", "))
+		petm, err := backuprepository.GetRepositoryFromBackupRepository(&group[0], logger)
+		if err != nil {
+			return problems, errors.Wrapf(err, "Failed to get the repository of the backup repository %s", group[0].Name)
+		}
+		results, err := scrub.VerifyRepository(ctx, petm, groupReferences, options, logger)
+		if err != nil {
+			return problems, errors.Wrapf(err, "Failed to verify the repository of the backup repository %s", group[0].Name)
+		}
+		problems += printResults(out, results)
+		fmt.Fprintln(out)
+	}
+	return problems, nil
+}
+
+// getRepositoryGroups returns the BackupRepositories to verify, grouped by the repository in which they store their
+// snapshots.
+func (o *VerifyOptions) getRepositoryGroups(ctx context.Context, pluginClient plugin_clientset.Interface) ([][]backupdriverv1.BackupRepository, error) {
+	backupRepositoryList, err := pluginClient.BackupdriverV1alpha1().BackupRepositories().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list the BackupRepositories")
+	}
+	groups := scrub.GroupBackupRepositories(backupRepositoryList.Items)
+	if o.BackupRepository == "" {
+		return groups, nil
+	}
+	for _, group := range groups {
+		for _, backupRepository := range group {
+			if backupRepository.Name == o.BackupRepository {
+				return [][]backupdriverv1.BackupRepository{group}, nil
+			}
+		}
+	}
+	return nil, errors.Errorf("BackupRepository %s not found", o.BackupRepository)
+}
+
+// getSnapshotReferences returns the snapshots referenced by the backups, read from the contents of the backups.
+func (o *VerifyOptions) getSnapshotReferences(ctx context.Context, veleroClient velero_clientset.Interface, namespace string,
+	out io.Writer, logger logrus.FieldLogger) ([]scrub.SnapshotReference, error) {
+	backupList, err := veleroClient.VeleroV1().Backups(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list the backups")
+	}
+	var references []scrub.SnapshotReference
+	for _, backup := range backupList.Items {
+		switch backup.Status.Phase {
+		case velerov1.BackupPhaseCompleted, velerov1.BackupPhasePartiallyFailed:
+		case velerov1.BackupPhaseNew, velerov1.BackupPhaseInProgress, velerov1.BackupPhaseDeleting:
+			fmt.Fprintf(out, "The backup %s is in phase %s, its snapshots may be reported as orphaned\n", backup.Name, backup.Status.Phase)
+			continue
+		default:
+			logger.Infof("Skipping the backup %s in phase %s", backup.Name, backup.Status.Phase)
+			continue
+		}
+		backupReferences, err := o.getBackupSnapshotReferences(veleroClient, namespace, backup.Name, logger)
+		if err != nil {
+			return nil, err
+		}
+		references = append(references, backupReferences...)
+	}
+	return references, nil
+}
+
+func (o *VerifyOptions) getBackupSnapshotReferences(veleroClient velero_clientset.Interface, namespace string, backupName string,
+	logger logrus.FieldLogger) ([]scrub.SnapshotReference, error) {
+	// The contents of a backup may be large, so they are downloaded to a temporary file
+	contents, err := ioutil.TempFile("", "backup-contents")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create a temporary file")
+	}
+	defer os.Remove(contents.Name())
+	defer contents.Close()
+
+	logger.Infof("Downloading the contents of the backup %s", backupName)
+	err = downloadrequest.Stream(veleroClient.VeleroV1(), namespace, backupName, velerov1.DownloadTargetKindBackupContents,
+		contents, o.Timeout, o.InsecureSkipTLSVerify, o.CACertFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to download the contents of the backup %s", backupName)
+	}
+	if _, err := contents.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrapf(err, "Failed to read the contents of the backup %s", backupName)
+	}
+	return scrub.GetSnapshotReferences(backupName, contents, logger)
+}
+
+// printResults writes the results to out, and returns the number of the snapshots with problems.
+func printResults(out io.Writer, results []scrub.SnapshotResult) int {
+	problems := 0
+	counts := make(map[scrub.SnapshotStatus]int)
+	var statuses []string
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT\tSTATUS\tBACKUPS\tMESSAGE")
+	for _, result := range results {
+		if counts[result.Status] == 0 {
+			statuses = append(statuses, string(result.Status))
+		}
+		counts[result.Status]++
+		// The healthy snapshots are only counted
+		if result.Status == scrub.SnapshotStatusVerified || result.Status == scrub.SnapshotStatusPresent {
+			continue
+		}
+		if result.IsProblem() {
+			problems++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.PeID.String(), result.Status, strings.Join(result.Backups, ","), result.Message)
+	}
+	w.Flush()
+
+	summary := make([]string, len(statuses))
+	for i, status := range statuses {
+		summary[i] = fmt.Sprintf("%s: %d", status, counts[scrub.SnapshotStatus(status)])
+	}
+	fmt.Fprintf(out, "Snapshots: %d (%s)\n", len(results), strings.Join(summary, ", "))
+	return problems
+}
@@ -42,11 +42,11 @@ import (
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/dataMover"
 	plugin_clientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned"
 	pluginInformers "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/informers/externalversions"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/metrics"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/snapshotmgr"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	"github.com/vmware-tanzu/velero/pkg/client"
 	"github.com/vmware-tanzu/velero/pkg/cmd/util/signals"
-	"github.com/vmware-tanzu/velero/pkg/metrics"
 	"github.com/vmware-tanzu/velero/pkg/util/logging"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubeinformers "k8s.io/client-go/informers"
@@ -66,6 +66,12 @@ type serverConfig struct {
 	clusterId          string
 	insecureFlag       bool
 	vcConfigFromSecret bool
+	uploadWorkers      int
+	downloadWorkers    int
+	bandwidthLimit     string
+	uploadMaxRetries   int
+	downloadMaxRetries int
+	incrementalUpload  bool
 }
 
 func NewCommand(f client.Factory) *cobra.Command {
@@ -76,6 +82,12 @@ func NewCommand(f client.Factory) *cobra.Command {
 			clientQPS:          cmd.DefaultClientQPS,
 			clientBurst:        cmd.DefaultClientBurst,
 			profilerAddress:    cmd.DefaultProfilerAddress,
+			uploadWorkers:      cmd.DefaultUploadWorkers,
+			downloadWorkers:    cmd.DefaultDownloadWorkers,
+			bandwidthLimit:     cmd.DefaultBandwidthLimit,
+			uploadMaxRetries:   cmd.DefaultUploadMaxRetries,
+			downloadMaxRetries: cmd.DefaultDownloadMaxRetries,
+			incrementalUpload:  cmd.DefaultIncrementalUpload,
 			formatFlag:         logging.NewFormatFlag(),
 			port:               constants.DefaultVCenterPort,
 			insecureFlag:       cmd.DefaultInsecureFlag,
@@ -133,6 +145,12 @@ func NewCommand(f client.Factory) *cobra.Command {
 	command.Flags().StringVar(&config.clusterId, "cluster-id", config.clusterId, "kubernetes cluster id. If specified, --use-secret should be set to False.")
 	command.Flags().BoolVar(&config.insecureFlag, "insecure-Flag", config.insecureFlag, "insecure flag. If specified, --use-secret should be set to False.")
 	command.Flags().BoolVar(&config.vcConfigFromSecret, "use-secret", config.vcConfigFromSecret, "retrieve VirtualCenter configuration from secret")
+	command.Flags().IntVar(&config.uploadWorkers, "upload-workers", config.uploadWorkers, "Concurrency to process multiple upload requests")
+	command.Flags().IntVar(&config.downloadWorkers, "download-workers", config.downloadWorkers, "Concurrency to process multiple download requests")
+	command.Flags().StringVar(&config.bandwidthLimit, "bandwidth-limit", config.bandwidthLimit, `maximum bandwidth in bytes per second, e.g. "100Mi", shared by all the uploads and downloads of the server. A value of "0" is treated as unbounded.`)
+	command.Flags().IntVar(&config.uploadMaxRetries, "upload-max-retries", config.uploadMaxRetries, `maximum number of retries of a failed upload before it is marked as Failed. A value of "0" is treated as unlimited.`)
+	command.Flags().IntVar(&config.downloadMaxRetries, "download-max-retries", config.downloadMaxRetries, `maximum number of retries of a failed download before it is marked as Failed. A value of "0" is treated as unlimited.`)
+	command.Flags().BoolVar(&config.incrementalUpload, "incremental-upload", config.incrementalUpload, "upload only the blocks changed since the previous upload of the volume, using changed block tracking. The latest uploaded local snapshot of each volume is kept as the base of its next upload.")
 
 	return command
 }
@@ -274,10 +292,17 @@ func newServer(f client.Factory, config serverConfig, logger *logrus.Logger) (*s
 		externalDataMgr = true
 	}
 
+	bandwidthLimit, err := dataMover.ParseBandwidthLimit(config.bandwidthLimit)
+	if err != nil {
+		return nil, err
+	}
+
 	clusterDataMover, err := dataMover.NewDataMoverFromCluster(ivdParams, logger)
 	if err != nil {
 		return nil, err
 	}
+	clusterDataMover.SetBandwidthLimit(bandwidthLimit)
+	clusterDataMover.SetIncrementalUpload(config.incrementalUpload)
 
 	s := &server{
 		namespace:             f.Namespace(),
@@ -327,6 +352,12 @@ func (s *server) runControllers() error {
 	s.logger.Info("Starting data manager controllers")
 
 	ctx := s.ctx
+
+	metrics.StartMetricsServer(s.metricsAddress, s.logger)
+	s.metrics = metrics.NewServerMetrics()
+	s.metrics.RegisterAllMetrics()
+	s.metrics.RegisterUploadsInProgress(os.Getenv("NODE_NAME"), s.dataMover.InProgressUploadCount)
+
 	var wg sync.WaitGroup
 	// Register controllers
 	s.logger.Info("Registering controllers")
@@ -340,6 +371,8 @@ func (s *server) runControllers() error {
 		s.snapManager,
 		os.Getenv("NODE_NAME"),
 		s.externalDataMgr,
+		s.metrics,
+		s.config.uploadMaxRetries,
 	)
 
 	downloadController := controller.NewDownloadController(
@@ -349,6 +382,8 @@ func (s *server) runControllers() error {
 		s.kubeClient,
 		s.dataMover,
 		os.Getenv("NODE_NAME"),
+		s.metrics,
+		s.config.downloadMaxRetries,
 	)
 
 	if !s.externalDataMgr && s.vcConfigSecret {
@@ -369,13 +404,13 @@ func (s *server) runControllers() error {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		uploadController.Run(s.ctx, 1)
+		uploadController.Run(s.ctx, s.config.uploadWorkers)
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		downloadController.Run(s.ctx, 1)
+		downloadController.Run(s.ctx, s.config.downloadWorkers)
 	}()
 
 	// SHARED INFORMERS HAVE TO BE STARTED AFTER ALL CONTROLLERS
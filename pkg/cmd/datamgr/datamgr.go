@@ -22,6 +22,7 @@ import (
 	"os"
 
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/cmd/datamgr/cli/install"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/cmd/datamgr/cli/repository"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/cmd/datamgr/cli/server"
 
 	"github.com/spf13/cobra"
@@ -53,6 +54,7 @@ func NewCommand(name string) *cobra.Command {
 	c.AddCommand(
 		server.NewCommand(f),
 		install.NewCommand(f),
+		repository.NewCommand(f),
 	)
 
 	// init and add the klog flags
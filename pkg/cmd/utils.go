@@ -330,3 +330,14 @@ func CheckPluginImageRepo(kubeClient kubernetes.Interface, ns string, defaultIma
 
 	return resultImage, err
 }
+
+func CheckRepositoryConfig(kubeClient kubernetes.Interface, ns string) (map[string]string, error) {
+	repositoryConfig, err := utils.RetrieveRepositoryConfig(kubeClient, ns)
+	if err != nil {
+		fmt.Printf("Failed to retrieve the repository config, error msg: %s. Assuming the object store is used as repository\n", err.Error())
+	} else if utils.IsFileSystemRepositoryConfigured(repositoryConfig) {
+		fmt.Println("Detected the file system repository config, mounting the repository volume")
+	}
+
+	return repositoryConfig, err
+}
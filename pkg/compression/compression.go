@@ -0,0 +1,211 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package compression implements the compression of the snapshot data written to a backup repository.
+//
+// A compressed stream starts with a header which records the codec the stream is compressed with, so that the codec
+// of a repository can be changed at any time and each snapshot is still decompressed with the codec it was written
+// with. A stream without the header, e.g. a snapshot written before the compression was enabled, is read as is.
+//
+// The only codec is gzip, besides none. Other codecs such as zstd are not in the Go standard library, and are not
+// offered to avoid the dependency. A codec is added by registering it in codecs.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// streamMagic identifies a compressed stream
+	streamMagic = "VPVSCMP1"
+	// readSize is the size of the reads from the uncompressed source
+	readSize = 64 * 1024
+)
+
+const (
+	// CodecNone leaves the snapshots uncompressed
+	CodecNone = "none"
+	// CodecGzip compresses the snapshots with gzip
+	CodecGzip = "gzip"
+)
+
+// codec creates the compressor and the decompressor of a stream.
+type codec struct {
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+	newReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// codecs is the registry of the supported codecs by name. The name of a codec is recorded in the streams, so it
+// must never change once the codec is supported.
+var codecs = map[string]codec{
+	CodecGzip: {
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, gzip.BestSpeed)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+}
+
+// ValidateCodec checks if the codec is supported. An empty codec is the same as CodecNone.
+func ValidateCodec(codecName string) error {
+	if codecName == "" || codecName == CodecNone {
+		return nil
+	}
+	if _, ok := codecs[codecName]; !ok {
+		supported := []string{CodecNone}
+		for name := range codecs {
+			supported = append(supported, name)
+		}
+		sort.Strings(supported)
+		return errors.Errorf("Unsupported compression codec %q, the supported codecs are %s", codecName, strings.Join(supported, ", "))
+	}
+	return nil
+}
+
+// compressReader compresses the stream read from the source.
+type compressReader struct {
+	source io.ReadCloser
+	writer io.WriteCloser
+	// pending is the compressed output which has not been read yet
+	pending bytes.Buffer
+	plain   []byte
+	done    bool
+}
+
+// NewCompressReader returns a reader of the source stream compressed with the codec. With CodecNone the source is
+// returned as is.
+func NewCompressReader(source io.ReadCloser, codecName string) (io.ReadCloser, error) {
+	if err := ValidateCodec(codecName); err != nil {
+		return nil, err
+	}
+	if codecName == "" || codecName == CodecNone {
+		return source, nil
+	}
+	reader := &compressReader{
+		source: source,
+		plain:  make([]byte, readSize),
+	}
+	reader.pending.WriteString(streamMagic)
+	reader.pending.WriteByte(byte(len(codecName)))
+	reader.pending.WriteString(codecName)
+	writer, err := codecs[codecName].newWriter(&reader.pending)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create the %s compressor", codecName)
+	}
+	reader.writer = writer
+	return reader, nil
+}
+
+func (this *compressReader) Read(p []byte) (int, error) {
+	for this.pending.Len() == 0 {
+		if this.done {
+			return 0, io.EOF
+		}
+		if err := this.compress(); err != nil {
+			return 0, err
+		}
+	}
+	return this.pending.Read(p)
+}
+
+// compress compresses the next read from the source into the pending output, which may stay empty as the compressor
+// buffers its input.
+func (this *compressReader) compress() error {
+	n, err := this.source.Read(this.plain)
+	if n > 0 {
+		if _, writeErr := this.writer.Write(this.plain[:n]); writeErr != nil {
+			return errors.Wrap(writeErr, "Failed to compress the stream")
+		}
+	}
+	if err == io.EOF {
+		this.done = true
+		if closeErr := this.writer.Close(); closeErr != nil {
+			return errors.Wrap(closeErr, "Failed to compress the stream")
+		}
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (this *compressReader) Close() error {
+	return this.source.Close()
+}
+
+// decompressReader decompresses a compressed stream read from the source.
+type decompressReader struct {
+	io.ReadCloser
+	source io.ReadCloser
+}
+
+// NewDecompressReader returns a reader of the source stream decompressed with the codec recorded in the stream. A
+// stream which is not compressed is returned as is.
+func NewDecompressReader(source io.ReadCloser) (io.ReadCloser, error) {
+	magic := make([]byte, len(streamMagic))
+	n, err := io.ReadFull(source, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if string(magic[:n]) != streamMagic {
+		return &prefixedReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(magic[:n]), source),
+			closer: source,
+		}, nil
+	}
+	codecNameLength := make([]byte, 1)
+	if _, err := io.ReadFull(source, codecNameLength); err != nil {
+		return nil, errors.Wrap(err, "Failed to read the header of the compressed stream")
+	}
+	codecName := make([]byte, codecNameLength[0])
+	if _, err := io.ReadFull(source, codecName); err != nil {
+		return nil, errors.Wrap(err, "Failed to read the header of the compressed stream")
+	}
+	codec, ok := codecs[string(codecName)]
+	if !ok {
+		return nil, errors.Errorf("The stream is compressed with the unsupported codec %q", string(codecName))
+	}
+	reader, err := codec.newReader(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create the %s decompressor", string(codecName))
+	}
+	return &decompressReader{
+		ReadCloser: reader,
+		source:     source,
+	}, nil
+}
+
+func (this *decompressReader) Close() error {
+	this.ReadCloser.Close()
+	return this.source.Close()
+}
+
+// prefixedReadCloser reads the bytes already consumed from the source before the rest of the source.
+type prefixedReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (this *prefixedReadCloser) Close() error {
+	return this.closer.Close()
+}
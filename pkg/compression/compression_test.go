@@ -0,0 +1,175 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compression
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/fsrepository"
+)
+
+func compress(t *testing.T, plain []byte, codecName string) []byte {
+	reader, err := NewCompressReader(ioutil.NopCloser(bytes.NewReader(plain)), codecName)
+	require.NoError(t, err)
+	compressed, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	return compressed
+}
+
+func decompress(compressed []byte) ([]byte, error) {
+	reader, err := NewDecompressReader(ioutil.NopCloser(bytes.NewReader(compressed)))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+func TestValidateCodec(t *testing.T) {
+	for _, codecName := range []string{"", CodecNone, CodecGzip} {
+		assert.NoError(t, ValidateCodec(codecName), codecName)
+	}
+	err := ValidateCodec("lz4")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "lz4")
+}
+
+func TestCompressDecompress(t *testing.T) {
+	for _, size := range []int{0, 1, readSize - 1, readSize, 3*readSize + 17} {
+		plain := bytes.Repeat([]byte("disk"), size)[:size]
+		compressed := compress(t, plain, CodecGzip)
+		assert.True(t, bytes.HasPrefix(compressed, []byte(streamMagic+"\x04gzip")), "size %d", size)
+		if size >= readSize {
+			assert.Less(t, len(compressed), size/10, "size %d", size)
+		}
+		decompressed, err := decompress(compressed)
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, plain, decompressed, "size %d", size)
+	}
+
+	// The data is left as is without compression
+	plain := []byte("not compressed")
+	assert.Equal(t, plain, compress(t, plain, CodecNone))
+}
+
+func TestDecompressUncompressed(t *testing.T) {
+	for _, plain := range [][]byte{nil, []byte("VPV"), []byte("written before the compression was enabled")} {
+		decompressed, err := decompress(plain)
+		require.NoError(t, err)
+		assert.Equal(t, string(plain), string(decompressed))
+	}
+}
+
+func TestDecompressUnsupportedCodec(t *testing.T) {
+	_, err := decompress([]byte(streamMagic + "\x03lz4data"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "lz4")
+}
+
+type fakeProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	info     astrolabe.ProtectedEntityInfo
+	data     []byte
+	metadata []byte
+}
+
+func (this fakeProtectedEntity) GetID() astrolabe.ProtectedEntityID {
+	return this.info.GetID()
+}
+
+func (this fakeProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return this.info, nil
+}
+
+func (this fakeProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.data)), nil
+}
+
+func (this fakeProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.metadata)), nil
+}
+
+func TestCompressedRepository(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "compression")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	fsPETM, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+
+	readData := func(pe astrolabe.ProtectedEntity) []byte {
+		reader, err := pe.GetDataReader(ctx)
+		require.NoError(t, err)
+		defer reader.Close()
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		return data
+	}
+	copyToRepo := func(petm astrolabe.ProtectedEntityTypeManager, id string, data []byte) astrolabe.ProtectedEntity {
+		peID, err := astrolabe.NewProtectedEntityIDFromString(id)
+		require.NoError(t, err)
+		transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("fake", map[string]string{})}
+		repoPE, err := petm.Copy(ctx, fakeProtectedEntity{
+			info:     astrolabe.NewProtectedEntityInfo(peID, "fake-pe", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}),
+			data:     data,
+			metadata: []byte("metadata"),
+		}, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+		require.NoError(t, err)
+		return repoPE
+	}
+
+	// A snapshot written before the compression was enabled
+	uncompressedData := bytes.Repeat([]byte("old data "), 10000)
+	uncompressedPE := copyToRepo(fsPETM, "ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:67469e1c-50a8-4f63-9a6a-ad8a2265197c", uncompressedData)
+
+	petm, err := NewProtectedEntityTypeManager(fsPETM, CodecGzip, logrus.New())
+	require.NoError(t, err)
+	compressedData := bytes.Repeat([]byte("new data "), 10000)
+	compressedPE := copyToRepo(petm, "ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:7d1f6cd2-5e4b-4bd1-a6a4-96a6b8d0cd5a", compressedData)
+	storedDataSize := compressedPE.(copiedProtectedEntity).GetStoredDataSize()
+	assert.Less(t, storedDataSize, int64(len(compressedData))/10)
+
+	// The repository only holds the compressed data
+	rawPE, err := fsPETM.GetProtectedEntity(ctx, compressedPE.GetID())
+	require.NoError(t, err)
+	assert.Equal(t, storedDataSize, int64(len(readData(rawPE))))
+
+	// Both snapshots are restored, also after the compression is disabled
+	uncompressedPETM, err := NewProtectedEntityTypeManager(fsPETM, CodecNone, logrus.New())
+	require.NoError(t, err)
+	for _, repoPETM := range []*ProtectedEntityTypeManager{petm, uncompressedPETM} {
+		repoPE, err := repoPETM.GetProtectedEntity(ctx, uncompressedPE.GetID())
+		require.NoError(t, err)
+		assert.Equal(t, uncompressedData, readData(repoPE))
+		repoPE, err = repoPETM.GetProtectedEntity(ctx, compressedPE.GetID())
+		require.NoError(t, err)
+		assert.Equal(t, compressedData, readData(repoPE))
+	}
+
+	_, err = NewProtectedEntityTypeManager(fsPETM, "lz4", logrus.New())
+	assert.Error(t, err)
+}
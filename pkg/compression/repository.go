@@ -0,0 +1,145 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compression
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/decorator"
+)
+
+// ProtectedEntityTypeManager compresses the data of the snapshots copied to the repository it wraps with its codec,
+// and decompresses the data of the snapshots read back with the codec recorded in each of them. The metadata is
+// small and stays uncompressed.
+type ProtectedEntityTypeManager struct {
+	decorator.ProtectedEntityTypeManager
+	codec  string
+	logger logrus.FieldLogger
+}
+
+func NewProtectedEntityTypeManager(petm astrolabe.ProtectedEntityTypeManager, codecName string,
+	logger logrus.FieldLogger) (*ProtectedEntityTypeManager, error) {
+	if err := ValidateCodec(codecName); err != nil {
+		return nil, err
+	}
+	if codecName == "" {
+		codecName = CodecNone
+	}
+	return &ProtectedEntityTypeManager{
+		ProtectedEntityTypeManager: decorator.NewProtectedEntityTypeManager(petm, func(pe astrolabe.ProtectedEntity) astrolabe.ProtectedEntity {
+			return decompressingProtectedEntity{
+				ProtectedEntity: pe,
+			}
+		}),
+		codec:  codecName,
+		logger: logger,
+	}, nil
+}
+
+// Copy copies the snapshot to the repository with its data compressed. The returned ProtectedEntity also reports
+// the number of bytes of the data stored in the repository through GetStoredDataSize.
+func (this *ProtectedEntityTypeManager) Copy(ctx context.Context, pe astrolabe.ProtectedEntity, params map[string]map[string]interface{},
+	options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntity, error) {
+	this.logger.Debugf("Compressing the snapshot %s with the codec %s", pe.GetID().String(), this.codec)
+	var storedDataSize int64
+	repoPE, err := this.ProtectedEntityTypeManager.Copy(ctx, compressingProtectedEntity{
+		ProtectedEntity: pe,
+		codec:           this.codec,
+		storedDataSize:  &storedDataSize,
+	}, params, options)
+	if err != nil {
+		return nil, err
+	}
+	return copiedProtectedEntity{
+		decompressingProtectedEntity: decompressingProtectedEntity{
+			ProtectedEntity: repoPE,
+		},
+		storedDataSize: atomic.LoadInt64(&storedDataSize),
+	}, nil
+}
+
+// compressingProtectedEntity is the source of a copy to the repository.
+type compressingProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	codec string
+	// storedDataSize counts the bytes of the compressed data stream
+	storedDataSize *int64
+}
+
+func (this compressingProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	reader, err := this.ProtectedEntity.GetDataReader(ctx)
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	compressReader, err := NewCompressReader(reader, this.codec)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	// The data stream is only read once per copy, the count restarts if it is read again
+	atomic.StoreInt64(this.storedDataSize, 0)
+	return countingReader{
+		ReadCloser: compressReader,
+		count:      this.storedDataSize,
+	}, nil
+}
+
+// decompressingProtectedEntity is a snapshot in the repository.
+type decompressingProtectedEntity struct {
+	astrolabe.ProtectedEntity
+}
+
+func (this decompressingProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	reader, err := this.ProtectedEntity.GetDataReader(ctx)
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	decompressReader, err := NewDecompressReader(reader)
+	if err != nil {
+		reader.Close()
+		return nil, errors.Wrapf(err, "Failed to decompress the snapshot %s", this.GetID().String())
+	}
+	return decompressReader, nil
+}
+
+// copiedProtectedEntity is a snapshot which has just been copied to the repository.
+type copiedProtectedEntity struct {
+	decompressingProtectedEntity
+	storedDataSize int64
+}
+
+// GetStoredDataSize returns the number of bytes of the data of the snapshot stored in the repository.
+func (this copiedProtectedEntity) GetStoredDataSize() int64 {
+	return this.storedDataSize
+}
+
+// countingReader counts the bytes read from the reader.
+type countingReader struct {
+	io.ReadCloser
+	count *int64
+}
+
+func (this countingReader) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	atomic.AddInt64(this.count, int64(n))
+	return n, err
+}
@@ -51,17 +51,23 @@ const (
 	// Valid values for the config with the VolumeSnapshotterManagerLocation key
 	VolumeSnapshotterPlugin     = "Plugin"
 	VolumeSnapshotterDataServer = "DataServer"
+	// The key of the overall timeout of restoring a volume from a snapshot in the repository, e.g. "4h".
+	// By default, it is DefaultDownloadTimeout. No timeout is applied if "0" is set.
+	VolumeSnapshotterDownloadTimeout = "DownloadTimeout"
 )
 
 const (
 	// Max retry limit for downloads.
 	DOWNLOAD_MAX_RETRY = 5
 
+	// Max retry limit for uploads.
+	UPLOAD_MAX_RETRY = 10
+
 	// Initial retry for both uploads and downloads.
 	MIN_RETRY = 0
 
-	// BACKOFF for downloads.
-	DOWNLOAD_BACKOFF = 5
+	// Max backoff limit for downloads.
+	DOWNLOAD_MAX_BACKOFF = 30
 
 	// Max backoff limit for uploads.
 	UPLOAD_MAX_BACKOFF = 60
@@ -70,6 +76,18 @@ const (
 	RETRY_WARNING_COUNT = 8
 )
 
+const (
+	// Minimum interval between two progress updates on the Upload/Download CRs during data movement.
+	ProgressReportInterval = 30 * time.Second
+
+	// Default overall timeout of restoring a volume from a snapshot in the repository.
+	DefaultDownloadTimeout = 24 * time.Hour
+
+	// Maximum number of snapshots in the chain of an incremental upload, including the full snapshot. Once it is
+	// reached, the next upload of the volume is a full one, which bounds the cost of restoring from the chain.
+	IncrementalUploadMaxChainLength = 30
+)
+
 // configuration constants for the S3 repository
 const (
 	DefaultS3RepoPrefix     = "plugins/vsphere-astrolabe-repo"
@@ -91,6 +109,7 @@ const (
 	// the Velero server and API objects.
 	DefaultNamespace          = "velero"
 	CloudCredentialSecretName = "cloud-credentials"
+	CloudCredentialSecretKey  = "cloud"
 )
 
 const (
@@ -109,7 +128,48 @@ const (
 )
 
 const (
-	S3RepositoryDriver string = "s3repository.astrolabe.vmware-tanzu.com"
+	S3RepositoryDriver         string = "s3repository.astrolabe.vmware-tanzu.com"
+	FileSystemRepositoryDriver string = "fsrepository.astrolabe.vmware-tanzu.com"
+)
+
+// The repository parameter, also read from the config of the BackupStorageLocation, to limit the bandwidth
+// of the data movements from/to a backup repository in bytes per second on each data manager pod, e.g. "50Mi".
+const RepositoryParamBandwidthLimit = "bandwidthLimit"
+
+// The repository parameter, also read from the config of the BackupStorageLocation or from the repository ConfigMap,
+// to select the codec the snapshot data is compressed with in a backup repository, e.g. "gzip". The data is not
+// compressed by default. The codec is recorded along with the data, so the codec may be changed at any time.
+const RepositoryParamCompression = "compression"
+
+// The ConfigMap, in the velero namespace, used to store volume snapshot data in a file system repository,
+// e.g., an NFS export, instead of the object store of the BackupStorageLocation, format:
+// repositoryDriver: filesystem
+// path: /backup-repository
+// nfsServer: 10.0.0.1
+// nfsPath: /exports/velero
+// The snapshots are encrypted on the client side, with either repository driver, if the Secret, in the velero
+// namespace, holding the encryption keys by key ID and the ID of the key to encrypt new snapshots with are set:
+// encryptionSecret: velero-vsphere-plugin-encryption-keys
+// encryptionKeyID: key-1
+// The snapshot data is compressed, with either repository driver, if the codec is set:
+// compression: gzip
+const (
+	RepositoryConfigMap = "velero-vsphere-plugin-repository-config"
+
+	RepositoryConfigDriverKey    = "repositoryDriver"
+	RepositoryConfigPathKey      = "path"
+	RepositoryConfigNFSServerKey = "nfsServer"
+	RepositoryConfigNFSPathKey   = "nfsPath"
+	RepositoryConfigHostPathKey  = "hostPath"
+
+	RepositoryConfigEncryptionSecretKey = "encryptionSecret"
+	RepositoryConfigEncryptionKeyIDKey  = "encryptionKeyID"
+
+	RepositoryDriverS3         = "s3"
+	RepositoryDriverFileSystem = "filesystem"
+
+	// The path where the file system repository is mounted in the data manager and backup driver pods
+	DefaultFileSystemRepositoryPath = "/backup-repository"
 )
 
 const (
@@ -117,6 +177,9 @@ const (
 	VCSecretNsSupervisor = "vmware-system-csi"
 	VCSecret             = "vsphere-config-secret"
 	VCSecretTKG          = "csi-vsphere-config"
+
+	// The key of the vSphere config of the vSphere CSI driver in the VC config secret
+	VCSecretKey = "csi-vsphere.conf"
 )
 
 const (
@@ -145,9 +208,15 @@ const (
 
 // Para Virtual Cluster access for Guest Cluster
 const (
-	PvApiEndpoint = "supervisor.default.svc" // TODO: get it from "kubectl get cm -n vmware-system-csi pvcsi-config"
+	// Default endpoint of the Supervisor Cluster, used if it is neither configured nor discovered
+	PvApiEndpoint = "supervisor.default.svc"
 	PvPort        = "6443"
 	PvSecretName  = "pvbackupdriver-provider-creds"
+	// The env variable to override the Supervisor Cluster endpoint, in the format of <host> or <host>:<port>
+	PvApiEndpointEnvVar = "SUPERVISOR_ENDPOINT"
+	// The pvCSI ConfigMap in the Guest Cluster which records the Supervisor Cluster endpoint
+	PvCSIConfigMapName      = "pvcsi-config"
+	PvCSIConfigMapNamespace = "vmware-system-csi"
 )
 
 const (
@@ -159,8 +228,57 @@ const (
 	ItemSnapshotLabel   = "velero-plugin-for-vsphere/item-snapshot-blob"
 	PluginVersionLabel  = "velero-plugin-for-vsphere/plugin-version"
 	SnapshotBackupLabel = "velero.io/backup-name"
+	// Labels of a Supervisor Snapshot CR which refer to the Guest Snapshot CR it was created for
+	GuestSnapshotNamespaceLabel = "velero-plugin-for-vsphere/guest-snapshot-namespace"
+	GuestSnapshotNameLabel      = "velero-plugin-for-vsphere/guest-snapshot-name"
+	// Label of a BackupRepository which records the namespace of the BackupRepositoryClaim it was created for, i.e. the
+	// Velero namespace for the claims of the cluster itself, or a Supervisor namespace for the claims of a Guest Cluster
+	BackupRepositoryClaimNamespaceLabel = "velero-plugin-for-vsphere/backup-repository-claim-namespace"
+	// Label of the Secrets created by the plugin to hold the credentials of the backup repositories, which are deleted
+	// once no BackupRepository or BackupRepositoryClaim references them
+	RepositoryCredentialLabel = "velero-plugin-for-vsphere/repository-credential"
+	// Label of a completed Upload whose local snapshot is kept as the base of the next incremental upload of the
+	// volume, whose ID is the value of the label
+	IncrementalBaseVolumeLabel = "velero-plugin-for-vsphere/incremental-base-volume"
+)
+
+// Annotation of an encrypted BackupRepository, which records the time the snapshots written to the repository before
+// the encryption was enabled were recorded, so that they can still be restored
+const UnencryptedSnapshotsRecordedAnnotation = "velero-plugin-for-vsphere/unencrypted-snapshots-recorded"
+
+// The annotations of a Velero backup which record the uploads of its snapshots, tracked by the backup-driver once
+// Velero has completed the backup. The status is InProgress until all the uploads are done, then Completed, or
+// PartiallyFailed if an upload failed. The progress is the number of uploaded snapshots out of the snapshots of the backup.
+const (
+	BackupUploadStatusAnnotation   = "velero-plugin-for-vsphere/upload-status"
+	BackupUploadProgressAnnotation = "velero-plugin-for-vsphere/upload-progress"
+
+	BackupUploadStatusInProgress      = "InProgress"
+	BackupUploadStatusCompleted       = "Completed"
+	BackupUploadStatusPartiallyFailed = "PartiallyFailed"
 )
 
+// The ConfigMap, in the velero namespace, which records the time the backup-driver started tracking the uploads of the
+// backups, so that the backups completed before, e.g. before the upgrade to a backup-driver which tracks the uploads,
+// are left alone. The time is RFC3339 formatted.
+const (
+	BackupUploadTrackingConfigMap = "velero-vsphere-plugin-backup-upload-tracking"
+	BackupUploadTrackingSinceKey  = "since"
+)
+
+// The in-place restore of the volumes of the existing PVCs, requested with the label on the restore, e.g.
+// velero restore create --from-backup <backup> --labels velero-plugin-for-vsphere/restore-in-place=true
+const (
+	RestoreInPlaceLabel = "velero-plugin-for-vsphere/restore-in-place"
+	// How long to wait for the volume of an existing PVC to be detached before failing its in-place restore
+	InPlaceRestoreDetachTimeout = 5 * time.Minute
+	// Annotation of a Deployment or a StatefulSet scaled down for the in-place restore of a PVC, which records its
+	// replicas before the restore until it is scaled back up
+	InPlaceRestoreReplicasAnnotation = "velero-plugin-for-vsphere/in-place-restore-replicas"
+)
+
+const VSphereCSIDriverName = "csi.vsphere.vmware.com"
+
 const (
 	RetryInterval = 5
 	RetryMaximum  = 5
@@ -327,6 +445,7 @@ const (
 	SnapshotParamBackupName       = "BackupName"
 	SnapshotParamSvcSnapshotName  = "SvcSnapshotName"
 	SnapshotParamBackupRepository = "BackupRepository"
+	SnapshotParamSnapshotRef      = "SnapshotRef"
 )
 
 // These label keys are used to identify configMap used for storage class mapping, format:
@@ -346,6 +465,40 @@ const (
 	EmptyStorageClass = "com.vmware.cnsdp.emptystorageclass"
 )
 
+// The annotations of a plugin config ConfigMap, e.g. a storage class mapping ConfigMap, which scope it to the PVCs
+// restored to the namespaces, comma separated, and to the PVCs whose labels match the label selector, in the format
+// of kubectl --selector.
+const (
+	PluginConfigNamespacesAnnotation  = "velero-plugin-for-vsphere/namespaces"
+	PluginConfigPVCSelectorAnnotation = "velero-plugin-for-vsphere/pvc-selector"
+)
+
+// The PVCs whose volumes are snapshotted in the backups are selected with the annotation of the PVCs, which takes
+// precedence, and with the ConfigMap in the velero namespace with the labels:
+// velero.io/plugin-config: ""
+// velero-plugin-for-vsphere/snapshot-filter: BackupItemAction
+const (
+	// The PVCs annotated with true are not snapshotted, the PVCs annotated with false are snapshotted regardless of the ConfigMap
+	SkipSnapshotAnnotation     = "velero-plugin-for-vsphere/skip-snapshot"
+	PluginKindBackupItemAction = "BackupItemAction"
+	SnapshotFilterLabelKey     = "velero-plugin-for-vsphere/snapshot-filter"
+	// The keys of the ConfigMap. The storage classes are comma separated, the PVCs without a storage class are
+	// matched by EmptyStorageClass. The label selectors are in the format of kubectl --selector.
+	SnapshotFilterIncludeStorageClasses = "includeStorageClasses"
+	SnapshotFilterExcludeStorageClasses = "excludeStorageClasses"
+	SnapshotFilterIncludeLabelSelector  = "includeLabelSelector"
+	SnapshotFilterExcludeLabelSelector  = "excludeLabelSelector"
+)
+
+// The restored PVCs are transformed as configured by the ConfigMaps in the velero namespace with the labels below,
+// which may be scoped with the plugin config annotations. The transformation is a YAML document in the data key.
+// velero.io/plugin-config: ""
+// velero-plugin-for-vsphere/pvc-transformation: RestoreItemAction
+const (
+	PVCTransformationLabelKey = "velero-plugin-for-vsphere/pvc-transformation"
+	PVCTransformationDataKey  = "transformation"
+)
+
 const (
 	DefaultRetryIntervalStart = time.Second
 	DefaultRetryIntervalMax   = 5 * time.Minute
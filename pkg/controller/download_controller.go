@@ -27,11 +27,14 @@ import (
 	backupdriverapi "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
 	pluginv1api "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/datamover/v1alpha1"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backuprepository"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/checksum"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/dataMover"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/encryption"
 	pluginv1client "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/datamover/v1alpha1"
 	informers "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/informers/externalversions/datamover/v1alpha1"
 	listers "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/listers/datamover/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/metrics"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -41,6 +44,8 @@ import (
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/utils/clock"
+	"math"
+	"strings"
 	"time"
 )
 
@@ -54,6 +59,8 @@ type downloadController struct {
 	dataMover           *dataMover.DataMover
 	clock               clock.Clock
 	processDownloadFunc func(*pluginv1api.Download) error
+	metrics             *metrics.ServerMetrics
+	maxRetries          int
 }
 
 func NewDownloadController(
@@ -63,6 +70,8 @@ func NewDownloadController(
 	kubeClient kubernetes.Interface,
 	dataMover *dataMover.DataMover,
 	nodeName string,
+	serverMetrics *metrics.ServerMetrics,
+	maxRetries int,
 ) Interface {
 	c := &downloadController{
 		genericController: newGenericController("download", logger),
@@ -72,10 +81,12 @@ func NewDownloadController(
 		nodeName:          nodeName,
 		dataMover:         dataMover,
 		clock:             &clock.RealClock{},
+		metrics:           serverMetrics,
+		maxRetries:        maxRetries,
 	}
 
 	c.syncHandler = c.processDownloadItem
-	c.retryHandler = c.reEnqueueHandler
+	c.retryHandler = c.exponentialBackoffHandler
 	c.cacheSyncWaiters = append(
 		c.cacheSyncWaiters,
 		downloadInformer.Informer().HasSynced,
@@ -98,9 +109,13 @@ func (c *downloadController) enqueueDownloadItem(obj interface{}) {
 	log := loggerForDownload(c.logger, req)
 
 	switch req.Status.Phase {
-	case "", pluginv1api.DownloadPhaseNew, pluginv1api.DownloadPhaseInProgress, pluginv1api.DownLoadPhaseRetry:
-		// Process New InProgress and Retry Downloads
-	case pluginv1api.DownloadPhaseCompleted:
+	case "", pluginv1api.DownloadPhaseNew, pluginv1api.DownloadPhaseInProgress, pluginv1api.DownLoadPhaseRetry, pluginv1api.DownloadPhaseCanceling:
+		// Process New InProgress Retry and Canceling Downloads
+	case pluginv1api.DownloadPhaseCanceled:
+		// The download was canceled, nothing to do.
+		log.Debug("The download request was canceled")
+		return
+	case pluginv1api.DownloadPhaseCompleted, pluginv1api.DownloadPhaseFailed:
 		// If Download CR status reaches terminal state, Download CR should be deleted after clean up window
 		now := c.clock.Now()
 		if now.After(req.Status.CompletionTimestamp.Add(constants.DefaultCRCleanUpWindow * time.Hour)) {
@@ -112,7 +127,16 @@ func (c *downloadController) enqueueDownloadItem(obj interface{}) {
 		}
 		return
 	default:
-		log.Debug("Download CR is not New or InProgress or Retry, skipping")
+		log.Debug("Download CR is not New or InProgress or Retry or Canceling, skipping")
+		return
+	}
+
+	// Check if the download was canceled and trigger cancellation.
+	if req.Spec.DownloadCancel {
+		err := c.triggerDownloadCancellation(req)
+		if err != nil {
+			log.Error("Received error during download cancellation.")
+		}
 		return
 	}
 
@@ -155,10 +179,21 @@ func (c *downloadController) processDownloadItem(key string) error {
 		// For DownloadPhaseInProgress, the resource lease logic will process the Download if the lease is not held by
 		// another DataManager. If the DataManager holding the lease has died and/or lease has expired the current node
 		// will pick such record in DownloadPhaseInProgress status for processing.
+	case pluginv1api.DownloadPhaseCanceling:
+		log.Infof("The download request is being canceled")
 	default:
 		return nil
 	}
 
+	// Check if the download was canceled and trigger cancellation if needed.
+	if req.Spec.DownloadCancel {
+		err := c.triggerDownloadCancellation(req)
+		if err != nil {
+			log.Error("Received error during download cancellation, skipping.")
+		}
+		return nil
+	}
+
 	leaseLockName := "download-lease." + name
 	// Acquire lease for processing Download.
 	lock := &resourcelock.LeaseLock{
@@ -231,6 +266,35 @@ func (c *downloadController) processDownload(req *pluginv1api.Download) error {
 		return nil
 	}
 
+	if req.Status.Phase == pluginv1api.DownloadPhaseFailed {
+		log.Debug("The status of download CR in kubernetes API server is failed. Skipping it")
+		return nil
+	}
+
+	// The download may have been re-added to the queue before the lister observed its latest backoff
+	if req.Status.Phase == pluginv1api.DownLoadPhaseRetry && req.Status.NextRetryTimestamp != nil {
+		if waitTime := req.Status.NextRetryTimestamp.Sub(c.clock.Now()); waitTime > 0 {
+			log.Debugf("The download will be retried after %v. Skipping it for now", waitTime)
+			key, err := cache.MetaNamespaceKeyFunc(req)
+			if err == nil {
+				c.queue.AddAfter(key, waitTime)
+			}
+			return nil
+		}
+	}
+
+	if req.Status.Phase == pluginv1api.DownloadPhaseCanceling || req.Status.Phase == pluginv1api.DownloadPhaseCanceled {
+		log.Debugf("The status of download CR in kubernetes API server is %s. Skipping it", req.Status.Phase)
+		return nil
+	}
+
+	// The download may have been canceled while it was waiting for the lease
+	if req.Spec.DownloadCancel && req.Status.Phase != pluginv1api.DownloadPhaseInProgress {
+		log.Info("The download was canceled before it was started. Skipping it")
+		_, err = c.patchDownloadByStatusWithRetry(req, pluginv1api.DownloadPhaseCanceled, "The download was canceled before it was started.")
+		return err
+	}
+
 	// update status to InProgress
 	if req.Status.Phase != pluginv1api.DownloadPhaseInProgress {
 		// update status to InProgress
@@ -242,13 +306,16 @@ func (c *downloadController) processDownload(req *pluginv1api.Download) error {
 
 	peID, err := astrolabe.NewProtectedEntityIDFromString(req.Spec.SnapshotID)
 	if err != nil {
+		// An invalid snapshot ID will never succeed, fail the download right away instead of retrying it
 		errMsg := fmt.Sprintf("Failed to get PEID from SnapshotID, %v. %v", req.Spec.SnapshotID, errors.WithStack(err))
-		_, err = c.patchDownloadByStatusWithRetry(req, pluginv1api.DownLoadPhaseRetry, errMsg)
+		_, err = c.patchDownloadByStatusWithRetry(req, pluginv1api.DownloadPhaseFailed, errMsg)
 		if err != nil {
 			errMsg = fmt.Sprintf("%v. %v", errMsg, errors.WithStack(err))
+			log.Error(errMsg)
+			return errors.New(errMsg)
 		}
 		log.Error(errMsg)
-		return errors.New(errMsg)
+		return nil
 	}
 
 	// Add Copy Options
@@ -262,11 +329,25 @@ func (c *downloadController) processDownload(req *pluginv1api.Download) error {
 		targetPEID, err = astrolabe.NewProtectedEntityIDFromString(req.Spec.ProtectedEntityID)
 		if err != nil {
 			errMsg := fmt.Sprintf("failed to create target PEID from string %s: %v", req.Spec.ProtectedEntityID, errors.WithStack(err))
+			_, err = c.patchDownloadByStatusWithRetry(req, pluginv1api.DownloadPhaseFailed, errMsg)
+			if err != nil {
+				errMsg = fmt.Sprintf("%v. %v", errMsg, errors.WithStack(err))
+				log.Error(errMsg)
+				return errors.New(errMsg)
+			}
 			log.Error(errMsg)
-			return errors.New(errMsg)
+			return nil
 		}
 	}
 	log.Infof("Copy options: %v, source PEID: %s, target PEID: %s", options, peID.String(), targetPEID.String())
+	// Report the progress of data movement on the Download CR
+	c.dataMover.RegisterProgressReporter(peID, c.downloadProgressReporter(req))
+	defer c.dataMover.UnregisterProgressReporter(peID)
+	defer c.dataMover.UnregisterOngoingDownload(req.Name)
+
+	c.metrics.RegisterDownloadAttempt(c.nodeName, req.Status.RetryCount > 0)
+	downloadStartTime := c.clock.Now()
+
 	var returnPeId astrolabe.ProtectedEntityID
 	if req.Spec.BackupRepositoryName != "" && req.Spec.BackupRepositoryName != constants.WithoutBackupRepository {
 		var backupRepositoryCR *backupdriverapi.BackupRepository
@@ -275,14 +356,37 @@ func (c *downloadController) processDownload(req *pluginv1api.Download) error {
 			log.WithError(err).Errorf("Failed to get BackupRepository from BackupRepositoryName %s", req.Spec.BackupRepositoryName)
 			return err
 		}
-		returnPeId, err = c.dataMover.CopyFromRepoWithBackupRepository(peID, targetPEID, backupRepositoryCR, options)
+		returnPeId, err = c.dataMover.CopyFromRepoWithBackupRepository(req.Name, peID, targetPEID, backupRepositoryCR, options)
 	} else {
-		returnPeId, err = c.dataMover.CopyFromRepo(peID, targetPEID, options)
+		returnPeId, err = c.dataMover.CopyFromRepo(req.Name, peID, targetPEID, options)
 	}
 
 	if err != nil {
+		// Check if the request was canceled.
+		if errors.Is(err, context.Canceled) {
+			log.Infof("The download of PE %v was canceled.", peID.String())
+			_, err = c.patchDownloadByStatusWithRetry(req, pluginv1api.DownloadPhaseCanceled, "The download was canceled.")
+			if err != nil {
+				return err
+			}
+			log.Infof("Download Cancellation complete.")
+			return nil
+		}
+		c.metrics.RegisterDownloadFailed(c.nodeName)
+		newPhase := pluginv1api.DownLoadPhaseRetry
 		errMsg := fmt.Sprintf("Failed to download snapshot, %v, from durable object storage. %v", peID.String(), errors.WithStack(err))
-		_, err = c.patchDownloadByStatusWithRetry(req, pluginv1api.DownLoadPhaseRetry, errMsg)
+		if encryption.IsKeyNotFound(err) {
+			// Retrying does not help until the key is restored to the Secret of the BackupRepository
+			newPhase = pluginv1api.DownloadPhaseFailed
+			errMsg = fmt.Sprintf("Failed to download snapshot, %v, from durable object storage. The snapshot is encrypted with a key which is not available: %v",
+				peID.String(), err)
+		} else if checksum.IsMismatch(err) {
+			// The snapshot is corrupted in the repository, retrying would fail the same way
+			newPhase = pluginv1api.DownloadPhaseFailed
+			errMsg = fmt.Sprintf("Failed to download snapshot, %v, from durable object storage. The snapshot does not match its checksums: %v",
+				peID.String(), err)
+		}
+		_, err = c.patchDownloadByStatusWithRetry(req, newPhase, errMsg)
 		if err != nil {
 			errMsg = fmt.Sprintf("%v. %v", errMsg, errors.WithStack(err))
 		}
@@ -290,6 +394,8 @@ func (c *downloadController) processDownload(req *pluginv1api.Download) error {
 		return errors.New(errMsg)
 	}
 
+	c.metrics.RegisterDownloadSuccess(c.nodeName, c.clock.Since(downloadStartTime).Seconds())
+
 	var msg string
 	if options == astrolabe.AllocateNewObject {
 		msg = fmt.Sprintf("A new volume %s was just created from the call to CopyFromRepo", returnPeId.String())
@@ -347,6 +453,30 @@ func (c *downloadController) patchDownload(req *pluginv1api.Download, mutate fun
 	return req, nil
 }
 
+// downloadProgressReporter returns a ProgressReporter which patches the progress of the given Download.
+// Failures are only logged as the progress is informational and will be refreshed by the next report.
+func (c *downloadController) downloadProgressReporter(req *pluginv1api.Download) dataMover.ProgressReporter {
+	progressReq := req.DeepCopy()
+	var lastBytesDone int64
+	return func(totalBytes int64, bytesDone int64) {
+		log := loggerForDownload(c.logger, progressReq)
+		if bytesDone > lastBytesDone {
+			c.metrics.AddDownloadBytes(c.nodeName, bytesDone-lastBytesDone)
+		}
+		lastBytesDone = bytesDone
+		updatedDownload, err := c.patchDownload(progressReq.DeepCopy(), func(r *pluginv1api.Download) {
+			r.Status.Progress.TotalBytes = totalBytes
+			r.Status.Progress.BytesDone = bytesDone
+		})
+		if err != nil {
+			log.WithError(err).Warnf("Failed to update the progress of Download to %d/%d bytes", bytesDone, totalBytes)
+			return
+		}
+		log.Debugf("Download progress updated to %d/%d bytes", bytesDone, totalBytes)
+		progressReq = updatedDownload
+	}
+}
+
 func (c *downloadController) patchDownloadByStatusWithRetry(req *pluginv1api.Download, newPhase pluginv1api.DownloadPhase, msg string) (*pluginv1api.Download, error) {
 	var updatedDownload *pluginv1api.Download
 	var err error
@@ -373,6 +503,13 @@ func (c *downloadController) patchDownloadByStatus(req *pluginv1api.Download, ne
 
 	var err error
 
+	// Stop retrying the download once the retry limit is reached
+	if newPhase == pluginv1api.DownLoadPhaseRetry && c.maxRetries > 0 && req.Status.RetryCount >= int32(c.maxRetries) {
+		log.Warningf("The download failed after %d retries, it will not be retried anymore", req.Status.RetryCount)
+		newPhase = pluginv1api.DownloadPhaseFailed
+		msg = fmt.Sprintf("%s. The download failed after %d retries.", strings.TrimSuffix(msg, "."), req.Status.RetryCount)
+	}
+
 	switch newPhase {
 	case pluginv1api.DownloadPhaseCompleted:
 		// in the status of DownloadPhaseCompleted, use the msg param to pass the new volume id
@@ -383,21 +520,25 @@ func (c *downloadController) patchDownloadByStatus(req *pluginv1api.Download, ne
 			r.Status.VolumeID = msg
 		})
 	case pluginv1api.DownLoadPhaseRetry:
-		if req.Status.RetryCount > constants.DOWNLOAD_MAX_RETRY {
-			log.Infof("Number of retry for download %s exceeds maximum limit, mark this download as DownloadPhaseFailed", req.Name)
-			req, err = c.patchDownload(req, func(r *pluginv1api.Download) {
-				r.Status.Phase = pluginv1api.DownloadPhaseFailed
-				r.Status.CompletionTimestamp = &metav1.Time{Time: c.clock.Now()}
-				r.Status.Message = msg
-			})
-		} else {
-			req, err = c.patchDownload(req, func(r *pluginv1api.Download) {
-				r.Status.Phase = newPhase
-				r.Status.NextRetryTimestamp = &metav1.Time{Time: c.clock.Now().Add(constants.DOWNLOAD_BACKOFF * time.Minute)}
-				r.Status.RetryCount = r.Status.RetryCount + 1
-				r.Status.Message = msg
-			})
-		}
+		req, err = c.patchDownload(req, func(r *pluginv1api.Download) {
+			r.Status.Phase = newPhase
+			r.Status.Message = msg
+			r.Status.RetryCount = r.Status.RetryCount + 1
+			log.Debugf("Retry for %d times", r.Status.RetryCount)
+			currentBackOff := math.Exp2(float64(r.Status.RetryCount - 1))
+			if currentBackOff >= constants.DOWNLOAD_MAX_BACKOFF {
+				currentBackOff = constants.DOWNLOAD_MAX_BACKOFF
+			}
+			r.Status.CurrentBackOff = int32(currentBackOff)
+			r.Status.NextRetryTimestamp = &metav1.Time{Time: c.clock.Now().Add(time.Duration(currentBackOff) * time.Minute)}
+		})
+	case pluginv1api.DownloadPhaseFailed:
+		req, err = c.patchDownload(req, func(r *pluginv1api.Download) {
+			r.Status.Phase = newPhase
+			r.Status.CompletionTimestamp = &metav1.Time{Time: c.clock.Now()}
+			r.Status.Message = msg
+			r.Status.NextRetryTimestamp = nil
+		})
 	case pluginv1api.DownloadPhaseInProgress:
 		req, err = c.patchDownload(req, func(r *pluginv1api.Download) {
 			if r.Status.Phase == pluginv1api.DownloadPhaseNew {
@@ -407,6 +548,17 @@ func (c *downloadController) patchDownloadByStatus(req *pluginv1api.Download, ne
 			r.Status.Phase = newPhase
 			r.Status.ProcessingNode = c.nodeName
 		})
+	case pluginv1api.DownloadPhaseCanceled:
+		req, err = c.patchDownload(req, func(r *pluginv1api.Download) {
+			r.Status.Phase = newPhase
+			r.Status.CompletionTimestamp = &metav1.Time{Time: c.clock.Now()}
+			r.Status.Message = msg
+		})
+	case pluginv1api.DownloadPhaseCanceling:
+		req, err = c.patchDownload(req, func(r *pluginv1api.Download) {
+			r.Status.Phase = newPhase
+			r.Status.Message = msg
+		})
 	default:
 		err = errors.New("Unexpected download phase")
 	}
@@ -435,10 +587,69 @@ func loggerForDownload(baseLogger logrus.FieldLogger, req *pluginv1api.Download)
 	return log
 }
 
-func (c *downloadController) reEnqueueHandler(key string) error {
+func (c *downloadController) exponentialBackoffHandler(key string) error {
 	log := c.logger.WithField("key", key)
-	log.Debug("Running reEnqueueHandler for re-adding failed download CR")
-	log.Debugf("Re-adding failed download %s to the queue", key)
-	c.queue.AddAfter(key, constants.DOWNLOAD_BACKOFF*time.Minute)
+	log.Debug("Running exponentialBackoffHandler")
+
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		log.WithError(err).Error("Failed to split the key of queue item")
+		c.queue.Forget(key)
+		return nil
+	}
+
+	req, err := c.downloadLister.Downloads(ns).Get(name)
+	if apierrors.IsNotFound(err) {
+		log.Error("Download is not found")
+		c.queue.Forget(key)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "Failed to get Download")
+	}
+	if req.Status.Phase == pluginv1api.DownloadPhaseFailed {
+		log.Info("The download failed, it will not be re-added to the queue")
+		c.queue.Forget(key)
+		return nil
+	}
+	log.Infof("Re-adding failed download to the queue")
+	c.queue.AddAfter(key, time.Duration(req.Status.CurrentBackOff)*time.Minute)
+	return nil
+}
+
+func (c *downloadController) triggerDownloadCancellation(req *pluginv1api.Download) error {
+	log := loggerForDownload(c.logger, req)
+	cancelPeId, err := astrolabe.NewProtectedEntityIDFromString(req.Spec.SnapshotID)
+	if err != nil {
+		log.Errorf("Error received when processing cancel")
+		return err
+	}
+	if !c.dataMover.IsDownloading(req.Name) {
+		switch req.Status.Phase {
+		case "", pluginv1api.DownloadPhaseNew, pluginv1api.DownLoadPhaseRetry:
+			// No node is moving the data for downloads which are waiting to be processed or retried,
+			// so they can be canceled right away.
+			log.Infof("The download for PE %v is not in progress on any node, marking it as canceled", cancelPeId.String())
+			_, err = c.patchDownloadByStatusWithRetry(req, pluginv1api.DownloadPhaseCanceled, "The download was canceled before it was started.")
+			if err != nil {
+				log.WithError(err).Error("Failed to patch Download to Canceled state")
+				return err
+			}
+		default:
+			log.Infof("Current node: %v is not processing the download, skipping", c.nodeName)
+		}
+		return nil
+	}
+	_, err = c.patchDownloadByStatusWithRetry(req, pluginv1api.DownloadPhaseCanceling, "Canceling on-going download from repository.")
+	if err != nil {
+		log.WithError(err).Error("Failed to patch ongoing Download to Canceling state")
+		return err
+	}
+	log.Infof("Current node: %v is processing the download for PE %v, triggering cancel", c.nodeName, cancelPeId.String())
+	err = c.dataMover.CancelDownload(req.Name)
+	if err != nil {
+		return err
+	}
+	log.Infof("Download cancellation trigger on current node: %v for PE %v is complete.", c.nodeName, cancelPeId.String())
 	return nil
 }
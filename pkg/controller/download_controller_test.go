@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/agiledragon/gomonkey"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -100,6 +101,8 @@ func TestPatchDownloadByStatus(t *testing.T) {
 		expectedPhase v1.DownloadPhase
 		download      *v1.Download
 		msg           string
+		expectedMsg   string
+		maxRetries    int
 	}{
 		{
 			name:     "Test New to Inprogress",
@@ -125,15 +128,45 @@ func TestPatchDownloadByStatus(t *testing.T) {
 			download: defaultDownload().Phase(v1.DownloadPhaseInProgress).Retry(constants.MIN_RETRY).Result(),
 			msg:      "Failed to download snapshot, ivd:1234:1234, from durable object storage.",
 		},
+		{
+			name:          "Test retry below maximum retry count",
+			key:           "velero/download-1",
+			oldPhase:      v1.DownloadPhaseInProgress,
+			newPhase:      v1.DownLoadPhaseRetry,
+			expectedPhase: v1.DownLoadPhaseRetry,
+			download:      defaultDownload().Phase(v1.DownloadPhaseInProgress).Retry(constants.DOWNLOAD_MAX_RETRY - 1).Result(),
+			msg:           "Failed to download snapshot, ivd:1234:1234, from durable object storage.",
+			maxRetries:    constants.DOWNLOAD_MAX_RETRY,
+		},
 		{
 			name:          "Test maximum retry count",
 			key:           "velero/download-1",
 			oldPhase:      v1.DownloadPhaseInProgress,
 			newPhase:      v1.DownLoadPhaseRetry,
 			expectedPhase: v1.DownloadPhaseFailed,
+			download:      defaultDownload().Phase(v1.DownloadPhaseInProgress).Retry(constants.DOWNLOAD_MAX_RETRY).Result(),
+			msg:           "Failed to download snapshot, ivd:1234:1234, from durable object storage.",
+			expectedMsg:   fmt.Sprintf("Failed to download snapshot, ivd:1234:1234, from durable object storage. The download failed after %d retries.", constants.DOWNLOAD_MAX_RETRY),
+			maxRetries:    constants.DOWNLOAD_MAX_RETRY,
+		},
+		{
+			name:          "Test unlimited retries",
+			key:           "velero/download-1",
+			oldPhase:      v1.DownloadPhaseInProgress,
+			newPhase:      v1.DownLoadPhaseRetry,
+			expectedPhase: v1.DownLoadPhaseRetry,
 			download:      defaultDownload().Phase(v1.DownloadPhaseInProgress).Retry(constants.DOWNLOAD_MAX_RETRY + 1).Result(),
 			msg:           "Failed to download snapshot, ivd:1234:1234, from durable object storage.",
 		},
+		{
+			name:          "Test InProgress to Failed",
+			key:           "velero/download-1",
+			oldPhase:      v1.DownloadPhaseInProgress,
+			newPhase:      v1.DownloadPhaseFailed,
+			expectedPhase: v1.DownloadPhaseFailed,
+			download:      defaultDownload().Phase(v1.DownloadPhaseInProgress).Retry(constants.MIN_RETRY).Result(),
+			msg:           "Failed to get PEID from SnapshotID, ivd:invalid.",
+		},
 	}
 
 	for _, test := range tests {
@@ -153,6 +186,7 @@ func TestPatchDownloadByStatus(t *testing.T) {
 				nodeName:          "download-test",
 				clock:             &clock.RealClock{},
 				dataMover:         &dataMover.DataMover{},
+				maxRetries:        test.maxRetries,
 			}
 
 			if test.download != nil {
@@ -243,19 +277,22 @@ func TestPatchDownloadByStatus(t *testing.T) {
 			}
 			res, err := c.patchDownloadByStatus(test.download, test.newPhase, test.msg)
 			require.NoError(t, err)
-			require.Equal(t, test.msg, res.Status.Message)
+			if test.expectedMsg == "" {
+				test.expectedMsg = test.msg
+			}
+			require.Equal(t, test.expectedMsg, res.Status.Message)
 			if test.oldPhase == v1.DownloadPhaseNew {
 				require.Equal(t, int32(constants.MIN_RETRY), res.Status.RetryCount)
 			}
-			if test.newPhase == v1.DownLoadPhaseRetry {
-				newRetry := res.Status.RetryCount
-				if newRetry > constants.DOWNLOAD_MAX_RETRY {
-					test.newPhase = test.expectedPhase
-				} else {
-					require.Equal(t, oldRetry+1, newRetry)
-				}
+			if test.expectedPhase == "" {
+				test.expectedPhase = test.newPhase
+			}
+			if test.expectedPhase == v1.DownLoadPhaseRetry {
+				require.Equal(t, oldRetry+1, res.Status.RetryCount)
+				require.Greater(t, res.Status.CurrentBackOff, int32(0))
+				require.LessOrEqual(t, res.Status.CurrentBackOff, int32(constants.DOWNLOAD_MAX_BACKOFF))
 			}
-			require.Equal(t, test.newPhase, res.Status.Phase)
+			require.Equal(t, test.expectedPhase, res.Status.Phase)
 		})
 	}
 }
@@ -279,8 +316,8 @@ func TestProcessedDownloadItem(t *testing.T) {
 			name:          "Invalid peID should fail download ",
 			key:           "velero/download-1",
 			download:      defaultDownload().Phase(v1.DownloadPhaseNew).SnapshotID("ivd:invalid").Retry(constants.MIN_RETRY).Result(),
-			expectedPhase: v1.DownLoadPhaseRetry,
-			expectedErr:   errors.New("Failed to get PEID from SnapshotID, ivd:invalid"),
+			expectedPhase: v1.DownloadPhaseFailed,
+			expectedErr:   nil,
 		},
 		{
 			name:          "Download fail when copying from remote repository",
@@ -310,7 +347,7 @@ func TestProcessedDownloadItem(t *testing.T) {
 			}
 			require.NoError(t, sharedInformers.Datamover().V1alpha1().Downloads().Informer().GetStore().Add(test.download))
 
-			patches := gomonkey.ApplyMethod(reflect.TypeOf(c.dataMover), "CopyFromRepo", func(_ *dataMover.DataMover, _ astrolabe.ProtectedEntityID,
+			patches := gomonkey.ApplyMethod(reflect.TypeOf(c.dataMover), "CopyFromRepo", func(_ *dataMover.DataMover, _ string, _ astrolabe.ProtectedEntityID,
 				_ astrolabe.ProtectedEntityID, _ astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntityID, error) {
 				return astrolabe.ProtectedEntityID{}, test.expectedErr
 			})
@@ -21,9 +21,11 @@ import (
 	"fmt"
 	backupdriverapi "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backuprepository"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/checksum"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -34,10 +36,12 @@ import (
 	pluginv1client "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/datamover/v1alpha1"
 	informers "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/informers/externalversions/datamover/v1alpha1"
 	listers "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/listers/datamover/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/metrics"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/snapshotmgr"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/leaderelection"
@@ -57,6 +61,9 @@ type uploadController struct {
 	clock             clock.Clock
 	processUploadFunc func(*pluginv1api.Upload) error
 	externalDataMgr   bool
+	metrics           *metrics.ServerMetrics
+	// maxRetries is the number of retries of a failed upload before it is marked as Failed, 0 means unlimited.
+	maxRetries int
 }
 
 func NewUploadController(
@@ -68,6 +75,8 @@ func NewUploadController(
 	snapMgr *snapshotmgr.SnapshotManager,
 	nodeName string,
 	externalDataMgr bool,
+	serverMetrics *metrics.ServerMetrics,
+	maxRetries int,
 ) Interface {
 	c := &uploadController{
 		genericController: newGenericController("upload", logger),
@@ -79,6 +88,8 @@ func NewUploadController(
 		snapMgr:           snapMgr,
 		clock:             &clock.RealClock{},
 		externalDataMgr:   externalDataMgr,
+		metrics:           serverMetrics,
+		maxRetries:        maxRetries,
 	}
 
 	c.syncHandler = c.processUploadItem
@@ -112,6 +123,20 @@ func (c *uploadController) enqueueUploadItem(obj interface{}) {
 		// The upload was canceled, nothing to do.
 		log.Debug("The upload request was canceled")
 		return
+	case pluginv1api.UploadPhaseFailed:
+		// The upload will not be retried anymore unless it is canceled or re-armed manually.
+		if req.Spec.UploadCancel {
+			if err := c.triggerUploadCancellation(req); err != nil {
+				log.Error("Received error during upload cancellation.")
+			}
+		} else if req.Spec.UploadRetry {
+			if _, err := c.patchUploadByStatusWithRetry(req, pluginv1api.UploadPhaseNew, "The failed upload was re-armed."); err != nil {
+				log.WithError(err).Error("Failed to re-arm the failed upload")
+			}
+		} else {
+			log.Debug("The upload request has failed")
+		}
+		return
 	case pluginv1api.UploadPhaseCompleted:
 		// If Upload CR status reaches terminal state, Upload CR should be deleted after clean up window
 		now := c.clock.Now()
@@ -306,6 +331,18 @@ func (c *uploadController) processUpload(req *pluginv1api.Upload) error {
 		return nil
 	}
 
+	if req.Status.Phase == pluginv1api.UploadPhaseFailed {
+		log.WithField("phase", req.Status.Phase).WithField("generation", req.Generation).Debug("The status of upload CR in kubernetes API server is failed. Skipping it")
+		return nil
+	}
+
+	// The upload may have been canceled while it was waiting for the lease
+	if req.Spec.UploadCancel && req.Status.Phase != pluginv1api.UploadPhaseInProgress && req.Status.Phase != pluginv1api.UploadPhaseCleanupFailed {
+		log.Info("The upload was canceled before it was started. Skipping it")
+		_, err = c.patchUploadByStatusWithRetry(req, pluginv1api.UploadPhaseCanceled, "The upload was canceled before it was started.")
+		return err
+	}
+
 	// update status to InProgress
 	if req.Status.Phase != pluginv1api.UploadPhaseInProgress && req.Status.Phase != pluginv1api.UploadPhaseCleanupFailed {
 		// update status to InProgress
@@ -348,6 +385,15 @@ func (c *uploadController) processUpload(req *pluginv1api.Upload) error {
 		}
 	}
 
+	// Report the progress of data movement on the Upload CR
+	c.dataMover.RegisterProgressReporter(peID, c.uploadProgressReporter(req))
+	defer c.dataMover.UnregisterProgressReporter(peID)
+	c.dataMover.RegisterStoredBytesReporter(peID, c.uploadStoredBytesReporter(req))
+	defer c.dataMover.UnregisterStoredBytesReporter(peID)
+
+	c.metrics.RegisterUploadAttempt(c.nodeName, req.Status.RetryCount > 0)
+	uploadStartTime := c.clock.Now()
+
 	if req.Spec.BackupRepositoryName != "" && req.Spec.BackupRepositoryName != constants.WithoutBackupRepository {
 		var backupRepositoryCR *backupdriverapi.BackupRepository
 		backupRepositoryCR, err = backuprepository.GetBackupRepositoryFromBackupRepositoryName(req.Spec.BackupRepositoryName)
@@ -371,7 +417,13 @@ func (c *uploadController) processUpload(req *pluginv1api.Upload) error {
 			log.Infof("Upload Cancellation complete.")
 			return nil
 		} else {
+			c.metrics.RegisterUploadFailed(c.nodeName)
 			errMsg := fmt.Sprintf("Failed to upload snapshot, %v, to durable object storage. %v", peID.String(), errors.WithStack(err))
+			if checksum.IsMismatch(err) {
+				// The local snapshot is kept, so that the snapshot is uploaded again on the retry
+				errMsg = fmt.Sprintf("Failed to upload snapshot, %v, to durable object storage. The uploaded snapshot does not match its checksums: %v",
+					peID.String(), err)
+			}
 			_, err = c.patchUploadByStatusWithRetry(req, pluginv1api.UploadPhaseUploadError, errMsg)
 			if err != nil {
 				errMsg = fmt.Sprintf("%v. %v", errMsg, errors.WithStack(err))
@@ -381,11 +433,29 @@ func (c *uploadController) processUpload(req *pluginv1api.Upload) error {
 		}
 	}
 
+	c.metrics.RegisterUploadSuccess(c.nodeName, c.clock.Since(uploadStartTime).Seconds())
+
 	// Unregister on-going upload
 	c.dataMover.UnregisterOngoingUpload(peID)
 
-	// Call snapshot manager API to cleanup the local snapshot
-	err = c.snapMgr.DeleteLocalSnapshot(peID)
+	if c.dataMover.IsIncrementalUploadEnabled() {
+		// Keep the local snapshot as the base of the next incremental upload of the volume
+		log.Infof("Keeping the local snapshot %s as the base of the next incremental upload", peID.String())
+		var labeled *pluginv1api.Upload
+		labeled, err = c.patchUpload(req, func(r *pluginv1api.Upload) {
+			if r.Labels == nil {
+				r.Labels = make(map[string]string)
+			}
+			r.Labels[constants.IncrementalBaseVolumeLabel] = peID.GetID()
+		})
+		if err == nil {
+			req = labeled
+			c.releaseSupersededSnapshots(req, peID.GetID())
+		}
+	} else {
+		// Call snapshot manager API to cleanup the local snapshot
+		err = c.snapMgr.DeleteLocalSnapshot(peID)
+	}
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to clean up local snapshot after uploading snapshot, %v. %v", peID.String(), errors.WithStack(err))
 		// TODO: Change the upload CRD definition to add one more phase, such as, UploadPhaseFailedLocalCleanup
@@ -408,11 +478,92 @@ func (c *uploadController) processUpload(req *pluginv1api.Upload) error {
 	return nil
 }
 
+// releaseSupersededSnapshots deletes the local snapshots kept as the bases of the earlier uploads of the volume, once a
+// later snapshot of the volume has been uploaded, whichever the repositories of the uploads. Failures are only logged,
+// the snapshots are released again by the next upload of the volume.
+func (c *uploadController) releaseSupersededSnapshots(req *pluginv1api.Upload, volumeID string) {
+	log := loggerForUpload(c.logger, req)
+	uploads, err := c.uploadLister.Uploads(req.Namespace).List(labels.SelectorFromSet(labels.Set{constants.IncrementalBaseVolumeLabel: volumeID}))
+	if err != nil {
+		log.WithError(err).Warnf("Failed to list the earlier uploads of the volume %s", volumeID)
+		return
+	}
+	for _, upload := range getSupersededUploads(req, uploads) {
+		peID, err := astrolabe.NewProtectedEntityIDFromString(upload.Spec.SnapshotID)
+		if err == nil {
+			err = c.snapMgr.DeleteLocalSnapshot(peID)
+		}
+		if err != nil {
+			log.WithError(err).Warnf("Failed to delete the superseded local snapshot %s", upload.Spec.SnapshotID)
+			continue
+		}
+		log.Infof("Deleted the superseded local snapshot %s", upload.Spec.SnapshotID)
+		if _, err := c.patchUpload(upload.DeepCopy(), func(r *pluginv1api.Upload) {
+			delete(r.Labels, constants.IncrementalBaseVolumeLabel)
+		}); err != nil {
+			log.WithError(err).Warnf("Failed to unlabel the Upload %s", upload.Name)
+		}
+	}
+}
+
+// getSupersededUploads returns the completed uploads among the uploads of the volume, which were created before the
+// upload. The in-flight uploads keep their local snapshots.
+func getSupersededUploads(req *pluginv1api.Upload, uploads []*pluginv1api.Upload) []*pluginv1api.Upload {
+	var superseded []*pluginv1api.Upload
+	for _, upload := range uploads {
+		if upload.Name != req.Name && upload.Status.Phase == pluginv1api.UploadPhaseCompleted &&
+			upload.CreationTimestamp.Before(&req.CreationTimestamp) {
+			superseded = append(superseded, upload)
+		}
+	}
+	return superseded
+}
+
 func (c *uploadController) patchUpload(req *pluginv1api.Upload, mutate func(*pluginv1api.Upload)) (*pluginv1api.Upload, error) {
 	log := loggerForUpload(c.logger, req)
 	return utils.PatchUpload(req, mutate, c.uploadClient.Uploads(req.Namespace), log)
 }
 
+// uploadProgressReporter returns a ProgressReporter which patches the progress of the given Upload.
+// Failures are only logged as the progress is informational and will be refreshed by the next report.
+func (c *uploadController) uploadProgressReporter(req *pluginv1api.Upload) dataMover.ProgressReporter {
+	progressReq := req.DeepCopy()
+	var lastBytesDone int64
+	return func(totalBytes int64, bytesDone int64) {
+		log := loggerForUpload(c.logger, progressReq)
+		if bytesDone > lastBytesDone {
+			c.metrics.AddUploadBytes(c.nodeName, bytesDone-lastBytesDone)
+		}
+		lastBytesDone = bytesDone
+		updatedUpload, err := c.patchUpload(progressReq.DeepCopy(), func(r *pluginv1api.Upload) {
+			r.Status.Progress.TotalBytes = totalBytes
+			r.Status.Progress.BytesDone = bytesDone
+		})
+		if err != nil {
+			log.WithError(err).Warnf("Failed to update the progress of Upload to %d/%d bytes", bytesDone, totalBytes)
+			return
+		}
+		log.Debugf("Upload progress updated to %d/%d bytes", bytesDone, totalBytes)
+		progressReq = updatedUpload
+	}
+}
+
+// uploadStoredBytesReporter returns a StoredBytesReporter which patches the number of bytes stored in the repository
+// for the given Upload. Failures are only logged as the stored bytes are informational.
+func (c *uploadController) uploadStoredBytesReporter(req *pluginv1api.Upload) dataMover.StoredBytesReporter {
+	return func(storedBytes int64) {
+		log := loggerForUpload(c.logger, req)
+		_, err := c.patchUpload(req.DeepCopy(), func(r *pluginv1api.Upload) {
+			r.Status.Progress.StoredBytes = storedBytes
+		})
+		if err != nil {
+			log.WithError(err).Warnf("Failed to update the stored bytes of Upload to %d bytes", storedBytes)
+			return
+		}
+		log.Infof("Upload stored %d bytes in the repository", storedBytes)
+	}
+}
+
 func (c *uploadController) patchUploadByStatusWithRetry(req *pluginv1api.Upload, newPhase pluginv1api.UploadPhase, msg string) (*pluginv1api.Upload, error) {
 	var updatedUpload *pluginv1api.Upload
 	var err error
@@ -439,6 +590,14 @@ func (c *uploadController) patchUploadByStatus(req *pluginv1api.Upload, newPhase
 
 	var err error
 
+	// Stop retrying the upload once the retry limit is reached
+	if newPhase == pluginv1api.UploadPhaseUploadError && c.maxRetries > 0 && req.Status.RetryCount >= int32(c.maxRetries) {
+		log.Warningf("The upload failed after %d retries, it will not be retried anymore", req.Status.RetryCount)
+		newPhase = pluginv1api.UploadPhaseFailed
+		msg = fmt.Sprintf("%s. The upload failed after %d retries. Set spec.uploadRetry to retry it.", strings.TrimSuffix(msg, "."),
+			req.Status.RetryCount)
+	}
+
 	switch newPhase {
 	case pluginv1api.UploadPhaseCompleted:
 		req, err = c.patchUpload(req, func(r *pluginv1api.Upload) {
@@ -487,6 +646,24 @@ func (c *uploadController) patchUploadByStatus(req *pluginv1api.Upload, newPhase
 			r.Status.CompletionTimestamp = &metav1.Time{Time: c.clock.Now()}
 			r.Status.Message = msg
 		})
+	case pluginv1api.UploadPhaseFailed:
+		req, err = c.patchUpload(req, func(r *pluginv1api.Upload) {
+			r.Status.Phase = newPhase
+			r.Status.CompletionTimestamp = &metav1.Time{Time: c.clock.Now()}
+			r.Status.Message = msg
+			r.Status.NextRetryTimestamp = nil
+		})
+	case pluginv1api.UploadPhaseNew:
+		// Re-arm a failed upload, the retries start over
+		req, err = c.patchUpload(req, func(r *pluginv1api.Upload) {
+			r.Spec.UploadRetry = false
+			r.Status.Phase = newPhase
+			r.Status.Message = msg
+			r.Status.RetryCount = constants.MIN_RETRY
+			r.Status.CurrentBackOff = 0
+			r.Status.NextRetryTimestamp = nil
+			r.Status.CompletionTimestamp = nil
+		})
 	default:
 		err = errors.New("Unexpected upload phase")
 	}
@@ -550,7 +727,19 @@ func (c *uploadController) triggerUploadCancellation(req *pluginv1api.Upload) er
 	}
 	uploadStatus := c.dataMover.IsUploading(cancelPeId)
 	if !uploadStatus {
-		log.Infof("Current node: %v is not processing the upload, skipping", c.nodeName)
+		switch req.Status.Phase {
+		case "", pluginv1api.UploadPhaseNew, pluginv1api.UploadPhaseUploadError, pluginv1api.UploadPhaseFailed:
+			// No node is moving the data for uploads which are waiting to be processed or retried,
+			// so they can be canceled right away.
+			log.Infof("The upload for PE %v is not in progress on any node, marking it as canceled", cancelPeId.String())
+			_, err = c.patchUploadByStatusWithRetry(req, pluginv1api.UploadPhaseCanceled, "The upload was canceled before it was started.")
+			if err != nil {
+				log.WithError(err).Error("Failed to patch Upload to Canceled state")
+				return err
+			}
+		default:
+			log.Infof("Current node: %v is not processing the upload, skipping", c.nodeName)
+		}
 		return nil
 	}
 	_, err = c.patchUploadByStatusWithRetry(req, pluginv1api.UploadPhaseCanceling, "Canceling on-going upload to repository.")
@@ -63,6 +63,11 @@ func TestProcessUploadNonProcessedItems(t *testing.T) {
 			key:    "velero/upload-1",
 			upload: defaultUpload().Phase(v1.UploadPhaseCompleted).Result(),
 		},
+		{
+			name:   "Failed upload is not processed",
+			key:    "velero/upload-1",
+			upload: defaultUpload().Phase(v1.UploadPhaseFailed).Result(),
+		},
 	}
 
 	for _, test := range tests {
@@ -188,10 +193,13 @@ func TestPatchUploadByStatus(t *testing.T) {
 	tests := []struct {
 		name     string
 		key      string
-		oldPhase v1.UploadPhase
-		newPhase v1.UploadPhase
-		upload   *v1.Upload
-		msg      string
+		oldPhase      v1.UploadPhase
+		newPhase      v1.UploadPhase
+		upload        *v1.Upload
+		msg           string
+		maxRetries    int
+		expectedPhase v1.UploadPhase
+		expectedMsg   string
 	}{
 		{
 			name:     "Test New to Inprogress",
@@ -249,6 +257,34 @@ func TestPatchUploadByStatus(t *testing.T) {
 			upload:   defaultUpload().Phase(v1.UploadPhaseUploadError).Result(),
 			msg:      "",
 		},
+		{
+			name:       "Test UploadError below max retries",
+			key:        "velero/upload-1",
+			oldPhase:   v1.UploadPhaseInProgress,
+			newPhase:   v1.UploadPhaseUploadError,
+			upload:     defaultUpload().Phase(v1.UploadPhaseInProgress).Retry(2).Result(),
+			msg:        "Failed to upload snapshot, ivd:1234:1234, to durable object storage.",
+			maxRetries: 3,
+		},
+		{
+			name:          "Test UploadError at max retries to Failed",
+			key:           "velero/upload-1",
+			oldPhase:      v1.UploadPhaseInProgress,
+			newPhase:      v1.UploadPhaseUploadError,
+			upload:        defaultUpload().Phase(v1.UploadPhaseInProgress).Retry(3).Result(),
+			msg:           "Failed to upload snapshot, ivd:1234:1234, to durable object storage.",
+			maxRetries:    3,
+			expectedPhase: v1.UploadPhaseFailed,
+			expectedMsg:   "Failed to upload snapshot, ivd:1234:1234, to durable object storage. The upload failed after 3 retries. Set spec.uploadRetry to retry it.",
+		},
+		{
+			name:     "Test Failed re-armed to New",
+			key:      "velero/upload-1",
+			oldPhase: v1.UploadPhaseFailed,
+			newPhase: v1.UploadPhaseNew,
+			upload:   defaultUpload().Phase(v1.UploadPhaseFailed).Retry(3).UploadRetry(true).Result(),
+			msg:      "The failed upload was re-armed.",
+		},
 	}
 
 	for _, test := range tests {
@@ -269,6 +305,7 @@ func TestPatchUploadByStatus(t *testing.T) {
 				clock:             &clock.RealClock{},
 				dataMover:         &dataMover.DataMover{},
 				snapMgr:           &snapshotmgr.SnapshotManager{},
+				maxRetries:        test.maxRetries,
 			}
 
 			if test.upload != nil {
@@ -299,6 +336,10 @@ func TestPatchUploadByStatus(t *testing.T) {
 					}
 					res.Status.Phase = v1.UploadPhase(phase)
 
+					if uploadRetry, found, err := unstructured.NestedFieldNoCopy(patchMap, "spec", "uploadRetry"); err == nil && found && uploadRetry == nil {
+						res.Spec.UploadRetry = false
+					}
+
 					message, found, err := unstructured.NestedString(patchMap, "status", "message")
 					if err == nil && found {
 						res.Status.Message = message
@@ -367,14 +408,25 @@ func TestPatchUploadByStatus(t *testing.T) {
 			if test.upload.Status.Phase != v1.UploadPhaseNew {
 				oldRetry = test.upload.Status.RetryCount
 			}
+			expectedPhase := test.newPhase
+			if test.expectedPhase != "" {
+				expectedPhase = test.expectedPhase
+			}
+			expectedMsg := test.msg
+			if test.expectedMsg != "" {
+				expectedMsg = test.expectedMsg
+			}
 			res, err := c.patchUploadByStatus(test.upload, test.newPhase, test.msg)
 			require.NoError(t, err)
-			require.Equal(t, test.newPhase, res.Status.Phase)
-			require.Equal(t, test.msg, res.Status.Message)
-			if test.oldPhase == v1.UploadPhaseNew {
+			require.Equal(t, expectedPhase, res.Status.Phase)
+			require.Equal(t, expectedMsg, res.Status.Message)
+			if test.oldPhase == v1.UploadPhaseNew || test.newPhase == v1.UploadPhaseNew {
 				require.Equal(t, int32(constants.MIN_RETRY), res.Status.RetryCount)
 			}
-			if test.newPhase == v1.UploadPhaseUploadError {
+			if test.newPhase == v1.UploadPhaseNew {
+				require.False(t, res.Spec.UploadRetry)
+			}
+			if expectedPhase == v1.UploadPhaseUploadError {
 				newRetry := res.Status.RetryCount
 				require.Equal(t, oldRetry+1, newRetry)
 				require.LessOrEqual(t, res.Status.CurrentBackOff, int32(constants.UPLOAD_MAX_BACKOFF))
@@ -531,4 +583,29 @@ func TestProcessUploadCleanupFailed(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestGetSupersededUploads(t *testing.T) {
+	now := time.Now()
+	newUpload := func(name string, phase v1.UploadPhase, created time.Time) *v1.Upload {
+		upload := builder.ForUpload(constants.DefaultNamespace, name).Phase(phase).SnapshotID("ivd:1234:" + name).Result()
+		upload.CreationTimestamp = metav1.Time{Time: created}
+		return upload
+	}
+	req := newUpload("upload-3", v1.UploadPhaseInProgress, now)
+	uploads := []*v1.Upload{
+		// Completed uploads of the volume to any repository
+		newUpload("upload-1", v1.UploadPhaseCompleted, now.Add(-2*time.Hour)),
+		newUpload("upload-2", v1.UploadPhaseCompleted, now.Add(-time.Hour)),
+		// The upload itself, and a later upload of the volume
+		req,
+		newUpload("upload-4", v1.UploadPhaseCompleted, now.Add(time.Hour)),
+		// An earlier upload which has not completed yet
+		newUpload("upload-5", v1.UploadPhaseUploadError, now.Add(-time.Hour)),
+	}
+
+	var superseded []string
+	for _, upload := range getSupersededUploads(req, uploads) {
+		superseded = append(superseded, upload.Name)
+	}
+	assert.Equal(t, []string{"upload-1", "upload-2"}, superseded)
+}
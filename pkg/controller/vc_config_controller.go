@@ -75,22 +75,21 @@ func (v *vcConfigController) processVcConfigSecretItem(key string) error {
 		return nil
 	}
 	// Retrieve the latest Secret.
-	ivdParams := make(map[string]interface{})
-	err = utils.RetrieveVcConfigSecret(ivdParams, nil, v.logger)
+	vcParams, err := utils.RetrieveVcConfigs(nil, v.logger)
 	if err != nil {
 		v.logger.Errorf("Failed to retrieve the latest vc config secret")
 		return err
 	}
 	v.logger.Debug("Successfully retrieved latest vSphere VC credentials.")
 	if v.dataMover != nil {
-		err = v.dataMover.ReloadDataMoverIvdPetmConfig(ivdParams)
+		err = v.dataMover.ReloadDataMoverIvdPetmConfig(vcParams)
 		if err != nil {
 			v.logger.Errorf("Secret %s/%s Reload on DataMover failed, err: %v", namespace, name, err)
 			return err
 		}
 	}
 	if v.snapMgr != nil {
-		err = v.snapMgr.ReloadSnapshotManagerIvdPetmConfig(ivdParams)
+		err = v.snapMgr.ReloadSnapshotManagerIvdPetmConfig(vcParams)
 		if err != nil {
 			v.logger.Errorf("Secret %s/%s Reload on Snapshot Manager failed, err: %v", namespace, name, err)
 			return err
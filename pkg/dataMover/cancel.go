@@ -0,0 +1,51 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"context"
+	"io"
+
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/decorator"
+)
+
+// cancelableProtectedEntity wraps a source ProtectedEntity so that the data reader handed out to
+// the Copy/Overwrite APIs of astrolabe honors the context passed to GetDataReader.
+type cancelableProtectedEntity struct {
+	astrolabe.ProtectedEntity
+}
+
+func newCancelableProtectedEntity(pe astrolabe.ProtectedEntity) astrolabe.ProtectedEntity {
+	return cancelableProtectedEntity{
+		ProtectedEntity: pe,
+	}
+}
+
+func (this cancelableProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	dataReader, err := this.ProtectedEntity.GetDataReader(ctx)
+	if err != nil || dataReader == nil {
+		return dataReader, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: decorator.NewContextReader(ctx, dataReader),
+		Closer: dataReader,
+	}, nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelDownload(t *testing.T) {
+	dataMover := &DataMover{logger: logrus.New()}
+	// The downloads restoring the same snapshot are canceled independently
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	dataMover.RegisterOngoingDownload("download-1", cancel1)
+	dataMover.RegisterOngoingDownload("download-2", cancel2)
+
+	assert.NoError(t, dataMover.CancelDownload("download-1"))
+	assert.Equal(t, context.Canceled, ctx1.Err())
+	assert.NoError(t, ctx2.Err())
+	assert.False(t, dataMover.IsDownloading("download-1"))
+	assert.True(t, dataMover.IsDownloading("download-2"))
+	assert.Error(t, dataMover.CancelDownload("download-1"))
+
+	dataMover.UnregisterOngoingDownload("download-2")
+	assert.False(t, dataMover.IsDownloading("download-2"))
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/common/vsphere"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/incremental"
+	vim "github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/govmomi/vslm"
+	vslmmethods "github.com/vmware/govmomi/vslm/methods"
+	vslmtypes "github.com/vmware/govmomi/vslm/types"
+)
+
+// changedBlockTracker queries the changed block tracking (CBT) of IVDs, which is not exposed by the IVD
+// ProtectedEntityTypeManager of astrolabe. It holds its own connection to VC, which is closed by close.
+type changedBlockTracker struct {
+	vc     *vsphere.VirtualCenter
+	vsom   *vslm.GlobalObjectManager
+	logger logrus.FieldLogger
+}
+
+func newChangedBlockTracker(ctx context.Context, params map[string]interface{}, logger logrus.FieldLogger) (*changedBlockTracker, error) {
+	vcConfig, err := vsphere.GetVirtualCenterConfigFromParams(params, logger)
+	if err != nil {
+		return nil, err
+	}
+	vc, vsom, _, err := vsphere.GetVirtualCenter(ctx, vcConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &changedBlockTracker{
+		vc:     vc,
+		vsom:   vsom,
+		logger: logger,
+	}, nil
+}
+
+func (this *changedBlockTracker) close(ctx context.Context) {
+	if err := this.vc.Disconnect(ctx); err != nil {
+		this.logger.WithError(err).Warnf("Failed to disconnect from VC after querying changed block tracking")
+	}
+}
+
+// getCapacity returns the capacity of the IVD in bytes, and whether changed block tracking is enabled on it.
+func (this *changedBlockTracker) getCapacity(ctx context.Context, id vim.ID) (int64, bool, error) {
+	vso, err := this.vsom.Retrieve(ctx, id)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "Failed to retrieve IVD %s", id.Id)
+	}
+	enabled := vso.Config.ChangedBlockTrackingEnabled != nil && *vso.Config.ChangedBlockTrackingEnabled
+	return vso.Config.CapacityInMB * 1024 * 1024, enabled, nil
+}
+
+// enable enables changed block tracking on the IVD, which takes effect from the next snapshot on.
+func (this *changedBlockTracker) enable(ctx context.Context, id vim.ID) error {
+	// The GlobalObjectManager of govmomi does not pass the IVD to SetVStorageObjectControlFlags, so call it directly
+	client, err := vslm.NewClient(ctx, this.vc.Client.Client)
+	if err != nil {
+		return err
+	}
+	req := vslmtypes.VslmSetVStorageObjectControlFlags{
+		This:         client.ServiceContent.VStorageObjectManager,
+		Id:           id,
+		ControlFlags: []string{string(vim.VslmVStorageObjectControlFlagEnableChangedBlockTracking)},
+	}
+	if _, err := vslmmethods.VslmSetVStorageObjectControlFlags(ctx, client, &req); err != nil {
+		return errors.Wrapf(err, "Failed to enable changed block tracking on IVD %s", id.Id)
+	}
+	return nil
+}
+
+func (this *changedBlockTracker) getSnapshots(ctx context.Context, id vim.ID) ([]vim.VStorageObjectSnapshotInfoVStorageObjectSnapshot, error) {
+	snapshots, err := this.vsom.RetrieveSnapshotInfo(ctx, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to retrieve the snapshots of IVD %s", id.Id)
+	}
+	return snapshots, nil
+}
+
+// getChangeID returns the change ID of the snapshot, which is empty if changed block tracking was not enabled on the
+// IVD when the snapshot was taken.
+func (this *changedBlockTracker) getChangeID(ctx context.Context, id vim.ID, snapshotID vim.ID) (string, error) {
+	details, err := this.vsom.RetrieveSnapshotDetails(ctx, id, snapshotID)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to retrieve the details of snapshot %s of IVD %s", snapshotID.Id, id.Id)
+	}
+	return details.ChangedBlockTrackingId, nil
+}
+
+// queryChangedExtents returns the extents of the snapshot which changed since the change ID.
+func (this *changedBlockTracker) queryChangedExtents(ctx context.Context, id vim.ID, snapshotID vim.ID, changeID string,
+	capacity int64) ([]incremental.Extent, error) {
+	var extents []incremental.Extent
+	var offset int64
+	for offset < capacity {
+		changeInfo, err := this.vsom.QueryChangedDiskAreas(ctx, id, snapshotID, offset, changeID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to query the changed areas of snapshot %s of IVD %s at offset %d", snapshotID.Id, id.Id, offset)
+		}
+		for _, area := range changeInfo.ChangedArea {
+			extents = append(extents, incremental.Extent{Offset: area.Start, Length: area.Length})
+		}
+		next := changeInfo.StartOffset + changeInfo.Length
+		if next <= offset {
+			return nil, errors.Errorf("The query of the changed areas of snapshot %s of IVD %s did not advance from offset %d", snapshotID.Id, id.Id, offset)
+		}
+		offset = next
+	}
+	return incremental.NormalizeExtents(extents, capacity), nil
+}
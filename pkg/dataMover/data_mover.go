@@ -26,22 +26,44 @@ import (
 	"github.com/vmware-tanzu/astrolabe/pkg/s3repository"
 	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backuprepository"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/checksum"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/incremental"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/multivc"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
+	vim "github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/time/rate"
+	"sort"
 	"sync"
 )
 
 type DataMover struct {
 	logger              logrus.FieldLogger
-	ivdPETM             *ivd.IVDProtectedEntityTypeManager
+	ivdPETM             *multivc.ProtectedEntityTypeManager
 	inProgressCancelMap *sync.Map
-	reloadConfigLock    *sync.Mutex
+	downloadCancelMap   sync.Map
+	progressReporterMap sync.Map
+	// storedBytesReporterMap maps the ID of the local PE of an upload to the reporter of the bytes stored in the
+	// repository, see RegisterStoredBytesReporter.
+	storedBytesReporterMap sync.Map
+	// reloadConfigLock guards the lookups in the ivdPETM against VC config reloads. The data movements keep the VC
+	// connections from being reloaded under them with ivdPETM.Acquire, which defers the reloads until they are done.
+	reloadConfigLock *sync.RWMutex
+	// bandwidthLimiter is shared by all the data movements of the pod, nil if the bandwidth is unbounded.
+	bandwidthLimiter *rate.Limiter
+	// repositoryLimiterMap maps the name of a BackupRepository to the limiter shared by the data movements
+	// from/to the repository, for the repositories with a bandwidth limit parameter.
+	repositoryLimiterMap sync.Map
+	// incrementalUpload enables the incremental uploads with changed block tracking, see SetIncrementalUpload.
+	incrementalUpload bool
 }
 
 func NewDataMoverFromCluster(params map[string]interface{}, logger logrus.FieldLogger) (*DataMover, error) {
 	// Retrieve VC configuration from the cluster only of it has not been passed by the caller
+	vcParams := []map[string]interface{}{params}
 	if _, ok := params[vsphere.HostVcParamKey]; !ok {
-		err := utils.RetrieveVcConfigSecret(params, nil, logger)
-
+		var err error
+		vcParams, err = utils.RetrieveVcConfigs(nil, logger)
 		if err != nil {
 			logger.WithError(err).Errorf("Could not retrieve vsphere credential from k8s secret.")
 			return nil, err
@@ -49,18 +71,15 @@ func NewDataMoverFromCluster(params map[string]interface{}, logger logrus.FieldL
 		logger.Infof("DataMover: vSphere VC credential is retrieved")
 	}
 
-	ivdPETM, err := utils.GetIVDPETMFromParamsMap(params, logger)
+	ivdPETM, err := multivc.NewProtectedEntityTypeManager(vcParams, logger)
 	if err != nil {
-		logger.WithFields(logrus.Fields{
-			"VirtualCenter": params["VirtualCenter"],
-			"port":          params["port"],
-		}).WithError(err).Errorf("Failed to get ivdPETM from params map.")
+		logger.WithError(err).Errorf("Failed to get ivdPETM from params map.")
 		return nil, err
 	}
 	logger.Infof("DataMover: Get ivdPETM from the params map")
 
 	var syncMap sync.Map
-	var mut sync.Mutex
+	var mut sync.RWMutex
 	dataMover := DataMover{
 		logger:              logger,
 		ivdPETM:             ivdPETM,
@@ -73,61 +92,238 @@ func NewDataMoverFromCluster(params map[string]interface{}, logger logrus.FieldL
 }
 
 func (this *DataMover) CopyToRepo(peID astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntityID, error) {
-	this.reloadConfigLock.Lock()
-	defer this.reloadConfigLock.Unlock()
 	var s3PETM *s3repository.ProtectedEntityTypeManager
 	logger := this.logger
-	s3PETM, err := utils.GetDefaultS3PETM(logger)
+	params := make(map[string]interface{})
+	err := utils.RetrieveVSLFromVeleroBSLs(params, constants.DefaultS3BackupLocation, nil, logger)
+	if err != nil {
+		logger.Errorf("CopyToRepo: Failed to retrieve the default backup storage location")
+		return astrolabe.ProtectedEntityID{}, err
+	}
+	s3PETM, err = utils.GetS3PETMFromParamsMap(params, logger)
 	if err != nil {
 		logger.Errorf("CopyToRepo: Failed to get Default S3 repository")
 		return astrolabe.ProtectedEntityID{}, err
 	}
-	return this.copyToRepo(peID, s3PETM)
+	return this.copyToRepo(peID, checksum.NewProtectedEntityTypeManager(s3PETM, logger), nil)
 }
 
 func (this *DataMover) CopyToRepoWithBackupRepository(peID astrolabe.ProtectedEntityID, backupRepository *backupdriverv1.BackupRepository) (astrolabe.ProtectedEntityID, error) {
-	this.reloadConfigLock.Lock()
-	defer this.reloadConfigLock.Unlock()
-	var s3PETM *s3repository.ProtectedEntityTypeManager
+	var repositoryPETM astrolabe.ProtectedEntityTypeManager
 	logger := this.logger
-	s3PETM, err := backuprepository.GetRepositoryFromBackupRepository(backupRepository, logger)
+	repositoryPETM, err := backuprepository.GetRepositoryFromBackupRepository(backupRepository, logger)
 	if err != nil {
-		logger.Errorf("CopyToRepoWithBackupRepository: Failed to get S3 repository from backup repository %s", backupRepository.Name)
+		logger.Errorf("CopyToRepoWithBackupRepository: Failed to get repository from backup repository %s", backupRepository.Name)
 		return astrolabe.ProtectedEntityID{}, err
 	}
-	return this.copyToRepo(peID, s3PETM)
+	return this.copyToRepo(peID, repositoryPETM, this.getRepositoryLimiter(backupRepository))
 }
 
-func (this *DataMover) copyToRepo(peID astrolabe.ProtectedEntityID, s3PETM *s3repository.ProtectedEntityTypeManager) (astrolabe.ProtectedEntityID, error) {
+func (this *DataMover) copyToRepo(peID astrolabe.ProtectedEntityID, repositoryPETM astrolabe.ProtectedEntityTypeManager,
+	repositoryLimiter *rate.Limiter) (astrolabe.ProtectedEntityID, error) {
 	log := this.logger.WithField("Local PEID", peID.String())
 	log.Infof("Copying the snapshot from local to remote repository")
-	ctx := context.Background()
-	updatedPE, err := this.ivdPETM.GetProtectedEntity(ctx, peID)
+	ctx, release := this.ivdPETM.Acquire(context.Background())
+	defer release()
+	updatedPE, err := this.getIvdProtectedEntity(ctx, peID)
 	if err != nil {
 		log.WithError(err).Errorf("Failed to get ProtectedEntity")
 		return astrolabe.ProtectedEntityID{}, err
 	}
 
+	fullPE := updatedPE
+	if this.incrementalUpload {
+		updatedPE = this.getIncrementalProtectedEntity(ctx, updatedPE, repositoryPETM, log)
+	}
+
 	log.Infof("Registering a in-progress cancel function.")
 	ctx, cancelFunc := context.WithCancel(ctx)
 	this.RegisterOngoingUpload(peID, cancelFunc)
 
-	log.Debugf("Ready to call s3 PETM copy API for local PE")
+	wrap := func(pe astrolabe.ProtectedEntity) astrolabe.ProtectedEntity {
+		pe = newProgressProtectedEntity(pe, this.getProgressReporter(peID), log)
+		return newThrottledProtectedEntity(pe, this.bandwidthLimiter, repositoryLimiter)
+	}
+
+	log.Debugf("Ready to call repository PETM copy API for local PE")
 	var params map[string]map[string]interface{}
-	s3PE, err := s3PETM.Copy(ctx, updatedPE, params, astrolabe.AllocateNewObject)
-	log.Debugf("Return from the call of s3 PETM copy API for local PE")
+	remotePE, err := repositoryPETM.Copy(ctx, wrap(updatedPE), params, astrolabe.AllocateNewObject)
+	log.Debugf("Return from the call of repository PETM copy API for local PE")
+	if baseID, ok := incremental.GetParentID(updatedPE); ok && err == nil {
+		remotePE, err = replaceIfBaseDeleted(ctx, repositoryPETM, remotePE, baseID, wrap(fullPE), log)
+	}
 	if err != nil {
 		log.WithError(err).Errorf("Failed at copying to remote repository")
 		return astrolabe.ProtectedEntityID{}, err
 	}
 
-	log.WithField("Remote s3PEID", s3PE.GetID().String()).Infof("Protected Entity was just copied from local to remote repository.")
-	return s3PE.GetID(), nil
+	log.WithField("Remote PEID", remotePE.GetID().String()).Infof("Protected Entity was just copied from local to remote repository.")
+	// The local snapshot is only deleted once the snapshot is known to be intact in the repository
+	if err := this.verifyRemoteSnapshot(ctx, repositoryPETM, remotePE.GetID(), repositoryLimiter, log); err != nil {
+		log.WithError(err).Errorf("Failed to verify the snapshot in the remote repository")
+		return astrolabe.ProtectedEntityID{}, err
+	}
+	if sizer, ok := remotePE.(storedDataSizer); ok {
+		if reporter := this.getStoredBytesReporter(peID); reporter != nil {
+			reporter(sizer.GetStoredDataSize())
+		}
+	}
+	return remotePE.GetID(), nil
+}
+
+// verifyRemoteSnapshot reads the snapshot back from the repository to verify it against the checksums computed while it
+// was uploaded. The read is throttled as any other data movement from the repository.
+func (this *DataMover) verifyRemoteSnapshot(ctx context.Context, repositoryPETM astrolabe.ProtectedEntityTypeManager,
+	peID astrolabe.ProtectedEntityID, repositoryLimiter *rate.Limiter, log logrus.FieldLogger) error {
+	log.Infof("Verifying the checksums of the snapshot in the remote repository")
+	remotePE, err := repositoryPETM.GetProtectedEntity(ctx, peID)
+	if err != nil {
+		return err
+	}
+	if err := checksum.VerifyProtectedEntity(ctx, newThrottledProtectedEntity(remotePE, this.bandwidthLimiter, repositoryLimiter)); err != nil {
+		return err
+	}
+	log.Infof("The checksums of the snapshot in the remote repository are verified")
+	return nil
+}
+
+// SetIncrementalUpload enables the incremental uploads. The local snapshot is then kept after its upload as the base
+// of the next upload of the volume, which only copies the extents changed since the base according to the changed
+// block tracking of vSphere. It is expected to be called before any data movement starts.
+func (this *DataMover) SetIncrementalUpload(enabled bool) {
+	this.incrementalUpload = enabled
+	if enabled {
+		this.logger.Infof("DataMover: incremental upload is enabled")
+	}
 }
 
-func (this *DataMover) CopyFromRepo(peID astrolabe.ProtectedEntityID, targetPEID astrolabe.ProtectedEntityID, options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntityID, error) {
-	this.reloadConfigLock.Lock()
-	defer this.reloadConfigLock.Unlock()
+// IsIncrementalUploadEnabled returns true if the local snapshots have to be kept after their uploads, as the bases
+// of the next incremental uploads.
+func (this *DataMover) IsIncrementalUploadEnabled() bool {
+	return this.incrementalUpload
+}
+
+// getIncrementalProtectedEntity returns the ProtectedEntity to copy to the repository for the local snapshot, which
+// is an incremental one if the volume has a base snapshot. The base is the latest local snapshot of the volume which
+// precedes the snapshot and is available in the repository, see incremental.IsBaseAvailable. The full snapshot is
+// returned whenever an incremental one is not possible.
+func (this *DataMover) getIncrementalProtectedEntity(ctx context.Context, pe astrolabe.ProtectedEntity,
+	repositoryPETM astrolabe.ProtectedEntityTypeManager, log logrus.FieldLogger) astrolabe.ProtectedEntity {
+	peID := pe.GetID()
+	// Changed block tracking is queried on the vCenter of the volume
+	this.reloadConfigLock.RLock()
+	vcParams, err := this.ivdPETM.GetVcParams(ctx, peID)
+	this.reloadConfigLock.RUnlock()
+	if err != nil {
+		log.WithError(err).Warnf("Failed to find the vCenter of the volume for changed block tracking, uploading the full snapshot")
+		return pe
+	}
+	tracker, err := newChangedBlockTracker(ctx, vcParams, log)
+	if err != nil {
+		log.WithError(err).Warnf("Failed to connect to VC for changed block tracking, uploading the full snapshot")
+		return pe
+	}
+	defer tracker.close(ctx)
+
+	volumeID := ivd.NewVimIDFromPEID(peID)
+	capacity, enabled, err := tracker.getCapacity(ctx, volumeID)
+	if err != nil {
+		log.WithError(err).Warnf("Uploading the full snapshot")
+		return pe
+	}
+	if !enabled {
+		if err := tracker.enable(ctx, volumeID); err != nil {
+			log.WithError(err).Warnf("Uploading the full snapshot")
+		} else {
+			log.Infof("Enabled changed block tracking on the volume, the incremental uploads of the volume start from its next snapshot")
+		}
+		return pe
+	}
+
+	snapshots, err := tracker.getSnapshots(ctx, volumeID)
+	if err != nil {
+		log.WithError(err).Warnf("Uploading the full snapshot")
+		return pe
+	}
+	var current *vim.VStorageObjectSnapshotInfoVStorageObjectSnapshot
+	for i := range snapshots {
+		if snapshots[i].Id != nil && snapshots[i].Id.Id == peID.GetSnapshotID().GetID() {
+			current = &snapshots[i]
+		}
+	}
+	if current == nil {
+		log.Warnf("The snapshot was not found on the volume, uploading the full snapshot")
+		return pe
+	}
+	// The preceding snapshots which have been uploaded, from the latest one
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreateTime.After(snapshots[j].CreateTime)
+	})
+	var baseID astrolabe.ProtectedEntityID
+	var baseSnapshotID *vim.ID
+	for _, snapshot := range snapshots {
+		if snapshot.Id == nil || !snapshot.CreateTime.Before(current.CreateTime) {
+			continue
+		}
+		snapshotPEID := peID.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID(snapshot.Id.Id))
+		if incremental.IsBaseAvailable(ctx, repositoryPETM, snapshotPEID) {
+			baseID, baseSnapshotID = snapshotPEID, snapshot.Id
+			break
+		}
+	}
+	if baseSnapshotID == nil {
+		log.Infof("No base snapshot of the volume was found in the repository, uploading the full snapshot")
+		return pe
+	}
+	log = log.WithField("Base PEID", baseID.String())
+
+	chainLength, err := incremental.GetChainLength(ctx, repositoryPETM, baseID)
+	if err != nil {
+		log.WithError(err).Warnf("Failed to get the chain of the base snapshot, uploading the full snapshot")
+		return pe
+	}
+	if chainLength >= constants.IncrementalUploadMaxChainLength {
+		log.Infof("The chain of the base snapshot reached the maximum length of %d, uploading the full snapshot", constants.IncrementalUploadMaxChainLength)
+		return pe
+	}
+	changeID, err := tracker.getChangeID(ctx, volumeID, *baseSnapshotID)
+	if err != nil {
+		log.WithError(err).Warnf("Uploading the full snapshot")
+		return pe
+	}
+	if changeID == "" {
+		log.Infof("Changed block tracking was not enabled when the base snapshot was taken, uploading the full snapshot")
+		return pe
+	}
+	extents, err := tracker.queryChangedExtents(ctx, volumeID, *current.Id, changeID, capacity)
+	if err != nil {
+		// e.g. changed block tracking was reset, which invalidates the change IDs
+		log.WithError(err).Warnf("Uploading the full snapshot")
+		return pe
+	}
+	incrementalPE := incremental.NewIncrementalProtectedEntity(pe, baseID, capacity, extents)
+	log.Infof("Uploading the incremental snapshot with %d changed bytes out of %d", incrementalPE.(dataSizer).GetDataSize(), capacity)
+	return incrementalPE
+}
+
+// replaceIfBaseDeleted checks that the base of the incremental snapshot copied to the repository is still available,
+// and replaces the copy by the full snapshot otherwise. The base may have been deleted while the snapshot was copied,
+// without the snapshot being merged into it, see incremental.Consolidate.
+func replaceIfBaseDeleted(ctx context.Context, repositoryPETM astrolabe.ProtectedEntityTypeManager, remotePE astrolabe.ProtectedEntity,
+	baseID astrolabe.ProtectedEntityID, fullPE astrolabe.ProtectedEntity, log logrus.FieldLogger) (astrolabe.ProtectedEntity, error) {
+	if incremental.IsBaseAvailable(ctx, repositoryPETM, baseID) {
+		return remotePE, nil
+	}
+	log.Warnf("The base snapshot %s was deleted during the upload, uploading the full snapshot", baseID.String())
+	if _, err := remotePE.DeleteSnapshot(ctx, remotePE.GetID().GetSnapshotID(), make(map[string]map[string]interface{})); err != nil {
+		return nil, errors.Wrapf(err, "Failed to delete the incremental snapshot %s", remotePE.GetID().String())
+	}
+	return repositoryPETM.Copy(ctx, fullPE, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+}
+
+// CopyFromRepo copies the snapshot from the default S3 repository for the Download CR with the name. The copy is
+// canceled with CancelDownload.
+func (this *DataMover) CopyFromRepo(downloadName string, peID astrolabe.ProtectedEntityID, targetPEID astrolabe.ProtectedEntityID, options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntityID, error) {
 	var s3PETM *s3repository.ProtectedEntityTypeManager
 	logger := this.logger
 	s3PETM, err := utils.GetDefaultS3PETM(logger)
@@ -135,37 +331,46 @@ func (this *DataMover) CopyFromRepo(peID astrolabe.ProtectedEntityID, targetPEID
 		logger.Errorf("CopyFromRepo: Failed to get Default S3 repository")
 		return astrolabe.ProtectedEntityID{}, err
 	}
-	return this.copyFromRepo(peID, targetPEID, s3PETM, options)
+	return this.copyFromRepo(downloadName, peID, targetPEID, checksum.NewProtectedEntityTypeManager(s3PETM, logger), nil, options)
 }
 
-func (this *DataMover) CopyFromRepoWithBackupRepository(peID astrolabe.ProtectedEntityID, targetPEID astrolabe.ProtectedEntityID, backupRepository *backupdriverv1.BackupRepository, options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntityID, error) {
-	this.reloadConfigLock.Lock()
-	defer this.reloadConfigLock.Unlock()
-	var s3PETM *s3repository.ProtectedEntityTypeManager
+func (this *DataMover) CopyFromRepoWithBackupRepository(downloadName string, peID astrolabe.ProtectedEntityID, targetPEID astrolabe.ProtectedEntityID, backupRepository *backupdriverv1.BackupRepository, options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntityID, error) {
+	var repositoryPETM astrolabe.ProtectedEntityTypeManager
 	logger := this.logger
-	s3PETM, err := backuprepository.GetRepositoryFromBackupRepository(backupRepository, logger)
+	repositoryPETM, err := backuprepository.GetRepositoryFromBackupRepository(backupRepository, logger)
 	if err != nil {
-		logger.Errorf("CopyFromRepoWithBackupRepository: Failed to get S3 repository from backup repository %s", backupRepository.Name)
+		logger.Errorf("CopyFromRepoWithBackupRepository: Failed to get repository from backup repository %s", backupRepository.Name)
 		return astrolabe.ProtectedEntityID{}, err
 	}
-	return this.copyFromRepo(peID, targetPEID, s3PETM, options)
+	return this.copyFromRepo(downloadName, peID, targetPEID, repositoryPETM, this.getRepositoryLimiter(backupRepository), options)
 }
 
-func (this *DataMover) copyFromRepo(peID astrolabe.ProtectedEntityID, targetPEID astrolabe.ProtectedEntityID, s3PETM *s3repository.ProtectedEntityTypeManager, options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntityID, error) {
+func (this *DataMover) copyFromRepo(downloadName string, peID astrolabe.ProtectedEntityID, targetPEID astrolabe.ProtectedEntityID, repositoryPETM astrolabe.ProtectedEntityTypeManager,
+	repositoryLimiter *rate.Limiter, options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntityID, error) {
 	log := this.logger.WithField("Remote PEID", peID.String())
 	log.Infof("Copying the snapshot from remote repository to local. Copy options: %d", options)
-	ctx := context.Background()
-	pe, err := s3PETM.GetProtectedEntity(ctx, peID)
+	ctx, release := this.ivdPETM.Acquire(context.Background())
+	defer release()
+	// The data of an incremental snapshot is reconstructed from its chain in the repository
+	pe, err := incremental.GetProtectedEntity(ctx, repositoryPETM, peID)
 	if err != nil {
 		log.WithError(err).Errorf("Failed to get ProtectedEntity from remote PEID")
 		return astrolabe.ProtectedEntityID{}, err
 	}
+	pe = newProgressProtectedEntity(pe, this.getProgressReporter(peID), log)
+	pe = newThrottledProtectedEntity(pe, this.bandwidthLimiter, repositoryLimiter)
+
+	log.Infof("Registering a in-progress cancel function.")
+	ctx, cancelFunc := context.WithCancel(ctx)
+	defer cancelFunc()
+	this.RegisterOngoingDownload(downloadName, cancelFunc)
+	pe = newCancelableProtectedEntity(pe)
 
 	// Options is UpdateExistingObject. Overwrite target with the snapshot
 	if options == astrolabe.UpdateExistingObject {
 		// Overwrite target pe with source pe
 		log.Infof("Overwriting the target PE %s with the snapshot from remote repository source PE %s.", targetPEID.String(), peID.String())
-		targetPE, err := this.ivdPETM.GetProtectedEntity(ctx, targetPEID)
+		targetPE, err := this.getIvdProtectedEntity(ctx, targetPEID)
 		if err != nil {
 			log.WithError(err).Errorf("Failed to get ProtectedEntity from target PEID %s", targetPEID.String())
 			return astrolabe.ProtectedEntityID{}, err
@@ -175,6 +380,10 @@ func (this *DataMover) copyFromRepo(peID astrolabe.ProtectedEntityID, targetPEID
 		err = targetPE.Overwrite(ctx, pe, params, true)
 		log.Infof("Return from the call of ivd PE overwrite API for remote PE.")
 		if err != nil {
+			if ctx.Err() == context.Canceled {
+				log.Infof("The overwrite from remote repository was canceled.")
+				return astrolabe.ProtectedEntityID{}, errors.Wrap(ctx.Err(), "Overwrite from remote repository was canceled")
+			}
 			log.WithError(err).Errorf("Failed to overwrite from remote repository.")
 			return astrolabe.ProtectedEntityID{}, err
 		}
@@ -183,12 +392,28 @@ func (this *DataMover) copyFromRepo(peID astrolabe.ProtectedEntityID, targetPEID
 		return targetPE.GetID(), nil
 	}
 
+	sourcePEInfo, err := pe.GetInfo(ctx)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to get the info of the remote PE")
+		return astrolabe.ProtectedEntityID{}, err
+	}
+	// The new volume is named after the Download CR, so that it is deleted if the copy fails
+	volumeName := copyVolumeName(sourcePEInfo.GetName(), downloadName)
+	pe = newNamedProtectedEntity(pe, volumeName)
+
 	log.Debugf("Ready to call ivd PETM copy API for remote PE.")
 	var params map[string]map[string]interface{}
 	// options should be astrolabe.AllocateNewObject
 	ivdPE, err := this.ivdPETM.Copy(ctx, pe, params, options)
 	log.Debugf("Return from the call of ivd PETM copy API for remote PE.")
 	if err != nil {
+		this.reloadConfigLock.RLock()
+		deleteFailedCopy(this.ivdPETM, volumeName, deleteIVD, log)
+		this.reloadConfigLock.RUnlock()
+		if ctx.Err() == context.Canceled {
+			log.Infof("The copy from remote repository was canceled.")
+			return astrolabe.ProtectedEntityID{}, errors.Wrap(ctx.Err(), "Copy from remote repository was canceled")
+		}
 		log.WithError(err).Errorf("Failed to copy from remote repository.")
 		return astrolabe.ProtectedEntityID{}, err
 	}
@@ -197,6 +422,13 @@ func (this *DataMover) copyFromRepo(peID astrolabe.ProtectedEntityID, targetPEID
 	return ivdPE.GetID(), nil
 }
 
+// getIvdProtectedEntity looks up the local PE, the data of the PE is accessed later under ivdPETM.Acquire
+func (this *DataMover) getIvdProtectedEntity(ctx context.Context, peID astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntity, error) {
+	this.reloadConfigLock.RLock()
+	defer this.reloadConfigLock.RUnlock()
+	return this.ivdPETM.GetProtectedEntity(ctx, peID)
+}
+
 func (this *DataMover) IsUploading(peID astrolabe.ProtectedEntityID) bool {
 	log := this.logger.WithField("PEID", peID.String())
 	log.Infof("Checking if the node is uploading")
@@ -237,11 +469,124 @@ func (this *DataMover) UnregisterOngoingUpload(peID astrolabe.ProtectedEntityID)
 	}
 }
 
-func (this *DataMover) ReloadDataMoverIvdPetmConfig(params map[string]interface{}) error {
+// IsDownloading returns true if the Download CR with the name is being processed on the node. The downloads are
+// tracked by the names of their CRs, as the same snapshot may be restored by concurrent downloads.
+func (this *DataMover) IsDownloading(downloadName string) bool {
+	_, ok := this.downloadCancelMap.Load(downloadName)
+	return ok
+}
+
+func (this *DataMover) CancelDownload(downloadName string) error {
+	log := this.logger.WithField("Download", downloadName)
+	if value, ok := this.downloadCancelMap.Load(downloadName); ok {
+		log.Infof("Triggering cancellation of the download.")
+		cancelFunc := value.(context.CancelFunc)
+		cancelFunc()
+		this.downloadCancelMap.Delete(downloadName)
+		log.Infof("Triggering cancellation of the download complete.")
+		return nil
+	} else {
+		return errors.Errorf("The download was not found to be in progress on the node.")
+	}
+}
+
+func (this *DataMover) RegisterOngoingDownload(downloadName string, cancelFunc context.CancelFunc) {
+	this.downloadCancelMap.Store(downloadName, cancelFunc)
+}
+
+func (this *DataMover) UnregisterOngoingDownload(downloadName string) {
+	this.downloadCancelMap.Delete(downloadName)
+}
+
+// InProgressUploadCount returns the number of uploads which are in progress on this node.
+func (this *DataMover) InProgressUploadCount() int {
+	count := 0
+	this.inProgressCancelMap.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// RegisterProgressReporter registers a reporter to be called with the progress of the data movement
+// of the given source PE, i.e. the local PE for uploads and the remote PE for downloads.
+func (this *DataMover) RegisterProgressReporter(peID astrolabe.ProtectedEntityID, reporter ProgressReporter) {
+	this.progressReporterMap.Store(peID, reporter)
+}
+
+func (this *DataMover) UnregisterProgressReporter(peID astrolabe.ProtectedEntityID) {
+	this.progressReporterMap.Delete(peID)
+}
+
+func (this *DataMover) getProgressReporter(peID astrolabe.ProtectedEntityID) ProgressReporter {
+	if value, ok := this.progressReporterMap.Load(peID); ok {
+		return value.(ProgressReporter)
+	}
+	return nil
+}
+
+// RegisterStoredBytesReporter registers a reporter to be called with the number of bytes stored in the repository
+// once the upload of the given local PE is completed. It is only called for the repositories which know the size of
+// the stored data, i.e. the ones of a BackupRepository.
+func (this *DataMover) RegisterStoredBytesReporter(peID astrolabe.ProtectedEntityID, reporter StoredBytesReporter) {
+	this.storedBytesReporterMap.Store(peID, reporter)
+}
+
+func (this *DataMover) UnregisterStoredBytesReporter(peID astrolabe.ProtectedEntityID) {
+	this.storedBytesReporterMap.Delete(peID)
+}
+
+func (this *DataMover) getStoredBytesReporter(peID astrolabe.ProtectedEntityID) StoredBytesReporter {
+	if value, ok := this.storedBytesReporterMap.Load(peID); ok {
+		return value.(StoredBytesReporter)
+	}
+	return nil
+}
+
+// SetBandwidthLimit limits the total bandwidth of all the data movements of the pod to bytesPerSecond.
+// A value of 0 is treated as unbounded. It is expected to be called before any data movement starts.
+func (this *DataMover) SetBandwidthLimit(bytesPerSecond int64) {
+	this.bandwidthLimiter = newBandwidthLimiter(bytesPerSecond)
+	if this.bandwidthLimiter != nil {
+		this.logger.Infof("DataMover: bandwidth is limited to %d bytes per second", bytesPerSecond)
+	}
+}
+
+// getRepositoryLimiter returns the limiter for the bandwidth limit parameter of the BackupRepository, or nil if
+// the parameter is not set. The limiter is shared by all the data movements from/to the same repository.
+func (this *DataMover) getRepositoryLimiter(backupRepository *backupdriverv1.BackupRepository) *rate.Limiter {
+	log := this.logger.WithField("BackupRepository", backupRepository.Name)
+	bytesPerSecond, err := ParseBandwidthLimit(backupRepository.RepositoryParameters[constants.RepositoryParamBandwidthLimit])
+	if err != nil {
+		log.WithError(err).Warnf("Ignoring the invalid bandwidth limit of the backup repository")
+		bytesPerSecond = 0
+	}
+	if bytesPerSecond <= 0 {
+		this.repositoryLimiterMap.Delete(backupRepository.Name)
+		return nil
+	}
+	if value, ok := this.repositoryLimiterMap.Load(backupRepository.Name); ok {
+		limiter := value.(*rate.Limiter)
+		if limiter.Limit() == rate.Limit(bytesPerSecond) {
+			return limiter
+		}
+	}
+	log.Infof("Bandwidth of the backup repository is limited to %d bytes per second", bytesPerSecond)
+	limiter := newBandwidthLimiter(bytesPerSecond)
+	this.repositoryLimiterMap.Store(backupRepository.Name, limiter)
+	return limiter
+}
+
+// ReloadDataMoverIvdPetmConfig reloads the config of each vCenter, see multivc.ProtectedEntityTypeManager.ReloadConfig.
+func (this *DataMover) ReloadDataMoverIvdPetmConfig(vcParams []map[string]interface{}) error {
 	this.reloadConfigLock.Lock()
 	defer this.reloadConfigLock.Unlock()
 	this.logger.Debug("DataMover Config Reload initiated.")
-	err := this.ivdPETM.ReloadConfig(context.TODO(), params)
+	err := this.ivdPETM.ReloadConfig(context.TODO(), vcParams)
+	if err == multivc.ErrReloadDeferred {
+		this.logger.Info("The reload of IVD PE Type Manager config associated with DataMover is deferred")
+		return nil
+	}
 	if err != nil {
 		this.logger.Infof("Failed to reload IVD PE Type Manager config associated with DataMover")
 		return err
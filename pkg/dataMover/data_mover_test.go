@@ -0,0 +1,143 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/fsrepository"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/incremental"
+)
+
+const testCapacity = 16
+
+// fakeSnapshot is a local snapshot, whose data reader runs onRead first.
+type fakeSnapshot struct {
+	astrolabe.ProtectedEntity
+	id     astrolabe.ProtectedEntityID
+	data   []byte
+	onRead func()
+}
+
+func (this fakeSnapshot) GetID() astrolabe.ProtectedEntityID {
+	return this.id
+}
+
+func (this fakeSnapshot) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("fake", map[string]string{})}
+	return astrolabe.NewProtectedEntityInfo(this.id, "pvc-1", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}), nil
+}
+
+func (this fakeSnapshot) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	if this.onRead != nil {
+		this.onRead()
+	}
+	return ioutil.NopCloser(bytes.NewReader(this.data)), nil
+}
+
+func (this fakeSnapshot) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader([]byte("metadata of " + this.id.String()))), nil
+}
+
+func TestReplaceIfBaseDeleted(t *testing.T) {
+	baseImage := bytes.Repeat([]byte{'a'}, testCapacity)
+	image := append(append([]byte(nil), baseImage[:8]...), bytes.Repeat([]byte{'b'}, 8)...)
+	changes := []incremental.Extent{{Offset: 8, Length: 8}}
+
+	tests := []struct {
+		name string
+		// deleteBase runs while the incremental snapshot is uploaded
+		deleteBase          func(t *testing.T, petm astrolabe.ProtectedEntityTypeManager, baseID astrolabe.ProtectedEntityID)
+		expectedChainLength int
+	}{
+		{
+			name:                "Base kept during the upload",
+			deleteBase:          func(t *testing.T, petm astrolabe.ProtectedEntityTypeManager, baseID astrolabe.ProtectedEntityID) {},
+			expectedChainLength: 2,
+		},
+		{
+			name: "Base being deleted during the upload",
+			deleteBase: func(t *testing.T, petm astrolabe.ProtectedEntityTypeManager, baseID astrolabe.ProtectedEntityID) {
+				require.NoError(t, incremental.Consolidate(context.Background(), petm, baseID, logrus.New()))
+			},
+			expectedChainLength: 1,
+		},
+		{
+			name: "Base deleted during the upload",
+			deleteBase: func(t *testing.T, petm astrolabe.ProtectedEntityTypeManager, baseID astrolabe.ProtectedEntityID) {
+				ctx := context.Background()
+				require.NoError(t, incremental.Consolidate(ctx, petm, baseID, logrus.New()))
+				pe, err := petm.GetProtectedEntity(ctx, baseID)
+				require.NoError(t, err)
+				_, err = pe.DeleteSnapshot(ctx, baseID.GetSnapshotID(), make(map[string]map[string]interface{}))
+				require.NoError(t, err)
+				require.NoError(t, incremental.CompleteDeletion(ctx, petm, baseID))
+			},
+			expectedChainLength: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			repoDir, err := ioutil.TempDir("", "dataMover")
+			require.NoError(t, err)
+			defer os.RemoveAll(repoDir)
+			petm, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+			require.NoError(t, err)
+
+			volumeID := astrolabe.NewProtectedEntityID("ivd", "volume-1")
+			baseID := volumeID.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID("snapshot-1"))
+			_, err = petm.Copy(ctx, fakeSnapshot{id: baseID, data: baseImage}, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+			require.NoError(t, err)
+			require.True(t, incremental.IsBaseAvailable(ctx, petm, baseID))
+
+			snapshot := fakeSnapshot{id: volumeID.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID("snapshot-2")), data: image}
+			uploading := snapshot
+			uploading.onRead = func() {
+				test.deleteBase(t, petm, baseID)
+			}
+			incrementalPE := incremental.NewIncrementalProtectedEntity(uploading, baseID, testCapacity, changes)
+			remotePE, err := petm.Copy(ctx, incrementalPE, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+			require.NoError(t, err)
+
+			remotePE, err = replaceIfBaseDeleted(ctx, petm, remotePE, baseID, snapshot, logrus.New())
+			require.NoError(t, err)
+			assert.Equal(t, snapshot.id, remotePE.GetID())
+			chainLength, err := incremental.GetChainLength(ctx, petm, snapshot.id)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedChainLength, chainLength)
+			pe, err := incremental.GetProtectedEntity(ctx, petm, snapshot.id)
+			require.NoError(t, err)
+			dataReader, err := pe.GetDataReader(ctx)
+			require.NoError(t, err)
+			defer dataReader.Close()
+			data, err := ioutil.ReadAll(dataReader)
+			require.NoError(t, err)
+			assert.Equal(t, image, data)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/astrolabe/pkg/common/vsphere"
+	vim "github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/govmomi/vim25/xml"
+)
+
+// copyVolumeName returns the name of the volume created by copying the snapshot for the Download CR with the name.
+// The name of the source is suffixed with a hash of the Download name, so that the volume left behind by a failed
+// copy is told apart from the volumes of the concurrent copies of the same snapshot.
+func copyVolumeName(sourceName string, downloadName string) string {
+	hash := sha256.Sum256([]byte(downloadName))
+	return fmt.Sprintf("%s-%s", sourceName, hex.EncodeToString(hash[:4]))
+}
+
+// ivdMetadata mirrors the metadata of the IVD ProtectedEntities of astrolabe.
+type ivdMetadata struct {
+	VirtualStorageObject vim.VStorageObject         `xml:"virtualStorageObject"`
+	Datastore            vim.ManagedObjectReference `xml:"datastore"`
+	ExtendedMetadata     []vim.KeyValue             `xml:"extendedMetadata"`
+}
+
+// namedProtectedEntity gives the name to the volume created by copying it. The IVD copy of astrolabe names the new
+// volume after the info of the source, or after its metadata when the volume is provisioned by CNS.
+type namedProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	name string
+}
+
+func newNamedProtectedEntity(pe astrolabe.ProtectedEntity, name string) astrolabe.ProtectedEntity {
+	return namedProtectedEntity{
+		ProtectedEntity: pe,
+		name:            name,
+	}
+}
+
+func (this namedProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	info, err := this.ProtectedEntity.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return astrolabe.NewProtectedEntityInfo(info.GetID(), this.name, info.GetDataTransports(), info.GetMetadataTransports(),
+		info.GetCombinedTransports(), info.GetComponentIDs()), nil
+}
+
+func (this namedProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	metadataReader, err := this.ProtectedEntity.GetMetadataReader(ctx)
+	if err != nil || metadataReader == nil {
+		return metadataReader, err
+	}
+	defer metadataReader.Close()
+	mdBuf, err := ioutil.ReadAll(metadataReader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read the metadata")
+	}
+	var md ivdMetadata
+	decoder := xml.NewDecoder(bytes.NewReader(mdBuf))
+	// The backing of the volume is decoded by its type, so that it is written back along with the name
+	decoder.TypeFunc = vim.TypeFunc()
+	if err := decoder.Decode(&md); err != nil {
+		return nil, errors.Wrap(err, "Failed to parse the metadata")
+	}
+	md.VirtualStorageObject.Config.Name = this.name
+	mdBuf, err = xml.MarshalIndent(md, "  ", "    ")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to write the metadata")
+	}
+	return ioutil.NopCloser(bytes.NewReader(mdBuf)), nil
+}
+
+// ivdVolumes looks up the volumes of the vCenters, it is implemented by the multi-VC ProtectedEntityTypeManager.
+type ivdVolumes interface {
+	GetProtectedEntities(ctx context.Context) ([]astrolabe.ProtectedEntityID, error)
+	GetProtectedEntity(ctx context.Context, id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntity, error)
+	GetVcParams(ctx context.Context, id astrolabe.ProtectedEntityID) (map[string]interface{}, error)
+}
+
+type deleteVolumeFunc func(ctx context.Context, params map[string]interface{}, volumeID string, logger logrus.FieldLogger) error
+
+// deleteFailedCopy deletes the volumes with the name of a failed copy, see copyVolumeName. The IVD copy of astrolabe
+// creates the new volume before copying the data into it, and does not return the volume when the copy fails, e.g.
+// because it was canceled. The failures are only logged, the volumes are left to be deleted manually.
+func deleteFailedCopy(volumes ivdVolumes, volumeName string, deleteVolume deleteVolumeFunc, log logrus.FieldLogger) {
+	// The context of the copy may be canceled
+	ctx := context.Background()
+	ids, err := volumes.GetProtectedEntities(ctx)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to look up the volume %s of the failed copy", volumeName)
+		return
+	}
+	for _, id := range ids {
+		pe, err := volumes.GetProtectedEntity(ctx, id)
+		if err != nil {
+			continue
+		}
+		info, err := pe.GetInfo(ctx)
+		if err != nil || info.GetName() != volumeName {
+			continue
+		}
+		params, err := volumes.GetVcParams(ctx, id)
+		if err == nil {
+			err = deleteVolume(ctx, params, id.GetID(), log)
+		}
+		if err != nil {
+			log.WithError(err).Errorf("Failed to delete the volume %s, %s, of the failed copy", volumeName, id.GetID())
+			continue
+		}
+		log.Infof("Deleted the volume %s, %s, of the failed copy", volumeName, id.GetID())
+	}
+}
+
+// deleteIVD deletes the IVD with its own connection to the vCenter, as the IVD ProtectedEntityTypeManager of
+// astrolabe does not delete IVDs.
+func deleteIVD(ctx context.Context, params map[string]interface{}, volumeID string, logger logrus.FieldLogger) error {
+	vcConfig, err := vsphere.GetVirtualCenterConfigFromParams(params, logger)
+	if err != nil {
+		return err
+	}
+	vc, vsom, _, err := vsphere.GetVirtualCenter(ctx, vcConfig, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := vc.Disconnect(ctx); err != nil {
+			logger.WithError(err).Warnf("Failed to disconnect from VC after deleting IVD %s", volumeID)
+		}
+	}()
+	task, err := vsom.Delete(ctx, vim.ID{Id: volumeID})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to delete IVD %s", volumeID)
+	}
+	if _, err := task.Wait(ctx, time.Second); err != nil {
+		return errors.Wrapf(err, "Failed to delete IVD %s", volumeID)
+	}
+	return nil
+}
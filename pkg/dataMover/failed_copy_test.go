@@ -0,0 +1,138 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	vim "github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/govmomi/vim25/xml"
+)
+
+type fakeVolume struct {
+	astrolabe.ProtectedEntity
+	id       astrolabe.ProtectedEntityID
+	name     string
+	metadata []byte
+}
+
+func (this fakeVolume) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return astrolabe.NewProtectedEntityInfo(this.id, this.name, nil, nil, nil, nil), nil
+}
+
+func (this fakeVolume) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.metadata)), nil
+}
+
+type fakeVolumes struct {
+	volumes map[string]string
+	deleted []string
+}
+
+func (this *fakeVolumes) GetProtectedEntities(ctx context.Context) ([]astrolabe.ProtectedEntityID, error) {
+	var ids []astrolabe.ProtectedEntityID
+	for id := range this.volumes {
+		ids = append(ids, astrolabe.NewProtectedEntityID("ivd", id))
+	}
+	return ids, nil
+}
+
+func (this *fakeVolumes) GetProtectedEntity(ctx context.Context, id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntity, error) {
+	return fakeVolume{id: id, name: this.volumes[id.GetID()]}, nil
+}
+
+func (this *fakeVolumes) GetVcParams(ctx context.Context, id astrolabe.ProtectedEntityID) (map[string]interface{}, error) {
+	return map[string]interface{}{"VirtualCenter": "vc-1"}, nil
+}
+
+func (this *fakeVolumes) deleteVolume(ctx context.Context, params map[string]interface{}, volumeID string, logger logrus.FieldLogger) error {
+	delete(this.volumes, volumeID)
+	this.deleted = append(this.deleted, volumeID)
+	return nil
+}
+
+func TestCopyVolumeName(t *testing.T) {
+	name := copyVolumeName("pvc-1", "download-1")
+	assert.Regexp(t, "^pvc-1-[0-9a-f]{8}$", name)
+	assert.Equal(t, name, copyVolumeName("pvc-1", "download-1"))
+	// The concurrent copies of the same snapshot create volumes with different names
+	assert.NotEqual(t, name, copyVolumeName("pvc-1", "download-2"))
+}
+
+func TestNamedProtectedEntity(t *testing.T) {
+	ctx := context.Background()
+	md := ivdMetadata{
+		VirtualStorageObject: vim.VStorageObject{
+			Config: vim.VStorageObjectConfigInfo{
+				BaseConfigInfo: vim.BaseConfigInfo{
+					Id:   vim.ID{Id: "volume-1"},
+					Name: "pvc-1",
+					Backing: &vim.BaseConfigInfoDiskFileBackingInfo{
+						BaseConfigInfoFileBackingInfo: vim.BaseConfigInfoFileBackingInfo{FilePath: "[datastore1] fcd/volume-1.vmdk"},
+						ProvisioningType:              "thin",
+					},
+				},
+				CapacityInMB: 1024,
+			},
+		},
+		ExtendedMetadata: []vim.KeyValue{{Key: "app", Value: "nginx"}},
+	}
+	mdBuf, err := xml.Marshal(md)
+	require.NoError(t, err)
+	pe := newNamedProtectedEntity(fakeVolume{id: astrolabe.NewProtectedEntityID("ivd", "volume-1"), name: "pvc-1", metadata: mdBuf}, "pvc-1-0123abcd")
+
+	info, err := pe.GetInfo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "pvc-1-0123abcd", info.GetName())
+	assert.Equal(t, "volume-1", info.GetID().GetID())
+
+	metadataReader, err := pe.GetMetadataReader(ctx)
+	require.NoError(t, err)
+	namedBuf, err := ioutil.ReadAll(metadataReader)
+	require.NoError(t, err)
+	var named ivdMetadata
+	decoder := xml.NewDecoder(bytes.NewReader(namedBuf))
+	decoder.TypeFunc = vim.TypeFunc()
+	require.NoError(t, decoder.Decode(&named))
+	// Only the name is changed
+	md.VirtualStorageObject.Config.Name = "pvc-1-0123abcd"
+	assert.Equal(t, md, named)
+}
+
+func TestDeleteFailedCopy(t *testing.T) {
+	failedName := copyVolumeName("pvc-1", "download-1")
+	volumes := &fakeVolumes{
+		volumes: map[string]string{
+			"volume-1": "pvc-1",
+			// The volume of the failed copy, and the volume of a concurrent copy of the same snapshot
+			"volume-2": failedName,
+			"volume-3": copyVolumeName("pvc-1", "download-2"),
+		},
+	}
+
+	deleteFailedCopy(volumes, failedName, volumes.deleteVolume, logrus.New())
+	assert.Equal(t, []string{"volume-2"}, volumes.deleted)
+	assert.Len(t, volumes.volumes, 2)
+}
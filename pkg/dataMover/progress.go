@@ -0,0 +1,162 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+)
+
+// ProgressReporter is called periodically during data movement with the total number of bytes
+// expected to be moved and the number of bytes moved so far.
+type ProgressReporter func(totalBytes int64, bytesDone int64)
+
+// StoredBytesReporter is called at the end of an upload with the number of bytes of the data stored in the
+// repository, which differs from the number of bytes moved if the repository compresses the data.
+type StoredBytesReporter func(storedBytes int64)
+
+// progressReader wraps the data stream of a ProtectedEntity and reports the number of bytes
+// read so far. Reports are rate-limited by interval, except for the last one at EOF.
+type progressReader struct {
+	reader     io.ReadCloser
+	totalBytes int64
+	bytesDone  int64
+	interval   time.Duration
+	lastReport time.Time
+	reporter   ProgressReporter
+	reportOnce sync.Once
+}
+
+func newProgressReader(reader io.ReadCloser, totalBytes int64, interval time.Duration, reporter ProgressReporter) *progressReader {
+	return &progressReader{
+		reader:     reader,
+		totalBytes: totalBytes,
+		interval:   interval,
+		lastReport: time.Now(),
+		reporter:   reporter,
+	}
+}
+
+func (this *progressReader) Read(p []byte) (int, error) {
+	n, err := this.reader.Read(p)
+	this.bytesDone += int64(n)
+	if err == io.EOF {
+		this.reportFinal()
+	} else if time.Since(this.lastReport) >= this.interval {
+		this.lastReport = time.Now()
+		this.reporter(this.totalBytes, this.bytesDone)
+	}
+	return n, err
+}
+
+func (this *progressReader) Close() error {
+	return this.reader.Close()
+}
+
+func (this *progressReader) reportFinal() {
+	this.reportOnce.Do(func() {
+		// The capacity of the source may not be known in advance, fall back to the bytes actually read
+		if this.totalBytes < this.bytesDone {
+			this.totalBytes = this.bytesDone
+		}
+		this.reporter(this.totalBytes, this.bytesDone)
+	})
+}
+
+// progressProtectedEntity wraps a source ProtectedEntity so that the data reader handed out to
+// the Copy/Overwrite APIs of astrolabe reports its progress.
+type progressProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	interval time.Duration
+	reporter ProgressReporter
+	logger   logrus.FieldLogger
+}
+
+func newProgressProtectedEntity(pe astrolabe.ProtectedEntity, reporter ProgressReporter, logger logrus.FieldLogger) astrolabe.ProtectedEntity {
+	if reporter == nil {
+		return pe
+	}
+	return progressProtectedEntity{
+		ProtectedEntity: pe,
+		interval:        constants.ProgressReportInterval,
+		reporter:        reporter,
+		logger:          logger,
+	}
+}
+
+func (this progressProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	dataReader, err := this.ProtectedEntity.GetDataReader(ctx)
+	if err != nil || dataReader == nil {
+		return dataReader, err
+	}
+	totalBytes, err := this.getCapacity(ctx)
+	if err != nil {
+		this.logger.WithError(err).Warnf("Failed to retrieve the capacity of PE %s, the total bytes will be reported at the end of data movement", this.GetID().String())
+	}
+	this.reporter(totalBytes, 0)
+	return newProgressReader(dataReader, totalBytes, this.interval, this.reporter), nil
+}
+
+// ivdCapacityMetadata captures the capacity from the metadata of an IVD ProtectedEntity. The same metadata
+// is stored along with the data in the remote repository, so this works for both local and remote PEs.
+type ivdCapacityMetadata struct {
+	VirtualStorageObject struct {
+		Config struct {
+			CapacityInMB int64 `xml:"capacityInMB"`
+		} `xml:"config"`
+	} `xml:"virtualStorageObject"`
+}
+
+// dataSizer is implemented by the ProtectedEntities which know the size of their data stream in advance, e.g. the
+// incremental snapshots whose data stream only has the changed extents.
+type dataSizer interface {
+	GetDataSize() int64
+}
+
+// storedDataSizer is implemented by the ProtectedEntities copied to a repository which know the size of the data
+// stored in the repository, e.g. the ones copied to a compressed repository.
+type storedDataSizer interface {
+	GetStoredDataSize() int64
+}
+
+func (this progressProtectedEntity) getCapacity(ctx context.Context) (int64, error) {
+	if sizer, ok := this.ProtectedEntity.(dataSizer); ok {
+		return sizer.GetDataSize(), nil
+	}
+	metadataReader, err := this.ProtectedEntity.GetMetadataReader(ctx)
+	if err != nil || metadataReader == nil {
+		return 0, err
+	}
+	defer metadataReader.Close()
+	mdBuf, err := ioutil.ReadAll(metadataReader)
+	if err != nil {
+		return 0, err
+	}
+	var md ivdCapacityMetadata
+	if err := xml.Unmarshal(mdBuf, &md); err != nil {
+		return 0, err
+	}
+	return md.VirtualStorageObject.Config.CapacityInMB * 1024 * 1024, nil
+}
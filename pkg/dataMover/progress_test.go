@@ -0,0 +1,86 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressReader(t *testing.T) {
+	tests := []struct {
+		name            string
+		dataSize        int
+		totalBytes      int64
+		interval        time.Duration
+		expectedReports int
+		expectedTotal   int64
+	}{
+		{
+			name:            "Reports are rate-limited and the final report is always sent at EOF",
+			dataSize:        4096,
+			totalBytes:      4096,
+			interval:        time.Hour,
+			expectedReports: 1,
+			expectedTotal:   4096,
+		},
+		{
+			name:            "Every read is reported without rate-limiting",
+			dataSize:        4096,
+			totalBytes:      4096,
+			interval:        0,
+			expectedReports: 5,
+			expectedTotal:   4096,
+		},
+		{
+			name:            "Unknown total bytes falls back to the bytes read at EOF",
+			dataSize:        2048,
+			totalBytes:      0,
+			interval:        time.Hour,
+			expectedReports: 1,
+			expectedTotal:   2048,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var reports int
+			var lastTotal, lastDone int64
+			reader := newProgressReader(ioutil.NopCloser(bytes.NewReader(make([]byte, test.dataSize))), test.totalBytes, test.interval,
+				func(totalBytes int64, bytesDone int64) {
+					reports++
+					lastTotal = totalBytes
+					lastDone = bytesDone
+				})
+
+			buf := make([]byte, 1024)
+			for {
+				_, err := reader.Read(buf)
+				if err != nil {
+					break
+				}
+			}
+			assert.Equal(t, test.expectedReports, reports)
+			assert.Equal(t, test.expectedTotal, lastTotal)
+			assert.Equal(t, int64(test.dataSize), lastDone)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ParseBandwidthLimit parses a bandwidth limit in bytes per second, e.g. "100Mi". A value of "0" or ""
+// is treated as unbounded and returns 0.
+func ParseBandwidthLimit(limit string) (int64, error) {
+	if limit == "" {
+		return 0, nil
+	}
+	quantity, err := resource.ParseQuantity(limit)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed to parse bandwidth limit %s", limit)
+	}
+	if quantity.Sign() < 0 {
+		return 0, errors.Errorf("Bandwidth limit %s must not be negative", limit)
+	}
+	return quantity.Value(), nil
+}
+
+// newBandwidthLimiter returns a limiter which allows bytesPerSecond bytes per second with a burst of one second,
+// or nil if bytesPerSecond is not positive, i.e. the bandwidth is unbounded.
+func newBandwidthLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond))
+}
+
+// throttledReader limits the rate at which the data stream is read. Each read has to be admitted by all
+// of the limiters, so a stream never exceeds the most restrictive one, and streams sharing a limiter
+// share its bandwidth.
+type throttledReader struct {
+	ctx      context.Context
+	reader   io.ReadCloser
+	limiters []*rate.Limiter
+}
+
+func (this throttledReader) Read(p []byte) (int, error) {
+	// A single read must not exceed the burst of any limiter, otherwise it could never be admitted
+	for _, limiter := range this.limiters {
+		if len(p) > limiter.Burst() {
+			p = p[:limiter.Burst()]
+		}
+	}
+	n, err := this.reader.Read(p)
+	if n > 0 {
+		for _, limiter := range this.limiters {
+			if waitErr := limiter.WaitN(this.ctx, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+	return n, err
+}
+
+func (this throttledReader) Close() error {
+	return this.reader.Close()
+}
+
+// throttledProtectedEntity wraps a source ProtectedEntity so that the data reader handed out to
+// the Copy/Overwrite APIs of astrolabe is throttled by the given limiters.
+type throttledProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	limiters []*rate.Limiter
+}
+
+func newThrottledProtectedEntity(pe astrolabe.ProtectedEntity, limiters ...*rate.Limiter) astrolabe.ProtectedEntity {
+	var activeLimiters []*rate.Limiter
+	for _, limiter := range limiters {
+		if limiter != nil {
+			activeLimiters = append(activeLimiters, limiter)
+		}
+	}
+	if len(activeLimiters) == 0 {
+		return pe
+	}
+	return throttledProtectedEntity{
+		ProtectedEntity: pe,
+		limiters:        activeLimiters,
+	}
+}
+
+func (this throttledProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	dataReader, err := this.ProtectedEntity.GetDataReader(ctx)
+	if err != nil || dataReader == nil {
+		return dataReader, err
+	}
+	return throttledReader{
+		ctx:      ctx,
+		reader:   dataReader,
+		limiters: this.limiters,
+	}, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dataMover
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestParseBandwidthLimit(t *testing.T) {
+	tests := []struct {
+		limit         string
+		expected      int64
+		expectedError bool
+	}{
+		{limit: "", expected: 0},
+		{limit: "0", expected: 0},
+		{limit: "1024", expected: 1024},
+		{limit: "100Mi", expected: 100 * 1024 * 1024},
+		{limit: "-1", expectedError: true},
+		{limit: "fast", expectedError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.limit, func(t *testing.T) {
+			bytesPerSecond, err := ParseBandwidthLimit(test.limit)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, bytesPerSecond)
+		})
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader := throttledReader{
+		ctx:      ctx,
+		reader:   ioutil.NopCloser(bytes.NewReader(make([]byte, 4096))),
+		limiters: []*rate.Limiter{newBandwidthLimiter(4096), newBandwidthLimiter(1024)},
+	}
+
+	// A read is capped to the smallest burst, the first second of bandwidth is available right away
+	buf := make([]byte, 2048)
+	n, err := reader.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 1024, n)
+
+	start := time.Now()
+	n, err = reader.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 1024, n)
+	assert.True(t, time.Since(start) >= 900*time.Millisecond)
+
+	cancel()
+	_, err = reader.Read(buf)
+	assert.Error(t, err)
+}
+
+func TestNewThrottledProtectedEntityWithoutLimiter(t *testing.T) {
+	assert.Nil(t, newBandwidthLimiter(0))
+	assert.Nil(t, newThrottledProtectedEntity(nil, nil, nil))
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package decorator holds the code shared by the ProtectedEntityTypeManagers which decorate a repository, i.e. wrap
+// it to transform the snapshots copied to and read from it, such as the compression, encryption and checksum ones, and
+// the helpers for the data streams of the snapshots which are shared with the repositories and the data mover.
+package decorator
+
+import (
+	"context"
+	"strings"
+
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+)
+
+// PrefixLister is implemented by the repositories which can list the snapshots of a single ProtectedEntity, e.g. the
+// S3 repository.
+type PrefixLister interface {
+	GetProtectedEntitiesByIDPrefix(ctx context.Context, idPrefix string) ([]astrolabe.ProtectedEntityID, error)
+}
+
+// GetProtectedEntitiesByIDPrefix returns the ProtectedEntities of the repository whose IDs start with the prefix. The
+// repositories which are not PrefixListers are listed in full and filtered.
+func GetProtectedEntitiesByIDPrefix(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, idPrefix string) ([]astrolabe.ProtectedEntityID, error) {
+	if lister, ok := petm.(PrefixLister); ok {
+		return lister.GetProtectedEntitiesByIDPrefix(ctx, idPrefix)
+	}
+	peIDs, err := petm.GetProtectedEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matches []astrolabe.ProtectedEntityID
+	for _, peID := range peIDs {
+		if strings.HasPrefix(peID.String(), idPrefix) {
+			matches = append(matches, peID)
+		}
+	}
+	return matches, nil
+}
+
+// ProtectedEntityTypeManager is embedded by the decorators of a repository. It wraps the snapshots read from the
+// repository with the wrap function of the decorator, and lists the snapshots by prefix. The calls it does not
+// implement go to the repository, so the decorators implement Copy themselves.
+type ProtectedEntityTypeManager struct {
+	astrolabe.ProtectedEntityTypeManager
+	wrap func(pe astrolabe.ProtectedEntity) astrolabe.ProtectedEntity
+}
+
+func NewProtectedEntityTypeManager(petm astrolabe.ProtectedEntityTypeManager,
+	wrap func(pe astrolabe.ProtectedEntity) astrolabe.ProtectedEntity) ProtectedEntityTypeManager {
+	return ProtectedEntityTypeManager{
+		ProtectedEntityTypeManager: petm,
+		wrap:                       wrap,
+	}
+}
+
+func (this ProtectedEntityTypeManager) GetProtectedEntity(ctx context.Context, id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntity, error) {
+	pe, err := this.ProtectedEntityTypeManager.GetProtectedEntity(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return this.wrap(pe), nil
+}
+
+func (this ProtectedEntityTypeManager) GetProtectedEntitiesByIDPrefix(ctx context.Context, idPrefix string) ([]astrolabe.ProtectedEntityID, error) {
+	return GetProtectedEntitiesByIDPrefix(ctx, this.ProtectedEntityTypeManager, idPrefix)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package decorator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+)
+
+type fakeRepository struct {
+	astrolabe.ProtectedEntityTypeManager
+	ids []astrolabe.ProtectedEntityID
+}
+
+func (this *fakeRepository) GetProtectedEntities(ctx context.Context) ([]astrolabe.ProtectedEntityID, error) {
+	return this.ids, nil
+}
+
+func (this *fakeRepository) GetProtectedEntity(ctx context.Context, id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntity, error) {
+	return fakeProtectedEntity{id: id}, nil
+}
+
+// fakePrefixRepository lists the snapshots by prefix on its own.
+type fakePrefixRepository struct {
+	fakeRepository
+	prefixes []string
+}
+
+func (this *fakePrefixRepository) GetProtectedEntitiesByIDPrefix(ctx context.Context, idPrefix string) ([]astrolabe.ProtectedEntityID, error) {
+	this.prefixes = append(this.prefixes, idPrefix)
+	return this.ids[:1], nil
+}
+
+type fakeProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	id astrolabe.ProtectedEntityID
+}
+
+type wrappedProtectedEntity struct {
+	astrolabe.ProtectedEntity
+}
+
+func TestGetProtectedEntitiesByIDPrefix(t *testing.T) {
+	ctx := context.Background()
+	ids := []astrolabe.ProtectedEntityID{
+		astrolabe.NewProtectedEntityIDWithSnapshotID("ivd", "volume-1", astrolabe.NewProtectedEntitySnapshotID("snap-1")),
+		astrolabe.NewProtectedEntityIDWithSnapshotID("ivd", "volume-2", astrolabe.NewProtectedEntitySnapshotID("snap-2")),
+	}
+
+	// The repositories which cannot list by prefix are listed in full
+	matches, err := GetProtectedEntitiesByIDPrefix(ctx, &fakeRepository{ids: ids}, "ivd:volume-2:")
+	require.NoError(t, err)
+	assert.Equal(t, ids[1:], matches)
+
+	repository := &fakePrefixRepository{fakeRepository: fakeRepository{ids: ids}}
+	petm := NewProtectedEntityTypeManager(repository, func(pe astrolabe.ProtectedEntity) astrolabe.ProtectedEntity {
+		return wrappedProtectedEntity{ProtectedEntity: pe}
+	})
+	matches, err = petm.GetProtectedEntitiesByIDPrefix(ctx, "ivd:volume-1:")
+	require.NoError(t, err)
+	assert.Equal(t, ids[:1], matches)
+	assert.Equal(t, []string{"ivd:volume-1:"}, repository.prefixes)
+
+	// The snapshots of the repository are wrapped
+	pe, err := petm.GetProtectedEntity(ctx, ids[0])
+	require.NoError(t, err)
+	assert.IsType(t, wrappedProtectedEntity{}, pe)
+}
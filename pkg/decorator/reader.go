@@ -0,0 +1,44 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package decorator
+
+import (
+	"context"
+	"io"
+)
+
+// contextReader stops the stream as soon as its context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// NewContextReader returns a reader which fails with the error of the context once the context is done, so that the
+// data movement is aborted even if the consumer of the stream does not check the context itself.
+func NewContextReader(ctx context.Context, reader io.Reader) io.Reader {
+	return &contextReader{
+		ctx:    ctx,
+		reader: reader,
+	}
+}
+
+func (this *contextReader) Read(p []byte) (int, error) {
+	if err := this.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return this.reader.Read(p)
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package decorator
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := NewContextReader(ctx, bytes.NewReader(make([]byte, 2048)))
+
+	buf := make([]byte, 1024)
+	n, err := reader.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 1024, n)
+
+	cancel()
+	n, err = reader.Read(buf)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,337 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package encryption implements the client-side encryption of the snapshots written to a backup repository.
+//
+// The data and the metadata of a snapshot are encrypted as separate streams. A stream starts with a header which
+// records the ID of the key the stream is encrypted with and a random salt, from which the key of the stream is
+// derived. The payload follows in chunks, each of which is sealed with AES-256-GCM. The header and the position of
+// a chunk are authenticated along with it, and the last chunk is flagged, so that a reordered or truncated stream
+// fails to decrypt.
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// streamMagic identifies an encrypted stream
+	streamMagic = "VPVSENC1"
+	// KeySize is the size of the encryption keys in bytes
+	KeySize  = 32
+	saltSize = 32
+	// chunkSize is the maximum size of the plaintext of a chunk
+	chunkSize = 64 * 1024
+	// finalChunkFlag is set in the chunk header of the last chunk of a stream
+	finalChunkFlag = uint32(1) << 31
+	// The label to derive the key of a stream with
+	streamKeyLabel = "velero-plugin-for-vsphere stream key"
+)
+
+// KeyNotFoundError is returned when the key a snapshot is encrypted or to be encrypted with is not available.
+type KeyNotFoundError struct {
+	KeyID  string
+	Source string
+}
+
+func (this *KeyNotFoundError) Error() string {
+	return fmt.Sprintf("The encryption key %s is not found in %s", this.KeyID, this.Source)
+}
+
+// IsKeyNotFound checks if the error is caused by a missing encryption key.
+func IsKeyNotFound(err error) bool {
+	var keyNotFoundErr *KeyNotFoundError
+	return errors.As(err, &keyNotFoundErr)
+}
+
+// Keyring holds the keys of a backup repository. New streams are encrypted with the active key, while a stream is
+// decrypted with the key whose ID is recorded in its header, so that the snapshots encrypted before a key rotation
+// can still be read as long as their keys are kept.
+type Keyring struct {
+	activeKeyID string
+	keys        map[string][]byte
+	// source describes where the keys come from in error messages, e.g. the Secret
+	source string
+}
+
+// NewKeyring returns the keyring of the keys by key ID. A key is either KeySize raw bytes or their base64 encoding.
+func NewKeyring(activeKeyID string, keys map[string][]byte, source string) (*Keyring, error) {
+	keyring := &Keyring{
+		activeKeyID: activeKeyID,
+		keys:        make(map[string][]byte),
+		source:      source,
+	}
+	for keyID, key := range keys {
+		if len(keyID) == 0 || len(keyID) > 255 {
+			return nil, errors.Errorf("Invalid encryption key ID %q in %s, the length of a key ID must be between 1 and 255", keyID, source)
+		}
+		if len(key) != KeySize {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(key)))
+			if err != nil || len(decoded) != KeySize {
+				return nil, errors.Errorf("Invalid encryption key %s in %s, a key must be %d bytes or their base64 encoding", keyID, source, KeySize)
+			}
+			key = decoded
+		}
+		keyring.keys[keyID] = key
+	}
+	return keyring, nil
+}
+
+// ActiveKeyID returns the ID of the key new streams are encrypted with.
+func (this *Keyring) ActiveKeyID() string {
+	return this.activeKeyID
+}
+
+func (this *Keyring) getKey(keyID string) ([]byte, error) {
+	key, ok := this.keys[keyID]
+	if !ok {
+		return nil, &KeyNotFoundError{KeyID: keyID, Source: this.source}
+	}
+	return key, nil
+}
+
+// newStreamCipher returns the cipher of the stream whose key is derived from the key and the salt of the stream.
+func newStreamCipher(key []byte, salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(streamKeyLabel))
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(aead cipher.AEAD, counter uint32) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint32(nonce[len(nonce)-4:], counter)
+	return nonce
+}
+
+func chunkAdditionalData(streamHeader []byte, chunkHeader []byte) []byte {
+	return append(append([]byte(nil), streamHeader...), chunkHeader...)
+}
+
+// encryptReader encrypts the stream read from the source with the active key of the keyring.
+type encryptReader struct {
+	source  io.ReadCloser
+	aead    cipher.AEAD
+	header  []byte
+	counter uint32
+	// pending is the encrypted output which has not been read yet
+	pending []byte
+	plain   []byte
+	done    bool
+}
+
+// NewEncryptReader returns a reader of the source stream encrypted with the active key of the keyring.
+func NewEncryptReader(source io.ReadCloser, keyring *Keyring) (io.ReadCloser, error) {
+	key, err := keyring.getKey(keyring.activeKeyID)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "Failed to generate the salt of the encrypted stream")
+	}
+	aead, err := newStreamCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	var header bytes.Buffer
+	header.WriteString(streamMagic)
+	header.WriteByte(byte(len(keyring.activeKeyID)))
+	header.WriteString(keyring.activeKeyID)
+	header.Write(salt)
+	return &encryptReader{
+		source:  source,
+		aead:    aead,
+		header:  header.Bytes(),
+		pending: header.Bytes(),
+		plain:   make([]byte, chunkSize),
+	}, nil
+}
+
+func (this *encryptReader) Read(p []byte) (int, error) {
+	for len(this.pending) == 0 {
+		if this.done {
+			return 0, io.EOF
+		}
+		if err := this.sealChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, this.pending)
+	this.pending = this.pending[n:]
+	return n, nil
+}
+
+func (this *encryptReader) sealChunk() error {
+	n, err := io.ReadFull(this.source, this.plain)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		this.done = true
+	} else if err != nil {
+		return err
+	}
+	chunkHeader := make([]byte, 4)
+	lengthAndFlag := uint32(n)
+	if this.done {
+		lengthAndFlag |= finalChunkFlag
+	}
+	binary.BigEndian.PutUint32(chunkHeader, lengthAndFlag)
+	sealed := this.aead.Seal(chunkHeader, chunkNonce(this.aead, this.counter), this.plain[:n], chunkAdditionalData(this.header, chunkHeader))
+	this.counter++
+	this.pending = sealed
+	return nil
+}
+
+func (this *encryptReader) Close() error {
+	return this.source.Close()
+}
+
+// decryptReader decrypts an encrypted stream read from the source.
+type decryptReader struct {
+	source  io.ReadCloser
+	aead    cipher.AEAD
+	header  []byte
+	counter uint32
+	// pending is the decrypted output which has not been read yet
+	pending []byte
+	done    bool
+}
+
+// NewDecryptReader returns a reader of the source stream decrypted with the key recorded in the stream. A stream
+// which is not encrypted is refused, unless allowUnencrypted returns true, e.g. for a snapshot written before the
+// encryption was enabled on the repository. The stream is then returned as is. A nil allowUnencrypted refuses all
+// the streams which are not encrypted.
+func NewDecryptReader(source io.ReadCloser, keyring *Keyring, allowUnencrypted func() (bool, error)) (io.ReadCloser, error) {
+	magic := make([]byte, len(streamMagic))
+	n, err := io.ReadFull(source, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if string(magic[:n]) != streamMagic {
+		allowed := false
+		if allowUnencrypted != nil {
+			if allowed, err = allowUnencrypted(); err != nil {
+				return nil, err
+			}
+		}
+		if !allowed {
+			return nil, errors.New("The stream is not encrypted, and it was not written before the encryption was enabled")
+		}
+		return &prefixedReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(magic[:n]), source),
+			closer: source,
+		}, nil
+	}
+	keyIDLength := make([]byte, 1)
+	if _, err := io.ReadFull(source, keyIDLength); err != nil {
+		return nil, errors.Wrap(err, "Failed to read the header of the encrypted stream")
+	}
+	keyIDAndSalt := make([]byte, int(keyIDLength[0])+saltSize)
+	if _, err := io.ReadFull(source, keyIDAndSalt); err != nil {
+		return nil, errors.Wrap(err, "Failed to read the header of the encrypted stream")
+	}
+	keyID := string(keyIDAndSalt[:keyIDLength[0]])
+	key, err := keyring.getKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newStreamCipher(key, keyIDAndSalt[keyIDLength[0]:])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		source: source,
+		aead:   aead,
+		header: append(append(magic, keyIDLength...), keyIDAndSalt...),
+	}, nil
+}
+
+func (this *decryptReader) Read(p []byte) (int, error) {
+	for len(this.pending) == 0 {
+		if this.done {
+			// Nothing may follow the last chunk
+			if n, _ := this.source.Read(make([]byte, 1)); n > 0 {
+				return 0, errors.New("Unexpected data after the end of the encrypted stream")
+			}
+			return 0, io.EOF
+		}
+		if err := this.openChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, this.pending)
+	this.pending = this.pending[n:]
+	return n, nil
+}
+
+func (this *decryptReader) openChunk() error {
+	chunkHeader := make([]byte, 4)
+	if _, err := io.ReadFull(this.source, chunkHeader); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return errors.Wrap(err, "The encrypted stream is truncated")
+	}
+	lengthAndFlag := binary.BigEndian.Uint32(chunkHeader)
+	length := lengthAndFlag &^ finalChunkFlag
+	if length > chunkSize {
+		return errors.Errorf("Invalid chunk length %d in the encrypted stream", length)
+	}
+	sealed := make([]byte, int(length)+this.aead.Overhead())
+	if _, err := io.ReadFull(this.source, sealed); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return errors.Wrap(err, "The encrypted stream is truncated")
+	}
+	plain, err := this.aead.Open(sealed[:0], chunkNonce(this.aead, this.counter), sealed, chunkAdditionalData(this.header, chunkHeader))
+	if err != nil {
+		return errors.Wrapf(err, "Failed to decrypt chunk %d of the encrypted stream", this.counter)
+	}
+	this.counter++
+	this.pending = plain
+	this.done = lengthAndFlag&finalChunkFlag != 0
+	return nil
+}
+
+func (this *decryptReader) Close() error {
+	return this.source.Close()
+}
+
+// prefixedReadCloser reads the bytes already consumed from the source before the rest of the source.
+type prefixedReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (this *prefixedReadCloser) Close() error {
+	return this.closer.Close()
+}
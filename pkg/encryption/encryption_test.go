@@ -0,0 +1,320 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/fsrepository"
+)
+
+func newTestKeyring(t *testing.T, activeKeyID string, keyIDs ...string) *Keyring {
+	keys := make(map[string][]byte)
+	for i, keyID := range keyIDs {
+		keys[keyID] = bytes.Repeat([]byte{byte(i + 1)}, KeySize)
+	}
+	keyring, err := NewKeyring(activeKeyID, keys, "Secret velero/test-keys")
+	require.NoError(t, err)
+	return keyring
+}
+
+func encrypt(t *testing.T, plain []byte, keyring *Keyring) []byte {
+	reader, err := NewEncryptReader(ioutil.NopCloser(bytes.NewReader(plain)), keyring)
+	require.NoError(t, err)
+	encrypted, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	return encrypted
+}
+
+func decrypt(encrypted []byte, keyring *Keyring) ([]byte, error) {
+	return decryptAllowing(encrypted, keyring, nil)
+}
+
+func decryptAllowing(encrypted []byte, keyring *Keyring, allowUnencrypted func() (bool, error)) ([]byte, error) {
+	reader, err := NewDecryptReader(ioutil.NopCloser(bytes.NewReader(encrypted)), keyring, allowUnencrypted)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+func TestNewKeyring(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KeySize)
+	keyring, err := NewKeyring("key-2", map[string][]byte{
+		"key-1": key,
+		"key-2": []byte(base64.StdEncoding.EncodeToString(key) + "\n"),
+	}, "test")
+	require.NoError(t, err)
+	assert.Equal(t, "key-2", keyring.ActiveKeyID())
+	for _, keyID := range []string{"key-1", "key-2"} {
+		actual, err := keyring.getKey(keyID)
+		require.NoError(t, err)
+		assert.Equal(t, key, actual)
+	}
+
+	_, err = NewKeyring("key-1", map[string][]byte{"key-1": []byte("too short")}, "test")
+	assert.Error(t, err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyring := newTestKeyring(t, "key-1", "key-1")
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, 3*chunkSize + 17} {
+		plain := make([]byte, size)
+		for i := range plain {
+			plain[i] = byte(i % 251)
+		}
+		encrypted := encrypt(t, plain, keyring)
+		if size > 16 {
+			assert.False(t, bytes.Contains(encrypted, plain[:16]), "The plaintext is visible in the encrypted stream")
+		}
+		decrypted, err := decrypt(encrypted, keyring)
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, plain, decrypted, "size %d", size)
+	}
+}
+
+func TestDecryptTamperedStream(t *testing.T) {
+	keyring := newTestKeyring(t, "key-1", "key-1")
+	plain := bytes.Repeat([]byte("data"), chunkSize)
+	encrypted := encrypt(t, plain, keyring)
+
+	// Truncated after the first chunk
+	firstChunkEnd := len(streamMagic) + 1 + len("key-1") + saltSize + 4 + chunkSize + 16
+	_, err := decrypt(encrypted[:firstChunkEnd], keyring)
+	assert.Error(t, err)
+
+	// Flipped bit
+	tampered := append([]byte(nil), encrypted...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = decrypt(tampered, keyring)
+	assert.Error(t, err)
+
+	// Trailing data
+	_, err = decrypt(append(append([]byte(nil), encrypted...), 0), keyring)
+	assert.Error(t, err)
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKeyring := newTestKeyring(t, "key-1", "key-1")
+	encrypted := encrypt(t, []byte("encrypted with the old key"), oldKeyring)
+
+	// The key ID recorded in the stream selects the key to decrypt it with
+	rotatedKeyring := newTestKeyring(t, "key-2", "key-1", "key-2")
+	decrypted, err := decrypt(encrypted, rotatedKeyring)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("encrypted with the old key"), decrypted)
+
+	_, err = decrypt(encrypted, newTestKeyring(t, "key-2", "key-2"))
+	require.Error(t, err)
+	assert.True(t, IsKeyNotFound(errors.Wrap(err, "Failed to restore")))
+	assert.Contains(t, err.Error(), "key-1")
+
+	_, err = NewEncryptReader(ioutil.NopCloser(bytes.NewReader(nil)), newTestKeyring(t, "key-3", "key-2"))
+	assert.True(t, IsKeyNotFound(err))
+}
+
+func TestDecryptPlaintext(t *testing.T) {
+	keyring := newTestKeyring(t, "key-1", "key-1")
+	allow := func() (bool, error) { return true, nil }
+	refuse := func() (bool, error) { return false, nil }
+	for _, plain := range [][]byte{nil, []byte("VPV"), []byte("written before the encryption was enabled")} {
+		_, err := decrypt(plain, keyring)
+		assert.Error(t, err)
+		_, err = decryptAllowing(plain, keyring, refuse)
+		assert.Error(t, err)
+
+		decrypted, err := decryptAllowing(plain, keyring, allow)
+		require.NoError(t, err)
+		assert.Equal(t, string(plain), string(decrypted))
+	}
+
+	// The encrypted streams do not depend on whether the plaintext is allowed
+	decrypted, err := decryptAllowing(encrypt(t, []byte("encrypted"), keyring), keyring, refuse)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("encrypted"), decrypted)
+}
+
+type fakeProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	info     astrolabe.ProtectedEntityInfo
+	data     []byte
+	metadata []byte
+}
+
+func (this fakeProtectedEntity) GetID() astrolabe.ProtectedEntityID {
+	return this.info.GetID()
+}
+
+func (this fakeProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return this.info, nil
+}
+
+func (this fakeProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.data)), nil
+}
+
+func (this fakeProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.metadata)), nil
+}
+
+func TestEncryptedRepository(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "encryption")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	fsPETM, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+	petm := NewProtectedEntityTypeManager(fsPETM, newTestKeyring(t, "key-1", "key-1"), logrus.New())
+
+	peID, err := astrolabe.NewProtectedEntityIDFromString("ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:67469e1c-50a8-4f63-9a6a-ad8a2265197c")
+	require.NoError(t, err)
+	transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("fake", map[string]string{})}
+	sourcePE := fakeProtectedEntity{
+		info:     astrolabe.NewProtectedEntityInfo(peID, "fake-pe", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}),
+		data:     bytes.Repeat([]byte("secret data "), 10000),
+		metadata: []byte("secret metadata"),
+	}
+	_, err = petm.Copy(ctx, sourcePE, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+
+	readAll := func(getReader func(context.Context) (io.ReadCloser, error)) ([]byte, error) {
+		reader, err := getReader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+
+	// The repository only holds the encrypted snapshot
+	rawPE, err := fsPETM.GetProtectedEntity(ctx, peID)
+	require.NoError(t, err)
+	rawData, err := readAll(rawPE.GetDataReader)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(rawData, []byte("secret data")))
+	rawMetadata, err := readAll(rawPE.GetMetadataReader)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(rawMetadata, []byte("secret metadata")))
+
+	repoPE, err := petm.GetProtectedEntity(ctx, peID)
+	require.NoError(t, err)
+	data, err := readAll(repoPE.GetDataReader)
+	require.NoError(t, err)
+	assert.Equal(t, sourcePE.data, data)
+	metadata, err := readAll(repoPE.GetMetadataReader)
+	require.NoError(t, err)
+	assert.Equal(t, sourcePE.metadata, metadata)
+
+	peIDs, err := petm.GetProtectedEntitiesByIDPrefix(ctx, "ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:")
+	require.NoError(t, err)
+	assert.Equal(t, []astrolabe.ProtectedEntityID{peID}, peIDs)
+
+	// Restoring without the key fails with a missing key error
+	otherPETM := NewProtectedEntityTypeManager(fsPETM, newTestKeyring(t, "key-2", "key-2"), logrus.New())
+	repoPE, err = otherPETM.GetProtectedEntity(ctx, peID)
+	require.NoError(t, err)
+	_, err = readAll(repoPE.GetMetadataReader)
+	assert.True(t, IsKeyNotFound(err))
+}
+
+func TestRecordUnencryptedSnapshots(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "encryption")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	fsPETM, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+	petm := NewProtectedEntityTypeManager(fsPETM, newTestKeyring(t, "key-1", "key-1"), logrus.New())
+
+	transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("fake", map[string]string{})}
+	newSourcePE := func(id string, data string) fakeProtectedEntity {
+		peID, err := astrolabe.NewProtectedEntityIDFromString(id)
+		require.NoError(t, err)
+		return fakeProtectedEntity{
+			info:     astrolabe.NewProtectedEntityInfo(peID, "fake-pe", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}),
+			data:     []byte(data),
+			metadata: []byte("metadata of " + id),
+		}
+	}
+	readData := func(peID astrolabe.ProtectedEntityID) ([]byte, error) {
+		pe, err := petm.GetProtectedEntity(ctx, peID)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := pe.GetDataReader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+
+	// Written before the encryption was enabled
+	before := newSourcePE("ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:11111111-50a8-4f63-9a6a-ad8a2265197c", "written before")
+	_, err = fsPETM.Copy(ctx, before, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+	encrypted := newSourcePE("ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:22222222-50a8-4f63-9a6a-ad8a2265197c", "encrypted")
+	_, err = petm.Copy(ctx, encrypted, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+
+	_, err = readData(before.GetID())
+	assert.Error(t, err, "The snapshot which is not encrypted is read before it is recorded")
+
+	require.NoError(t, petm.RecordUnencryptedSnapshots(ctx))
+	data, err := readData(before.GetID())
+	require.NoError(t, err)
+	assert.Equal(t, before.data, data)
+	data, err = readData(encrypted.GetID())
+	require.NoError(t, err)
+	assert.Equal(t, encrypted.data, data)
+	_, err = fsPETM.GetProtectedEntity(ctx, unencryptedMarkerID(encrypted.GetID()))
+	assert.Error(t, err, "The encrypted snapshot is recorded as not encrypted")
+	// Recording again is a no-op
+	require.NoError(t, petm.RecordUnencryptedSnapshots(ctx))
+
+	// Planted in the repository after the encryption was enabled
+	planted := newSourcePE("ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:33333333-50a8-4f63-9a6a-ad8a2265197c", "planted")
+	_, err = fsPETM.Copy(ctx, planted, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+	_, err = readData(planted.GetID())
+	assert.Error(t, err)
+
+	// A mark which is not encrypted with the key of the repository does not record the snapshot
+	_, err = fsPETM.Copy(ctx, newUnencryptedMarker(planted.GetID()), make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+	_, err = readData(planted.GetID())
+	assert.Error(t, err)
+
+	markerID, ok := UnencryptedSnapshotID(unencryptedMarkerID(before.GetID()))
+	assert.True(t, ok)
+	assert.Equal(t, before.GetID(), markerID)
+	_, ok = UnencryptedSnapshotID(before.GetID())
+	assert.False(t, ok)
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryption
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/decorator"
+)
+
+// The prefix of the snapshot ID of the mark which records that a snapshot was written to the repository before the
+// encryption was enabled on it, see RecordUnencryptedSnapshots
+const unencryptedMarkerPrefix = "unencrypted-"
+
+// ProtectedEntityTypeManager encrypts the data and metadata of the snapshots copied to the repository it wraps, and
+// decrypts them when they are read back. The PE info stays in the clear, as it is needed to locate the snapshots.
+type ProtectedEntityTypeManager struct {
+	decorator.ProtectedEntityTypeManager
+	keyring *Keyring
+	logger  logrus.FieldLogger
+}
+
+func NewProtectedEntityTypeManager(petm astrolabe.ProtectedEntityTypeManager, keyring *Keyring,
+	logger logrus.FieldLogger) *ProtectedEntityTypeManager {
+	encryptionPETM := &ProtectedEntityTypeManager{
+		keyring: keyring,
+		logger:  logger,
+	}
+	encryptionPETM.ProtectedEntityTypeManager = decorator.NewProtectedEntityTypeManager(petm, func(pe astrolabe.ProtectedEntity) astrolabe.ProtectedEntity {
+		return decryptingProtectedEntity{
+			ProtectedEntity: pe,
+			petm:            encryptionPETM,
+		}
+	})
+	return encryptionPETM
+}
+
+func (this *ProtectedEntityTypeManager) Copy(ctx context.Context, pe astrolabe.ProtectedEntity, params map[string]map[string]interface{},
+	options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntity, error) {
+	// Fail before anything is written if the active key is missing
+	if _, err := this.keyring.getKey(this.keyring.activeKeyID); err != nil {
+		return nil, err
+	}
+	this.logger.Debugf("Encrypting the snapshot %s with the key %s", pe.GetID().String(), this.keyring.activeKeyID)
+	repoPE, err := this.ProtectedEntityTypeManager.Copy(ctx, encryptingProtectedEntity{
+		ProtectedEntity: pe,
+		keyring:         this.keyring,
+	}, params, options)
+	if err != nil {
+		return nil, err
+	}
+	return decryptingProtectedEntity{
+		ProtectedEntity: repoPE,
+		petm:            this,
+	}, nil
+}
+
+// RecordUnencryptedSnapshots marks the snapshots in the repository which are not encrypted, when the encryption is
+// enabled on the repository. Only the marked snapshots are read without being decrypted, any other snapshot which is
+// not encrypted is refused, as it may have been planted in the repository. The marks are encrypted themselves, so
+// that they cannot be forged without the keys.
+func (this *ProtectedEntityTypeManager) RecordUnencryptedSnapshots(ctx context.Context) error {
+	peIDs, err := this.GetProtectedEntities(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Failed to list the snapshots of the repository")
+	}
+	for _, peID := range peIDs {
+		if !peID.HasSnapshot() || IsUnencryptedMarker(peID) {
+			continue
+		}
+		encrypted, err := this.isEncrypted(ctx, peID)
+		if err != nil {
+			return err
+		}
+		if encrypted {
+			continue
+		}
+		recorded, err := this.isRecordedUnencrypted(ctx, peID)
+		if err != nil {
+			return err
+		}
+		if recorded {
+			continue
+		}
+		this.logger.Infof("Recording the snapshot %s as written before the encryption was enabled", peID.String())
+		if _, err := this.Copy(ctx, newUnencryptedMarker(peID), make(map[string]map[string]interface{}), astrolabe.AllocateNewObject); err != nil {
+			return errors.Wrapf(err, "Failed to record the snapshot %s as written before the encryption was enabled", peID.String())
+		}
+	}
+	return nil
+}
+
+// isEncrypted returns true if the streams of the snapshot in the repository are encrypted. The streams of a snapshot
+// are either all encrypted or none is, so only the first stream of the snapshot is checked.
+func (this *ProtectedEntityTypeManager) isEncrypted(ctx context.Context, id astrolabe.ProtectedEntityID) (bool, error) {
+	// The snapshot is read as stored in the repository
+	pe, err := this.ProtectedEntityTypeManager.ProtectedEntityTypeManager.GetProtectedEntity(ctx, id)
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to get the snapshot %s", id.String())
+	}
+	for _, getReader := range []func(context.Context) (io.ReadCloser, error){pe.GetMetadataReader, pe.GetDataReader} {
+		reader, err := getReader(ctx)
+		if err != nil {
+			return false, errors.Wrapf(err, "Failed to read the snapshot %s", id.String())
+		}
+		if reader == nil {
+			continue
+		}
+		magic := make([]byte, len(streamMagic))
+		n, err := io.ReadFull(reader, magic)
+		reader.Close()
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return false, errors.Wrapf(err, "Failed to read the snapshot %s", id.String())
+		}
+		return string(magic[:n]) == streamMagic, nil
+	}
+	// A snapshot without streams holds nothing to decrypt
+	return true, nil
+}
+
+// isRecordedUnencrypted returns true if the snapshot was recorded as written before the encryption was enabled on the
+// repository. The mark is only trusted if it decrypts to the ID of the snapshot.
+func (this *ProtectedEntityTypeManager) isRecordedUnencrypted(ctx context.Context, id astrolabe.ProtectedEntityID) (bool, error) {
+	markerPE, err := this.GetProtectedEntity(ctx, unencryptedMarkerID(id))
+	if err != nil {
+		return false, nil
+	}
+	reader, err := markerPE.GetMetadataReader(ctx)
+	if err != nil {
+		if IsKeyNotFound(err) {
+			return false, err
+		}
+		this.logger.WithError(err).Warnf("Ignoring the invalid mark of the snapshot %s", id.String())
+		return false, nil
+	}
+	if reader == nil {
+		return false, nil
+	}
+	defer reader.Close()
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		this.logger.WithError(err).Warnf("Ignoring the invalid mark of the snapshot %s", id.String())
+		return false, nil
+	}
+	return string(buf) == id.String(), nil
+}
+
+// IsUnencryptedMarker returns true if the snapshot records that another snapshot was written to the repository before
+// the encryption was enabled on it, see RecordUnencryptedSnapshots. The mark is not referenced by any backup.
+func IsUnencryptedMarker(id astrolabe.ProtectedEntityID) bool {
+	return strings.HasPrefix(id.GetSnapshotID().GetID(), unencryptedMarkerPrefix)
+}
+
+// UnencryptedSnapshotID returns the snapshot recorded by the mark, or false if the snapshot is not a mark.
+func UnencryptedSnapshotID(marker astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntityID, bool) {
+	if !IsUnencryptedMarker(marker) {
+		return astrolabe.ProtectedEntityID{}, false
+	}
+	return marker.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID(strings.TrimPrefix(marker.GetSnapshotID().GetID(), unencryptedMarkerPrefix))), true
+}
+
+func unencryptedMarkerID(id astrolabe.ProtectedEntityID) astrolabe.ProtectedEntityID {
+	return id.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID(unencryptedMarkerPrefix + id.GetSnapshotID().GetID()))
+}
+
+// unencryptedMarker is the source of the mark of a snapshot which is not encrypted, whose metadata is the ID of the
+// snapshot.
+type unencryptedMarker struct {
+	astrolabe.ProtectedEntity
+	snapshotID astrolabe.ProtectedEntityID
+}
+
+func newUnencryptedMarker(snapshotID astrolabe.ProtectedEntityID) astrolabe.ProtectedEntity {
+	return unencryptedMarker{snapshotID: snapshotID}
+}
+
+func (this unencryptedMarker) GetID() astrolabe.ProtectedEntityID {
+	return unencryptedMarkerID(this.snapshotID)
+}
+
+func (this unencryptedMarker) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("marker", map[string]string{})}
+	return astrolabe.NewProtectedEntityInfo(this.GetID(), this.GetID().String(), []astrolabe.DataTransport{}, transports,
+		[]astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}), nil
+}
+
+func (this unencryptedMarker) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+func (this unencryptedMarker) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(this.snapshotID.String())), nil
+}
+
+func (this *ProtectedEntityTypeManager) CopyFromInfo(ctx context.Context, info astrolabe.ProtectedEntityInfo, params map[string]map[string]interface{},
+	options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntity, error) {
+	// The data would be copied from the transports of the info, bypassing the encryption
+	return nil, errors.New("CopyFromInfo is not supported by an encrypted repository")
+}
+
+// encryptingProtectedEntity is the source of a copy to the repository.
+type encryptingProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	keyring *Keyring
+}
+
+func (this encryptingProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return this.encrypt(this.ProtectedEntity.GetDataReader(ctx))
+}
+
+func (this encryptingProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return this.encrypt(this.ProtectedEntity.GetMetadataReader(ctx))
+}
+
+func (this encryptingProtectedEntity) encrypt(reader io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	encryptReader, err := NewEncryptReader(reader, this.keyring)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return encryptReader, nil
+}
+
+// decryptingProtectedEntity is a snapshot in the repository.
+type decryptingProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	petm *ProtectedEntityTypeManager
+}
+
+func (this decryptingProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	reader, err := this.ProtectedEntity.GetDataReader(ctx)
+	return this.decrypt(ctx, reader, err)
+}
+
+func (this decryptingProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	reader, err := this.ProtectedEntity.GetMetadataReader(ctx)
+	return this.decrypt(ctx, reader, err)
+}
+
+func (this decryptingProtectedEntity) decrypt(ctx context.Context, reader io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	id := this.GetID()
+	allowUnencrypted := func() (bool, error) {
+		if IsUnencryptedMarker(id) {
+			return false, nil
+		}
+		return this.petm.isRecordedUnencrypted(ctx, id)
+	}
+	decryptReader, err := NewDecryptReader(reader, this.petm.keyring, allowUnencrypted)
+	if err != nil {
+		reader.Close()
+		return nil, errors.Wrapf(err, "Failed to decrypt the snapshot %s", this.GetID().String())
+	}
+	return decryptReader, nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsrepository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/decorator"
+)
+
+type ProtectedEntity struct {
+	rpetm  *ProtectedEntityTypeManager
+	peinfo astrolabe.ProtectedEntityInfo
+}
+
+func (this ProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return this.peinfo, nil
+}
+
+func (this ProtectedEntity) GetCombinedInfo(ctx context.Context) ([]astrolabe.ProtectedEntityInfo, error) {
+	return nil, errors.New("GetCombinedInfo not supported")
+}
+
+func (this ProtectedEntity) Snapshot(ctx context.Context, params map[string]map[string]interface{}) (astrolabe.ProtectedEntitySnapshotID, error) {
+	return astrolabe.ProtectedEntitySnapshotID{}, errors.New("Snapshot not supported")
+}
+
+func (this ProtectedEntity) ListSnapshots(ctx context.Context) ([]astrolabe.ProtectedEntitySnapshotID, error) {
+	peID := this.peinfo.GetID()
+	idPrefix := peID.GetPeType() + ":" + peID.GetID()
+	retPEIDs, err := this.rpetm.GetProtectedEntitiesByIDPrefix(ctx, idPrefix)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get PEs by the id prefix, %s", idPrefix)
+	}
+	retPESnapshotIDs := make([]astrolabe.ProtectedEntitySnapshotID, len(retPEIDs))
+	for index, retPEID := range retPEIDs {
+		retPESnapshotIDs[index] = retPEID.GetSnapshotID()
+	}
+	return retPESnapshotIDs, nil
+}
+
+func (this ProtectedEntity) DeleteSnapshot(ctx context.Context, snapshotToDelete astrolabe.ProtectedEntitySnapshotID,
+	params map[string]map[string]interface{}) (bool, error) {
+	id := this.peinfo.GetID()
+	// Remove the PE info first so that a partially deleted snapshot is never visible
+	for _, name := range []string{this.rpetm.peinfoName(id), this.rpetm.metadataName(id), this.rpetm.dataName(id)} {
+		for _, fileName := range []string{name, name + tmpSuffix} {
+			if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+				return false, errors.Wrapf(err, "Failed to delete %s from the repository", fileName)
+			}
+		}
+	}
+	return true, nil
+}
+
+func (this ProtectedEntity) GetInfoForSnapshot(ctx context.Context, snapshotID astrolabe.ProtectedEntitySnapshotID) (*astrolabe.ProtectedEntityInfo, error) {
+	return nil, errors.New("GetInfoForSnapshot not supported")
+}
+
+func (this ProtectedEntity) GetComponents(ctx context.Context) ([]astrolabe.ProtectedEntity, error) {
+	return nil, errors.New("GetComponents not supported")
+}
+
+func (this ProtectedEntity) GetID() astrolabe.ProtectedEntityID {
+	return this.peinfo.GetID()
+}
+
+func (this ProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	if len(this.peinfo.GetDataTransports()) > 0 {
+		return os.Open(this.rpetm.dataName(this.GetID()))
+	}
+	return nil, nil
+}
+
+func (this ProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	if len(this.peinfo.GetMetadataTransports()) > 0 {
+		return os.Open(this.rpetm.metadataName(this.GetID()))
+	}
+	return nil, nil
+}
+
+func (this ProtectedEntity) Overwrite(ctx context.Context, sourcePE astrolabe.ProtectedEntity, params map[string]map[string]interface{},
+	overwriteComponents bool) error {
+	return errors.New("Overwrite not supported")
+}
+
+// copy writes the data and metadata streams to the repository, followed by the PE info. As the PE info is
+// written last, a snapshot is only visible in the repository once all of its streams are complete.
+func (this *ProtectedEntity) copy(ctx context.Context, dataReader io.Reader, metadataReader io.Reader) (err error) {
+	peInfo := this.peinfo
+	defer func() {
+		if err != nil {
+			this.rpetm.logger.WithError(err).Infof("Cleaning up the partially copied PE %s", peInfo.GetID().String())
+			if _, deleteErr := this.DeleteSnapshot(context.Background(), peInfo.GetID().GetSnapshotID(), make(map[string]map[string]interface{})); deleteErr != nil {
+				this.rpetm.logger.WithError(deleteErr).Errorf("Failed to clean up the partially copied PE %s", peInfo.GetID().String())
+			}
+		}
+	}()
+
+	peInfoBuf, err := json.Marshal(peInfo)
+	if err != nil {
+		return err
+	}
+	if len(peInfoBuf) > maxPEInfoSize {
+		return errors.New("JSON for pe info > 16K")
+	}
+
+	if dataReader != nil {
+		if err = writeFile(ctx, this.rpetm.dataName(peInfo.GetID()), dataReader); err != nil {
+			return err
+		}
+	}
+	if metadataReader != nil {
+		if err = writeFile(ctx, this.rpetm.metadataName(peInfo.GetID()), metadataReader); err != nil {
+			return err
+		}
+	}
+	return writeFile(ctx, this.rpetm.peinfoName(peInfo.GetID()), bytes.NewReader(peInfoBuf))
+}
+
+// writeFile writes the stream to a temporary file first and renames it once the stream is complete,
+// so that readers of the repository never observe a partially written file.
+func writeFile(ctx context.Context, name string, reader io.Reader) error {
+	tmpName := name + tmpSuffix
+	file, err := os.OpenFile(tmpName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create %s", tmpName)
+	}
+	_, err = io.Copy(file, decorator.NewContextReader(ctx, reader))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return errors.Wrapf(err, "Failed to write %s", tmpName)
+	}
+	if err := os.Rename(tmpName, name); err != nil {
+		os.Remove(tmpName)
+		return errors.Wrapf(err, "Failed to rename %s to %s", tmpName, name)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsrepository
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+)
+
+/*
+ * ProtectedEntityTypeManager for a file system repository acts as a passive, generic Protected Entity Type Manager,
+ * the same way as the S3 repository of astrolabe does. The repository lives in a directory, typically an NFS export
+ * mounted into the pods, and Protected Entities served by the type manager are always read-only.
+ *
+ * The layout of the repository mirrors the layout of the S3 repository
+ *    <path>/<prefix>/<type>/{peinfo, md, data}/<peid>[, .md, .data]
+ * The PEID must have a snapshot component.
+ */
+type ProtectedEntityTypeManager struct {
+	typeName                             string
+	objectDir, peinfoDir, mdDir, dataDir string
+	logger                               logrus.FieldLogger
+}
+
+const (
+	TransportType      = "file"
+	TransportPathParam = "path"
+	mdSuffix           = ".md"
+	dataSuffix         = ".data"
+	tmpSuffix          = ".tmp"
+	maxPEInfoSize      = 16 * 1024
+)
+
+func NewFileSystemRepositoryProtectedEntityTypeManager(typeName string, path string, prefix string,
+	logger logrus.FieldLogger) (*ProtectedEntityTypeManager, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to access the repository path %s", path)
+	}
+	if !fileInfo.IsDir() {
+		return nil, errors.Errorf("The repository path %s is not a directory", path)
+	}
+
+	objectDir := filepath.Join(path, prefix, typeName)
+	returnPETM := ProtectedEntityTypeManager{
+		typeName:  typeName,
+		objectDir: objectDir,
+		peinfoDir: filepath.Join(objectDir, "peinfo"),
+		mdDir:     filepath.Join(objectDir, "md"),
+		dataDir:   filepath.Join(objectDir, "data"),
+		logger:    logger,
+	}
+	for _, dir := range []string{returnPETM.peinfoDir, returnPETM.mdDir, returnPETM.dataDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.Wrapf(err, "Failed to create the repository directory %s", dir)
+		}
+	}
+	logger.Infof("Created file system repo type=%s path=%s prefix=%s", typeName, path, prefix)
+	return &returnPETM, nil
+}
+
+func (this *ProtectedEntityTypeManager) peinfoName(id astrolabe.ProtectedEntityID) string {
+	if !id.HasSnapshot() {
+		panic("Cannot store objects that do not have snapshots")
+	}
+	return filepath.Join(this.peinfoDir, id.String())
+}
+
+func (this *ProtectedEntityTypeManager) metadataName(id astrolabe.ProtectedEntityID) string {
+	if !id.HasSnapshot() {
+		panic("Cannot store objects that do not have snapshots")
+	}
+	return filepath.Join(this.mdDir, id.String()+mdSuffix)
+}
+
+func (this *ProtectedEntityTypeManager) dataName(id astrolabe.ProtectedEntityID) string {
+	if !id.HasSnapshot() {
+		panic("Cannot store objects that do not have snapshots")
+	}
+	return filepath.Join(this.dataDir, id.String()+dataSuffix)
+}
+
+func (this *ProtectedEntityTypeManager) GetTypeName() string {
+	return this.typeName
+}
+
+func (this *ProtectedEntityTypeManager) GetProtectedEntity(ctx context.Context, id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntity, error) {
+	peinfoName := this.peinfoName(id)
+	peinfoBuf, err := ioutil.ReadFile(peinfoName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read the PE info file %s", peinfoName)
+	}
+	peInfo := astrolabe.ProtectedEntityInfoImpl{}
+	if err := json.Unmarshal(peinfoBuf, &peInfo); err != nil {
+		return nil, errors.Wrapf(err, "Failed to unmarshal the PE info for %s", id.String())
+	}
+	return ProtectedEntity{
+		rpetm:  this,
+		peinfo: peInfo,
+	}, nil
+}
+
+func (this *ProtectedEntityTypeManager) GetProtectedEntitiesByIDPrefix(ctx context.Context, idPrefix string) ([]astrolabe.ProtectedEntityID, error) {
+	fileInfos, err := ioutil.ReadDir(this.peinfoDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the PE info directory %s", this.peinfoDir)
+	}
+	retPEIDs := make([]astrolabe.ProtectedEntityID, 0)
+	for _, fileInfo := range fileInfos {
+		name := fileInfo.Name()
+		// Skip the PE info which is still being written
+		if fileInfo.IsDir() || strings.HasSuffix(name, tmpSuffix) || !strings.HasPrefix(name, idPrefix) {
+			continue
+		}
+		retPEID, err := astrolabe.NewProtectedEntityIDFromString(name)
+		if err != nil {
+			this.logger.WithError(err).Warnf("Skipping unexpected file %s in the repository", name)
+			continue
+		}
+		retPEIDs = append(retPEIDs, retPEID)
+	}
+	return retPEIDs, nil
+}
+
+func (this *ProtectedEntityTypeManager) GetProtectedEntities(ctx context.Context) ([]astrolabe.ProtectedEntityID, error) {
+	return this.GetProtectedEntitiesByIDPrefix(ctx, "")
+}
+
+func (this *ProtectedEntityTypeManager) Copy(ctx context.Context, sourcePE astrolabe.ProtectedEntity, params map[string]map[string]interface{},
+	options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntity, error) {
+	sourcePEInfo, err := sourcePE.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dataReader, err := sourcePE.GetDataReader(ctx)
+	if dataReader != nil {
+		defer func() {
+			if err := dataReader.Close(); err != nil {
+				this.logger.Errorf("The deferred data reader is closed with error, %v", err)
+			}
+		}()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadataReader, err := sourcePE.GetMetadataReader(ctx)
+	if metadataReader != nil {
+		defer metadataReader.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return this.copyInt(ctx, sourcePEInfo, options, dataReader, metadataReader)
+}
+
+func (this *ProtectedEntityTypeManager) CopyFromInfo(ctx context.Context, sourcePEInfo astrolabe.ProtectedEntityInfo, params map[string]map[string]interface{},
+	options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntity, error) {
+	return nil, errors.New("CopyFromInfo not supported")
+}
+
+func (this *ProtectedEntityTypeManager) copyInt(ctx context.Context, sourcePEInfo astrolabe.ProtectedEntityInfo,
+	options astrolabe.CopyCreateOptions, dataReader io.Reader, metadataReader io.Reader) (astrolabe.ProtectedEntity, error) {
+	id := sourcePEInfo.GetID()
+	if id.GetPeType() != this.typeName {
+		return nil, errors.New(id.GetPeType() + " is not of type " + this.typeName)
+	}
+	if options == astrolabe.AllocateObjectWithID {
+		return nil, errors.New("AllocateObjectWithID not supported")
+	}
+	if options == astrolabe.UpdateExistingObject {
+		return nil, errors.New("UpdateExistingObject not supported")
+	}
+
+	_, err := this.GetProtectedEntity(ctx, id)
+	if err == nil {
+		return nil, errors.New("id " + id.String() + " already exists")
+	}
+
+	dataTransports := []astrolabe.DataTransport{}
+	if len(sourcePEInfo.GetDataTransports()) > 0 {
+		dataTransports = []astrolabe.DataTransport{newDataTransportForFile(this.dataName(id))}
+	}
+	metadataTransports := []astrolabe.DataTransport{}
+	if len(sourcePEInfo.GetMetadataTransports()) > 0 {
+		metadataTransports = []astrolabe.DataTransport{newDataTransportForFile(this.metadataName(id))}
+	}
+
+	rPEInfo := astrolabe.NewProtectedEntityInfo(sourcePEInfo.GetID(), sourcePEInfo.GetName(),
+		dataTransports, metadataTransports, []astrolabe.DataTransport{}, sourcePEInfo.GetComponentIDs())
+
+	rpe := ProtectedEntity{
+		rpetm:  this,
+		peinfo: rPEInfo,
+	}
+
+	// Clean up any leftover of a previous attempt
+	if _, err = rpe.DeleteSnapshot(ctx, id.GetSnapshotID(), make(map[string]map[string]interface{})); err != nil {
+		return nil, err
+	}
+	if err = rpe.copy(ctx, dataReader, metadataReader); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
+	return rpe, nil
+}
+
+func newDataTransportForFile(path string) astrolabe.DataTransport {
+	return astrolabe.NewDataTransport(TransportType, map[string]string{
+		TransportPathParam: path,
+	})
+}
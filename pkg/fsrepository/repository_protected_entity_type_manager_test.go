@@ -0,0 +1,140 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsrepository
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+)
+
+type fakeProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	info     astrolabe.ProtectedEntityInfo
+	data     []byte
+	metadata []byte
+}
+
+func (this fakeProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return this.info, nil
+}
+
+func (this fakeProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.data)), nil
+}
+
+func (this fakeProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.metadata)), nil
+}
+
+func newFakeProtectedEntity(t *testing.T, idStr string) fakeProtectedEntity {
+	peID, err := astrolabe.NewProtectedEntityIDFromString(idStr)
+	require.NoError(t, err)
+	transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("fake", map[string]string{})}
+	return fakeProtectedEntity{
+		info:     astrolabe.NewProtectedEntityInfo(peID, "fake-pe", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}),
+		data:     []byte("data of " + idStr),
+		metadata: []byte("metadata of " + idStr),
+	}
+}
+
+func TestFileSystemRepository(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "fsrepository")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	petm, err := NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+
+	sourcePE := newFakeProtectedEntity(t, "ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:67469e1c-50a8-4f63-9a6a-ad8a2265197c")
+	otherPE := newFakeProtectedEntity(t, "ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:7a2b2e1a-0d6b-4b36-a9c3-6c2b0bd6b4a1")
+
+	// Copy to the repository and read it back
+	repoPE, err := petm.Copy(ctx, sourcePE, nil, astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+	assert.Equal(t, sourcePE.info.GetID(), repoPE.GetID())
+	_, err = petm.Copy(ctx, otherPE, nil, astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+
+	_, err = petm.Copy(ctx, sourcePE, nil, astrolabe.AllocateNewObject)
+	assert.Error(t, err, "Copying an existing snapshot should fail")
+
+	repoPE, err = petm.GetProtectedEntity(ctx, sourcePE.info.GetID())
+	require.NoError(t, err)
+	dataReader, err := repoPE.GetDataReader(ctx)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(dataReader)
+	dataReader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, sourcePE.data, data)
+	metadataReader, err := repoPE.GetMetadataReader(ctx)
+	require.NoError(t, err)
+	metadata, err := ioutil.ReadAll(metadataReader)
+	metadataReader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, sourcePE.metadata, metadata)
+
+	snapshotIDs, err := repoPE.ListSnapshots(ctx)
+	require.NoError(t, err)
+	assert.Len(t, snapshotIDs, 2)
+
+	// Delete the snapshot from the repository
+	_, err = repoPE.DeleteSnapshot(ctx, repoPE.GetID().GetSnapshotID(), nil)
+	require.NoError(t, err)
+	_, err = petm.GetProtectedEntity(ctx, sourcePE.info.GetID())
+	assert.Error(t, err)
+	peIDs, err := petm.GetProtectedEntities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []astrolabe.ProtectedEntityID{otherPE.info.GetID()}, peIDs)
+}
+
+func TestFileSystemRepositoryCopyCanceled(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "fsrepository")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	petm, err := NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "", logrus.New())
+	require.NoError(t, err)
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+	sourcePE := newFakeProtectedEntity(t, "ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5:67469e1c-50a8-4f63-9a6a-ad8a2265197c")
+	_, err = petm.Copy(ctx, sourcePE, nil, astrolabe.AllocateNewObject)
+	assert.Equal(t, context.Canceled, err)
+
+	// Nothing should be left behind in the repository
+	peIDs, err := petm.GetProtectedEntities(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, peIDs)
+	dataFiles, err := ioutil.ReadDir(petm.dataDir)
+	require.NoError(t, err)
+	assert.Empty(t, dataFiles)
+}
+
+func TestNewFileSystemRepositoryWithInvalidPath(t *testing.T) {
+	_, err := NewFileSystemRepositoryProtectedEntityTypeManager("ivd", "/non-existing-repository-path", "", logrus.New())
+	assert.Error(t, err)
+}
@@ -29,13 +29,13 @@ import (
 )
 
 var rawCRDs = [][]byte{
-	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xb4V\xcdn#7\f\xbe\xcfS\x10\xdbC.\xf1x\x83\xf6P\xccm\xeb\xb4@п \tr)z\xa0%\xdaf\xa3\x91T\x91\xe3\xd4}\xfaBҌ\xc7N\xbcȩ\xbe\x18\xc3\u007f}\x1fI\xa9Y,\x16\rF~\xa6$\x1c|\a\x18\x99\xfeQ\xf2\xf9Kڗ\xef\xa5\xe5\xb0\xdc߬I\xf1\xa6yao;X\r\xa2\xa1\u007f \tC2tK\x1b\xf6\xac\x1c|ӓ\xa2EŮ\x01@\xef\x83b\x16K\xfe\x040\xc1k\n\xceQZlɷ/Ú\xd6\x03;K\xa9d\x98\xf2\xef?\xb7ߵ\x9f\x1b\x00\x93\xa8\xb8?qO\xa2\xd8\xc7\x0e\xfc\xe0\\\x03౧\x0e\xd6h^\x86\x98(\x06a\r\x89I\xda*\xb2\x89\xf7\x94Z\xe3\xc5\xc6v߿b\xa2ք\xbe\x91H&\x97\xb2Ma\x88\x93\xff\u05cck\x96\xb1\xf4z\xec\x1f\x8a\xc3Ô\xf0PT\x8eE\u007f\xbe\xa8\xfe\x85E\x8bItCBw\xa9\xe0\xa2\x16\xf6\xdb\xc1azg\x90\x13\x88\t\x91:X\xb9A\x94R\x03\xb0GǶ\xe0RK\v\x91\xfc\x97\xfb\xbb\xe7o\x1f͎z\xacB\x00Kb\x12\xc7b\aW\xefj\x03\x16@05\xea\xa2$\xb1\x90FB[\x80;\xcd\x16G\xc6,\xac\x0f\xa0;\x1a\xe3\xc0m\x01\r\xd0g\xa7\r%\xf2\xa6\xda\xc0\xa3\xc7(\xbb\xa0װr\xc1\xd3O)\xf4\x93\xa8\x98ߒ#\xcd\x19\x9e\x8e\xd1\x1eH\x8eeM%\x94\xd4\xc8^JV\x93ȒWF\a\x9b\x90\x00G\xa0`Fj\f8\x1fp\xac\xd0\xe6ޤ\x1a\xa52\r\xbaC\x85Wv\x0e\xd6\x04\x83\x90\x05\r\xa0\xe8^\xca\xff\x8eN\xa2\x02\xfc\xee\xdda>\x13\xa9ia\xf5 \xb0I\xa1\xaf\xfd\x11є𨀉J7\x90\x05\xf6\xf0Ź\xf0J\xf6\xb7\xd9hʉ&\x9b\x04\u007f\r\xbc)\t\x8f\x812\xe6\xe0\x83^\xf6Ϧ!R*\xe4\xd7h\x1bd\xd7^\x8d\x94ǔ\xb5\xcaS\xd3\xe6\x1f\xbe\x8d2\xab\x00X\xa9?\x13\x00\xe8!w\x9bhb\xbfmފ1%<̡\xe7\x9dqby\xdew\xb91\xab\xcd\x19\x13㬓\x05)M\v!\x03\xc1\x92\xa1O$\xe4\xeb\xde\xc8b\xf4\x10\xd6\u007f\x91\xd1\x16\x1e)eG\x90]\x18\x9c\xcd\x1d\xb2\xa7\xa4\x90Ȅ\xad\xe7\u007f\x8f\xd1d\"ҡ\x92d0\x95\x92G\x97Gg\xa0\xeb҆=\xe6^\xcbqa\xf0'\x11\x8a\x89\xb4\xf0kH\x04\xec7\xa1\x83\x9dj\x94n\xb9ܲN\xdbЄ\xbe\x1f<\xebaY&\x84׃\x86$KK{rK\xe1\xed\x02\x93ٱ\x92\xd1!\xd1\x12#/J\xb1\xbe,ö\xb7\xdfLm.W\xef@~\x83\xfd\xfa\xcd\xe0\xae\x1cr\xdf}\xe4U\xf6\xd5WY\xc9\xeb\xaa.\x80\xeaVO=\x83\x9fE\x19\xbf\x87\x1f\x1f\x9f\xe6\x89,\x04U.fS\x99iɐ\xb2ߔ\t\xe3qDr\x14\xf26\x06\xf6Z'\xd91y\x05\x19\xd6=k\xe6\xfb\xef\x81D3c-\xacʍQ\xa62ZT\xb2-\xdcyXaOn\x85B\xff;)\x19IYd\xe8>\xa6\xe5\xf4\xa2;7\xac\b\x1d\xc5y\x99\xc4J\xdd=&\xecI)\x9d\xcd\x1cZ[\xeeNt\xf7\x17\xe6\xf7Ù\xbc\x90\xeet\x01~\xd8)\xb27o\uf1bc,>\xf0˼q\xa2c\x8f-\xde/\x9a\xa3\xe6b\a\x1f\xb5\x17\xf19Ӟ\x1e\xa7\xb9x\xf4\xf9\xe9p\x83.\xee\xf0f\x96\x15(\x17\xe3\x83\xe1D\r \xb9mm\a\x9a\x06\xaa\x02\r\t\xb74JDQ\x87\xe2\x8d\xc6P\xd4\xf1h\xa7ρO\x9f\xcen\xf7\xf2i\x82\xaftJ\a\u007f\xfc\xd9Ԩd\x9f\xa7j\xb2\xf0\xbf\x00\x00\x00\xff\xff\xf9\x044Gr\t\x00\x00"),
-	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xb4U;\x8f#7\f\xee\xe7W\x10\x97\xe2\x9a\xf3\xf8\x16I\x11Lw\xf1\xa58\xe4\x81\xc3\xedb\x9b \x05-\xd16\xb3\x1aI!9v6\xbf>\x90\xe4箍\xad2\xddP\x1fɏ\xefn6\x9bu\x98\xf9\x91D9\xc5\x0103\xfdc\x14˟\xf6O?j\xcfi\xbe\xbd[\x92\xe1]\xf7\xc4\xd1\x0f\xb0\x98\xd4\xd2\xf8\x8d4M\xe2\xe83\xad8\xb2q\x8a\xddH\x86\x1e\r\x87\x0e\x00cL\x86E\xac\xe5\x17\xc0\xa5h\x92B \x99\xad)\xf6OӒ\x96\x13\aOR=\x1c\xfco?\xf6?\xf4\x1f;\x00'T\xd5\x1fx$5\x1c\xf3\x00q\n\xa1\x03\x888\xd2\x00KtOS\x16\xcaIْ<\xbb\x80<j\xdf\xc4^xKһ\xa8>\xf7\xdbq\x87B\xbdKc\xa7\x99\\\xa1\xb3\x964働[\xe0\xe6iO\xbf\x85\xfeSU\xf8vt\xba(N\xeb{`\xb5_nc~e\xb5\x8a\xcba\x12\f\xb7\xe8W\x88r\\O\x01\xe5\x06\xa8\x03P\x972\r\xf0{\xa1\x97ё\xef\x00\xb6\x18\xd8\u05cc5\xc2)S\xfc\xf4\xf5\xcb\xe3\xf7\xf7nC#6!\x80'u¹\xe2\xe0\xfdu\xb2\xc0\n\x93\x92\aK\xe0Kyi\x8eΑ*\xe0+\x85\x1e\xe0\x13Dڽz\x80\x1d\x87\x00Kj\x85$\x0f\xb0cۀm\bN\xa0\xcf5\xf5\x1f`!\xe4)\x1ac\x00\x8c\x1e>\x85\x90v\xe4\x8f\xf1i3Fl\x1b\x92b\xb3X\x89\x87W\xb0\rZ\x15\xbd\xe4p\x9f\xc9\x01\xecP\x8f$8B\x92\x8a}\xed\xa34\a\xaf\xb8\xa1n\x99\xeb\x01\x1e\xae<\xc1\x8a)\xf8F\xb3\x10\x9c\xb2\xaf\xfe\x8e1\x17\xb6\x90VW\xed\x1e\xd8\xf5\xef\xf7Eʒ2\x89\xf1\xa1\xf9ʇ/\xf9\x9e\x9e\x00\xd8h\xbc\x10\x00\xd8si\x115\xe1\xb8\xee^\x8aQ\x04\x9fO\xa6O\xf3\u007f\x86\xbc\xec\x94\xd2J\r\xb3o\t\xad\xa1\xec\xe7\x96<hm\xb3\x16\"+\be!\xa5\xd8v@\x11c\x84\xb4\xfc\x8b\x9c\xf5pOR\x14A7i\n\xbe\xac\x86-\x89\x81\x90K\xeb\xc8\xff\x1e\xadi\xe9\xc0\xe2&\xa0\x91\x1ap4\x92\x88\xa14\xfbD\x1fj\xaf\x8c\xf8\fB\xc5.L\xf1\xccB\x85h\x0f\xbf%!\xe0\xb8J\x03l̲\x0e\xf3\xf9\x9a\xed\xb0\xd9\\\x1a\xc7)\xb2=\xcf\xeb~\xe2\xe5dIt\xeeiKa\xae\xbc\x9e\xa1\xb8\r\x1b9\x9b\x84\xe6\x98yV\xc9ƺ\xd8\xfa\xd1\u007f'\xfb5\xa8\xef_%\xf9E\xee\x97/\xaa>\xbc\xa5PW\xce͂\x94eS\x06\x15\xf7j-\xe0Sދ\xa8\x0e\xdb\xcf\xf7\x0fp`\xd9j\xd3\xcap\x82\xea\xa9\"%\x9b\x1cW$\r\xb9\x924V+\x14}N\x1cۘ\xb9\xc0\x14\rtZ\x8el\xa5\xd4\u007fO\xa4V\x8a\xd5â.\xfe\xb3\x11\xe8\xe1K\x84\x05\x8e\x14\x16\xa8\xf4\xbfףdRg%uoW\xe4\xfc^]\x02[\x86\x8eⲁs\xab\xdaW\x14\x1c\xc9H.\xc6\r\xbd\xaf'\x10\xc3\xd7+\xa3\xfb\xe68^qw\xbe\x1f\xdf蔒\u007f\x16:\xf6\xca\xec:ߋ\xd7s\xf3\xddU*\xa7\x8b|\x87!o\xf0\xee$\xab\xa1\xcd\xf6w\xf8\xec\x19@K\x1b\xf9\x01L&j\x02K\x82k\xdaK\xd4Ц\xaa]\xeeI\xb6\xfd:;\xbf\xb0\xef\xde]\x9c\xc9\xfa\xebRl\xe9\xd5\x01\xfe\xf8\xb3kV\xc9?\x1e\xd8\x14\xe1\u007f\x01\x00\x00\xff\xff٩t \xc9\b\x00\x00"),
-	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xb4XA\x8f\xe3\xb6\x0e\xbe\xe7W\x10\xfb\x0e\xf3\x1e\xb0qv\xf0\xde\xe1!\xb7E\xa6\xdb\x06\xedn\a;\x8b\xb9\x14=\xd0\x12\x13\xabcK\xaa(g\x9b\x16\xfd\xef\x05%;\x8e\x1dOv\x8a\xb6\xb9\x99\xa2H\xea#\xf9Q\xcab\xb9\\.ЛG\nl\x9c]\x03zC\xbfD\xb2\xf2\xc5\xc5\xd3\xff\xb90nu\xb8-)\xe2\xed\xe2\xc9X\xbd\x86M\xcb\xd15\x1f\x89]\x1b\x14\xdd\xd1\xceX\x13\x8d\xb3\x8b\x86\"j\x8c\xb8^\x00\xa0\xb5.\xa2\x88Y>\x01\x94\xb31\xb8\xba\xa6\xb0ܓ-\x9eڒ\xca\xd6ԚB\xf2\xd0\xfb?\xbc)\xfeW\xbcY\x00\xa8@i\xfb'\xd3\x10Gl\xfc\x1al[\xd7\v\x00\x8b\r\xadA\xd5\xce\xd2.\xb8\x86-z\xae\\\xe4\xa2D\xf5\xd4z\x1d́B\xa1,k_\x1c\x9a\xcf\x18\xa8P\xaeY\xb0'%\xa1\xec\x83k\xfd\x1a\xae+g/]\xe8ݱ\xc5\xe1\xbb\xe0\x9a\x87\xceaZ\xab\r\xc7o\xe7\u05ff3\x9cu|\xdd\x06\xac\xe7BN\xcbl쾭1\xcc(,\x00X9Ok\xf8 \xe1xT\xa4E֖\xa1\x83\xbf\v\x91#Ɩ\xd7\xf0\xdb\xef\v\x80\x03\xd6F'\xf0\xf2\xa2\xf3d\xdf\xdeo\x1f\xff\xfb\xa0*j0\v\x014\xb1\n\xc6'=\xb8\xb9\x8c\x1f\fCˤ!\xba\x9c\r\x02\x04K\x9f\xa1\xf7\r\xff\x8eGo\x14\xd6\xf5\x11\x10\xee\x1f7\xff\x01\t\x1e\x10\xfa\xf8\v\x80\xef\xad\"\x88\x15Ao\xf6\xe6\x86\xe1\xbeB&\xa8\x90\x01\x1aw\xc8.\xfa\xf5H\x1aLr\x9e\xce\xf1\xbc\xf7\xe4K,\xf7\xde`{wӝ\xcd\a\xe7)D\xd3\xe3#\xbf\xb32?ɦ(\bLY\a\xb4\x146q\xf2Е'i\xe0\x04!\xb8\x1d\xc4\xca0\x04\xf2\x81\x98l.u\x11\xa3\x05W\xfeD*\x16\xf0@A6\x02W\xae\xad\xb5t\xc0\x81B\x84@\xca\xed\xad\xf9\xf5d\x8d\xe5\x8c\xe2\xa6\xc6H\x1c\xc1\xd8H\xc1b-\x00\xb4\xf4\x1a\xd0jh\xf0\b\x81\xc4.\xb4\xf6\xccBR\xe1\x02\u07bb@`\xecέ\xa1\x8a\xd1\xf3z\xb5ڛ\xd87\xb0rM\xd3Z\x13\x8f\xabԆ\xa6l\xa3\v\xbc\xd2t\xa0z\xc5f\xbfĠ*\x13I\xc56\xd0\n\xbdY\xa6`m\xeaߢ\xd1\xff:\x95\xdb\xcd\x19t\xf1(\x95\xc91\x18\xbb?\x89S\xb3<\x8b\xaf\xb4\x8a\xa4\x16\xbbm9\xfe\x01F\x11\t\x12\x1f\xbfz\xf84d:A\x9dQ\x1dTy\x00X\xc01vG!k\x9e\n\x83\xac\xf6\xceؘ>Tm\xc8F\xe9\x9d\xc6D\xc9\xdc\xcf-q\x14\xec\v\xd8$\xba\x82\x92\xa0\xf5\x1a#\xe9\x02\xb6\x166\xd8P\xbdA\xa6\u007f\x1c^A\x92\x97\x02ݗ\x01>gٱbF\xe8$\xee\to6\x13\x17\xdd\xfe\xe0I\xa5-fg\x88\x872\x96\xda,)S\x93\x9e\xf67l\xef\n\x80O\x15\xc1\xfb.\xaa\x94\x8b\x92\xc0\x1d(\x04\xa35\xd9\xd7\t\xfd\x9d\v\r\xc6\xdc7t:\x03\fy\xed\\\xab\x02\xe0\xed\xfd\xf6k!\xe9T\xf8\xa9b\xf2\xe21핳\x8a\x9d!\xbcL\x0f\xc5\xd9Q\xe7ڿ\xa3\x80dy,\x9d@sr\xdf\x05{*Ò\xa4<\xb37=\xb10\x9b*\xf9\xe59\xf3\x91\xbcc\x13]8^u-H\xe6\rR\xe7\xdd\x0e9b\xa0\x18\f\x1dh\xccw\x92\x8d\x0e\u007f\xdbχ\x11\u05ee\xee\x1f7P\x9b\x031\x18\vM\xcb\x11*<\x10\xa0R\xc4'\xda\x19\\\xbd\xf4P\xa9\x1a6h\x15\xd5W\xcf\xd3ǑU\xc1Xm\x94p\\\xdf}){y\xcdٽ\x13x\xcfF\xc7d\xb7B+\x95\xc5\x14\x01#\xa0=F\xd3HRvқ#\\\x02\xa1\xaa\xa4\x88!Rh\x8cP\xa9\xafR\x1f\xc3v7V\x95\x19\x94\xd5\xf5\x85\xfa,\x1a\xa5s5\xa1\x1d\xadM9\xef\x02\x87\x9e\xf6\xce\xeb\xf7\xaf\x95\xd5\x1c\v\xc8/\xf7\xd9\x1a\xcac\x9c\x8f\u007f\xc6V\x0f\xc6\xf6nj\xed\x99-\x92=\x13ht\xe6婹F\xc2i\xf9\x8f\x16\xcf\xcah$\x17<G\x82!\xc2/\x92^\xbe\b\xbd\x80\v\x94k|M\xe3+\xe6\xb5\x1cn.\xf5\xd3(\x0f\xba˫T#ڡ\xb4>#\xf7Nd\xa0H\x97r\x1aX7\x9c\x95\xfb\xfb\xd5΅9\xeb\xfcLje@-\xc5\xc0d]\xae\xc7Xִ\x86\x18\xda\x17'\xbf!f\xdc\xd3գ\xbf\xcf:ypw\x1b\x00K\xd7\xf6\x93\xd5Y\xba\xe1\x0e\xfb⥞S\x8f]\xf5\x9b\xaf\x89]ߨ6\x844\xc0\xa3\xdc\x05;n\xbe\x18c/\xf6\u07b7\xdf7hu}=\f\xc9\\\x95\xd4.FB\xac\xb0K\xf4\xd9|<'\x98\x89\xdd\xe7\x8a\x11\xae\f\xa7\x8bxN\x03\xaaC&\xbdhR\x15͌\xab@;\ndU\xba\xd3\xecF{\xe5\xca\xd3O|\x9d\xc7\xf4\xe93SV\x9a\x18\xa5\xdc|r\n\x84i\xdf\xdeo\xb3\xc7\x02\u07b9 <\f.V\xf9\xee\x15\xf4\xd2c\x88\xc7\x04;\xbf\x1ey\xebIc\x9a\xa1\xabY\x82g\xd8\xf5\x02\x93\x170\xec\x80ğ\x8d \xbd8\xbf\x14\x81\xbc\xd0\xfa\bd\xc3\xdf\x18\xc1\x1c\xdf\xc2\x1cSf\xa1x\x9fm\x83\tWΊ\xa7\xbe\x96\xa9&\x16\xb3\xfaë\xfd\x16k_\xe1\xed K\xf5\xbd\xec\xde\xeagː9P\x9f\x91\x14G\x17\x84\x81\xb2d p\xb9\xa4\xf8H\xfa\xc3\xf4%\xfe\xea\xd5\xe8Y\x9d>\x95\xb3\xda\xe4\xbf\x1b\xe0\x87\x1f\x17\xd9*\xe9\xc7>\x1a\x11\xfe\x11\x00\x00\xff\xffÈy\xcd\xed\x10\x00\x00"),
-	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xb4VOo\xe3\xb6\x13\xbd\xebS\f\xf6w\xc8\xe5'y\x83\xf6P\xe8Vx{\b\xda]\x04I\x90K\xd1\x03M\x8e-6\x14\xc9r\x86ަE\xbf{1\xa4dَ\x93ͥ\xbei\xfe\xfb\xbd7#5m\xdb6*\xdaGLd\x83\xefAE\x8b\u007f2zy\xa2\xee\xe9\a\xealX\xed\xaf7\xc8\xea\xbay\xb2\xde\xf4\xb0\xce\xc4a\xbcC\n9i\xfc\x84[\xeb-\xdb\xe0\x9b\x11Y\x19Ūo\x00\x94\xf7\x81\x95\x98I\x1e\x01t\xf0\x9c\x82s\x98\xda\x1d\xfa\xee)op\x93\xad3\x98J\x87\xb9\xff\xfec\xf7}\xf7\xb1\x01\xd0\tK\xfa\x83\x1d\x91X\x8d\xb1\a\x9f\x9dk\x00\xbc\x1a\xb1\a\x83\x0e\x19ɫHC`\xea6J?\xe5h\x92\xddc\xea\xb4'\x13\xbb\xfd\xf8U%\xect\x18\x1b\x8a\xa8e\x8e]\n9\xf6\xf0vpm1\xcd]\xff\xf3\xa7\xd2\xed~\xeaV\x1c\xce\x12\xff|\xc1\xf9\x8b\xa5\x1a\x10]Nʽ\x98\xb4\xf8\xc8\xfa]v*\x9d{\x1b\x00\xd2!b\x0f_d\x84\xa84\x1a\xb1\xe5M\x9a\xf0\x9e\xc6\"V\x9c\xa9\x87\xbf\xffi\x00\xf6\xcaYSЪ\xce\x10\xd1\xffx{\xf3\xf8ݽ\x1epT\xd5\b\x10S\x88\x98\xd8\xce5\xe4w\xc4\xfd\xc1\x06`\x90t\xb2\xb1T\x84+)Uc\xc0\b\xdbH\xc0\x03\xc2\xc4\x19\x1a\xa0\xd2\x06\xc2\x16x\xb0\x04\tcBB_\xf9\x17\xb3\xf2\x106\xbf\xa3\xe6\x0e\xee1I\"\xd0\x10\xb23\"\x8b=&\x86\x84:\xec\xbc\xfd\xebP\x8d\x80Ci\xe3\x14#1XϘ\xbcr\xf2g3\xfe\x1f\x9470\xaagH(u!\xfb\xa3\n%\x84:\xf8\x1c\x12\x82\xf5\xdb\xd0\xc3\xc0\x1c\xa9_\xadv\x96gU\xeb0\x8e\xd9[~^\x15m\xdaM\xe6\x90hep\x8fnEvת\xa4\a˨9'\\\xa9h\xdb2\xac/\xa2\xeeF\xf3\xbf\x03%WG\xd0\xf1\xb3\xb0G\x9c\xac\xdf\x1d\xccED\xaf\xe2+*\x02K\xa0\xa6\xb4:\xff\x02\xa3\x98\x04\x89\xbb\x9f\xee\x1f`nZ\xa1\xae\xa8.\xa1\xb4\x00,\xe0X\xbf\xc5T#\xb7)\x8c\xa5\nz\x13\x83\xf5\\\x1e\xb4\xb3\xe8Y\xf45Z\x16\xe6\xfe\xc8H,\xd8w\xb0.;\f\x1b\x84\x1c\x8db4\x1d\xdcxX\xab\x11\xddZ\x11\xfe\xe7\xf0\n\x92\xd4\nt\xdf\x06\xf8\xf8\xf4\x9c\x06V\x84\x0e\xe6\xf9\x10\\d\xe2>\xa2\x16\"\n2\xe5\xca-pK\xe2Qޥ]\x92_\xbd,w\x18\x03Y\x0e\xe9\xf9\xd4{\xd6\xefa\xc0)A\x18\x9c2D\xf7\t9Y\xdcc\x99d\xbe\r\x85®$\xf9\xf98\x94\x80\xf9\xf2\xacn\x1f\xd7\xe0\xec\x1e\t\xac\x871\x13à\xf6\bJk\xa4\xc3>-\x9d\xceF\xbb\bl\xc1l\xaa\u007f\xf3\xe9\xfc\u07fc\x92\"2\xb2\tO$߾\x80\xe6Ĺ\xf4\xf8&\x83\xf5\xf2\xbd\xc6\xe1:\xa7T$]\xc2\xeaM\xc2\xe9\xca.P\ny\xe5:\xbd\x83R\x1d\xc6\xe8\xf0\xf4]\xf4\x16\xab\xeb\x97\xf1\xe5\xbc%S\x95\xc5vD\xb9\x88\xa7\x97\x1f\xbe*\x9a[ɪ\t\xcdTV\xf9\x8aj\x8a%Ȅ\x06\xb6!]\xeaAg3mC\x1a\x15\xf7 \xab\xdbJ\x813\xbf\xbcM\xd5\xc6a\x0f\x9c\xf2\xb9\xf3U-\x8cH\xa4v\xf8&\x00\x9fkL=iS\x02\xa8M\xc8|\x89\x8b+\x9a\xb8\xea\xde;C\x1c\x14\xbd=\xc1\xadD,\x9b\xbc(\x02gA\xd4w\xf6au\xde\xd9\xfb\x82 ϵ\xde\x1e_\x8a\xb3\xf8\xe5+\xe7Z\xb98\xa8\xeb\xc5V4\xd7N\xdf6Gn\xa8\"0G,\x11\x87$\x14T˲\x0e\xb2\xe6\x91\xd1|9\xffx\xf9\xf0\xe1\xe4{\xa4<\xea\xe0\x8d\xad\x9fg\xf0\xeboM\xad\x8a\xe6q\x9eF\x8c\xff\x06\x00\x00\xff\xff\xb5\xec\xc68\x1d\n\x00\x00"),
-	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xb4XQo\xe38\x0e~ϯ z\x0f}i\x9c)np8\xe4m.\xbd\xbb-fgP\xb4E_\x16\xfb KL\xac\xad,yE:\x9d\xecb\xff\xfb\x82\x92\x1dǉ\x9bv\x81Y\xbf\x99\"%\xf2\x13\xf9\x91\xf6l>\x9f\xcfTc\x9f0\x92\r~\t\xaa\xb1\xf8\x8d\xd1\xcb\x1b\x15\xcf\xff\xa6\u0086\xc5\xf6\xbaDV׳g\xeb\xcd\x12V-q\xa8\xef\x91B\x1b5\xde\xe0\xdaz\xcb6\xf8Y\x8d\xac\x8cb\xb5\x9c\x01(\xef\x03+\x11\x93\xbc\x02\xe8\xe09\x06\xe70\xce7\xe8\x8b\xe7\xb6Ĳ\xb5\xce`L'\xf4\xe7o?\x14\x1f\x8b\x0f3\x00\x1d1\x99?\xda\x1a\x89U\xdd,\xc1\xb7\xce\xcd\x00\xbc\xaaq\t\xe4UCU`*J\xa5\x9f\xdb\xc6D\xbb\xc5XhO\xa6)\xb6\xf5\x8b\x8aX\xe8PϨA-\x1elbh\x9b%\x9cWΛw\x1e\xe7h\x1f\xbas\x92\xc8Y\xe2\xcf#\xf1\x8f\x96\xf2R\xe3ڨ܁_IJ\xd6oZ\xa7\xe2 \x9f\x01\x90\x0e\r.\xe1\xab\x1c\xd5(\x8dFdm\x19;D\xbb\xe3\x89\x15\xb7\xb4\x84\xdf\xff\x98\x01l\x95\xb3&\xe1\x91\x17C\x83\xfe\xd3\xdd\xed\xd3?\x1ft\x85\xb5\xcaB\x00\x83\xa4\xa3m\x92\x1e\\\xee\x9d\x04K\xd0\x12\x1a\xe0\x00\x11\u007fm\x91\x18\xb8R\fj\uf5a8\xb0zF_\x00ܦ7\x1fxoT+\xaf6\b\\!X\xbfE\xcf!\xee \xac\x87`Ay\x03&`6\x03\x8f\xd9\x0e\xbfYb\xb0\x1eB4\x18E\xa2]\xf0y\xa3>\\X\xc7P\x1fxr\xd9\xc5\xd2\xc4\xd0`d\xdb\xe3!\xcfA\xa6\xeee\xc7Q\v,Y\a\x8c\xe4&R:\xae\xcb04@\t2q\x9f+K\x10\xb1\x89H\xe8s\xb6\x8aXy\b\xe5/\xa8\xb9\x80\a\x8cb\bT\x85\xd6\x19I\xe2-F\x86\x88:l\xbc\xfdm\xbf\x1bIlr\x8cS\x8c)d\xc6蕓\x8bk\xf1*\xc1S\xab\x1dD\x94}\xa1\xf5\a;$\x15*\xe0K\x88\x02\xef:,\xa1bnh\xb9Xl,\xf75\xa8C]\xb7\xde\xf2n\x91*ɖ-\x87H\v\x83[t\v\xb2\x9b\xb9\x8a\xba\xb2\x8c\x9aۈ\v\xd5\xd8yr֧\x12,j\xf3\x8f}z]\x1e@\xc7;\xc9D\xe2h\xfdf/N\x89\xff*\xbe\x92\xff\x92\x1f\xaa3\xcb\xfe\x0f0\x8aH\x90\xb8\xff\xef\xc3\xe3p\xc9\t\xea\x8c\xea\xa0J\x03\xc0\x02\x8e\xf5kI\x12\xd1L9!\xbb\xa07M\xb0\x9eӋv\x16=K\xadԖ\xa9Oe\xc1\xbe\x80Ub\x1c(\x11\xda\xc6(FS\xc0\xad\x87\x95\xaaѭ\x14\xe1\xdf\x0e\xaf Is\x81\xeem\x80\x0f\x89r\xac\x98\x11ڋ{\U0009af09\x87\x06u*[A&q\xf2\x00\xb7\x18\x1e\xd8MՒ<\x99\r\xef\xb1\td\xa5\xa6ǫG\xe7=V\xd8\x19\xc8\rv\x16\x92\xf7\x03\x87x\xb9\x89\xa4\xe8{rK\xee\xf5D\xb4\xb8{Z\x81\xb3[$!\x85\xba%\x86Jm\x11\x94\xd6H\xfb\x1a\x1av?rg\x12Ly\xfa\xb8\u007fP\xde8<\x1b\xc5\xfdH\x15\"\xae%\xfd8\x80\x82\xcfm\x89\xd1##\xed7\xbc\x02\xddƈ\x9e\xdd\x0e\x14\x88\xf7e\xcb9E%cK\x84\xd4-\r\x1a\tH\x9c_\xb7\x92!G\x1e\xbc\x86?dN\xfb\u007f\xeaM'+G\x9e\u007f\xba\xbbM\x8a\xfd\x9d\xa7\x8e\x06\xeb\x10\xc7tZ\xa2T`\x8a\v\xbdNu\xb0\x1e\xd9J\x99H~صEs\x95\x8c\xf7\xaf\x90\xaa;]L\x89}HZ*\xe7\xd3\xddm>\xb1\x80\xff\x85\b\xca\xef p\x95\xeb5\x9ay\xa3\"\xef\xd2\x05\xd1\xd5\xe84)R\x1b\xd1\x14\x13\xe1\xbdz\x9f0\xc1B\x93\x98\xf4d$n\xcanB߯\"\xf1W=H\x83\xc6[\x1eH\x17\xef=\x10\x83\xef\xe8A\x0fݱ\x0f\xf3\x84͉PN\x9f,\x98#R\x91\xa7/ٕ\xf2\x1a\xddقy\x18\xa9\x82\xf5\xc6j\x95k\xa4\x9b$\x02\xe8\xbc\x16\xfc&H\xc0\xfd\xee\x05\x1c[k\xe5%\xb1\b\x19d\xfc\xf0;\xb6\xb5\xc0\xb4\x96\x14K\x99\xd8sID\xa5+\x946\xc3\x18k+\xbd\xb4\xa9\x12\x91K>\x8fT+E\x9d\xba9Q\x9f\x04\xa4\f\xc1\xa1\xf2\xb3\xf3P\xcfO\xe8q\xb48\xe6\x9c7\x99<Os\xafq\xf9*\xb3L\xa7\x96g\x93\x83\b\x85=\xd2x\xf2\x0eNסn\x1c\x8eG\xe7s\xf7\xbb:\xd5O\xf3M4]Y\xc9\r)?8\xf3\xa2\xa8?D\xd8E؞R\x17\xbf\xa4\xac\xdcO\x9bBM\x13\xbbӑ7\xeb\x10k\xc5K\x90\xae=\x97\r\x8e\xd6e\xecW\xa5\xc3%pl\xa7/t\xa2|j$R\x9b\xf3\xbd\xe0K\xd6\xc9\xd3Lg\x00\xaa\f-\x8f\u0fe4\xee^\x8e9\xec\xcc\xe1\xa7\r~\xe2\xf4\xac\xb4\xa7\xf0\xfe<F3U\xb3\x03R\xe5\x8eߍC\xaa\x82\xb3~܉\xc60H\f\x89\x88}\x1e\xf6E\xfc\xee\xf8\x9b\x186\x11\xe9\xa4ۍ\xcf\xed\x94\xf6\xf1\xb7\x8d\v\xea\x98\xd7εN\xc1\x81n\x82\x9fd\xe9\x1e,\xeb\xf9_\x1f_%^\x19\xd47\x18O\xd69\xb0r\xff\x91\xfd\xbf\xf7\xdeor\xf2\xedͻ\xf8\x18no\xf2G\x9c\xd0_\x89\xe8\xf7\xdfo\x8f2\x9e\xbcX\xe7\x84j\xd7ֹ<\x9d\xbcT\x98\xfbyJ\t\xd8\xc8\xd7\x1a\a\xb8x\x18\xd2\xee\xe2\xbd\x17L[ݛ}\x9d\xe8\x92#\x87e\\ؤN\xa1]K\x8c\x91\xae\x80d\xea;l\x99ݜ\x11\x91\x9a\xe0Mj\"m\x83qk)\xc4\xden\xa0 \xb1*r\x9c\a\x9f\xb6\x1c\x95~>Ȥ\x9eM\xf7\x1f\x13\xa7[\xbe3\xa3'\xee\xec\xb8c\xcc\x0fg\xee#\xfd\xe1\xefƵrM\xa5\xae\aYJ\xafy\xf7O\xe3`\x192\xa7\x9a\x03\xd2#\x0eQ\x18-K\x86\x86\"\xc3s\xc3h\xbe\x1e\xff\xba\xb8\xb8\x18\xfd\x99H\xafZ\xd0Ϳeয়gyW4O\xbd7\"\xfc3\x00\x00\xff\xff\xda`\xffX\x15\x12\x00\x00"),
-	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xbcXOo\xeb\xb8\x11\xbf\xfbS\f\xb6\x87\\jy_wQ\x14\xbeu\x93] h_\xf0\x10\xbf\xe6R\xf4@\x89c\x8b\rEj9C\xfb\xb9\x9f\xbe\x18R\xff,;\x8e\x83v7\x97\xc0\xa3\xf9\xc7\xdf\xcc\xfc\x86\xd2b\xb9\\.Tk^0\x90\xf1n\r\xaa5\xf8\x8d\xd1\xc9/*^\xffB\x85\xf1\xab\xfd\xa7\x12Y}Z\xbc\x1a\xa7\xd7p\x1f\x89}\xf3\x8c\xe4c\xa8\xf0\x01\xb7\xc6\x196\xde-\x1ad\xa5\x15\xab\xf5\x02@9\xe7Y\x89\x98\xe4'@\xe5\x1d\ao-\x86\xe5\x0e]\xf1\x1aK,\xa3\xb1\x1aC\x8a\xd0\xc7\xdf\u007f_\xfcX|\xbf\x00\xa8\x02&\xf3\xaf\xa6Abմkp\xd1\xda\x05\x80S\r\xaeA\xfb\x83\xb3^i*$d\xe3\xf7\x18\x8aʑn\x8b}sP\x01\x8b\xca7\vj\xb1\x92\xf0\xbb\xe0c\xbb\x86+\x9a\xd9m\x97k>\xe7C\x17!\x89\xac!\xfeۉ\xf8\xef\x868=jm\f\xcaN2JR2n\x17\xad\n\xa3|\x01@\x95oq\rO\x12\xaaU\x15\x8al\xaf\xac\xd1\xe9\xa89\xb8o\xd1\xfd\xf5\xcb\xe3\xcb\x0f\x9b\xaa\xc6Fe!\x80F\xaa\x82i\x93ސC'-\x11\x14\xec\xd1b\xf0\xcb\xd6Ɲq\x10\x90\xd8\a\xec\x8c\xdb\xe0[\fl\xfa\x03\xcaߤ\xea\x83l\x16\xe6N\xf2\xc8:\xa0\xa5\xceH\xc05BW-\xd4@)G\xf0[\xe0\xda\x10\x04l\x03\x12\xba\\y\x11+\a\xbe\xfc7V\\\xc0\x06\x83\x18\x02\xd5>Z-\r\xb1\xc7\xc0\x10\xb0\xf2;g\xfe3x#`\x9f\xc2X\xc5H\f\xc61\x06\xa7\xac \x15\U0004f81c\x86F\x1d!\xa0\xf8\x85\xe8&\x1e\x92\n\x15\xf0\xd9\a\x04\xe3\xb6~\r5sK\xeb\xd5jg\xb8\xef\xe7\xca7Mt\x86\x8f\xabԕ\xa6\x8c\xec\x03\xad4\xeeѮ\xc8\xec\x96*T\xb5a\xac8\x06\\\xa9\xd6,S\xb2.\xb5s\xd1\xe8?\x84\xae\xf9\xe9n\x02\x1d\x1f\xa5\xb6\xc4\xc1\xb8\xdd N\xad\xf4&\xbe\xd2Q`\bTg\x96\xf3\x1fa\x14\x91 \xf1\xfc\xf3\xe6+\xf4A3\xd4\x19\xd5Q\x95F\x80\x05\x1c\xe3\xb6\x18\xb2\xe66\xf8&yA\xa7[o\x1c\xa7\x1f\x955\xe8\x18(\x96\x8da\xa9ܯ\x11\x89\x05\xfb\x02\xee\xd3\xf4B\x89\x10[\xad\x18u\x01\x8f\x0e\xeeU\x83\xf6^\x11\xfe\xe6\xf0\n\x92\xb4\x14\xe8\xde\axJ:\xa7\x8a\x19\xa1A\xdcs\xc1\xc5JlZ\xac\xa4\x10\t\x99\xc4o#\xdcb8\xb1\xbb4K\xf2W\xaa\xea5\xb6\xcf\xd8z2\xec\xc3Q\x86\xfcTc\x16\U000e7641x\xde\x1b\x8dԹ\x92\xda\xf6\x8f\x04k\xd8\xfa0\x90Iqf.\xf1\xfa#\b\x97\xe5\xa1\xc43\xbdb\x96\xd3ET\x13c[\xefP\x9ag\xe3TK\xb5\xe7g\xdcb@W]?ֽ\x98\xfdr\xc9l\x9a]\xa2\xbf4\xcaC\x18\xea\xf4s\xf6r\xd8Ծ\xfd\x89\xfb\x16-\xe0k\x9d\x1e7\x8a\xc5\xe3Y\xbc\x81]W\x17\x1f\xc1c2\x8b\x84Z\x88&wx\xcak\x88D\xac8\x12\x18\xd7M\xca,\xc1\x9b\x11l\x83\x97.G\xfd\xb3c\xc3\xc7Ǉ\xab\xc0}\x99k\xf7x\x19-\xb3\xb15\x18:Tp\xf4\f\x98\x94\x05\x14Cb\xe0\x10u>\x99,\xbaC0,0\x03~3\x94\xe8d\xefml\xf0\xe6#t{d\xdc\xc2\xd7N\xf0<SN\xdc\x1et>\x05\x9b&\xc3ܹ\x84\x83\"\xa8\x94\xb5B/RRJ\xf4uGY\xb3/\x91\x9c\xb8/\xdf\xe0x\x96Dn\x86\xb4\xdfq)ַ\x9e\xae\xaf\xe8;\x95\xd9\fjWJ2\xb4o7w\x1f\x02\xfa2c\xa56|\x93\xb3\xfaK\xc0\xa6\xeb֞\xbdBHĞ\xa5\xb2\x82\a\xcd\xe2\x06\x1a\xab|\xd3Z<\xbdy]\x1d\xf7s\xfd\xf3\xb2+7\x0eW*{6\x92ʏ\xf6Cݳ\xb9\xf4\xf6\x1e\x1dx\a[e,\xeaɕ\xef\x9d~\xb9\x90\x13}\xb0e䮩J\x8bk\xe0\x10o\xee\xa7\x06\x89\xd4\xee:?~\xce:y\xedw\x06\xa0J\x1f\xf9\x84\x84\xee\xa8+\xe1ͣ\xea\xf0\x1b?#\x87\xe3m\x85{:S\xefof%\x0e\x95\xcbr\xae\x85j\x9d6UO\x95\x12\v\x82X'\xc0g\x05\x82\xfb\xe7\x02\xfe\xd1\x11\xec\xd6X\xc6\x00\xf3\xf3\xf5t\x0e\x87\xdaT\xb5t\x04&\xc6-q+\xe40\t y\xccA\xf8m\xca\xd7֊\xae\x17\xef\x8bh\\\x1a\xb5a\xdd^\x9a5\xf9C\x17\x9b\xb9\xeb%<\xe1\xe1L\xf6\xe8\xbe\x04\xbf\vH\xf3\x9e]\xf6\x8d\x8d\xfa\xecI\xaa\xe4\x99\xf4\x97T\x97\x0f\xac\xab\nI^\\\x9e\xbc\xbe\x8e\x83\xcc߃b\xf5Y9\xb5\xc3\x00\xcek\xccmR+\x82\xd6T\xaf\xa8!\xb6'\x88\xa4N\x19ct+\xeb`\xac\x9d\xdc4A\x11\x90\xf7N\xfe\x9f\x18\x9b\xa9۹\xa7\xc7\x0e\xfbIF\x95\xb4\xb2\xbb\xe3^\xef4\r\xf2\x8d,\"Eށ\xe1!\x891By\x04\xe5<\xd7\xdd\xd9>\xb2\xf2S\xe9\xde\xdb\xf4I\tjo{\x9a\xf4\xac,\xb8ؔ2+[(\x8f\x8ct\xbaJ\xd2mi\xdaw\xa3v\xbfRS\xe2\x8c\xd4a[)\x97\xa0\xed&Q\x1bj\xad:\x0e9\xa6[\xa5̑\x90\xef\xc8@\xbd3\xd9\x0f\xe9\xd9\xfc\xf0o\xad\x0e\xf9K\xe1\x1f\xbc;k\x1f\x98L\xadq\xfc\xe7\x1f/<Ϩ\xca\xfb\xde\x0e\xc3\xd9\xf3\x04\xd1O\xe2\xff\xff\xed\xfb\xe2\xf6\x85t\xfb\xe1p\xbc\xf7\xd1\xf1;\xf7\x9e^\xedd\xf5M\xeb\xd3\x13\x19\xa5\xf6\v\xb8TZ\xcbuL\xf5\xdc\xf90rgG\x89=\xa9F\xe9\x0ep\xc8\a\x1f^\xc1\x10ELo\b\"\xfd5bĎk\xc5q$y\xeb\v\xaazMΝ\x06\x8de\xdc\xeddJޠP\xe3\xf8\x87?]\x04\xe4\x12X\xc4*\xf0m\vfs\xa2\xfa\xfe\xa5 \xb9\xbe\xe12x\xe2\xf6\xf7\xd9\xeby\x00߹%\xbetJW\xee\x88\xddh\xe9\xff\xf9r(\xcb\xd3\x04\x1c>-,\xa7o\xa93\xfd\xf1\xdb\xda'e\xdbZ}\x1aei\x92\x96\xdd\x17\xb5\xc9c\xc8\xf8\xeb\tJ\x92\xb8\\m\xb2d\xbc\x9a\xaa\xaa\u0096Q?\xcd?\x9f}\xf7\xdd\xc9ױ\xf4\xb3\xf2N\x9b\xfcQ\x10\xfe\xf9\xafE\xf6\x8a\xfa\xa5\xcfF\x84\xff\r\x00\x00\xff\xff\xba\x90\xfd\xf5\x93\x14\x00\x00"),
-	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xbcXOo#\xbb\r\xbf\xfbS\x10\xaf\x87\\\xea\xc9۾\x87\xa2\xf0\xad\xcf\xfb\n\x04\xed\xa6\x8bxw/E\x0f\x1a\x89\xe3Q\xa3\x91\xf4Dɉ\xfb\xe9\vJ\x9a\xf1\xf8O\x1c\x17\xd8v.\x89)\x8a\xa2~$\u007f\xe4\xccb\xb9\\.\x84\xd7\xdf0\x90vv\x05\xc2k|\x8dh\xf9\x175\xcf\u007f\xa2F\xbb\xfb݇\x16\xa3\xf8\xb0x\xd6V\xad`\x9d(\xba\xe1\tɥ \xf1#v\xdaꨝ]\f\x18\x85\x12Q\xac\x16\x00\xc2Z\x17\x05\x8b\x89\u007f\x02Hgcp\xc6`Xn\xd16ϩ\xc56i\xa30\xe4\x13\xc6\xf3w?6?7?.\x00d\xc0\xbc\xfd\x8b\x1e\x90\xa2\x18\xfc\nl2f\x01`ŀ+H\xde8\xa1\xa8\xe1\x03\a\xb7\xc3\xd0HK\xca7\xbb\xe1E\x04l\xa4\x1b\x16\xe4Q\xf2\xe1\xdb\xe0\x92_\xc1\x15\xcdb\xb4zZn\xf95\xdb\xcf\x02\xa3)\xfeu&\xfc\x9b\xa6\x98\x17\xbcIA\x98ɗ,#m\xb7Ɉ0J\x17\x00$\x9d\xc7\x15<\xf2\x11^Hd\xd9N\x18\xad\xf2\x05ˡΣ\xfd\xf3\xe7\x87o?md\x8f\x83(B\x00\x85$\x83\xf6Y\xaf\x9e^e-\x82\x80\x1d\x1a\fn\xe9M\xdaj\v\xad\x90\xcf\xc9ם>8\x8f!\xea\xf1V\xfc\xcc\x02=\xc9Nθc'\x8a\x0e(\x0e-\x12\xc4\x1e\xa1\x06\b\x15Pv\x10\\\a\xb1\xd7\x04\x01}@B[\x82\xcdba\xc1\xb5\xffB\x19\x1b\xd8`\xe0\x8d@\xbdKFq\x0e\xec0D\b(\xdd\xd6\xea\u007fO\xd6\b\xa2\xcb\xc7\x18\x11\x91\"h\x1b1Xa\x18\xa6\x84\xbf\aa\x15\fb\x0f\x01\xd9.$;\xb3\x90U\xa8\x81O. h۹\x15\xf41zZ\xdd\xdfou\x1cSX\xbaaHV\xc7\xfd}NDݦ\xe8\x02\xdd+ܡ\xb9'\xbd]\x8a {\x1dQ\xc6\x14\xf0^x\xbd\xcc\xceڜ\xc1͠~\x17j\xbe\xd3\xdd\f\xba\xb8\xe7\xc0R\f\xdan'qΟ7\xf1\xe5D\x02M \xea\xb6\xe2\xff\x01F\x161\x12O\xbfn\xbe\xc0xh\x81\xba\xa0zP\xa5\x03\xc0\f\x8e\xb6\x1d\x86\xa2\xd9\x057d+h\x95w\xda\xc6\xfcC\x1a\x8d6\x02\xa5vБ#\xf7[B\x8a\x8c}\x03\xeb\\\xb0\xd0\"$\xafDD\xd5\xc0\x83\x85\xb5\x18Ь\x05\xe1\xff\x1c^F\x92\x96\f\xdd\xfb\x00\xcfy\xe6X\xb1 4\x89G\x02\xb8\x18\x89\x8dGɁ\xc8\xc8dJ;\xc0\xcd\x1bg\xfb.\xd5\x12?\xa5\xe0\x9e\xd0;\xd2х\xfd\xf1\xea\xc9y\xbf\x9c(\xb3՝VH\xd5\f\xc7u\\b\x9c\xa1s\xa1rH\x03_\tU\x16\f\xc9D\xed\r\x9eojN\x0e\xbf\b\xdd\xc1\xeb\x03\xaf\xbe\xef\xf4\xa4\x9b+7\xa8\x02Z\xd4\x03\xe6\u007f\xaa+/\x82@\nc8w\xbe\xf4\b\x94s\U000ce2a2&H\xe3%6Vx\xea]\x9c잸й0\x88\x98\x19\x1b\x97\xbc\xfb֫Q5\xfc\xf0\xf1\xea\xad6\x93ژ\x00Zq.v\x1aCv\x90E\xa3\xad\xc2t\b;gҀ7\xa3<n\u007f\xc2\x0e\x03Z\x897y4i\x8f\x8eٱid\x0e\x9c|bq\xf5\x94\x81-\x8d\xa1\x16tA\xbf`\xc8fF\xdbS\xff\xb9\x1f%\xeb'\x96\xc1C\x9c\xa2\x13]\xad\xff|x\xb5KQ\xc4D\xa0\xed\x11.7#Q\xac\xac\x85\x95h\xae\x82\xf0u\xa6\b\xda*-\xb9\x1b\x8c\xd7b\xdfdYsv\xeb\x98'ku\\t\xa4uΠ\xb0\xefRD\xbeۛ$Q\\\xdaT\x00F\xb2\b!\xf3h\x91rǫz\xcd\r\x9c!\xdd\xe0\r\x1eO6\xd7@Y\x9f\xeb\x9fW\xa1\xb0c\xacr\x11\x96-\\\x87\x87\xddS\x15\x96ͨ\x00wh\xc1Y\xe8\x846\xa8\xa6\x81\xea\x9dڽ\xe0\x0f\xfd\x97\xe5\xcbs\x9ch\r\xae \x86tsmWؙ\x91\xfe\xdeu\xd71;R=\x82\x8b\xb9\xcau\x1d\xdf;`\f\xfb|\xa7#\x04\x1ax\xca\vn´N/\xae\xc5=\xe0\xabw\x96\xb9B\x98\xc9ր\xb2\x17V\xd3p\x9a\x89#\x10\xdaƟ\xfep\xf1\x9e<\xe8l1\x1c\xad\rH$\xb6\xd7\xf9\xe2S\xd1)\x83D\xdd\x00\xa2u)\xce\n\xf7\x8ej\x8e\xde\\\xaa\x16_c\xbe\xfdm\xb9\xf9x\xa6>b\xd5├E\x1e{&\xa3ZӅ\xd9\xf05\xbe\x15\x03X?՞\x17\x1dt\xdaD\fp|\xb7\x89\x15^z-{Ny\xcc\f\xd5bǃ\xca\xcc8\xfb\xf0V`\xbeo\x86\xfa^\xd0\xf5\xb0}f\x8dK<\x82c\x979'\x12~Ц\xe1\xd4\xf0\x12\x1e\xf1\xe5L\xf6`?\a\xb7\rH\xa7E\xb9\x1c+\x17\xd5\xd9J9\xf4\xd7\x10\\8\xdf\xc5,\x9a\xfc_rx\xceW3!\xbf\xb9p\x8a\xd05\xf0\x82\x93H\xfc\xf6\xf4\xe8\xd4u\x14\x99\xa0>\x8a(>\t+\xb6\x18\xc0:\x85%\xc1zA\xe0\xb5|Ή4\xc33g\xd8\xe1\x04\xe68M𢍙M\xbc \b\xc89\xcb\u007fg[\xf5\xdc䩝\x87\x12\xb5\xb97\x92\v\xc0\xde\xc5Qo\xee\x02\xb9\x81G}A\u0382\x8e\x93\x03\a\xfb\xed\x1e\x84u\xb1\xaf\xb7\xba\xb9p}\r\xfa\xf5\xec\xabJ\xd0;3v\x0f\x17\x85\x01\x9b\x86\x96+\xac\x83v\xcf\x1d\xf7h\xe2Ƀ\xc7<_g\xda\xe2\xe0wD\xaa\xb0Ja3\xaa\xb5|\x95&o\xc4~r1\x0f\xb7\\~ܔ\x0e\x94U\x87Hn\x9ay\xe9\xf4\xeao\xf5S~\xf2\xe9\x1f\x9d=K\x1b8&\xe1?\xfe|a\xfdm\"Ϋ\f\xd0/l\xff{۾8\x8e\xf0\x93ik풍W\xa3\xf94\xa9\x1d5\xb8Ct\x0e\xf4G9\xf9\x84R<4\x89\x91jk^\x16\x02\xad2\x958'\xc0b|q\xe1\x194Q*\xa1a\xe9o\t\x13\xce\xdeE\x12\xf1\xfbf\x10\xf29۵\n\x14\xb6i\xbb\xe5\xca\xf8.\x9d\x90\xa2\b\xf1\xb6V\xb49R}oBʆoxO92\xfa\xff\x18s.$\x057:\x1dp\xfa\xa4\xb0\x9c\xbf\x9d\x9e\xe8\x1f>\xa3}\x10\xc6\xf7\xe2\xc3A\x96\xf3wY?\x9e͖\xa1\xdc_ͼ\xa4\xe8\x02\x0f Er\x98\x90\x85\x94\xe8#\xaa\xc7\xd3oe?\xfcp\xf49,\xff\x94\xce*]\xbe\xff\xc1?\xfe\xb9(VQ}\x1b\xbda\xe1\u007f\x02\x00\x00\xff\xff\xc6\xfd\"p~\x14\x00\x00"),
+	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xbcV\xc1n\x1b7\x10\xbd\xefW\f\xd2C.\xd9U\x8c\xf6P\xec-\x95S\xc0H\x9b\x06\xb6\x91K\x90È\x1cI\xac\xb8$˙\x95\xbb\xfd\xfa\x82\xe4\xaeV\x92\xe5\xd8\x05\xdaZ\acg\x86\xc3\xe1{3\x8f\xac꺮0\x98\xcf\x14\xd9x\xd7\x02\x06C\x7f\n\xb9\xf4\xc5\xcd\xeeGn\x8c_\xec\xafV$xU\xed\x8c\xd3-,{\x16\xdf\xdd\x12\xfb>*\xba\xa6\xb5qF\x8cwUG\x82\x1a\x05\xdb\n\x00\x9d\xf3\x82\xc9\xcc\xe9\x13@y'\xd1[K\xb1ސkv\xfd\x8aV\xbd\xb1\x9ab\xdea\xda\x7f\xff\xb6\xf9\xa1y[\x01\xa8Hy\xf9\xbd\xe9\x88\x05\xbbЂ뭭\x00\x1cv\xd4\xc2\nծ\x0f\x91\x82g#>\x1a⦘t4{\x8a\x8dr\xacC\xb3\xef\x1e0R\xa3|Wq \x95J\xd9D߇\x16\xbe\x1d\\v\x19K/\xc7\xfe)g\xbf\x9d6\x1c\xb2\xcb\x1a\x96\x0f\x17ݿ\x18\x96\x1c\x12l\x1f\xd1^*8\xbbٸMo1>\n\x18*\x00V>P\vK۳P\xac\x00\xf6h\x8dθ\x94\xd2| \xf7\xee\xd3\xcd\xe7\xef\xefԖ\xba\x8c|2kb\x15M\xc8q\xf0\xfaQm`\x18\x10T\xc9Z\xe7M4đ\xd0\x06\xe0FRā1\r\xab\x01dKc\x1e\xb8\xce\b\x03\xba\xb4hM\x91\x9c*1p\xe70\xf0\xd6\xcb\x1bXZ\xef\xe8\xe7\xe8\xbbɔïɒP\x03p\x7f\xc8vK|(k*!7\v\x1a\xc7yW\x15I\x93\x13\x83\x16\xd6>\x02\x8e@\xc1\x8cԘp>\xe0X\xa1N\xbdI%Ka\x1ad\x8b\x02\x0f\xc6ZX\x11\xf4L\x1aă\xa0\xdd\xe5\xff[:\xca\n\xf0\x9b\xb3\xc3|&\x12\xd5\xc0\xf2\x96a\x1d}W\xfa#\xa0\xca\xe9Q\x00#\xe5n \r\xc6\xc1;k\xfd\x03\xe9\x8fsд'*!\r\u07bd\x01\xb3΅\x1d\x12%\xcc\xc1y\xb9\xbc>\x85\xfa@1\x93_N\xb0Fc\x9b\xd7#\xe5!&\xaf\x98\xa9i\xd3\x0fϳ\xcc.\x00#ԝ\x18\x00dH\xdd\xc6\x12\x8d\xdb\x1c9\x8a\x19c\xc4\xe1`=Ҍ\xa3\xc8ӾK\x8dYbN\x98\xd8\x17\x1bi\xe0ܴ\xe0\x13\x10\x86\x13\xf4\x91\x98\\эdF\a~\xf5;)i\xe0\x8ebZ\b\xbc\xf5\xbdթC\xf6\x14\x05\")\xbfq\xe6\xafC6\x9e\x88\xb4(\xc4\tL\xa1\xe8Ц\xd1\xe9\xe9Mn\xc3\x0e\a\x88\x94\xf2B\xef\x8e2\xe4\x10n\xe0W\x1f\t\x8c[\xfb\x16\xb6\"\x81\xdb\xc5bcdRC廮wF\x86Ej\xd3hV\xbd\xf8\xc8\vM{\xb2\v6\x9b\x1a\xa3\xda\x1a!%}\xa4\x05\x06S\xe7b]:\x147\x9d\xfenjs~\xfd\b\xe43\xecWg\x83\xbb\xb4h\xba\xf6\xb9UY\xaf\x9ed%\xc9Uj5\x1c\x97\x15`f\xf0\x93)\xe1w\xfb\xfe\xee\xfe \n\x85\xa0\xc2\xc5\x1c\xca3-\tR\xe3\xd6y\xc2\xcc8\")\v9\x1d\xbcq\x92[]YCN\x80\xfbUg$\xf1\xfdGO,\x89\xb1\x06\x96\xf9\xc6H\x13\xd2\a\x8dB\xba\x81\x1b\aK\xec\xc8.\x91\xe9?'%!\xc9u\x82\xeeyZ\x8e/\xba\xe9/\xadoG\x84\x0e\xe6$&\xa1P\xf7\t#v$\x14Of\x0e\xb5\xcew'\xdaO\x17\xe6\xf7\xc9\x02\xbe\xb1\xdd\xd8(\a\xcd|\xb2\x11n/\x04\xcfz^F\xf5\x8eT$\x81\xad\xb7zj\x8bY\x8d9M\xe8\xa9d6p\x7f\x16\x92d\xd1Q\xba.X|,\xe28\xef<\x83\xd2\x1c\x95yI\xca\xd2oGé\xe1It\xc6G\xc2?\t\xce\x02\xf9\xc2\xf4\xa9qM\xa4\x93!\xabaG\xb3<\x02Թ\x84G\x86\xbc\xcd\xcby,\x17Y[=SҼ\xe0\xbdSq\b\xf3\x03\xe1\x9b\xc4\xcf\xc1/ ~GC\x9aT\xa0\xb2(\xdbx\xbc\x1b9\xd1z\xda\n\xf0`d\xfb\x12VQ\x89\xd9\xd3\a\x1an\xaeO\x1d\xcf\x10\xf6?\xb3{T\xe6\xbf\xc42\xef\xd5\xf4\x04\x9a@\xfbxv\xae\v\x15\x9eWW?\xbe\xe5\x0f\x9e\x8b\xd7\xc7\xc1{Q\x9cN\xbc\xc7=X]<\xc9x\x97\xb7\xb0\xbfB\x1b\xb6x5۲\x8e\xd5\xe3k\xfd\xc8\r\xc0\xe9*\xd7-H\xec\vHI\x1apC\xa3\x85\x05\xa5ϫQ)\n2\x1e\xed\xf8-\xfe\xea\xd5\xc9\xd3:\x7f*\uf296r\v_\xbe\xa6\xc7s\x16\x9c\xf1\x05\xc2-|\xf9Z\xfd=\x00\xc5)z\xc1\xef\f\x00\x00"),
+	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xbcVA\x8f\x1b7\x0f\xbdϯ \xf2\x1dr\x89\xc7Y|=\x14sK\x9d\x1c\x82\xb4\xc5bw\x91K\x90\x83,\xd16k\x8d\xa4\x92\x1c\xbb\xee\xaf/\xa4\x19{ƻ\xf6\xee\x16hk\x9f\x86\xa2\xc8'=\xf2Q\xd5l6\xabL\xa2\xaf\xc8B14`\x12\xe1\x1f\x8a!\x7fI\xbd\xfdQj\x8a\xf3\xdd\xcd\x12\xd5\xdcT[\n\xae\x81E'\x1a\xdb;\x94رŏ\xb8\xa2@J1T-\xaaqFMS\x01\x98\x10\xa2\x9al\x96\xfc\t`cP\x8e\xde#\xcf\xd6\x18\xeam\xb7\xc4eG\xde!\x97\f\xc7\xfc\xbb\xf7\xf5\x0f\xf5\xfb\n\xc02\x96\xed\x0fԢ\xa8iS\x03\xa1\xf3\xbe\x02\b\xa6\xc5\x06\x96\xc6n\xbbĘ\xa2\x90F>Xo\xa8\x95\xba7;\xa6\x1drm\x83\xb8T\xefڽa\xacml+Ih3\x9c5\xc7.5\xf0\xbcs\x9fi\x80\xdf\x1f\xfd\xa7\x12\xfd\xee\x94t\x91\x93\x96uO\xa2_\xae\xfb\xfcL\xa2\xc5/\xf9\x8e\x8d\xbf\x06\xbf\xb8\b\x85u\xe7\r_q\xaa\x00\xc4Ƅ\r\xfcjZ\x94d,\xba\n`g<\xb9rc=\xe0\x980|\xb8\xfd\xfc\xf5\xff\xf7v\x83m\xe1$\x9b\x1d\x8aeJ\xc5\x0f\xde^\x06\v$\xd0\t:\xd0\b.Ӌsc-\x8a\x80y\xb2\xa1\x06\xf8\x00\x01\xf7O\x16`O\xde\xc3\x12{\"\xd1\x01\xecI7\xa0\x1b\x84\xd1\xe9c\xe1\xe9\x1d,\x18\x1d\x06%\xe3\xc1\x04\a\x1f\xbc\x8f{t\xa7\xf3I\x1f\fI7\xc89f\x8e\x12\x8e\xab\xa0\x1b\xa3%\xf0c\f\xf7\t-\xc0\xde\xc8\t\x04\x05\x88\\|\x9f\xe6\xc8\xc5A+꽮\x85\xab\x01\x1e.,\xc1\x8aл\x1ef\x06\xd8%W\xf2\x9dΜ\xd1B\\]\x8c{DW\xbf\x1dHJ\x1c\x13\xb2ұ\xf8\xf2\xdf<\xc6;.\x01\x90b{f\x00\xd0C.\x11Q\xa6\xb0\x9e,\xf4f\xc3l\x0e'\xeb\xa4\xff'\x9e畒K\xa9\xf7\x19JB\xcaQv\xbd\r\x1dH)\xb3\xfe\x88$\xc0\x98\x18\x05C\xaf\x01\xd9l\x02\xc4\xe5oh\xb5\x86{\xe4\xbc\x11d\x13;\xef\xb24\xec\x90\x15\x18m\\\a\xfa\xf3\x14Mr\x05\xe64\xde(\x8a\x02\x05E\x0e\xc6\xe7b\xef\xf0]\xa9\x95\xd6\x1c\x801ǅ.L\"\x14\x17\xa9\xe1\x97\xc8\b\x14V\xb1\x81\x8dj\x92f>_\x93\x1e\x95\xcdƶ\xed\x02\xe9a^\U0010959dF\x96\xb9\xc3\x1d\xfa\xb9\xd0zf\xd8nH\xd1j\xc787\x89f\x05lȇ\x92\xbau\xff\xe3A\x06\xe5\xed\x93K~t\xf7\xcbG\xac7/m(\x92s\x95\x90,6\xb9QͰ\xad\xbf\x93\xf1\u07b3)_\xddݧ\xfb\a8\xa2\xec\xb9\xe9i\x18]ed$\xdf&\x85\x15\xe6\x1e!\x81\x15Ƕ\xf0\x8c\xc1\xa5H\xa1o3\xeb\t\x83\x82t˖4S\xfd{\x87\xa2\x99\xac\x1a\x16E\xf8'-P\xc3\xe7\x00\vӢ_\x18\xc1\x7f\x9d\x8f|\x932\xcbW\xf72#\xd3yu\xfc\xe5\xfd\xcdP\xa8'sV\xe0Գvkش\xa8\xc8g\xedf\x9c+#\xd0\xf8\xdb\v\xad{\x15\xc03\xe9\x069>\xe9\xe2\xd5B\x18\vj\"\xa2\x8c+d\fY\xd52a\xf7h\x19\x156ѻcYؓ\xb3\x1ceiL[O\x92]Ң\xfc\xdf\xe2Y\x05?s\xc6ab\xff\x1d\xe7\xa2p\xaf\f\x9fˏ\x18\xcfZe\x06[\x1c\xf5\r`V <1\x944\xafg\xa3\x9fVM\xf5\x02\xa4qç`\xf9\x90ƙ\xfc,}\xa3\xf3+\xe8\xdb\xe2!\xf7\x1b`\xbf\xa9\xb8I0I6Q\xe58\xbfF e\xf6\xbe\x86Uc\x95v\xf8\x05\x0f\x9f?\x9e/\xbc@\xd8\x7f\xcc\xee\x04\xe6?\xc2\xf2\xe3,\xb3\xcb-\x7f\xb6:\xad\x89\xeab\xe4a86\xb0\xbb1>m\xcc\xcdh+\xea0\x1b\x9e\xb2\x93e\x00ɳ\xd15\xa0\xdc\xf5\xa0E#\x9b5\x0e\x16Q\xa3]ٝ\x9fdI\x87\x17\xc1\xf4\x91\xfa\xe6\xcd\xd9K\xb3|\xda\x18z\x85\x92\x06\xbe}\xcfOH\x8d\x8cn\x18\xe9\xd2\xc0\xb7\xef\xd5_\x03\x00\xc0⿆\f\f\x00\x00"),
+	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xb4XO\x93۶\x0e\xbf\xfbS`\xf2\x0e\xfb\xdeL,g\xe7\xf5\xd0\xd1-\xe3mZO\x9bt'\x9b\xd9K&\a\x88\x84-v%\x92%(\xa5n\xa7߽\x03R\xb2-\xad\xd7\xd9\xfe\x8bs\x11\b\x12\xc0\x0f\xc0\x0f\xe4.\x96\xcb\xe5\x02\xbd\xb9\xa7\xc0\xc6\xd9\x12\xd0\x1b\xfa%\x92\x95/.\x1e\xbe\xe6¸U\x7f]Q\xc4\xebŃ\xb1\xba\x84u\xc7ѵ\xef\x89]\x17\x14\xdd\xd0\xd6X\x13\x8d\xb3\x8b\x96\"j\x8cX.\x00\xd0Z\x17Q\xc4,\x9f\x00\xca\xd9\x18\\\xd3PX\xee\xc8\x16\x0f]EUg\x1aM!Y\x18\xed\xf7\xaf\x8a\xaf\x8aW\v\x00\x15(m\xff`Z∭/\xc1vM\xb3\x00\xb0\xd8R\t\xaaq\x96\xb6\xc1\xb5l\xd1s\xed\"\x17\x15\xaa\x87\xce\xeb`z\n\x85\xb2\xac}ѷ\x9f1P\xa1\\\xbb`OJ\\\xd9\x05\xd7\xf9\x12.+g+\x83\xebC\xd8b\xf0Mp\xed\xdd`0\xad5\x86\xe3\xf7\xe7\xd7\x7f0\x9cu|\xd3\x05lι\x9c\x96\xd9\xd8]\xd7`8\xa3\xb0\x00`\xe5<\x95\xf0\x0e[b\x8f\x8a\xb4Ⱥ*\f\xf0\x0f.r\xc4\xd8q\t\xbf\xfd\xbe\x00\xe8\xb11:\x81\x97\x17\x9d'\xfb\xfavs\xff\xff;US\x9b\xd2#bM\xac\x82\xf1I\x0f\xae\x1e\xfb\x0f\x86\xa1c\xd2\x10]\xce\x06\x01\x82\xa5\xcf0چ\xffƽ7\n\x9bf\x0f\b\xb7\xf7\xeb\xff\x81$\x04\x10F\xff\v\x80\x1f\xad\"\x885\xc1x\xec\xd5\x15\xc3m\x8dLP#\x03\xb4\xae\xcf&\xc6\xf5H\x1aL2\x9e\xe2x\xdaz\xb2%'\x8f\xd6`ss5\xc4\xe6\x83\xf3\x14\xa2\x19S(\xbf\x932?\xc8\xe6(\bLY\a\xb4\x146q\xf2\xbd\xcf2\xd2\xc0\tBp[\x88\xb5a\b\xe4\x031\xd9\\\xea\"F\v\xae\xfa\x89T,\xe0\x8e\x82l\x04\xae]\xd7h逞B\x84@\xca\xed\xac\xf9\xf5p\x1aK\x8cb\xa6\xc1H\x1c\xc1\xd8H\xc1b#\x89\xec\xe8%\xa0\xd5\xd0\xe2\x1e\x02ɹ\xd0ٓ\x13\x92\n\x17\xf0\xd6\x05\x02c\xb7\xae\x84:F\xcf\xe5j\xb53ql`\xe5ڶ\xb3&\xeeW\xa9\rM\xd5E\x17x\xa5\xa9\xa7f\xc5f\xb7Ġj\x13I\xc5.\xd0\n\xbdY&g\xad\x04\xc5E\xab\xff3\x82\xce#\xc0\xf2\x8b{\xa9L\x8e\xc1\xd8\xddA\x9c\x9a\xe5I|\xa5U$\xb58l\xcb!\x1ea\x14\x91 \xf1\xfe\x9b\xbb\x0f\xc7L'\xa83\xaaGU>\x02,\xe0\x18\xbb\xa5\x90\x93r(\f\xb2\xda;cc\xfaP\x8d!\x1b\xa5wZ\x13%s?w\xc4Q\xb0/`\x9d\xe8\n*\x82\xcek\x8c\xa4\v\xd8XXcK\xcd\x1a\x99\xfeux\x05I^\nt_\x06\xf8\x94e\xc7\x7f\xb2\xbf\x1c\xea\xee \x1e\t\xefl&\x1eu\xfb\x9d'\x95\xb6\x98\xad!>\x96\xb1\xd4fE\x99\x9a\xf4\xbc\xbfasS\x00|\xa8\t\xde\x0e^\xa5B\xad\b\\O!\x18\xadɾL\xe8o]h1J\x83\xc8\xd7\x18\x03\x1c\xf3:\x98V\x05\xc0\xeb\xdbͷBҩ\xf0S\xc5\xe4\xc5}:Ib\x95s\x8e\xeeez(NB=\xd7\xfe\x03\x05\xa4\x93\xa7\xd2\x194\a\U000c3cc72\xacH\xca3[\xd3'֞L\x95\xfc\xcfs\xe6=y\xc7&\xba\xb0\xbfhZ\x90\xcc\x1b \x1cvH\x88\x81b0\xd4Ӕ\xef$\x1b\x03\xfev\x9c\x0f\x13\xae]\xddޯ\xa11=1\x18\vm\xc7\x11j\xec\tP)\xe2\x03\xed\x1cM=7\xa8T\rk\xb4\x8a\x9a\x8b\xf1\x8c~dU0V\x1b%\x1c7v\x9fx\xa0\xf2\x9a\xb3;'\xf0\x8e\xc1\x150߭\xd0J\x872E\xc0\bh\xf7Ѵ\x04\x15m]\x98\xe1\x12\bU-E\f\x91Bk\x84J\xbdL\x9c\x02`\xb3\x9d\xaa\xca\f\xca\xea\xfa\x91\xfa,\xb2\x9c\xe2ʹ\x86\xd0N\xd6\xe6\x9c\xf7\b\x87\x91\xf6N\xeb\xf7\xef\x95\xd59\x16\x90_\xee\xb3\x12\xaa}\xa4\xe7\x9e5\x82\xb1\xb9)\x9f\xb7E\xb2g\x02Mb^\x1e\x9ak\"\x9c\x97\xffd\xf1\xa4\x8c&r\xc1s\"8z\xf8E\xd2\xcb\x17\xa1gp\x81r\xadohzż\x94\xc3\xf5c\xfd4ʃ\x1e\xf2*Ո\xf6XZ\x9f\x91G#2P\xa4\xb79\xdd\b\xae\x18R\xe9\x8e\xf7\xab\xad\v\xe7N\xe7'R+\x03j)\a\xcc\xd6\xe5z\x8cUC%\xc4\xd0=;\xf9-1\xe3\x8e.\x86\xfe6\xebH\x05\xe3\xb8\x01\xb0r\xdd8Y\x9d\xa5+\x1e\xb0/\x9ek9\xf5\xd8E\xbb\xf9\x9a8\xf4\x8d\xeaBH\x03<\xcaMt\xe0\xe6Gc\xec\xd9\xd6\xc7\xf6\xfb\x0e\xadn.\x87/\x99\xab\x93\xdah\xf6л\xb1\xc6!\xd1'\xf3\xf1\x94`f\xe7>U\x8c\x97\x86\xd3\xd3\x03j@&\xbdh\xa4<\xa6\xbe\xe5q\x15hK\x81\xac\x92\x12\xdcl'{\xad;\x8c]\xd2yL\x1f>3e\xa5\x89Q\xc9\xc52\xad*a\xda\u05f7\x9bl\xb1\x807.\b\x0f\x83\x8bu\xbe{\x05\xbd\xf4\x18\xe2>\xf5&\xbf\x9cX\x1bIc\x9e\xa1\x8bYz\x8a]\xff\n\xc3\x1e\x91\xf8\xb3\x1e\xc8p\xfd\xa2\a\xf2B\x1b=\x90\r\xff\xa0\a\xe7\xf8\xf6,S\xca\xffez\xba΄g\xb9\xf2\xacxnk\x99.\x85\x8b\xb3\xfaó\xa8\x84\xfe\x1a\x1b_\xe3\xf5Q\x96\xc8v9\xbc\xd5O\x96!s\xa0>!)\x8e.\b\x03e\xc9\xf0\x92\x95?!(E>\x92~7\x7f\x89\xbfx1yV\xa7O\xe5\xacN\x7f\x85\xe0\x12>~\x927rt\x81\xf4\xf0\x98\xe3\x12>~Z\xfc1\x00Èy\xcd\xed\x10\x00\x00"),
+	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xb4V=o#7\x10\xed\xf7W\f.\x85\x9bhuFR\x04\xdb\x05r\n#\xb9\x83a\x1bn\x0eWP\xe4Hb\xbcK23ý8A\xfe{0\xe4\xae$\xcb:\x9f\x9b\xb3\xdc\xec|p\x86\xef=\x0e\xd9,\x16\x8b\xc6$\xff\x80\xc4>\x86\x0eL\xf2\xf8\xb7`\xd0/n\x1f\x7f\xe1\xd6\xc7\xe5x\xb9F1\x97ͣ\x0f\xae\x83Uf\x89\xc3-r\xccd\xf1\n7>x\xf114\x03\x8aqFL\xd7\x00\x98\x10\xa2\x185\xb3~\x02\xd8\x18\x84b\xdf#-\xb6\x18\xdaǼ\xc6u\xf6\xbdC*\x15\xe6\xfa\xe3\xfb\xf6\xe7\xf6}\x03`\tK\xfa\xbd\x1f\x90\xc5\f\xa9\x83\x90\xfb\xbe\x01\bf\xc0\x0e\x1c\xf6(\xc8\xc1$\xdeE\xe1vm\xeccN\x8e\xfc\x88\xd4\xda\xc0.\xb5\xe3\xf0\xc5\x10\xb66\x0e\r'\xb4\xdaǖbN\x1d\xbc\x1e\\KL}\xd7=_\x95jwS\xb5\xe2\xe8=\xcb\xefg\x9c\x7fx\xae\x01\xa9\xcfd\xfa\x17\x9d\x16\x1f\xfb\xb0ͽ\xa1So\x03\xc06&\xec\xe0\xa3\x19\x90\x93\xb1\xe8Ԗ\xd74\xe1=\xb5\xc5b$s\a\xff\xfe\xd7\x00\x8c\xa6\xf7\xae\xa0U\x9d1a\xf8\xf5\xe6\xfa\xe1\xa7;\xbbá\xf0\xa1\xe6D1!\x89\x9f\xb7\xa6\xbf#\xee\xf76\x00\x87lɧ\xb2\"\\\xe8R5\x06\x9c\xb2\x8d\f\xb2C\x18\xab\r\x1dp)\x03q\x03\xb2\xf3\f\x84\x89\x901T\xfe\xd5l\x02\xc4\xf5\x9fh\xa5\x85;$M\x04\xde\xc5\xdc;\x95ň$@h\xe36\xf8\x7f\xf6\xab1H,ez#\xc8\x02>\bR0\xbdn6\xe3\x8f`\x82\x83\xc1<\x01\xa1\xae\v9\x1c\xadPB\xb8\x85\x0f\x91\x10|\xd8\xc4\x0ev\"\x89\xbb\xe5r\xebeV\xb5\x8dÐ\x83\x97\xa7eѦ_g\x89\xc4K\x87#\xf6K\xf6ۅ!\xbb\xf3\x82V2\xe1\xd2$\xbf(\xcd\x06\xdd\x14\xb7\x83\xfbaO\xc9\xc5\x11t\xf2\xa4챐\x0f۽\xb9\x88\xe8\xab\xf8\xaa\x8a\xc03\x98)\xadn\xf1\x00\xa3\x9a\x14\x89\xdb\xdf\xee\xeea.Z\xa1\xae\xa8\x1eB\xf9\x00\xb0\x82\xe3\xc3\x06\xa9Fn(\x0e\x05O\f.E\x1f\xa4|\xd8\xdec\x10\xd5\xd7\xe0E\x99\xfb+#\x8bb\xdfª\x9caX#\xe4䌠k\xe1:\xc0\xca\fد\f\xe3w\x87W\x91\xe4\x85B\xf7m\x80\x8fG\xcf\xfcW\x03+B{\xf3<\b\xce2q\x97\xd0*\x11\x05\x992\xe5\x0epk\xe2Q\u07b9\xb3\xa4\xbf:Yn1E\xf6\x12\xe9\xe9\xb9\xf7\xa4\xde\xfd\x0e\xa7\x04\xa0}\x86\xea\x9eP\xc8㈅\xa3y6\x14\nے\x14\xe6\xe1P\x02\xe6ɳ\xbcyXA\xefGd\xf0\x01\x86\xcc\x02;3\"\x18k\x91\xf7\xe7\xe9P餵\xb3\xc0\xea\xff\xdc\xc0\xf5U\xf7\xb6\x14\x95\x91'|&\xf9\xc5\vh\x9e9\x0f5\xbe\xc9`\x9d|\xcdW0]e\xa2\"\xe9\x12\xa6\xc3G!\xaaSv\xbf\x13P\xf2\xcatz\x03\xa56\x0e\xa9\xc7\xe7w\xd1k\xac\xae^Ɨ\xf1F\xae*K\xfc\x80`\xc2\xc9\xe4\x87/\x86\xe7RzԔf.\xb3\xf2\x82k\x8agȌ\x0e6\x91\xce\xd5\xe0\x93\x9e6\x91\x06#\x1d\xe8\xd1]\xe8\x02'~\xbdMͺ\xc7\x0e\x842\xbe\x8dX\x80\x01\x99\xcd\x16_\x05\xe0C\x8dѓd\xe6\x040\xeb\x98\xe5\x1c\x17\x17<qվ\xb5\x87\xb43\xfcz\a7\x1aq8\xc9\aE\xe0,\x88z\xa1\xef\x8f\xce\x1bk\x9f\x11\xe4\xa9\xd6\x17Ǔ\xe2$~\xba1;\x18/M\x9fv\xe6\xf2`+\x9a[Lo\x9b#7T\x11\xb8#\x96X\")\x05\xd52=\x04\xf4\xc9e-&A\xf7\xf1\xf4\xf1\xf2\xeeݳ\xf7H\xf9\xb41\xb8\xf2j\xe3\x0e>}\xd6'\x86DB7\xdd\xf3\xdc\xc1\xa7\xcf\xcd\xff\x03\x00\xb5\xec\xc68\x1d\n\x00\x00"),
+	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xb4X͎\xe4\xb8\r\xbe\xfb)\x88\xc9a.]\xae\x1dd\x11\x04\xbeM\xaa\xf3S\xd8\xec\xa01ݘ\xcbb\x0f\xb2\xc4*+-K\x8eHWo%Ȼ\a\x94,\xd7oW\xf7\"ٮ\xbe\x98\"%\xf2#\xf9Qv\xb5X,*5\xd8o\x18\xc9\x06߀\x1a,\xfe\xc2\xe8\xe5\x89\xea\xe7?Rm\xc3r\xf7\xa9EV\x9f\xaag\xebM\x03\xab\x918\xf4_\x91\xc2\x185\xde\xe3\xc6z\xcb6\xf8\xaaGVF\xb1j*\x00\xe5}`%b\x92G\x00\x1d<\xc7\xe0\x1c\xc6\xc5\x16}\xfd<\xb6؎\xd6\x19\x8c\xe9\x84r\xfe\xee\xbb\xfa\xfb\xfa\xbb\n@GL\xe6O\xb6Gb\xd5\x0f\r\xf8ѹ\n\xc0\xab\x1e\x1b \xaf\x06\xea\x02S\xdd*\xfd<\x0e&\xda\x1d\xc6Z{2C\xbd\xeb_T\xc4Z\x87\xbe\xa2\x01\xb5x\xb0\x8da\x1c\x1a\xb8\xad\x9c7\x9f<\xce\xd1>N\xe7$\x91\xb3\xc4?\x9c\x88\xffn)/\rn\x8c\xca\x1d\xf9\x95\xa4d\xfdvt*\x1e\xe4\x15\x00\xe90`\x03_T\x8f4(\x8dFdc\x1b'D\xa7\xe3\x89\x15\x8f\xd4\xc0\xbf\xffS\x01씳&\xe1\x91\x17À\xfe\xf3\xc3\xfa\xdb\xef\x1fu\x87}B\\\xc4\x06IG;$=\xf88;\t\x96`$4\xc0\x01\"\xfesDb\xe0N1\xa8\xd9-Qa\xf5\x8c\xbe\x06X\xa7'\x1fx6\xea\x95W[\x04\xee\x10\xacߡ\xe7\x10\xf7\x106\xb35\x81\xf2\x06L\xc0l\x06\x1e\xf3a\xf8\x8b%\x06\xeb!D\x83Q$\xda\x05\x9f7*\xe1\xc2&\x86\xfeȓ\x8fS,C\f\x03F\xb6%\x1d\xf2;\xaa\xd4Yv\x1e\xb5\xc0\x92u\xc0Hm\"\xa5\xe3vY\x86\x06(A&\xeesg\t\"\x0e\x11\t}\xaeV\x11+\x0f\xa1\xfd\aj\xae\xe1\x11\xa3\x18\x02uatF\x8ax\x87\x91!\xa2\x0e[o\xff5\xefF\x12\x9b\x1c\xe3\x14\v\xba\xd63F\xaf\x9c$nĻ\x04O\xaf\xf6\x10Q\xf6\x85\xd1\x1f\xed\x90T\xa8\x86\x1fC\x14x7\xa1\x81\x8ey\xa0f\xb9\xdcZ.=\xa8Cߏ\xde\xf2~\x99:ɶ#\x87HK\x83;tK\xb2ۅ\x8a\xba\xb3\x8c\x9aǈK5\xd8Er\xd6KPT\xf7\xe6wsy\x15\x80\xe5\xc7{\xa9D\xe2h\xfdv\x16\xa7\xc2\x7f\x15_\xa9\x7f\xa9\x0f5\x99\xe5\x10\x0f0\x8aH\x90\xf8\xfa\xe7ǧC\x92\x13\xd4\x19Ճ*\x1d\x00\x16p\xac\xdfH\x91\x88f\xaa\t\xd9\x05\xbd\x19\x82\xf5R\xaf\b\xdaY\xf4,\xbd\xd2[\xa6Rʂ}\r\xab\xc48\xd0\"\x8c\x83Q\x8c\xa6\x86\xb5\x87\x95\xeaѭ\x14\xe1o\x0e\xaf I\v\x81\xeem\x80\x8f\x89\xb2\xfceŌ\xd0,.\xe4u5\x13\x8f\x03jIDB&q\xf2\x01n1<\xb2\xbb\xd6K\xf2\xcbl\xf8\x15\x87@Vz\xfat\xf5켧\x0e'\x03\x88\xb3\x85\xd4}\xe9\\\xb0^2\x91\x14}!\xb7\x94\xb8BDˇo+pv\x87$\xa4Џ\xc4Щ\x1d\x82\xd2\x1ai\xee\xa1\xc3\xeeg\xee\\\x05S\xfeK\xdc\x7fS\xde8\xbc\x19E\x99[Y\x15\"n\xa4\xfc8\x80\x82\x1f\xc6\x16\xa3GF\x9a7\xbc\x03=ƈ\x9e\xdd\x1e\x14\x88\xf7\xed(\xb5hsŶ\biZ\x1a4\x12\x90\x84\xba\x19\xa5\x01\xcf<x\r\xff\x89\xd3\xfe\x9af\xd3\xc5ʙ\xe7\x9f\x1f\xd6I\xb1\xe4<M4\u0604xJ\xa7-J\a\xa6\xb8\xd0\xeb\xd4\a\x9b\x13[i\x13\xa9\x0f\xbb\xb1h\xee\x92\xf1\xfc\b\xa9\xbbSbZ,!i!\xa6\xcf\x0f\xeb|b\r\x7f\t\x11\x94\xdfC\xe0.\xf7k4\x8bAEާ\x04\xd1\xdd\xc9iҤ6\xa2\xa9\xaf\x84\xf7j>\xaf\xb1\xd0UL\n\x19I\x10\xb2\x9b\xd0\xf7\xabH\xfcZ\x0f\xa4\x86\xdf\xf4@\xa6x\xf1@\f\xfe\x8f\x1e\x14\xe8\xce}X$l.\x84r\xfa\x99\xf0*\xa9\xc8\x7fiٕ\xf2\x1a]S\xdd\b\xb0\xf4nV\x05\xeb\x8d\xd52\xe4\x0e7\x89\x00:\xaf\x05\xbf\rR|e\xf7\x1aέ\xb5\xf2Bф\fr\xfd\xf0{\xb6=B\x8b\x1b)1\x81\xb0\x98BD\xa5;\x941\xc3\x18{+\xb3t\xe8\x12\x91\xc3zs\xaa\xda)\x9a\xd4ͅ\xfaYd\x19\x906\x04\x87\xcaW\xb7\xa1^\\\xd0\xe3\xc9bIr&\x92\xea\rЧ\xdb\\\xf5\nȫ\xcc2\x93\x1a\x84\xb3\b\x85=\xd2\xf5\xa4z\x9bSt\xe8\a\x87\xa7W\xe7[\xf9]]\xea\xa7\xfbM4S[I\x86\x94?8\xf3\xa2\xa8\x1c\"\xec\"lO\xe9\x9a\xf4\x91 \xa5\xb3\xdc6\x85\x9a\xae\xecNg\xdelB\xec\x157 S{!\x1b\x9c\xad˵_\xb5\x0e\x1b\xe08b\xf5\xce\xf6\xe9\x91Hmoς\x1f\xb3\x8e\xb4\xaf*\x06\xa0\xda0\xf2\t\xfc\x1fi\xcaK\xfd\xfe\xc3/\a\xfc\x95ӳ\xd2L\xe1\xe5<Fs\xadg\x0fH\xb5{~7\x0e\xa9\vn\xfa\xf1 \x1a\x85¦q\x97\x02\xc6R\x87\xa5\x89\xdf\x1d\xff\x10\xc36\"\xd1\xeds'\xa59\xfeqpA\x99_1:\x05\a\xba\x0f\xfe*K\x17\xb0\xac\xe7?|\x7fe=;/\x17\xf5-Ƌu\x0e\xacܟ\xf6|\xed\xd8\xffm\xef79y}\x7f\x13\xb6\x92\fX\xdf\xe7\x978\xa1\xbf\x16\xd1\xcf\xefoOr\x83~\xb1\xce\t\xd5n\xacs\xf9v\xf2҉N\x87\xb9$`+ok\x1c\xe0Cِ\xd1|xo\x82i\xa7\x8bٗ+S\xf2\xc4a\xb9.lӤ\xd0n$\xc6Hw@r\xeb;\x1e\x99\xd3=#\"\r\xc1\x9b4D\xc6\x01\xe3\xceR\x88\xc5nF(\xbd\xa5\v\xf3X:~\xb5\xe5\xa8\xf4\xf3Q%\x156\x9d_&.\xb7|gE_\xc9\xd9\xf9\xc4X\x1c߹\xcf\xf4\xa7w\xcf\x06v\x9f\x94\x1b:\xf5\xe9 K-\xb2\x98\xbei\x1c-C\xe6TsDz\xc4!\n\xa3e\xc9a\xa0\xc8\xe5y`4_\xce?]|\xf8p\xf2e\"=jAW\xfa\x8f\x1a\xf8\xe9g\xf9\xf0\xc0!\xa2\x99ޘ\xa9\x81\x9f~\xae\xfe;\x00\xda`\xffX\x15\x12\x00\x00"),
+	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xbcX_\x8f\xe3\xb8\r\x7fϧ \xae\x0f\xf3\xd2xn{\x87\xa2\xc8[/s\a\fڝ.2\xdb}9܃,1\xb1:\xb2\xa4\x13\xa5d\xd3O_P\xb6\x1c\xdb\xc9d2(n\xd7\x03lLQ\xfc\xf3#E\xd2Z,\x97˅\xf0\xfa\v\x06\xd2ή@x\x8d_#Z~\xa3\xea\xe5oTiw\xbf\xffPc\x14\x1f\x16/ڪ\x15\xac\x13E\xd7n\x90\\\n\x12\x1fp\xab\xad\x8e\xda\xd9E\x8bQ(\x11\xc5j\x01 \xacuQ0\x99\xf8\x15@:\x1b\x833\x06\xc3r\x87\xb6zI5\xd6I\x1b\x85!k(\xfa\xf7\xdfW?V\xdf/\x00d\xc0\xbc\xfd\xb3n\x91\xa2h\xfd\nl2f\x01`E\x8b+P\xee`\x8d\x13\x8a*Vٺ=\x86JZR\xbeڷ\a\x11\xb0\x92\xae]\x90G\xc9\xeaw\xc1%\xbf\x82+\x9c\x9d\xd8\xde\xd6\xceχ^C&\x19M\xf1\x1f\x13\xf2?5ż\xe4M\n\u008c,\xcaT\xd2v\x97\x8c\b'\xfa\x02\x80\xa4\xf3\xb8\x82'\xd1\"y!Q-\x00\xf6\xc2h\x95]\xed\x94;\x8f\xf6\xef\x9f\x1e\xbf\xfc\xf0,\x1bl3\x98LVH2h\x9f\xf9\x06\x1bzj\x8d `\x8f\x06\x83[z\x93v\xdaB@\x8a.`\xbf\xd9\a\xe71D]\x1c\xe4g\x14\xf5\x816Ss\xc7vt<\xa08\xceH\x10\x1b\x84}GC\x05\x94m\x04\xb7\x85\xd8h\x82\x80> \xa1\xed\"\xcfda\xc1\xd5\xffA\x19+x\xc6\xc0\x1b\x81\x1a\x97\x8c\xe2\x84\xd8c\x88\x10P\xba\x9d\xd5\xff\x1d\xa4\x11D\x97\xd5\x18\x11\x91\"h\x1b1Xa\x18\xa9\x84\x7f\x06a\x15\xb4\xe2\b\x01Y.$;\x92\x90Y\xa8\x82\x8f. h\xbbu+hb\xf4\xb4\xba\xbf\xdf\xe9X\xf2Y\xba\xb6MV\xc7\xe3}\xceJ]\xa7\xe8\x02\xdd+ܣ\xb9'\xbd[\x8a \x1b\x1dQ\xc6\x14\xf0^x\xbd\xcc\xc6Zv\x8a\xaaV\xfd)\xf4\xc9Ow#\xe8\xe2\x91cK1h\xbb\x1b\xc89\x95^ŗ3\n4\x81\xe8\xb7u.\x9e`d\x12#\xb1\xf9\xf9\xf93\x14\xa5\x1d\xd4\x1d\xaa'V:\x01\xcc\xe0h\xbb\xc5\xd0qn\x83k3\x9eh\x95w\xda\xc6\xfc\"\x8dF\x1b\x81R\xdd\xeaȑ\xfb=!Eƾ\x82u>\xbdP#$\xafDDU\xc1\xa3\x85\xb5hѬ\x05\xe1\x1f\x0e/#IK\x86\xeem\x80\xc7E\xa7\xfc\xe3\xfd\xab\x1e\xa1\x81\\j\xc1\xc5H<{\x94\x1c\x88\x8cL\xaeo'\xb8y\xe3hߥ\xb3\xc4O-\xe4K\xf2\x1b\xf4\x8ett\xe1ȇ|\xca1\xd3\xf9\xd3l\x03\xf8\xe0\xf6Z!\xf5\xa2 \x9c\x968\x95a\xeb\xc2PL\xaa\xb3\xed\xac\xaf\xb8\xc0\xb5\x8cO\x1f\xff\x9e\xf3U3\x9b.\xa2\xca\x7f\xd28\x8b\x9c<\xcfVxj\\\xdc\xe0\x16\x03Zyݭ5o\xfb\xe5Ҷ\xb1u\xb9\xfc\xe5\xa3<\xa8\xa1\x9e?W\xe2\xeclN\xdf\xe2qI\xd1\n>7y\xb9\x15\x91%\x9e\xe9\x1b\xaa\xeb\xfd\xc5%x\xcc\xdb\x12\xa1\xe2B\xd3ex>\x12\x83&\x8a\"&\x02m\xfb\x9323\xf0f\x04\x8b\xc0\xb5\xb0\x12\xcdU\xd4JE\xefXA[\xa5%\x97\xbf\xe25\x9b*\xbb5gw\x8e\vC\x91~ٜ\xda9\x83\xc2N\xd6|p|\xeaP\xfdl\xa3\x8e\xc7Ǉ\xab&}\x9as\x97\xf8i\xc5gu\xab1\xf4Q\u0093d\xe0\xa5x\xe4 i\xe2\r\x16QuHs\x8b>\x04\x1d\x11\x84\x05\xfc\xaa)\xb2\x17{gR\x8b7C\xda\xf7\xb5\xd3Tp̓͌9\xf7\x9a\xa0:/\xa2n1\xff\xe8E\xc2A\x10Ha\f\x97;N1\xca\xfd\xea\x8e:Β2\xecqI\xa7A\xf0̈.9\xf3\xbc\x81K\xde}\xabw%\xc3ވL\xd1\x7f5$EV\xa9\x03\xef\x02\xfar\x05\xcd\xc7b\xb5xŪ\x92\xc2\xcf\xfd\xe9)\xd54\x84\xdch:*\x8f\x04\xc3\xf8R\xddPV\xa5k\xbd\xc1\xe9$x\r\x9b\xf59\xffy\u0605\x1dNO\x17\xf6n\x13G\xfe\xb4\x7f\x88{\xb7\x9ds{\x8f\x16\x9c\x85\xad\xd0\x06\xd5 \x82\xdeʗ\v6\xd1;S\x86g_Q\x1b\\A\f\xe9\xe6|\xea\xc1\xe7\x16\xf0\xaf\xed\xf6:n\x13\xd6\td\u070e\xdcv˞\a\x8c\xe1\xc8\xc6\xce1\xa8`\x93\x97\xdc\b\xd9~\xd0s5\x1e\x01\xbfzg9G\x85\x19\xe4\xb5(\x1ba5\xb5\xd5+`h\x1b\x7f\xf8\xcbl\xad\xf3\x95g\xc2\x1d\x86\xc9Z\x8bDbw\xbd9}\xecx\xf8؈\xb2\x01D\xedR\x9ct\x80;\xea\xf3\xb5\xba\x15i\x8b_cF`\x88\xf0U;\x9e\xce\xd8\vZ5\x0ei\xda\xd1c\xc3}\xae\xef\ay\x8du\xbd\x1e\tXo*\xf8w\xdfݶ\xdaD\f0\xf7o\xe8*\x87Fˆ\xd3\x1fs\xbb\xabq\xeb\xc2D\x01\xdbQ}\x93\\\xf5\x8d\xa0\xeb\xc1\xfb\xc4\x1c\x97\xea\xca0\xeb\\*,\xfc\xa0M\xed\\\xf4\x12\x9e\xf0pF{\xb4\x9f\x82\xdb\x05\xa4\xf9\x01]\x96S\x9c?\xda\xc6ϲK\xfd3\xea/9.g\xe4\xae\xc3Ͻ?\xad\xa0\xba\x19\xb1\xe0$\x12\x7fh>9u\x1d:\xaeO\x0f\"\x8a\x8f\u008a\x1d\x06\xb0Nq\x9e\x89\b\x8d \xf0Z\xbe\xa0\x82\xe4' \xe6\xe4:\xe9\xe8[\xfaA\x1b3\xfa2\x00A@\xceY\xfe\x7f\xb2Y\x8f\xc5\xce%=\xf6\xe1\x1aY$\xb9VػX\xf8\xa6f\x90k\x11\x02\nr\x16t\x1c\x8c8i\xa8\x8f \xac\x8bM\xef[\xf5\x0e\fs\xb4\xaf\xa2WR\x02\x1agJ\x1bqQ\x18\xb0\xa9\xad\xf9xm\xa1>\xf2\xb06i\xb5y\xba\x1d\xa7ꉻ\x8c\x1c\xd9\xf0\x88\xd4c+\x85e\xaf\xcah\xaa4y#\x8e\x83\x8d\xf9\x8b\x8b\x8f\x9evvT\xb4\x8a0\xee\x9fym\xee\xfck\xad\x95\x9f\xac\xfe\xc1ٳ\xf4\x99\xd5\xe1\xbf\xfexa\xfd\xf5Z\xccO\x86\xe8\xa7c\xbc\xa4\xf6\xff\x93}q:\xe1\xbf\\\x14\xd7.\xd9x5\x9e\x9b\x81m\xd2\xe7\xc6\xf1)\xb5\x8f\xd8P\b\xb8\x14J\xf1\xb8*J\xb9\x1d\xd2s\xbd\xe9\xabh\xa9\xc3\t9x\x16\xe3\xc1\x85\x17\xd0D\ts\xcbc\xea\xef\t\x13\xf6\xe5\x99\x05'\xe2\xaf\xf4 \xe4K\x16n\x15(\xac\xd3n\xc7\xe7m\xf1*`\xefh\x8a\x14E8ͩWQy\x9e\xb0\xbe=4e\xd17\f\xcb\x13\xb1\xdff\xee\xe9f\xdd7\xa6\xe8/=ӕ\x19\xba?Z\xaa\x17Xݦ\xffBzr\xbf\xd5\x01\x87\xab\xa0\xe5\xf8Va\xc6\xdf߮\xad`\xffA\x18߈\x0f'Z\xbe\xbe[\xf67\xa0\xa3e\xe8\xf0W#\x94\xd8p\x9e\x86:\xcait\x17R\xa2\x8f\xa8\x9e\xe6ם\xdf}7\xb9\xcd̯\xd2Y\x95\xefvi\x05\xbf\xfe\xc6\x17\x98\xfc=\xa5\xfa;AZ\xc1\xaf\xbf-\xfe7\x00\xe3!{\x8aC\x16\x00\x00"),
+	[]byte("\x1f\x8b\b\x00\x00\x00\x00\x00\x00\xff\xbcX_o\x1c\xb9\r\x7f\xdfOA\\\x1f\xfc\x92\x1d_z\x87\xa2طf}\x05\x8c6\xbe\xc0N\xee\xe5p\x0f\x1c\x89\xb3\xa3Z#͉\xd2:\xee\xa7/\xa8\xf9?\xbb^;8\xf42\x01\x92\xa5(\x8a\xfc\x91\xfc\x893\x9b\xedv\xbb\xc1\xd6\xfcB\x81\x8dw;\xc0\xd6\xd0\xd7HN~q\xf1\xf8w.\x8c\xbf>\xbe/)\xe2\xfbͣqz\a\xfb\xc4\xd17\xf7\xc4>\x05E7T\x19g\xa2\xf1n\xd3PD\x8d\x11w\x1b\x00t\xceG\x141\xcbO\x00\xe5]\f\xdeZ\n\xdb\x03\xb9\xe21\x95T&c5\x85|\xc2p\xfe\xf1\xfb\xe2\xc7\xe2\xfb\r\x80\n\x94\xb7\x7f6\rqĦ݁K\xd6n\x00\x1c6\xb4\x83\xd4Z\x8f\x9a\v9\xb0\xf1G\n\x85r\xac\xdb\xe2\xd8<a\xa0B\xf9f\xc3-)9\xfc\x10|jwpA\xb33\xda{\xdaE\xf9%\xdb\xcf\x02k8\xfek&\xfc\xb7\xe1\x98\x17Z\x9b\x02\xdaї,c\xe3\x0e\xc9b\x18\xa4\x1b\x00V\xbe\xa5\x1d\xdcaCܢ\"\xbd\x018\xa25:\a\xd8\x1d\xea[r\xff\xf8t\xfb\xcb\x0f\x0f\xaa\xa6&C(bM\xac\x82i\xb3^\x7fz/+\t\x10\x8ed)\xf8mk\xd3\xc18(Q=\xa6\xb6\xdf\xd9\x06\xdfR\x88f\x88J\x9eY\xa2G\xd9\xea\x8c+q\xa2\xd3\x01-\xa9%\x86X\x13\x1c;\x19i\xe0\xec \xf8\nbm\x18\x02\xb5\x81\x98\\\x97l\x11\xa3\x03_\xfe\x87T,\xe0\x81\x82l\x04\xae}\xb2Zj\xe0H!B \xe5\x0f\xce\xfcw\xb4\xc6\x10}>\xc6b$\x8e`\\\xa4\xe0\xd0\nL\x89\xde\x01:\r\r>C \xb1\v\xc9\xcd,d\x15.\xe0\xa3\x0f\x04\xc6U~\au\x8c-ﮯ\x0f&\x0e%\xac|\xd3$g\xe2\xf3u.DS\xa6\xe8\x03_k:\x92\xbdfs\xd8bP\xb5\x89\xa4b\nt\x8d\xad\xd9fg\x9d\x04\xc5E\xa3\xff\x12\xfaz\xe7\xab\x19t\xf1Y\x12\xcb1\x18w\x18Ź~^\xc4W\n\t\f\x03\xf6ۺ\x10'\x18E$H\xdc\xff\xf4\xf0\x19\x86C;\xa8;T'U\x9e\x00\x16p\x8c\xab(t\x9aU\xf0MƓ\x9cn\xbdq1\xffP\u0590\x8b\xc0\xa9lL\x94\xcc\xfd\x9e\x88\xa3`_\xc0>7,\x94\x04\xa9\xd5\x18I\x17p\xeb`\x8f\r\xd9=2\xfd\xdf\xe1\x15$y+н\x0e\xf0\x9cg\x86?\xb2\x7f\xd7#4\x8a\a\x028\x9b\x89\x87\x96\x94$\"#\x93)m\x82[6\xce\xf6\x9d\xeb%y\xba\x86\xbb\xa7ֳ\x89></WW\xe7}X)C\x1b\xfc\xd1h\xe2\xde\f\x84iI\xca\x18*\x1fz\x0e)\xe0\v\x93\u0382&\xd9hZK\xa7\x9b\x8a\xd5\xe1g\xa1\x9b\xbc\x9ex\xf5u\xa7G\xddܹAw\xa0E\xd3P\xfeO\xef\xca\x132(\xb4Vj\xe7sM\xc0\xb9\xf9\xaf\xb8S4\fi\b\xe2\xc1a˵\x8f\xa3ݕ\v\x95\x0f\r\xc6\xccش\x95\xddo\r\x8d{÷7\x17\xa3\x1aο\xbd\x19\n\xc0h\xa9\xc5\xcaP\xc8(\x8bh\xb0%\x94&\xbf\x8fަ\x86\x8aou\xe5\x9e*\n\xe4\x14\xbdɣQ{p\xcc\r\x97F\xe6\xc0\xd1'\x11\xf7\x9e\n\xb0\xb9J\x86\x86\xee\xd0\xef0\x143\x83\xed\xf1\xfe\xb9\x1e$\xfb{\x91\xc1m\x1c\xb3\x13}\xdf\xff9\xb1\xbd]\x8e\x18\x13\x83q\v\\ތDge\x8fN\x91\xbd\b\u0097\x99\"\x18\xa7\x8d\x92\xdb`\bK\xae\b\x95\x8d\x80w\a/<\xd9w\xc7\xcaf\xe7H\xe9\xbd%t\x8b\xb5N\xff\x9e\xe2+\xad\xfae\xd2;\xefG\x10\x13\x80P\xa1\xb1\xa4\a?z \x85\x9c#x\xa7\x16 \xe6\x85-\x86\x86\xde\xec\xf0yN\xcb\xc9\xd8m.\xba\xfe\xd0gl`\xb7\x102\xf1wR\xb9\xa2{\xbd\xe2\r$\xa7|\xd3ZZ\x8eb\x97\xc0۟\xea\x9f\xd2\x06\xba\x01\x97\xcc\x1a\xdd\x16!\x8ei\xf7H\x1b\xddf\xd2@Gr\xe0\xdd\x12u~\x8dl\xce\xf8\xc3\xdf\xc872xbii\a1\xa47\x93Q\x0f\xbbP\xe8\xcfUu\x19\xb3\x85\xea\x02.!W_U\x12wWu\x12Ӫ\xee\xbaJ\xf5#\xa6\xfd\xb8\xe5Kz\x06\xfa\xdaz'\xe4\x86v\xb4Ր\xaa\xd1\x19n\x8a\x17\x800.\xfe\xf0\xd7\xd5Z\x17\xa7Lf\a\n\x8b\xb5\x86\x98\xf1p\x99\xe0>v:B48l\x00,}\x8a\xb3&\xb9\xe2\xbeF\x8b\xb7b\xec\xe8k\xccя\xb9\xbd\xe8\xc5݉\xfa0\x9a\x964\x16g'\x8f5Ʊ\xf9\xf3\x9a\x9c\xf5R\x0e`\x7f\xdf_\xd2\xd1Cel\xa4\x00\xcb\xd8F\xfax\xaa\x8d\xaaA\xf9\x862\xa5\x96T\xf9\xb00.>\x14\x7fJ\x85\xb65\xf2\xe5\xb4}\x12\x8ds<BõxJ$\xf2\x90K\xcd\xda\xf0\x16\xee\xe8\xe9Dv\xeb>\x05\x7f\b\xc4\xeb\xa6\xdc\x0e\x9d\x9bߗ\xe6϶?\xf4\xa7\x10\xfc\xb2\x12em/,\x9a\xda\x7f\xe6\xf4\x9c\xae\xe6\x1b\xe4Ņ5Bb𬥗1\r^\x11\xcb[\xe0\x9dח\xc1\x15\u07ba\xc1\x88\x1f\xd1\xe1\x81\x028\xaf\xa5\n1B\x8d\f\xadQ\x8f\xb9\xc7g0\xe7\u009bN\x10\xea3\fO\xc6\xda\xd9\xe4\x0e\xc8\xc0\xde;\xf9w\xb6\xd5\xccM\xae\xed\xdcvɜ{\xa3\x84C\xdcU\x1c\xf4\xe6.\xb0o\b\x02!{\a&\x8e\x0eL\xf6\xcbg@\xe7c\xddGU|\x03z\xb9\x16.\xe26\x14\f\xd4\xde\x0e\x97\x8a\x8fh\xc1\xa5\xa6\x94ƫ\xa0|\x96\xc9a1\xb9\xe5\x01j^\xc63m\x9c\xfc\x8e\xc4=\xac\n]F\xb5\xefjm\xb8\xb5\xf8<\xba\x98_\x86\xa4+\x8dw3&\xeb\x87a\xb9K\xf3R\x01?\x9fN\x02\xfd\x9dJ\xfa\x9d\xc0\x87\x96\xfd,\x96u\x14\x1c} =\x8c_'s\xff;\xc0J\xe8Fl\n*r\xe6\n\xbd\x97\xaevy\xf2\x197ޝ\x94\xea\xea>\xf8ۏg\xd6_\xbe\x13\xe4\xe9\x1c\xff\xf0\x1cϝ\xbb\xca\xe9ä\v\xe6<\x0e\xcbl\xca\v\xe0\xeb\xd0tdk\x18\xacd,\xd6\xe8\xe0\xc3\x100\x98\xce`\xb6\xd4'E\x00<\x1d\xd0\xfe8\x14\xb9>_D\xe2\x8f\xd8>;$\xca\xdf|S\xed}rq\xb7\xb9\x00\xfc\xfd\xa8\xb6\x18;&\xf0\xa7K\x893\xfd\xa0\xd62{\x8f\xc3oO\v\x1dҽL'\x921\xd9Q|\xf2\xe1\x11\fs\xea*W\xa4\xbf'J4{\xa5M,\x9f-\x02\xaa\xc7l\xd7i\xd0T\xa6\xc3\xc1\xb8C\xb1y\x11\xa8o\x98O8b\x98\xde5/\xa2\xf1\xb0P}mn͆\xdf\xf0\xba\xbb0\xfag\f\x9fg\x8aB\xc6\x0f\x13h\xfc2\xb5\x9d\x7f\xe4X\xe9\xf7\x1f\xfbvp|\x8f\xb6\xad\xf1\xfd$\xcb\xf5\xbb\xed\xbf\xc1Ζ\xa1{\xdd\xd73/\xa59e,\xec$\xd3{\v*Em$}\xb7\xfe\xe4\xfa\xddw\x8b\xaf\xaa\xf9\xa7\xf2N\xe7\xaf˼\x83_\x7f\xdb\f\xa4\xd2\x7f\xa2\xe4\x1d\xfc\xfa\xdb\xe6\x7f\x03\x00\xf0r\x1d\\\xc5\x16\x00\x00"),
 }
 
 var CRDs = crds()
@@ -0,0 +1,322 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package incremental
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/decorator"
+)
+
+const (
+	// The prefix of the snapshot ID under which the merged snapshot is staged during a consolidation
+	stagingSnapshotPrefix = "consolidate-"
+	// The prefix of the snapshot ID under which a snapshot is marked as being deleted, see Consolidate
+	deletionMarkerPrefix = "deleting-"
+)
+
+// layer is a snapshot of a chain in the repository.
+type layer struct {
+	pe astrolabe.ProtectedEntity
+	// metadata is nil for a full snapshot
+	metadata *LayerMetadata
+	// sourceMetadata is the metadata of the source ProtectedEntity
+	sourceMetadata []byte
+}
+
+func (this layer) isFull() bool {
+	return this.metadata == nil
+}
+
+// extents returns the extents whose data is stored in the snapshot, in the order in which it is stored.
+func (this layer) extents(capacity int64) []Extent {
+	if this.isFull() {
+		return []Extent{{Offset: 0, Length: capacity}}
+	}
+	return this.metadata.Extents
+}
+
+func getLayer(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) (layer, error) {
+	pe, err := petm.GetProtectedEntity(ctx, id)
+	if err != nil {
+		// The snapshot is being replaced by its staging snapshot, see replaceWithStagingSnapshot
+		stagingPE, stagingErr := petm.GetProtectedEntity(ctx, stagingID(id))
+		if stagingErr != nil {
+			return layer{}, err
+		}
+		pe = stagingPE
+	}
+	buf, err := readMetadata(ctx, pe)
+	if err != nil {
+		return layer{}, errors.Wrapf(err, "Failed to read the metadata of snapshot %s", id.String())
+	}
+	md, err := parseLayerMetadata(buf)
+	if err != nil {
+		return layer{}, err
+	}
+	if md == nil {
+		return layer{pe: pe, sourceMetadata: buf}, nil
+	}
+	return layer{pe: pe, metadata: md, sourceMetadata: md.Metadata}, nil
+}
+
+// getChain returns the chain of the snapshot, from the full snapshot to the snapshot itself.
+func getChain(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) ([]layer, error) {
+	var chain []layer
+	for len(chain) < maxChainLength {
+		l, err := getLayer(ctx, petm, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get snapshot %s of the chain", id.String())
+		}
+		chain = append([]layer{l}, chain...)
+		if l.isFull() {
+			return chain, nil
+		}
+		// The parent ID has been validated by parseLayerMetadata
+		id, _ = astrolabe.NewProtectedEntityIDFromString(l.metadata.ParentID)
+	}
+	return nil, errors.Errorf("The chain of snapshot %s is longer than %d", id.String(), maxChainLength)
+}
+
+// GetChainLength returns the number of snapshots in the chain of the snapshot in the repository, i.e. 1 for a full
+// snapshot.
+func GetChainLength(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) (int, error) {
+	chain, err := getChain(ctx, petm, id)
+	if err != nil {
+		return 0, err
+	}
+	return len(chain), nil
+}
+
+// GetChainIDs returns the snapshots of the chain of the snapshot in the repository, from the full snapshot to the
+// snapshot itself.
+func GetChainIDs(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) ([]astrolabe.ProtectedEntityID, error) {
+	chain, err := getChain(ctx, petm, id)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]astrolabe.ProtectedEntityID, len(chain))
+	for i, l := range chain {
+		ids[i] = l.pe.GetID()
+	}
+	return ids, nil
+}
+
+// GetProtectedEntity returns the snapshot from the repository. The data of an incremental snapshot is reconstructed
+// from its chain, so the data of the returned ProtectedEntity is always the whole disk image.
+func GetProtectedEntity(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntity, error) {
+	chain, err := getChain(ctx, petm, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 1 {
+		return chain[0].pe, nil
+	}
+	return newChainProtectedEntity(chain), nil
+}
+
+// Consolidate merges the snapshot into the incremental snapshots whose parent it is, so that it can be deleted from
+// the repository without breaking their chains. If the snapshot is a full one, its children become full snapshots.
+// Each child is merged into a staging snapshot first, which then replaces the child.
+//
+// The snapshot is marked as being deleted before its children are listed, so that an incremental snapshot uploaded
+// on top of it concurrently is either listed, or finds the mark once uploaded, see IsBaseAvailable. The mark is
+// removed by CompleteDeletion once the snapshot has been deleted.
+func Consolidate(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID, logger logrus.FieldLogger) error {
+	log := logger.WithField("peID", id.String())
+	snapshotIDs, err := listSnapshotsOfVolume(ctx, petm, id)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to list the snapshots of the volume of %s", id.String())
+	}
+	// Complete the consolidations which were interrupted, and drop the marks of the deletions which were
+	// interrupted after the snapshot had been deleted
+	stored := make(map[astrolabe.ProtectedEntityID]bool)
+	for _, snapshotID := range snapshotIDs {
+		stored[snapshotID] = true
+	}
+	for _, snapshotID := range snapshotIDs {
+		if isStagingID(snapshotID) {
+			if err := replaceWithStagingSnapshot(ctx, petm, primaryID(snapshotID), log); err != nil {
+				return err
+			}
+		}
+		if marked := markedID(snapshotID); isDeletionMarkerID(snapshotID) && !stored[marked] && !stored[stagingID(marked)] {
+			if err := deleteIfExists(ctx, petm, snapshotID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := petm.GetProtectedEntity(ctx, id); err != nil {
+		log.Infof("The snapshot was not found in the repository, nothing to consolidate")
+		return nil
+	}
+	parent, err := getLayer(ctx, petm, id)
+	if err != nil {
+		return err
+	}
+	if err := markDeleting(ctx, petm, id); err != nil {
+		return err
+	}
+	snapshotIDs, err = listSnapshotsOfVolume(ctx, petm, id)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to list the snapshots of the volume of %s", id.String())
+	}
+	for _, snapshotID := range snapshotIDs {
+		if isStagingID(snapshotID) || isDeletionMarkerID(snapshotID) || snapshotID == id {
+			continue
+		}
+		child, err := getLayer(ctx, petm, snapshotID)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to check if snapshot %s depends on snapshot %s", snapshotID.String(), id.String())
+		}
+		if child.isFull() || child.metadata.ParentID != id.String() {
+			continue
+		}
+		log.Infof("Merging the snapshot into its incremental snapshot %s", snapshotID.String())
+		merged := newMergedProtectedEntity(stagingID(snapshotID), parent, child)
+		if err := deleteIfExists(ctx, petm, merged.GetID()); err != nil {
+			return err
+		}
+		if _, err := petm.Copy(ctx, merged, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject); err != nil {
+			return errors.Wrapf(err, "Failed to merge snapshot %s into snapshot %s", id.String(), snapshotID.String())
+		}
+		if err := replaceWithStagingSnapshot(ctx, petm, snapshotID, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompleteDeletion removes the mark of the snapshot set by Consolidate, once the snapshot has been deleted from the
+// repository.
+func CompleteDeletion(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) error {
+	return deleteIfExists(ctx, petm, deletionMarkerID(id))
+}
+
+// IsBaseAvailable returns true if the snapshot is in the repository and is not being deleted, i.e. an incremental
+// snapshot can be based on it. Once an incremental snapshot has been uploaded, its parent is checked again: a parent
+// which is still available is either not being deleted yet, or it has been merged into the uploaded snapshot.
+func IsBaseAvailable(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) bool {
+	// The mark is checked first, as it is only removed once the snapshot is deleted
+	if _, err := petm.GetProtectedEntity(ctx, deletionMarkerID(id)); err == nil {
+		return false
+	}
+	_, err := getLayer(ctx, petm, id)
+	return err == nil
+}
+
+// markDeleting writes the empty snapshot which marks the snapshot as being deleted.
+func markDeleting(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) error {
+	marker := newDeletionMarker(deletionMarkerID(id))
+	if err := deleteIfExists(ctx, petm, marker.GetID()); err != nil {
+		return err
+	}
+	if _, err := petm.Copy(ctx, marker, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject); err != nil {
+		return errors.Wrapf(err, "Failed to mark snapshot %s as being deleted", id.String())
+	}
+	return nil
+}
+
+// replaceWithStagingSnapshot replaces the snapshot by its staging snapshot. The staging snapshot only becomes visible
+// once it is complete, so it is always safe to replace the snapshot by it. Until the replacement completes, the
+// staging snapshot is read in place of the snapshot, see getLayer.
+func replaceWithStagingSnapshot(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID, logger logrus.FieldLogger) error {
+	staging := stagingID(id)
+	stagingPE, err := petm.GetProtectedEntity(ctx, staging)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get the staging snapshot %s", staging.String())
+	}
+	logger.Infof("Replacing snapshot %s with the staging snapshot %s", id.String(), staging.String())
+	if err := deleteIfExists(ctx, petm, id); err != nil {
+		return err
+	}
+	if _, err := petm.Copy(ctx, newRenamedProtectedEntity(stagingPE, id), make(map[string]map[string]interface{}), astrolabe.AllocateNewObject); err != nil {
+		return errors.Wrapf(err, "Failed to copy the staging snapshot %s to snapshot %s", staging.String(), id.String())
+	}
+	return deleteIfExists(ctx, petm, staging)
+}
+
+func deleteIfExists(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) error {
+	pe, err := petm.GetProtectedEntity(ctx, id)
+	if err != nil {
+		return nil
+	}
+	if _, err := pe.DeleteSnapshot(ctx, id.GetSnapshotID(), make(map[string]map[string]interface{})); err != nil {
+		return errors.Wrapf(err, "Failed to delete snapshot %s", id.String())
+	}
+	return nil
+}
+
+// listSnapshotsOfVolume returns the snapshots in the repository of the same ProtectedEntity as the given snapshot.
+func listSnapshotsOfVolume(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) ([]astrolabe.ProtectedEntityID, error) {
+	candidates, err := decorator.GetProtectedEntitiesByIDPrefix(ctx, petm, id.GetPeType()+":"+id.GetID()+":")
+	if err != nil {
+		return nil, err
+	}
+	var snapshotIDs []astrolabe.ProtectedEntityID
+	for _, candidate := range candidates {
+		if candidate.GetPeType() == id.GetPeType() && candidate.GetID() == id.GetID() && candidate.HasSnapshot() {
+			snapshotIDs = append(snapshotIDs, candidate)
+		}
+	}
+	return snapshotIDs, nil
+}
+
+func stagingID(id astrolabe.ProtectedEntityID) astrolabe.ProtectedEntityID {
+	return id.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID(stagingSnapshotPrefix + id.GetSnapshotID().GetID()))
+}
+
+func isStagingID(id astrolabe.ProtectedEntityID) bool {
+	return strings.HasPrefix(id.GetSnapshotID().GetID(), stagingSnapshotPrefix)
+}
+
+// StagedSnapshotID returns the snapshot replaced by the staging snapshot of an interrupted consolidation, or false if
+// the snapshot is not a staging snapshot. Until the consolidation completes, the staging snapshot is read in place of
+// the snapshot it replaces.
+func StagedSnapshotID(id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntityID, bool) {
+	if !isStagingID(id) {
+		return astrolabe.ProtectedEntityID{}, false
+	}
+	return primaryID(id), true
+}
+
+func deletionMarkerID(id astrolabe.ProtectedEntityID) astrolabe.ProtectedEntityID {
+	return id.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID(deletionMarkerPrefix + id.GetSnapshotID().GetID()))
+}
+
+func isDeletionMarkerID(id astrolabe.ProtectedEntityID) bool {
+	return strings.HasPrefix(id.GetSnapshotID().GetID(), deletionMarkerPrefix)
+}
+
+func markedID(marker astrolabe.ProtectedEntityID) astrolabe.ProtectedEntityID {
+	return marker.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID(strings.TrimPrefix(marker.GetSnapshotID().GetID(), deletionMarkerPrefix)))
+}
+
+// IsDeletionMarker returns true if the snapshot marks another snapshot as being deleted, see Consolidate. The mark
+// holds no data and is not referenced by any backup.
+func IsDeletionMarker(id astrolabe.ProtectedEntityID) bool {
+	return isDeletionMarkerID(id)
+}
+
+func primaryID(staging astrolabe.ProtectedEntityID) astrolabe.ProtectedEntityID {
+	return staging.IDWithSnapshot(astrolabe.NewProtectedEntitySnapshotID(strings.TrimPrefix(staging.GetSnapshotID().GetID(), stagingSnapshotPrefix)))
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package incremental implements incremental snapshots in the remote repository.
+//
+// A full snapshot is stored as is, i.e. the data is the whole disk image and the metadata is the metadata of the
+// source ProtectedEntity. An incremental snapshot only stores the extents of the disk which changed since its parent
+// snapshot. Its data is the concatenation of the changed extents in the order of their offsets, and its metadata is
+// a LayerMetadata which records the parent, the extents and the metadata of the source ProtectedEntity. The parent
+// of an incremental snapshot is either a full snapshot or another incremental snapshot of the same volume, so the
+// snapshots of a volume form a chain which ends with a full snapshot.
+package incremental
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+)
+
+const (
+	// layerMetadataFormat identifies the metadata of incremental snapshots
+	layerMetadataFormat = "velero-plugin-for-vsphere/incremental/v1"
+	// maxChainLength guards against loops in corrupted chains
+	maxChainLength = 1024
+)
+
+// Extent is a range of a disk in bytes.
+type Extent struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+func (this Extent) end() int64 {
+	return this.Offset + this.Length
+}
+
+// LayerMetadata is the metadata of an incremental snapshot in the repository.
+type LayerMetadata struct {
+	Format string `json:"format"`
+	// The PEID of the parent snapshot in the repository
+	ParentID string `json:"parentID"`
+	// The capacity of the disk in bytes when the snapshot was taken
+	Capacity int64 `json:"capacity"`
+	// The changed extents since the parent snapshot, the data of which is stored in this order
+	Extents []Extent `json:"extents"`
+	// The metadata of the source ProtectedEntity, which is handed out on restore
+	Metadata []byte `json:"metadata"`
+}
+
+func newLayerMetadata(parentID astrolabe.ProtectedEntityID, capacity int64, extents []Extent) LayerMetadata {
+	return LayerMetadata{
+		Format:   layerMetadataFormat,
+		ParentID: parentID.String(),
+		Capacity: capacity,
+		Extents:  extents,
+	}
+}
+
+// parseLayerMetadata returns the LayerMetadata of an incremental snapshot, or nil if the metadata is the one of a
+// full snapshot.
+func parseLayerMetadata(buf []byte) (*LayerMetadata, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(buf), []byte("{")) {
+		return nil, nil
+	}
+	var md LayerMetadata
+	if err := json.Unmarshal(buf, &md); err != nil || md.Format != layerMetadataFormat {
+		// Not written by this package, so it is the metadata of the source ProtectedEntity
+		return nil, nil
+	}
+	if _, err := astrolabe.NewProtectedEntityIDFromString(md.ParentID); err != nil {
+		return nil, errors.Wrapf(err, "Invalid parent %s in the incremental snapshot metadata", md.ParentID)
+	}
+	return &md, nil
+}
+
+func readMetadata(ctx context.Context, pe astrolabe.ProtectedEntity) ([]byte, error) {
+	metadataReader, err := pe.GetMetadataReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if metadataReader == nil {
+		return nil, nil
+	}
+	defer metadataReader.Close()
+	return ioutil.ReadAll(metadataReader)
+}
+
+// NormalizeExtents sorts the extents, merges the overlapping and adjacent ones and clips them to the capacity.
+func NormalizeExtents(extents []Extent, capacity int64) []Extent {
+	sorted := make([]Extent, 0, len(extents))
+	for _, extent := range extents {
+		if extent.Offset < 0 {
+			extent.Length += extent.Offset
+			extent.Offset = 0
+		}
+		if extent.end() > capacity {
+			extent.Length = capacity - extent.Offset
+		}
+		if extent.Length > 0 {
+			sorted = append(sorted, extent)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Offset < sorted[j].Offset
+	})
+	var normalized []Extent
+	for _, extent := range sorted {
+		last := len(normalized) - 1
+		if last >= 0 && extent.Offset <= normalized[last].end() {
+			if extent.end() > normalized[last].end() {
+				normalized[last].Length = extent.end() - normalized[last].Offset
+			}
+			continue
+		}
+		normalized = append(normalized, extent)
+	}
+	return normalized
+}
+
+// totalLength returns the number of bytes covered by the extents.
+func totalLength(extents []Extent) int64 {
+	var total int64
+	for _, extent := range extents {
+		total += extent.Length
+	}
+	return total
+}
+
+// piece is a range of the disk whose data comes from a single layer of the chain.
+type piece struct {
+	Extent
+	layer int
+}
+
+// overlay returns the pieces after the extents of layer are put on top of the given pieces. Both the pieces and the
+// extents have to be sorted and non-overlapping, and so are the returned pieces.
+func overlay(pieces []piece, extents []Extent, layer int) []piece {
+	var result []piece
+	for _, extent := range extents {
+		result = append(result, piece{Extent: extent, layer: layer})
+	}
+	for _, below := range pieces {
+		start, end := below.Offset, below.end()
+		// The first extent which ends after the start of the piece
+		next := sort.Search(len(extents), func(i int) bool {
+			return extents[i].end() > start
+		})
+		for start < end {
+			if next < len(extents) && extents[next].Offset < end {
+				if extents[next].Offset > start {
+					result = append(result, piece{Extent: Extent{Offset: start, Length: extents[next].Offset - start}, layer: below.layer})
+				}
+				start = extents[next].end()
+				next++
+			} else {
+				result = append(result, piece{Extent: Extent{Offset: start, Length: end - start}, layer: below.layer})
+				break
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Offset < result[j].Offset
+	})
+	return result
+}
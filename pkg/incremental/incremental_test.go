@@ -0,0 +1,297 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package incremental
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/fsrepository"
+)
+
+const (
+	testVolumeID = "ivd:e1c3cb20-db88-4c1c-9f02-5f5347e435d5"
+	testCapacity = 64
+)
+
+type fakeProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	info     astrolabe.ProtectedEntityInfo
+	data     []byte
+	metadata []byte
+}
+
+func (this fakeProtectedEntity) GetID() astrolabe.ProtectedEntityID {
+	return this.info.GetID()
+}
+
+func (this fakeProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return this.info, nil
+}
+
+func (this fakeProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.data)), nil
+}
+
+func (this fakeProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.metadata)), nil
+}
+
+func newFakeProtectedEntity(t *testing.T, snapshotID string, data []byte) fakeProtectedEntity {
+	peID, err := astrolabe.NewProtectedEntityIDFromString(testVolumeID + ":" + snapshotID)
+	require.NoError(t, err)
+	transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("fake", map[string]string{})}
+	return fakeProtectedEntity{
+		info:     astrolabe.NewProtectedEntityInfo(peID, "fake-pe", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}),
+		data:     data,
+		metadata: []byte("metadata of " + snapshotID),
+	}
+}
+
+// writeExtents returns a copy of the disk image with the extents filled with the fill byte.
+func writeExtents(image []byte, extents []Extent, fill byte) []byte {
+	result := append([]byte(nil), image...)
+	for _, extent := range extents {
+		for i := extent.Offset; i < extent.end(); i++ {
+			result[i] = fill
+		}
+	}
+	return result
+}
+
+func readAll(t *testing.T, pe astrolabe.ProtectedEntity) ([]byte, []byte) {
+	ctx := context.Background()
+	dataReader, err := pe.GetDataReader(ctx)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(dataReader)
+	require.NoError(t, dataReader.Close())
+	require.NoError(t, err)
+	metadata, err := readMetadata(ctx, pe)
+	require.NoError(t, err)
+	return data, metadata
+}
+
+func deleteFromRepo(t *testing.T, petm astrolabe.ProtectedEntityTypeManager, id astrolabe.ProtectedEntityID) {
+	ctx := context.Background()
+	require.NoError(t, Consolidate(ctx, petm, id, logrus.New()))
+	require.NoError(t, deleteIfExists(ctx, petm, id))
+	require.NoError(t, CompleteDeletion(ctx, petm, id))
+}
+
+func TestNormalizeExtents(t *testing.T) {
+	tests := []struct {
+		name     string
+		extents  []Extent
+		expected []Extent
+	}{
+		{
+			name:     "Empty",
+			extents:  nil,
+			expected: nil,
+		},
+		{
+			name:     "Sorted and merged",
+			extents:  []Extent{{Offset: 40, Length: 8}, {Offset: 0, Length: 4}, {Offset: 4, Length: 4}, {Offset: 42, Length: 2}},
+			expected: []Extent{{Offset: 0, Length: 8}, {Offset: 40, Length: 8}},
+		},
+		{
+			name:     "Overlapping",
+			extents:  []Extent{{Offset: 10, Length: 10}, {Offset: 15, Length: 10}},
+			expected: []Extent{{Offset: 10, Length: 15}},
+		},
+		{
+			name:     "Clipped to the capacity",
+			extents:  []Extent{{Offset: -4, Length: 8}, {Offset: 60, Length: 10}, {Offset: 70, Length: 4}, {Offset: 20, Length: 0}},
+			expected: []Extent{{Offset: 0, Length: 4}, {Offset: 60, Length: 4}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, NormalizeExtents(test.extents, testCapacity))
+		})
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	pieces := overlay(nil, []Extent{{Offset: 0, Length: testCapacity}}, 0)
+	pieces = overlay(pieces, []Extent{{Offset: 8, Length: 8}, {Offset: 32, Length: 8}}, 1)
+	pieces = overlay(pieces, []Extent{{Offset: 12, Length: 24}}, 2)
+	assert.Equal(t, []piece{
+		{Extent: Extent{Offset: 0, Length: 8}, layer: 0},
+		{Extent: Extent{Offset: 8, Length: 4}, layer: 1},
+		{Extent: Extent{Offset: 12, Length: 24}, layer: 2},
+		{Extent: Extent{Offset: 36, Length: 4}, layer: 1},
+		{Extent: Extent{Offset: 40, Length: 24}, layer: 0},
+	}, pieces)
+}
+
+func TestIncrementalProtectedEntity(t *testing.T) {
+	image := writeExtents(make([]byte, testCapacity), []Extent{{Offset: 4, Length: 4}, {Offset: 50, Length: 6}}, 'a')
+	sourcePE := newFakeProtectedEntity(t, "snapshot-2", image)
+	parentID, err := astrolabe.NewProtectedEntityIDFromString(testVolumeID + ":snapshot-1")
+	require.NoError(t, err)
+
+	pe := NewIncrementalProtectedEntity(sourcePE, parentID, testCapacity, []Extent{{Offset: 50, Length: 6}, {Offset: 4, Length: 4}})
+	assert.Equal(t, int64(10), pe.(incrementalProtectedEntity).GetDataSize())
+	data, buf := readAll(t, pe)
+	assert.Equal(t, []byte("aaaaaaaaaa"), data)
+
+	md, err := parseLayerMetadata(buf)
+	require.NoError(t, err)
+	require.NotNil(t, md)
+	assert.Equal(t, parentID.String(), md.ParentID)
+	assert.Equal(t, int64(testCapacity), md.Capacity)
+	assert.Equal(t, []Extent{{Offset: 4, Length: 4}, {Offset: 50, Length: 6}}, md.Extents)
+	assert.Equal(t, sourcePE.metadata, md.Metadata)
+
+	// The metadata of a full snapshot is not mistaken for the one of an incremental snapshot
+	for _, buf := range [][]byte{sourcePE.metadata, []byte(`{"format":"other"}`), nil} {
+		md, err := parseLayerMetadata(buf)
+		assert.NoError(t, err)
+		assert.Nil(t, md)
+	}
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "incremental")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	petm, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+
+	// A full snapshot followed by two incremental snapshots
+	images := [][]byte{writeExtents(make([]byte, testCapacity), []Extent{{Offset: 0, Length: testCapacity}}, 'a')}
+	changes := [][]Extent{nil, {{Offset: 8, Length: 8}, {Offset: 32, Length: 8}}, {{Offset: 12, Length: 24}, {Offset: 60, Length: 4}}}
+	var sourcePEs []fakeProtectedEntity
+	for i := range changes {
+		if i > 0 {
+			images = append(images, writeExtents(images[i-1], changes[i], byte('a'+i)))
+		}
+		sourcePE := newFakeProtectedEntity(t, "snapshot-"+string(rune('1'+i)), images[i])
+		sourcePEs = append(sourcePEs, sourcePE)
+		var pe astrolabe.ProtectedEntity = sourcePE
+		if i > 0 {
+			pe = NewIncrementalProtectedEntity(sourcePE, sourcePEs[i-1].GetID(), testCapacity, changes[i])
+		}
+		_, err = petm.Copy(ctx, pe, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+		require.NoError(t, err)
+	}
+
+	verify := func(i int, expectedChainLength int) {
+		id := sourcePEs[i].GetID()
+		chainLength, err := GetChainLength(ctx, petm, id)
+		require.NoError(t, err)
+		assert.Equal(t, expectedChainLength, chainLength)
+		pe, err := GetProtectedEntity(ctx, petm, id)
+		require.NoError(t, err)
+		data, metadata := readAll(t, pe)
+		assert.Equal(t, images[i], data)
+		assert.Equal(t, sourcePEs[i].metadata, metadata)
+	}
+	verify(0, 1)
+	verify(1, 2)
+	verify(2, 3)
+
+	// Deleting the middle snapshot merges it into its child
+	deleteFromRepo(t, petm, sourcePEs[1].GetID())
+	verify(0, 1)
+	verify(2, 2)
+
+	// Deleting the full snapshot turns its child into a full snapshot
+	deleteFromRepo(t, petm, sourcePEs[0].GetID())
+	verify(2, 1)
+
+	peIDs, err := petm.GetProtectedEntities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []astrolabe.ProtectedEntityID{sourcePEs[2].GetID()}, peIDs)
+}
+
+func TestConsolidateCompletesInterruptedReplacement(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "incremental")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	petm, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+
+	fullImage := writeExtents(make([]byte, testCapacity), []Extent{{Offset: 0, Length: testCapacity}}, 'a')
+	image := writeExtents(fullImage, []Extent{{Offset: 16, Length: 16}}, 'b')
+	parentPE := newFakeProtectedEntity(t, "snapshot-1", fullImage)
+	childPE := newFakeProtectedEntity(t, "snapshot-2", image)
+	_, err = petm.Copy(ctx, parentPE, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+
+	// The child was deleted after its staging snapshot had been written, but before it was copied back
+	_, err = petm.Copy(ctx, newRenamedProtectedEntity(childPE, stagingID(childPE.GetID())), make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+
+	pe, err := GetProtectedEntity(ctx, petm, childPE.GetID())
+	require.NoError(t, err)
+	data, _ := readAll(t, pe)
+	assert.Equal(t, image, data)
+
+	deleteFromRepo(t, petm, parentPE.GetID())
+	peIDs, err := petm.GetProtectedEntities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []astrolabe.ProtectedEntityID{childPE.GetID()}, peIDs)
+	pe, err = GetProtectedEntity(ctx, petm, childPE.GetID())
+	require.NoError(t, err)
+	data, metadata := readAll(t, pe)
+	assert.Equal(t, image, data)
+	assert.Equal(t, childPE.metadata, metadata)
+}
+
+func TestConsolidateMarksSnapshot(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "incremental")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	petm, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+
+	fullImage := writeExtents(make([]byte, testCapacity), []Extent{{Offset: 0, Length: testCapacity}}, 'a')
+	parentPE := newFakeProtectedEntity(t, "snapshot-1", fullImage)
+	_, err = petm.Copy(ctx, parentPE, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+	require.NoError(t, err)
+	assert.True(t, IsBaseAvailable(ctx, petm, parentPE.GetID()))
+
+	// The snapshot is not available as a base from the start of its deletion
+	require.NoError(t, Consolidate(ctx, petm, parentPE.GetID(), logrus.New()))
+	assert.False(t, IsBaseAvailable(ctx, petm, parentPE.GetID()))
+	peIDs, err := petm.GetProtectedEntities(ctx)
+	require.NoError(t, err)
+	assert.Len(t, peIDs, 2)
+	for _, peID := range peIDs {
+		assert.Equal(t, peID != parentPE.GetID(), IsDeletionMarker(peID))
+	}
+
+	require.NoError(t, deleteIfExists(ctx, petm, parentPE.GetID()))
+	require.NoError(t, CompleteDeletion(ctx, petm, parentPE.GetID()))
+	assert.False(t, IsBaseAvailable(ctx, petm, parentPE.GetID()))
+	peIDs, err = petm.GetProtectedEntities(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, peIDs)
+}
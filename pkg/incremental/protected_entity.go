@@ -0,0 +1,412 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package incremental
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+)
+
+// incrementalProtectedEntity wraps the source ProtectedEntity of an upload so that the repository copies only the
+// changed extents, along with the LayerMetadata.
+type incrementalProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	metadata LayerMetadata
+}
+
+// NewIncrementalProtectedEntity returns the ProtectedEntity to copy to the repository for an incremental snapshot
+// of pe on top of the parent snapshot in the repository. The changed extents are those since the parent snapshot.
+func NewIncrementalProtectedEntity(pe astrolabe.ProtectedEntity, parentID astrolabe.ProtectedEntityID, capacity int64,
+	changedExtents []Extent) astrolabe.ProtectedEntity {
+	return incrementalProtectedEntity{
+		ProtectedEntity: pe,
+		metadata:        newLayerMetadata(parentID, capacity, NormalizeExtents(changedExtents, capacity)),
+	}
+}
+
+func (this incrementalProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	dataReader, err := this.ProtectedEntity.GetDataReader(ctx)
+	if err != nil || dataReader == nil {
+		return dataReader, err
+	}
+	return &extentReader{
+		reader:  dataReader,
+		extents: this.metadata.Extents,
+	}, nil
+}
+
+func (this incrementalProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	sourceMetadata, err := readMetadata(ctx, this.ProtectedEntity)
+	if err != nil {
+		return nil, err
+	}
+	md := this.metadata
+	md.Metadata = sourceMetadata
+	buf, err := json.Marshal(md)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(buf)), nil
+}
+
+// GetParentID returns the parent snapshot of the ProtectedEntity returned by NewIncrementalProtectedEntity, or false if
+// the ProtectedEntity is not an incremental snapshot.
+func GetParentID(pe astrolabe.ProtectedEntity) (astrolabe.ProtectedEntityID, bool) {
+	incrementalPE, ok := pe.(incrementalProtectedEntity)
+	if !ok {
+		return astrolabe.ProtectedEntityID{}, false
+	}
+	parentID, err := astrolabe.NewProtectedEntityIDFromString(incrementalPE.metadata.ParentID)
+	if err != nil {
+		return astrolabe.ProtectedEntityID{}, false
+	}
+	return parentID, true
+}
+
+// GetDataSize returns the number of bytes of the data stream, i.e. of the changed extents.
+func (this incrementalProtectedEntity) GetDataSize() int64 {
+	return totalLength(this.metadata.Extents)
+}
+
+// extentReader reads the extents from the whole disk image, with random access if the image supports it.
+type extentReader struct {
+	reader  io.ReadCloser
+	extents []Extent
+	// The extent being read and the number of bytes read from it
+	current int
+	done    int64
+	// The offset of the reader, if it is read sequentially
+	pos int64
+}
+
+func (this *extentReader) Read(p []byte) (int, error) {
+	for this.current < len(this.extents) && this.done == this.extents[this.current].Length {
+		this.current++
+		this.done = 0
+	}
+	if this.current == len(this.extents) {
+		return 0, io.EOF
+	}
+	extent := this.extents[this.current]
+	if remaining := extent.Length - this.done; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	offset := extent.Offset + this.done
+	var n int
+	var err error
+	if readerAt, ok := this.reader.(io.ReaderAt); ok {
+		n, err = readerAt.ReadAt(p, offset)
+		if err == io.EOF && n == len(p) {
+			err = nil
+		}
+	} else {
+		if skip := offset - this.pos; skip > 0 {
+			skipped, err := io.CopyN(ioutil.Discard, this.reader, skip)
+			this.pos += skipped
+			if err != nil {
+				return 0, err
+			}
+		}
+		n, err = this.reader.Read(p)
+		this.pos += int64(n)
+	}
+	this.done += int64(n)
+	if err == io.EOF {
+		return n, errors.Wrapf(io.ErrUnexpectedEOF, "The disk image ended in the changed extent at offset %d", extent.Offset)
+	}
+	return n, err
+}
+
+func (this *extentReader) Close() error {
+	return this.reader.Close()
+}
+
+// chainProtectedEntity is an incremental snapshot in the repository whose data is reconstructed from its chain.
+type chainProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	chain []layer
+}
+
+func newChainProtectedEntity(chain []layer) astrolabe.ProtectedEntity {
+	return chainProtectedEntity{
+		ProtectedEntity: chain[len(chain)-1].pe,
+		chain:           chain,
+	}
+}
+
+func (this chainProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return newChainReader(ctx, this.chain, this.chain[len(this.chain)-1].metadata.Capacity), nil
+}
+
+func (this chainProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.chain[len(this.chain)-1].sourceMetadata)), nil
+}
+
+// mergedProtectedEntity is the snapshot which results from merging a parent snapshot into its child.
+type mergedProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	id     astrolabe.ProtectedEntityID
+	parent layer
+	child  layer
+}
+
+func newMergedProtectedEntity(id astrolabe.ProtectedEntityID, parent layer, child layer) astrolabe.ProtectedEntity {
+	return mergedProtectedEntity{
+		ProtectedEntity: child.pe,
+		id:              id,
+		parent:          parent,
+		child:           child,
+	}
+}
+
+func (this mergedProtectedEntity) GetID() astrolabe.ProtectedEntityID {
+	return this.id
+}
+
+func (this mergedProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return renamedInfo(ctx, this.ProtectedEntity, this.id)
+}
+
+func (this mergedProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	// If the parent is a full snapshot, the reader covers the whole disk. Otherwise it covers the extents of both
+	// the parent and the child, which are the extents of the merged snapshot.
+	return newChainReader(ctx, []layer{this.parent, this.child}, this.child.metadata.Capacity), nil
+}
+
+func (this mergedProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	if this.parent.isFull() {
+		return ioutil.NopCloser(bytes.NewReader(this.child.sourceMetadata)), nil
+	}
+	md := *this.child.metadata
+	md.ParentID = this.parent.metadata.ParentID
+	md.Extents = NormalizeExtents(append(append([]Extent{}, this.parent.metadata.Extents...), this.child.metadata.Extents...), md.Capacity)
+	buf, err := json.Marshal(md)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(buf)), nil
+}
+
+// renamedProtectedEntity copies a snapshot of the repository under another ID.
+type renamedProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	id astrolabe.ProtectedEntityID
+}
+
+func newRenamedProtectedEntity(pe astrolabe.ProtectedEntity, id astrolabe.ProtectedEntityID) astrolabe.ProtectedEntity {
+	return renamedProtectedEntity{
+		ProtectedEntity: pe,
+		id:              id,
+	}
+}
+
+func (this renamedProtectedEntity) GetID() astrolabe.ProtectedEntityID {
+	return this.id
+}
+
+func (this renamedProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return renamedInfo(ctx, this.ProtectedEntity, this.id)
+}
+
+func renamedInfo(ctx context.Context, pe astrolabe.ProtectedEntity, id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntityInfo, error) {
+	info, err := pe.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return astrolabe.NewProtectedEntityInfo(id, info.GetName(), info.GetDataTransports(), info.GetMetadataTransports(),
+		info.GetCombinedTransports(), info.GetComponentIDs()), nil
+}
+
+// deletionMarker is the empty snapshot which marks a snapshot of the repository as being deleted.
+type deletionMarker struct {
+	astrolabe.ProtectedEntity
+	id astrolabe.ProtectedEntityID
+}
+
+func newDeletionMarker(id astrolabe.ProtectedEntityID) astrolabe.ProtectedEntity {
+	return deletionMarker{id: id}
+}
+
+func (this deletionMarker) GetID() astrolabe.ProtectedEntityID {
+	return this.id
+}
+
+func (this deletionMarker) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return astrolabe.NewProtectedEntityInfo(this.id, this.id.String(), []astrolabe.DataTransport{}, []astrolabe.DataTransport{},
+		[]astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}), nil
+}
+
+func (this deletionMarker) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+func (this deletionMarker) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+// layerReader reads the data of a snapshot of a chain by disk offset. The offsets have to be read in increasing order.
+type layerReader struct {
+	pe   astrolabe.ProtectedEntity
+	full bool
+	// The extents whose data is stored in the snapshot, and the offset of the data of each extent in the data stream
+	extents []Extent
+	starts  []int64
+	// The extent which contains the next offset to read
+	current int
+	reader  io.ReadCloser
+	// The offset in the data stream
+	pos int64
+	eof bool
+}
+
+func newLayerReader(l layer, capacity int64) *layerReader {
+	extents := l.extents(capacity)
+	starts := make([]int64, len(extents))
+	var start int64
+	for i, extent := range extents {
+		starts[i] = start
+		start += extent.Length
+	}
+	return &layerReader{
+		pe:      l.pe,
+		full:    l.isFull(),
+		extents: extents,
+		starts:  starts,
+	}
+}
+
+func (this *layerReader) readAt(ctx context.Context, p []byte, offset int64) (int, error) {
+	for this.current < len(this.extents) && this.extents[this.current].end() <= offset {
+		this.current++
+	}
+	if this.current == len(this.extents) || this.extents[this.current].Offset > offset {
+		return 0, errors.Errorf("Offset %d is not stored in snapshot %s", offset, this.pe.GetID().String())
+	}
+	extent := this.extents[this.current]
+	if remaining := extent.end() - offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	streamOffset := this.starts[this.current] + offset - extent.Offset
+	if streamOffset < this.pos {
+		return 0, errors.Errorf("Offset %d of snapshot %s has already been read", offset, this.pe.GetID().String())
+	}
+	if this.reader == nil {
+		reader, err := this.pe.GetDataReader(ctx)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Failed to get the data reader of snapshot %s", this.pe.GetID().String())
+		}
+		if reader == nil {
+			return 0, errors.Errorf("Snapshot %s has no data", this.pe.GetID().String())
+		}
+		this.reader = reader
+	}
+	var n int
+	var err error
+	if !this.eof {
+		if skip := streamOffset - this.pos; skip > 0 {
+			var skipped int64
+			skipped, err = io.CopyN(ioutil.Discard, this.reader, skip)
+			this.pos += skipped
+		}
+		if err == nil {
+			n, err = io.ReadFull(this.reader, p)
+			this.pos += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			this.eof = true
+		} else if err != nil {
+			return n, err
+		}
+	}
+	if this.eof {
+		if !this.full {
+			return n, errors.Wrapf(io.ErrUnexpectedEOF, "The data of snapshot %s ended at offset %d", this.pe.GetID().String(), this.pos)
+		}
+		// The disk was extended after the full snapshot was taken, and the extended part was never written
+		for i := n; i < len(p); i++ {
+			p[i] = 0
+		}
+		n = len(p)
+	}
+	return n, nil
+}
+
+func (this *layerReader) close() error {
+	if this.reader == nil {
+		return nil
+	}
+	return this.reader.Close()
+}
+
+// chainReader reads the data of a chain. For each offset, the data is read from the newest snapshot which stores it,
+// and the data of all the offsets stored by any snapshot of the chain is concatenated in the order of the offsets.
+// All the snapshots are read sequentially, so the data stream of the repository does not need to support seeking.
+type chainReader struct {
+	ctx    context.Context
+	layers []*layerReader
+	pieces []piece
+	// The piece being read and the number of bytes read from it
+	current int
+	done    int64
+}
+
+func newChainReader(ctx context.Context, chain []layer, capacity int64) *chainReader {
+	var layers []*layerReader
+	var pieces []piece
+	for i, l := range chain {
+		layers = append(layers, newLayerReader(l, capacity))
+		pieces = overlay(pieces, l.extents(capacity), i)
+	}
+	return &chainReader{
+		ctx:    ctx,
+		layers: layers,
+		pieces: pieces,
+	}
+}
+
+func (this *chainReader) Read(p []byte) (int, error) {
+	for this.current < len(this.pieces) && this.done == this.pieces[this.current].Length {
+		this.current++
+		this.done = 0
+	}
+	if this.current == len(this.pieces) {
+		return 0, io.EOF
+	}
+	piece := this.pieces[this.current]
+	if remaining := piece.Length - this.done; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := this.layers[piece.layer].readAt(this.ctx, p, piece.Offset+this.done)
+	this.done += int64(n)
+	return n, err
+}
+
+func (this *chainReader) Close() error {
+	var closeErr error
+	for _, l := range this.layers {
+		if err := l.close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
@@ -19,6 +19,8 @@ package install
 import (
 	"strings"
 
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,6 +37,8 @@ type podTemplateConfig struct {
 	withSecret     bool
 	masterAffinity bool
 	hostNetwork    bool
+	repoConfig     map[string]string
+	args           []string
 }
 
 func WithImage(image string) podTemplateOption {
@@ -73,12 +77,63 @@ func WithMasterNodeAffinity(affinity bool) podTemplateOption {
 	}
 }
 
+// WithArgs appends the args to the default args of the server
+func WithArgs(args ...string) podTemplateOption {
+	return func(c *podTemplateConfig) {
+		c.args = append(c.args, args...)
+	}
+}
+
 func WithHostNetwork(hostNetwork bool) podTemplateOption {
 	return func(c *podTemplateConfig) {
 		c.hostNetwork = hostNetwork
 	}
 }
 
+// WithRepositoryConfig mounts the volume of the file system repository, if it is configured, into the pod
+func WithRepositoryConfig(repositoryConfig map[string]string) podTemplateOption {
+	return func(c *podTemplateConfig) {
+		c.repoConfig = repositoryConfig
+	}
+}
+
+// appendRepositoryVolume adds the NFS or host path volume of the file system repository to the pod spec.
+// If neither is configured, the volume is expected to be provided by other means.
+func appendRepositoryVolume(podSpec *corev1.PodSpec, repositoryConfig map[string]string) {
+	if strings.ToLower(repositoryConfig[constants.RepositoryConfigDriverKey]) != constants.RepositoryDriverFileSystem {
+		return
+	}
+
+	var volumeSource corev1.VolumeSource
+	if nfsServer, ok := repositoryConfig[constants.RepositoryConfigNFSServerKey]; ok && nfsServer != "" {
+		volumeSource.NFS = &corev1.NFSVolumeSource{
+			Server: nfsServer,
+			Path:   repositoryConfig[constants.RepositoryConfigNFSPathKey],
+		}
+	} else if hostPath, ok := repositoryConfig[constants.RepositoryConfigHostPathKey]; ok && hostPath != "" {
+		hostPathType := corev1.HostPathDirectoryOrCreate
+		volumeSource.HostPath = &corev1.HostPathVolumeSource{
+			Path: hostPath,
+			Type: &hostPathType,
+		}
+	} else {
+		return
+	}
+
+	mountPath, ok := repositoryConfig[constants.RepositoryConfigPathKey]
+	if !ok || mountPath == "" {
+		mountPath = constants.DefaultFileSystemRepositoryPath
+	}
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name:         "backup-repository",
+		VolumeSource: volumeSource,
+	})
+	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      "backup-repository",
+		MountPath: mountPath,
+	})
+}
+
 func DaemonSet(namespace string, opts ...podTemplateOption) *appsv1.DaemonSet {
 	c := &podTemplateConfig{
 		image: DefaultDatamgrImage,
@@ -134,13 +189,14 @@ func DaemonSet(namespace string, opts ...podTemplateOption) *appsv1.DaemonSet {
 						{
 							Name:            "datamgr-for-vsphere-plugin",
 							Image:           c.image,
+							Ports:           containerPorts(),
 							ImagePullPolicy: pullPolicy,
 							Command: []string{
 								"/datamgr",
 							},
-							Args: []string{
+							Args: append([]string{
 								"server",
-							},
+							}, c.args...),
 
 							VolumeMounts: []corev1.VolumeMount{
 								{
@@ -219,6 +275,8 @@ func DaemonSet(namespace string, opts ...podTemplateOption) *appsv1.DaemonSet {
 		}...)
 	}
 
+	appendRepositoryVolume(&daemonSet.Spec.Template.Spec, c.repoConfig)
+
 	daemonSet.Spec.Template.Spec.Containers[0].Env = append(daemonSet.Spec.Template.Spec.Containers[0].Env, c.envVars...)
 
 	return daemonSet
@@ -101,9 +101,9 @@ func Deployment(namespace string, opts ...podTemplateOption) *appsv1.Deployment
 							Command: []string{
 								"/backup-driver",
 							},
-							Args: []string{
+							Args: append([]string{
 								"server",
-							},
+							}, c.args...),
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "scratch",
@@ -168,6 +168,8 @@ func Deployment(namespace string, opts ...podTemplateOption) *appsv1.Deployment
 		deployment.Spec.Template.Spec.HostNetwork = true
 	}
 
+	appendRepositoryVolume(&deployment.Spec.Template.Spec, c.repoConfig)
+
 	deployment.Spec.Template.Spec.Containers[0].Env = append(deployment.Spec.Template.Spec.Containers[0].Env, c.envVars...)
 
 	return deployment
@@ -93,6 +93,16 @@ type PodOptions struct {
 	MasterAffinity bool
 	HostNetwork    bool
 	Features	   []string
+	RepositoryConfig map[string]string
+	UploadWorkers      int
+	DownloadWorkers    int
+	BandwidthLimit     string
+	UploadMaxRetries   int
+	DownloadMaxRetries int
+	IncrementalUpload  bool
+	RepositoryGCPeriod      time.Duration
+	RepositoryGCGracePeriod time.Duration
+	RepositoryGCDelete      bool
 }
 
 // Use "latest" if the build process didn't supply a version
@@ -377,12 +387,38 @@ func AllDatamgrResources(o *PodOptions, withCRDs bool) (*unstructured.Unstructur
 		WithImage(o.Image),
 		WithResources(o.PodResources),
 		WithSecret(o.SecretAdd),
+		WithRepositoryConfig(o.RepositoryConfig),
+		WithArgs(datamgrArgs(o)...),
 	)
 	appendUnstructured(resources, ds)
 
 	return resources, nil
 }
 
+// datamgrArgs returns the args of the data manager server for the options which are explicitly specified
+func datamgrArgs(o *PodOptions) []string {
+	var args []string
+	if o.UploadWorkers > 0 {
+		args = append(args, fmt.Sprintf("--upload-workers=%d", o.UploadWorkers))
+	}
+	if o.DownloadWorkers > 0 {
+		args = append(args, fmt.Sprintf("--download-workers=%d", o.DownloadWorkers))
+	}
+	if o.BandwidthLimit != "" && o.BandwidthLimit != "0" {
+		args = append(args, fmt.Sprintf("--bandwidth-limit=%s", o.BandwidthLimit))
+	}
+	if o.UploadMaxRetries > 0 {
+		args = append(args, fmt.Sprintf("--upload-max-retries=%d", o.UploadMaxRetries))
+	}
+	if o.DownloadMaxRetries != constants.DOWNLOAD_MAX_RETRY {
+		args = append(args, fmt.Sprintf("--download-max-retries=%d", o.DownloadMaxRetries))
+	}
+	if o.IncrementalUpload {
+		args = append(args, "--incremental-upload")
+	}
+	return args
+}
+
 // AllBackupDriverResources returns a list of all resources necessary to install Datamgr, in the appropriate order, into a Kubernetes cluster.
 // Items are unstructured, since there are different data types returned.
 func AllBackupDriverResources(o *PodOptions, withCRDs bool) (*unstructured.UnstructuredList, error) {
@@ -400,12 +436,30 @@ func AllBackupDriverResources(o *PodOptions, withCRDs bool) (*unstructured.Unstr
 		WithResources(o.PodResources),
 		WithMasterNodeAffinity(o.MasterAffinity),
 		WithHostNetwork(o.HostNetwork),
+		WithRepositoryConfig(o.RepositoryConfig),
+		WithArgs(backupDriverArgs(o)...),
 	)
 	appendUnstructured(resources, deploy)
 
 	return resources, nil
 }
 
+// backupDriverArgs returns the args of the backup-driver server for the options which are explicitly specified
+func backupDriverArgs(o *PodOptions) []string {
+	var args []string
+	if o.RepositoryGCPeriod <= 0 {
+		return args
+	}
+	args = append(args, fmt.Sprintf("--repository-gc-period=%v", o.RepositoryGCPeriod))
+	if o.RepositoryGCGracePeriod > 0 {
+		args = append(args, fmt.Sprintf("--repository-gc-grace-period=%v", o.RepositoryGCGracePeriod))
+	}
+	if o.RepositoryGCDelete {
+		args = append(args, "--repository-gc-delete")
+	}
+	return args
+}
+
 // GroupResources groups resources based on whether the resources are CustomResourceDefinitions or other types of kubernetes objects
 // This is useful to wait for readiness before creating CRD objects
 func GroupResources(resources *unstructured.UnstructuredList) *ResourceGroup {
@@ -0,0 +1,347 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// ServerMetrics contains Prometheus metrics for the data manager and backup driver servers.
+// All the recording methods are no-ops on a nil *ServerMetrics, so that controllers can be
+// constructed without metrics, e.g., in unit tests.
+type ServerMetrics struct {
+	metrics      map[string]prometheus.Collector
+	registerOnce sync.Once
+}
+
+const (
+	metricNamespace = "velero_plugin_for_vsphere"
+
+	// Backup driver metrics
+	snapshotAttemptTotal = "snapshot_attempt_total"
+	snapshotSuccessTotal = "snapshot_success_total"
+	snapshotFailureTotal = "snapshot_failure_total"
+	workqueueDepth       = "workqueue_depth"
+
+	// Data manager metrics
+	uploadAttemptTotal      = "upload_attempt_total"
+	uploadSuccessTotal      = "upload_success_total"
+	uploadFailureTotal      = "upload_failure_total"
+	uploadRetryTotal        = "upload_retry_total"
+	uploadDurationSeconds   = "upload_duration_seconds"
+	uploadBytesTotal        = "upload_bytes_total"
+	uploadsInProgress       = "uploads_in_progress"
+	downloadAttemptTotal    = "download_attempt_total"
+	downloadSuccessTotal    = "download_success_total"
+	downloadFailureTotal    = "download_failure_total"
+	downloadRetryTotal      = "download_retry_total"
+	downloadDurationSeconds = "download_duration_seconds"
+	downloadBytesTotal      = "download_bytes_total"
+
+	// Labels
+	nodeMetricLabel  = "node"
+	queueMetricLabel = "queue"
+)
+
+// Buckets from 10 seconds to roughly 11 hours, which covers data movement of small volumes up to multi-TB FCDs.
+var dataMovementDurationBuckets = prometheus.ExponentialBuckets(10, 2, 13)
+
+// NewServerMetrics returns new ServerMetrics
+func NewServerMetrics() *ServerMetrics {
+	return &ServerMetrics{
+		metrics: map[string]prometheus.Collector{
+			snapshotAttemptTotal: prometheus.NewCounter(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      snapshotAttemptTotal,
+					Help:      "Total number of attempted local snapshots",
+				},
+			),
+			snapshotSuccessTotal: prometheus.NewCounter(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      snapshotSuccessTotal,
+					Help:      "Total number of successful local snapshots",
+				},
+			),
+			snapshotFailureTotal: prometheus.NewCounter(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      snapshotFailureTotal,
+					Help:      "Total number of failed local snapshots",
+				},
+			),
+			uploadAttemptTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      uploadAttemptTotal,
+					Help:      "Total number of attempted uploads to the remote repository",
+				},
+				[]string{nodeMetricLabel},
+			),
+			uploadSuccessTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      uploadSuccessTotal,
+					Help:      "Total number of successful uploads to the remote repository",
+				},
+				[]string{nodeMetricLabel},
+			),
+			uploadFailureTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      uploadFailureTotal,
+					Help:      "Total number of failed uploads to the remote repository",
+				},
+				[]string{nodeMetricLabel},
+			),
+			uploadRetryTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      uploadRetryTotal,
+					Help:      "Total number of upload attempts which are retries of previously failed uploads",
+				},
+				[]string{nodeMetricLabel},
+			),
+			uploadDurationSeconds: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace: metricNamespace,
+					Name:      uploadDurationSeconds,
+					Help:      "Time taken to upload a snapshot to the remote repository, in seconds",
+					Buckets:   dataMovementDurationBuckets,
+				},
+				[]string{nodeMetricLabel},
+			),
+			uploadBytesTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      uploadBytesTotal,
+					Help:      "Total number of bytes uploaded to the remote repository",
+				},
+				[]string{nodeMetricLabel},
+			),
+			downloadAttemptTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      downloadAttemptTotal,
+					Help:      "Total number of attempted downloads from the remote repository",
+				},
+				[]string{nodeMetricLabel},
+			),
+			downloadSuccessTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      downloadSuccessTotal,
+					Help:      "Total number of successful downloads from the remote repository",
+				},
+				[]string{nodeMetricLabel},
+			),
+			downloadFailureTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      downloadFailureTotal,
+					Help:      "Total number of failed downloads from the remote repository",
+				},
+				[]string{nodeMetricLabel},
+			),
+			downloadRetryTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      downloadRetryTotal,
+					Help:      "Total number of download attempts which are retries of previously failed downloads",
+				},
+				[]string{nodeMetricLabel},
+			),
+			downloadDurationSeconds: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace: metricNamespace,
+					Name:      downloadDurationSeconds,
+					Help:      "Time taken to download a snapshot from the remote repository, in seconds",
+					Buckets:   dataMovementDurationBuckets,
+				},
+				[]string{nodeMetricLabel},
+			),
+			downloadBytesTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      downloadBytesTotal,
+					Help:      "Total number of bytes downloaded from the remote repository",
+				},
+				[]string{nodeMetricLabel},
+			),
+		},
+	}
+}
+
+// RegisterAllMetrics registers all prometheus metrics. The metrics are registered once, calling it again is a no-op,
+// but it panics if other metrics with the same names are already registered.
+func (m *ServerMetrics) RegisterAllMetrics() {
+	m.registerOnce.Do(func() {
+		for _, pm := range m.metrics {
+			prometheus.MustRegister(pm)
+		}
+	})
+}
+
+// StartMetricsServer serves the registered metrics at the given address in a separate goroutine.
+func StartMetricsServer(address string, logger logrus.FieldLogger) {
+	go func() {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", promhttp.Handler())
+		logger.Infof("Starting metric server at address [%s]", address)
+		if err := http.ListenAndServe(address, metricsMux); err != nil {
+			logger.Fatalf("Failed to start metric server at [%s]: %v", address, err)
+		}
+	}()
+}
+
+// RegisterWorkqueueDepth registers a gauge which reports the current depth of the named work queue.
+func (m *ServerMetrics) RegisterWorkqueueDepth(queueName string, depth func() int) {
+	if m == nil {
+		return
+	}
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace:   metricNamespace,
+			Name:        workqueueDepth,
+			Help:        "Current depth of the work queue",
+			ConstLabels: prometheus.Labels{queueMetricLabel: queueName},
+		},
+		func() float64 { return float64(depth()) },
+	))
+}
+
+// RegisterUploadsInProgress registers a gauge which reports the number of uploads in progress on the node.
+func (m *ServerMetrics) RegisterUploadsInProgress(node string, count func() int) {
+	if m == nil {
+		return
+	}
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace:   metricNamespace,
+			Name:        uploadsInProgress,
+			Help:        "Current number of uploads in progress",
+			ConstLabels: prometheus.Labels{nodeMetricLabel: node},
+		},
+		func() float64 { return float64(count()) },
+	))
+}
+
+// RegisterSnapshotAttempt records an attempt to take a local snapshot.
+func (m *ServerMetrics) RegisterSnapshotAttempt() {
+	if m == nil {
+		return
+	}
+	if c, ok := m.metrics[snapshotAttemptTotal].(prometheus.Counter); ok {
+		c.Inc()
+	}
+}
+
+// RegisterSnapshotSuccess records a successful local snapshot.
+func (m *ServerMetrics) RegisterSnapshotSuccess() {
+	if m == nil {
+		return
+	}
+	if c, ok := m.metrics[snapshotSuccessTotal].(prometheus.Counter); ok {
+		c.Inc()
+	}
+}
+
+// RegisterSnapshotFailed records a failed local snapshot.
+func (m *ServerMetrics) RegisterSnapshotFailed() {
+	if m == nil {
+		return
+	}
+	if c, ok := m.metrics[snapshotFailureTotal].(prometheus.Counter); ok {
+		c.Inc()
+	}
+}
+
+// RegisterUploadAttempt records an upload attempt, and whether it is a retry of a previously failed upload.
+func (m *ServerMetrics) RegisterUploadAttempt(node string, isRetry bool) {
+	m.registerAttempt(uploadAttemptTotal, uploadRetryTotal, node, isRetry)
+}
+
+// RegisterUploadSuccess records a successful upload and the time it took.
+func (m *ServerMetrics) RegisterUploadSuccess(node string, seconds float64) {
+	m.registerSuccess(uploadSuccessTotal, uploadDurationSeconds, node, seconds)
+}
+
+// RegisterUploadFailed records a failed upload.
+func (m *ServerMetrics) RegisterUploadFailed(node string) {
+	m.incCounterVec(uploadFailureTotal, node)
+}
+
+// AddUploadBytes records the number of bytes moved by uploads.
+func (m *ServerMetrics) AddUploadBytes(node string, bytes int64) {
+	m.addCounterVec(uploadBytesTotal, node, bytes)
+}
+
+// RegisterDownloadAttempt records a download attempt, and whether it is a retry of a previously failed download.
+func (m *ServerMetrics) RegisterDownloadAttempt(node string, isRetry bool) {
+	m.registerAttempt(downloadAttemptTotal, downloadRetryTotal, node, isRetry)
+}
+
+// RegisterDownloadSuccess records a successful download and the time it took.
+func (m *ServerMetrics) RegisterDownloadSuccess(node string, seconds float64) {
+	m.registerSuccess(downloadSuccessTotal, downloadDurationSeconds, node, seconds)
+}
+
+// RegisterDownloadFailed records a failed download.
+func (m *ServerMetrics) RegisterDownloadFailed(node string) {
+	m.incCounterVec(downloadFailureTotal, node)
+}
+
+// AddDownloadBytes records the number of bytes moved by downloads.
+func (m *ServerMetrics) AddDownloadBytes(node string, bytes int64) {
+	m.addCounterVec(downloadBytesTotal, node, bytes)
+}
+
+func (m *ServerMetrics) registerAttempt(attemptMetric string, retryMetric string, node string, isRetry bool) {
+	m.incCounterVec(attemptMetric, node)
+	if isRetry {
+		m.incCounterVec(retryMetric, node)
+	}
+}
+
+func (m *ServerMetrics) registerSuccess(successMetric string, durationMetric string, node string, seconds float64) {
+	m.incCounterVec(successMetric, node)
+	if m == nil {
+		return
+	}
+	if h, ok := m.metrics[durationMetric].(*prometheus.HistogramVec); ok {
+		h.WithLabelValues(node).Observe(seconds)
+	}
+}
+
+func (m *ServerMetrics) incCounterVec(metric string, node string) {
+	m.addCounterVec(metric, node, 1)
+}
+
+func (m *ServerMetrics) addCounterVec(metric string, node string, value int64) {
+	if m == nil || value <= 0 {
+		return
+	}
+	if c, ok := m.metrics[metric].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(node).Add(float64(value))
+	}
+}
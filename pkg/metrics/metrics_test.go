@@ -0,0 +1,96 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadMetrics(t *testing.T) {
+	m := NewServerMetrics()
+	node := "node-1"
+
+	m.RegisterUploadAttempt(node, false)
+	m.RegisterUploadAttempt(node, true)
+	m.RegisterUploadFailed(node)
+	m.RegisterUploadSuccess(node, 42)
+	m.AddUploadBytes(node, 1024)
+	m.AddUploadBytes(node, 0)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.metrics[uploadAttemptTotal].(*prometheus.CounterVec).WithLabelValues(node)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.metrics[uploadRetryTotal].(*prometheus.CounterVec).WithLabelValues(node)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.metrics[uploadFailureTotal].(*prometheus.CounterVec).WithLabelValues(node)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.metrics[uploadSuccessTotal].(*prometheus.CounterVec).WithLabelValues(node)))
+	assert.Equal(t, float64(1024), testutil.ToFloat64(m.metrics[uploadBytesTotal].(*prometheus.CounterVec).WithLabelValues(node)))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.metrics[uploadDurationSeconds]))
+}
+
+func TestNilServerMetrics(t *testing.T) {
+	var m *ServerMetrics
+	assert.NotPanics(t, func() {
+		m.RegisterSnapshotAttempt()
+		m.RegisterSnapshotSuccess()
+		m.RegisterSnapshotFailed()
+		m.RegisterUploadAttempt("node-1", true)
+		m.RegisterUploadSuccess("node-1", 1)
+		m.RegisterDownloadFailed("node-1")
+		m.AddDownloadBytes("node-1", 1)
+		m.RegisterWorkqueueDepth("queue", func() int { return 0 })
+	})
+}
+
+func TestRegisterAllMetricsOnce(t *testing.T) {
+	m := NewServerMetrics()
+	defer func() {
+		for _, pm := range m.metrics {
+			prometheus.Unregister(pm)
+		}
+	}()
+
+	assert.NotPanics(t, func() {
+		m.RegisterAllMetrics()
+		m.RegisterAllMetrics()
+	})
+	// The metrics of another server are duplicates
+	assert.Panics(t, func() {
+		NewServerMetrics().RegisterAllMetrics()
+	})
+}
+
+func TestRegisterWorkqueueDepthTwice(t *testing.T) {
+	m := NewServerMetrics()
+	gauge := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace:   metricNamespace,
+			Name:        workqueueDepth,
+			ConstLabels: prometheus.Labels{queueMetricLabel: "queue"},
+		},
+		func() float64 { return 0 },
+	)
+	defer prometheus.Unregister(gauge)
+
+	assert.NotPanics(t, func() {
+		m.RegisterWorkqueueDepth("queue", func() int { return 1 })
+	})
+	assert.Panics(t, func() {
+		m.RegisterWorkqueueDepth("queue", func() int { return 2 })
+	})
+}
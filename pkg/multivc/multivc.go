@@ -0,0 +1,421 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package multivc implements the ivd ProtectedEntityTypeManager of a cluster whose volumes are spread across
+// multiple vCenters, e.g. a cluster stretched across two vCenters with the multi-VC config of the vSphere CSI driver.
+//
+// The ivd ProtectedEntityTypeManager of astrolabe is bound to a single vCenter, and the pe-ids of the ivds do not
+// record their vCenter. The ProtectedEntityTypeManager of this package keeps an ivd ProtectedEntityTypeManager per
+// vCenter, and routes each ivd to the vCenter which has its volume.
+package multivc
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/astrolabe/pkg/common/vsphere"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
+)
+
+// ivdTypeManager is the ivd ProtectedEntityTypeManager of a single vCenter.
+type ivdTypeManager interface {
+	astrolabe.ProtectedEntityTypeManager
+	ReloadConfig(ctx context.Context, params map[string]interface{}) error
+}
+
+type newTypeManagerFunc func(params map[string]interface{}, logger logrus.FieldLogger) (ivdTypeManager, error)
+
+// vcenter is a vCenter of the cluster along with its ivd ProtectedEntityTypeManager.
+type vcenter struct {
+	host   string
+	params map[string]interface{}
+	// petm is nil when the vCenter could not be connected, the connection is retried on the next reload
+	petm ivdTypeManager
+}
+
+// ProtectedEntityTypeManager routes each ivd to the ivd ProtectedEntityTypeManager of the vCenter which has its
+// volume. The vCenter of a volume is looked up once and cached. The new volumes of the copies are provisioned in
+// the vCenter of the source ivd, or of the volume it was snapshotted from, if it is one of the vCenters, and in the
+// first vCenter otherwise.
+type ProtectedEntityTypeManager struct {
+	logger         logrus.FieldLogger
+	newTypeManager newTypeManagerFunc
+
+	lock sync.RWMutex
+	// vcenters are in the order of the vSphere config
+	vcenters []*vcenter
+	// volumes maps the ids of the volumes which have been looked up to the host of their vCenter
+	volumes map[string]string
+	// inFlight counts the data movements in progress, see Acquire
+	inFlight int
+	// pendingReload is the config of the last reload deferred until no data movement is in progress
+	pendingReload []map[string]interface{}
+	// reloading is set while the vCenters are reconnected, outside the lock
+	reloading bool
+	// reloadDone is signaled, with the lock, when no reload is pending or in progress anymore
+	reloadDone *sync.Cond
+}
+
+// ErrReloadDeferred is returned by ReloadConfig when the reload is deferred until the data movements in progress
+// are done, see Acquire.
+var ErrReloadDeferred = errors.New("The reload of the vCenter config is deferred until the data movements in progress are done")
+
+type acquiredKey struct{}
+
+// NewProtectedEntityTypeManager returns the ProtectedEntityTypeManager of the vCenters with the params. It fails
+// only if none of the vCenters can be connected, the others are connected again on the next reload.
+func NewProtectedEntityTypeManager(vcParams []map[string]interface{}, logger logrus.FieldLogger) (*ProtectedEntityTypeManager, error) {
+	return newProtectedEntityTypeManager(vcParams, newIVDTypeManager, logger)
+}
+
+func newIVDTypeManager(params map[string]interface{}, logger logrus.FieldLogger) (ivdTypeManager, error) {
+	petm, err := utils.GetIVDPETMFromParamsMap(params, logger)
+	if err != nil {
+		return nil, err
+	}
+	return petm, nil
+}
+
+func newProtectedEntityTypeManager(vcParams []map[string]interface{}, newTypeManager newTypeManagerFunc,
+	logger logrus.FieldLogger) (*ProtectedEntityTypeManager, error) {
+	this := &ProtectedEntityTypeManager{
+		logger:         logger,
+		newTypeManager: newTypeManager,
+		volumes:        make(map[string]string),
+	}
+	this.reloadDone = sync.NewCond(&this.lock)
+	err := this.ReloadConfig(context.Background(), vcParams)
+	if len(this.connected()) == 0 {
+		if err == nil {
+			err = errors.New("No VirtualCenter is configured")
+		}
+		return nil, err
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to connect to some of the vCenters")
+	}
+	return this, nil
+}
+
+// ReloadConfig reloads the config of each vCenter independently. The vCenters whose config has not changed keep
+// their connection, the new vCenters are connected and the removed ones are dropped. The vCenters which fail to
+// reload keep their previous config, and the errors of all the vCenters are returned together.
+//
+// The reload is deferred while data movements are in progress, and ErrReloadDeferred is returned, see Acquire.
+func (this *ProtectedEntityTypeManager) ReloadConfig(ctx context.Context, vcParams []map[string]interface{}) error {
+	this.lock.Lock()
+	if this.inFlight > 0 || this.reloading {
+		this.pendingReload = vcParams
+		inFlight := this.inFlight
+		this.lock.Unlock()
+		this.logger.Infof("Deferring the reload of the vCenter config until the %d data movements in progress are done", inFlight)
+		return ErrReloadDeferred
+	}
+	this.reloading = true
+	this.lock.Unlock()
+	return this.runReload(ctx, vcParams)
+}
+
+// Acquire marks the start of a data movement, which uses the connections to the vCenters until it is done, possibly
+// for hours. Reloading the config of a vCenter disconnects its connection and replaces it under the data movement,
+// so the reloads are deferred until no data movement is in progress, and only the last one is applied then. The new
+// data movements wait while a reload is pending, so that the data movements in progress are eventually done, unless
+// they are nested in a data movement of the context. The returned function marks the end of the data movement.
+func (this *ProtectedEntityTypeManager) Acquire(ctx context.Context) (context.Context, func()) {
+	this.lock.Lock()
+	if ctx.Value(acquiredKey{}) != this {
+		for this.pendingReload != nil || this.reloading {
+			this.reloadDone.Wait()
+		}
+		ctx = context.WithValue(ctx, acquiredKey{}, this)
+	}
+	this.inFlight++
+	this.lock.Unlock()
+	var once sync.Once
+	return ctx, func() {
+		once.Do(this.release)
+	}
+}
+
+func (this *ProtectedEntityTypeManager) release() {
+	this.lock.Lock()
+	this.inFlight--
+	if this.inFlight > 0 || this.pendingReload == nil || this.reloading {
+		this.lock.Unlock()
+		return
+	}
+	vcParams := this.pendingReload
+	this.pendingReload = nil
+	this.reloading = true
+	this.lock.Unlock()
+	this.logger.Info("Reloading the vCenter config deferred by the data movements")
+	if err := this.runReload(context.Background(), vcParams); err != nil {
+		this.logger.WithError(err).Error("Failed to reload the deferred vCenter config")
+	}
+}
+
+// runReload reloads the config, and then the configs deferred meanwhile, and resumes the data movements waiting in
+// Acquire. The caller must have set reloading.
+func (this *ProtectedEntityTypeManager) runReload(ctx context.Context, vcParams []map[string]interface{}) error {
+	err := this.reloadConfig(ctx, vcParams)
+	for {
+		this.lock.Lock()
+		vcParams = this.pendingReload
+		this.pendingReload = nil
+		if vcParams == nil {
+			this.reloading = false
+			this.reloadDone.Broadcast()
+			this.lock.Unlock()
+			return err
+		}
+		this.lock.Unlock()
+		this.logger.Info("Reloading the vCenter config deferred by the reload in progress")
+		err = this.reloadConfig(ctx, vcParams)
+	}
+}
+
+// reloadConfig reloads the config of the vCenters. The vCenters are connected without the lock, only one reload
+// runs at a time.
+func (this *ProtectedEntityTypeManager) reloadConfig(ctx context.Context, vcParams []map[string]interface{}) error {
+	this.lock.RLock()
+	previous := make(map[string]*vcenter)
+	for _, vc := range this.vcenters {
+		previous[vc.host] = vc
+	}
+	this.lock.RUnlock()
+
+	var vcenters []*vcenter
+	var failures []string
+	for _, params := range vcParams {
+		host, _ := params[vsphere.HostVcParamKey].(string)
+		if host == "" {
+			failures = append(failures, "VirtualCenter without a host")
+			continue
+		}
+		if findVCenter(vcenters, host) != nil {
+			this.logger.Warnf("Skipping the duplicate VirtualCenter %s", host)
+			continue
+		}
+		log := this.logger.WithField("VirtualCenter", host)
+		vc, ok := previous[host]
+		if ok && vc.petm != nil {
+			if err := vc.petm.ReloadConfig(ctx, params); err != nil {
+				log.WithError(err).Error("Failed to reload the config of the vCenter")
+				failures = append(failures, host+": "+err.Error())
+			} else {
+				vc = &vcenter{
+					host:   host,
+					params: params,
+					petm:   vc.petm,
+				}
+			}
+			vcenters = append(vcenters, vc)
+			continue
+		}
+		vc = &vcenter{
+			host:   host,
+			params: params,
+		}
+		petm, err := this.newTypeManager(params, log)
+		if err != nil {
+			log.WithError(err).Error("Failed to connect to the vCenter")
+			failures = append(failures, host+": "+err.Error())
+		} else {
+			log.Info("Connected to the vCenter")
+			vc.petm = petm
+		}
+		vcenters = append(vcenters, vc)
+	}
+
+	this.lock.Lock()
+	this.vcenters = vcenters
+	// The volumes of the removed vCenters are looked up again
+	for volumeID, host := range this.volumes {
+		if findVCenter(vcenters, host) == nil {
+			delete(this.volumes, volumeID)
+		}
+	}
+	this.lock.Unlock()
+
+	if len(failures) > 0 {
+		return errors.Errorf("Failed to reload the config of the vCenters: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func findVCenter(vcenters []*vcenter, host string) *vcenter {
+	for _, vc := range vcenters {
+		if vc.host == host {
+			return vc
+		}
+	}
+	return nil
+}
+
+// connected returns the vCenters which are connected, the caller must hold the lock.
+func (this *ProtectedEntityTypeManager) connected() []*vcenter {
+	var connected []*vcenter
+	for _, vc := range this.vcenters {
+		if vc.petm != nil {
+			connected = append(connected, vc)
+		}
+	}
+	return connected
+}
+
+// getVCenter returns the vCenter which has the volume of the ivd.
+func (this *ProtectedEntityTypeManager) getVCenter(ctx context.Context, id astrolabe.ProtectedEntityID) (*vcenter, error) {
+	this.lock.RLock()
+	connected := this.connected()
+	host, cached := this.volumes[id.GetID()]
+	this.lock.RUnlock()
+
+	switch len(connected) {
+	case 0:
+		return nil, errors.New("No vCenter is connected")
+	case 1:
+		// There is nothing to look up with a single vCenter
+		return connected[0], nil
+	}
+	if cached {
+		if vc := findVCenter(connected, host); vc != nil {
+			return vc, nil
+		}
+	}
+
+	var lastErr error
+	for _, vc := range connected {
+		pe, err := vc.petm.GetProtectedEntity(ctx, id)
+		if err == nil {
+			// The info of an ivd is retrieved from its vCenter
+			_, err = pe.GetInfo(ctx)
+		}
+		if err != nil {
+			this.logger.WithError(err).Debugf("The volume %s is not in the vCenter %s", id.GetID(), vc.host)
+			lastErr = err
+			continue
+		}
+		this.logger.Infof("The volume %s is in the vCenter %s", id.GetID(), vc.host)
+		this.lock.Lock()
+		this.volumes[id.GetID()] = vc.host
+		this.lock.Unlock()
+		return vc, nil
+	}
+	return nil, errors.Wrapf(lastErr, "Failed to find the volume %s in any vCenter", id.GetID())
+}
+
+// GetVcParams returns the params of the vCenter which has the volume of the ivd.
+func (this *ProtectedEntityTypeManager) GetVcParams(ctx context.Context, id astrolabe.ProtectedEntityID) (map[string]interface{}, error) {
+	vc, err := this.getVCenter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// The params are replaced on the reloads
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return vc.params, nil
+}
+
+func (this *ProtectedEntityTypeManager) GetTypeName() string {
+	return "ivd"
+}
+
+func (this *ProtectedEntityTypeManager) GetProtectedEntity(ctx context.Context, id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntity, error) {
+	vc, err := this.getVCenter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return vc.petm.GetProtectedEntity(ctx, id)
+}
+
+// GetProtectedEntities returns the ivds of all the connected vCenters.
+func (this *ProtectedEntityTypeManager) GetProtectedEntities(ctx context.Context) ([]astrolabe.ProtectedEntityID, error) {
+	this.lock.RLock()
+	connected := this.connected()
+	this.lock.RUnlock()
+
+	var ids []astrolabe.ProtectedEntityID
+	for _, vc := range connected {
+		vcIDs, err := vc.petm.GetProtectedEntities(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to list the ivds of the vCenter %s", vc.host)
+		}
+		ids = append(ids, vcIDs...)
+	}
+	return ids, nil
+}
+
+// Copy copies the source ivd into the vCenter of the source ivd, see getCopyVCenter.
+func (this *ProtectedEntityTypeManager) Copy(ctx context.Context, sourcePE astrolabe.ProtectedEntity,
+	params map[string]map[string]interface{}, options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntity, error) {
+	sourcePEInfo, err := sourcePE.GetInfo(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetInfo failed")
+	}
+	vc, err := this.getCopyVCenter(ctx, sourcePEInfo)
+	if err != nil {
+		return nil, err
+	}
+	ctx, release := this.Acquire(ctx)
+	defer release()
+	this.logger.Infof("Copying %s into the vCenter %s", sourcePE.GetID().String(), vc.host)
+	return vc.petm.Copy(ctx, sourcePE, params, options)
+}
+
+func (this *ProtectedEntityTypeManager) CopyFromInfo(ctx context.Context, info astrolabe.ProtectedEntityInfo,
+	params map[string]map[string]interface{}, options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntity, error) {
+	vc, err := this.getCopyVCenter(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	return vc.petm.CopyFromInfo(ctx, info, params, options)
+}
+
+// getCopyVCenter returns the vCenter to copy the source ivd into. It is the vCenter recorded in the vadp data
+// transport of the source ivd, if it is one of the vCenters. The snapshots read from a repository have no vadp data
+// transport, they are copied into the vCenter which has the volume they were snapshotted from, as the pe-ids of the
+// snapshots keep the id of their volume. The snapshots of the volumes which are not in any vCenter anymore are copied
+// into the first connected vCenter.
+func (this *ProtectedEntityTypeManager) getCopyVCenter(ctx context.Context, sourcePEInfo astrolabe.ProtectedEntityInfo) (*vcenter, error) {
+	this.lock.RLock()
+	connected := this.connected()
+	this.lock.RUnlock()
+	if len(connected) == 0 {
+		return nil, errors.New("No vCenter is connected")
+	}
+	for _, transport := range sourcePEInfo.GetDataTransports() {
+		if transport.GetTransportType() != "vadp" {
+			continue
+		}
+		if host, ok := transport.GetParam("vcenter"); ok {
+			if vc := findVCenter(connected, host); vc != nil {
+				return vc, nil
+			}
+		}
+	}
+	id := sourcePEInfo.GetID()
+	vc, err := this.getVCenter(ctx, astrolabe.NewProtectedEntityID(id.GetPeType(), id.GetID()))
+	if err != nil {
+		this.logger.WithError(err).Warnf("The volume of %s is not in any vCenter, copying it into the vCenter %s",
+			id.String(), connected[0].host)
+		return connected[0], nil
+	}
+	return vc, nil
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multivc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/fsrepository"
+)
+
+type fakeProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	id         astrolabe.ProtectedEntityID
+	vcenter    string
+	transports []astrolabe.DataTransport
+	exists     bool
+}
+
+func (this fakeProtectedEntity) GetID() astrolabe.ProtectedEntityID {
+	return this.id
+}
+
+func (this fakeProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	if !this.exists {
+		return nil, errors.Errorf("The volume %s is not in the vCenter %s", this.id.GetID(), this.vcenter)
+	}
+	return astrolabe.NewProtectedEntityInfo(this.id, "fake-pe", this.transports, nil, nil, nil), nil
+}
+
+type fakeTypeManager struct {
+	astrolabe.ProtectedEntityTypeManager
+	host    string
+	volumes map[string]bool
+	reloads int
+	fail    bool
+}
+
+func (this *fakeTypeManager) GetProtectedEntity(ctx context.Context, id astrolabe.ProtectedEntityID) (astrolabe.ProtectedEntity, error) {
+	return fakeProtectedEntity{id: id, vcenter: this.host, exists: this.volumes[id.GetID()]}, nil
+}
+
+func (this *fakeTypeManager) GetProtectedEntities(ctx context.Context) ([]astrolabe.ProtectedEntityID, error) {
+	var ids []astrolabe.ProtectedEntityID
+	for volume := range this.volumes {
+		ids = append(ids, astrolabe.NewProtectedEntityID("ivd", volume))
+	}
+	return ids, nil
+}
+
+func (this *fakeTypeManager) Copy(ctx context.Context, pe astrolabe.ProtectedEntity, params map[string]map[string]interface{},
+	options astrolabe.CopyCreateOptions) (astrolabe.ProtectedEntity, error) {
+	return fakeProtectedEntity{id: astrolabe.NewProtectedEntityID("ivd", "new-volume"), vcenter: this.host, exists: true}, nil
+}
+
+func (this *fakeTypeManager) ReloadConfig(ctx context.Context, params map[string]interface{}) error {
+	if this.fail {
+		return errors.New("Failed to connect")
+	}
+	this.reloads++
+	return nil
+}
+
+type fakeVCenters struct {
+	typeManagers map[string]*fakeTypeManager
+	unreachable  map[string]bool
+	// onConnect is called when a vCenter is connected
+	onConnect func()
+}
+
+func (this *fakeVCenters) newTypeManager(params map[string]interface{}, logger logrus.FieldLogger) (ivdTypeManager, error) {
+	host := params["VirtualCenter"].(string)
+	if this.unreachable[host] {
+		return nil, errors.Errorf("Failed to connect to %s", host)
+	}
+	if this.onConnect != nil {
+		this.onConnect()
+	}
+	petm := &fakeTypeManager{host: host, volumes: make(map[string]bool)}
+	this.typeManagers[host] = petm
+	return petm, nil
+}
+
+func vcParams(hosts ...string) []map[string]interface{} {
+	var params []map[string]interface{}
+	for _, host := range hosts {
+		params = append(params, map[string]interface{}{"VirtualCenter": host})
+	}
+	return params
+}
+
+func TestRouteToVCenter(t *testing.T) {
+	ctx := context.Background()
+	vcenters := &fakeVCenters{typeManagers: make(map[string]*fakeTypeManager)}
+	petm, err := newProtectedEntityTypeManager(vcParams("vc-1", "vc-2"), vcenters.newTypeManager, logrus.New())
+	require.NoError(t, err)
+	vcenters.typeManagers["vc-1"].volumes["volume-1"] = true
+	vcenters.typeManagers["vc-2"].volumes["volume-2"] = true
+
+	for volume, host := range map[string]string{"volume-1": "vc-1", "volume-2": "vc-2"} {
+		pe, err := petm.GetProtectedEntity(ctx, astrolabe.NewProtectedEntityID("ivd", volume))
+		require.NoError(t, err)
+		assert.Equal(t, host, pe.(fakeProtectedEntity).vcenter)
+		params, err := petm.GetVcParams(ctx, pe.GetID())
+		require.NoError(t, err)
+		assert.Equal(t, host, params["VirtualCenter"])
+	}
+	_, err = petm.GetProtectedEntity(ctx, astrolabe.NewProtectedEntityID("ivd", "volume-3"))
+	assert.Error(t, err)
+
+	// The vCenter of a volume is cached
+	delete(vcenters.typeManagers["vc-2"].volumes, "volume-2")
+	pe, err := petm.GetProtectedEntity(ctx, astrolabe.NewProtectedEntityID("ivd", "volume-2"))
+	require.NoError(t, err)
+	assert.Equal(t, "vc-2", pe.(fakeProtectedEntity).vcenter)
+
+	ids, err := petm.GetProtectedEntities(ctx)
+	require.NoError(t, err)
+	assert.Len(t, ids, 1)
+
+	// The copies go to the vCenter of their source, or to the first vCenter
+	for host, expected := range map[string]string{"vc-2": "vc-2", "vc-other": "vc-1"} {
+		source := fakeProtectedEntity{
+			id:         astrolabe.NewProtectedEntityID("ivd", "volume-1"),
+			transports: []astrolabe.DataTransport{astrolabe.NewDataTransport("vadp", map[string]string{"vcenter": host})},
+			exists:     true,
+		}
+		copied, err := petm.Copy(ctx, source, nil, astrolabe.AllocateNewObject)
+		require.NoError(t, err)
+		assert.Equal(t, expected, copied.(fakeProtectedEntity).vcenter)
+	}
+}
+
+func TestReloadVCenters(t *testing.T) {
+	ctx := context.Background()
+	vcenters := &fakeVCenters{
+		typeManagers: make(map[string]*fakeTypeManager),
+		unreachable:  map[string]bool{"vc-2": true},
+	}
+	// The vCenters which can be connected are used
+	petm, err := newProtectedEntityTypeManager(vcParams("vc-1", "vc-2"), vcenters.newTypeManager, logrus.New())
+	require.NoError(t, err)
+	vc1 := vcenters.typeManagers["vc-1"]
+	vc1.volumes["volume-1"] = true
+	pe, err := petm.GetProtectedEntity(ctx, astrolabe.NewProtectedEntityID("ivd", "volume-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "vc-1", pe.(fakeProtectedEntity).vcenter)
+
+	// The unreachable vCenter is connected on the next reload, while the other one is reloaded in place
+	delete(vcenters.unreachable, "vc-2")
+	require.NoError(t, petm.ReloadConfig(ctx, vcParams("vc-1", "vc-2")))
+	assert.Equal(t, 1, vc1.reloads)
+	assert.Same(t, vc1, vcenters.typeManagers["vc-1"])
+	vcenters.typeManagers["vc-2"].volumes["volume-2"] = true
+	pe, err = petm.GetProtectedEntity(ctx, astrolabe.NewProtectedEntityID("ivd", "volume-2"))
+	require.NoError(t, err)
+	assert.Equal(t, "vc-2", pe.(fakeProtectedEntity).vcenter)
+
+	// A vCenter failing to reload does not prevent the others from reloading
+	vc1.fail = true
+	err = petm.ReloadConfig(ctx, vcParams("vc-1", "vc-2"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "vc-1")
+	assert.Equal(t, 1, vcenters.typeManagers["vc-2"].reloads)
+	pe, err = petm.GetProtectedEntity(ctx, astrolabe.NewProtectedEntityID("ivd", "volume-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "vc-1", pe.(fakeProtectedEntity).vcenter)
+
+	// The removed vCenters are dropped
+	require.NoError(t, petm.ReloadConfig(ctx, vcParams("vc-2")))
+	pe, err = petm.GetProtectedEntity(ctx, astrolabe.NewProtectedEntityID("ivd", "volume-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "vc-2", pe.(fakeProtectedEntity).vcenter)
+
+	vcenters.unreachable["vc-3"] = true
+	_, err = newProtectedEntityTypeManager(vcParams("vc-3"), vcenters.newTypeManager, logrus.New())
+	assert.Error(t, err)
+}
+
+func TestDeferReloadUnderDataMovement(t *testing.T) {
+	vcenters := &fakeVCenters{typeManagers: make(map[string]*fakeTypeManager)}
+	petm, err := newProtectedEntityTypeManager(vcParams("vc-1"), vcenters.newTypeManager, logrus.New())
+	require.NoError(t, err)
+	vc1 := vcenters.typeManagers["vc-1"]
+
+	// The reloads are deferred until the data movements are done, and only the last one is applied
+	ctx1, release1 := petm.Acquire(context.Background())
+	_, release2 := petm.Acquire(context.Background())
+	assert.Equal(t, ErrReloadDeferred, petm.ReloadConfig(context.Background(), vcParams("vc-1")))
+	assert.Equal(t, ErrReloadDeferred, petm.ReloadConfig(context.Background(), vcParams("vc-1", "vc-2")))
+	assert.Equal(t, 0, vc1.reloads)
+	assert.NotContains(t, vcenters.typeManagers, "vc-2")
+
+	// The data movements nested in a data movement in progress do not wait for the reload
+	_, releaseNested := petm.Acquire(ctx1)
+	releaseNested()
+
+	// The new data movements wait for the pending reload
+	acquired := make(chan struct{})
+	go func() {
+		_, release3 := petm.Acquire(context.Background())
+		close(acquired)
+		release3()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("The data movement started while the reload is pending")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The vCenters are connected without the lock
+	vcenters.onConnect = func() {
+		_, err := petm.GetProtectedEntities(context.Background())
+		assert.NoError(t, err)
+	}
+	release1()
+	// Releasing twice has no effect
+	release1()
+	assert.Equal(t, 0, vc1.reloads)
+	release2()
+	assert.Equal(t, 1, vc1.reloads)
+	assert.Contains(t, vcenters.typeManagers, "vc-2")
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The data movement did not start after the reload")
+	}
+
+	// The reloads are applied right away without data movements
+	require.NoError(t, petm.ReloadConfig(context.Background(), vcParams("vc-1")))
+	assert.Equal(t, 2, vc1.reloads)
+}
+
+// snapshotProtectedEntity is a local snapshot of an ivd to copy into a repository
+type snapshotProtectedEntity struct {
+	fakeProtectedEntity
+}
+
+func (this snapshotProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader([]byte("data"))), nil
+}
+
+func (this snapshotProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader([]byte("metadata"))), nil
+}
+
+func TestRouteRepositorySnapshot(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "multivc")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	repositoryPETM, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+
+	vcenters := &fakeVCenters{typeManagers: make(map[string]*fakeTypeManager)}
+	petm, err := newProtectedEntityTypeManager(vcParams("vc-1", "vc-2"), vcenters.newTypeManager, logrus.New())
+	require.NoError(t, err)
+	vcenters.typeManagers["vc-2"].volumes["volume-2"] = true
+
+	for volume, expected := range map[string]string{
+		// The snapshot is restored into the vCenter of its volume
+		"volume-2": "vc-2",
+		// The snapshot of a deleted volume is restored into the first vCenter
+		"volume-3": "vc-1",
+	} {
+		id := astrolabe.NewProtectedEntityIDWithSnapshotID("ivd", volume, astrolabe.NewProtectedEntitySnapshotID("snapshot-1"))
+		source := snapshotProtectedEntity{fakeProtectedEntity{
+			id:         id,
+			transports: []astrolabe.DataTransport{astrolabe.NewDataTransport("vadp", map[string]string{"vcenter": "vc-2"})},
+			exists:     true,
+		}}
+		_, err = repositoryPETM.Copy(ctx, source, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+		require.NoError(t, err)
+
+		// The repository does not keep the vadp data transport of the snapshot
+		repositoryPE, err := repositoryPETM.GetProtectedEntity(ctx, id)
+		require.NoError(t, err)
+		info, err := repositoryPE.GetInfo(ctx)
+		require.NoError(t, err)
+		for _, transport := range info.GetDataTransports() {
+			assert.NotEqual(t, "vadp", transport.GetTransportType())
+		}
+
+		copied, err := petm.Copy(ctx, repositoryPE, nil, astrolabe.AllocateNewObject)
+		require.NoError(t, err)
+		assert.Equal(t, expected, copied.(fakeProtectedEntity).vcenter, volume)
+	}
+}
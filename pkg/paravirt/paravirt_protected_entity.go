@@ -11,8 +11,11 @@ import (
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/snapshotUtils"
 	"io"
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 /*
@@ -78,10 +81,18 @@ func (this ParaVirtProtectedEntity) Snapshot(ctx context.Context, params map[str
 	labels := map[string]string{
 		constants.SnapshotBackupLabel: params[astrolabe.PvcPEType][constants.SnapshotParamBackupName].(string),
 	}
+	// Refer to the guest snapshot so that the guest can map the supervisor snapshot back to it after a restart
+	if snapshotRef, ok := params[astrolabe.PvcPEType][constants.SnapshotParamSnapshotRef].(string); ok {
+		if refParts := strings.Split(snapshotRef, "/"); len(refParts) == 2 {
+			labels[constants.GuestSnapshotNamespaceLabel] = refParts[0]
+			labels[constants.GuestSnapshotNameLabel] = refParts[1]
+		}
+	}
 
 	this.logger.Info("Creating a snapshot CR")
 	backupRepository := snapshotUtils.NewBackupRepository(backupRepositoryName)
-	snapshot, err := snapshotUtils.SnapshotRef(ctx, this.pvpetm.svcBackupDriverClient, objectToSnapshot, this.pvpetm.svcNamespace,
+	_, svcBackupDriverClient, svcNamespace := this.pvpetm.getSvcClients()
+	snapshot, err := snapshotUtils.SnapshotRef(ctx, svcBackupDriverClient, objectToSnapshot, svcNamespace,
 		*backupRepository, labels, []backupdriverv1api.SnapshotPhase{backupdriverv1api.SnapshotPhaseSnapshotted, backupdriverv1api.SnapshotPhaseSnapshotFailed}, this.logger)
 	if err != nil {
 		this.logger.Errorf("Failed to create a snapshot CR: %v", err)
@@ -126,17 +137,18 @@ func (this ParaVirtProtectedEntity) DeleteSnapshot(
 		deleteSnapshotName = "INVALID_DELETE_SNAPSHOT_NAME"
 	}
 
+	_, svcBackupDriverClient, svcNamespace := this.pvpetm.getSvcClients()
 	peIDName := this.GetID().GetID()
 	// Reconstruct the snapshot-id to delete.
 	peID := astrolabe.NewProtectedEntityIDWithNamespaceAndSnapshot(
 		astrolabe.PvcPEType,
 		peIDName,
-		this.pvpetm.svcNamespace,
+		svcNamespace,
 		snapshotToDelete.String())
 	this.logger.Infof("ParaVirtProtectedEntity: Reconstructed peID: %s", peID.String())
 
 	backupRepository := snapshotUtils.NewBackupRepository(backupRepositoryName)
-	svcDeleteSnap, err := snapshotUtils.DeleteSnapshotRef(ctx, this.pvpetm.svcBackupDriverClient, peID.String(), this.pvpetm.svcNamespace, *backupRepository,
+	svcDeleteSnap, err := snapshotUtils.DeleteSnapshotRef(ctx, svcBackupDriverClient, peID.String(), svcNamespace, *backupRepository,
 		[]backupdriverv1api.DeleteSnapshotPhase{backupdriverv1api.DeleteSnapshotPhaseCompleted, backupdriverv1api.DeleteSnapshotPhaseFailed}, this.logger)
 	if err != nil {
 		this.logger.Errorf("Failed to create a DeleteSnapshot CR: %v", err)
@@ -147,6 +159,37 @@ func (this ParaVirtProtectedEntity) DeleteSnapshot(
 	return true, nil
 }
 
+// CancelSnapshot requests the cancellation of the Supervisor Cluster snapshot which backs a snapshot of this
+// Protected Entity, and returns the phase of the Supervisor Cluster snapshot. The Supervisor Cluster backup driver
+// carries out the cancellation, a snapshot which is already done, e.g. Uploaded, is left alone.
+func (this ParaVirtProtectedEntity) CancelSnapshot(ctx context.Context, svcSnapshotName string) (backupdriverv1api.SnapshotPhase, error) {
+	this.logger.Infof("ParaVirtProtectedEntity: CancelSnapshot called on Paravirtualized Protected Entity, %v svcSnapshotName: %s", this.id.String(), svcSnapshotName)
+	_, svcBackupDriverClient, svcNamespace := this.pvpetm.getSvcClients()
+	svcSnapshot, err := svcBackupDriverClient.Snapshots(svcNamespace).Get(ctx, svcSnapshotName, metav1.GetOptions{})
+	if err != nil {
+		this.logger.Errorf("Failed to get Supervisor snapshot %s/%s: %v", svcNamespace, svcSnapshotName, err)
+		return "", errors.WithStack(err)
+	}
+	switch svcSnapshot.Status.Phase {
+	case backupdriverv1api.SnapshotPhaseUploaded, backupdriverv1api.SnapshotPhaseCleanupFailed,
+		backupdriverv1api.SnapshotPhaseSnapshotFailed, backupdriverv1api.SnapshotPhaseCanceled:
+		this.logger.Infof("Supervisor snapshot %s/%s is already in phase %s, nothing left to cancel", svcNamespace, svcSnapshotName, svcSnapshot.Status.Phase)
+		return svcSnapshot.Status.Phase, nil
+	}
+	if svcSnapshot.Spec.SnapshotCancel {
+		this.logger.Infof("The cancellation of Supervisor snapshot %s/%s was already requested", svcNamespace, svcSnapshotName)
+		return backupdriverv1api.SnapshotPhaseCanceling, nil
+	}
+	patchBytes := []byte(`{"spec":{"snapshotCancel":true}}`)
+	_, err = svcBackupDriverClient.Snapshots(svcNamespace).Patch(ctx, svcSnapshotName, types.MergePatchType, patchBytes, metav1.PatchOptions{})
+	if err != nil {
+		this.logger.Errorf("Failed to request the cancellation of Supervisor snapshot %s/%s: %v", svcNamespace, svcSnapshotName, err)
+		return "", errors.WithStack(err)
+	}
+	this.logger.Infof("Requested the cancellation of Supervisor snapshot %s/%s", svcNamespace, svcSnapshotName)
+	return backupdriverv1api.SnapshotPhaseCanceling, nil
+}
+
 func (this ParaVirtProtectedEntity) GetInfoForSnapshot(ctx context.Context, snapshotID astrolabe.ProtectedEntitySnapshotID) (*astrolabe.ProtectedEntityInfo, error) {
 	panic("implement me")
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backuprepository"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	"sync"
 
 	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
 	"github.com/vmware-tanzu/astrolabe/pkg/pvc"
@@ -47,6 +48,8 @@ type ParaVirtProtectedEntityTypeManager struct {
 	svcNamespace          string
 	s3Config              astrolabe.S3Config
 	logger                logrus.FieldLogger
+	// Protects the supervisor clients and namespace which are rebuilt when the supervisor credentials are rotated
+	svcLock sync.RWMutex
 }
 
 const (
@@ -116,6 +119,34 @@ func NewParaVirtProtectedEntityTypeManagerFromConfig(params map[string]interface
 	}, nil
 }
 
+// ReloadSupervisorConfig rebuilds the clients to the Supervisor Cluster with the given configuration, e.g. after
+// the supervisor credentials were rotated.
+func (this *ParaVirtProtectedEntityTypeManager) ReloadSupervisorConfig(svcConfig *rest.Config, svcNamespace string) error {
+	svcKubeClientSet, err := kubernetes.NewForConfig(svcConfig)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	svcBackupDriverClient, err := backupdriverTypedV1.NewForConfig(svcConfig)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	this.svcLock.Lock()
+	defer this.svcLock.Unlock()
+	this.svcKubeClientSet = svcKubeClientSet
+	this.svcBackupDriverClient = svcBackupDriverClient
+	this.svcNamespace = svcNamespace
+	this.logger.Infof("Reloaded the Supervisor Cluster config of paravirtualized PETM, supervisor namespace: %s", svcNamespace)
+	return nil
+}
+
+// getSvcClients returns the current clients to the Supervisor Cluster and the supervisor namespace.
+func (this *ParaVirtProtectedEntityTypeManager) getSvcClients() (*kubernetes.Clientset, *backupdriverTypedV1.BackupdriverV1alpha1Client, string) {
+	this.svcLock.RLock()
+	defer this.svcLock.RUnlock()
+	return this.svcKubeClientSet, this.svcBackupDriverClient, this.svcNamespace
+}
+
 func (this *ParaVirtProtectedEntityTypeManager) GetTypeName() string {
 	// e.g. "paravirt-pv"
 	return ParaVirtPETypePrefix + ParaVirtPETypeSep + string(this.entityType)
@@ -204,10 +235,11 @@ func (this *ParaVirtProtectedEntityTypeManager) CreateFromMetadata(ctx context.C
 
 	apiGroup := ""
 	kind := "PersistentVolumeClaim"
+	svcKubeClientSet, svcBackupDriverClient, svcNamespace := this.getSvcClients()
 
 	// Get Supervisor Cluster PVC and Guest Cluster PVC name and
 	// namespace by retrieving from metadata
-	svcPVC, gcPVCNamespace, gcPVCName, gcPVCLabels, err := this.getSuperPVCandGuestPVCName(metadata)
+	svcPVC, gcPVCNamespace, gcPVCName, gcPVCLabels, err := this.getSuperPVCandGuestPVCName(metadata, svcNamespace)
 	if err != nil {
 		return nil, err
 	}
@@ -230,7 +262,7 @@ func (this *ParaVirtProtectedEntityTypeManager) CreateFromMetadata(ctx context.C
 	// PVC PETM will call CreateFromMetadata which will create a PVC
 	// in the Supervisor Cluster
 	this.logger.Info("Creating a CloneFromSnapshot CR in Supervisor Cluster")
-	svcClone, err := snapshotUtils.CloneFromSnapshopRef(ctx, this.svcBackupDriverClient, newSnapshotID, svcPVCData, &apiGroup, kind, this.svcNamespace, *backupRepo, []backupdriverv1.ClonePhase{backupdriverv1.ClonePhaseCompleted, backupdriverv1.ClonePhaseFailed, backupdriverv1.ClonePhaseCanceled}, this.logger)
+	svcClone, err := snapshotUtils.CloneFromSnapshopRef(ctx, svcBackupDriverClient, newSnapshotID, svcPVCData, &apiGroup, kind, svcNamespace, *backupRepo, []backupdriverv1.ClonePhase{backupdriverv1.ClonePhaseCompleted, backupdriverv1.ClonePhaseFailed, backupdriverv1.ClonePhaseCanceled}, this.logger)
 	this.logger.Infof("CreateFromMetadata: finished waiting for CloneFromSnapshot's status to be completed, failed, or canceled in the Supervisor Cluster")
 
 	if err != nil {
@@ -239,17 +271,17 @@ func (this *ParaVirtProtectedEntityTypeManager) CreateFromMetadata(ctx context.C
 	}
 
 	if svcClone.Status.Phase == backupdriverv1.ClonePhaseFailed {
-		this.logger.Errorf("CloneFromSnapshot CR %s/%s failed in the Supervisor Cluster", this.svcNamespace, svcClone.Name)
-		return nil, fmt.Errorf("CloneFromSnapshot failed in the Supervisor Cluster: %s/%s", svcClone.Namespace, svcClone.Name)
+		this.logger.Errorf("CloneFromSnapshot CR %s/%s failed in the Supervisor Cluster", svcNamespace, svcClone.Name)
+		return nil, fmt.Errorf("CloneFromSnapshot failed in the Supervisor Cluster: %s/%s: %s", svcClone.Namespace, svcClone.Name, svcClone.Status.Message)
 	} else if svcClone.Status.Phase == backupdriverv1.ClonePhaseCanceled {
-		this.logger.Errorf("CloneFromSnapshot CR %s/%s is canceled in the Supervisor Cluster", this.svcNamespace, svcClone.Name)
+		this.logger.Errorf("CloneFromSnapshot CR %s/%s is canceled in the Supervisor Cluster", svcNamespace, svcClone.Name)
 		return nil, fmt.Errorf("CloneFromSnapshot is canceled: %s/%s in the Supervisor Cluster", svcClone.Namespace, svcClone.Name)
 	}
 	this.logger.Infof("CreateFromMetadata: CloneFromSnapshot %s/%s is completed in Supervisor Cluster. Phase: %v", svcClone.Namespace, svcClone.Name, svcClone.Status.Phase)
 	// Get a fresh Supervisor PVC object
-	svcPvcUpdated, err := this.svcKubeClientSet.CoreV1().PersistentVolumeClaims(this.svcNamespace).Get(context.TODO(), svcPVC.Name, metav1.GetOptions{})
+	svcPvcUpdated, err := svcKubeClientSet.CoreV1().PersistentVolumeClaims(svcNamespace).Get(context.TODO(), svcPVC.Name, metav1.GetOptions{})
 	if err != nil {
-		this.logger.Errorf("Failed to get PVC %s/%s from Supervisor Cluster: %v", this.svcNamespace, svcPVC.Name, err)
+		this.logger.Errorf("Failed to get PVC %s/%s from Supervisor Cluster: %v", svcNamespace, svcPVC.Name, err)
 		return nil, errors.Wrapf(err, "Failed to get PVC from Supervisor Cluster")
 	}
 	svcPVC = svcPvcUpdated
@@ -311,7 +343,7 @@ func decodeSnapshotID(snapshotID astrolabe.ProtectedEntitySnapshotID, logger log
 
 // getSuperPVCandGuestPVCName converts metadata to PVC and returns the PVC
 // in Supervisor Cluster and namespace and name of the PVC in Guest Cluster
-func (this *ParaVirtProtectedEntityTypeManager) getSuperPVCandGuestPVCName(metadata []byte) (*v1.PersistentVolumeClaim, string, string, map[string]string, error) {
+func (this *ParaVirtProtectedEntityTypeManager) getSuperPVCandGuestPVCName(metadata []byte, svcNamespace string) (*v1.PersistentVolumeClaim, string, string, map[string]string, error) {
 	// Decode metadata, change namespace of PVC
 	// from Guest Cluster namespace to Supervisor Cluster namespace,
 	// and encode again before calling CreateFromMetadata
@@ -345,7 +377,7 @@ func (this *ParaVirtProtectedEntityTypeManager) getSuperPVCandGuestPVCName(metad
 	
 	// Construct a name for the PVC in Supervisor cluster
 	svcPVC.Name = svcPVC.Name[0:4] + "-" + pvcUUID.String()
-	svcPVC.Namespace = this.svcNamespace
+	svcPVC.Namespace = svcNamespace
 	svcStorageClassName := ""
 	if svcPVC.Spec.StorageClassName != nil {
 		svcStorageClassName = *svcPVC.Spec.StorageClassName
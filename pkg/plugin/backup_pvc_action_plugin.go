@@ -91,6 +91,17 @@ func (p *NewPVCBackupItemAction) Execute(item runtime.Unstructured, backup *vele
 		return nil, nil, errors.WithStack(err)
 	}
 
+	// The PVCs excluded from the volume snapshots are backed up as is, the reason is recorded in the backup log
+	snapshotFilter, err := pluginUtil.RetrieveSnapshotFilter(restConfig, veleroNs, p.Log)
+	if err != nil {
+		p.Log.Errorf("Failed to retrieve the snapshot filter: %v", err)
+		return nil, nil, errors.WithStack(err)
+	}
+	if snapshot, reason := pluginUtil.ShouldSnapshotPVC(&pvc, snapshotFilter, p.Log); !snapshot {
+		p.Log.Infof("Skipping the snapshot of PVC %s/%s since %s", pvc.Namespace, pvc.Name, reason)
+		return item, nil, nil
+	}
+
 	// Do not claim a backup repository in local mode
 	var backupRepositoryName string
 	isLocalMode := utils.IsFeatureEnabled(constants.VSphereLocalModeFlag, false, p.Log)
@@ -116,6 +127,9 @@ func (p *NewPVCBackupItemAction) Execute(item runtime.Unstructured, backup *vele
 		constants.SnapshotBackupLabel: backup.Name,
 	}
 
+	// The item action only waits for the local snapshot, the snapshot is uploaded after the backup item is returned to
+	// Velero. The uploads are tracked by the backup-driver, which marks the backup as PartiallyFailed if an upload fails.
+	// The Snapshot may already be past Snapshotted when it is observed, its later phases are accepted as well.
 	p.Log.Info("Creating a Snapshot CR")
 	updatedSnapshot, err := snapshotUtils.SnapshotRef(ctx, backupdriverClient, objectToSnapshot, pvc.Namespace, *backupRepository, labels,
 		[]backupdriverv1.SnapshotPhase{backupdriverv1.SnapshotPhaseSnapshotted, backupdriverv1.SnapshotPhaseSnapshotFailed, backupdriverv1.SnapshotPhaseUploaded, backupdriverv1.SnapshotPhaseUploading, backupdriverv1.SnapshotPhaseUploadFailed, backupdriverv1.SnapshotPhaseCanceling, backupdriverv1.SnapshotPhaseCanceled, backupdriverv1.SnapshotPhaseCleanupFailed}, p.Log)
@@ -127,20 +141,14 @@ func (p *NewPVCBackupItemAction) Execute(item runtime.Unstructured, backup *vele
 		errMsg := fmt.Sprintf("Failed to create a Snapshot CR: Phase=SnapshotFailed, err=%v", updatedSnapshot.Status.Message)
 		p.Log.Error(errMsg)
 		return nil, nil, errors.New(errMsg)
-	} else if updatedSnapshot.Status.Phase == backupdriverv1.SnapshotPhaseUploadFailed {
-		errMsg := fmt.Sprintf("Failed to upload a Snapshot: Phase=UploadFailed, err=%v", updatedSnapshot.Status.Message)
-		p.Log.Error(errMsg)
-		return nil, nil, errors.New(errMsg)
-	} else if updatedSnapshot.Status.Phase == backupdriverv1.SnapshotPhaseCanceled {
-		errMsg := fmt.Sprintf("Snapshot upload canceled: Phase=Canceled, err=%v", updatedSnapshot.Status.Message)
+	} else if updatedSnapshot.Status.SnapshotID == "" {
+		// The Snapshot was canceled before the local snapshot was taken
+		errMsg := fmt.Sprintf("Failed to create a Snapshot CR: Phase=%s, err=%v", updatedSnapshot.Status.Phase, updatedSnapshot.Status.Message)
 		p.Log.Error(errMsg)
 		return nil, nil, errors.New(errMsg)
-	} else if updatedSnapshot.Status.Phase == backupdriverv1.SnapshotPhaseCleanupFailed {
-		errMsg := fmt.Sprintf("Failed to clean up a local snapshot after upload: Phase=CleanupAfterUploadFailed, err=%v", updatedSnapshot.Status.Message)
-		p.Log.Error(errMsg)
-		// Since upload is successful, log an error but don't return error so that backup will be considered successful
-		// return nil, nil, errors.New(errMsg)
 	}
+	p.Log.Infof("Local snapshot of PVC %s/%s taken, phase: %s. The upload of the snapshot is tracked by the backup-driver",
+		pvc.Namespace, pvc.Name, updatedSnapshot.Status.Phase)
 
 	p.Log.Infof("Persisting snapshot with snapshotID :%s under label: %s Snapshot: %v", updatedSnapshot.Status.SnapshotID, constants.ItemSnapshotLabel, updatedSnapshot)
 	// Persist the snapshot blob as an annotation of PVC
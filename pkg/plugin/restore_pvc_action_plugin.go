@@ -16,6 +16,7 @@ import (
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -141,6 +142,48 @@ func (p *NewPVCRestoreItemAction) Execute(input *velero.RestoreItemActionExecute
 		return nil, errors.WithStack(err)
 	}
 
+	// The volume of an existing PVC is only overwritten if the restore requests it
+	kubeClient, err := pluginItem.GetKubeClient(restConfig, p.Log)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	existingPVC, err := kubeClient.CoreV1().PersistentVolumeClaims(targetNamespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		p.Log.Errorf("Failed to check whether the PVC %s/%s exists: %v", targetNamespace, pvc.Name, err)
+		return nil, errors.WithStack(err)
+	}
+	restoreInPlace := err == nil
+	if restoreInPlace {
+		if !pluginItem.IsRestoreInPlace(input.Restore) {
+			p.Log.Infof("Skipping PVCRestoreItemAction for PVC %s/%s, the PVC already exists. Label the restore with %s=true to overwrite its volume in place.",
+				targetNamespace, pvc.Name, constants.RestoreInPlaceLabel)
+			return &velero.RestoreItemActionExecuteOutput{
+				UpdatedItem: item,
+			}, nil
+		}
+		clusterFlavor, err := utils.GetClusterFlavor(restConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get the cluster flavor")
+		}
+		if clusterFlavor != constants.VSphere {
+			errMsg := fmt.Sprintf("Cannot restore the PVC %s/%s in place, the in-place restore is not supported in %s", targetNamespace, pvc.Name, clusterFlavor)
+			p.Log.Error(errMsg)
+			return nil, errors.New(errMsg)
+		}
+		p.Log.Infof("Restoring the volume of the existing PVC %s/%s in place", targetNamespace, pvc.Name)
+		scaledWorkloads, err := pluginItem.PrepareInPlaceRestore(ctx, kubeClient, existingPVC, &pvc, constants.InPlaceRestoreDetachTimeout, p.Log)
+		if err != nil {
+			p.Log.WithError(err).Errorf("Failed to prepare the in-place restore of the PVC %s/%s", targetNamespace, pvc.Name)
+			return nil, err
+		}
+		// The workloads are scaled back up once the volume is restored, or failed to be restored
+		defer func() {
+			if err := pluginItem.ScaleUpWorkloads(ctx, kubeClient, scaledWorkloads, p.Log); err != nil {
+				p.Log.WithError(err).Errorf("Failed to scale the workloads using the PVC %s/%s back up after its in-place restore", targetNamespace, pvc.Name)
+			}
+		}()
+	}
+
 	// Retrieve storage class mapping information and update pvc StorageClassName with new name
 	p.Log.Info("Retrieving storage class mapping information from configMap")
 	storageClassMapping, err := pluginItem.RetrieveStorageClassMapping(restConfig, veleroNs, p.Log)
@@ -149,15 +192,34 @@ func (p *NewPVCRestoreItemAction) Execute(input *velero.RestoreItemActionExecute
 		p.Log.WithError(err).Error(errMsg)
 		return nil, errors.New(errMsg)
 	}
-	if storageClassMapping != nil && len(storageClassMapping) != 0 {
+	if storageClassMapping != nil {
 		p.Log.Info("Updating target PVC storage class based on the storage class mapping")
-		itemSnapshot, err = pluginItem.UpdateSnapshotWithNewStorageClass(restConfig, &itemSnapshot, storageClassMapping, p.Log)
+		itemSnapshot, err = pluginItem.UpdateSnapshotWithNewStorageClass(&itemSnapshot, storageClassMapping, p.Log)
 		if err != nil {
 			p.Log.Errorf("Failed to update storage class name")
 			return nil, errors.WithStack(err)
 		}
 	}
 
+	// Transform the PVC restored from the metadata, the volume of a PVC restored in place is overwritten as is
+	var pvcTransformation *pluginItem.PVCTransformation
+	if !restoreInPlace {
+		p.Log.Info("Retrieving PVC transformations from configMap")
+		pvcTransformations, err := pluginItem.RetrievePVCTransformations(restConfig, veleroNs, p.Log)
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to retrieve PVC transformations : %v", err)
+			p.Log.WithError(err).Error(errMsg)
+			return nil, errors.New(errMsg)
+		}
+		if pvcTransformations != nil {
+			itemSnapshot, pvcTransformation, err = pluginItem.UpdateSnapshotWithPVCTransformations(restConfig, &itemSnapshot, pvcTransformations, p.Log)
+			if err != nil {
+				p.Log.Errorf("Failed to transform PVC %s/%s", targetNamespace, pvc.Name)
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+
 	snapshotID := itemSnapshot.Status.SnapshotID
 	snapshotMetadata := itemSnapshot.Status.Metadata
 	apiGroup := itemSnapshot.Spec.APIGroup
@@ -183,7 +245,7 @@ func (p *NewPVCRestoreItemAction) Execute(input *velero.RestoreItemActionExecute
 
 	p.Log.Info("Creating a CloneFromSnapshot CR")
 	updatedCloneFromSnapshot, err := snapshotUtils.CloneFromSnapshopRef(ctx, backupdriverClient, snapshotID, snapshotMetadata, apiGroup, kind, targetNamespace, *backupRepository,
-		[]backupdriverv1.ClonePhase{backupdriverv1.ClonePhaseCompleted, backupdriverv1.ClonePhaseFailed}, p.Log)
+		[]backupdriverv1.ClonePhase{backupdriverv1.ClonePhaseCompleted, backupdriverv1.ClonePhaseFailed, backupdriverv1.ClonePhaseCanceled}, p.Log)
 	if err != nil {
 		p.Log.Errorf("Failed to create a CloneFromSnapshot CR: %v", err)
 		return nil, errors.WithStack(err)
@@ -192,9 +254,20 @@ func (p *NewPVCRestoreItemAction) Execute(input *velero.RestoreItemActionExecute
 		errMsg := fmt.Sprintf("Failed to create a CloneFromSnapshot CR: Phase=Failed, err=%v", updatedCloneFromSnapshot.Status.Message)
 		p.Log.Error(errMsg)
 		return nil, errors.New(errMsg)
+	} else if updatedCloneFromSnapshot.Status.Phase == backupdriverv1.ClonePhaseCanceled {
+		errMsg := fmt.Sprintf("Failed to create a CloneFromSnapshot CR: Phase=Canceled, err=%v", updatedCloneFromSnapshot.Status.Message)
+		p.Log.Error(errMsg)
+		return nil, errors.New(errMsg)
 	}
 	p.Log.Info("Restored, %v, from PVC %s/%s in the backup to PVC %s/%s", updatedCloneFromSnapshot.Status.ResourceHandle, pvc.Namespace, pvc.Name, targetNamespace, pvc.Name)
 
+	if pvcTransformation != nil {
+		if err := pluginItem.PatchRestoredPVC(ctx, kubeClient, targetNamespace, pvc.Name, pvcTransformation, p.Log); err != nil {
+			p.Log.WithError(err).Errorf("Failed to complete the transformation of the restored PVC %s/%s", targetNamespace, pvc.Name)
+			return nil, err
+		}
+	}
+
 	return &velero.RestoreItemActionExecuteOutput{
 		SkipRestore: true,
 	}, nil
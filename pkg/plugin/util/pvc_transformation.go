@@ -0,0 +1,370 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+// PVCTransformation transforms the restored PVCs, as configured by a PVC transformation ConfigMap.
+type PVCTransformation struct {
+	// Storage is the size requested by the restored PVCs, it cannot be smaller than their size in the backup. The
+	// PVCs are restored with their size in the backup and then expanded.
+	Storage *resource.Quantity `json:"storage,omitempty"`
+	// AccessModes replace the access modes of the PVCs. The volume mode of the PVCs cannot be transformed, as the
+	// volumes are restored as they were snapshotted.
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	Labels      LabelTransformation                 `json:"labels,omitempty"`
+	// Annotations are set on the restored PVCs, the annotations of the PVCs in the backup are not restored
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// LabelTransformation removes and then sets the labels of the restored PVCs.
+type LabelTransformation struct {
+	// Remove are the keys of the labels to remove, a key ending with "*" removes the labels with the prefix
+	Remove []string `json:"remove,omitempty"`
+	// Set are the labels to add or overwrite
+	Set map[string]string `json:"set,omitempty"`
+}
+
+// Validate returns the problems of the transformation.
+func (this *PVCTransformation) Validate() []string {
+	var problems []string
+	if this.Storage != nil && this.Storage.Sign() <= 0 {
+		problems = append(problems, fmt.Sprintf("storage %s is not positive", this.Storage.String()))
+	}
+	for _, accessMode := range this.AccessModes {
+		switch accessMode {
+		case corev1.ReadWriteOnce, corev1.ReadOnlyMany, corev1.ReadWriteMany:
+		default:
+			problems = append(problems, fmt.Sprintf("access mode %q is not supported", accessMode))
+		}
+	}
+	for _, key := range this.Labels.Remove {
+		// The keys ending with "*" are prefixes, which are not label keys
+		if strings.HasSuffix(key, "*") {
+			continue
+		}
+		if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+			problems = append(problems, fmt.Sprintf("label key %q to remove is invalid: %s", key, strings.Join(msgs, ", ")))
+		}
+	}
+	for key, value := range this.Labels.Set {
+		msgs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(value)...)
+		if len(msgs) > 0 {
+			problems = append(problems, fmt.Sprintf("label %s=%s is invalid: %s", key, value, strings.Join(msgs, ", ")))
+		}
+	}
+	for key := range this.Annotations {
+		if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+			problems = append(problems, fmt.Sprintf("annotation key %q is invalid: %s", key, strings.Join(msgs, ", ")))
+		}
+	}
+	return problems
+}
+
+func hasMultiNodeAccessMode(accessModes []corev1.PersistentVolumeAccessMode) bool {
+	for _, accessMode := range accessModes {
+		if accessMode == corev1.ReadOnlyMany || accessMode == corev1.ReadWriteMany {
+			return true
+		}
+	}
+	return false
+}
+
+// PVCTransformationRule transforms the PVCs in its scope, as configured by a PVC transformation ConfigMap.
+type PVCTransformationRule struct {
+	PVCScope
+	ConfigMap      string
+	Transformation PVCTransformation
+}
+
+// PVCTransformations are the transformations of the restored PVCs, configured by one or more ConfigMaps. All the
+// rules which apply to a PVC transform it, from the lowest precedence to the highest, so that the more specific rules
+// override the less specific ones: the cluster-wide rules first, then the rules scoped to namespaces, then the rules
+// scoped to a label selector, and finally the rules scoped to both namespaces and a label selector. The rules with
+// the same precedence are applied in the order of the names of their ConfigMaps.
+type PVCTransformations struct {
+	// Rules are sorted by increasing precedence
+	Rules []PVCTransformationRule
+}
+
+// NewPVCTransformations returns the PVC transformations of the PVC transformation ConfigMaps. It fails if a ConfigMap
+// is invalid.
+func NewPVCTransformations(configMaps []corev1.ConfigMap) (*PVCTransformations, error) {
+	var problems []string
+	var rules []PVCTransformationRule
+	for _, configMap := range configMaps {
+		scope, scopeProblems := NewPVCScope(&configMap)
+		problems = append(problems, scopeProblems...)
+		rule := PVCTransformationRule{
+			PVCScope:  scope,
+			ConfigMap: configMap.Name,
+		}
+		data, ok := configMap.Data[constants.PVCTransformationDataKey]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("ConfigMap %s has no %s key", configMap.Name, constants.PVCTransformationDataKey))
+			continue
+		}
+		// The volume mode is rejected with its reason rather than as an unknown field
+		var fields map[string]interface{}
+		if err := yaml.Unmarshal([]byte(data), &fields); err == nil {
+			if _, ok := fields["volumeMode"]; ok {
+				problems = append(problems, fmt.Sprintf("ConfigMap %s has an invalid transformation: volumeMode is not supported, the volumes are restored in the volume mode of the PVCs in the backup",
+					configMap.Name))
+				continue
+			}
+		}
+		if err := yaml.UnmarshalStrict([]byte(data), &rule.Transformation); err != nil {
+			problems = append(problems, fmt.Sprintf("ConfigMap %s has an invalid transformation: %v", configMap.Name, err))
+			continue
+		}
+		for _, problem := range rule.Transformation.Validate() {
+			problems = append(problems, fmt.Sprintf("ConfigMap %s has an invalid transformation: %s", configMap.Name, problem))
+		}
+		rules = append(rules, rule)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Precedence() != rules[j].Precedence() {
+			return rules[i].Precedence() < rules[j].Precedence()
+		}
+		return rules[i].ConfigMap < rules[j].ConfigMap
+	})
+	return &PVCTransformations{Rules: rules}, nil
+}
+
+// Transform applies to the PVC the labels and access modes of the rules which select it, as restored
+// from the backup. It returns the transformation merged from these rules, along with their ConfigMaps, or nil if no
+// rule selects the PVC.
+func (this *PVCTransformations) Transform(pvc *corev1.PersistentVolumeClaim) (*PVCTransformation, []string) {
+	// The rules select the PVC by its labels in the backup, not by the labels set by the other rules
+	original := pvc.DeepCopy()
+	var merged *PVCTransformation
+	var configMaps []string
+	for _, rule := range this.Rules {
+		if !rule.Matches(original) {
+			continue
+		}
+		if merged == nil {
+			merged = &PVCTransformation{}
+		}
+		configMaps = append(configMaps, rule.ConfigMap)
+		transformation := rule.Transformation
+		if transformation.Storage != nil {
+			merged.Storage = transformation.Storage
+		}
+		if len(transformation.AccessModes) > 0 {
+			merged.AccessModes = transformation.AccessModes
+			pvc.Spec.AccessModes = transformation.AccessModes
+		}
+		for _, key := range transformation.Labels.Remove {
+			for label := range pvc.Labels {
+				if label == key || strings.HasSuffix(key, "*") && strings.HasPrefix(label, strings.TrimSuffix(key, "*")) {
+					delete(pvc.Labels, label)
+				}
+			}
+		}
+		for key, value := range transformation.Labels.Set {
+			if pvc.Labels == nil {
+				pvc.Labels = make(map[string]string)
+			}
+			pvc.Labels[key] = value
+		}
+		for key, value := range transformation.Annotations {
+			if merged.Annotations == nil {
+				merged.Annotations = make(map[string]string)
+			}
+			merged.Annotations[key] = value
+		}
+	}
+	return merged, configMaps
+}
+
+// ValidateTransformedPVC checks that the transformed PVC can be restored to its storage class, nil if the storage
+// class does not exist.
+func ValidateTransformedPVC(original, pvc *corev1.PersistentVolumeClaim, transformation *PVCTransformation, storageClass *storagev1.StorageClass) error {
+	var problems []string
+	storageClassName := ""
+	if pvc.Spec.StorageClassName != nil {
+		storageClassName = *pvc.Spec.StorageClassName
+	}
+	if storageClass == nil {
+		problems = append(problems, fmt.Sprintf("the storage class %q of the PVC does not exist", storageClassName))
+	}
+	if transformation.Storage != nil {
+		size := original.Spec.Resources.Requests[corev1.ResourceStorage]
+		switch transformation.Storage.Cmp(size) {
+		case -1:
+			problems = append(problems, fmt.Sprintf("the storage %s is smaller than the size %s of the PVC in the backup",
+				transformation.Storage.String(), size.String()))
+		case 1:
+			if storageClass != nil && (storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion) {
+				problems = append(problems, fmt.Sprintf("the storage class %s does not allow volume expansion to %s",
+					storageClassName, transformation.Storage.String()))
+			}
+		}
+	}
+	if pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock && hasMultiNodeAccessMode(pvc.Spec.AccessModes) {
+		problems = append(problems, "block volumes cannot be accessed by multiple nodes")
+	}
+	// The multi-node volumes of the vSphere CSI driver are file volumes, which cannot be restored from the snapshots
+	// of block volumes
+	if storageClass != nil && storageClass.Provisioner == constants.VSphereCSIDriverName && hasMultiNodeAccessMode(pvc.Spec.AccessModes) {
+		problems = append(problems, fmt.Sprintf("the storage class %s provisions file volumes for the access modes %v, which cannot be restored from block volume snapshots",
+			storageClassName, pvc.Spec.AccessModes))
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("PVC %s/%s cannot be transformed: %s", pvc.Namespace, pvc.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// RetrievePVCTransformations retrieves the PVC transformations from the ConfigMaps in the velero namespace. It returns
+// nil if there is no PVC transformation ConfigMap.
+func RetrievePVCTransformations(config *rest.Config, veleroNs string, logger logrus.FieldLogger) (*PVCTransformations, error) {
+	clientset, err := GetKubeClient(config, logger)
+	if err != nil {
+		logger.Error("Failed to get clientset from given config")
+		return nil, err
+	}
+	opts := metav1.ListOptions{
+		// velero.io/plugin-config: ""
+		// velero-plugin-for-vsphere/pvc-transformation: RestoreItemAction
+		LabelSelector: fmt.Sprintf("%s,%s=%s", constants.PluginConfigLabelKey, constants.PVCTransformationLabelKey, constants.PluginKindRestoreItemAction),
+	}
+	configMaps, err := clientset.CoreV1().ConfigMaps(veleroNs).List(context.TODO(), opts)
+	if err != nil {
+		logger.WithError(err).Errorf("Failed to retrieve config map lists for PVC transformations")
+		return nil, err
+	}
+	if len(configMaps.Items) == 0 {
+		logger.Info("No config map for PVC transformations exists.")
+		return nil, nil
+	}
+	transformations, err := NewPVCTransformations(configMaps.Items)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid PVC transformations")
+	}
+	return transformations, nil
+}
+
+// UpdateSnapshotWithPVCTransformations transforms the PVC in the metadata of the snapshot and validates it against its
+// storage class. It returns the transformation to complete once the PVC is restored with PatchRestoredPVC, or nil if
+// no transformation applies to the PVC.
+func UpdateSnapshotWithPVCTransformations(config *rest.Config, itemSnapshot *backupdriverv1.Snapshot, transformations *PVCTransformations,
+	logger logrus.FieldLogger) (backupdriverv1.Snapshot, *PVCTransformation, error) {
+	if itemSnapshot == nil {
+		return backupdriverv1.Snapshot{}, nil, errors.New("itemSnapshot is nil, unable to update")
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := pvc.Unmarshal(itemSnapshot.Status.Metadata); err != nil {
+		logger.WithError(err).Error("Failed to unmarshal snapshotMetadata")
+		return backupdriverv1.Snapshot{}, nil, err
+	}
+	original := pvc.DeepCopy()
+	transformation, configMaps := transformations.Transform(pvc)
+	if transformation == nil {
+		logger.Infof("No PVC transformation applies to PVC %s/%s", pvc.Namespace, pvc.Name)
+		return *itemSnapshot, nil, nil
+	}
+	logger.Infof("Transforming PVC %s/%s as configured by ConfigMaps %s", pvc.Namespace, pvc.Name, strings.Join(configMaps, ", "))
+
+	var storageClass *storagev1.StorageClass
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		clientset, err := GetKubeClient(config, logger)
+		if err != nil {
+			return *itemSnapshot, nil, err
+		}
+		storageClass, err = clientset.StorageV1().StorageClasses().Get(context.TODO(), *pvc.Spec.StorageClassName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			storageClass = nil
+		} else if err != nil {
+			return *itemSnapshot, nil, errors.Wrapf(err, "failed to get the storage class %s", *pvc.Spec.StorageClassName)
+		}
+	}
+	if err := ValidateTransformedPVC(original, pvc, transformation, storageClass); err != nil {
+		logger.WithError(err).Error("Invalid PVC transformation")
+		return *itemSnapshot, nil, err
+	}
+
+	updatedSnapshotMetadata, err := pvc.Marshal()
+	if err != nil {
+		return backupdriverv1.Snapshot{}, nil, err
+	}
+	itemSnapshot.Status.Metadata = updatedSnapshotMetadata
+
+	return *itemSnapshot, transformation, nil
+}
+
+// PatchRestoredPVC expands the restored PVC to the storage of the transformation and sets its annotations.
+func PatchRestoredPVC(ctx context.Context, kubeClient kubernetes.Interface, namespace string, name string, transformation *PVCTransformation,
+	logger logrus.FieldLogger) error {
+	if transformation.Storage == nil && len(transformation.Annotations) == 0 {
+		return nil
+	}
+	pvc, err := kubeClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the restored PVC %s/%s", namespace, name)
+	}
+	patch := map[string]interface{}{}
+	if len(transformation.Annotations) > 0 {
+		patch["metadata"] = map[string]interface{}{"annotations": transformation.Annotations}
+	}
+	if size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; transformation.Storage != nil && transformation.Storage.Cmp(size) > 0 {
+		logger.Infof("Expanding the restored PVC %s/%s from %s to %s", namespace, name, size.String(), transformation.Storage.String())
+		patch["spec"] = map[string]interface{}{
+			"resources": map[string]interface{}{
+				"requests": map[string]interface{}{string(corev1.ResourceStorage): transformation.Storage.String()},
+			},
+		}
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := kubeClient.CoreV1().PersistentVolumeClaims(namespace).Patch(ctx, name, types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to patch the restored PVC %s/%s", namespace, name)
+	}
+	return nil
+}
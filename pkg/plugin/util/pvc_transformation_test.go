@@ -0,0 +1,103 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewPVCTransformationsVolumeMode(t *testing.T) {
+	configMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "block"},
+		Data:       map[string]string{constants.PVCTransformationDataKey: "accessModes:\n- ReadWriteOnce\nvolumeMode: Block\n"},
+	}
+	_, err := NewPVCTransformations([]corev1.ConfigMap{configMap})
+	require.Error(t, err)
+	assert.Equal(t, "ConfigMap block has an invalid transformation: volumeMode is not supported, the volumes are restored in the volume mode of the PVCs in the backup",
+		err.Error())
+}
+
+func TestValidateTransformedPVCAccessModes(t *testing.T) {
+	block := corev1.PersistentVolumeBlock
+	vSphereStorageClass := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "gold"},
+		Provisioner: constants.VSphereCSIDriverName,
+	}
+	otherStorageClass := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "gold"},
+		Provisioner: "other.csi.driver",
+	}
+	newPVC := func(volumeMode *corev1.PersistentVolumeMode, accessModes ...corev1.PersistentVolumeAccessMode) *corev1.PersistentVolumeClaim {
+		storageClassName := "gold"
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "data"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClassName,
+				AccessModes:      accessModes,
+				VolumeMode:       volumeMode,
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		pvc          *corev1.PersistentVolumeClaim
+		storageClass *storagev1.StorageClass
+		err          string
+	}{
+		{name: "Single node", pvc: newPVC(nil, corev1.ReadWriteOnce), storageClass: vSphereStorageClass},
+		{name: "Multi-node of another provisioner", pvc: newPVC(nil, corev1.ReadWriteMany), storageClass: otherStorageClass},
+		{
+			name:         "ReadWriteMany of the vSphere CSI driver",
+			pvc:          newPVC(nil, corev1.ReadWriteMany),
+			storageClass: vSphereStorageClass,
+			err:          "the storage class gold provisions file volumes for the access modes [ReadWriteMany], which cannot be restored from block volume snapshots",
+		},
+		{
+			name:         "ReadOnlyMany of the vSphere CSI driver",
+			pvc:          newPVC(nil, corev1.ReadWriteOnce, corev1.ReadOnlyMany),
+			storageClass: vSphereStorageClass,
+			err:          "the storage class gold provisions file volumes for the access modes [ReadWriteOnce ReadOnlyMany]",
+		},
+		{
+			name:         "Multi-node block volume",
+			pvc:          newPVC(&block, corev1.ReadOnlyMany),
+			storageClass: otherStorageClass,
+			err:          "block volumes cannot be accessed by multiple nodes",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transformation := &PVCTransformation{AccessModes: test.pvc.Spec.AccessModes}
+			original := newPVC(test.pvc.Spec.VolumeMode, corev1.ReadWriteOnce)
+			err := ValidateTransformedPVC(original, test.pvc, transformation, test.storageClass)
+			if test.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
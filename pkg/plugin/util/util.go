@@ -16,7 +16,16 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 func GetSnapshotFromPVCAnnotation(snapshotAnnotation string, itemSnapshot interface{}) error {
@@ -179,7 +188,194 @@ func GetKubeClient(config *rest.Config, logger logrus.FieldLogger) (*kubernetes.
 	return clientset, nil
 }
 
-func RetrieveStorageClassMapping(config *rest.Config, veleroNs string, logger logrus.FieldLogger) (map[string]string, error) {
+// PVCScope selects the PVCs which a plugin config ConfigMap applies to, as configured by the annotations of the
+// ConfigMap.
+type PVCScope struct {
+	// Namespaces are the namespaces which the PVCs are restored to, all the namespaces if empty
+	Namespaces map[string]bool
+	// Selector selects the PVCs by their labels, all the PVCs if nil
+	Selector labels.Selector
+}
+
+// NewPVCScope returns the scope of the ConfigMap, along with the problems of its annotations.
+func NewPVCScope(configMap *corev1.ConfigMap) (PVCScope, []string) {
+	var problems []string
+	scope := PVCScope{Namespaces: make(map[string]bool)}
+	if namespaces, ok := configMap.Annotations[constants.PluginConfigNamespacesAnnotation]; ok {
+		for _, namespace := range strings.Split(namespaces, ",") {
+			namespace = strings.TrimSpace(namespace)
+			if msgs := validation.IsDNS1123Label(namespace); len(msgs) > 0 {
+				problems = append(problems, fmt.Sprintf("ConfigMap %s has the invalid namespace %q in its annotation %s: %s",
+					configMap.Name, namespace, constants.PluginConfigNamespacesAnnotation, strings.Join(msgs, ", ")))
+				continue
+			}
+			scope.Namespaces[namespace] = true
+		}
+	}
+	if selector, ok := configMap.Annotations[constants.PluginConfigPVCSelectorAnnotation]; ok {
+		var err error
+		if scope.Selector, err = labels.Parse(selector); err != nil {
+			problems = append(problems, fmt.Sprintf("ConfigMap %s has the invalid label selector %q in its annotation %s: %v",
+				configMap.Name, selector, constants.PluginConfigPVCSelectorAnnotation, err))
+		}
+	}
+	return scope, problems
+}
+
+// Precedence returns the precedence of the scope, the more specific scopes have the higher precedences: the scopes
+// with both namespaces and a label selector, then with a label selector, then with namespaces, and finally the
+// cluster-wide scopes.
+func (this *PVCScope) Precedence() int {
+	precedence := 0
+	if this.Selector != nil {
+		precedence += 2
+	}
+	if len(this.Namespaces) > 0 {
+		precedence++
+	}
+	return precedence
+}
+
+// String returns a description of the scope, the scopes with the same description select the same PVCs.
+func (this *PVCScope) String() string {
+	var scope []string
+	if len(this.Namespaces) > 0 {
+		var namespaces []string
+		for namespace := range this.Namespaces {
+			namespaces = append(namespaces, namespace)
+		}
+		sort.Strings(namespaces)
+		scope = append(scope, "namespaces "+strings.Join(namespaces, ","))
+	}
+	if this.Selector != nil {
+		scope = append(scope, fmt.Sprintf("PVCs matching %q", this.Selector.String()))
+	}
+	if len(scope) == 0 {
+		return "all PVCs"
+	}
+	return strings.Join(scope, " and ")
+}
+
+// Overlaps returns whether the scope may select the same PVCs as the other scope with the same precedence: the scopes
+// overlap unless they are scoped to disjoint sets of namespaces. The label selectors are assumed to overlap, as whether
+// two label selectors match the same PVC depends on the labels of the PVC.
+func (this *PVCScope) Overlaps(other *PVCScope) bool {
+	if len(this.Namespaces) == 0 || len(other.Namespaces) == 0 {
+		return true
+	}
+	for namespace := range this.Namespaces {
+		if other.Namespaces[namespace] {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns whether the scope selects the PVC, in the namespace which it is restored to.
+func (this *PVCScope) Matches(pvc *corev1.PersistentVolumeClaim) bool {
+	if len(this.Namespaces) > 0 && !this.Namespaces[pvc.Namespace] {
+		return false
+	}
+	return this.Selector == nil || this.Selector.Matches(labels.Set(pvc.Labels))
+}
+
+// StorageClassMappingRule maps the storage classes of the PVCs in its scope, as configured by a storage class mapping
+// ConfigMap.
+type StorageClassMappingRule struct {
+	PVCScope
+	ConfigMap string
+	// Mapping maps the old storage classes to the new ones
+	Mapping map[string]string
+}
+
+// StorageClassMapping is the storage class mapping of the restores, configured by one or more ConfigMaps. The rule
+// which applies to a PVC is the one with the highest precedence which maps its storage class: the rules scoped to
+// both namespaces and a label selector come first, then the rules scoped to a label selector, then the rules scoped
+// to namespaces, and finally the cluster-wide rules.
+type StorageClassMapping struct {
+	// Rules are sorted by decreasing precedence
+	Rules []StorageClassMappingRule
+}
+
+// NewStorageClassMapping returns the storage class mapping of the storage class mapping ConfigMaps. It fails if a
+// ConfigMap is invalid, or if ConfigMaps with the same precedence and overlapping scopes map the same storage class to
+// different ones, e.g. ConfigMaps scoped to different label selectors, or to sets of namespaces which intersect.
+func NewStorageClassMapping(configMaps []corev1.ConfigMap) (*StorageClassMapping, error) {
+	var problems []string
+	var rules []StorageClassMappingRule
+	for _, configMap := range configMaps {
+		scope, scopeProblems := NewPVCScope(&configMap)
+		problems = append(problems, scopeProblems...)
+		rule := StorageClassMappingRule{
+			PVCScope:  scope,
+			ConfigMap: configMap.Name,
+			Mapping:   configMap.Data,
+		}
+		if len(rule.Mapping) == 0 {
+			problems = append(problems, fmt.Sprintf("ConfigMap %s has no storage class mapping", configMap.Name))
+		}
+		rules = append(rules, rule)
+	}
+	// The ConfigMaps with the same precedence are ordered by name, so that the conflicts are reported consistently
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Precedence() != rules[j].Precedence() {
+			return rules[i].Precedence() > rules[j].Precedence()
+		}
+		return rules[i].ConfigMap < rules[j].ConfigMap
+	})
+	for i := range rules {
+		for j := i + 1; j < len(rules); j++ {
+			if rules[i].Precedence() != rules[j].Precedence() || !rules[i].Overlaps(&rules[j].PVCScope) {
+				continue
+			}
+			for oldName, newName := range rules[i].Mapping {
+				if otherName, ok := rules[j].Mapping[oldName]; ok && otherName != newName {
+					problems = append(problems, fmt.Sprintf("ConfigMaps %s and %s may both apply to a PVC, of the %s and of the %s, and map its storage class %s to %s and %s, narrow their scopes",
+						rules[i].ConfigMap, rules[j].ConfigMap, rules[i].PVCScope.String(), rules[j].PVCScope.String(), oldName, newName, otherName))
+				}
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return &StorageClassMapping{Rules: rules}, nil
+}
+
+// Validate checks that the new storage classes of the mapping exist.
+func (this *StorageClassMapping) Validate(storageClasses map[string]bool) error {
+	var problems []string
+	for _, rule := range this.Rules {
+		for oldName, newName := range rule.Mapping {
+			if !storageClasses[newName] {
+				problems = append(problems, fmt.Sprintf("ConfigMap %s maps the storage class %s to the storage class %s which does not exist",
+					rule.ConfigMap, oldName, newName))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// GetStorageClass returns the new storage class of the PVC, along with the ConfigMap which maps it, or "" if the
+// storage class of the PVC is not mapped. The rules with the same precedence which apply to the PVC map its storage
+// class to the same one, see NewStorageClassMapping.
+func (this *StorageClassMapping) GetStorageClass(pvc *corev1.PersistentVolumeClaim, oldName string) (string, string) {
+	for _, rule := range this.Rules {
+		if newName, ok := rule.Mapping[oldName]; ok && rule.Matches(pvc) {
+			return newName, rule.ConfigMap
+		}
+	}
+	return "", ""
+}
+
+// RetrieveStorageClassMapping retrieves and validates the storage class mapping from the ConfigMaps in the velero
+// namespace. It returns nil if there is no storage class mapping ConfigMap.
+func RetrieveStorageClassMapping(config *rest.Config, veleroNs string, logger logrus.FieldLogger) (*StorageClassMapping, error) {
 	clientset, err := GetKubeClient(config, logger)
 	if err != nil {
 		logger.Error("Failed to get clientset from given config")
@@ -188,7 +384,7 @@ func RetrieveStorageClassMapping(config *rest.Config, veleroNs string, logger lo
 	opts := metav1.ListOptions{
 		// velero.io/plugin-config: ""
 		// velero.io/change-storage-class: RestoreItemAction
-		LabelSelector: fmt.Sprintf("%s,%s=%s", constants.PluginConfigLabelKey,constants.ChangeStorageClassLabelKey,constants.PluginKindRestoreItemAction),
+		LabelSelector: fmt.Sprintf("%s,%s=%s", constants.PluginConfigLabelKey, constants.ChangeStorageClassLabelKey, constants.PluginKindRestoreItemAction),
 	}
 	configMaps, err := clientset.CoreV1().ConfigMaps(veleroNs).List(context.TODO(), opts)
 	if err != nil {
@@ -199,18 +395,26 @@ func RetrieveStorageClassMapping(config *rest.Config, veleroNs string, logger lo
 		logger.Info("No config map for storage class mapping exists.")
 		return nil, nil
 	}
-	if len(configMaps.Items) > 1 {
-		var items []string
-		for _, item := range configMaps.Items {
-			items = append(items, item.Name)
-		}
-		return nil, errors.Errorf("found more than one ConfigMap matching label selector %q: %v", opts.LabelSelector, items)
-	}
 
-	return configMaps.Items[0].Data, nil
+	storageClassMapping, err := NewStorageClassMapping(configMaps.Items)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid storage class mapping")
+	}
+	storageClassList, err := clientset.StorageV1().StorageClasses().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing the storage classes from API")
+	}
+	storageClasses := make(map[string]bool)
+	for _, storageClass := range storageClassList.Items {
+		storageClasses[storageClass.Name] = true
+	}
+	if err := storageClassMapping.Validate(storageClasses); err != nil {
+		return nil, errors.Wrap(err, "invalid storage class mapping")
+	}
+	return storageClassMapping, nil
 }
 
-func UpdateSnapshotWithNewStorageClass(config *rest.Config, itemSnapshot *backupdriverv1.Snapshot, storageClassMapping map[string]string, logger logrus.FieldLogger) (backupdriverv1.Snapshot, error) {
+func UpdateSnapshotWithNewStorageClass(itemSnapshot *backupdriverv1.Snapshot, storageClassMapping *StorageClassMapping, logger logrus.FieldLogger) (backupdriverv1.Snapshot, error) {
 	if itemSnapshot == nil {
 		return backupdriverv1.Snapshot{}, errors.New("itemSnapshot is nil, unable to update")
 	}
@@ -225,36 +429,23 @@ func UpdateSnapshotWithNewStorageClass(config *rest.Config, itemSnapshot *backup
 	}
 
 	// update the PVC storage class
-	var old string
-	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
-		_, ok := storageClassMapping[constants.EmptyStorageClass]
-		if !ok {
+	old := constants.EmptyStorageClass
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		old = *pvc.Spec.StorageClassName
+	}
+	logger.Infof("Updating storage class name for old storage class: %s", old)
+	newName, configMap := storageClassMapping.GetStorageClass(pvc, old)
+	if newName == "" {
+		if old == constants.EmptyStorageClass {
 			errMsg := fmt.Sprintf("PVC %s/%s has no storage class, unable to restore", pvc.Namespace, pvc.Name)
 			logger.Error(errMsg)
 			return *itemSnapshot, errors.New(errMsg)
 		}
-		old = constants.EmptyStorageClass
-	} else {
-		old = *pvc.Spec.StorageClassName
-	}
-	logger.Infof("Updating storage class name for old storage class: %s", old)
-	newName, ok := storageClassMapping[old]
-	if !ok {
 		logger.Infof("No mapping found for storage class %s", old)
 		return *itemSnapshot, nil
 	}
 
-	// validate that new storage class exists
-	clientset, err := GetKubeClient(config, logger)
-	if err != nil {
-		logger.Error("Failed to get core v1 client from given config")
-		return *itemSnapshot, err
-	}
-	if _, err := clientset.StorageV1().StorageClasses().Get(context.TODO(), newName, metav1.GetOptions{}); err != nil {
-		return *itemSnapshot, errors.Wrapf(err, "error getting storage class %s from API", newName)
-	}
-
-	logger.Infof("Updating item's storage class name to %s", newName)
+	logger.Infof("Updating item's storage class name to %s as mapped by ConfigMap %s", newName, configMap)
 	pvc.Spec.StorageClassName = &newName
 
 	var updatedSnapshotMetadata []byte
@@ -264,4 +455,381 @@ func UpdateSnapshotWithNewStorageClass(config *rest.Config, itemSnapshot *backup
 	itemSnapshot.Status.Metadata = updatedSnapshotMetadata
 
 	return *itemSnapshot, nil
-}
\ No newline at end of file
+}
+
+// IsRestoreInPlace returns whether the restore overwrites the volumes of the existing PVCs in place, as requested
+// with the restore-in-place label of the restore.
+func IsRestoreInPlace(restore *velerov1.Restore) bool {
+	inPlace, err := strconv.ParseBool(restore.Labels[constants.RestoreInPlaceLabel])
+	return err == nil && inPlace
+}
+
+// PrepareInPlaceRestore checks that the volume of the existing PVC can be overwritten in place with the snapshot of
+// the backed up PVC: the existing PVC must be bound to a vSphere CSI volume at least as large as the backed up PVC.
+// It then scales down the Deployments and StatefulSets of the pods using the existing PVC, and waits up to the timeout
+// for the pods to be gone and for the volume to be detached. It fails if the volume is still in use, e.g. by a pod
+// which is not managed by a Deployment or a StatefulSet, in which case the workloads are scaled back up right away.
+// The scaled down workloads are returned, to be scaled back up with ScaleUpWorkloads once the volume is restored.
+func PrepareInPlaceRestore(ctx context.Context, kubeClient kubernetes.Interface, existing *corev1.PersistentVolumeClaim,
+	backedUp *corev1.PersistentVolumeClaim, timeout time.Duration, logger logrus.FieldLogger) ([]ScaledWorkload, error) {
+	pvcName := fmt.Sprintf("%s/%s", existing.Namespace, existing.Name)
+	if existing.DeletionTimestamp != nil {
+		return nil, errors.Errorf("Cannot restore the PVC %s in place, it is being deleted", pvcName)
+	}
+	if existing.Status.Phase != corev1.ClaimBound || existing.Spec.VolumeName == "" {
+		return nil, errors.Errorf("Cannot restore the PVC %s in place, it is %s instead of Bound", pvcName, existing.Status.Phase)
+	}
+	pv, err := kubeClient.CoreV1().PersistentVolumes().Get(ctx, existing.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get the PV %s of the PVC %s", existing.Spec.VolumeName, pvcName)
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != constants.VSphereCSIDriverName {
+		return nil, errors.Errorf("Cannot restore the PVC %s in place, its PV %s is not a volume of the vSphere CSI driver", pvcName, pv.Name)
+	}
+	existingSize := existing.Status.Capacity[corev1.ResourceStorage]
+	backedUpSize := backedUp.Spec.Resources.Requests[corev1.ResourceStorage]
+	if existingSize.Cmp(backedUpSize) < 0 {
+		return nil, errors.Errorf("Cannot restore the PVC %s in place, its size %s is smaller than the size %s of the backed up PVC, expand the PVC first",
+			pvcName, existingSize.String(), backedUpSize.String())
+	}
+
+	workloads, err := scaleDownWorkloads(ctx, kubeClient, existing, logger)
+	if err == nil {
+		var users []string
+		err = wait.PollImmediate(5*time.Second, timeout, func() (bool, error) {
+			users, err = getVolumeUsers(ctx, kubeClient, existing, pv.Name)
+			if err != nil {
+				return false, err
+			}
+			if len(users) > 0 {
+				logger.Infof("Waiting for the volume of the PVC %s to be detached, it is in use by %s", pvcName, strings.Join(users, ", "))
+				return false, nil
+			}
+			return true, nil
+		})
+		if err == wait.ErrWaitTimeout {
+			err = errors.Errorf("Cannot restore the PVC %s in place, its volume is still in use by %s after %v, stop the pods using the PVC which are not managed by a Deployment or a StatefulSet and retry the restore",
+				pvcName, strings.Join(users, ", "), timeout)
+		}
+	}
+	if err != nil {
+		if scaleErr := ScaleUpWorkloads(ctx, kubeClient, workloads, logger); scaleErr != nil {
+			logger.WithError(scaleErr).Errorf("Failed to scale the workloads using the PVC %s back up", pvcName)
+		}
+		return nil, err
+	}
+	return workloads, nil
+}
+
+// ScaledWorkload is a Deployment or a StatefulSet scaled down for the in-place restore of a PVC which its pods use. Its
+// replicas before the restore are recorded in its InPlaceRestoreReplicasAnnotation until it is scaled back up.
+type ScaledWorkload struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (this ScaledWorkload) String() string {
+	return fmt.Sprintf("%s %s/%s", this.Kind, this.Namespace, this.Name)
+}
+
+// scaleDownWorkloads scales down the Deployments and StatefulSets of the pods which use the PVC. The workloads which
+// have been scaled down are returned along with any error.
+func scaleDownWorkloads(ctx context.Context, kubeClient kubernetes.Interface, pvc *corev1.PersistentVolumeClaim,
+	logger logrus.FieldLogger) ([]ScaledWorkload, error) {
+	pods, err := getPodsUsingPVC(ctx, kubeClient, pvc)
+	if err != nil {
+		return nil, err
+	}
+	var workloads []ScaledWorkload
+	seen := make(map[ScaledWorkload]bool)
+	for _, pod := range pods {
+		workload, ok, err := getPodWorkload(ctx, kubeClient, &pod)
+		if err != nil {
+			return workloads, err
+		}
+		if !ok || seen[workload] {
+			continue
+		}
+		seen[workload] = true
+		replicas, annotations, err := getWorkloadReplicas(ctx, kubeClient, workload)
+		if err != nil {
+			return workloads, err
+		}
+		patch := map[string]interface{}{
+			"spec": map[string]interface{}{"replicas": 0},
+		}
+		// The replicas recorded by an earlier restore which failed to scale the workload back up are kept
+		if _, ok := annotations[constants.InPlaceRestoreReplicasAnnotation]; !ok {
+			patch["metadata"] = map[string]interface{}{
+				"annotations": map[string]string{constants.InPlaceRestoreReplicasAnnotation: strconv.Itoa(int(replicas))},
+			}
+		}
+		logger.Infof("Scaling down the %s from %d replicas for the in-place restore of the PVC %s/%s", workload.String(), replicas, pvc.Namespace, pvc.Name)
+		if err := patchWorkload(ctx, kubeClient, workload, patch); err != nil {
+			return workloads, errors.Wrapf(err, "Failed to scale down the %s", workload.String())
+		}
+		workloads = append(workloads, workload)
+	}
+	return workloads, nil
+}
+
+// ScaleUpWorkloads scales the workloads scaled down for an in-place restore back to their replicas before the restore,
+// unless their replicas have been changed since.
+func ScaleUpWorkloads(ctx context.Context, kubeClient kubernetes.Interface, workloads []ScaledWorkload, logger logrus.FieldLogger) error {
+	var failed []string
+	for _, workload := range workloads {
+		if err := scaleUpWorkload(ctx, kubeClient, workload, logger); err != nil {
+			logger.WithError(err).Errorf("Failed to scale up the %s", workload.String())
+			failed = append(failed, workload.String())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("Failed to scale up %s, scale them up to the replicas in their annotation %s", strings.Join(failed, ", "),
+			constants.InPlaceRestoreReplicasAnnotation)
+	}
+	return nil
+}
+
+func scaleUpWorkload(ctx context.Context, kubeClient kubernetes.Interface, workload ScaledWorkload, logger logrus.FieldLogger) error {
+	replicas, annotations, err := getWorkloadReplicas(ctx, kubeClient, workload)
+	if err != nil {
+		return err
+	}
+	recorded, ok := annotations[constants.InPlaceRestoreReplicasAnnotation]
+	if !ok {
+		return nil
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{constants.InPlaceRestoreReplicasAnnotation: nil},
+		},
+	}
+	if replicas == 0 {
+		recordedReplicas, err := strconv.Atoi(recorded)
+		if err != nil {
+			return errors.Errorf("Invalid replicas %q in the annotation %s", recorded, constants.InPlaceRestoreReplicasAnnotation)
+		}
+		patch["spec"] = map[string]interface{}{"replicas": recordedReplicas}
+		logger.Infof("Scaling the %s back up to %d replicas", workload.String(), recordedReplicas)
+	}
+	return patchWorkload(ctx, kubeClient, workload, patch)
+}
+
+// getPodWorkload returns the Deployment or the StatefulSet which manages the pod, or false if the pod is managed by
+// neither.
+func getPodWorkload(ctx context.Context, kubeClient kubernetes.Interface, pod *corev1.Pod) (ScaledWorkload, bool, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return ScaledWorkload{}, false, nil
+	}
+	switch owner.Kind {
+	case "StatefulSet":
+		return ScaledWorkload{Kind: owner.Kind, Namespace: pod.Namespace, Name: owner.Name}, true, nil
+	case "ReplicaSet":
+		replicaSet, err := kubeClient.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return ScaledWorkload{}, false, errors.Wrapf(err, "Failed to get the ReplicaSet %s/%s of the pod %s", pod.Namespace, owner.Name, pod.Name)
+		}
+		if replicaSetOwner := metav1.GetControllerOf(replicaSet); replicaSetOwner != nil && replicaSetOwner.Kind == "Deployment" {
+			return ScaledWorkload{Kind: replicaSetOwner.Kind, Namespace: pod.Namespace, Name: replicaSetOwner.Name}, true, nil
+		}
+	}
+	return ScaledWorkload{}, false, nil
+}
+
+// getWorkloadReplicas returns the replicas and the annotations of the workload.
+func getWorkloadReplicas(ctx context.Context, kubeClient kubernetes.Interface, workload ScaledWorkload) (int32, map[string]string, error) {
+	var replicas *int32
+	var annotations map[string]string
+	switch workload.Kind {
+	case "Deployment":
+		deployment, err := kubeClient.AppsV1().Deployments(workload.Namespace).Get(ctx, workload.Name, metav1.GetOptions{})
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "Failed to get the %s", workload.String())
+		}
+		replicas, annotations = deployment.Spec.Replicas, deployment.Annotations
+	case "StatefulSet":
+		statefulSet, err := kubeClient.AppsV1().StatefulSets(workload.Namespace).Get(ctx, workload.Name, metav1.GetOptions{})
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "Failed to get the %s", workload.String())
+		}
+		replicas, annotations = statefulSet.Spec.Replicas, statefulSet.Annotations
+	default:
+		return 0, nil, errors.Errorf("Cannot scale the %s", workload.String())
+	}
+	// The replicas default to 1
+	if replicas == nil {
+		return 1, annotations, nil
+	}
+	return *replicas, annotations, nil
+}
+
+func patchWorkload(ctx context.Context, kubeClient kubernetes.Interface, workload ScaledWorkload, patch map[string]interface{}) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	switch workload.Kind {
+	case "Deployment":
+		_, err = kubeClient.AppsV1().Deployments(workload.Namespace).Patch(ctx, workload.Name, types.MergePatchType, data, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = kubeClient.AppsV1().StatefulSets(workload.Namespace).Patch(ctx, workload.Name, types.MergePatchType, data, metav1.PatchOptions{})
+	default:
+		err = errors.Errorf("Cannot scale the %s", workload.String())
+	}
+	return err
+}
+
+// getPodsUsingPVC returns the pods which use the PVC and have not terminated.
+func getPodsUsingPVC(ctx context.Context, kubeClient kubernetes.Interface, pvc *corev1.PersistentVolumeClaim) ([]corev1.Pod, error) {
+	pods, err := kubeClient.CoreV1().Pods(pvc.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list the pods in the namespace %s", pvc.Namespace)
+	}
+	var users []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc.Name {
+				users = append(users, pod)
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+// getVolumeUsers returns the pods which use the PVC and the nodes to which its PV is attached.
+func getVolumeUsers(ctx context.Context, kubeClient kubernetes.Interface, pvc *corev1.PersistentVolumeClaim, pvName string) ([]string, error) {
+	var users []string
+	pods, err := getPodsUsingPVC(ctx, kubeClient, pvc)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		users = append(users, "pod "+pod.Name)
+	}
+	attachments, err := kubeClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list the VolumeAttachments")
+	}
+	for _, attachment := range attachments.Items {
+		if attachment.Spec.Source.PersistentVolumeName != nil && *attachment.Spec.Source.PersistentVolumeName == pvName {
+			users = append(users, "node "+attachment.Spec.NodeName)
+		}
+	}
+	return users, nil
+}
+
+// SnapshotFilter selects the PVCs whose volumes are snapshotted in the backups.
+type SnapshotFilter struct {
+	IncludeStorageClasses map[string]bool
+	ExcludeStorageClasses map[string]bool
+	IncludeSelector       labels.Selector
+	ExcludeSelector       labels.Selector
+}
+
+// RetrieveSnapshotFilter retrieves the snapshot filter from the ConfigMap in the velero namespace. It returns nil if
+// there is no such ConfigMap.
+func RetrieveSnapshotFilter(config *rest.Config, veleroNs string, logger logrus.FieldLogger) (*SnapshotFilter, error) {
+	clientset, err := GetKubeClient(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	opts := metav1.ListOptions{
+		// velero.io/plugin-config: ""
+		// velero-plugin-for-vsphere/snapshot-filter: BackupItemAction
+		LabelSelector: fmt.Sprintf("%s,%s=%s", constants.PluginConfigLabelKey, constants.SnapshotFilterLabelKey, constants.PluginKindBackupItemAction),
+	}
+	configMaps, err := clientset.CoreV1().ConfigMaps(veleroNs).List(context.TODO(), opts)
+	if err != nil {
+		logger.WithError(err).Errorf("Failed to retrieve config map lists for snapshot filter")
+		return nil, err
+	}
+	if len(configMaps.Items) == 0 {
+		return nil, nil
+	}
+	if len(configMaps.Items) > 1 {
+		var items []string
+		for _, item := range configMaps.Items {
+			items = append(items, item.Name)
+		}
+		return nil, errors.Errorf("found more than one ConfigMap matching label selector %q: %v", opts.LabelSelector, items)
+	}
+	filter, err := NewSnapshotFilter(configMaps.Items[0].Data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid snapshot filter in the ConfigMap %s/%s", veleroNs, configMaps.Items[0].Name)
+	}
+	return filter, nil
+}
+
+// NewSnapshotFilter returns the snapshot filter of the data of the snapshot filter ConfigMap.
+func NewSnapshotFilter(data map[string]string) (*SnapshotFilter, error) {
+	filter := &SnapshotFilter{
+		IncludeStorageClasses: parseStorageClasses(data[constants.SnapshotFilterIncludeStorageClasses]),
+		ExcludeStorageClasses: parseStorageClasses(data[constants.SnapshotFilterExcludeStorageClasses]),
+	}
+	var err error
+	if selector, ok := data[constants.SnapshotFilterIncludeLabelSelector]; ok {
+		if filter.IncludeSelector, err = labels.Parse(selector); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s %q", constants.SnapshotFilterIncludeLabelSelector, selector)
+		}
+	}
+	if selector, ok := data[constants.SnapshotFilterExcludeLabelSelector]; ok {
+		if filter.ExcludeSelector, err = labels.Parse(selector); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s %q", constants.SnapshotFilterExcludeLabelSelector, selector)
+		}
+	}
+	return filter, nil
+}
+
+func parseStorageClasses(storageClasses string) map[string]bool {
+	parsed := make(map[string]bool)
+	for _, storageClass := range strings.Split(storageClasses, ",") {
+		if storageClass = strings.TrimSpace(storageClass); storageClass != "" {
+			parsed[storageClass] = true
+		}
+	}
+	return parsed
+}
+
+// ShouldSnapshotPVC returns whether the volume of the PVC is snapshotted, along with the reason why it is not. The
+// skip-snapshot annotation of the PVC takes precedence over the filter, which may be nil.
+func ShouldSnapshotPVC(pvc *corev1.PersistentVolumeClaim, filter *SnapshotFilter, logger logrus.FieldLogger) (bool, string) {
+	if value, ok := pvc.Annotations[constants.SkipSnapshotAnnotation]; ok {
+		skip, err := strconv.ParseBool(value)
+		if err != nil {
+			logger.Warnf("Ignoring the invalid annotation %s=%s of the PVC %s/%s, the value must be true or false",
+				constants.SkipSnapshotAnnotation, value, pvc.Namespace, pvc.Name)
+		} else if skip {
+			return false, fmt.Sprintf("the PVC is annotated with %s=%s", constants.SkipSnapshotAnnotation, value)
+		} else {
+			return true, ""
+		}
+	}
+	if filter == nil {
+		return true, ""
+	}
+
+	storageClass := constants.EmptyStorageClass
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		storageClass = *pvc.Spec.StorageClassName
+	}
+	if filter.ExcludeStorageClasses[storageClass] {
+		return false, fmt.Sprintf("the storage class %s is in the %s of the snapshot filter", storageClass, constants.SnapshotFilterExcludeStorageClasses)
+	}
+	if len(filter.IncludeStorageClasses) > 0 && !filter.IncludeStorageClasses[storageClass] {
+		return false, fmt.Sprintf("the storage class %s is not in the %s of the snapshot filter", storageClass, constants.SnapshotFilterIncludeStorageClasses)
+	}
+	pvcLabels := labels.Set(pvc.Labels)
+	if filter.ExcludeSelector != nil && !filter.ExcludeSelector.Empty() && filter.ExcludeSelector.Matches(pvcLabels) {
+		return false, fmt.Sprintf("the labels of the PVC match the %s %q of the snapshot filter", constants.SnapshotFilterExcludeLabelSelector, filter.ExcludeSelector.String())
+	}
+	if filter.IncludeSelector != nil && !filter.IncludeSelector.Matches(pvcLabels) {
+		return false, fmt.Sprintf("the labels of the PVC do not match the %s %q of the snapshot filter", constants.SnapshotFilterIncludeLabelSelector, filter.IncludeSelector.String())
+	}
+	return true, ""
+}
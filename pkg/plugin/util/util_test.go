@@ -0,0 +1,662 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newPVC(name string, size string, phase corev1.PersistentVolumeClaimPhase, volumeName string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: name},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName: volumeName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase:    phase,
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+		},
+	}
+}
+
+func newPV(name string, driver string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: "volume-" + name},
+			},
+		},
+	}
+}
+
+func newPod(name string, claimName string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: name},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func newVolumeAttachment(name string, pvName string, nodeName string) *storagev1.VolumeAttachment {
+	return &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Attacher: constants.VSphereCSIDriverName,
+			NodeName: nodeName,
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+		},
+	}
+}
+
+func newOwnedPod(name string, claimName string, ownerKind string, ownerName string) *corev1.Pod {
+	pod := newPod(name, claimName, corev1.PodRunning)
+	controller := true
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: ownerName, Controller: &controller}}
+	return pod
+}
+
+func newDeployment(name string, replicas int32, annotations map[string]string) (*appsv1.Deployment, *appsv1.ReplicaSet) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: name, Annotations: annotations},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+	controller := true
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "app",
+			Name:            name + "-0123abcd",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: name, Controller: &controller}},
+		},
+	}
+	return deployment, replicaSet
+}
+
+func newStatefulSet(name string, replicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: name},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+	}
+}
+
+// stopPodsOnScaleDown removes the pods and the VolumeAttachments once a workload is scaled down, as its controller,
+// the scheduler and the attacher would.
+func stopPodsOnScaleDown(t *testing.T, kubeClient *fake.Clientset, pods []string, attachments []string) {
+	reactor := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		for _, pod := range pods {
+			require.NoError(t, kubeClient.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), "app", pod))
+		}
+		for _, attachment := range attachments {
+			require.NoError(t, kubeClient.Tracker().Delete(storagev1.SchemeGroupVersion.WithResource("volumeattachments"), "", attachment))
+		}
+		pods, attachments = nil, nil
+		return false, nil, nil
+	}
+	kubeClient.PrependReactor("patch", "deployments", reactor)
+	kubeClient.PrependReactor("patch", "statefulsets", reactor)
+}
+
+func TestGetVolumeUsers(t *testing.T) {
+	pvc := newPVC("data", "1Gi", corev1.ClaimBound, "pv-1")
+	kubeClient := fake.NewSimpleClientset(
+		newPod("app-1", "data", corev1.PodRunning),
+		newPod("app-2", "data", corev1.PodPending),
+		// The completed pods and the pods of the other PVCs do not use the volume
+		newPod("job-1", "data", corev1.PodSucceeded),
+		newPod("other-1", "other", corev1.PodRunning),
+		newVolumeAttachment("attachment-1", "pv-1", "node-1"),
+		newVolumeAttachment("attachment-2", "pv-2", "node-2"),
+	)
+	users, err := getVolumeUsers(context.Background(), kubeClient, pvc, "pv-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"pod app-1", "pod app-2", "node node-1"}, users)
+}
+
+func TestPrepareInPlaceRestore(t *testing.T) {
+	deleting := newPVC("data", "1Gi", corev1.ClaimBound, "pv-1")
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+
+	tests := []struct {
+		name     string
+		existing *corev1.PersistentVolumeClaim
+		backedUp *corev1.PersistentVolumeClaim
+		objects  []runtime.Object
+		err      string
+	}{
+		{
+			name:     "Detached volume",
+			existing: newPVC("data", "2Gi", corev1.ClaimBound, "pv-1"),
+			backedUp: newPVC("data", "1Gi", corev1.ClaimBound, "pv-0"),
+			objects:  []runtime.Object{newPV("pv-1", constants.VSphereCSIDriverName), newPod("job-1", "data", corev1.PodSucceeded)},
+		},
+		{
+			name:     "PVC being deleted",
+			existing: deleting,
+			backedUp: newPVC("data", "1Gi", corev1.ClaimBound, "pv-0"),
+			err:      "it is being deleted",
+		},
+		{
+			name:     "Pending PVC",
+			existing: newPVC("data", "1Gi", corev1.ClaimPending, ""),
+			backedUp: newPVC("data", "1Gi", corev1.ClaimBound, "pv-0"),
+			err:      "it is Pending instead of Bound",
+		},
+		{
+			name:     "Missing PV",
+			existing: newPVC("data", "1Gi", corev1.ClaimBound, "pv-1"),
+			backedUp: newPVC("data", "1Gi", corev1.ClaimBound, "pv-0"),
+			err:      "Failed to get the PV pv-1 of the PVC app/data",
+		},
+		{
+			name:     "PV of another driver",
+			existing: newPVC("data", "1Gi", corev1.ClaimBound, "pv-1"),
+			backedUp: newPVC("data", "1Gi", corev1.ClaimBound, "pv-0"),
+			objects:  []runtime.Object{newPV("pv-1", "other.csi.driver")},
+			err:      "is not a volume of the vSphere CSI driver",
+		},
+		{
+			name:     "Smaller volume",
+			existing: newPVC("data", "1Gi", corev1.ClaimBound, "pv-1"),
+			backedUp: newPVC("data", "2Gi", corev1.ClaimBound, "pv-0"),
+			objects:  []runtime.Object{newPV("pv-1", constants.VSphereCSIDriverName)},
+			err:      "its size 1Gi is smaller than the size 2Gi of the backed up PVC",
+		},
+		{
+			name:     "Volume in use",
+			existing: newPVC("data", "1Gi", corev1.ClaimBound, "pv-1"),
+			backedUp: newPVC("data", "1Gi", corev1.ClaimBound, "pv-0"),
+			objects: []runtime.Object{
+				newPV("pv-1", constants.VSphereCSIDriverName),
+				newPod("app-1", "data", corev1.PodRunning),
+				newVolumeAttachment("attachment-1", "pv-1", "node-1"),
+			},
+			err: "its volume is still in use by pod app-1, node node-1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset(test.objects...)
+			workloads, err := PrepareInPlaceRestore(context.Background(), kubeClient, test.existing, test.backedUp, 10*time.Millisecond, logrus.New())
+			assert.Empty(t, workloads)
+			if test.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestPrepareInPlaceRestoreScalesWorkloads(t *testing.T) {
+	ctx := context.Background()
+	existing := newPVC("data", "1Gi", corev1.ClaimBound, "pv-1")
+	backedUp := newPVC("data", "1Gi", corev1.ClaimBound, "pv-0")
+	deployment, replicaSet := newDeployment("web", 3, nil)
+	kubeClient := fake.NewSimpleClientset(
+		newPV("pv-1", constants.VSphereCSIDriverName),
+		deployment, replicaSet, newStatefulSet("db", 2),
+		newOwnedPod("web-1", "data", "ReplicaSet", replicaSet.Name),
+		newOwnedPod("web-2", "data", "ReplicaSet", replicaSet.Name),
+		newOwnedPod("db-0", "data", "StatefulSet", "db"),
+		newVolumeAttachment("attachment-1", "pv-1", "node-1"),
+	)
+	stopPodsOnScaleDown(t, kubeClient, []string{"web-1", "web-2", "db-0"}, []string{"attachment-1"})
+
+	workloads, err := PrepareInPlaceRestore(ctx, kubeClient, existing, backedUp, 10*time.Millisecond, logrus.New())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ScaledWorkload{
+		{Kind: "Deployment", Namespace: "app", Name: "web"},
+		{Kind: "StatefulSet", Namespace: "app", Name: "db"},
+	}, workloads)
+	scaledDeployment, err := kubeClient.AppsV1().Deployments("app").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), *scaledDeployment.Spec.Replicas)
+	assert.Equal(t, "3", scaledDeployment.Annotations[constants.InPlaceRestoreReplicasAnnotation])
+	scaledStatefulSet, err := kubeClient.AppsV1().StatefulSets("app").Get(ctx, "db", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), *scaledStatefulSet.Spec.Replicas)
+	assert.Equal(t, "2", scaledStatefulSet.Annotations[constants.InPlaceRestoreReplicasAnnotation])
+
+	require.NoError(t, ScaleUpWorkloads(ctx, kubeClient, workloads, logrus.New()))
+	scaledDeployment, err = kubeClient.AppsV1().Deployments("app").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), *scaledDeployment.Spec.Replicas)
+	assert.NotContains(t, scaledDeployment.Annotations, constants.InPlaceRestoreReplicasAnnotation)
+	scaledStatefulSet, err = kubeClient.AppsV1().StatefulSets("app").Get(ctx, "db", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), *scaledStatefulSet.Spec.Replicas)
+	assert.NotContains(t, scaledStatefulSet.Annotations, constants.InPlaceRestoreReplicasAnnotation)
+}
+
+func TestPrepareInPlaceRestoreScalesWorkloadsBackUpOnFailure(t *testing.T) {
+	ctx := context.Background()
+	existing := newPVC("data", "1Gi", corev1.ClaimBound, "pv-1")
+	backedUp := newPVC("data", "1Gi", corev1.ClaimBound, "pv-0")
+	deployment, replicaSet := newDeployment("web", 3, nil)
+	kubeClient := fake.NewSimpleClientset(
+		newPV("pv-1", constants.VSphereCSIDriverName),
+		deployment, replicaSet,
+		newOwnedPod("web-1", "data", "ReplicaSet", replicaSet.Name),
+		// A bare pod keeps using the volume
+		newPod("debug", "data", corev1.PodRunning),
+	)
+	stopPodsOnScaleDown(t, kubeClient, []string{"web-1"}, nil)
+
+	workloads, err := PrepareInPlaceRestore(ctx, kubeClient, existing, backedUp, 10*time.Millisecond, logrus.New())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "its volume is still in use by pod debug")
+	assert.Empty(t, workloads)
+	scaledDeployment, err := kubeClient.AppsV1().Deployments("app").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), *scaledDeployment.Spec.Replicas)
+	assert.NotContains(t, scaledDeployment.Annotations, constants.InPlaceRestoreReplicasAnnotation)
+}
+
+func TestScaleDownWorkloadsKeepsRecordedReplicas(t *testing.T) {
+	ctx := context.Background()
+	// The Deployment was left scaled down by an interrupted restore
+	deployment, replicaSet := newDeployment("web", 0, map[string]string{constants.InPlaceRestoreReplicasAnnotation: "3"})
+	kubeClient := fake.NewSimpleClientset(deployment, replicaSet, newOwnedPod("web-1", "data", "ReplicaSet", replicaSet.Name))
+
+	workloads, err := scaleDownWorkloads(ctx, kubeClient, newPVC("data", "1Gi", corev1.ClaimBound, "pv-1"), logrus.New())
+	require.NoError(t, err)
+	require.NoError(t, ScaleUpWorkloads(ctx, kubeClient, workloads, logrus.New()))
+	scaledDeployment, err := kubeClient.AppsV1().Deployments("app").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), *scaledDeployment.Spec.Replicas)
+}
+
+func TestScaleUpWorkloadsKeepsChangedReplicas(t *testing.T) {
+	ctx := context.Background()
+	// The Deployment was scaled by the user during the restore
+	deployment, _ := newDeployment("web", 1, map[string]string{constants.InPlaceRestoreReplicasAnnotation: "3"})
+	kubeClient := fake.NewSimpleClientset(deployment)
+
+	workloads := []ScaledWorkload{{Kind: "Deployment", Namespace: "app", Name: "web"}}
+	require.NoError(t, ScaleUpWorkloads(ctx, kubeClient, workloads, logrus.New()))
+	scaledDeployment, err := kubeClient.AppsV1().Deployments("app").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), *scaledDeployment.Spec.Replicas)
+	assert.NotContains(t, scaledDeployment.Annotations, constants.InPlaceRestoreReplicasAnnotation)
+
+	// The workloads which are gone fail to be scaled up
+	err = ScaleUpWorkloads(ctx, kubeClient, []ScaledWorkload{{Kind: "StatefulSet", Namespace: "app", Name: "db"}}, logrus.New())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Failed to scale up StatefulSet app/db")
+}
+
+func TestNewSnapshotFilter(t *testing.T) {
+	filter, err := NewSnapshotFilter(map[string]string{
+		constants.SnapshotFilterIncludeStorageClasses: " gold, silver ,,",
+		constants.SnapshotFilterExcludeStorageClasses: "scratch",
+		constants.SnapshotFilterIncludeLabelSelector:  "tier in (db,cache)",
+		constants.SnapshotFilterExcludeLabelSelector:  "temporary",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"gold": true, "silver": true}, filter.IncludeStorageClasses)
+	assert.Equal(t, map[string]bool{"scratch": true}, filter.ExcludeStorageClasses)
+	assert.Equal(t, "tier in (cache,db)", filter.IncludeSelector.String())
+	assert.Equal(t, "temporary", filter.ExcludeSelector.String())
+
+	// The selectors are only set if they are in the data
+	filter, err = NewSnapshotFilter(map[string]string{})
+	require.NoError(t, err)
+	assert.Empty(t, filter.IncludeStorageClasses)
+	assert.Nil(t, filter.IncludeSelector)
+	assert.Nil(t, filter.ExcludeSelector)
+
+	for _, key := range []string{constants.SnapshotFilterIncludeLabelSelector, constants.SnapshotFilterExcludeLabelSelector} {
+		_, err = NewSnapshotFilter(map[string]string{key: "tier in (db"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid "+key)
+	}
+}
+
+func TestShouldSnapshotPVC(t *testing.T) {
+	newFilter := func(data map[string]string) *SnapshotFilter {
+		filter, err := NewSnapshotFilter(data)
+		require.NoError(t, err)
+		return filter
+	}
+	newPVC := func(storageClass *string, annotations map[string]string, pvcLabels map[string]string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "data", Annotations: annotations, Labels: pvcLabels},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: storageClass},
+		}
+	}
+	gold := "gold"
+	empty := ""
+	excludeGold := newFilter(map[string]string{constants.SnapshotFilterExcludeStorageClasses: "gold"})
+
+	tests := []struct {
+		name     string
+		pvc      *corev1.PersistentVolumeClaim
+		filter   *SnapshotFilter
+		expected bool
+		reason   string
+	}{
+		{
+			name:     "No filter",
+			pvc:      newPVC(&gold, nil, nil),
+			expected: true,
+		},
+		{
+			name:     "Skip annotation without filter",
+			pvc:      newPVC(&gold, map[string]string{constants.SkipSnapshotAnnotation: "true"}, nil),
+			reason:   "the PVC is annotated with " + constants.SkipSnapshotAnnotation + "=true",
+			expected: false,
+		},
+		{
+			name:     "Annotation takes precedence over an excluding filter",
+			pvc:      newPVC(&gold, map[string]string{constants.SkipSnapshotAnnotation: "false"}, nil),
+			filter:   excludeGold,
+			expected: true,
+		},
+		{
+			name:     "Invalid annotation falls back to the filter",
+			pvc:      newPVC(&gold, map[string]string{constants.SkipSnapshotAnnotation: "maybe"}, nil),
+			filter:   excludeGold,
+			reason:   "the storage class gold is in the excludeStorageClasses of the snapshot filter",
+			expected: false,
+		},
+		{
+			name:     "Included storage class",
+			pvc:      newPVC(&gold, nil, nil),
+			filter:   newFilter(map[string]string{constants.SnapshotFilterIncludeStorageClasses: "gold,silver"}),
+			expected: true,
+		},
+		{
+			name:     "Storage class not included",
+			pvc:      newPVC(&gold, nil, nil),
+			filter:   newFilter(map[string]string{constants.SnapshotFilterIncludeStorageClasses: "silver"}),
+			reason:   "the storage class gold is not in the includeStorageClasses of the snapshot filter",
+			expected: false,
+		},
+		{
+			name:     "Exclusion takes precedence over inclusion",
+			pvc:      newPVC(&gold, nil, nil),
+			filter:   newFilter(map[string]string{constants.SnapshotFilterIncludeStorageClasses: "gold", constants.SnapshotFilterExcludeStorageClasses: "gold"}),
+			reason:   "the storage class gold is in the excludeStorageClasses of the snapshot filter",
+			expected: false,
+		},
+		{
+			name:     "Empty storage class",
+			pvc:      newPVC(&empty, nil, nil),
+			filter:   newFilter(map[string]string{constants.SnapshotFilterExcludeStorageClasses: constants.EmptyStorageClass}),
+			reason:   "the storage class " + constants.EmptyStorageClass + " is in the excludeStorageClasses of the snapshot filter",
+			expected: false,
+		},
+		{
+			name:     "No storage class",
+			pvc:      newPVC(nil, nil, nil),
+			filter:   newFilter(map[string]string{constants.SnapshotFilterIncludeStorageClasses: constants.EmptyStorageClass}),
+			expected: true,
+		},
+		{
+			name:     "Included labels",
+			pvc:      newPVC(&gold, nil, map[string]string{"tier": "db"}),
+			filter:   newFilter(map[string]string{constants.SnapshotFilterIncludeLabelSelector: "tier=db"}),
+			expected: true,
+		},
+		{
+			name:     "Labels not included",
+			pvc:      newPVC(&gold, nil, map[string]string{"tier": "web"}),
+			filter:   newFilter(map[string]string{constants.SnapshotFilterIncludeLabelSelector: "tier=db"}),
+			reason:   "the labels of the PVC do not match the includeLabelSelector \"tier=db\" of the snapshot filter",
+			expected: false,
+		},
+		{
+			name:     "Excluded labels take precedence over included labels",
+			pvc:      newPVC(&gold, nil, map[string]string{"tier": "db", "temporary": "true"}),
+			filter:   newFilter(map[string]string{constants.SnapshotFilterIncludeLabelSelector: "tier=db", constants.SnapshotFilterExcludeLabelSelector: "temporary"}),
+			reason:   "the labels of the PVC match the excludeLabelSelector \"temporary\" of the snapshot filter",
+			expected: false,
+		},
+		{
+			name:     "Empty exclude selector excludes nothing",
+			pvc:      newPVC(&gold, nil, nil),
+			filter:   newFilter(map[string]string{constants.SnapshotFilterExcludeLabelSelector: ""}),
+			expected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snapshot, reason := ShouldSnapshotPVC(test.pvc, test.filter, logrus.New())
+			assert.Equal(t, test.expected, snapshot)
+			assert.Equal(t, test.reason, reason)
+		})
+	}
+}
+
+func newStorageClassMappingConfigMap(name string, namespaces string, pvcSelector string, mapping map[string]string) corev1.ConfigMap {
+	annotations := make(map[string]string)
+	if namespaces != "" {
+		annotations[constants.PluginConfigNamespacesAnnotation] = namespaces
+	}
+	if pvcSelector != "" {
+		annotations[constants.PluginConfigPVCSelectorAnnotation] = pvcSelector
+	}
+	return corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "velero", Name: name, Annotations: annotations},
+		Data:       mapping,
+	}
+}
+
+func TestNewStorageClassMapping(t *testing.T) {
+	tests := []struct {
+		name       string
+		configMaps []corev1.ConfigMap
+		expected   []string
+		err        string
+	}{
+		{
+			name: "Rules sorted by precedence then by name",
+			configMaps: []corev1.ConfigMap{
+				newStorageClassMappingConfigMap("cluster-b", "", "", map[string]string{"gold": "silver"}),
+				newStorageClassMappingConfigMap("cluster-a", "", "", map[string]string{"bronze": "silver"}),
+				newStorageClassMappingConfigMap("namespaces", "app", "", map[string]string{"gold": "bronze"}),
+				newStorageClassMappingConfigMap("selector", "", "tier=db", map[string]string{"gold": "platinum"}),
+				newStorageClassMappingConfigMap("both", "app", "tier=db", map[string]string{"gold": "iron"}),
+			},
+			expected: []string{"both", "selector", "namespaces", "cluster-a", "cluster-b"},
+		},
+		{
+			name: "Same mapping in the same scope",
+			configMaps: []corev1.ConfigMap{
+				newStorageClassMappingConfigMap("cluster-a", "", "", map[string]string{"gold": "silver"}),
+				newStorageClassMappingConfigMap("cluster-b", "", "", map[string]string{"gold": "silver"}),
+			},
+			expected: []string{"cluster-a", "cluster-b"},
+		},
+		{
+			name: "Conflicting mappings in the same scope",
+			configMaps: []corev1.ConfigMap{
+				newStorageClassMappingConfigMap("app-b", "app,web", "", map[string]string{"gold": "bronze"}),
+				newStorageClassMappingConfigMap("app-a", "web, app", "", map[string]string{"gold": "silver"}),
+			},
+			err: "ConfigMaps app-a and app-b may both apply to a PVC, of the namespaces app,web and of the namespaces app,web, and map its storage class gold to silver and bronze",
+		},
+		{
+			name: "Conflicting mappings in intersecting namespaces",
+			configMaps: []corev1.ConfigMap{
+				newStorageClassMappingConfigMap("app", "app,web", "", map[string]string{"gold": "bronze"}),
+				newStorageClassMappingConfigMap("web", "web,db", "", map[string]string{"gold": "silver"}),
+			},
+			err: "ConfigMaps app and web may both apply to a PVC, of the namespaces app,web and of the namespaces db,web, and map its storage class gold to bronze and silver",
+		},
+		{
+			name: "Conflicting mappings in disjoint namespaces",
+			configMaps: []corev1.ConfigMap{
+				newStorageClassMappingConfigMap("app", "app", "", map[string]string{"gold": "bronze"}),
+				newStorageClassMappingConfigMap("web", "web", "", map[string]string{"gold": "silver"}),
+			},
+			expected: []string{"app", "web"},
+		},
+		{
+			name: "Conflicting mappings of different label selectors",
+			configMaps: []corev1.ConfigMap{
+				newStorageClassMappingConfigMap("db", "", "tier=db", map[string]string{"gold": "platinum"}),
+				newStorageClassMappingConfigMap("cache", "", "role=cache", map[string]string{"gold": "copper"}),
+			},
+			err: "ConfigMaps cache and db may both apply to a PVC, of the PVCs matching \"role=cache\" and of the PVCs matching \"tier=db\", and map its storage class gold to copper and platinum",
+		},
+		{
+			name: "Conflicting mappings of label selectors in disjoint namespaces",
+			configMaps: []corev1.ConfigMap{
+				newStorageClassMappingConfigMap("app-db", "app", "tier=db", map[string]string{"gold": "platinum"}),
+				newStorageClassMappingConfigMap("web-cache", "web", "role=cache", map[string]string{"gold": "copper"}),
+			},
+			expected: []string{"app-db", "web-cache"},
+		},
+		{
+			name: "Conflicting mappings in different scopes",
+			configMaps: []corev1.ConfigMap{
+				newStorageClassMappingConfigMap("cluster", "", "", map[string]string{"gold": "bronze"}),
+				newStorageClassMappingConfigMap("app", "app", "", map[string]string{"gold": "silver"}),
+			},
+			expected: []string{"app", "cluster"},
+		},
+		{
+			name: "Invalid annotations and empty mapping",
+			configMaps: []corev1.ConfigMap{
+				newStorageClassMappingConfigMap("invalid", "App_1", "tier in (db", map[string]string{"gold": "silver"}),
+				newStorageClassMappingConfigMap("empty", "", "", nil),
+			},
+			err: "ConfigMap empty has no storage class mapping; ConfigMap invalid has the invalid label selector",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mapping, err := NewStorageClassMapping(test.configMaps)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			var names []string
+			for _, rule := range mapping.Rules {
+				names = append(names, rule.ConfigMap)
+			}
+			assert.Equal(t, test.expected, names)
+		})
+	}
+}
+
+func TestGetStorageClass(t *testing.T) {
+	mapping, err := NewStorageClassMapping([]corev1.ConfigMap{
+		newStorageClassMappingConfigMap("cluster", "", "", map[string]string{"gold": "silver", "bronze": "iron"}),
+		newStorageClassMappingConfigMap("app", "app", "", map[string]string{"gold": "bronze"}),
+		newStorageClassMappingConfigMap("db", "", "tier=db", map[string]string{"gold": "platinum"}),
+		newStorageClassMappingConfigMap("cache", "", "role=cache", map[string]string{"bronze": "copper"}),
+		newStorageClassMappingConfigMap("app-db", "app", "tier=db", map[string]string{"silver": "platinum"}),
+	})
+	require.NoError(t, err)
+	newPVC := func(namespace string, pvcLabels map[string]string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "data", Labels: pvcLabels}}
+	}
+
+	tests := []struct {
+		name              string
+		pvc               *corev1.PersistentVolumeClaim
+		oldName           string
+		expectedName      string
+		expectedConfigMap string
+	}{
+		{
+			name:              "Cluster-wide rule",
+			pvc:               newPVC("web", nil),
+			oldName:           "gold",
+			expectedName:      "silver",
+			expectedConfigMap: "cluster",
+		},
+		{
+			name:              "Namespace rule takes precedence over the cluster-wide rule",
+			pvc:               newPVC("app", nil),
+			oldName:           "gold",
+			expectedName:      "bronze",
+			expectedConfigMap: "app",
+		},
+		{
+			name:              "Selector rule takes precedence over the namespace rule",
+			pvc:               newPVC("app", map[string]string{"tier": "db"}),
+			oldName:           "gold",
+			expectedName:      "platinum",
+			expectedConfigMap: "db",
+		},
+		{
+			name:              "Rule with a higher precedence which does not map the storage class",
+			pvc:               newPVC("app", map[string]string{"tier": "db"}),
+			oldName:           "bronze",
+			expectedName:      "iron",
+			expectedConfigMap: "cluster",
+		},
+		{
+			name:              "Namespace and selector rule",
+			pvc:               newPVC("app", map[string]string{"tier": "db"}),
+			oldName:           "silver",
+			expectedName:      "platinum",
+			expectedConfigMap: "app-db",
+		},
+		{
+			name:    "Storage class not mapped",
+			pvc:     newPVC("app", nil),
+			oldName: "silver",
+		},
+		{
+			name:              "Rules with the same precedence map different storage classes",
+			pvc:               newPVC("web", map[string]string{"tier": "db", "role": "cache"}),
+			oldName:           "bronze",
+			expectedName:      "copper",
+			expectedConfigMap: "cache",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newName, configMap := mapping.GetStorageClass(test.pvc, test.oldName)
+			assert.Equal(t, test.expectedName, newName)
+			assert.Equal(t, test.expectedConfigMap, configMap)
+		})
+	}
+}
@@ -0,0 +1,476 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scrub verifies the snapshots stored in a backup repository against the Velero backups which reference them.
+//
+// A backup references the snapshots of its PVCs through the snapshot annotation of the PVCs in the backup contents.
+// The snapshots referenced by a backup which are not in the repository are missing, and the snapshots in the
+// repository which are not referenced by any backup are orphaned. The snapshots which are referenced are read back
+// from the repository to verify them against their checksums.
+package scrub
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	datamoverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/datamover/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/checksum"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/encryption"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/incremental"
+	pluginUtil "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/plugin/util"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/snapshotmgr"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SnapshotReference is a snapshot in a backup repository referenced by a PVC in a Velero backup.
+type SnapshotReference struct {
+	Backup string
+	// PVC is the namespace/name of the PVC
+	PVC              string
+	BackupRepository string
+	// PeID is the pe-id of the snapshot in the backup repository
+	PeID astrolabe.ProtectedEntityID
+}
+
+// GetSnapshotReferences returns the snapshots referenced by the PVCs in the contents of a backup, i.e. the gzipped
+// tarball of the backed up resources. The snapshots which were not uploaded to a backup repository are left out.
+func GetSnapshotReferences(backupName string, contents io.Reader, logger logrus.FieldLogger) ([]SnapshotReference, error) {
+	gzipReader, err := gzip.NewReader(contents)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read the contents of the backup %s", backupName)
+	}
+	defer gzipReader.Close()
+
+	pvcDir := path.Join(velerov1.ResourcesDir, "persistentvolumeclaims") + "/"
+	var references []SnapshotReference
+	// The PVCs are stored once per API group version when the API group versions are enabled in Velero
+	seen := make(map[string]bool)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read the contents of the backup %s", backupName)
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasPrefix(header.Name, pvcDir) || !strings.HasSuffix(header.Name, ".json") {
+			continue
+		}
+		var pvc corev1.PersistentVolumeClaim
+		if err := json.NewDecoder(tarReader).Decode(&pvc); err != nil {
+			return nil, errors.Wrapf(err, "Failed to decode %s in the contents of the backup %s", header.Name, backupName)
+		}
+		reference, ok, err := getSnapshotReference(backupName, &pvc, logger)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		key := reference.PVC + "/" + reference.PeID.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		references = append(references, reference)
+	}
+	return references, nil
+}
+
+func getSnapshotReference(backupName string, pvc *corev1.PersistentVolumeClaim, logger logrus.FieldLogger) (SnapshotReference, bool, error) {
+	snapshotAnnotation, ok := pvc.Annotations[constants.ItemSnapshotLabel]
+	if !ok {
+		return SnapshotReference{}, false, nil
+	}
+	var itemSnapshot backupdriverv1.Snapshot
+	if err := pluginUtil.GetSnapshotFromPVCAnnotation(snapshotAnnotation, &itemSnapshot); err != nil {
+		return SnapshotReference{}, false, errors.Wrapf(err, "Failed to parse the Snapshot of the PVC %s/%s in the backup %s", pvc.Namespace, pvc.Name, backupName)
+	}
+	return newSnapshotReference(backupName, pvc.Namespace+"/"+pvc.Name, &itemSnapshot, logger)
+}
+
+// GetPendingSnapshotReference returns the snapshot referenced by a Snapshot CR which has not reached a terminal phase
+// yet, i.e. whose snapshot the backup is still waiting for.
+func GetPendingSnapshotReference(snapshot *backupdriverv1.Snapshot, logger logrus.FieldLogger) (SnapshotReference, bool, error) {
+	switch snapshot.Status.Phase {
+	case backupdriverv1.SnapshotPhaseSnapshotFailed, backupdriverv1.SnapshotPhaseUploaded, backupdriverv1.SnapshotPhaseUploadFailed,
+		backupdriverv1.SnapshotPhaseCanceled, backupdriverv1.SnapshotPhaseCleanupFailed:
+		return SnapshotReference{}, false, nil
+	}
+	return newSnapshotReference(snapshot.Labels[constants.SnapshotBackupLabel], snapshot.Namespace+"/"+snapshot.Spec.Name, snapshot, logger)
+}
+
+func newSnapshotReference(backupName string, pvcName string, itemSnapshot *backupdriverv1.Snapshot, logger logrus.FieldLogger) (SnapshotReference, bool, error) {
+	backupRepositoryName := itemSnapshot.Spec.BackupRepository
+	if itemSnapshot.Status.SnapshotID == "" || backupRepositoryName == "" || backupRepositoryName == constants.WithoutBackupRepository {
+		logger.Debugf("The snapshot of the PVC %s in the backup %s was not uploaded to a backup repository", pvcName, backupName)
+		return SnapshotReference{}, false, nil
+	}
+	snapshotID, err := astrolabe.NewProtectedEntityIDFromString(itemSnapshot.Status.SnapshotID)
+	if err != nil {
+		return SnapshotReference{}, false, errors.Wrapf(err, "Invalid snapshot ID %s of the PVC %s in the backup %s", itemSnapshot.Status.SnapshotID, pvcName, backupName)
+	}
+	peID, err := snapshotmgr.GetRepositoryPeID(snapshotID, logger)
+	if err != nil {
+		return SnapshotReference{}, false, errors.Wrapf(err, "Failed to translate the snapshot ID %s of the PVC %s in the backup %s", snapshotID.String(), pvcName, backupName)
+	}
+	return SnapshotReference{
+		Backup:           backupName,
+		PVC:              pvcName,
+		BackupRepository: backupRepositoryName,
+		PeID:             peID,
+	}, true, nil
+}
+
+// GroupBackupRepositories groups the BackupRepositories which store their snapshots in the same repository, e.g. the
+// BackupRepositories claimed for different namespaces with the same backup storage location.
+func GroupBackupRepositories(backupRepositories []backupdriverv1.BackupRepository) [][]backupdriverv1.BackupRepository {
+	sorted := append([]backupdriverv1.BackupRepository(nil), backupRepositories...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	var groups [][]backupdriverv1.BackupRepository
+	groupIndex := make(map[string]int)
+	for _, backupRepository := range sorted {
+		key := repositoryLocation(backupRepository)
+		if i, ok := groupIndex[key]; ok {
+			groups[i] = append(groups[i], backupRepository)
+			continue
+		}
+		groupIndex[key] = len(groups)
+		groups = append(groups, []backupdriverv1.BackupRepository{backupRepository})
+	}
+	return groups
+}
+
+// repositoryLocation identifies where a BackupRepository stores its snapshots.
+func repositoryLocation(backupRepository backupdriverv1.BackupRepository) string {
+	var params []string
+	for k, v := range backupRepository.RepositoryParameters {
+		// The compression does not change where the snapshots are stored
+		if k == constants.RepositoryParamCompression {
+			continue
+		}
+		params = append(params, k+"="+v)
+	}
+	sort.Strings(params)
+	return backupRepository.RepositoryDriver + "\n" + strings.Join(params, "\n")
+}
+
+// SnapshotStatus is the result of the verification of a snapshot.
+type SnapshotStatus string
+
+const (
+	// The snapshot matches its checksums
+	SnapshotStatusVerified SnapshotStatus = "Verified"
+	// The snapshot is in the repository, its data was not read
+	SnapshotStatusPresent SnapshotStatus = "Present"
+	// The snapshot was written before the checksums were introduced, so it cannot be verified
+	SnapshotStatusUnverified SnapshotStatus = "Unverified"
+	// The snapshot is still being uploaded to the repository
+	SnapshotStatusPending SnapshotStatus = "Pending"
+	// The snapshot is referenced by a backup, but it is not in the repository
+	SnapshotStatusMissing SnapshotStatus = "Missing"
+	// The snapshot is in the repository, but it is not referenced by any backup
+	SnapshotStatusOrphaned SnapshotStatus = "Orphaned"
+	// The snapshot does not match its checksums, or the chain of the incremental snapshot is broken
+	SnapshotStatusCorrupt SnapshotStatus = "Corrupt"
+	// The snapshot could not be read from the repository
+	SnapshotStatusFailed SnapshotStatus = "Failed"
+)
+
+// SnapshotResult is the result of the verification of a snapshot in the repository.
+type SnapshotResult struct {
+	PeID   astrolabe.ProtectedEntityID
+	Status SnapshotStatus
+	// Backups are the backups which depend on the snapshot
+	Backups []string
+	Message string
+}
+
+// IsProblem checks if a backup can not be restored because of the snapshot, or the snapshot wastes space in the
+// repository.
+func (this SnapshotResult) IsProblem() bool {
+	switch this.Status {
+	case SnapshotStatusMissing, SnapshotStatusOrphaned, SnapshotStatusCorrupt, SnapshotStatusFailed:
+		return true
+	}
+	return false
+}
+
+type Options struct {
+	// VerifyData reads the data of the snapshots back from the repository to verify them against their checksums
+	VerifyData bool
+	// UploadPhases are the phases of the Uploads by snapshot pe-id, which tell the snapshots still being uploaded from
+	// the missing ones
+	UploadPhases map[string]datamoverv1.UploadPhase
+}
+
+// VerifyRepository verifies the snapshots in the repository against the snapshots referenced by the backups. The
+// snapshots which the incremental snapshots referenced by the backups are based on are verified as well. The results
+// are sorted by status and pe-id.
+func VerifyRepository(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, references []SnapshotReference,
+	options Options, logger logrus.FieldLogger) ([]SnapshotResult, error) {
+	peIDs, err := petm.GetProtectedEntities(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list the snapshots in the repository")
+	}
+	// stored maps the snapshots to the pe-id under which they are stored, i.e. the pe-id of the staging snapshot of an
+	// interrupted consolidation, which is read in place of the snapshot it replaces, until the consolidation completes
+	stored := make(map[string]astrolabe.ProtectedEntityID)
+	staging := make(map[string]astrolabe.ProtectedEntityID)
+	markers := make(map[string]astrolabe.ProtectedEntityID)
+	for _, peID := range peIDs {
+		if !peID.HasSnapshot() {
+			continue
+		}
+		if primary, ok := incremental.StagedSnapshotID(peID); ok {
+			staging[primary.String()] = peID
+			continue
+		}
+		if incremental.IsDeletionMarker(peID) {
+			// The mark of a snapshot being deleted holds no data
+			continue
+		}
+		if snapshotID, ok := encryption.UnencryptedSnapshotID(peID); ok {
+			markers[snapshotID.String()] = peID
+			continue
+		}
+		stored[peID.String()] = peID
+	}
+	for key, stagingID := range staging {
+		if _, ok := stored[key]; !ok {
+			stored[key] = stagingID
+		}
+	}
+	for key, markerID := range markers {
+		// The record of a snapshot written before the encryption was enabled is orphaned when the snapshot is gone
+		if _, ok := stored[key]; !ok {
+			stored[markerID.String()] = markerID
+		}
+	}
+
+	var results []SnapshotResult
+	backups := make(map[string][]string)
+	var queue []astrolabe.ProtectedEntityID
+	for _, reference := range references {
+		key := reference.PeID.String()
+		if _, ok := backups[key]; !ok {
+			queue = append(queue, reference.PeID)
+		}
+		backups[key] = appendUnique(backups[key], reference.Backup)
+	}
+	for len(queue) > 0 {
+		peID := queue[0]
+		queue = queue[1:]
+		key := peID.String()
+		storedID, ok := stored[key]
+		if !ok {
+			results = append(results, missingSnapshot(peID, backups[key], options.UploadPhases))
+			continue
+		}
+		result, chain := verifySnapshot(ctx, petm, storedID, options.VerifyData, logger)
+		result.PeID = peID
+		result.Backups = backups[key]
+		results = append(results, result)
+		// The backups depend on the snapshots which the snapshot is based on as well
+		for _, parentID := range chain {
+			parentKey := parentID.String()
+			if parent, ok := incremental.StagedSnapshotID(parentID); ok {
+				parentID, parentKey = parent, parent.String()
+			}
+			if parentKey == key {
+				continue
+			}
+			if _, ok := backups[parentKey]; !ok {
+				queue = append(queue, parentID)
+			}
+			for _, backup := range backups[key] {
+				backups[parentKey] = appendUnique(backups[parentKey], backup)
+			}
+		}
+	}
+	for key, peID := range stored {
+		if _, ok := backups[key]; !ok {
+			results = append(results, SnapshotResult{
+				PeID:    peID,
+				Status:  SnapshotStatusOrphaned,
+				Message: "The snapshot is not referenced by any backup",
+			})
+		}
+	}
+	sortResults(results)
+	return results, nil
+}
+
+func missingSnapshot(peID astrolabe.ProtectedEntityID, backups []string, uploadPhases map[string]datamoverv1.UploadPhase) SnapshotResult {
+	result := SnapshotResult{
+		PeID:    peID,
+		Status:  SnapshotStatusMissing,
+		Backups: backups,
+		Message: "The snapshot is not in the repository",
+	}
+	phase, ok := uploadPhases[peID.String()]
+	if !ok {
+		return result
+	}
+	switch phase {
+	case datamoverv1.UploadPhaseNew, datamoverv1.UploadPhaseInProgress, datamoverv1.UploadPhaseUploadError, datamoverv1.UploadPhaseCanceling:
+		result.Status = SnapshotStatusPending
+		result.Message = fmt.Sprintf("The upload of the snapshot is in phase %s", phase)
+	case datamoverv1.UploadPhaseFailed, datamoverv1.UploadPhaseCanceled:
+		result.Message = fmt.Sprintf("The upload of the snapshot is in phase %s", phase)
+	}
+	return result
+}
+
+// verifySnapshot verifies the snapshot stored in the repository, and returns the chain of the snapshot along with the
+// result.
+func verifySnapshot(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, peID astrolabe.ProtectedEntityID,
+	verifyData bool, logger logrus.FieldLogger) (SnapshotResult, []astrolabe.ProtectedEntityID) {
+	log := logger.WithField("peID", peID.String())
+	result := SnapshotResult{
+		Status: SnapshotStatusPresent,
+	}
+	if verifyData {
+		log.Info("Verifying the snapshot against its checksums")
+		err := verifyProtectedEntity(ctx, petm, peID)
+		switch {
+		case err == nil:
+			result.Status = SnapshotStatusVerified
+		case checksum.IsMissingChecksum(err):
+			result.Status = SnapshotStatusUnverified
+			result.Message = "The snapshot was written without checksums"
+		case checksum.IsMismatch(err):
+			result.Status = SnapshotStatusCorrupt
+			result.Message = err.Error()
+		default:
+			result.Status = SnapshotStatusFailed
+			result.Message = err.Error()
+		}
+		if result.Status == SnapshotStatusCorrupt || result.Status == SnapshotStatusFailed {
+			log.WithError(err).Error("Failed to verify the snapshot")
+			return result, nil
+		}
+	}
+	chain, err := incremental.GetChainIDs(ctx, petm, peID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get the chain of the snapshot")
+		result.Status = SnapshotStatusCorrupt
+		result.Message = fmt.Sprintf("The chain of the snapshot is broken: %v", err)
+		return result, nil
+	}
+	return result, chain
+}
+
+func verifyProtectedEntity(ctx context.Context, petm astrolabe.ProtectedEntityTypeManager, peID astrolabe.ProtectedEntityID) error {
+	pe, err := petm.GetProtectedEntity(ctx, peID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get the snapshot %s from the repository", peID.String())
+	}
+	return checksum.VerifyProtectedEntity(ctx, pe)
+}
+
+var statusOrder = map[SnapshotStatus]int{
+	SnapshotStatusMissing:    0,
+	SnapshotStatusCorrupt:    1,
+	SnapshotStatusFailed:     2,
+	SnapshotStatusOrphaned:   3,
+	SnapshotStatusPending:    4,
+	SnapshotStatusUnverified: 5,
+	SnapshotStatusPresent:    6,
+	SnapshotStatusVerified:   7,
+}
+
+func sortResults(results []SnapshotResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Status != results[j].Status {
+			return statusOrder[results[i].Status] < statusOrder[results[j].Status]
+		}
+		return results[i].PeID.String() < results[j].PeID.String()
+	})
+}
+
+// OrphanTracker tracks since when the snapshots in the repositories have been orphaned, so that an orphaned snapshot
+// can be told from a snapshot whose backup has not completed yet by the time it has been orphaned for.
+type OrphanTracker struct {
+	// since records when the snapshots were first found orphaned, by repository and pe-id
+	since map[string]map[string]time.Time
+}
+
+func NewOrphanTracker() *OrphanTracker {
+	return &OrphanTracker{
+		since: make(map[string]map[string]time.Time),
+	}
+}
+
+// OrphanedSnapshot is a snapshot which has been orphaned since a given time.
+type OrphanedSnapshot struct {
+	PeID  astrolabe.ProtectedEntityID
+	Since time.Time
+}
+
+// Update records the snapshots found orphaned in the repository at the given time, and forgets the snapshots of the
+// repository which are no longer orphaned. It returns the orphaned snapshots along with the time since which they
+// have been orphaned.
+func (this *OrphanTracker) Update(repository string, results []SnapshotResult, now time.Time) []OrphanedSnapshot {
+	previous := this.since[repository]
+	current := make(map[string]time.Time)
+	var orphans []OrphanedSnapshot
+	for _, result := range results {
+		if result.Status != SnapshotStatusOrphaned {
+			continue
+		}
+		key := result.PeID.String()
+		since, ok := previous[key]
+		if !ok {
+			since = now
+		}
+		current[key] = since
+		orphans = append(orphans, OrphanedSnapshot{PeID: result.PeID, Since: since})
+	}
+	this.since[repository] = current
+	return orphans
+}
+
+// Forget forgets the snapshot of the repository, e.g. once it has been deleted.
+func (this *OrphanTracker) Forget(repository string, peID astrolabe.ProtectedEntityID) {
+	delete(this.since[repository], peID.String())
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
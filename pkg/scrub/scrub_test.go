@@ -0,0 +1,347 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scrub
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
+	datamoverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/datamover/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/checksum"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/fsrepository"
+	pluginUtil "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/plugin/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const fcdID = "e1c3cb20-db88-4c1c-9f02-5f5347e435d5"
+
+func ivdSnapshotID(snapshot string) string {
+	return fmt.Sprintf("ivd:%s:%s", fcdID, snapshot)
+}
+
+func newPVC(t *testing.T, name string, backupRepository string, snapshotID string) corev1.PersistentVolumeClaim {
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "app",
+			Name:      name,
+		},
+	}
+	if snapshotID != "" {
+		snapshot := backupdriverv1.Snapshot{
+			Spec: backupdriverv1.SnapshotSpec{
+				BackupRepository: backupRepository,
+			},
+			Status: backupdriverv1.SnapshotStatus{
+				SnapshotID: snapshotID,
+			},
+		}
+		annotation, err := pluginUtil.GetAnnotationFromSnapshot(snapshot)
+		require.NoError(t, err)
+		pvc.Annotations = map[string]string{constants.ItemSnapshotLabel: annotation}
+	}
+	return pvc
+}
+
+func backupContents(t *testing.T, items map[string]interface{}) []byte {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, item := range items {
+		content, err := json.Marshal(item)
+		require.NoError(t, err)
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err = tarWriter.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	return buf.Bytes()
+}
+
+func TestGetSnapshotReferences(t *testing.T) {
+	ivdID := ivdSnapshotID("67469e1c-50a8-4f63-9a6a-ad8a2265197c")
+	pvcID := "pvc:app/data:" + base64.RawStdEncoding.EncodeToString([]byte(ivdID))
+	contents := backupContents(t, map[string]interface{}{
+		"resources/persistentvolumeclaims/namespaces/app/data.json":                     newPVC(t, "data", "br-1", pvcID),
+		"resources/persistentvolumeclaims/v1-preferredversion/namespaces/app/data.json": newPVC(t, "data", "br-1", pvcID),
+		"resources/persistentvolumeclaims/namespaces/app/legacy.json":                   newPVC(t, "legacy", "br-1", ivdSnapshotID("7d1f6cd2-5e4b-4bd1-a6a4-96a6b8d0cd5a")),
+		"resources/persistentvolumeclaims/namespaces/app/local.json":                    newPVC(t, "local", constants.WithoutBackupRepository, pvcID),
+		"resources/persistentvolumeclaims/namespaces/app/other.json":                    newPVC(t, "other", "", ""),
+		"resources/pods/namespaces/app/data.json":                                       corev1.Pod{},
+	})
+
+	references, err := GetSnapshotReferences("backup-1", bytes.NewReader(contents), logrus.New())
+	require.NoError(t, err)
+	require.Len(t, references, 2)
+	byPVC := make(map[string]SnapshotReference)
+	for _, reference := range references {
+		byPVC[reference.PVC] = reference
+	}
+	assert.Equal(t, "backup-1", byPVC["app/data"].Backup)
+	assert.Equal(t, "br-1", byPVC["app/data"].BackupRepository)
+	assert.Equal(t, ivdID, byPVC["app/data"].PeID.String())
+	assert.Equal(t, ivdSnapshotID("7d1f6cd2-5e4b-4bd1-a6a4-96a6b8d0cd5a"), byPVC["app/legacy"].PeID.String())
+
+	_, err = GetSnapshotReferences("backup-1", bytes.NewReader([]byte("not a tarball")), logrus.New())
+	assert.Error(t, err)
+}
+
+func TestGetPendingSnapshotReference(t *testing.T) {
+	ivdID := ivdSnapshotID("67469e1c-50a8-4f63-9a6a-ad8a2265197c")
+	pvcID := "pvc:app/data:" + base64.RawStdEncoding.EncodeToString([]byte(ivdID))
+	newSnapshot := func(phase backupdriverv1.SnapshotPhase, snapshotID string) *backupdriverv1.Snapshot {
+		return &backupdriverv1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "app",
+				Name:      "snap-1",
+				Labels:    map[string]string{constants.SnapshotBackupLabel: "backup-1"},
+			},
+			Spec: backupdriverv1.SnapshotSpec{
+				TypedLocalObjectReference: corev1.TypedLocalObjectReference{Name: "data"},
+				BackupRepository:          "br-1",
+			},
+			Status: backupdriverv1.SnapshotStatus{
+				Phase:      phase,
+				SnapshotID: snapshotID,
+			},
+		}
+	}
+
+	reference, ok, err := GetPendingSnapshotReference(newSnapshot(backupdriverv1.SnapshotPhaseUploading, pvcID), logrus.New())
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, SnapshotReference{Backup: "backup-1", PVC: "app/data", BackupRepository: "br-1", PeID: reference.PeID}, reference)
+	assert.Equal(t, ivdID, reference.PeID.String())
+
+	// The Snapshots which are done with their snapshot do not reference it anymore
+	for _, phase := range []backupdriverv1.SnapshotPhase{backupdriverv1.SnapshotPhaseUploaded, backupdriverv1.SnapshotPhaseUploadFailed, backupdriverv1.SnapshotPhaseCanceled} {
+		_, ok, err = GetPendingSnapshotReference(newSnapshot(phase, pvcID), logrus.New())
+		require.NoError(t, err)
+		assert.False(t, ok, "phase %s", phase)
+	}
+	// The snapshot is not taken yet
+	_, ok, err = GetPendingSnapshotReference(newSnapshot(backupdriverv1.SnapshotPhaseNew, ""), logrus.New())
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestOrphanTracker(t *testing.T) {
+	orphan := func(snapshot string) SnapshotResult {
+		peID, err := astrolabe.NewProtectedEntityIDFromString(ivdSnapshotID(snapshot))
+		require.NoError(t, err)
+		return SnapshotResult{PeID: peID, Status: SnapshotStatusOrphaned}
+	}
+	present := orphan("00000000-0000-0000-0000-000000000000")
+	present.Status = SnapshotStatusPresent
+	first := orphan("11111111-1111-1111-1111-111111111111")
+	second := orphan("22222222-2222-2222-2222-222222222222")
+	start := time.Now()
+	tracker := NewOrphanTracker()
+
+	orphans := tracker.Update("br-1", []SnapshotResult{present, first}, start)
+	assert.Equal(t, []OrphanedSnapshot{{PeID: first.PeID, Since: start}}, orphans)
+
+	// The snapshots stay orphaned since the time they were first found orphaned
+	later := start.Add(time.Hour)
+	orphans = tracker.Update("br-1", []SnapshotResult{first, second}, later)
+	assert.Equal(t, []OrphanedSnapshot{{PeID: first.PeID, Since: start}, {PeID: second.PeID, Since: later}}, orphans)
+	// The repositories are tracked separately
+	assert.Equal(t, []OrphanedSnapshot{{PeID: first.PeID, Since: later}}, tracker.Update("br-2", []SnapshotResult{first}, later))
+
+	// A snapshot which got referenced again is forgotten
+	orphans = tracker.Update("br-1", []SnapshotResult{second}, later.Add(time.Hour))
+	assert.Equal(t, []OrphanedSnapshot{{PeID: second.PeID, Since: later}}, orphans)
+	orphans = tracker.Update("br-1", []SnapshotResult{first, second}, later.Add(2*time.Hour))
+	assert.Equal(t, []OrphanedSnapshot{{PeID: first.PeID, Since: later.Add(2 * time.Hour)}, {PeID: second.PeID, Since: later}}, orphans)
+
+	tracker.Forget("br-1", second.PeID)
+	orphans = tracker.Update("br-1", []SnapshotResult{second}, later.Add(3*time.Hour))
+	assert.Equal(t, []OrphanedSnapshot{{PeID: second.PeID, Since: later.Add(3 * time.Hour)}}, orphans)
+}
+
+func TestGroupBackupRepositories(t *testing.T) {
+	newBackupRepository := func(name string, bucket string, compression string) backupdriverv1.BackupRepository {
+		return backupdriverv1.BackupRepository{
+			ObjectMeta:       metav1.ObjectMeta{Name: name},
+			RepositoryDriver: constants.S3RepositoryDriver,
+			RepositoryParameters: map[string]string{
+				"bucket":                             bucket,
+				constants.RepositoryParamCompression: compression,
+			},
+		}
+	}
+	groups := GroupBackupRepositories([]backupdriverv1.BackupRepository{
+		newBackupRepository("br-3", "bucket-1", "gzip"),
+		newBackupRepository("br-2", "bucket-2", ""),
+		newBackupRepository("br-1", "bucket-1", ""),
+	})
+	require.Len(t, groups, 2)
+	require.Len(t, groups[0], 2)
+	assert.Equal(t, "br-1", groups[0][0].Name)
+	assert.Equal(t, "br-3", groups[0][1].Name)
+	require.Len(t, groups[1], 1)
+	assert.Equal(t, "br-2", groups[1][0].Name)
+}
+
+type fakeProtectedEntity struct {
+	astrolabe.ProtectedEntity
+	info     astrolabe.ProtectedEntityInfo
+	data     []byte
+	metadata []byte
+}
+
+func (this fakeProtectedEntity) GetID() astrolabe.ProtectedEntityID {
+	return this.info.GetID()
+}
+
+func (this fakeProtectedEntity) GetInfo(ctx context.Context) (astrolabe.ProtectedEntityInfo, error) {
+	return this.info, nil
+}
+
+func (this fakeProtectedEntity) GetDataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.data)), nil
+}
+
+func (this fakeProtectedEntity) GetMetadataReader(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(this.metadata)), nil
+}
+
+func TestVerifyRepository(t *testing.T) {
+	ctx := context.Background()
+	repoDir, err := ioutil.TempDir("", "scrub")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+	fsPETM, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager("ivd", repoDir, "plugins/vsphere-astrolabe-repo", logrus.New())
+	require.NoError(t, err)
+	petm := checksum.NewProtectedEntityTypeManager(fsPETM, logrus.New())
+
+	store := func(petm astrolabe.ProtectedEntityTypeManager, snapshot string, metadata string) astrolabe.ProtectedEntityID {
+		peID, err := astrolabe.NewProtectedEntityIDFromString(ivdSnapshotID(snapshot))
+		require.NoError(t, err)
+		transports := []astrolabe.DataTransport{astrolabe.NewDataTransport("fake", map[string]string{})}
+		_, err = petm.Copy(ctx, fakeProtectedEntity{
+			info:     astrolabe.NewProtectedEntityInfo(peID, "fake-pe", transports, transports, []astrolabe.DataTransport{}, []astrolabe.ProtectedEntityID{}),
+			data:     bytes.Repeat([]byte(snapshot), 1000),
+			metadata: []byte(metadata),
+		}, make(map[string]map[string]interface{}), astrolabe.AllocateNewObject)
+		require.NoError(t, err)
+		return peID
+	}
+	layerMetadata := func(parent string) string {
+		return fmt.Sprintf(`{"format": "velero-plugin-for-vsphere/incremental/v1", "parentID": "%s", "capacity": 4096, "extents": []}`, ivdSnapshotID(parent))
+	}
+	reference := func(backup string, snapshot string) SnapshotReference {
+		peID, err := astrolabe.NewProtectedEntityIDFromString(ivdSnapshotID(snapshot))
+		require.NoError(t, err)
+		return SnapshotReference{Backup: backup, PVC: "app/data", BackupRepository: "br-1", PeID: peID}
+	}
+
+	store(petm, "verified", "metadata")
+	corruptID := store(petm, "corrupt", "metadata")
+	dataFile := filepath.Join(repoDir, "plugins/vsphere-astrolabe-repo", "ivd", "data", corruptID.String()+".data")
+	stored, err := ioutil.ReadFile(dataFile)
+	require.NoError(t, err)
+	stored[len(stored)/2] ^= 1
+	require.NoError(t, ioutil.WriteFile(dataFile, stored, 0644))
+	store(fsPETM, "legacy", "metadata")
+	store(petm, "orphaned", "metadata")
+	store(petm, "consolidate-staged", "metadata")
+	store(petm, "base", "metadata")
+	store(petm, "incremental", layerMetadata("base"))
+	store(petm, "broken", layerMetadata("deleted"))
+
+	references := []SnapshotReference{
+		reference("backup-1", "verified"),
+		reference("backup-2", "verified"),
+		reference("backup-1", "corrupt"),
+		reference("backup-1", "legacy"),
+		reference("backup-1", "staged"),
+		reference("backup-3", "incremental"),
+		reference("backup-3", "broken"),
+		reference("backup-4", "uploading"),
+		reference("backup-4", "lost"),
+	}
+	options := Options{
+		VerifyData: true,
+		UploadPhases: map[string]datamoverv1.UploadPhase{
+			ivdSnapshotID("uploading"): datamoverv1.UploadPhaseInProgress,
+		},
+	}
+	results, err := VerifyRepository(ctx, petm, references, options, logrus.New())
+	require.NoError(t, err)
+
+	statuses := make(map[string]SnapshotStatus)
+	backups := make(map[string][]string)
+	for _, result := range results {
+		snapshot := result.PeID.GetSnapshotID().String()
+		statuses[snapshot] = result.Status
+		backups[snapshot] = result.Backups
+	}
+	assert.Equal(t, map[string]SnapshotStatus{
+		"verified":    SnapshotStatusVerified,
+		"corrupt":     SnapshotStatusCorrupt,
+		"legacy":      SnapshotStatusUnverified,
+		"orphaned":    SnapshotStatusOrphaned,
+		"staged":      SnapshotStatusVerified,
+		"base":        SnapshotStatusVerified,
+		"incremental": SnapshotStatusVerified,
+		"broken":      SnapshotStatusCorrupt,
+		"uploading":   SnapshotStatusPending,
+		"lost":        SnapshotStatusMissing,
+	}, statuses)
+	assert.Equal(t, []string{"backup-1", "backup-2"}, backups["verified"])
+	// The base of an incremental snapshot is needed by the backups of the incremental snapshot
+	assert.Equal(t, []string{"backup-3"}, backups["base"])
+	assert.Empty(t, backups["orphaned"])
+	assert.Equal(t, SnapshotStatusMissing, results[0].Status)
+	assert.Equal(t, SnapshotStatusVerified, results[len(results)-1].Status)
+
+	// Without reading the data, only the missing and the orphaned snapshots and the broken chains are detected
+	options.VerifyData = false
+	results, err = VerifyRepository(ctx, petm, references, options, logrus.New())
+	require.NoError(t, err)
+	for _, result := range results {
+		statuses[result.PeID.GetSnapshotID().String()] = result.Status
+	}
+	assert.Equal(t, SnapshotStatusPresent, statuses["corrupt"])
+	assert.Equal(t, SnapshotStatusPresent, statuses["legacy"])
+	assert.Equal(t, SnapshotStatusCorrupt, statuses["broken"])
+	assert.Equal(t, SnapshotStatusOrphaned, statuses["orphaned"])
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
-	"github.com/vmware-tanzu/astrolabe/pkg/ivd"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/multivc"
 )
 
 import "github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
@@ -55,11 +55,15 @@ func (this DataManagerProtectedEntityTypeManager) CopyFromInfo(ctx context.Conte
 	return nil, nil
 }
 
-func (this DataManagerProtectedEntityTypeManager) ReloadDmIvdPetm(ctx context.Context, ivdParams map[string]interface{}, logger logrus.FieldLogger) error {
+func (this DataManagerProtectedEntityTypeManager) ReloadDmIvdPetm(ctx context.Context, vcParams []map[string]interface{}, logger logrus.FieldLogger) error {
 	logger.Debug("ReloadDmIvdPetm called")
-	ivdProtectedEntityTypeManager, ok := this.ProtectedEntityTypeManager.(*ivd.IVDProtectedEntityTypeManager)
+	ivdProtectedEntityTypeManager, ok := this.ProtectedEntityTypeManager.(*multivc.ProtectedEntityTypeManager)
 	if ok {
-		err := ivdProtectedEntityTypeManager.ReloadConfig(context.TODO(), ivdParams)
+		err := ivdProtectedEntityTypeManager.ReloadConfig(context.TODO(), vcParams)
+		if err == multivc.ErrReloadDeferred {
+			logger.Info("The reload of IVD Config in datapetm is deferred")
+			return nil
+		}
 		if err != nil {
 			return errors.Wrapf(err, "Failed to Reload IVD Config in datapetm.")
 		}
@@ -22,13 +22,17 @@ import (
 	"fmt"
 	"github.com/vmware-tanzu/astrolabe/pkg/common/vsphere"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/backuprepository"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/checksum"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/incremental"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/multivc"
 	v1 "k8s.io/api/core/v1"
 	"os"
 	"strings"
 	"time"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -44,6 +48,7 @@ import (
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/utils"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/clock"
 )
@@ -110,15 +115,20 @@ func NewSnapshotManagerFromConfig(configInfo server.ConfigInfo, s3RepoParams map
 	// Retrieve VC configuration from the cluster only if it has not been set by the caller
 	// An empty ivd map must be passed to use the IVD
 	// TODO - Move this code out to the caller - this assumes we always want IVD
+	var vcParams []map[string]interface{}
 	if ivdParams, ok := configInfo.PEConfigs["ivd"]; ok {
-		if _, ok := ivdParams[vsphere.HostVcParamKey]; !ok {
-			err := utils.RetrieveVcConfigSecret(ivdParams, k8sRestConfig, logger)
+		if _, ok := ivdParams[vsphere.HostVcParamKey]; ok {
+			vcParams = []map[string]interface{}{ivdParams}
+		} else {
+			vcParams, err = utils.RetrieveVcConfigs(k8sRestConfig, logger)
 			if err != nil {
 				logger.WithError(err).Errorf("Could not retrieve vsphere credential from k8s secret")
 				return nil, err
 			}
-			logger.Infof("SnapshotManager: vSphere VC credential is retrieved")
+			logger.Infof("SnapshotManager: vSphere VC credential is retrieved for %d VirtualCenters", len(vcParams))
 		}
+		// The ivd PETM is registered below, with an ivd PETM per VirtualCenter
+		delete(configInfo.PEConfigs, "ivd")
 	}
 
 	// TODO: Remove the use of s3RepoParams. Do not use BackupStorageLocation,
@@ -183,11 +193,15 @@ func NewSnapshotManagerFromConfig(configInfo server.ConfigInfo, s3RepoParams map
 		dpem.RegisterExternalProtectedEntityTypeManagers([]astrolabe.ProtectedEntityTypeManager{paraVirtPETM})
 	}
 
-	ivdPETM := dpem.GetProtectedEntityTypeManager("ivd")
-	if ivdPETM != nil {
-		dmIVDPE := NewDataManagerProtectedEntityTypeManager(ivdPETM, &snapMgr, true)
-		// We use a DataManagerPE to delegate data copy to the data manager
-		dpem.RegisterExternalProtectedEntityTypeManagers([]astrolabe.ProtectedEntityTypeManager{dmIVDPE})
+	if vcParams != nil {
+		ivdPETM, err := multivc.NewProtectedEntityTypeManager(vcParams, logger)
+		if err != nil {
+			logger.WithError(err).Errorf("Could not start service ivd")
+		} else {
+			dmIVDPE := NewDataManagerProtectedEntityTypeManager(ivdPETM, &snapMgr, true)
+			// We use a DataManagerPE to delegate data copy to the data manager
+			dpem.RegisterExternalProtectedEntityTypeManagers([]astrolabe.ProtectedEntityTypeManager{dmIVDPE})
+		}
 	}
 
 	logger.Infof("SnapshotManager is initialized with the configuration: %v", config)
@@ -225,6 +239,7 @@ func (this *SnapshotManager) createSnapshot(peID astrolabe.ProtectedEntityID, ta
 	// Pass the backup repository name as snapshot param.
 	guestSnapshotParams[constants.SnapshotParamBackupRepository] = backupRepositoryName
 	guestSnapshotParams[constants.SnapshotParamBackupName] = backupName
+	guestSnapshotParams[constants.SnapshotParamSnapshotRef] = snapshotRef
 
 	snapshotParams[peID.GetPeType()] = guestSnapshotParams
 
@@ -406,49 +421,14 @@ func (this *SnapshotManager) deleteSnapshot(peID astrolabe.ProtectedEntityID, ba
 			return err
 		}
 
-		var snapIDDecoded string
-		if peID.GetPeType() == astrolabe.IvdPEType {
-			log.Infof("The pe-id %s is not encoded.This maybe a request to delete the backup from plugin prior or equal to v1.0.2", peID.String())
-			// no need to decode.
-			// This occurs when deleting backups <=1.0.2, the pe-id is stored directly as ivd type.
-			snapIDDecoded = peID.GetSnapshotID().String()
-		} else {
-			snapIDDecoded, err = decodeSnapshotID(peID.GetSnapshotID(), this.FieldLogger)
-			if err != nil {
-				log.WithError(err).Errorf("Failed to retrieve decoded snapshot id to search for on-going uploads, original pe-id :%s", peID.String())
-				return err
-			}
-		}
-		uploadName := "upload-" + snapIDDecoded
-		log.Infof("Searching for Upload CR: %s", uploadName)
-		uploadCR, err := pluginClient.DatamoverV1alpha1().Uploads(veleroNs).Get(ctx, uploadName, metav1.GetOptions{})
+		uploadCR, err := this.getUploadForSnapshot(ctx, peID, pluginClient, veleroNs, log)
 		if err != nil {
-			log.WithError(err).Errorf(" Error while retrieving the upload CR %v", uploadName)
-			if k8serrors.IsNotFound(err) {
-				log.Errorf("The upload CR: %s was not found, assuming terminal stage", uploadName)
-			}
-			uploadCR = nil
+			return err
 		}
-		uploadCompleted := this.isTerminalState(uploadCR)
-		if uploadCR != nil && !uploadCompleted {
-			log.Infof("Found the Upload CR: %v, updating spec to indicate cancel upload.", uploadName)
-			timeNow := clock.RealClock{}
-			mutate := func(r *v1api.Upload) {
-				uploadCR.Spec.UploadCancel = true
-				uploadCR.Status.StartTimestamp = &metav1.Time{Time: timeNow.Now()}
-				uploadCR.Status.Message = "Canceling on going upload to repository."
-			}
-			_, err = utils.PatchUpload(uploadCR, mutate, pluginClient.DatamoverV1alpha1().Uploads(veleroNs), log)
-			if err != nil {
-				log.WithError(err).Error("Failed to patch ongoing Upload")
-				return err
-			} else {
-				log.Infof("Upload status updated to UploadCancel")
-			}
-			return nil
-		} else {
-			log.Infof("The upload %v was in terminal stage, proceeding with snapshot deletes", uploadName)
+		if !this.isTerminalState(uploadCR) {
+			return this.requestUploadCancel(uploadCR, pluginClient, veleroNs, log)
 		}
+		log.Infof("The upload for snapshot %v was in terminal stage, proceeding with snapshot deletes", peID.String())
 		if peID.GetPeType() == astrolabe.IvdPEType {
 			log.Infof("This maybe a request to delete the backup from plugin prior or equal to v1.0.2, using the incoming pe-id %s as is", peID.String())
 		} else {
@@ -525,11 +505,168 @@ func (this *SnapshotManager) deleteSnapshot(peID astrolabe.ProtectedEntityID, ba
 	return nil
 }
 
+// CancelSnapshot cancels the in-flight work for the given snapshot, and returns the phase which the snapshot lands in.
+// It returns Canceled once nothing is in flight anymore and the local snapshot has been deleted, and Canceling if the
+// cancellation is still pending, either on the Upload CR or, for Guest Clusters, on the Supervisor Cluster snapshot.
+// A snapshot whose data is already in the repository is not canceled, its phase is returned instead, e.g. Uploaded.
+func (this *SnapshotManager) CancelSnapshot(peID astrolabe.ProtectedEntityID, svcSnapshotName string) (backupdriverv1.SnapshotPhase, error) {
+	log := this.WithField("peID", peID.String())
+	log.Info("SnapshotManager.CancelSnapshot was called.")
+	ctx := context.Background()
+
+	if this.clusterFlavor == constants.TkgGuest {
+		return this.cancelSvcSnapshot(ctx, peID, svcSnapshotName, log)
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		log.WithError(err).Errorf("Failed to get k8s inClusterConfig")
+		return "", err
+	}
+	pluginClient, err := plugin_clientset.NewForConfig(config)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to get k8s clientset from the given config: %v ", config)
+		return "", err
+	}
+	veleroNs, exist := os.LookupEnv("VELERO_NAMESPACE")
+	if !exist {
+		errMsg := "Failed to lookup the env variable for velero namespace"
+		log.Error(errMsg)
+		return "", errors.New(errMsg)
+	}
+
+	uploadCR, err := this.getUploadForSnapshot(ctx, peID, pluginClient, veleroNs, log)
+	if err != nil {
+		return "", err
+	}
+	if uploadCR != nil {
+		switch uploadCR.Status.Phase {
+		case v1api.UploadPhaseCompleted:
+			// The data is already in the repository and the data manager takes care of the local snapshot
+			log.Infof("The upload %s is already in phase %s, nothing left to cancel", uploadCR.Name, uploadCR.Status.Phase)
+			return backupdriverv1.SnapshotPhaseUploaded, nil
+		case v1api.UploadPhaseCleanupFailed:
+			log.Infof("The upload %s is already in phase %s, nothing left to cancel", uploadCR.Name, uploadCR.Status.Phase)
+			return backupdriverv1.SnapshotPhaseCleanupFailed, nil
+		case v1api.UploadPhaseCanceled:
+			log.Infof("The upload %s was canceled", uploadCR.Name)
+		default:
+			if err := this.requestUploadCancel(uploadCR, pluginClient, veleroNs, log); err != nil {
+				return "", err
+			}
+			return backupdriverv1.SnapshotPhaseCanceling, nil
+		}
+	}
+
+	// Nothing is uploading the local snapshot anymore, delete it.
+	localPeID := peID
+	if peID.GetPeType() != astrolabe.IvdPEType {
+		localPeID, err = decodePeIdFromSnapshotID(peID.GetSnapshotID(), this.FieldLogger)
+		if err != nil {
+			errorMsg := fmt.Sprintf("Failed to translate snapshotID %s into pe-id", peID.GetSnapshotID().String())
+			log.Error(errorMsg)
+			return "", errors.Wrap(err, errorMsg)
+		}
+	}
+	if err := this.DeleteLocalSnapshot(localPeID); err != nil {
+		log.WithError(err).Errorf("Failed to delete the local snapshot %s of the canceled snapshot", localPeID.String())
+		return "", err
+	}
+	log.Infof("Deleted the local snapshot %s of the canceled snapshot", localPeID.String())
+	return backupdriverv1.SnapshotPhaseCanceled, nil
+}
+
+// cancelSvcSnapshot requests the cancellation of the Supervisor Cluster snapshot backing a Guest Cluster snapshot
+// through the paravirtualized component of the PVC, and returns the phase of the Supervisor Cluster snapshot.
+func (this *SnapshotManager) cancelSvcSnapshot(ctx context.Context, peID astrolabe.ProtectedEntityID, svcSnapshotName string,
+	log logrus.FieldLogger) (backupdriverv1.SnapshotPhase, error) {
+	if svcSnapshotName == "" {
+		errMsg := fmt.Sprintf("No Supervisor Cluster snapshot is associated with %s", peID.String())
+		log.Error(errMsg)
+		return "", errors.New(errMsg)
+	}
+	pe, err := this.Pem.GetProtectedEntity(ctx, peID)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to GetProtectedEntity for %s", peID.String())
+		return "", err
+	}
+	components, err := pe.GetComponents(ctx)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to get the components of %s", peID.String())
+		return "", err
+	}
+	for _, component := range components {
+		if paravirtPE, ok := component.(paravirt.ParaVirtProtectedEntity); ok {
+			return paravirtPE.CancelSnapshot(ctx, svcSnapshotName)
+		}
+	}
+	errMsg := fmt.Sprintf("No paravirtualized component found for %s", peID.String())
+	log.Error(errMsg)
+	return "", errors.New(errMsg)
+}
+
+// getUploadForSnapshot retrieves the Upload CR of the given snapshot, or nil if there is no such Upload CR. It fails
+// if the Upload CR cannot be retrieved for any other reason.
+func (this *SnapshotManager) getUploadForSnapshot(ctx context.Context, peID astrolabe.ProtectedEntityID,
+	pluginClient plugin_clientset.Interface, veleroNs string, log logrus.FieldLogger) (*v1api.Upload, error) {
+	var snapIDDecoded string
+	var err error
+	if peID.GetPeType() == astrolabe.IvdPEType {
+		log.Infof("The pe-id %s is not encoded.This maybe a request to delete the backup from plugin prior or equal to v1.0.2", peID.String())
+		// no need to decode.
+		// This occurs when deleting backups <=1.0.2, the pe-id is stored directly as ivd type.
+		snapIDDecoded = peID.GetSnapshotID().String()
+	} else {
+		snapIDDecoded, err = decodeSnapshotID(peID.GetSnapshotID(), this.FieldLogger)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to retrieve decoded snapshot id to search for on-going uploads, original pe-id :%s", peID.String())
+			return nil, err
+		}
+	}
+	uploadName := "upload-" + snapIDDecoded
+	log.Infof("Searching for Upload CR: %s", uploadName)
+	uploadCR, err := pluginClient.DatamoverV1alpha1().Uploads(veleroNs).Get(ctx, uploadName, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			log.Infof("The upload CR: %s was not found, assuming terminal stage", uploadName)
+			return nil, nil
+		}
+		// The upload may still be reading the local snapshot, it must not be deleted until the upload is known
+		log.WithError(err).Errorf(" Error while retrieving the upload CR %v", uploadName)
+		return nil, errors.Wrapf(err, "Failed to retrieve the upload CR %s", uploadName)
+	}
+	return uploadCR, nil
+}
+
+// requestUploadCancel updates the spec of the given Upload CR to indicate that the upload should be canceled.
+func (this *SnapshotManager) requestUploadCancel(uploadCR *v1api.Upload, pluginClient *plugin_clientset.Clientset,
+	veleroNs string, log logrus.FieldLogger) error {
+	if uploadCR.Spec.UploadCancel {
+		log.Infof("The cancellation of Upload CR: %v was already requested", uploadCR.Name)
+		return nil
+	}
+	log.Infof("Found the Upload CR: %v, updating spec to indicate cancel upload.", uploadCR.Name)
+	timeNow := clock.RealClock{}
+	mutate := func(r *v1api.Upload) {
+		r.Spec.UploadCancel = true
+		r.Status.StartTimestamp = &metav1.Time{Time: timeNow.Now()}
+		r.Status.Message = "Canceling on going upload to repository."
+	}
+	_, err := utils.PatchUpload(uploadCR, mutate, pluginClient.DatamoverV1alpha1().Uploads(veleroNs), log)
+	if err != nil {
+		log.WithError(err).Error("Failed to patch ongoing Upload")
+		return err
+	}
+	log.Infof("Upload status updated to UploadCancel")
+	return nil
+}
+
 func (this *SnapshotManager) isTerminalState(uploadCR *v1api.Upload) bool {
 	if uploadCR == nil {
 		return true
 	}
-	return uploadCR.Status.Phase == v1api.UploadPhaseCompleted || uploadCR.Status.Phase == v1api.UploadPhaseCleanupFailed || uploadCR.Status.Phase == v1api.UploadPhaseCanceled
+	return uploadCR.Status.Phase == v1api.UploadPhaseCompleted || uploadCR.Status.Phase == v1api.UploadPhaseCleanupFailed ||
+		uploadCR.Status.Phase == v1api.UploadPhaseCanceled || uploadCR.Status.Phase == v1api.UploadPhaseFailed
 }
 
 func (this *SnapshotManager) DeleteLocalSnapshot(peID astrolabe.ProtectedEntityID) error {
@@ -548,16 +685,38 @@ func (this *SnapshotManager) DeleteRemoteSnapshot(peID astrolabe.ProtectedEntity
 		logger.Errorf("DeleteRemoteSnapshot: Failed to get Default S3 repository")
 		return err
 	}
-	return this.deleteSnapshotFromRepo(peID, s3PETM)
+	// The snapshots consolidated on the deletion are read through the checksums they were uploaded with
+	return this.deleteRemoteSnapshotFromRepo(peID, checksum.NewProtectedEntityTypeManager(s3PETM, logger))
 }
 
 func (this *SnapshotManager) DeleteRemoteSnapshotFromRepo(peID astrolabe.ProtectedEntityID, backupRepository *backupdriverv1.BackupRepository) error {
 	this.WithField("peID", peID.String()).Infof("SnapshotManager.deleteRemoteSnapshotFromRepo Called")
-	s3PETM, err := backuprepository.GetRepositoryFromBackupRepository(backupRepository, this.FieldLogger)
+	repositoryPETM, err := backuprepository.GetRepositoryFromBackupRepository(backupRepository, this.FieldLogger)
 	if err != nil {
-		this.WithError(err).Errorf("Failed to create s3PETM from backup repository %s", backupRepository.Name)
+		this.WithError(err).Errorf("Failed to create repository PETM from backup repository %s", backupRepository.Name)
+		return err
 	}
-	return this.deleteSnapshotFromRepo(peID, s3PETM)
+	return this.deleteRemoteSnapshotFromRepo(peID, repositoryPETM)
+}
+
+// deleteRemoteSnapshotFromRepo deletes the snapshot from the remote repository, after merging it into the incremental
+// snapshots which depend on it.
+func (this *SnapshotManager) deleteRemoteSnapshotFromRepo(peID astrolabe.ProtectedEntityID, petm astrolabe.ProtectedEntityTypeManager) error {
+	if !peID.HasSnapshot() {
+		return this.deleteSnapshotFromRepo(peID, petm)
+	}
+	if err := incremental.Consolidate(context.Background(), petm, peID, this.FieldLogger); err != nil {
+		this.WithError(err).Errorf("Failed to consolidate the snapshot %s into its incremental snapshots", peID.String())
+		return err
+	}
+	if err := this.deleteSnapshotFromRepo(peID, petm); err != nil {
+		return err
+	}
+	if err := incremental.CompleteDeletion(context.Background(), petm, peID); err != nil {
+		// The mark is dropped by the next deletion of a snapshot of the volume
+		this.WithError(err).Warnf("Failed to remove the deletion mark of the snapshot %s", peID.String())
+	}
+	return nil
 }
 
 func (this *SnapshotManager) deleteSnapshotFromRepo(peID astrolabe.ProtectedEntityID, petm astrolabe.ProtectedEntityTypeManager) error {
@@ -671,9 +830,28 @@ func (this *SnapshotManager) CreateVolumeFromSnapshot(sourcePEID astrolabe.Prote
 		return
 	}
 
+	downloadTimeout := this.getDownloadTimeout()
+	var pollTimeout <-chan time.Time
+	if downloadTimeout > 0 {
+		this.Infof("Waiting for download record %s to complete in %v", downloadRecordName, downloadTimeout)
+		timer := time.NewTimer(downloadTimeout)
+		defer timer.Stop()
+		pollTimeout = timer.C
+	}
 	lastPollLogTime := time.Now()
-	// TODO: Suitable length of timeout
+	downloadCancelRequested := false
 	err = wait.PollImmediateInfinite(time.Second, func() (bool, error) {
+		select {
+		case <-pollTimeout:
+			this.Errorf("Download record %s did not complete in %v, canceling it", downloadRecordName, downloadTimeout)
+			patchBytes := []byte(`{"spec":{"downloadCancel":true}}`)
+			_, cancelErr := pluginClient.DatamoverV1alpha1().Downloads(veleroNs).Patch(context.TODO(), downloadRecordName, k8stypes.MergePatchType, patchBytes, metav1.PatchOptions{})
+			if cancelErr != nil {
+				this.WithError(cancelErr).Warnf("Failed to request the cancellation of download record %s", downloadRecordName)
+			}
+			return false, errors.Errorf("Timed out after %v waiting for download record %s to complete", downloadTimeout, downloadRecordName)
+		default:
+		}
 		infoLog := false
 		if time.Now().Sub(lastPollLogTime) > PollLogInterval {
 			infoLog = true
@@ -689,8 +867,23 @@ func (this *SnapshotManager) CreateVolumeFromSnapshot(sourcePEID astrolabe.Prote
 			this.Infof("Download record %s completed", downloadRecordName)
 			return true, nil
 		} else if download.Status.Phase == v1api.DownloadPhaseFailed {
-			return false, errors.Errorf("Create download cr failed.")
+			this.Errorf("Download record %s failed: %s", downloadRecordName, download.Status.Message)
+			if cloneFromSnapshotNameExists && cloneFromSnapshotNamespaceExists {
+				// The failure of the download is terminal, e.g. the encryption key of the snapshot is missing
+				this.failCloneFromSnapshot(pluginClient, cloneFromSnapshotNamespace, cloneFromSnapshotName, download.Status.Message)
+			}
+			return false, errors.Errorf("Download record %s failed: %s", downloadRecordName, download.Status.Message)
+		} else if download.Status.Phase == v1api.DownloadPhaseCanceled {
+			this.Infof("Download record %s canceled", downloadRecordName)
+			return false, errors.Errorf("Download record %s was canceled.", downloadRecordName)
 		} else {
+			if !downloadCancelRequested && cloneFromSnapshotNameExists && cloneFromSnapshotNamespaceExists {
+				downloadCancelRequested, err = this.cancelDownloadIfCloneCanceled(pluginClient, download, cloneFromSnapshotNamespace, cloneFromSnapshotName)
+				if err != nil {
+					// Retry on the next poll
+					this.WithError(err).Warnf("Failed to check the cancellation of CloneFromSnapshot %s", cloneRef)
+				}
+			}
 			if infoLog {
 				this.Infof("Retrieve phase %s for download record %s", download.Status.Phase, downloadRecordName)
 			}
@@ -734,6 +927,66 @@ func (this *SnapshotManager) CreateVolumeFromSnapshot(sourcePEID astrolabe.Prote
 	return
 }
 
+// getDownloadTimeout returns the overall timeout of waiting for a download to complete, as configured with the
+// VolumeSnapshotterDownloadTimeout key. A non-positive timeout means waiting until the download reaches a terminal phase.
+func (this *SnapshotManager) getDownloadTimeout() time.Duration {
+	timeoutStr, ok := this.config[constants.VolumeSnapshotterDownloadTimeout]
+	if !ok || timeoutStr == "" {
+		return constants.DefaultDownloadTimeout
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil {
+		this.WithError(err).Warnf("Invalid %s %s, using the default %v", constants.VolumeSnapshotterDownloadTimeout, timeoutStr, constants.DefaultDownloadTimeout)
+		return constants.DefaultDownloadTimeout
+	}
+	return timeout
+}
+
+// cancelDownloadIfCloneCanceled requests the cancellation of the download if the CloneFromSnapshot it serves was
+// canceled, and moves the CloneFromSnapshot to Canceling. It returns true once the cancellation was requested.
+func (this *SnapshotManager) cancelDownloadIfCloneCanceled(pluginClient plugin_clientset.Interface, download *v1api.Download,
+	cloneFromSnapshotNamespace string, cloneFromSnapshotName string) (bool, error) {
+	cloneFromSnap, err := pluginClient.BackupdriverV1alpha1().CloneFromSnapshots(cloneFromSnapshotNamespace).Get(context.TODO(), cloneFromSnapshotName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	if !cloneFromSnap.Spec.CloneCancel {
+		return false, nil
+	}
+	this.Infof("CloneFromSnapshot %s/%s was canceled, canceling download record %s", cloneFromSnapshotNamespace, cloneFromSnapshotName, download.Name)
+	patchBytes := []byte(`{"spec":{"downloadCancel":true}}`)
+	_, err = pluginClient.DatamoverV1alpha1().Downloads(download.Namespace).Patch(context.TODO(), download.Name, k8stypes.MergePatchType, patchBytes, metav1.PatchOptions{})
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to request the cancellation of download record %s", download.Name)
+	}
+	clone := cloneFromSnap.DeepCopy()
+	clone.Status.Phase = backupdriverv1.ClonePhaseCanceling
+	clone.Status.Message = "Canceling the download from repository."
+	_, err = pluginClient.BackupdriverV1alpha1().CloneFromSnapshots(cloneFromSnapshotNamespace).UpdateStatus(context.TODO(), clone, metav1.UpdateOptions{})
+	if err != nil {
+		// The cancellation is already requested, the CloneFromSnapshot will land in Canceled regardless
+		this.WithError(err).Warnf("Failed to update status of CloneFromSnapshot %s/%s to %v", cloneFromSnapshotNamespace, cloneFromSnapshotName, clone.Status.Phase)
+	}
+	return true, nil
+}
+
+// failCloneFromSnapshot moves the CloneFromSnapshot to the Failed phase with the message of the failed download.
+func (this *SnapshotManager) failCloneFromSnapshot(pluginClient *plugin_clientset.Clientset, cloneFromSnapshotNamespace string,
+	cloneFromSnapshotName string, msg string) {
+	cloneFromSnap, err := pluginClient.BackupdriverV1alpha1().CloneFromSnapshots(cloneFromSnapshotNamespace).Get(context.TODO(), cloneFromSnapshotName, metav1.GetOptions{})
+	if err != nil {
+		this.WithError(err).Errorf("Failed to get CloneFromSnapshot %s/%s", cloneFromSnapshotNamespace, cloneFromSnapshotName)
+		return
+	}
+	clone := cloneFromSnap.DeepCopy()
+	clone.Status.Phase = backupdriverv1.ClonePhaseFailed
+	clone.Status.Message = msg
+	_, err = pluginClient.BackupdriverV1alpha1().CloneFromSnapshots(cloneFromSnapshotNamespace).UpdateStatus(context.TODO(), clone, metav1.UpdateOptions{})
+	if err != nil {
+		this.WithError(err).Errorf("Failed to update status of CloneFromSnapshot %s/%s to %v", cloneFromSnapshotNamespace, cloneFromSnapshotName, clone.Status.Phase)
+	}
+}
+
 func (this *SnapshotManager) CreateVolumeFromSnapshotWithMetadata(peID astrolabe.ProtectedEntityID, metadata []byte,
 	snapshotIDStr string, backupRepositoryName string, cloneFromSnapshotNamespace string, cloneFromSnapshotName string) (astrolabe.ProtectedEntityID, error) {
 	this.Infof("CreateVolumeFromSnapshotWithMetadata: Start creating restore for %s, snapshot ID %s, backupRepositoryName %s, cloneFromSnapshot %s/%s", peID.String(), snapshotIDStr, backupRepositoryName, cloneFromSnapshotNamespace, cloneFromSnapshotName)
@@ -756,17 +1009,38 @@ func (this *SnapshotManager) CreateVolumeFromSnapshotWithMetadata(peID astrolabe
 			}
 			snapshotRepo, err = backuprepository.GetRepositoryFromBackupRepository(backupRepository, this)
 			if err != nil {
-				this.WithError(err).Errorf("Failed to get S3 repository from backup repository %s", backupRepository.Name)
+				this.WithError(err).Errorf("Failed to get repository from backup repository %s", backupRepository.Name)
 				return astrolabe.ProtectedEntityID{}, err
 			}
 
 			peTM := this.Pem.GetProtectedEntityTypeManager(peID.GetPeType())
 			pvcPETM := peTM.(*astrolabe_pvc.PVCProtectedEntityTypeManager)
 
+			kubeClient, err := this.getKubeClient()
+			if err != nil {
+				return astrolabe.ProtectedEntityID{}, err
+			}
+			pvc := v1.PersistentVolumeClaim{}
+			if err := pvc.Unmarshal(metadata); err != nil {
+				this.WithError(err).Errorf("Error extracting metadata into PVC")
+				return astrolabe.ProtectedEntityID{}, errors.Wrap(err, "Error extracting metadata into PVC")
+			}
+			_, err = kubeClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+			pvcExisted := err == nil
+
 			this.Infof("Ready to call astrolabe CreateFromMetadata API: snapshot ID %s", snapshotID.String())
 			pe, err := pvcPETM.CreateFromMetadata(ctx, metadata, snapshotID, snapshotRepo, cloneFromSnapshotNamespace, cloneFromSnapshotName, backupRepositoryName)
 			if err != nil {
 				this.WithError(err).Errorf("Error creating volume from metadata")
+				if !pvcExisted {
+					pluginClient, clientErr := this.getPluginClient()
+					if clientErr != nil {
+						return astrolabe.ProtectedEntityID{}, clientErr
+					}
+					if deleteErr := this.deleteCanceledRestore(ctx, kubeClient, pluginClient, &pvc, cloneFromSnapshotNamespace, cloneFromSnapshotName); deleteErr != nil {
+						return astrolabe.ProtectedEntityID{}, deleteErr
+					}
+				}
 				return astrolabe.ProtectedEntityID{}, errors.Wrap(err, "Error creating volume from metadata")
 			}
 			this.Infof("CreateVolumeFromSnapshotWithMetadata: PE returned by CreateFromMetadata: %s", pe.GetID().String())
@@ -794,7 +1068,65 @@ func (this *SnapshotManager) CreateVolumeFromSnapshotWithMetadata(peID astrolabe
 	return pe.GetID(), err
 }
 
-func (this *SnapshotManager) ReloadSnapshotManagerIvdPetmConfig(ivdParams map[string]interface{}) error {
+func (this *SnapshotManager) getKubeClient() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		this.WithError(err).Errorf("Failed to get k8s inClusterConfig")
+		return nil, err
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		this.WithError(err).Errorf("Failed to get k8s clientset with the given config: %v", config)
+		return nil, err
+	}
+	return kubeClient, nil
+}
+
+func (this *SnapshotManager) getPluginClient() (plugin_clientset.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		this.WithError(err).Errorf("Failed to get k8s inClusterConfig")
+		return nil, err
+	}
+	pluginClient, err := plugin_clientset.NewForConfig(config)
+	if err != nil {
+		this.WithError(err).Errorf("Failed to get k8s clientset with the given config: %v", config)
+		return nil, err
+	}
+	return pluginClient, nil
+}
+
+// deleteCanceledRestore deletes the PVC created by a failed restore if the CloneFromSnapshot of the restore was
+// canceled. The caller must only call it for the PVCs which did not exist before the restore. The underlying FCD is
+// deleted along with the PVC, and the FCD of a canceled copy from the repository is deleted by the copy.
+func (this *SnapshotManager) deleteCanceledRestore(ctx context.Context, kubeClient kubernetes.Interface, pluginClient plugin_clientset.Interface,
+	pvc *v1.PersistentVolumeClaim, cloneFromSnapshotNamespace string, cloneFromSnapshotName string) error {
+	if !this.isCloneCanceled(pluginClient, cloneFromSnapshotNamespace, cloneFromSnapshotName) {
+		return nil
+	}
+	this.Infof("CloneFromSnapshot %s/%s was canceled, deleting the partially restored PVC %s/%s", cloneFromSnapshotNamespace, cloneFromSnapshotName, pvc.Namespace, pvc.Name)
+	err := kubeClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Delete(ctx, pvc.Name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		this.WithError(err).Errorf("Failed to delete the partially restored PVC %s/%s", pvc.Namespace, pvc.Name)
+		return errors.Wrapf(err, "Failed to delete the partially restored PVC %s/%s", pvc.Namespace, pvc.Name)
+	}
+	return nil
+}
+
+func (this *SnapshotManager) isCloneCanceled(pluginClient plugin_clientset.Interface, cloneFromSnapshotNamespace string, cloneFromSnapshotName string) bool {
+	if cloneFromSnapshotNamespace == "" || cloneFromSnapshotName == "" {
+		return false
+	}
+	cloneFromSnap, err := pluginClient.BackupdriverV1alpha1().CloneFromSnapshots(cloneFromSnapshotNamespace).Get(context.TODO(), cloneFromSnapshotName, metav1.GetOptions{})
+	if err != nil {
+		this.WithError(err).Errorf("Failed to get CloneFromSnapshot %s/%s", cloneFromSnapshotNamespace, cloneFromSnapshotName)
+		return false
+	}
+	return cloneFromSnap.Spec.CloneCancel
+}
+
+// ReloadSnapshotManagerIvdPetmConfig reloads the config of each vCenter, see multivc.ProtectedEntityTypeManager.ReloadConfig.
+func (this *SnapshotManager) ReloadSnapshotManagerIvdPetmConfig(vcParams []map[string]interface{}) error {
 
 	petm := this.Pem.GetProtectedEntityTypeManager("ivd")
 	if petm == nil {
@@ -803,7 +1135,7 @@ func (this *SnapshotManager) ReloadSnapshotManagerIvdPetmConfig(ivdParams map[st
 	dmPetm, ok := petm.(DataManagerProtectedEntityTypeManager)
 	if ok {
 		this.Debugf("Found DataManagerProtectedEntityTypeManager during snapshot IVD Petm Reload")
-		err := dmPetm.ReloadDmIvdPetm(context.TODO(), ivdParams, this.FieldLogger)
+		err := dmPetm.ReloadDmIvdPetm(context.TODO(), vcParams, this.FieldLogger)
 		if err != nil {
 			return errors.Wrapf(err, "Failed to Reload IVD Config in SnapshotManager.")
 		}
@@ -812,6 +1144,23 @@ func (this *SnapshotManager) ReloadSnapshotManagerIvdPetmConfig(ivdParams map[st
 	return errors.New("Failed to find ivd petm associated with snapshot manager.")
 }
 
+// ReloadSnapshotManagerParaVirtPetmConfig rebuilds the Supervisor Cluster clients of the paravirtualized PETM in the guest cluster.
+func (this *SnapshotManager) ReloadSnapshotManagerParaVirtPetmConfig(svcConfig *rest.Config, svcNamespace string) error {
+	petm := this.Pem.GetProtectedEntityTypeManager(paravirt.ParaVirtPETypePrefix + paravirt.ParaVirtPETypeSep + string(paravirt.ParaVirtEntityTypePersistentVolume))
+	if petm == nil {
+		return errors.New("Failed to retrieve paravirtualized Protected Type Manager from Snapshot Manager")
+	}
+	paravirtPetm, ok := petm.(*paravirt.ParaVirtProtectedEntityTypeManager)
+	if !ok {
+		return errors.New("Failed to find paravirtualized petm associated with snapshot manager.")
+	}
+	err := paravirtPetm.ReloadSupervisorConfig(svcConfig, svcNamespace)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to Reload Supervisor Config in SnapshotManager.")
+	}
+	return nil
+}
+
 func uploadCRNameForSnapshotPEID(snapshotPEID astrolabe.ProtectedEntityID) (string, error) {
 	if !snapshotPEID.HasSnapshot() {
 		return "", errors.New(fmt.Sprintf("snapshotPEID %s does not have a snapshot ID", snapshotPEID.String()))
@@ -819,6 +1168,16 @@ func uploadCRNameForSnapshotPEID(snapshotPEID astrolabe.ProtectedEntityID) (stri
 	return "upload-" + snapshotPEID.GetSnapshotID().String(), nil
 }
 
+// GetRepositoryPeID returns the pe-id under which the snapshot recorded in a backup is stored in the backup repository,
+// i.e. the pe-id of the snapshot of the component of a pvc pe-id. An ivd pe-id, as recorded by the plugin prior or
+// equal to v1.0.2, is returned as is.
+func GetRepositoryPeID(peID astrolabe.ProtectedEntityID, logger logrus.FieldLogger) (astrolabe.ProtectedEntityID, error) {
+	if peID.GetPeType() == astrolabe.IvdPEType {
+		return peID, nil
+	}
+	return decodePeIdFromSnapshotID(peID.GetSnapshotID(), logger)
+}
+
 func decodePeIdFromSnapshotID(snapshotID astrolabe.ProtectedEntitySnapshotID, logger logrus.FieldLogger) (astrolabe.ProtectedEntityID, error) {
 	var peId astrolabe.ProtectedEntityID
 	snapshotID64Str := snapshotID.String()
@@ -18,10 +18,21 @@ package snapshotmgr
 
 import (
 	"context"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
+	backupdriverv1 "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
 	v1api "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/datamover/v1alpha1"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/builder"
 	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/fake"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"testing"
 	"time"
 )
@@ -57,3 +68,128 @@ func TestDownload_Creation(t *testing.T) {
 
 	pluginClient.DatamoverV1alpha1().Downloads("velero").Create(context.TODO(), download, metav1.CreateOptions{})
 }
+
+func TestGetUploadForSnapshot(t *testing.T) {
+	peID := astrolabe.NewProtectedEntityIDWithSnapshotID(astrolabe.IvdPEType, "fcd-1", astrolabe.NewProtectedEntitySnapshotID("snap-1"))
+	snapManager := &SnapshotManager{FieldLogger: logrus.New()}
+
+	tests := []struct {
+		name       string
+		uploads    []runtime.Object
+		getErr     error
+		wantUpload bool
+		wantErr    bool
+	}{
+		{
+			name:       "Upload found",
+			uploads:    []runtime.Object{builder.ForUpload("velero", "upload-snap-1").Phase(v1api.UploadPhaseInProgress).Result()},
+			wantUpload: true,
+		},
+		{
+			name: "Upload not found",
+		},
+		{
+			name:    "Upload not retrieved",
+			getErr:  errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pluginClient := fake.NewSimpleClientset(test.uploads...)
+			if test.getErr != nil {
+				pluginClient.PrependReactor("get", "uploads", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, test.getErr
+				})
+			}
+			upload, err := snapManager.getUploadForSnapshot(context.TODO(), peID, pluginClient, "velero", logrus.New())
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantUpload, upload != nil)
+		})
+	}
+}
+
+func newCloneFromSnapshot(canceled bool) *backupdriverv1.CloneFromSnapshot {
+	return &backupdriverv1.CloneFromSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns-1", Name: "clone-1"},
+		Spec:       backupdriverv1.CloneFromSnapshotSpec{CloneCancel: canceled},
+		Status:     backupdriverv1.CloneStatus{Phase: backupdriverv1.ClonePhaseInProgress},
+	}
+}
+
+func TestCancelDownloadIfCloneCanceled(t *testing.T) {
+	for _, canceled := range []bool{false, true} {
+		download := &v1api.Download{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "velero", Name: "download-1"},
+			Status:     v1api.DownloadStatus{Phase: v1api.DownloadPhaseInProgress},
+		}
+		pluginClient := fake.NewSimpleClientset(newCloneFromSnapshot(canceled), download)
+		snapMgr := &SnapshotManager{FieldLogger: logrus.New()}
+
+		requested, err := snapMgr.cancelDownloadIfCloneCanceled(pluginClient, download, "ns-1", "clone-1")
+		require.NoError(t, err)
+		assert.Equal(t, canceled, requested)
+
+		download, err = pluginClient.DatamoverV1alpha1().Downloads("velero").Get(context.TODO(), "download-1", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, canceled, download.Spec.DownloadCancel)
+		clone, err := pluginClient.BackupdriverV1alpha1().CloneFromSnapshots("ns-1").Get(context.TODO(), "clone-1", metav1.GetOptions{})
+		require.NoError(t, err)
+		if canceled {
+			assert.Equal(t, backupdriverv1.ClonePhaseCanceling, clone.Status.Phase)
+		} else {
+			assert.Equal(t, backupdriverv1.ClonePhaseInProgress, clone.Status.Phase)
+		}
+	}
+}
+
+func TestDeleteCanceledRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		canceled  bool
+		cloneName string
+		deleted   bool
+	}{
+		{
+			name:      "The PVC of a canceled clone is deleted",
+			canceled:  true,
+			cloneName: "clone-1",
+			deleted:   true,
+		},
+		{
+			name:      "The PVC of a failed clone is kept",
+			canceled:  false,
+			cloneName: "clone-1",
+			deleted:   false,
+		},
+		{
+			name:      "The PVC of a restore without a clone is kept",
+			canceled:  true,
+			cloneName: "",
+			deleted:   false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns-1", Name: "pvc-1"}}
+			kubeClient := kubefake.NewSimpleClientset(pvc)
+			pluginClient := fake.NewSimpleClientset(newCloneFromSnapshot(test.canceled))
+			snapMgr := &SnapshotManager{FieldLogger: logrus.New()}
+
+			err := snapMgr.deleteCanceledRestore(context.TODO(), kubeClient, pluginClient, pvc, "ns-1", test.cloneName)
+			require.NoError(t, err)
+			_, err = kubeClient.CoreV1().PersistentVolumeClaims("ns-1").Get(context.TODO(), "pvc-1", metav1.GetOptions{})
+			assert.Equal(t, test.deleted, k8serrors.IsNotFound(err))
+		})
+	}
+
+	// The PVC may already be gone
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns-1", Name: "pvc-1"}}
+	snapMgr := &SnapshotManager{FieldLogger: logrus.New()}
+	err := snapMgr.deleteCanceledRestore(context.TODO(), kubefake.NewSimpleClientset(), fake.NewSimpleClientset(newCloneFromSnapshot(true)), pvc, "ns-1", "clone-1")
+	assert.NoError(t, err)
+}
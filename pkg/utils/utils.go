@@ -27,6 +27,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"net"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -43,7 +45,9 @@ import (
 	"github.com/vmware-tanzu/astrolabe/pkg/astrolabe"
 	"github.com/vmware-tanzu/astrolabe/pkg/ivd"
 	"github.com/vmware-tanzu/astrolabe/pkg/s3repository"
+	backupdriverv1api "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/backupdriver/v1alpha1"
 	pluginv1api "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/apis/datamover/v1alpha1"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/fsrepository"
 	plugin_clientset "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned"
 	pluginv1client "github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/generated/clientset/versioned/typed/datamover/v1alpha1"
 	v1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
@@ -58,21 +62,43 @@ import (
 
 /*
  * In the CSI setup, VC credential is stored as a secret
- * under the kube-system namespace.
+ * under the kube-system namespace. RetrieveVcConfigSecret
+ * adds the params of the first VirtualCenter to the params.
  */
 func RetrieveVcConfigSecret(params map[string]interface{}, config *rest.Config, logger logrus.FieldLogger) error {
+	vcParams, err := RetrieveVcConfigs(config, logger)
+	if err != nil {
+		return err
+	}
+	for key, value := range vcParams[0] {
+		params[key] = value
+	}
+	return nil
+}
+
+// RetrieveVcConfigs retrieves the configuration of each VirtualCenter in the VC config secret, in the order of the
+// secret. The secret lists multiple VirtualCenters when the cluster is stretched across vCenters.
+func RetrieveVcConfigs(config *rest.Config, logger logrus.FieldLogger) ([]map[string]interface{}, error) {
+	vsphereConfig, err := retrieveVSphereConfig(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	return vsphereConfig.VcParams(), nil
+}
+
+func retrieveVSphereConfig(config *rest.Config, logger logrus.FieldLogger) (*VSphereConfig, error) {
 	var err error // Declare here to avoid shadowing on config using := with rest.InClusterConfig
 	if config == nil {
 		config, err = rest.InClusterConfig()
 		if err != nil {
 			logger.WithError(err).Errorf("Failed to get k8s inClusterConfig")
-			return errors.Wrap(err, "Could not retrieve in-cluster config")
+			return nil, errors.Wrap(err, "Could not retrieve in-cluster config")
 		}
 	}
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		logger.WithError(err).Errorf("Failed to get k8s clientset from the given config: %v", config)
-		return err
+		return nil, err
 	}
 
 	// Get the cluster flavor
@@ -80,7 +106,7 @@ func RetrieveVcConfigSecret(params map[string]interface{}, config *rest.Config,
 	clusterFlavor, err := GetClusterFlavor(config)
 	if clusterFlavor == constants.TkgGuest || clusterFlavor == constants.Unknown {
 		logger.Errorf("RetrieveVcConfigSecret: Cannot retrieve VC secret in cluster flavor %s", clusterFlavor)
-		return errors.New("RetrieveVcConfigSecret: Cannot retrieve VC secret")
+		return nil, errors.New("RetrieveVcConfigSecret: Cannot retrieve VC secret")
 	} else if clusterFlavor == constants.Supervisor {
 		ns = constants.VCSecretNsSupervisor
 	} else {
@@ -102,119 +128,173 @@ func RetrieveVcConfigSecret(params map[string]interface{}, config *rest.Config,
 	// No valid secret found.
 	if err != nil {
 		logger.WithError(err).Errorf("Failed to get k8s secret, %s", vsphere_secrets)
-		return err
-	}
-	// No kv pairs in the secret.
-	if len(secret.Data) == 0 {
-		errMsg := fmt.Sprintf("Failed to get any data in k8s secret, %s", vsphere_secrets)
-		logger.Errorf(errMsg)
-		return errors.New(errMsg)
+		return nil, err
 	}
 
-	var sEnc string
-	var lines []string
-	for _, value := range secret.Data {
-		sEnc = string(value)
-		lines = strings.Split(sEnc, "\n")
-		logger.Debugf("Successfully retrieved vCenter configuration from secret %s", secret.Name)
-		break
+	key, err := getVcConfigSecretKey(secret)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get the vSphere config from the VC config secret")
+		return nil, err
 	}
-
-	ParseLines(lines, params, logger)
-
-	// If port is missing, add an entry in the params to use the standard https port
-	if _, ok := params["port"]; !ok {
-		params["port"] = constants.DefaultVCenterPort
+	vsphereConfig, err := ParseVSphereConfig(string(secret.Data[key]))
+	if err == nil {
+		err = vsphereConfig.Validate()
 	}
-
-	return nil
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid vSphere config in the key %s of the secret %s/%s", key, secret.Namespace, secret.Name)
+		logger.WithError(err).Error("Failed to parse the vSphere config")
+		return nil, err
+	}
+	for _, warning := range vsphereConfig.Warnings() {
+		logger.Warnf("vSphere config in the key %s of the secret %s/%s: %s", key, secret.Namespace, secret.Name, warning)
+	}
+	logger.Debugf("Successfully retrieved vCenter configuration from secret %s", secret.Name)
+	return vsphereConfig, nil
 }
 
-func ParseLines(lines []string, params map[string]interface{}, logger logrus.FieldLogger) {
-	for _, line := range lines {
-		if strings.Contains(line, "VirtualCenter") {
-			parts := strings.Split(line, "\"")
-			params["VirtualCenter"] = parts[1]
-		} else if strings.Contains(line, "=") {
-			parts := strings.SplitN(line, "=", 2)
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			// Skip the quotes in the value if present
-			unquotedValue, err := strconv.Unquote(string(value))
-			if err != nil {
-				logger.WithError(err).Debugf("Failed to unquote value %v for key %v. Just store the original value string", value, key)
-				params[key] = string(value)
-				continue
-			}
-			params[key] = unquotedValue
-		}
-	}
+// getVcConfigSecretKey returns the key of the vSphere config in the VC config secret. A secret with a single key
+// is accepted whatever its key, as with the earlier releases.
+func getVcConfigSecretKey(secret *k8sv1.Secret) (string, error) {
+	if _, ok := secret.Data[constants.VCSecretKey]; ok {
+		return constants.VCSecretKey, nil
+	}
+	var keys []string
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	switch len(keys) {
+	case 0:
+		return "", errors.Errorf("The secret %s/%s has no data, the vSphere config is expected in its key %s",
+			secret.Namespace, secret.Name, constants.VCSecretKey)
+	case 1:
+		return keys[0], nil
+	}
+	sort.Strings(keys)
+	return "", errors.Errorf("The secret %s/%s has no key %s but the keys %s, put the vSphere config in the key %s",
+		secret.Namespace, secret.Name, constants.VCSecretKey, strings.Join(keys, ", "), constants.VCSecretKey)
 }
 
+// RetrieveParamsFromBSL translates the BSL into repository parameters. The credentials of the BSL are never copied
+// into the parameters, a reference to the key of the cloud credential Secret is returned instead.
 func RetrieveParamsFromBSL(repositoryParams map[string]string, bslName string, config *rest.Config,
-	logger logrus.FieldLogger) error {
+	logger logrus.FieldLogger) (*backupdriverv1api.SecretKeyReference, error) {
 	s3RepoParams := make(map[string]interface{})
 	err := RetrieveVSLFromVeleroBSLs(s3RepoParams, bslName, config, logger)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	//Translate s3RepoParams to repositoryParams.
 	for key, val := range s3RepoParams {
 		paramValue, ok := val.(string)
 		if !ok {
-			return errors.Errorf("Failed to translate s3 repository parameter value: %v", val)
+			return nil, errors.Errorf("Failed to translate s3 repository parameter value: %v", val)
 		}
 		repositoryParams[key] = paramValue
 	}
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return errors.Wrap(err, "Failed to retrieve the k8s clientset")
+		return nil, errors.Wrap(err, "Failed to retrieve the k8s clientset")
 	}
 
 	veleroNs, exist := os.LookupEnv("VELERO_NAMESPACE")
 	if !exist {
 		logger.Errorf("RetrieveParamsFromBSL: Failed to lookup the env variable for velero namespace")
-		return err
+		return nil, errors.New("Failed to lookup the env variable for velero namespace")
 	}
 
 	secretsClient := clientset.CoreV1().Secrets(veleroNs)
 	secret, err := secretsClient.Get(context.TODO(), constants.CloudCredentialSecretName, metav1.GetOptions{})
 	if err != nil {
 		logger.Errorf("RetrieveParamsFromBSL: Failed to retrieve the Secret for %s", constants.CloudCredentialSecretName)
-		return err
+		return nil, err
 	}
 
-	for _, value := range secret.Data {
-		tmpfile, err := ioutil.TempFile("", "temp-aws-cred")
-		if err != nil {
-			return errors.Wrap(err, "Failed to create temp file to extract aws credentials")
-		}
-		// Cleanup
-		defer os.Remove(tmpfile.Name())
+	secretKey, err := GetCloudCredentialSecretKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	// Make sure that the credentials for the profile extracted from BSL are available before referencing them.
+	if _, err := ParseAWSSharedCredentials(secret.Data[secretKey], repositoryParams["profile"]); err != nil {
+		logger.Errorf("RetrieveParamsFromBSL: Failed to extract credentials for profile :%s", repositoryParams["profile"])
+		return nil, err
+	}
+	logger.Infof("Successfully retrieved AWS credentials for the BackupStorageLocation.")
 
-		// Writing the encoded value into into a temporary file.
-		// The file is in a non-standard format, aws APIs recognize the format.
-		if _, err := tmpfile.Write(value); err != nil {
-			return errors.Wrap(err, "Failed to write aws credentials into temp file.")
-		}
-		if err := tmpfile.Close(); err != nil {
-			return errors.Wrap(err, "Failed to close into temp file.")
-		}
-		// Extract the right set of credentials based on the profile extracted from BSL.
-		awsCredentials := credentials.NewSharedCredentials(tmpfile.Name(), repositoryParams["profile"])
-		awsPlainCred, err := awsCredentials.Get()
-		if err != nil {
-			logger.Errorf("RetrieveParamsFromBSL: Failed to extract credentials for profile :%s", repositoryParams["profile"])
-			return err
-		}
-		repositoryParams[constants.AWS_ACCESS_KEY_ID] = awsPlainCred.AccessKeyID
-		repositoryParams[constants.AWS_SECRET_ACCESS_KEY] = awsPlainCred.SecretAccessKey
-		logger.Infof("Successfully retrieved AWS credentials for the BackupStorageLocation.")
-		//Breaking since its expected to have only one kv pair for the secret data.
-		break
+	return &backupdriverv1api.SecretKeyReference{
+		Name:      secret.Name,
+		Namespace: secret.Namespace,
+		Key:       secretKey,
+	}, nil
+}
+
+// GetCloudCredentialSecretKey returns the key of the credentials in the cloud credential Secret.
+// It is expected to have only one kv pair for the secret data, the key used by velero is preferred otherwise.
+func GetCloudCredentialSecretKey(secret *k8sv1.Secret) (string, error) {
+	if _, ok := secret.Data[constants.CloudCredentialSecretKey]; ok {
+		return constants.CloudCredentialSecretKey, nil
+	}
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
 	}
+	if len(keys) == 0 {
+		return "", errors.Errorf("No credentials found in the Secret %s/%s", secret.Namespace, secret.Name)
+	}
+	sort.Strings(keys)
+	return keys[0], nil
+}
 
+// ParseAWSSharedCredentials extracts the credentials of the profile from the content of an AWS shared credentials file.
+func ParseAWSSharedCredentials(data []byte, profile string) (credentials.Value, error) {
+	tmpfile, err := ioutil.TempFile("", "temp-aws-cred")
+	if err != nil {
+		return credentials.Value{}, errors.Wrap(err, "Failed to create temp file to extract aws credentials")
+	}
+	// Cleanup
+	defer os.Remove(tmpfile.Name())
+
+	// Writing the encoded value into into a temporary file.
+	// The file is in a non-standard format, aws APIs recognize the format.
+	if _, err := tmpfile.Write(data); err != nil {
+		tmpfile.Close()
+		return credentials.Value{}, errors.Wrap(err, "Failed to write aws credentials into temp file.")
+	}
+	if err := tmpfile.Close(); err != nil {
+		return credentials.Value{}, errors.Wrap(err, "Failed to close into temp file.")
+	}
+	return credentials.NewSharedCredentials(tmpfile.Name(), profile).Get()
+}
+
+// BuildAWSSharedCredentials formats the credentials as the content of an AWS shared credentials file.
+func BuildAWSSharedCredentials(accessKeyID string, secretAccessKey string, profile string) []byte {
+	if profile == "" {
+		profile = "default"
+	}
+	return []byte(fmt.Sprintf("[%s]\n%s = %s\n%s = %s\n", profile,
+		constants.AWS_ACCESS_KEY_ID, accessKeyID, constants.AWS_SECRET_ACCESS_KEY, secretAccessKey))
+}
+
+// AddCredentialsFromSecretRef resolves the credentials referenced by the repository and adds them to the
+// repository params. It is expected to be called only when the repository is about to be accessed.
+func AddCredentialsFromSecretRef(params map[string]interface{}, credentialRef *backupdriverv1api.SecretKeyReference,
+	kubeClient kubernetes.Interface, logger logrus.FieldLogger) error {
+	secret, err := kubeClient.CoreV1().Secrets(credentialRef.Namespace).Get(context.TODO(), credentialRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to retrieve the repository credential Secret %s/%s", credentialRef.Namespace, credentialRef.Name)
+	}
+	data, ok := secret.Data[credentialRef.Key]
+	if !ok {
+		return errors.Errorf("Key %s is not found in the repository credential Secret %s/%s", credentialRef.Key, credentialRef.Namespace, credentialRef.Name)
+	}
+	profile, _ := params["profile"].(string)
+	credential, err := ParseAWSSharedCredentials(data, profile)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to extract credentials for profile %s from the Secret %s/%s", profile, credentialRef.Namespace, credentialRef.Name)
+	}
+	params[constants.AWS_ACCESS_KEY_ID] = credential.AccessKeyID
+	params[constants.AWS_SECRET_ACCESS_KEY] = credential.SecretAccessKey
+	logger.Debugf("Resolved the repository credentials from the Secret %s/%s", credentialRef.Namespace, credentialRef.Name)
 	return nil
 }
 
@@ -313,6 +393,12 @@ func RetrieveVSLFromVeleroBSLs(params map[string]interface{}, bslName string, co
 	params["s3ForcePathStyle"] = backupStorageLocation.Spec.Config["s3ForcePathStyle"]
 	params["s3Url"] = backupStorageLocation.Spec.Config["s3Url"]
 	params["profile"] = backupStorageLocation.Spec.Config["profile"]
+	if bandwidthLimit, ok := backupStorageLocation.Spec.Config[constants.RepositoryParamBandwidthLimit]; ok {
+		params[constants.RepositoryParamBandwidthLimit] = bandwidthLimit
+	}
+	if compression, ok := backupStorageLocation.Spec.Config[constants.RepositoryParamCompression]; ok {
+		params[constants.RepositoryParamCompression] = compression
+	}
 
 	if backupStorageLocation.Spec.ObjectStorage.CACert != nil {
 		params["caCert"] = string(backupStorageLocation.Spec.ObjectStorage.CACert)
@@ -440,6 +526,45 @@ func GetDefaultS3PETM(logger logrus.FieldLogger) (*s3repository.ProtectedEntityT
 	return s3PETM, nil
 }
 
+func GetFileSystemPETMFromParamsMap(params map[string]interface{}, logger logrus.FieldLogger) (*fsrepository.ProtectedEntityTypeManager, error) {
+	serviceType := "ivd"
+	path, ok := GetStringFromParamsMap(params, constants.RepositoryConfigPathKey, logger)
+	if !ok || path == "" {
+		return nil, errors.New("Missing path param, cannot initialize file system PETM")
+	}
+
+	prefix, ok := params["prefix"].(string)
+	if !ok {
+		prefix = constants.DefaultS3RepoPrefix
+	}
+	fsPETM, err := fsrepository.NewFileSystemRepositoryProtectedEntityTypeManager(serviceType, path, prefix, logger)
+	if err != nil {
+		logger.WithError(err).Errorf("Error at creating new file system PETM from serviceType: %s, path: %s",
+			serviceType, path)
+		return nil, err
+	}
+
+	return fsPETM, nil
+}
+
+// RetrieveRepositoryConfig returns the data of the repository ConfigMap in the velero namespace,
+// or nil if the ConfigMap does not exist, in which case the object store of the BSL is used as repository.
+func RetrieveRepositoryConfig(kubeClient kubernetes.Interface, veleroNs string) (map[string]string, error) {
+	configMap, err := kubeClient.CoreV1().ConfigMaps(veleroNs).Get(context.TODO(), constants.RepositoryConfigMap, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Failed to retrieve the repository ConfigMap %s/%s", veleroNs, constants.RepositoryConfigMap)
+	}
+	return configMap.Data, nil
+}
+
+// IsFileSystemRepositoryConfigured checks if the repository ConfigMap selects the file system repository driver
+func IsFileSystemRepositoryConfigured(repositoryConfig map[string]string) bool {
+	return strings.ToLower(repositoryConfig[constants.RepositoryConfigDriverKey]) == constants.RepositoryDriverFileSystem
+}
+
 func GetStringFromParamsMap(params map[string]interface{}, key string, logger logrus.FieldLogger) (value string, ok bool) {
 	valueIF, ok := params[key]
 	if ok {
@@ -664,7 +789,8 @@ func GetClusterFlavor(config *rest.Config) (constants.ClusterFlavor, error) {
  * where the para virt backup driver secret will be written.
  * 2. Wait for the para virt backup driver secret to be written.
  * 3. Get the supervisor cluster configuration from the cert and token in the secret.
- * TODO: Handle update of the para virt backup driver secret
+ * Updates of the para virt backup driver secret are handled by the backup driver, which
+ * rebuilds its supervisor clients with NewSupervisorConfigFromSecret.
  */
 func GetSupervisorConfig(guestConfig *rest.Config, logger logrus.FieldLogger) (*rest.Config, string, error) {
 	var err error
@@ -706,6 +832,13 @@ func GetSupervisorConfig(guestConfig *rest.Config, logger logrus.FieldLogger) (*
 		}
 	}
 
+	svcConfig, svcNamespace := NewSupervisorConfigFromSecret(secret, GetSupervisorEndpoint(clientset, logger))
+	return svcConfig, svcNamespace, nil
+}
+
+// NewSupervisorConfigFromSecret creates the configuration to access the given Supervisor Cluster endpoint
+// with the cert and token in the para virt backup driver secret, and returns it with the supervisor namespace.
+func NewSupervisorConfigFromSecret(secret *k8sv1.Secret, endpoint string) (*rest.Config, string) {
 	// Get data from the secret
 	svcNamespace := string(secret.Data["namespace"])
 	svcCrt := secret.Data["ca.crt"]
@@ -716,11 +849,71 @@ func GetSupervisorConfig(guestConfig *rest.Config, logger logrus.FieldLogger) (*
 	tlsClientConfig.CAData = svcCrt
 
 	return &rest.Config{
-		// TODO: switch to using cluster DNS.
-		Host:            "https://" + net.JoinHostPort(constants.PvApiEndpoint, constants.PvPort),
+		Host:            "https://" + endpoint,
 		TLSClientConfig: tlsClientConfig,
 		BearerToken:     svcToken,
-	}, svcNamespace, nil
+	}, svcNamespace
+}
+
+var pvCSIConfigEntryRegexp = regexp.MustCompile(`^\s*(\w[\w-]*)\s*=\s*"?([^"]*?)"?\s*$`)
+
+// parsePvCSIEndpoint returns the Supervisor Cluster endpoint and port in the [GC] section of the pvCSI config.
+func parsePvCSIEndpoint(config string) (string, string) {
+	var host, port string
+	inGCSection := false
+	for _, line := range strings.Split(config, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inGCSection = line == "[GC]"
+			continue
+		}
+		if !inGCSection {
+			continue
+		}
+		if match := pvCSIConfigEntryRegexp.FindStringSubmatch(line); match != nil {
+			switch match[1] {
+			case "endpoint":
+				host = match[2]
+			case "port":
+				port = match[2]
+			}
+		}
+	}
+	return host, port
+}
+
+// GetSupervisorEndpoint returns the <host>:<port> of the Supervisor Cluster. The endpoint configured with the
+// SUPERVISOR_ENDPOINT env variable takes precedence, otherwise it is discovered from the pvCSI ConfigMap. If both
+// are unavailable, the default supervisor.default.svc:6443 is used.
+func GetSupervisorEndpoint(clientset kubernetes.Interface, logger logrus.FieldLogger) string {
+	host, port := constants.PvApiEndpoint, constants.PvPort
+	if endpoint := os.Getenv(constants.PvApiEndpointEnvVar); endpoint != "" {
+		if envHost, envPort, err := net.SplitHostPort(endpoint); err == nil {
+			host, port = envHost, envPort
+		} else {
+			host = endpoint
+		}
+		logger.Infof("Using the Supervisor Cluster endpoint %s configured by %s", net.JoinHostPort(host, port), constants.PvApiEndpointEnvVar)
+		return net.JoinHostPort(host, port)
+	}
+
+	configMap, err := clientset.CoreV1().ConfigMaps(constants.PvCSIConfigMapNamespace).Get(context.TODO(), constants.PvCSIConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		logger.WithError(err).Warnf("Failed to discover the Supervisor Cluster endpoint from ConfigMap %s/%s, using the default %s",
+			constants.PvCSIConfigMapNamespace, constants.PvCSIConfigMapName, net.JoinHostPort(host, port))
+		return net.JoinHostPort(host, port)
+	}
+	for _, data := range configMap.Data {
+		configHost, configPort := parsePvCSIEndpoint(data)
+		if configHost != "" {
+			host = configHost
+		}
+		if configPort != "" {
+			port = configPort
+		}
+	}
+	logger.Infof("Using the Supervisor Cluster endpoint %s", net.JoinHostPort(host, port))
+	return net.JoinHostPort(host, port)
 }
 
 /*
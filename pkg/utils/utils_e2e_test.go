@@ -74,7 +74,7 @@ func TestRetrieveParamsFromBSL(t *testing.T) {
 	for _, item := range backupStorageLocationList.Items {
 		repositoryParameters := make(map[string]string)
 		bslName := item.Name
-		err := RetrieveParamsFromBSL(repositoryParameters, bslName, config, logger)
+		_, err := RetrieveParamsFromBSL(repositoryParameters, bslName, config, logger)
 		if err != nil {
 			logger.Errorf("Retrieve Failed %v", err)
 			t.Fatalf("RetrieveParamsFromBSL failed!")
@@ -25,6 +25,9 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"k8s.io/client-go/rest"
+	k8sv1 "k8s.io/api/core/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -158,11 +161,105 @@ func TestRerieveVcConfigSecret(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			lines := strings.Split(test.sEnc, "\n")
-			params := make(map[string]interface{})
-			ParseLines(lines, params, logger)
-			assert.Equal(t, test.vc, params["VirtualCenter"])
-			assert.Equal(t, test.password, params["password"])
+			vsphereConfig, err := ParseVSphereConfig(test.sEnc)
+			require.NoError(t, err)
+			require.Len(t, vsphereConfig.VirtualCenters, 1)
+			assert.Equal(t, test.vc, vsphereConfig.VirtualCenters[0].Host)
+			assert.Equal(t, test.password, vsphereConfig.VirtualCenters[0].Password)
+		})
+	}
+}
+
+func TestParseVSphereConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		sEnc     string
+		expected []map[string]interface{}
+		warnings []string
+		err      string
+	}{
+		{
+			name: "Single VirtualCenter",
+			sEnc: "[Global]\ncluster-id = \"cluster-1\"\n\n[VirtualCenter \"vc-1.example.com\"]\nuser = \"admin\"\npassword = \"G4\\t=4t\"\nport = \"8443\"\ndatacenters = \"dc-1\"",
+			expected: []map[string]interface{}{
+				{"VirtualCenter": "vc-1.example.com", "cluster-id": "cluster-1", "user": "admin", "password": "G4\t=4t", "port": "8443", "insecure-flag": "false", "datacenters": "dc-1"},
+			},
+		},
+		{
+			name: "Multiple VirtualCenters inheriting the Global section",
+			sEnc: "[Global]\ncluster-id = \"cluster-1\"\nuser = \"admin\"\npassword = \"secret\"\ninsecure-flag = \"true\"\n\n[VirtualCenter \"vc-1.example.com\"]\ndatacenters = \"dc-1\"\n\n[VirtualCenter \"vc-2.example.com\"]\nuser = \"admin-2\"\nport = \"8443\"\nca-file = \"/etc/ssl/vc-2.pem\"\ndatacenters = \"dc-2\"\n\n[Labels]\nzone = \"k8s-zone\"",
+			expected: []map[string]interface{}{
+				{"VirtualCenter": "vc-1.example.com", "cluster-id": "cluster-1", "user": "admin", "password": "secret", "port": "443", "insecure-flag": "true", "datacenters": "dc-1"},
+				{"VirtualCenter": "vc-2.example.com", "cluster-id": "cluster-1", "user": "admin-2", "password": "secret", "port": "8443", "insecure-flag": "true", "datacenters": "dc-2"},
+			},
+			warnings: []string{"ca-file is ignored for the VirtualCenter vc-2.example.com, the vCenter certificate is not verified as insecure-flag = true"},
+		},
+		{
+			name: "Comments, CRLF line endings, unquoted values and case insensitive names",
+			sEnc: "; vSphere config\r\n[global]\r\nCluster-ID = cluster-1 # the cluster\r\n\r\n[VirtualCenter \"fd00::10\"] ; IPv6\r\nuser = admin@vsphere.local\r\npassword = \"p;a#s=s\" ; quoted\r\nthumbprint = 3E:8B:1A\r\ninsecure-flag\r\n",
+			expected: []map[string]interface{}{
+				{"VirtualCenter": "fd00::10", "cluster-id": "cluster-1", "user": "admin@vsphere.local", "password": "p;a#s=s", "port": "443", "insecure-flag": "true"},
+			},
+			warnings: []string{"thumbprint is ignored for the VirtualCenter fd00::10, the vCenter certificate is not verified as insecure-flag = true"},
+		},
+		{
+			name: "IPv6 VirtualCenter in brackets and continued value",
+			sEnc: "[VirtualCenter \"[fd00::10]\"]\ncluster-id = cluster-1\nuser = admin\npassword = \"sec\\\nret\"",
+			expected: []map[string]interface{}{
+				{"VirtualCenter": "fd00::10", "cluster-id": "cluster-1", "user": "admin", "password": "secret", "port": "443", "insecure-flag": "false"},
+			},
+		},
+		{
+			name: "No VirtualCenter",
+			sEnc: "[Global]\ncluster-id = \"cluster-1\"",
+			err:  "no VirtualCenter section",
+		},
+		{
+			name: "Missing credentials",
+			sEnc: "[Global]\ncluster-id = \"cluster-1\"\n[VirtualCenter \"vc-1\"]\nuser = \"admin\"",
+			err:  "password is not set for the VirtualCenter vc-1, set it in the [VirtualCenter \"vc-1\"] or the [Global] section",
+		},
+		{
+			name: "Invalid port and insecure-flag",
+			sEnc: "[Global]\ncluster-id = c\nuser = u\npassword = p\n[VirtualCenter \"vc-1\"]\nport = 70000\ninsecure-flag = maybe",
+			err:  "invalid port \"70000\" for the VirtualCenter vc-1, the port must be a number between 1 and 65535; invalid insecure-flag \"maybe\"",
+		},
+		{
+			name: "Verified certificate with a ca-file",
+			sEnc: "[Global]\ncluster-id = c\nuser = u\npassword = p\n[VirtualCenter \"vc-1\"]\nca-file = /etc/ssl/vc-1.pem\nthumbprint = 3E:8B:1A",
+			expected: []map[string]interface{}{
+				{"VirtualCenter": "vc-1", "cluster-id": "c", "user": "u", "password": "p", "port": "443", "insecure-flag": "false"},
+			},
+			warnings: []string{
+				"ca-file is ignored for the VirtualCenter vc-1, the vCenter certificate is verified against the system CA certificates of the plugin image",
+				"thumbprint is ignored for the VirtualCenter vc-1, the vCenter certificate is verified against the system CA certificates of the plugin image",
+			},
+		},
+		{
+			name: "Unterminated quote",
+			sEnc: "[VirtualCenter \"vc-1\"]\npassword = \"secret\nuser = admin",
+			err:  "line 2: the value of the variable password has no closing quote",
+		},
+		{
+			name: "Variable outside of a section",
+			sEnc: "user = admin",
+			err:  "line 1: the variable is outside of any section",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vsphereConfig, err := ParseVSphereConfig(test.sEnc)
+			if err == nil {
+				err = vsphereConfig.Validate()
+			}
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, vsphereConfig.VcParams())
+			assert.Equal(t, test.warnings, vsphereConfig.Warnings())
 		})
 	}
 }
@@ -446,4 +543,129 @@ func TestGetS3SessionOptionsFromParamsMap(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestAddCredentialsFromSecretRef(t *testing.T) {
+	secret := &k8sv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.CloudCredentialSecretName,
+			Namespace: "velero",
+		},
+		Data: map[string][]byte{
+			constants.CloudCredentialSecretKey: []byte("[default]\naws_access_key_id = id-default\naws_secret_access_key = key-default\n" +
+				"[other]\naws_access_key_id = id-other\naws_secret_access_key = key-other\n"),
+		},
+	}
+	tests := []struct {
+		name              string
+		profile           interface{}
+		key               string
+		expectedErr       bool
+		expectedKeyID     string
+		expectedSecretKey string
+	}{
+		{
+			name:              "Credentials of the default profile are resolved if no profile is specified",
+			key:               constants.CloudCredentialSecretKey,
+			expectedKeyID:     "id-default",
+			expectedSecretKey: "key-default",
+		},
+		{
+			name:              "Credentials of the specified profile are resolved",
+			profile:           "other",
+			key:               constants.CloudCredentialSecretKey,
+			expectedKeyID:     "id-other",
+			expectedSecretKey: "key-other",
+		},
+		{
+			name:        "Missing profile in the Secret",
+			profile:     "missing",
+			key:         constants.CloudCredentialSecretKey,
+			expectedErr: true,
+		},
+		{
+			name:        "Missing key in the Secret",
+			key:         "missing",
+			expectedErr: true,
+		},
+	}
+	logger := veleroplugintest.NewLogger()
+	kubeClient := kubefake.NewSimpleClientset(secret)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := map[string]interface{}{"region": "us-west-1"}
+			if test.profile != nil {
+				params["profile"] = test.profile
+			}
+			credentialRef := &backupdriverapi.SecretKeyReference{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+				Key:       test.key,
+			}
+			err := AddCredentialsFromSecretRef(params, credentialRef, kubeClient, logger)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedKeyID, params[constants.AWS_ACCESS_KEY_ID])
+			assert.Equal(t, test.expectedSecretKey, params[constants.AWS_SECRET_ACCESS_KEY])
+		})
+	}
+}
+
+func TestGetSupervisorEndpoint(t *testing.T) {
+	pvCSIConfig := `[GC]
+endpoint = "10.0.0.10"
+port = "6444"
+tanzukubernetescluster-name = "tkc-1"
+[Global]
+port = "443"
+`
+	tests := []struct {
+		name             string
+		envEndpoint      string
+		configMapData    map[string]string
+		expectedEndpoint string
+	}{
+		{
+			name:             "Default endpoint without ConfigMap",
+			expectedEndpoint: "supervisor.default.svc:6443",
+		},
+		{
+			name:             "Endpoint discovered from ConfigMap",
+			configMapData:    map[string]string{"cns-csi.conf": pvCSIConfig},
+			expectedEndpoint: "10.0.0.10:6444",
+		},
+		{
+			name:             "Endpoint configured by env variable",
+			envEndpoint:      "10.0.0.20:6445",
+			configMapData:    map[string]string{"cns-csi.conf": pvCSIConfig},
+			expectedEndpoint: "10.0.0.20:6445",
+		},
+		{
+			name:             "Host configured by env variable",
+			envEndpoint:      "10.0.0.20",
+			expectedEndpoint: "10.0.0.20:6443",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset()
+			if test.configMapData != nil {
+				kubeClient = kubefake.NewSimpleClientset(&k8sv1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      constants.PvCSIConfigMapName,
+						Namespace: constants.PvCSIConfigMapNamespace,
+					},
+					Data: test.configMapData,
+				})
+			}
+			if test.envEndpoint != "" {
+				os.Setenv(constants.PvApiEndpointEnvVar, test.envEndpoint)
+				defer os.Unsetenv(constants.PvApiEndpointEnvVar)
+			}
+			endpoint := GetSupervisorEndpoint(kubeClient, logrus.New())
+			require.Equal(t, test.expectedEndpoint, endpoint)
+		})
+	}
+}
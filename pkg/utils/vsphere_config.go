@@ -0,0 +1,468 @@
+/*
+Copyright 2020 the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware-tanzu/astrolabe/pkg/common/vsphere"
+	"github.com/vmware-tanzu/velero-plugin-for-vsphere/pkg/constants"
+)
+
+// The params of a VirtualCenter which are not supported by astrolabe, the certificate of a vCenter is either verified
+// against the system CA certificates of the plugin image or not at all, see VSphereConfig.Warnings
+const (
+	CAFileVcParamKey     = "ca-file"
+	ThumbprintVcParamKey = "thumbprint"
+)
+
+// VCConfig is the config of a VirtualCenter in the vSphere config of the vSphere CSI driver.
+type VCConfig struct {
+	Host         string
+	User         string
+	Password     string
+	Port         string
+	InsecureFlag string
+	CAFile       string
+	Thumbprint   string
+	Datacenters  string
+	ClusterID    string
+}
+
+// VSphereConfig is the vSphere config of the vSphere CSI driver, i.e. the csi-vsphere.conf in the vc config secret.
+// The VirtualCenters inherit the params of the Global section which they do not set.
+type VSphereConfig struct {
+	Global VCConfig
+	// VirtualCenters are in the order of the vSphere config
+	VirtualCenters []*VCConfig
+}
+
+// ParseVSphereConfig parses the vSphere config of the vSphere CSI driver, which is in the gcfg (INI) format of
+// gopkg.in/gcfg.v1. Only the Global and VirtualCenter sections are read, the other sections are skipped.
+func ParseVSphereConfig(data string) (*VSphereConfig, error) {
+	sections, err := parseGcfg(data)
+	if err != nil {
+		return nil, err
+	}
+	vsphereConfig := &VSphereConfig{}
+	for _, section := range sections {
+		var vcConfig *VCConfig
+		switch section.name {
+		case "global":
+			vcConfig = &vsphereConfig.Global
+		case "virtualcenter":
+			host := section.subsection
+			// An IPv6 address may be in brackets as in a URL
+			if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") && net.ParseIP(host[1:len(host)-1]) != nil {
+				host = host[1 : len(host)-1]
+			}
+			if host == "" {
+				return nil, errors.Errorf("line %d: the VirtualCenter section has no host, use [VirtualCenter \"<vCenter host>\"]", section.line)
+			}
+			// The sections of the same VirtualCenter are merged
+			for _, virtualCenter := range vsphereConfig.VirtualCenters {
+				if virtualCenter.Host == host {
+					vcConfig = virtualCenter
+				}
+			}
+			if vcConfig == nil {
+				vcConfig = &VCConfig{Host: host}
+				vsphereConfig.VirtualCenters = append(vsphereConfig.VirtualCenters, vcConfig)
+			}
+		default:
+			continue
+		}
+		for _, variable := range section.variables {
+			setVCConfigVariable(vcConfig, variable.name, variable.value)
+		}
+	}
+	for _, vcConfig := range vsphereConfig.VirtualCenters {
+		vcConfig.inherit(&vsphereConfig.Global)
+	}
+	return vsphereConfig, nil
+}
+
+func setVCConfigVariable(vcConfig *VCConfig, name string, value string) {
+	switch name {
+	case vsphere.UserVcParamKey:
+		vcConfig.User = value
+	case vsphere.PasswordVcParamKey:
+		vcConfig.Password = value
+	case vsphere.PortVcParamKey:
+		vcConfig.Port = value
+	case vsphere.InsecureFlagVcParamKey:
+		vcConfig.InsecureFlag = value
+	case CAFileVcParamKey:
+		vcConfig.CAFile = value
+	case ThumbprintVcParamKey:
+		vcConfig.Thumbprint = value
+	case vsphere.DatacenterVcParamKey:
+		vcConfig.Datacenters = value
+	case vsphere.ClusterVcParamKey:
+		vcConfig.ClusterID = value
+	}
+}
+
+func (this *VCConfig) inherit(global *VCConfig) {
+	inherit := func(value *string, globalValue string) {
+		if *value == "" {
+			*value = globalValue
+		}
+	}
+	inherit(&this.User, global.User)
+	inherit(&this.Password, global.Password)
+	inherit(&this.Port, global.Port)
+	inherit(&this.InsecureFlag, global.InsecureFlag)
+	inherit(&this.CAFile, global.CAFile)
+	inherit(&this.Thumbprint, global.Thumbprint)
+	inherit(&this.Datacenters, global.Datacenters)
+	inherit(&this.ClusterID, global.ClusterID)
+}
+
+// Validate checks that the params needed to connect to each VirtualCenter are set and valid. The returned error
+// lists all the problems found, along with where to fix them.
+func (this *VSphereConfig) Validate() error {
+	if len(this.VirtualCenters) == 0 {
+		return errors.New("no VirtualCenter section, add a [VirtualCenter \"<vCenter host>\"] section for each vCenter of the cluster")
+	}
+	var problems []string
+	for _, vcConfig := range this.VirtualCenters {
+		missing := func(key string) {
+			problems = append(problems, fmt.Sprintf("%s is not set for the VirtualCenter %s, set it in the [VirtualCenter %q] or the [Global] section",
+				key, vcConfig.Host, vcConfig.Host))
+		}
+		if vcConfig.User == "" {
+			missing(vsphere.UserVcParamKey)
+		}
+		if vcConfig.Password == "" {
+			missing(vsphere.PasswordVcParamKey)
+		}
+		if vcConfig.ClusterID == "" {
+			missing(vsphere.ClusterVcParamKey)
+		}
+		if vcConfig.Port != "" {
+			if port, err := strconv.Atoi(vcConfig.Port); err != nil || port < 1 || port > 65535 {
+				problems = append(problems, fmt.Sprintf("invalid port %q for the VirtualCenter %s, the port must be a number between 1 and 65535",
+					vcConfig.Port, vcConfig.Host))
+			}
+		}
+		if vcConfig.InsecureFlag != "" {
+			if _, err := parseGcfgBool(vcConfig.InsecureFlag); err != nil {
+				problems = append(problems, fmt.Sprintf("invalid insecure-flag %q for the VirtualCenter %s, the insecure-flag must be true or false",
+					vcConfig.InsecureFlag, vcConfig.Host))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Warnings returns the params of the VirtualCenters which are set but ignored. The connections to the vCenters cannot
+// use the ca-file or the thumbprint, so the certificate of a vCenter is verified against the system CA certificates
+// of the plugin image unless the insecure-flag is set, as the vSphere CSI driver accepts them along with either.
+func (this *VSphereConfig) Warnings() []string {
+	var warnings []string
+	for _, vcConfig := range this.VirtualCenters {
+		insecure, _ := parseGcfgBool(vcConfig.InsecureFlag)
+		for _, param := range [][2]string{{CAFileVcParamKey, vcConfig.CAFile}, {ThumbprintVcParamKey, vcConfig.Thumbprint}} {
+			key, value := param[0], param[1]
+			if value == "" {
+				continue
+			}
+			if insecure {
+				warnings = append(warnings, fmt.Sprintf("%s is ignored for the VirtualCenter %s, the vCenter certificate is not verified as insecure-flag = true",
+					key, vcConfig.Host))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("%s is ignored for the VirtualCenter %s, the vCenter certificate is verified against the system CA certificates of the plugin image",
+					key, vcConfig.Host))
+			}
+		}
+	}
+	return warnings
+}
+
+// VcParams returns the params of each VirtualCenter, in the order of the vSphere config. The port defaults to the
+// standard https port, and the insecure-flag to false. The config should have been validated.
+func (this *VSphereConfig) VcParams() []map[string]interface{} {
+	var vcParams []map[string]interface{}
+	for _, vcConfig := range this.VirtualCenters {
+		params := map[string]interface{}{
+			vsphere.HostVcParamKey:         vcConfig.Host,
+			vsphere.UserVcParamKey:         vcConfig.User,
+			vsphere.PasswordVcParamKey:     vcConfig.Password,
+			vsphere.PortVcParamKey:         constants.DefaultVCenterPort,
+			vsphere.InsecureFlagVcParamKey: "false",
+			vsphere.ClusterVcParamKey:      vcConfig.ClusterID,
+		}
+		if vcConfig.Port != "" {
+			params[vsphere.PortVcParamKey] = vcConfig.Port
+		}
+		if insecure, err := parseGcfgBool(vcConfig.InsecureFlag); err == nil {
+			params[vsphere.InsecureFlagVcParamKey] = strconv.FormatBool(insecure)
+		}
+		if vcConfig.Datacenters != "" {
+			params[vsphere.DatacenterVcParamKey] = vcConfig.Datacenters
+		}
+		vcParams = append(vcParams, params)
+	}
+	return vcParams
+}
+
+// parseGcfgBool parses a bool value of gcfg, where a variable without a value is true.
+func parseGcfgBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, errors.Errorf("invalid bool %q", value)
+}
+
+type gcfgVariable struct {
+	// name is lower case, the names of the variables are case insensitive
+	name  string
+	value string
+}
+
+type gcfgSection struct {
+	// name is lower case, the names of the sections are case insensitive
+	name       string
+	subsection string
+	line       int
+	variables  []gcfgVariable
+}
+
+// gcfgParser parses the gcfg format: the sections are [name] or [name "subsection"], the variables are name = value,
+// or just name for true, and the comments start with ; or #. The values may be quoted, the quotes, the backslashes
+// and the \n, \t and \b escapes are unescaped, and a backslash at the end of a line continues the value on the next
+// line. The whitespace around the unquoted parts of a value is trimmed.
+type gcfgParser struct {
+	data string
+	pos  int
+	line int
+}
+
+func parseGcfg(data string) ([]*gcfgSection, error) {
+	data = strings.TrimPrefix(data, "\ufeff")
+	data = strings.Replace(data, "\r\n", "\n", -1)
+	parser := &gcfgParser{data: data, line: 1}
+	var sections []*gcfgSection
+	var section *gcfgSection
+	for {
+		parser.skipBlanks()
+		c, ok := parser.peek()
+		switch {
+		case !ok:
+			return sections, nil
+		case c == '\n':
+			parser.pos++
+			parser.line++
+			continue
+		case c == ';' || c == '#':
+			parser.skipComment()
+			continue
+		case c == '[':
+			var err error
+			section, err = parser.parseSectionHeader()
+			if err != nil {
+				return nil, err
+			}
+			sections = append(sections, section)
+		case isGcfgNameChar(c):
+			if section == nil {
+				return nil, parser.errorf("the variable is outside of any section")
+			}
+			variable, err := parser.parseVariable()
+			if err != nil {
+				return nil, err
+			}
+			section.variables = append(section.variables, variable)
+		default:
+			return nil, parser.errorf("unexpected %q", c)
+		}
+		if err := parser.parseLineEnd(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (this *gcfgParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("line %d: %s", this.line, fmt.Sprintf(format, args...))
+}
+
+func (this *gcfgParser) peek() (byte, bool) {
+	if this.pos >= len(this.data) {
+		return 0, false
+	}
+	return this.data[this.pos], true
+}
+
+func (this *gcfgParser) skipBlanks() {
+	for this.pos < len(this.data) && (this.data[this.pos] == ' ' || this.data[this.pos] == '\t') {
+		this.pos++
+	}
+}
+
+func (this *gcfgParser) skipComment() {
+	for this.pos < len(this.data) && this.data[this.pos] != '\n' {
+		this.pos++
+	}
+}
+
+// parseLineEnd parses the rest of a line after a section header or a variable, which may only be a comment.
+func (this *gcfgParser) parseLineEnd() error {
+	this.skipBlanks()
+	c, ok := this.peek()
+	switch {
+	case !ok || c == '\n':
+		return nil
+	case c == ';' || c == '#':
+		this.skipComment()
+		return nil
+	}
+	return this.errorf("unexpected %q at the end of the line", c)
+}
+
+func isGcfgNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+}
+
+func (this *gcfgParser) parseName() string {
+	start := this.pos
+	for this.pos < len(this.data) && isGcfgNameChar(this.data[this.pos]) {
+		this.pos++
+	}
+	return strings.ToLower(this.data[start:this.pos])
+}
+
+func (this *gcfgParser) parseSectionHeader() (*gcfgSection, error) {
+	section := &gcfgSection{line: this.line}
+	// Skip the [
+	this.pos++
+	this.skipBlanks()
+	section.name = this.parseName()
+	if section.name == "" {
+		return nil, this.errorf("the section has no name")
+	}
+	this.skipBlanks()
+	if c, ok := this.peek(); ok && c == '"' {
+		this.pos++
+		var subsection strings.Builder
+		for {
+			c, ok := this.peek()
+			if !ok || c == '\n' {
+				return nil, this.errorf("the subsection of the section %s has no closing quote", section.name)
+			}
+			this.pos++
+			if c == '"' {
+				break
+			}
+			if c == '\\' {
+				if escaped, ok := this.peek(); ok && (escaped == '\\' || escaped == '"') {
+					c = escaped
+					this.pos++
+				} else {
+					return nil, this.errorf("invalid escape in the subsection of the section %s, only \\\\ and \\\" are allowed", section.name)
+				}
+			}
+			subsection.WriteByte(c)
+		}
+		section.subsection = subsection.String()
+		this.skipBlanks()
+	}
+	if c, ok := this.peek(); !ok || c != ']' {
+		return nil, this.errorf("the header of the section %s has no closing ]", section.name)
+	}
+	this.pos++
+	return section, nil
+}
+
+func (this *gcfgParser) parseVariable() (gcfgVariable, error) {
+	variable := gcfgVariable{name: this.parseName()}
+	this.skipBlanks()
+	c, ok := this.peek()
+	if !ok || c == '\n' || c == ';' || c == '#' {
+		// A variable without a value is true
+		variable.value = "true"
+		return variable, nil
+	}
+	if c != '=' {
+		return variable, this.errorf("expected = after the variable %s", variable.name)
+	}
+	this.pos++
+	this.skipBlanks()
+
+	var value strings.Builder
+	// The length of the value without the trailing unquoted whitespace
+	length := 0
+	quoted := false
+	for {
+		c, ok := this.peek()
+		if !ok || c == '\n' {
+			if quoted {
+				return variable, this.errorf("the value of the variable %s has no closing quote", variable.name)
+			}
+			break
+		}
+		if !quoted && (c == ';' || c == '#') {
+			break
+		}
+		this.pos++
+		switch {
+		case c == '"':
+			quoted = !quoted
+			continue
+		case c == '\\':
+			escaped, ok := this.peek()
+			if !ok {
+				return variable, this.errorf("the value of the variable %s ends with a backslash", variable.name)
+			}
+			this.pos++
+			switch escaped {
+			case '\n':
+				// The value continues on the next line
+				this.line++
+				continue
+			case '\\', '"':
+				c = escaped
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			default:
+				return variable, this.errorf("invalid escape \\%c in the value of the variable %s, quote the value and escape the backslashes as \\\\",
+					escaped, variable.name)
+			}
+		case !quoted && (c == ' ' || c == '\t'):
+			value.WriteByte(c)
+			continue
+		}
+		value.WriteByte(c)
+		length = value.Len()
+	}
+	variable.value = value.String()[:length]
+	return variable, nil
+}